@@ -2,38 +2,60 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
+	// embed the IANA time zone database; user time zones are validated against it
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/unibazzar/auth-service/internal/config"
+	"github.com/unibazzar/auth-service/internal/domain"
 	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
 	"github.com/unibazzar/auth-service/internal/repo"
 	"github.com/unibazzar/auth-service/internal/services"
-	"github.com/unibazzar/auth-service/internal/transport/http"
+	grpctransport "github.com/unibazzar/auth-service/internal/transport/grpc"
+	httptransport "github.com/unibazzar/auth-service/internal/transport/http"
+	"github.com/unibazzar/auth-service/migrations"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const serviceName = "auth-service"
 const serviceVersion = "1.0.0"
 
 func main() {
+	migrateOnly := flag.Bool("migrate-only", false, "apply pending database migrations and exit")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
 	}
 
+	// Structured JSON logs; the standard logger writes through it too
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel}))
+	slog.SetDefault(logger)
+
 	// Initialize OpenTelemetry
 	ctx := context.Background()
-	tracer, err := initTracer(ctx, cfg.OTELEndpoint)
+	tracer, err := initTracer(ctx, cfg.OTELCollectorAddress())
 	if err != nil {
 		log.Fatalf("Failed to initialize tracer: %v", err)
 	}
@@ -44,32 +66,284 @@ func main() {
 	}()
 
 	// Initialize database connection
-	db, err := repo.NewPostgresDB(cfg.DatabaseURL)
+	pool := repo.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+	db, err := repo.NewPostgresDB(cfg.DatabaseURL, pool)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	repo.RegisterPoolMetrics("primary", db)
+
+	if cfg.AutoMigrate || *migrateOnly {
+		applied, err := repo.Migrate(ctx, db, migrations.FS)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		log.Printf("Database schema up to date, %d migrations applied", applied)
+	}
+	if *migrateOnly {
+		return
+	}
+
+	var replicaDB *sql.DB
+	if cfg.DatabaseReplicaURL != "" {
+		replicaDB, err = repo.NewPostgresDB(cfg.DatabaseReplicaURL, pool)
+		if err != nil {
+			log.Fatalf("Failed to connect to database replica: %v", err)
+		}
+		defer replicaDB.Close()
+		repo.RegisterPoolMetrics("replica", replicaDB)
+	}
+	readRouter := repo.NewReadRouter(db, replicaDB, cfg.ReadYourWritesWindow)
+
+	// Initialize token signing keys
+	signingKeys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	mfaRequiredRoles, err := parseRoles(cfg.MFARequiredRoles)
+	if err != nil {
+		log.Fatalf("Invalid MFA_REQUIRED_ROLES: %v", err)
+	}
+
+	passwordPolicy, err := buildPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_ROLE_RULES: %v", err)
+	}
+
+	peppers := buildPeppers(cfg)
+
+	lockoutScope, err := ratelimit.ParseLockoutScope(cfg.LoginLockoutScope)
+	if err != nil {
+		log.Fatalf("Invalid LOGIN_LOCKOUT_SCOPE: %v", err)
+	}
 
 	// Initialize repositories
-	userRepo := repo.NewPostgresUserRepo(db)
+	userRepo := repo.NewPostgresUserRepo(readRouter)
+	sessionRepo := repo.NewPostgresSessionRepo(db)
+	passwordResetRepo := repo.NewPostgresPasswordResetRepo(db)
+	verificationTokenRepo := repo.NewPostgresVerificationTokenRepo(db)
+	trustedDeviceRepo := repo.NewPostgresTrustedDeviceRepo(db)
+	mfaMethodRepo := repo.NewPostgresMFAMethodRepo(db)
+	consentRepo := repo.NewPostgresConsentRepo(db)
+	auditRepo := repo.NewPostgresAuditRepo(db)
+	revocationCutoffRepo := repo.NewPostgresRevocationCutoffRepo(db)
+	oauthClientRepo := repo.NewPostgresOAuthClientRepo(db)
+	reverificationJobRepo := repo.NewPostgresReverificationJobRepo(db)
+	deviceLoginRepo := repo.NewPostgresDeviceLoginRepo(db)
+	signingKeyRepo := repo.NewPostgresSigningKeyRepo(db)
+	accessTokenRepo := repo.NewPostgresAccessTokenRepo(db)
+	appAuthorizationRepo := repo.NewPostgresAppAuthorizationRepo(db)
+	verificationReminderRepo := repo.NewPostgresVerificationReminderRepo(db)
+	delegationRepo := repo.NewPostgresDelegationRepo(db)
+	oauthIdentityRepo := repo.NewPostgresOAuthIdentityRepo(db)
+	outboxRepo := repo.NewPostgresOutboxRepo(db)
+	transactor := repo.NewPostgresTransactor(readRouter)
 	
+	// Rotate the signing keys on a schedule; the persisted generations replace the configured key
+	var keyRotator *services.KeyRotator
+	if cfg.KeyRotationInterval > 0 {
+		keyRotator = services.NewKeyRotator(signingKeyRepo, signingKeys, services.KeyRotationConfig{
+			Interval: cfg.KeyRotationInterval,
+			Lead:     cfg.KeyRotationLead,
+		})
+		if err := keyRotator.Load(ctx); err != nil {
+			log.Fatalf("Failed to load rotated signing keys: %v", err)
+		}
+		go keyRotator.Run(ctx)
+	}
+
+	// Access tokens are JWTs of either configured algorithm or opaque, per client
+	alternateKeys, err := loadAlternateKeys(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load alternate signing keys: %v", err)
+	}
+	// Revoked access tokens are shared through Redis when it is configured,
+	// so a revocation reaches every instance and survives restarts
+	var blacklist services.TokenBlacklist = services.NewMemoryBlacklist()
+	if cfg.RedisURL != "" {
+		redisBlacklist, err := services.NewRedisBlacklist(cfg.RedisURL, cfg.RedisPassword, cfg.RedisDB)
+		if err != nil {
+			log.Fatalf("Failed to initialize token blacklist: %v", err)
+		}
+		defer redisBlacklist.Close()
+		blacklist = redisBlacklist
+	}
+	accessTokens := services.NewAccessTokens(signingKeys, alternateKeys, accessTokenRepo, blacklist, cfg.AccessTokenTTL)
+	activeUsers := services.NewActiveUsers(userRepo, cfg.ActiveUserCacheTTL)
+	go accessTokens.Run(ctx)
+
 	// Initialize event publisher
-	eventPublisher, err := events.NewRabbitMQPublisher(cfg.RabbitMQURL)
+	rabbitPublisher, err := events.NewRabbitMQPublisher(cfg.RabbitMQURL, events.PublisherConfig{
+		ConfirmTimeout: cfg.RabbitMQConfirmTimeout,
+		PublishRetries: cfg.RabbitMQPublishRetries,
+		BufferSize:     cfg.RabbitMQBufferSize,
+		UnhealthyAfter: cfg.RabbitMQUnhealthyAfter,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize event publisher: %v", err)
 	}
-	defer eventPublisher.Close()
+	defer rabbitPublisher.Close()
 
-	// Initialize services
-	userService := services.NewUserService(userRepo, eventPublisher)
-	authService := services.NewAuthService(userRepo, cfg.JWTSecret)
+	// Registration events are written to the outbox with the user and published from there
+	outboxDispatcher := services.NewOutboxDispatcher(outboxRepo, rabbitPublisher, services.OutboxConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		Retention:    cfg.OutboxRetention,
+	})
+	go outboxDispatcher.Run(ctx)
+
+	// Logins and account changes are audited off the request path
+	auditWriter := services.NewAuditWriter(auditRepo, cfg.AuditBufferSize)
+	go auditWriter.Run(ctx)
+
+	// Initialize services; consent-gated events are filtered before reaching the bus
+	privacyService := services.NewPrivacyService(userRepo, sessionRepo, trustedDeviceRepo, consentRepo)
+	eventPublisher := events.NewConsentPublisher(rabbitPublisher, privacyService)
+	// Failed logins and reactivations share one lockout, as both check passwords
+	loginLockout := ratelimit.NewLockout(ratelimit.LockoutPolicy{
+		Scope:       lockoutScope,
+		MaxFailures: cfg.LoginLockoutMaxFailures,
+		Window:      cfg.LoginLockoutWindow,
+		Duration:    cfg.LoginLockoutDuration,
+	})
+	emailVerificationService := services.NewEmailVerificationService(userRepo, verificationTokenRepo, eventPublisher, cfg.PublicURL, cfg.VerificationTokenTTL, ratelimit.NewMemoryLimiter(), cfg.DeferPIIEvents)
+	userService := services.NewUserService(userRepo, transactor, eventPublisher, emailVerificationService, passwordPolicy, peppers, domain.TimezoneDefaults{
+		Campuses: cfg.CampusTimezones,
+		Fallback: cfg.DefaultTimezone,
+	}, cfg.ReactivationWindow, ratelimit.NewMemoryLimiter(), ratelimit.Limit{
+		Requests: cfg.ProfileUpdateLimit,
+		Window:   cfg.ProfileUpdateWindow,
+	}, ratelimit.NewMemoryLimiter(), &ratelimit.Policy{
+		Default:   ratelimit.Limit{Requests: cfg.CampusRegistrationLimit, Window: cfg.CampusRegistrationWindow},
+		Overrides: ratelimit.StaticOverrides(cfg.CampusRegistrationOverrides),
+	}, auditWriter, cfg.NormalizeEmailAliases, loginLockout, activeUsers)
+	if err := userService.NormalizeEmails(ctx); err != nil {
+		log.Printf("Failed to normalize stored emails: %v", err)
+	}
+	riskAssessor := services.NewRiskAssessor(services.NoopNetworkReputation{}, services.NoopTravelAnalyzer{}, services.NoopGeoResolver{})
+	authService := services.NewAuthService(userRepo, sessionRepo, trustedDeviceRepo, mfaMethodRepo, revocationCutoffRepo, oauthClientRepo, appAuthorizationRepo, riskAssessor, eventPublisher, services.AuthConfig{
+		Keys:               signingKeys,
+		DeviceTrustTTL:     cfg.DeviceTrustTTL,
+		AccessTokens:       accessTokens,
+		RefreshTokenTTL:    cfg.RefreshTokenTTL,
+		ShortSessionTTL:    cfg.ShortSessionTTL,
+		SessionMaxAge:      cfg.SessionMaxAge,
+		Issuer:             cfg.PublicURL,
+		RefreshGraceWindow: cfg.RefreshGraceWindow,
+		Lockout:            loginLockout,
+		TwoFactorLockout: ratelimit.NewLockout(ratelimit.LockoutPolicy{
+			Scope:       ratelimit.ScopeAccount,
+			MaxFailures: cfg.TwoFactorMaxFailures,
+			Window:      cfg.LoginLockoutWindow,
+			Duration:    cfg.LoginLockoutDuration,
+		}),
+		Peppers:            peppers,
+		ReactivationWindow: cfg.ReactivationWindow,
+		PinLockoutRevokes:  cfg.PinLockoutRevokes,
+		MaxSessionsPerUser: cfg.MaxSessionsPerUser,
+		Audit:              auditWriter,
+	})
+	twoFactorService := services.NewTwoFactorService(userRepo, trustedDeviceRepo, mfaMethodRepo, eventPublisher, mfaRequiredRoles)
+	phoneService := services.NewPhoneService(userRepo, verificationTokenRepo, eventPublisher, cfg.EnforceUniquePhones)
+	adminService := services.NewAdminService(userRepo, sessionRepo, auditRepo, revocationCutoffRepo, transactor, eventPublisher, passwordPolicy, accessTokens, activeUsers)
+	passwordResetService := services.NewPasswordResetService(userRepo, passwordResetRepo, sessionRepo, transactor, eventPublisher, accessTokens, passwordPolicy, peppers)
+	oauthClientService := services.NewOAuthClientService(oauthClientRepo, accessTokens)
+	// Re-verification jobs and verification reminders share one send rate
+	mailLimiter := ratelimit.NewMemoryLimiter()
+	mailRate := ratelimit.Limit{Requests: cfg.ReverificationRate, Window: cfg.ReverificationWindow}
+	reverificationService := services.NewReverificationService(userRepo, verificationTokenRepo, reverificationJobRepo, auditRepo, emailVerificationService, mailLimiter, services.ReverificationConfig{
+		BatchSize: cfg.ReverificationBatchSize,
+		Rate:      mailRate,
+	})
+	if err := reverificationService.ResumeRunning(ctx); err != nil {
+		log.Printf("Failed to resume re-verification jobs: %v", err)
+	}
+	if cfg.VerificationReminderInterval > 0 {
+		reminderService := services.NewVerificationReminderService(userRepo, verificationTokenRepo, verificationReminderRepo, consentRepo, emailVerificationService, mailLimiter, services.VerificationReminderConfig{
+			Interval:     cfg.VerificationReminderInterval,
+			MaxReminders: cfg.VerificationReminderMax,
+			BatchSize:    cfg.ReverificationBatchSize,
+			Rate:         mailRate,
+		})
+		go reminderService.Run(ctx)
+	}
+	deviceLoginService := services.NewDeviceLoginService(deviceLoginRepo, userRepo, authService, services.DeviceLoginConfig{
+		TTL:             cfg.DeviceLoginTTL,
+		Interval:        cfg.DeviceLoginInterval,
+		VerificationURI: cfg.DeviceLoginURL,
+	})
+	recoveryEmailService := services.NewRecoveryEmailService(userRepo, verificationTokenRepo, eventPublisher, cfg.PublicURL)
+	emailChangeService := services.NewEmailChangeService(userRepo, verificationTokenRepo, eventPublisher, cfg.PublicURL, cfg.EmailChangeTokenTTL)
+	appAuthorizationService := services.NewAppAuthorizationService(appAuthorizationRepo, sessionRepo)
+	delegationService := services.NewDelegationService(delegationRepo, userRepo, sessionRepo, auditRepo, accessTokens)
+	oauthProviders := make(map[string]services.OAuthProvider, len(cfg.OAuthProviders))
+	for name, provider := range cfg.OAuthProviders {
+		oauthProviders[name] = services.OAuthProvider(provider)
+	}
+	oauthService := services.NewOAuthService(oauthProviders, oauthIdentityRepo, userRepo, auditRepo, authService, emailVerificationService, cfg.PublicURL)
 
 	// Initialize HTTP handlers
-	handlers := http.NewHandlers(userService, authService)
+	handlers := httptransport.NewHandlers(userService, authService)
+	passwordResetHandlers := httptransport.NewPasswordResetHandlers(passwordResetService)
+	recoveryEmailHandlers := httptransport.NewRecoveryEmailHandlers(recoveryEmailService)
+	emailChangeHandlers := httptransport.NewEmailChangeHandlers(emailChangeService)
+	twoFactorHandlers := httptransport.NewTwoFactorHandlers(twoFactorService, authService)
+	keyHandlers := httptransport.NewKeyHandlers(signingKeys, alternateKeys, keyRotator)
+	oidcHandlers := httptransport.NewOIDCHandlers(signingKeys, cfg.PublicURL)
+	privacyHandlers := httptransport.NewPrivacyHandlers(privacyService)
+	adminHandlers := httptransport.NewAdminHandlers(adminService)
+	phoneHandlers := httptransport.NewPhoneHandlers(phoneService)
+	oauthClientHandlers := httptransport.NewOAuthClientHandlers(oauthClientService)
+	internalHandlers := httptransport.NewInternalHandlers(userService)
+	introspectionHandlers := httptransport.NewIntrospectionHandlers(oauthClientService, authService, accessTokens)
+	appAuthorizationHandlers := httptransport.NewAppAuthorizationHandlers(appAuthorizationService)
+	delegationHandlers := httptransport.NewDelegationHandlers(delegationService)
+	oauthHandlers := httptransport.NewOAuthHandlers(oauthService, cfg.Cookies)
+	verificationHandlers := httptransport.NewVerificationHandlers(emailVerificationService, reverificationService)
+	deviceLoginHandlers := httptransport.NewDeviceLoginHandlers(deviceLoginService)
+
+	// Initialize rate limiting; per-identity overrides take precedence over the default tier.
+	// With Redis configured the buckets are shared by every instance.
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	if cfg.RedisURL != "" {
+		redisLimiter, err := ratelimit.NewRedisLimiter(cfg.RedisURL, cfg.RedisPassword, cfg.RedisDB, limiter)
+		if err != nil {
+			log.Fatalf("Failed to initialize rate limiter: %v", err)
+		}
+		defer redisLimiter.Close()
+		limiter = redisLimiter
+	}
+	rateLimitPolicy := &ratelimit.Policy{
+		Default:   ratelimit.Limit{Requests: cfg.RateLimitRequests, Window: cfg.RateLimitWindow},
+		Overrides: ratelimit.StaticOverrides(cfg.RateLimitOverrides),
+	}
+	rateLimit := httptransport.RateLimitMiddleware(limiter, rateLimitPolicy)
+	loginRateLimit := httptransport.RouteRateLimitMiddleware(limiter, "login", ratelimit.Limit(cfg.RateLimits["login"]), httptransport.ClientIPKey, httptransport.EmailKey)
+	registerRateLimit := httptransport.RouteRateLimitMiddleware(limiter, "register", ratelimit.Limit(cfg.RateLimits["register"]), httptransport.ClientIPKey)
+	rateLimitStatus := httptransport.RateLimitStatusHandler(limiter, rateLimitPolicy, map[string]httptransport.RateLimitGroup{
+		"login":    {Limit: ratelimit.Limit(cfg.RateLimits["login"]), Keys: []httptransport.RateLimitKey{httptransport.ClientIPKey, httptransport.AccountEmailKey}},
+		"register": {Limit: ratelimit.Limit(cfg.RateLimits["register"]), Keys: []httptransport.RateLimitKey{httptransport.ClientIPKey}},
+	})
+	kioskAuth := httptransport.RequireKioskKey(cfg.KioskAPIKeys)
+	internalAuth := httptransport.RequireInternalToken(cfg.InternalAuthToken)
 
 	// Setup router
 	router := gin.New()
-	router.Use(gin.Logger())
+	router.Use(httptransport.RequestIDMiddleware())
+	router.Use(httptransport.RequestLogger(logger))
+	router.Use(httptransport.CORSMiddleware(httptransport.CORSConfig{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+	}))
 	router.Use(gin.Recovery())
 	
 	// Health checks
@@ -77,37 +351,176 @@ func main() {
 		c.JSON(200, gin.H{"status": "healthy", "service": serviceName})
 	})
 	
-	router.GET("/readyz", func(c *gin.Context) {
-		// Check database connectivity
-		if err := db.Ping(); err != nil {
-			c.JSON(503, gin.H{"status": "not ready", "error": err.Error()})
-			return
-		}
-		c.JSON(200, gin.H{"status": "ready", "service": serviceName})
-	})
+	critical := func(name string) bool { return !slices.Contains(cfg.ReadinessNonCritical, name) }
+	router.GET("/readyz", httptransport.ReadinessHandler(serviceName, []httptransport.ReadinessCheck{
+		{
+			// a saturated pool refuses the ping too
+			Name:     "database",
+			Critical: true,
+			Check:    db.PingContext,
+			Details:  func() interface{} { return repo.PoolStatus(db, cfg.DBMaxOpenConns) },
+		},
+		{
+			// brief broker outages are buffered; only longer ones fail the check
+			Name:     "rabbitmq",
+			Critical: critical("rabbitmq"),
+			Check:    func(context.Context) error { return rabbitPublisher.HealthCheck() },
+		},
+		{
+			Name:     "tracer",
+			Critical: critical("tracer"),
+			Check:    collectorReachable(cfg.OTELCollectorAddress()),
+		},
+	}))
+
+	// OpenID Connect discovery and the token verification keys
+	router.GET("/.well-known/openid-configuration", oidcHandlers.Discovery)
+	router.GET("/.well-known/jwks.json", keyHandlers.JWKS)
 
 	// API routes
 	v1 := router.Group("/api/v1")
+	v1.Use(httptransport.ServiceModeMiddleware(cfg.Mode, "/api/v1/auth/introspect"), httptransport.TimeoutMiddleware(cfg.RequestTimeout, cfg.RouteTimeouts))
 	{
+		// Reports the client's quota; registered outside the auth group so it consumes none
+		v1.GET("/auth/rate-limit-status", httptransport.OptionalAuthMiddleware(accessTokens, activeUsers), rateLimitStatus)
+
+		// The /auth routes of signed-in users, rate limited per user
+		signedIn := httptransport.SignedInGroup(v1.Group("/auth"), accessTokens, activeUsers, rateLimit)
+		{
+			signedIn.POST("/action-token", httptransport.RequirePasswordChanged(), httptransport.RequireVerified(), handlers.IssueActionToken)
+			signedIn.GET("/session/posture", handlers.GetSessionPosture)
+			signedIn.POST("/resend-verification", verificationHandlers.ResendVerification)
+			signedIn.POST("/device-login/approve", httptransport.RequirePasswordChanged(), httptransport.RequireVerified(), deviceLoginHandlers.Approve)
+		}
+
 		auth := v1.Group("/auth")
+		auth.Use(rateLimit)
 		{
-			auth.POST("/register", handlers.Register)
-			auth.POST("/login", handlers.Login)
+			auth.POST("/register", registerRateLimit, handlers.Register)
+			auth.GET("/password-policy", handlers.GetPasswordPolicy)
+			auth.POST("/login", loginRateLimit, handlers.Login)
+			auth.POST("/2fa/verify", twoFactorHandlers.VerifyLogin)
+			auth.GET("/public-key.pem", keyHandlers.PublicKeyPEM)
 			auth.POST("/refresh", handlers.RefreshToken)
+			auth.POST("/pin-unlock", handlers.PinUnlock)
+			auth.POST("/introspect", introspectionHandlers.Introspect)
 			auth.POST("/logout", handlers.Logout)
+			auth.POST("/reactivate", loginRateLimit, handlers.Reactivate)
+			auth.POST("/forgot-password", passwordResetHandlers.ForgotPassword)
+			auth.GET("/reset-token/validate", passwordResetHandlers.ValidateResetToken)
+			auth.POST("/reset-password", passwordResetHandlers.ResetPassword)
+			auth.GET("/confirm-recovery-email", recoveryEmailHandlers.ConfirmRecoveryEmail)
+			auth.GET("/confirm-email-change", emailChangeHandlers.ConfirmEmailChange)
+			auth.GET("/verify", verificationHandlers.VerifyEmail)
+			auth.GET("/verify-email", verificationHandlers.VerifyEmail)
+			auth.POST("/device-login", kioskAuth, deviceLoginHandlers.Start)
+			auth.GET("/device-login/poll", kioskAuth, deviceLoginHandlers.Poll)
+			auth.GET("/oauth/:provider", oauthHandlers.Start)
+			auth.GET("/oauth/:provider/callback", oauthHandlers.Callback)
 		}
 		
+		// Changing the password stays reachable while a password change is required
+		v1.POST("/users/password", httptransport.AuthMiddleware(accessTokens, activeUsers), httptransport.RequireSessionScope(), rateLimit, handlers.ChangePassword)
+
 		users := v1.Group("/users")
-		users.Use(http.AuthMiddleware(cfg.JWTSecret))
+		users.Use(httptransport.AuthMiddleware(accessTokens, activeUsers), httptransport.RequireSessionScope(), httptransport.RequirePasswordChanged(), rateLimit)
 		{
 			users.GET("/profile", handlers.GetProfile)
+			users.GET("/session", handlers.GetSessionStatus)
+			users.GET("/sessions", handlers.ListSessions)
+			users.DELETE("/sessions", handlers.RevokeOtherSessions)
+			users.DELETE("/sessions/:id", handlers.RevokeSession)
 			users.PUT("/profile", handlers.UpdateProfile)
-			users.DELETE("/profile", handlers.DeleteProfile)
+			users.DELETE("/profile", httptransport.RequireLowRisk(), handlers.DeleteProfile)
+			users.POST("/deactivate", handlers.DeactivateAccount)
+			users.PUT("/pin", handlers.SetPin)
+			users.DELETE("/pin", handlers.RemovePin)
+			users.PUT("/recovery-email", recoveryEmailHandlers.SetRecoveryEmail)
+			users.POST("/email-change", emailChangeHandlers.RequestEmailChange)
+			users.PUT("/phone", phoneHandlers.SetPhone)
+			users.POST("/phone/verify", phoneHandlers.VerifyPhone)
+			users.POST("/2fa/setup", twoFactorHandlers.Setup)
+			users.POST("/2fa/enable", twoFactorHandlers.Enable)
+			users.POST("/2fa/disable", twoFactorHandlers.Disable)
+			users.GET("/mfa/methods", twoFactorHandlers.ListMethods)
+			users.PUT("/mfa/methods/:id/preferred", twoFactorHandlers.SetPreferredMethod)
+			users.DELETE("/mfa/methods/:id", httptransport.RequireLowRisk(), twoFactorHandlers.RemoveMethod)
+			users.GET("/consents", privacyHandlers.GetConsents)
+			users.PUT("/consents", privacyHandlers.UpdateConsents)
+			users.GET("/export", privacyHandlers.ExportData)
+			users.GET("/trusted-devices", twoFactorHandlers.ListTrustedDevices)
+			users.DELETE("/trusted-devices/:id", twoFactorHandlers.RevokeTrustedDevice)
+			users.GET("/authorizations", appAuthorizationHandlers.List)
+			users.DELETE("/authorizations/:clientId", appAuthorizationHandlers.Revoke)
+			users.POST("/delegations", httptransport.RequireVerified(), delegationHandlers.Grant)
+			users.GET("/delegations", delegationHandlers.List)
+			users.DELETE("/delegations/:id", delegationHandlers.Revoke)
+			users.POST("/delegations/:id/token", httptransport.RequireVerified(), delegationHandlers.IssueToken)
+			users.GET("/:id", handlers.GetUser)
+		}
+
+		// Delegated tokens reach only these routes, each checking its delegation
+		delegated := v1.Group("/delegated")
+		delegated.Use(httptransport.AuthMiddleware(accessTokens, activeUsers), rateLimit)
+		{
+			delegated.GET("/profile", httptransport.RequireDelegation(delegationService, domain.DelegationProfile), delegationHandlers.Profile)
+			delegated.GET("/contact", httptransport.RequireDelegation(delegationService, domain.DelegationContact), delegationHandlers.Contact)
+		}
+
+		// Service-to-service routes, for other UniBazzar services only
+		internal := v1.Group("/internal")
+		internal.Use(internalAuth)
+		{
+			internal.POST("/users/batch", internalHandlers.BatchGetUsers)
+		}
+
+		// Administration routes moderators reach too, limited to the users
+		// of their campus
+		moderation := v1.Group("/admin")
+		moderation.Use(httptransport.AuthMiddleware(accessTokens, activeUsers), httptransport.RequireSessionScope(domain.ScopeAdmin), httptransport.RequirePasswordChanged(), httptransport.RequireRole(domain.RoleAdmin, domain.RoleModerator), rateLimit)
+		{
+			moderation.GET("/users", adminHandlers.ListUsers)
+			moderation.GET("/users/:id", adminHandlers.GetUser)
+			moderation.GET("/users/:id/audit", adminHandlers.ListUserAudit)
+		}
+
+		admin := v1.Group("/admin")
+		admin.Use(httptransport.AuthMiddleware(accessTokens, activeUsers), httptransport.RequireSessionScope(domain.ScopeAdmin), httptransport.RequirePasswordChanged(), httptransport.RequireRole(domain.RoleAdmin), rateLimit)
+		{
+			admin.GET("/users/role-counts", adminHandlers.CountUsersByRole)
+			admin.GET("/campuses/:id/users/export", adminHandlers.ExportCampusUsers)
+			admin.POST("/users/bulk-role", adminHandlers.BulkAssignRole)
+			admin.PUT("/users/:id/role", adminHandlers.ChangeRole)
+			admin.POST("/users/merge", adminHandlers.MergeAccounts)
+			admin.POST("/users/reverify", verificationHandlers.StartReverification)
+			admin.GET("/users/reverify/:id", verificationHandlers.GetReverification)
+			admin.POST("/users/reverify/:id/resume", verificationHandlers.ResumeReverification)
+			admin.POST("/users/:id/require-password-change", adminHandlers.RequirePasswordChange)
+			admin.POST("/users/:id/restore", adminHandlers.RestoreUser)
+			admin.POST("/users/:id/deactivate", adminHandlers.DeactivateUser)
+			admin.POST("/users/:id/activate", adminHandlers.ActivateUser)
+			admin.POST("/revocations", adminHandlers.ScheduleRevocation)
+			admin.POST("/sessions/revoke-token", adminHandlers.RevokeRefreshToken)
+			admin.GET("/sessions", adminHandlers.ListDeviceSessions)
+			admin.POST("/sessions/revoke-device", adminHandlers.RevokeDeviceSessions)
+			admin.POST("/tokens/decode", adminHandlers.DecodeToken)
+			admin.GET("/keys", keyHandlers.RotationStatus)
+			admin.POST("/events/test", adminHandlers.TestEventDelivery)
+			admin.POST("/events/preview", adminHandlers.PreviewEvents)
+			admin.GET("/audit/verify", adminHandlers.VerifyAuditChain)
+			admin.GET("/metrics/signup-funnel", adminHandlers.SignupFunnel)
+			admin.GET("/oauth-clients", oauthClientHandlers.List)
+			admin.POST("/oauth-clients", oauthClientHandlers.Register)
+			admin.GET("/oauth-clients/:id", oauthClientHandlers.Get)
+			admin.PUT("/oauth-clients/:id", oauthClientHandlers.Update)
+			admin.POST("/oauth-clients/:id/secret", oauthClientHandlers.RotateSecret)
+			admin.DELETE("/oauth-clients/:id", oauthClientHandlers.Delete)
 		}
 	}
 
-	// Metrics endpoint
-	router.GET("/metrics", gin.WrapH(http.MetricsHandler()))
+	// Metrics endpoint; the active session count is taken on every scrape
+	services.RegisterSessionMetrics(sessionRepo)
+	router.GET("/metrics", gin.WrapH(httptransport.MetricsHandler()))
 
 	// Start server
 	srv := &http.Server{
@@ -126,6 +539,32 @@ func main() {
 		}
 	}()
 
+	// Internal gRPC API for other services
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != 0 {
+		var creds credentials.TransportCredentials
+		if cfg.GRPCTLSCertFile != "" {
+			creds, err = grpctransport.LoadTLSCredentials(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile, cfg.GRPCClientCAFile)
+			if err != nil {
+				logger.Error("Failed to configure gRPC TLS", "error", err)
+				os.Exit(1)
+			}
+		}
+		grpcServer = grpctransport.NewServer(grpctransport.NewAuthServer(accessTokens, activeUsers, userService), cfg.InternalAuthToken, creds)
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			logger.Error("Failed to listen for gRPC", "port", cfg.GRPCPort, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			logger.Info("Starting gRPC API", "port", cfg.GRPCPort, "tls", creds != nil)
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Error("Failed to serve gRPC", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -136,13 +575,146 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
+	// drain the gRPC API alongside HTTP under the same deadline
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		if grpcServer != nil {
+			stopGRPC(ctx, logger, grpcServer)
+		}
+	}()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	<-grpcStopped
+	auditWriter.Flush()
 	
 	log.Println("Server exited")
 }
 
+// stopGRPC lets the calls in flight on the gRPC server finish, cutting off
+// those still running at the deadline of ctx
+func stopGRPC(ctx context.Context, logger *slog.Logger, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		logger.Info("gRPC API stopped")
+	case <-ctx.Done():
+		logger.Warn("gRPC calls still running at the shutdown deadline, stopping", "error", ctx.Err())
+		server.Stop()
+		<-stopped
+	}
+}
+
+func parseRoles(names []string) ([]domain.Role, error) {
+	roles := make([]domain.Role, 0, len(names))
+	for _, name := range names {
+		role := domain.Role(name)
+		if !role.IsValid() {
+			return nil, fmt.Errorf("unknown role %q", name)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// buildPasswordPolicy turns the configured password rules into a policy; a
+// role rule never weakens the default rule
+func buildPasswordPolicy(cfg *config.Config) (domain.PasswordPolicy, error) {
+	policy := domain.PasswordPolicy{
+		Default:      domain.PasswordRule(cfg.PasswordRule),
+		Roles:        make(map[domain.Role]domain.PasswordRule, len(cfg.PasswordRoleRules)),
+		MaxLength:    cfg.PasswordMaxLength,
+		RejectCommon: cfg.PasswordRejectCommon,
+	}
+	for _, class := range cfg.PasswordRequire {
+		switch class {
+		case "lowercase":
+			policy.Require.Lower = true
+		case "uppercase":
+			policy.Require.Upper = true
+		case "digit":
+			policy.Require.Digit = true
+		case "symbol":
+			policy.Require.Symbol = true
+		default:
+			return policy, fmt.Errorf("unknown character class %q", class)
+		}
+	}
+	for name, rule := range cfg.PasswordRoleRules {
+		role := domain.Role(name)
+		if !role.IsValid() {
+			return policy, fmt.Errorf("unknown role %q", name)
+		}
+		if rule.MinLength < policy.Default.MinLength || rule.MinClasses < policy.Default.MinClasses {
+			return policy, fmt.Errorf("rule for %s is weaker than the default rule", name)
+		}
+		policy.Roles[role] = domain.PasswordRule(rule)
+	}
+	for role, rule := range policy.Roles {
+		if policy.MaxLength > 0 && rule.MinLength > policy.MaxLength {
+			return policy, fmt.Errorf("rule for %s is longer than the maximum length", role)
+		}
+	}
+	if policy.MaxLength > 0 && policy.Default.MinLength > policy.MaxLength {
+		return policy, fmt.Errorf("minimum length is longer than the maximum length")
+	}
+	return policy, nil
+}
+
+func buildPeppers(cfg *config.Config) domain.Peppers {
+	peppers := domain.Peppers{
+		Current: cfg.PasswordPepperVersion,
+		Secrets: make(map[int][]byte, len(cfg.PasswordPeppers)),
+	}
+	for version, secret := range cfg.PasswordPeppers {
+		peppers.Secrets[version] = []byte(secret)
+	}
+	return peppers
+}
+
+func loadSigningKeys(cfg *config.Config) (*services.SigningKeys, error) {
+	if cfg.JWTAlgorithm == "RS256" {
+		return services.LoadRSAKeys(cfg.JWTPrivateKeyFile, cfg.JWTPublicKeyFiles)
+	}
+	return services.NewHMACKeys(cfg.JWTSecret), nil
+}
+
+// loadAlternateKeys loads the keys of the algorithm not selected by
+// JWT_ALGORITHM when they are configured too, so clients registered for
+// that algorithm can be issued tokens; nil otherwise
+func loadAlternateKeys(cfg *config.Config) (*services.SigningKeys, error) {
+	if cfg.JWTAlgorithm == "RS256" {
+		if cfg.JWTSecret == "" {
+			return nil, nil
+		}
+		return services.NewHMACKeys(cfg.JWTSecret), nil
+	}
+	if cfg.JWTPrivateKeyFile == "" {
+		return nil, nil
+	}
+	return services.LoadRSAKeys(cfg.JWTPrivateKeyFile, cfg.JWTPublicKeyFiles)
+}
+
+// collectorReachable checks that the OTLP collector at address accepts
+// connections. Spans are exported in the background, so an unreachable
+// collector would otherwise go unnoticed.
+func collectorReachable(address string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
 func initTracer(ctx context.Context, endpoint string) (*trace.TracerProvider, error) {
 	exporter, err := otlptracegrpc.New(ctx,
 		otlptracegrpc.WithEndpoint(endpoint),
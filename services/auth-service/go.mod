@@ -12,8 +12,13 @@ require (
     go.opentelemetry.io/otel v1.21.0
     go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.21.0
     go.opentelemetry.io/otel/sdk v1.21.0
+    go.opentelemetry.io/otel/trace v1.21.0
     go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin v0.46.1
     github.com/prometheus/client_golang v1.17.0
     github.com/go-playground/validator/v10 v10.16.0
     github.com/joho/godotenv v1.4.0
+    github.com/redis/go-redis/v9 v9.5.3
+    github.com/alicebob/miniredis/v2 v2.33.0
+    google.golang.org/grpc v1.59.0
+    google.golang.org/protobuf v1.31.0
 )
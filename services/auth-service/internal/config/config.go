@@ -0,0 +1,881 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// ServiceMode controls which requests the API accepts
+type ServiceMode string
+
+// Supported service modes
+const (
+	// ModeNormal serves all requests
+	ModeNormal ServiceMode = "normal"
+	// ModeReadOnly serves reads but rejects every mutating request, e.g. during data migrations
+	ModeReadOnly ServiceMode = "read_only"
+	// ModeMaintenance rejects all API requests; health checks and metrics stay up
+	ModeMaintenance ServiceMode = "maintenance"
+)
+
+// PasswordRule is a minimum password length and number of character classes
+type PasswordRule struct {
+	MinLength  int
+	MinClasses int
+}
+
+// RateLimit allows Requests per Window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// OAuthProvider is an external identity provider users can log in with
+// through the authorization-code flow
+type OAuthProvider struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+	// CampusID is the campus of users signing up through the provider, e.g.
+	// a university SSO; empty leaves them without one
+	CampusID string
+	// TrustEmail treats every email the provider reports as verified, for
+	// providers that do not send email_verified
+	TrustEmail bool
+}
+
+// CookieSettings are the attributes of every cookie the service sets
+type CookieSettings struct {
+	// Domain shares cookies with subdomains, e.g. campus.edu for
+	// app.campus.edu and api.campus.edu; empty keeps them to the API host
+	Domain   string
+	Path     string
+	SameSite http.SameSite
+	Secure   bool
+}
+
+// oauthCallbackPath is where identity providers send the browser back to;
+// the OAuth state cookie must reach it
+const oauthCallbackPath = "/api/v1/auth/oauth"
+
+// Config holds the auth-service runtime configuration
+type Config struct {
+	Port        int
+	Environment string
+	Mode        ServiceMode
+	// PublicURL is the externally reachable base URL used in links sent to users
+	PublicURL string
+
+	// GRPCPort serves the internal gRPC API used by other services; zero
+	// disables it
+	GRPCPort int
+	// InternalAuthToken is the shared token other services present as a
+	// bearer token to the gRPC API and the /api/v1/internal routes; those
+	// routes refuse every call while it is empty
+	InternalAuthToken string
+	// GRPCTLSCertFile and GRPCTLSKeyFile serve the gRPC API over TLS; with
+	// GRPCClientCAFile set too, callers must present a certificate it signed
+	GRPCTLSCertFile  string
+	GRPCTLSKeyFile   string
+	GRPCClientCAFile string
+
+	// RequestTimeout bounds API requests; RouteTimeouts overrides it per
+	// route, keyed by "METHOD /route/path"
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+
+	DatabaseURL          string
+	DatabaseReplicaURL   string
+	ReadYourWritesWindow time.Duration
+	// DBMaxOpenConns caps the connections of each database pool; operations
+	// needing another connection fail with 503 instead of waiting for one
+	DBMaxOpenConns int
+	// DBMaxIdleConns is how many unused connections each pool keeps open
+	DBMaxIdleConns int
+	// DBConnMaxLifetime and DBConnMaxIdleTime recycle connections older or
+	// unused for longer than them
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// AutoMigrate applies the pending schema migrations at startup
+	AutoMigrate bool
+
+	JWTAlgorithm      string
+	JWTSecret         string
+	JWTPrivateKeyFile string
+	JWTPublicKeyFiles []string
+	// KeyRotationInterval is how long each persisted signing key generation
+	// signs before the next takes over; zero keeps the configured key. A new
+	// key is published KeyRotationLead ahead of signing.
+	KeyRotationInterval time.Duration
+	KeyRotationLead     time.Duration
+	// AccessTokenTTL is the lifetime of access tokens, and RefreshTokenTTL
+	// that of sessions and so of their refresh tokens. Sessions opened
+	// without remember me last ShortSessionTTL instead.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	ShortSessionTTL time.Duration
+	// ActiveUserCacheTTL is how long authenticating a request trusts the
+	// last read active state of the account; a deactivation reaches the
+	// other instances within it
+	ActiveUserCacheTTL time.Duration
+
+	DeviceTrustTTL time.Duration
+	SessionMaxAge  time.Duration
+	// RefreshGraceWindow tolerates concurrent use of a just-rotated refresh token
+	RefreshGraceWindow time.Duration
+	// MaxSessionsPerUser caps the active sessions of a user; opening one more
+	// evicts the least recently used. Zero disables the cap.
+	MaxSessionsPerUser int
+	// MFARequiredRoles are the roles that may not remove their last second factor
+	MFARequiredRoles []string
+
+	// DefaultTimezone is the time zone of users whose campus has no entry in
+	// CampusTimezones and who have not chosen one
+	DefaultTimezone string
+	CampusTimezones map[string]string
+
+	// LoginLockoutScope is what failed logins count against: account, ip or
+	// account_ip. LoginLockoutMaxFailures within LoginLockoutWindow lock the
+	// scope out for LoginLockoutDuration; zero failures disables lockouts.
+	LoginLockoutScope       string
+	LoginLockoutMaxFailures int
+	LoginLockoutWindow      time.Duration
+	LoginLockoutDuration    time.Duration
+	// TwoFactorMaxFailures wrong TOTP codes within LoginLockoutWindow lock
+	// the account's second factor for LoginLockoutDuration; zero disables it
+	TwoFactorMaxFailures int
+
+	// PasswordRule applies to every role without a stricter PasswordRoleRules entry
+	PasswordRule      PasswordRule
+	PasswordRoleRules map[string]PasswordRule
+	// PasswordMaxLength caps passwords in characters; 0 leaves only the bcrypt limit
+	PasswordMaxLength int
+	// PasswordRequire lists the character classes every password must use:
+	// lowercase, uppercase, digit and symbol
+	PasswordRequire []string
+	// PasswordRejectCommon refuses passwords on the embedded common password list
+	PasswordRejectCommon bool
+	// PasswordPeppers are versioned server-side secrets mixed into passwords;
+	// new hashes use PasswordPepperVersion, 0 turning peppering off
+	PasswordPepperVersion int
+	PasswordPeppers       map[int]string
+
+	EnforceUniquePhones bool
+
+	// NormalizeEmailAliases refuses registering an email that differs from a
+	// registered one only by dots or a plus tag, on domains like gmail.com
+	// that deliver those to the same mailbox
+	NormalizeEmailAliases bool
+
+	// PinLockoutRevokes revokes a session whose PIN attempts are used up
+	PinLockoutRevokes bool
+
+	// KioskAPIKeys maps kiosk IDs to the API keys they start device logins with
+	KioskAPIKeys map[string]string
+	// DeviceLoginTTL bounds how long a kiosk login can be approved; kiosks
+	// poll for it at most once per DeviceLoginInterval
+	DeviceLoginTTL      time.Duration
+	DeviceLoginInterval time.Duration
+	// DeviceLoginURL is the page where students approve a kiosk login
+	DeviceLoginURL string
+
+	RabbitMQURL string
+	// RabbitMQConfirmTimeout bounds the wait for the broker to acknowledge a published event
+	RabbitMQConfirmTimeout time.Duration
+	// RabbitMQPublishRetries is how often a failed publish is retried before it fails
+	RabbitMQPublishRetries int
+	// RabbitMQBufferSize bounds the events held while the broker is unreachable
+	RabbitMQBufferSize int
+	// RabbitMQUnhealthyAfter is how long the broker may be unreachable before
+	// the service reports not ready
+	RabbitMQUnhealthyAfter time.Duration
+	// OutboxPollInterval is how often the outbox is checked for events to publish
+	OutboxPollInterval time.Duration
+	// OutboxBatchSize is the number of outbox events published per claim
+	OutboxBatchSize int
+	// OutboxRetention is how long published outbox events are kept
+	OutboxRetention time.Duration
+	// AuditBufferSize is how many audit entries are queued for writing before
+	// requests write their own
+	AuditBufferSize int
+	// DeferPIIEvents keeps the email and name of a new account out
+	// of published events until the email is verified
+	DeferPIIEvents bool
+
+	OTELEndpoint string
+	// LogLevel is the minimum level of the structured logs: debug, info,
+	// warn or error
+	LogLevel slog.Level
+	// ReadinessNonCritical names the dependencies, of rabbitmq and tracer,
+	// whose outage only reports the service degraded rather than not ready
+	ReadinessNonCritical []string
+
+	RateLimitRequests  int
+	RateLimitWindow    time.Duration
+	RateLimitOverrides map[string]int
+
+	ProfileUpdateLimit  int
+	ProfileUpdateWindow time.Duration
+
+	// ReactivationWindow is how long after deactivating their account users
+	// can reactivate it; afterwards it is due for anonymization. Zero means
+	// indefinitely.
+	ReactivationWindow time.Duration
+
+	// VerificationTokenTTL is how long an email verification link stays usable
+	VerificationTokenTTL    time.Duration
+	ReverificationBatchSize int
+	ReverificationRate      int
+	ReverificationWindow    time.Duration
+
+	// EmailChangeTokenTTL is how long a pending email change can be confirmed
+	EmailChangeTokenTTL time.Duration
+
+	// VerificationReminderInterval spaces the reminders sent to unverified
+	// users; zero disables them
+	VerificationReminderInterval time.Duration
+	VerificationReminderMax      int
+
+	// CampusRegistrationLimit caps registrations per campus per
+	// CampusRegistrationWindow, independently of the per-IP limit; zero
+	// leaves campuses without an override unlimited
+	CampusRegistrationLimit     int
+	CampusRegistrationWindow    time.Duration
+	CampusRegistrationOverrides map[string]int
+
+	// RateLimits are the per-IP limits of route groups throttled on top of
+	// the default tier, keyed by group: "login" and "register". Login
+	// attempts are limited per submitted email too.
+	RateLimits map[string]RateLimit
+	// RedisURL points the rate limiters at a Redis shared by every instance;
+	// empty keeps the buckets in process
+	RedisURL      string
+	RedisPassword string
+	RedisDB       int
+
+	// OAuthProviders are the identity providers users can log in with, keyed
+	// by the name used in /auth/oauth/:provider
+	OAuthProviders map[string]OAuthProvider
+	// Cookies are the attributes of the cookies set by the service
+	Cookies CookieSettings
+
+	// AllowedOrigins are the browser origins allowed to call the API
+	// cross-origin, "*" allowing any; empty disables CORS
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets allowed origins send cookies cross-origin
+	AllowCredentials bool
+
+	// loadProblems are the values Load could not parse, reported by Validate
+	loadProblems []error
+}
+
+// Load reads the configuration from the environment, falling back to a .env
+// file when present. Values that fail to parse are left at their defaults and
+// reported by Validate, along with every other problem.
+func Load() *Config {
+	// A missing .env file is fine; real deployments inject the environment directly
+	_ = godotenv.Load()
+
+	env := &envReader{}
+
+	port := env.int("PORT", 8081)
+	grpcPort := env.int("GRPC_PORT", 0)
+	rateLimitRequests := env.int("RATE_LIMIT_REQUESTS", 100)
+	rateLimitWindow := env.duration("RATE_LIMIT_WINDOW", time.Minute)
+	readYourWritesWindow := env.duration("READ_YOUR_WRITES_WINDOW", 10*time.Second)
+	dbMaxOpenConns := env.int("DB_MAX_OPEN_CONNS", 25)
+	dbMaxIdleConns := env.int("DB_MAX_IDLE_CONNS", 10)
+	dbConnMaxLifetime := env.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	dbConnMaxIdleTime := env.duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+	autoMigrate := env.bool("AUTO_MIGRATE", false)
+	accessTokenTTL := env.duration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	refreshTokenTTL := env.duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	shortSessionTTL := env.duration("SHORT_SESSION_TTL", 12*time.Hour)
+	activeUserCacheTTL := env.duration("ACTIVE_USER_CACHE_TTL", 30*time.Second)
+	sessionMaxAge := env.duration("SESSION_MAX_AGE", 30*24*time.Hour)
+	refreshGraceWindow := env.duration("REFRESH_GRACE_WINDOW", 10*time.Second)
+	maxSessionsPerUser := env.int("MAX_SESSIONS_PER_USER", 10)
+	requestTimeout := env.duration("REQUEST_TIMEOUT", 10*time.Second)
+
+	routeTimeouts, err := parseRouteTimeouts(getEnv("ROUTE_TIMEOUTS", ""))
+	env.check(err)
+
+	mode, err := parseServiceMode(getEnv("SERVICE_MODE", string(ModeNormal)))
+	env.check(err)
+
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		env.check(fmt.Errorf("invalid LOG_LEVEL: %w", err))
+	}
+
+	allowedOrigins := splitList(getEnv("CORS_ALLOWED_ORIGINS", ""))
+	allowCredentials := env.bool("CORS_ALLOW_CREDENTIALS", false)
+	readinessNonCritical := splitList(getEnv("READINESS_NON_CRITICAL", "tracer"))
+	jwtAlgorithm := getEnv("JWT_ALGORITHM", "HS256")
+
+	deviceTrustTTL := env.duration("DEVICE_TRUST_TTL", 30*24*time.Hour)
+
+	rateLimitOverrides, err := parseOverrides("RATE_LIMIT_OVERRIDES", getEnv("RATE_LIMIT_OVERRIDES", ""))
+	env.check(err)
+
+	enforceUniquePhones := env.bool("ENFORCE_UNIQUE_PHONES", true)
+	normalizeEmailAliases := env.bool("NORMALIZE_EMAIL_ALIASES", false)
+	pinLockoutRevokes := env.bool("PIN_LOCKOUT_REVOKES_SESSION", true)
+	deferPIIEvents := env.bool("DEFER_UNVERIFIED_PII_EVENTS", false)
+	rabbitMQConfirmTimeout := env.duration("RABBITMQ_CONFIRM_TIMEOUT", 5*time.Second)
+	rabbitMQPublishRetries := env.int("RABBITMQ_PUBLISH_RETRIES", 3)
+	rabbitMQBufferSize := env.int("RABBITMQ_BUFFER_SIZE", 1000)
+	rabbitMQUnhealthyAfter := env.duration("RABBITMQ_UNHEALTHY_AFTER", 30*time.Second)
+	outboxPollInterval := env.duration("OUTBOX_POLL_INTERVAL", time.Second)
+	outboxBatchSize := env.int("OUTBOX_BATCH_SIZE", 100)
+	outboxRetention := env.duration("OUTBOX_RETENTION", 7*24*time.Hour)
+	auditBufferSize := env.int("AUDIT_BUFFER_SIZE", 1000)
+	profileUpdateLimit := env.int("PROFILE_UPDATE_LIMIT", 0)
+	profileUpdateWindow := env.duration("PROFILE_UPDATE_WINDOW", time.Hour)
+	reactivationWindow := env.duration("REACTIVATION_WINDOW", 30*24*time.Hour)
+	reverificationBatchSize := env.int("REVERIFICATION_BATCH_SIZE", 100)
+	reverificationRate := env.int("REVERIFICATION_RATE", 100)
+	reverificationWindow := env.duration("REVERIFICATION_WINDOW", time.Minute)
+	verificationReminderInterval := env.duration("VERIFICATION_REMINDER_INTERVAL", 0)
+	verificationReminderMax := env.int("VERIFICATION_REMINDER_MAX", 3)
+	campusRegistrationLimit := env.int("CAMPUS_REGISTRATION_LIMIT", 0)
+	campusRegistrationWindow := env.duration("CAMPUS_REGISTRATION_WINDOW", time.Minute)
+
+	campusRegistrationOverrides, err := parseOverrides("CAMPUS_REGISTRATION_OVERRIDES", getEnv("CAMPUS_REGISTRATION_OVERRIDES", ""))
+	env.check(err)
+
+	rateLimits, err := parseRateLimits(getEnv("RATE_LIMITS", "login=10/1m,register=5/1m"))
+	env.check(err)
+
+	redisDB := env.int("REDIS_DB", 0)
+
+	oauthProviders, err := loadOAuthProviders(getEnv("OAUTH_PROVIDERS", ""))
+	env.check(err)
+
+	passwordMinLength := env.int("PASSWORD_MIN_LENGTH", 8)
+	passwordMinClasses := env.int("PASSWORD_MIN_CLASSES", 1)
+
+	passwordRoleRules, err := parsePasswordRules(getEnv("PASSWORD_ROLE_RULES", "moderator=12:3,admin=14:3"))
+	env.check(err)
+
+	passwordMaxLength := env.int("PASSWORD_MAX_LENGTH", 64)
+	passwordRejectCommon := env.bool("PASSWORD_REJECT_COMMON", true)
+	passwordPepperVersion := env.int("PASSWORD_PEPPER_VERSION", 0)
+
+	passwordPeppers, err := parsePeppers(getEnv("PASSWORD_PEPPERS", ""))
+	env.check(err)
+
+	defaultTimezone := getEnv("DEFAULT_TIMEZONE", "UTC")
+
+	campusTimezones, err := parseCampusTimezones(getEnv("CAMPUS_TIMEZONES", ""))
+	env.check(err)
+
+	loginLockoutMaxFailures := env.int("LOGIN_LOCKOUT_MAX_FAILURES", 5)
+	loginLockoutWindow := env.duration("LOGIN_LOCKOUT_WINDOW", 15*time.Minute)
+	loginLockoutDuration := env.duration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	twoFactorMaxFailures := env.int("TWO_FACTOR_MAX_FAILURES", 5)
+
+	kioskAPIKeys, err := parseKioskKeys(getEnv("KIOSK_API_KEYS", ""))
+	env.check(err)
+
+	deviceLoginTTL := env.duration("DEVICE_LOGIN_TTL", 10*time.Minute)
+	deviceLoginInterval := env.duration("DEVICE_LOGIN_INTERVAL", 5*time.Second)
+	verificationTokenTTL := env.duration("VERIFICATION_TOKEN_TTL", 24*time.Hour)
+	emailChangeTokenTTL := env.duration("EMAIL_CHANGE_TOKEN_TTL", 24*time.Hour)
+	keyRotationInterval := env.duration("KEY_ROTATION_INTERVAL", 0)
+	keyRotationLead := env.duration("KEY_ROTATION_LEAD", time.Hour)
+
+	publicURL := getEnv("PUBLIC_URL", fmt.Sprintf("http://localhost:%d", port))
+
+	cookies, err := loadCookieSettings(strings.HasPrefix(publicURL, "https://"))
+	env.check(err)
+
+	return &Config{
+		Port:                    port,
+		GRPCPort:                grpcPort,
+		InternalAuthToken:       getEnv("INTERNAL_AUTH_TOKEN", ""),
+		GRPCTLSCertFile:         getEnv("GRPC_TLS_CERT_FILE", ""),
+		GRPCTLSKeyFile:          getEnv("GRPC_TLS_KEY_FILE", ""),
+		GRPCClientCAFile:        getEnv("GRPC_CLIENT_CA_FILE", ""),
+		Environment:             getEnv("ENVIRONMENT", "development"),
+		Mode:                    mode,
+		PublicURL:               publicURL,
+		RequestTimeout:          requestTimeout,
+		RouteTimeouts:           routeTimeouts,
+		DatabaseURL:             getEnv("DATABASE_URL", ""),
+		DatabaseReplicaURL:      getEnv("DATABASE_REPLICA_URL", ""),
+		ReadYourWritesWindow:    readYourWritesWindow,
+		DBMaxOpenConns:          dbMaxOpenConns,
+		DBMaxIdleConns:          dbMaxIdleConns,
+		DBConnMaxLifetime:       dbConnMaxLifetime,
+		DBConnMaxIdleTime:       dbConnMaxIdleTime,
+		AutoMigrate:             autoMigrate,
+		JWTAlgorithm:            jwtAlgorithm,
+		JWTSecret:               getEnv("JWT_SECRET", ""),
+		JWTPrivateKeyFile:       getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPublicKeyFiles:       splitList(getEnv("JWT_PUBLIC_KEY_FILES", "")),
+		KeyRotationInterval:     keyRotationInterval,
+		KeyRotationLead:         keyRotationLead,
+		DeviceTrustTTL:          deviceTrustTTL,
+		AccessTokenTTL:          accessTokenTTL,
+		RefreshTokenTTL:         refreshTokenTTL,
+		ShortSessionTTL:         shortSessionTTL,
+		ActiveUserCacheTTL:      activeUserCacheTTL,
+		SessionMaxAge:           sessionMaxAge,
+		RefreshGraceWindow:      refreshGraceWindow,
+		MaxSessionsPerUser:      maxSessionsPerUser,
+		MFARequiredRoles:        splitList(getEnv("MFA_REQUIRED_ROLES", "")),
+		DefaultTimezone:         defaultTimezone,
+		CampusTimezones:         campusTimezones,
+		LoginLockoutScope:       getEnv("LOGIN_LOCKOUT_SCOPE", "account_ip"),
+		LoginLockoutMaxFailures: loginLockoutMaxFailures,
+		LoginLockoutWindow:      loginLockoutWindow,
+		LoginLockoutDuration:    loginLockoutDuration,
+		TwoFactorMaxFailures:    twoFactorMaxFailures,
+		PasswordRule:            PasswordRule{MinLength: passwordMinLength, MinClasses: passwordMinClasses},
+		PasswordRoleRules:       passwordRoleRules,
+		PasswordMaxLength:       passwordMaxLength,
+		PasswordRequire:         splitList(getEnv("PASSWORD_REQUIRE", "")),
+		PasswordRejectCommon:    passwordRejectCommon,
+		PasswordPepperVersion:   passwordPepperVersion,
+		PasswordPeppers:         passwordPeppers,
+		EnforceUniquePhones:     enforceUniquePhones,
+		NormalizeEmailAliases:   normalizeEmailAliases,
+		PinLockoutRevokes:       pinLockoutRevokes,
+		KioskAPIKeys:            kioskAPIKeys,
+		DeviceLoginTTL:          deviceLoginTTL,
+		DeviceLoginInterval:     deviceLoginInterval,
+		DeviceLoginURL:          getEnv("DEVICE_LOGIN_URL", publicURL+"/device"),
+		RabbitMQURL:             getEnv("RABBITMQ_URL", ""),
+		RabbitMQConfirmTimeout:  rabbitMQConfirmTimeout,
+		RabbitMQPublishRetries:  rabbitMQPublishRetries,
+		RabbitMQBufferSize:      rabbitMQBufferSize,
+		RabbitMQUnhealthyAfter:  rabbitMQUnhealthyAfter,
+		OutboxPollInterval:      outboxPollInterval,
+		OutboxBatchSize:         outboxBatchSize,
+		OutboxRetention:         outboxRetention,
+		AuditBufferSize:         auditBufferSize,
+		DeferPIIEvents:          deferPIIEvents,
+		OTELEndpoint:            getEnv("OTEL_ENDPOINT", "localhost:4317"),
+		LogLevel:                logLevel,
+		ReadinessNonCritical:    readinessNonCritical,
+		RateLimitRequests:       rateLimitRequests,
+		RateLimitWindow:         rateLimitWindow,
+		RateLimitOverrides:      rateLimitOverrides,
+		ProfileUpdateLimit:      profileUpdateLimit,
+		ProfileUpdateWindow:     profileUpdateWindow,
+		ReactivationWindow:      reactivationWindow,
+		VerificationTokenTTL:    verificationTokenTTL,
+		EmailChangeTokenTTL:     emailChangeTokenTTL,
+		ReverificationBatchSize: reverificationBatchSize,
+		ReverificationRate:      reverificationRate,
+		ReverificationWindow:    reverificationWindow,
+
+		VerificationReminderInterval: verificationReminderInterval,
+		VerificationReminderMax:      verificationReminderMax,
+		CampusRegistrationLimit:      campusRegistrationLimit,
+		CampusRegistrationWindow:     campusRegistrationWindow,
+		CampusRegistrationOverrides:  campusRegistrationOverrides,
+
+		RateLimits:    rateLimits,
+		RedisURL:      getEnv("REDIS_URL", ""),
+		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		RedisDB:       redisDB,
+
+		OAuthProviders:   oauthProviders,
+		Cookies:          cookies,
+		AllowedOrigins:   allowedOrigins,
+		AllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")),
+		AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization")),
+		AllowCredentials: allowCredentials,
+
+		loadProblems: env.problems,
+	}
+}
+
+// envReader reads typed environment variables, collecting the values that
+// fail to parse instead of stopping at the first
+type envReader struct {
+	problems []error
+}
+
+// check records err, if any
+func (e *envReader) check(err error) {
+	if err != nil {
+		e.problems = append(e.problems, err)
+	}
+}
+
+func (e *envReader) int(key string, fallback int) int {
+	value, err := getEnvInt(key, fallback)
+	if err != nil {
+		e.check(err)
+		return fallback
+	}
+	return value
+}
+
+func (e *envReader) bool(key string, fallback bool) bool {
+	value, err := getEnvBool(key, fallback)
+	if err != nil {
+		e.check(err)
+		return fallback
+	}
+	return value
+}
+
+func (e *envReader) duration(key string, fallback time.Duration) time.Duration {
+	value, err := getEnvDuration(key, fallback)
+	if err != nil {
+		e.check(err)
+		return fallback
+	}
+	return value
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func parseServiceMode(value string) (ServiceMode, error) {
+	switch mode := ServiceMode(value); mode {
+	case ModeNormal, ModeReadOnly, ModeMaintenance:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid SERVICE_MODE %q", value)
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseOverrides parses the "identity=limit" pairs of the key variable,
+// separated by commas, e.g. "user:6f1c...=1000,user:9a2b...=500"
+func parseOverrides(key, value string) (map[string]int, error) {
+	overrides := make(map[string]int)
+	if value == "" {
+		return overrides, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		idx := strings.LastIndex(pair, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %q", key, pair)
+		}
+		limit, err := strconv.Atoi(pair[idx+1:])
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid %s limit in %q", key, pair)
+		}
+		overrides[strings.TrimSpace(pair[:idx])] = limit
+	}
+
+	return overrides, nil
+}
+
+// parseRouteTimeouts parses "METHOD /path=duration" pairs separated by commas,
+// e.g. "POST /api/v1/auth/login=15s,GET /api/v1/users/profile=2s"
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range splitList(value) {
+		idx := strings.LastIndex(pair, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q", pair)
+		}
+		timeout, err := time.ParseDuration(pair[idx+1:])
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid ROUTE_TIMEOUTS duration in %q", pair)
+		}
+		route := strings.Join(strings.Fields(pair[:idx]), " ")
+		timeouts[route] = timeout
+	}
+	return timeouts, nil
+}
+
+// parseKioskKeys parses "kiosk-id=api-key" pairs separated by commas,
+// e.g. "library-1=3f9c...,cafeteria-2=b71e..."
+func parseKioskKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for i, pair := range splitList(value) {
+		// entries hold secrets, so errors name their position only
+		idx := strings.Index(pair, "=")
+		if idx <= 0 || idx == len(pair)-1 {
+			return nil, fmt.Errorf("invalid KIOSK_API_KEYS entry #%d", i+1)
+		}
+		keys[strings.TrimSpace(pair[:idx])] = pair[idx+1:]
+	}
+	return keys, nil
+}
+
+// parsePeppers parses "version:secret" pairs separated by commas, e.g.
+// "2:9d41...,1:c07a..." with positive versions
+func parsePeppers(value string) (map[int]string, error) {
+	peppers := make(map[int]string)
+	for i, pair := range splitList(value) {
+		// entries hold secrets, so errors name their position only
+		version, secret, ok := strings.Cut(pair, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid PASSWORD_PEPPERS entry #%d", i+1)
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(version))
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("invalid PASSWORD_PEPPERS version in entry #%d", i+1)
+		}
+		if _, taken := peppers[parsed]; taken {
+			return nil, fmt.Errorf("duplicate PASSWORD_PEPPERS version in entry #%d", i+1)
+		}
+		peppers[parsed] = secret
+	}
+	return peppers, nil
+}
+
+// parsePasswordRules parses "role=length:classes" pairs separated by commas,
+// e.g. "moderator=12:3,admin=14:3"
+func parsePasswordRules(value string) (map[string]PasswordRule, error) {
+	rules := make(map[string]PasswordRule)
+	for _, pair := range splitList(value) {
+		role, rule, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid PASSWORD_ROLE_RULES entry %q", pair)
+		}
+		length, classes, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid PASSWORD_ROLE_RULES entry %q", pair)
+		}
+		minLength, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil || minLength < 1 {
+			return nil, fmt.Errorf("invalid PASSWORD_ROLE_RULES length in %q", pair)
+		}
+		minClasses, err := strconv.Atoi(strings.TrimSpace(classes))
+		if err != nil || minClasses < 1 || minClasses > 4 {
+			return nil, fmt.Errorf("invalid PASSWORD_ROLE_RULES classes in %q", pair)
+		}
+		rules[strings.TrimSpace(role)] = PasswordRule{MinLength: minLength, MinClasses: minClasses}
+	}
+	return rules, nil
+}
+
+// parseRateLimits parses "group=requests/window" pairs separated by commas,
+// e.g. "login=10/1m,register=5/1h"
+func parseRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, pair := range splitList(value) {
+		group, limit, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(group) == "" {
+			return nil, fmt.Errorf("invalid RATE_LIMITS entry %q", pair)
+		}
+		requests, window, ok := strings.Cut(limit, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid RATE_LIMITS entry %q", pair)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(requests))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMITS requests in %q", pair)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMITS window in %q", pair)
+		}
+		limits[strings.TrimSpace(group)] = RateLimit{Requests: count, Window: duration}
+	}
+	return limits, nil
+}
+
+// googleOAuth holds the endpoints of Google, so only its credentials need
+// configuring
+var googleOAuth = OAuthProvider{
+	AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+	TokenURL:    "https://oauth2.googleapis.com/token",
+	UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+}
+
+// loadOAuthProviders reads the providers named in the comma separated list
+// from OAUTH_<NAME>_* variables, e.g. OAUTH_GOOGLE_CLIENT_ID
+func loadOAuthProviders(names string) (map[string]OAuthProvider, error) {
+	providers := make(map[string]OAuthProvider)
+	for _, name := range splitList(names) {
+		name = strings.ToLower(name)
+		prefix := oauthEnvPrefix(name)
+
+		defaults := OAuthProvider{}
+		if name == "google" {
+			defaults = googleOAuth
+		}
+		trustEmail, err := getEnvBool(prefix+"TRUST_EMAIL", false)
+		if err != nil {
+			return nil, err
+		}
+
+		provider := OAuthProvider{
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			AuthURL:      getEnv(prefix+"AUTH_URL", defaults.AuthURL),
+			TokenURL:     getEnv(prefix+"TOKEN_URL", defaults.TokenURL),
+			UserInfoURL:  getEnv(prefix+"USERINFO_URL", defaults.UserInfoURL),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			CampusID:     getEnv(prefix+"CAMPUS_ID", ""),
+			TrustEmail:   trustEmail,
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// oauthEnvPrefix is the prefix of the variables configuring the provider
+func oauthEnvPrefix(name string) string {
+	return "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// check reports the settings the provider cannot be used without
+func (p OAuthProvider) check(name string) error {
+	prefix := oauthEnvPrefix(name)
+	if p.ClientID == "" || p.ClientSecret == "" {
+		return fmt.Errorf("OAuth provider %q needs %sCLIENT_ID and %sCLIENT_SECRET", name, prefix, prefix)
+	}
+	if p.AuthURL == "" || p.TokenURL == "" || p.UserInfoURL == "" {
+		return fmt.Errorf("OAuth provider %q needs %sAUTH_URL, %sTOKEN_URL and %sUSERINFO_URL", name, prefix, prefix, prefix)
+	}
+	return nil
+}
+
+// loadCookieSettings reads the COOKIE_* variables. Cookies are Secure by
+// default when the service is served over HTTPS.
+func loadCookieSettings(https bool) (CookieSettings, error) {
+	secure, err := getEnvBool("COOKIE_SECURE", https)
+	if err != nil {
+		return CookieSettings{}, err
+	}
+	sameSite, err := parseSameSite(getEnv("COOKIE_SAMESITE", "lax"))
+	if err != nil {
+		return CookieSettings{}, err
+	}
+
+	cookies := CookieSettings{
+		Domain:   strings.TrimSpace(getEnv("COOKIE_DOMAIN", "")),
+		Path:     getEnv("COOKIE_PATH", "/api/v1/auth"),
+		SameSite: sameSite,
+		Secure:   secure,
+	}
+	return cookies, nil
+}
+
+// check reports attributes browsers would reject the cookies for
+func (c CookieSettings) check() []error {
+	var problems []error
+	if strings.ContainsAny(c.Domain, ":/ ") {
+		problems = append(problems, fmt.Errorf("invalid COOKIE_DOMAIN %q: must be a bare host name such as campus.edu", c.Domain))
+	}
+	if !strings.HasPrefix(c.Path, "/") || strings.ContainsAny(c.Path, "; ") {
+		problems = append(problems, fmt.Errorf("invalid COOKIE_PATH %q: must be an absolute path", c.Path))
+	}
+	// browsers drop SameSite=None cookies that are not Secure
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		problems = append(problems, fmt.Errorf("invalid COOKIE_SAMESITE none: requires COOKIE_SECURE=true"))
+	}
+	return problems
+}
+
+// checkOAuth reports settings under which the OAuth state cookie would not
+// come back with the provider's redirect to the callback
+func (c CookieSettings) checkOAuth() error {
+	if c.SameSite == http.SameSiteStrictMode {
+		return fmt.Errorf("invalid COOKIE_SAMESITE strict: OAuth logins need lax or none, as the provider redirects back cross-site")
+	}
+	prefix := strings.TrimSuffix(c.Path, "/")
+	if oauthCallbackPath != prefix && !strings.HasPrefix(oauthCallbackPath, prefix+"/") {
+		return fmt.Errorf("invalid COOKIE_PATH %q: OAuth logins need it to cover %s", c.Path, oauthCallbackPath)
+	}
+	return nil
+}
+
+// parseSameSite parses lax, strict or none
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("invalid COOKIE_SAMESITE %q: must be lax, strict or none", value)
+}
+
+// parseCampusTimezones parses "campus=zone" pairs separated by commas,
+// e.g. "main-campus=Africa/Addis_Ababa,north=Africa/Nairobi"
+func parseCampusTimezones(value string) (map[string]string, error) {
+	zones := make(map[string]string)
+	for _, pair := range splitList(value) {
+		campus, zone, ok := strings.Cut(pair, "=")
+		zone = strings.TrimSpace(zone)
+		if !ok || strings.TrimSpace(campus) == "" || zone == "" {
+			return nil, fmt.Errorf("invalid CAMPUS_TIMEZONES entry %q", pair)
+		}
+		if _, err := time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("invalid CAMPUS_TIMEZONES zone in %q: %w", pair, err)
+		}
+		zones[strings.TrimSpace(campus)] = zone
+	}
+	return zones, nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minJWTSecretLength is the shortest JWT_SECRET accepted, in bytes: the
+// output size of HMAC-SHA256
+const minJWTSecretLength = 32
+
+// minInternalAuthTokenLength is the shortest INTERNAL_AUTH_TOKEN accepted, in bytes
+const minInternalAuthTokenLength = 32
+
+// Validate checks that the settings the service cannot start without are
+// present and well-formed. Every problem found is reported, one per line,
+// rather than only the first.
+func (c *Config) Validate() error {
+	problems := append([]error(nil), c.loadProblems...)
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Errorf("PORT %d is not between 1 and 65535", c.Port))
+	}
+	if err := checkURL(c.DatabaseURL, "postgres", "postgresql"); err != nil {
+		problems = append(problems, fmt.Errorf("DATABASE_URL %w", err))
+	}
+	if err := checkURL(c.RabbitMQURL, "amqp", "amqps"); err != nil {
+		problems = append(problems, fmt.Errorf("RABBITMQ_URL %w", err))
+	}
+	if _, _, err := net.SplitHostPort(c.OTELCollectorAddress()); err != nil {
+		problems = append(problems, fmt.Errorf("OTEL_ENDPOINT %q must be host:port or http(s)://host:port", c.OTELEndpoint))
+	}
+	if err := checkURL(c.PublicURL, "http", "https"); err != nil {
+		problems = append(problems, fmt.Errorf("PUBLIC_URL %w", err))
+	}
+	if c.GRPCPort != 0 {
+		if c.GRPCPort < 1 || c.GRPCPort > 65535 || c.GRPCPort == c.Port {
+			problems = append(problems, fmt.Errorf("GRPC_PORT %d is not between 1 and 65535, or is PORT", c.GRPCPort))
+		}
+		if c.InternalAuthToken == "" {
+			problems = append(problems, errors.New("INTERNAL_AUTH_TOKEN is required when GRPC_PORT is set"))
+		}
+		if (c.GRPCTLSCertFile == "") != (c.GRPCTLSKeyFile == "") {
+			problems = append(problems, errors.New("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together"))
+		}
+		if c.GRPCClientCAFile != "" && c.GRPCTLSCertFile == "" {
+			problems = append(problems, errors.New("GRPC_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE"))
+		}
+	}
+	if c.InternalAuthToken != "" && len(c.InternalAuthToken) < minInternalAuthTokenLength {
+		problems = append(problems, fmt.Errorf("INTERNAL_AUTH_TOKEN must be at least %d bytes, got %d", minInternalAuthTokenLength, len(c.InternalAuthToken)))
+	}
+	if c.RedisURL != "" {
+		if err := checkURL(c.RedisURL, "redis"); err != nil {
+			problems = append(problems, fmt.Errorf("REDIS_URL %w", err))
+		}
+	}
+
+	switch {
+	case c.JWTAlgorithm == "HS256" && c.JWTSecret == "":
+		problems = append(problems, errors.New("JWT_SECRET is required with JWT_ALGORITHM HS256"))
+	case c.JWTSecret != "" && len(c.JWTSecret) < minJWTSecretLength:
+		problems = append(problems, fmt.Errorf("JWT_SECRET must be at least %d bytes, got %d", minJWTSecretLength, len(c.JWTSecret)))
+	}
+	if c.JWTAlgorithm == "RS256" && c.JWTPrivateKeyFile == "" {
+		problems = append(problems, errors.New("JWT_PRIVATE_KEY_FILE is required with JWT_ALGORITHM RS256"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		problems = append(problems, fmt.Errorf("ACCESS_TOKEN_TTL %s must be positive", c.AccessTokenTTL))
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		problems = append(problems, fmt.Errorf("REFRESH_TOKEN_TTL %s must be longer than ACCESS_TOKEN_TTL %s", c.RefreshTokenTTL, c.AccessTokenTTL))
+	}
+	if c.ShortSessionTTL <= c.AccessTokenTTL || c.ShortSessionTTL > c.RefreshTokenTTL {
+		problems = append(problems, fmt.Errorf("SHORT_SESSION_TTL %s must be longer than ACCESS_TOKEN_TTL %s and at most REFRESH_TOKEN_TTL %s", c.ShortSessionTTL, c.AccessTokenTTL, c.RefreshTokenTTL))
+	}
+	if c.ActiveUserCacheTTL < 0 {
+		problems = append(problems, fmt.Errorf("ACTIVE_USER_CACHE_TTL %s must not be negative", c.ActiveUserCacheTTL))
+	}
+	if c.RateLimitRequests < 1 || c.RateLimitWindow <= 0 {
+		problems = append(problems, fmt.Errorf("RATE_LIMIT_REQUESTS %d and RATE_LIMIT_WINDOW %s must be positive", c.RateLimitRequests, c.RateLimitWindow))
+	}
+	for _, group := range sortedKeys(c.RateLimits) {
+		if limit := c.RateLimits[group]; limit.Requests < 1 || limit.Window <= 0 {
+			problems = append(problems, fmt.Errorf("RATE_LIMITS %s=%d/%s must allow at least one request per positive window", group, limit.Requests, limit.Window))
+		}
+	}
+
+	if c.JWTAlgorithm != "HS256" && c.JWTAlgorithm != "RS256" {
+		problems = append(problems, fmt.Errorf("invalid JWT_ALGORITHM %q", c.JWTAlgorithm))
+	}
+	if c.KeyRotationInterval > 0 && c.KeyRotationLead >= c.KeyRotationInterval {
+		problems = append(problems, fmt.Errorf("invalid KEY_ROTATION_LEAD: %s is not shorter than KEY_ROTATION_INTERVAL %s", c.KeyRotationLead, c.KeyRotationInterval))
+	}
+	if _, ok := c.PasswordPeppers[c.PasswordPepperVersion]; c.PasswordPepperVersion != 0 && !ok {
+		problems = append(problems, fmt.Errorf("invalid PASSWORD_PEPPER_VERSION: no pepper %d in PASSWORD_PEPPERS", c.PasswordPepperVersion))
+	}
+	if _, err := time.LoadLocation(c.DefaultTimezone); err != nil {
+		problems = append(problems, fmt.Errorf("invalid DEFAULT_TIMEZONE: %w", err))
+	}
+	if c.ReverificationBatchSize < 1 {
+		problems = append(problems, fmt.Errorf("invalid REVERIFICATION_BATCH_SIZE %d", c.ReverificationBatchSize))
+	}
+	for _, name := range c.ReadinessNonCritical {
+		if name != "rabbitmq" && name != "tracer" {
+			problems = append(problems, fmt.Errorf("invalid READINESS_NON_CRITICAL: unknown dependency %q", name))
+		}
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" && c.AllowCredentials {
+			problems = append(problems, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS *: credentials may only be allowed for listed origins"))
+		}
+		if origin != "*" && (!strings.Contains(origin, "://") || strings.HasSuffix(origin, "/")) {
+			problems = append(problems, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS %q: must be scheme://host[:port] without a path", origin))
+		}
+	}
+
+	problems = append(problems, c.Cookies.check()...)
+	for _, name := range sortedKeys(c.OAuthProviders) {
+		if err := c.OAuthProviders[name].check(name); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(c.OAuthProviders) > 0 {
+		if err := c.Cookies.checkOAuth(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// OTELCollectorAddress is the host:port of OTELEndpoint, which may also be
+// given as an http(s) URL
+func (c *Config) OTELCollectorAddress() string {
+	if u, err := url.Parse(c.OTELEndpoint); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return u.Host
+	}
+	return c.OTELEndpoint
+}
+
+// checkURL reports a value that is missing or not an absolute URL of one of
+// the given schemes
+func checkURL(value string, schemes ...string) error {
+	if value == "" {
+		return errors.New("is required")
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return errors.New("is not an absolute URL")
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("must use the %s scheme, got %q", schemes[0], u.Scheme)
+}
+
+// sortedKeys returns the keys of m in order, so problems are reported in a
+// stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
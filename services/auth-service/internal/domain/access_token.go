@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenFormat is the form of the access tokens issued to a client
+type TokenFormat string
+
+// Access token formats. Clients pick one at registration; sessions opened
+// without a client get the default.
+const (
+	// TokenFormatDefault is a JWT signed with the service's configured algorithm
+	TokenFormatDefault TokenFormat = ""
+	// TokenFormatJWTHS256 is a JWT signed with the shared HMAC secret, for
+	// clients that cannot verify RSA signatures
+	TokenFormatJWTHS256 TokenFormat = "jwt_hs256"
+	// TokenFormatJWTRS256 is a JWT signed with the RSA private key
+	TokenFormatJWTRS256 TokenFormat = "jwt_rs256"
+	// TokenFormatOpaque is a random reference token carrying no claims;
+	// resource servers validate it by introspection
+	TokenFormatOpaque TokenFormat = "opaque"
+)
+
+// AccessToken is an issued opaque access token. Only the hash of the token
+// is stored, with the claims it stands for.
+type AccessToken struct {
+	TokenHash string    `json:"-" db:"token_hash"`
+	SessionID uuid.UUID `json:"session_id" db:"session_id"`
+	// Claims are the JSON encoded access token claims
+	Claims    []byte    `json:"-" db:"claims"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// NewAccessToken creates an opaque access token of the session
+func NewAccessToken(token string, sessionID uuid.UUID, claims []byte, expiresAt time.Time) *AccessToken {
+	return &AccessToken{
+		TokenHash: HashToken(token),
+		SessionID: sessionID,
+		Claims:    claims,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// TokenDecodeRequest carries an access token an administrator wants decoded
+type TokenDecodeRequest struct {
+	Token string `json:"token" validate:"required,max=8192"`
+}
+
+// AccessTokenRepository defines the interface for opaque access token persistence
+type AccessTokenRepository interface {
+	Create(token *AccessToken) error
+	// GetActive fetches the token with the hash unless it has expired at now
+	// or its session was revoked, failing with sql.ErrNoRows
+	GetActive(tokenHash string, now time.Time) (*AccessToken, error)
+	// DeleteExpired removes the tokens expired at before and returns how many
+	DeleteExpired(before time.Time) (int64, error)
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AccountState summarizes whether an account can be used
+type AccountState string
+
+// Account states, in order of precedence
+const (
+	AccountMerged      AccountState = "merged"
+	AccountBanned      AccountState = "banned"
+	AccountSuspended   AccountState = "suspended"
+	AccountDisabled    AccountState = "disabled"
+	AccountDeactivated AccountState = "deactivated"
+	AccountActive      AccountState = "active"
+)
+
+// AccountStatus is the computed status of an account shown to clients. It is
+// the single place the account flags are interpreted; login checks use it too.
+type AccountStatus struct {
+	State  AccountState `json:"state"`
+	Reason string       `json:"reason,omitempty"`
+	Since  *time.Time   `json:"since,omitempty"`
+	Until  *time.Time   `json:"until,omitempty"`
+}
+
+// Status derives the account status from the user's flags. A merged account
+// is retired for good; otherwise a ban outranks a suspension, which outranks a
+// deactivation, by an administrator or by the owner.
+func (u *User) Status() AccountStatus {
+	switch {
+	case u.MergedInto != nil:
+		return AccountStatus{State: AccountMerged, Reason: "account merged into another account", Since: u.DeactivatedAt}
+	case u.BannedAt != nil:
+		return AccountStatus{State: AccountBanned, Reason: "account banned by an administrator", Since: u.BannedAt}
+	case u.SuspendedUntil != nil && time.Now().Before(*u.SuspendedUntil):
+		return AccountStatus{State: AccountSuspended, Reason: "account temporarily suspended", Until: u.SuspendedUntil}
+	case !u.IsActive && u.DisabledBy != nil:
+		return AccountStatus{State: AccountDisabled, Reason: "account disabled by an administrator", Since: u.DeactivatedAt}
+	case !u.IsActive:
+		return AccountStatus{State: AccountDeactivated, Reason: "account deactivated by its owner", Since: u.DeactivatedAt}
+	}
+	return AccountStatus{State: AccountActive}
+}
+
+// MarshalJSON adds the computed status to the user's JSON representation
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return json.Marshal(struct {
+		user
+		Status AccountStatus `json:"status"`
+	}{user(u), u.Status()})
+}
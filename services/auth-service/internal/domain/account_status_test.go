@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUserStatusPrecedence(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	adminID := uuid.New()
+	targetID := uuid.New()
+
+	tests := []struct {
+		name  string
+		setup func(*User)
+		want  AccountState
+	}{
+		{"active", func(u *User) {}, AccountActive},
+		{"deactivated", func(u *User) { u.Deactivate() }, AccountDeactivated},
+		{"disabled", func(u *User) { u.Disable(adminID) }, AccountDisabled},
+		{"reactivated after being disabled", func(u *User) { u.Disable(adminID); u.Reactivate() }, AccountActive},
+		{"suspended", func(u *User) { u.SuspendedUntil = &future }, AccountSuspended},
+		{"suspension over", func(u *User) { u.SuspendedUntil = &past }, AccountActive},
+		{"suspended and disabled", func(u *User) { u.Disable(adminID); u.SuspendedUntil = &future }, AccountSuspended},
+		{"suspension over while deactivated", func(u *User) { u.Deactivate(); u.SuspendedUntil = &past }, AccountDeactivated},
+		{"banned", func(u *User) { u.BannedAt = &past }, AccountBanned},
+		{"banned and suspended", func(u *User) { u.BannedAt = &past; u.SuspendedUntil = &future }, AccountBanned},
+		{"banned and deactivated", func(u *User) { u.Deactivate(); u.BannedAt = &past }, AccountBanned},
+		{"merged", func(u *User) { u.Deactivate(); u.MergedInto = &targetID }, AccountMerged},
+		{"merged and banned", func(u *User) { u.BannedAt = &past; u.MergedInto = &targetID }, AccountMerged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{ID: uuid.New(), IsActive: true}
+			tt.setup(user)
+			if got := user.Status().State; got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserStatusDetails(t *testing.T) {
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	user := &User{ID: uuid.New(), IsActive: true, SuspendedUntil: &future}
+
+	status := user.Status()
+	if status.Reason == "" || status.Until == nil || !status.Until.Equal(future) || status.Since != nil {
+		t.Errorf("suspended status = %+v", status)
+	}
+
+	user.SuspendedUntil = nil
+	user.Deactivate()
+	status = user.Status()
+	if status.Since == nil || !status.Since.Equal(*user.DeactivatedAt) || status.Until != nil {
+		t.Errorf("deactivated status = %+v", status)
+	}
+}
+
+// The status is part of every JSON representation of a user, so /me and the
+// admin user views report the same state the login checks use
+func TestUserJSONCarriesStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	user := User{ID: uuid.New(), Email: "ada@example.edu", IsActive: true, BannedAt: &past}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded struct {
+		Email  string        `json:"email"`
+		Status AccountStatus `json:"status"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Email != user.Email || decoded.Status.State != AccountBanned {
+		t.Errorf("decoded = %+v from %s", decoded, data)
+	}
+
+	// pointers marshal the same way
+	if pointer, _ := json.Marshal(&user); string(pointer) != string(data) {
+		t.Errorf("*User marshals to %s, User to %s", pointer, data)
+	}
+}
+
+func TestReactivationWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		deactivated time.Duration
+		window      time.Duration
+		wantExpired bool
+	}{
+		{"within the window", time.Hour, 24 * time.Hour, false},
+		{"window elapsed", 25 * time.Hour, 24 * time.Hour, true},
+		{"no window", 1000 * time.Hour, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{ID: uuid.New(), IsActive: true}
+			user.Deactivate()
+			deactivatedAt := time.Now().Add(-tt.deactivated)
+			user.DeactivatedAt = &deactivatedAt
+
+			if got := user.ReactivationExpired(tt.window); got != tt.wantExpired {
+				t.Errorf("ReactivationExpired = %v, want %v", got, tt.wantExpired)
+			}
+			if got := user.CanSelfReactivate(tt.window); got == tt.wantExpired {
+				t.Errorf("CanSelfReactivate = %v, want %v", got, !tt.wantExpired)
+			}
+			deadline := user.ReactivationDeadline(tt.window)
+			if tt.window == 0 && deadline != nil {
+				t.Errorf("deadline without a window = %v, want none", deadline)
+			}
+			if tt.window != 0 && (deadline == nil || !deadline.Equal(deactivatedAt.Add(tt.window))) {
+				t.Errorf("deadline = %v, want %v", deadline, deactivatedAt.Add(tt.window))
+			}
+		})
+	}
+
+	if active := (&User{ID: uuid.New(), IsActive: true}); active.ReactivationDeadline(time.Hour) != nil || active.CanSelfReactivate(time.Hour) {
+		t.Error("an active account has a reactivation window")
+	}
+}
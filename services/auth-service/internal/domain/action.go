@@ -0,0 +1,53 @@
+package domain
+
+// Action is an operation performed on another service on the user's behalf,
+// authorized by a short-lived action token
+type Action struct {
+	Name string
+	// Audience is the service that accepts tokens for this action
+	Audience string
+	// Roles lists the roles allowed to perform the action
+	Roles []Role
+}
+
+// Actions lists every action an action token can be issued for
+var Actions = map[string]Action{
+	"media.upload_avatar": {
+		Name:     "media.upload_avatar",
+		Audience: "media-service",
+		Roles:    []Role{RoleStudent, RoleModerator, RoleAdmin},
+	},
+	"media.upload_listing_image": {
+		Name:     "media.upload_listing_image",
+		Audience: "media-service",
+		Roles:    []Role{RoleStudent, RoleModerator, RoleAdmin},
+	},
+	"listing.moderate": {
+		Name:     "listing.moderate",
+		Audience: "listing-service",
+		Roles:    []Role{RoleModerator, RoleAdmin},
+	},
+}
+
+// ActionTokenRequest asks for an action token
+type ActionTokenRequest struct {
+	Action string `json:"action" validate:"required"`
+}
+
+// ActionToken is a short-lived token scoped to a single action
+type ActionToken struct {
+	Token     string `json:"token"`
+	Action    string `json:"action"`
+	Audience  string `json:"audience"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// Allows checks if role may perform the action
+func (a Action) Allows(role Role) bool {
+	for _, allowed := range a.Roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
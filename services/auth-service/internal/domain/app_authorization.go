@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppAuthorization records that a user let an OAuth client act on their
+// account, by logging in through it. It lasts until the user revokes it.
+type AppAuthorization struct {
+	UserID   uuid.UUID `json:"-" db:"user_id"`
+	ClientID string    `json:"client_id" db:"client_id"`
+	// ClientName is the registered name of the client, filled in when listing
+	ClientName string    `json:"client_name" db:"-"`
+	Scopes     []string  `json:"scopes" db:"scopes"`
+	GrantedAt  time.Time `json:"granted_at" db:"granted_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// NewAppAuthorization creates the authorization of a login through the client
+func NewAppAuthorization(userID uuid.UUID, clientID string, scopes []string) *AppAuthorization {
+	now := time.Now()
+	return &AppAuthorization{
+		UserID:     userID,
+		ClientID:   clientID,
+		Scopes:     append([]string{}, scopes...),
+		GrantedAt:  now,
+		LastUsedAt: now,
+	}
+}
+
+// AppAuthorizationRepository defines the interface for app authorization persistence
+type AppAuthorizationRepository interface {
+	// Grant stores the authorization; when the user already authorized the
+	// client, the scopes are added to the granted ones and it is marked used
+	Grant(authorization *AppAuthorization) error
+	// Touch marks the user's authorization of the client as used at
+	Touch(userID uuid.UUID, clientID string, at time.Time) error
+	// ListByUserID returns the user's authorizations with the client names,
+	// most recently used first
+	ListByUserID(userID uuid.UUID) ([]*AppAuthorization, error)
+	// Delete removes the user's authorization of the client, failing with
+	// sql.ErrNoRows when there is none
+	Delete(userID uuid.UUID, clientID string) error
+}
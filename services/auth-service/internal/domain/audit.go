@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions
+const (
+	AuditRoleChanged            = "role_changed"
+	AuditPasswordChangeRequired = "password_change_required"
+	AuditRevocationScheduled    = "revocation_scheduled"
+	AuditBulkReverification     = "bulk_reverification"
+	AuditAccountsMerged         = "accounts_merged"
+	AuditRefreshTokenRevoked    = "refresh_token_revoked"
+	AuditEventDeliveryTested    = "event_delivery_tested"
+	AuditCampusUsersExported    = "campus_users_exported"
+	AuditUserRestored           = "user_restored"
+	AuditUserDeactivated        = "user_deactivated"
+	AuditUserReactivated        = "user_reactivated"
+	AuditDeviceSessionsRevoked  = "device_sessions_revoked"
+	AuditDelegationGranted      = "delegation_granted"
+	AuditDelegationRevoked      = "delegation_revoked"
+	AuditOAuthIdentityLinked    = "oauth_identity_linked"
+	AuditLoginSucceeded         = "login_succeeded"
+	AuditLoginFailed            = "login_failed"
+	AuditLoggedOut              = "logged_out"
+	AuditPasswordChanged        = "password_changed"
+	AuditProfileUpdated         = "profile_updated"
+	AuditSessionRevoked         = "session_revoked"
+)
+
+// AuditMetadata holds action specific details of an audit entry
+type AuditMetadata map[string]interface{}
+
+// AuditLog is an immutable record of a security relevant action on a user's
+// account. Entries form a hash chain: each one's Hash covers its content and
+// the Hash of the entry before it, so editing or deleting an entry breaks
+// every link after it.
+type AuditLog struct {
+	ID        uuid.UUID     `json:"id" db:"id"`
+	UserID    uuid.UUID     `json:"user_id" db:"user_id"`
+	Action    string        `json:"action" db:"action"`
+	IPAddress string        `json:"ip_address" db:"ip_address"`
+	UserAgent string        `json:"user_agent" db:"user_agent"`
+	Metadata  AuditMetadata `json:"metadata" db:"metadata"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+
+	// Sequence is the position in the chain, starting at 1 without gaps
+	Sequence int64  `json:"sequence" db:"seq"`
+	PrevHash string `json:"prev_hash" db:"prev_hash"`
+	Hash     string `json:"hash" db:"hash"`
+}
+
+// NewAuditLog creates an audit entry for an action on the given user. The
+// repository links it into the chain when appending it.
+func NewAuditLog(userID uuid.UUID, action, ipAddress, userAgent string, metadata AuditMetadata) *AuditLog {
+	return &AuditLog{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Action:    action,
+		IPAddress: truncate(ipAddress, MaxIPAddressLength),
+		UserAgent: truncate(userAgent, MaxUserAgentLength),
+		Metadata:  metadata,
+		// stored with microsecond precision; hashing must see the stored value
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+// Link places the entry after prev in the chain, nil for the first entry,
+// and seals it with its hash
+func (e *AuditLog) Link(prev *AuditLog) error {
+	e.Sequence, e.PrevHash = 1, ""
+	if prev != nil {
+		e.Sequence, e.PrevHash = prev.Sequence+1, prev.Hash
+	}
+	hash, err := e.ComputeHash()
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+	return nil
+}
+
+// ComputeHash returns the hex SHA-256 of the previous hash and the entry's
+// content. Metadata is hashed in its canonical JSON form, so the hash is the
+// same before and after a round trip through the database.
+func (e *AuditLog) ComputeHash() (string, error) {
+	metadata, err := e.Metadata.canonical()
+	if err != nil {
+		return "", err
+	}
+	content, err := json.Marshal([]interface{}{
+		e.Sequence,
+		e.ID,
+		e.UserID,
+		e.Action,
+		e.IPAddress,
+		e.UserAgent,
+		metadata,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(e.PrevHash), content...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonical returns the metadata as it reads back from JSON, with numbers as
+// float64 and keys sorted on encoding
+func (m AuditMetadata) canonical() (interface{}, error) {
+	// nil metadata is stored as an empty object
+	if m == nil {
+		m = AuditMetadata{}
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var canonical interface{}
+	if err := json.Unmarshal(raw, &canonical); err != nil {
+		return nil, err
+	}
+	return canonical, nil
+}
+
+// AuditChainProblem names the way an audit chain is broken
+type AuditChainProblem string
+
+// Audit chain problems
+const (
+	// AuditEntryMissing is a gap in the sequence: an entry was deleted
+	AuditEntryMissing AuditChainProblem = "entry_missing"
+	// AuditEntryAltered is an entry whose content no longer matches its hash
+	AuditEntryAltered AuditChainProblem = "entry_altered"
+	// AuditLinkBroken is an entry whose previous hash is not that of the
+	// entry before it: an entry was replaced or re-sealed
+	AuditLinkBroken AuditChainProblem = "link_broken"
+)
+
+// AuditChainBreak reports the first broken entry of a verified range
+type AuditChainBreak struct {
+	Sequence int64             `json:"sequence"`
+	Problem  AuditChainProblem `json:"problem"`
+}
+
+// CheckAuditLink verifies entry against the entry before it, nil when entry
+// is the first of the chain
+func CheckAuditLink(prev, entry *AuditLog) (*AuditChainBreak, error) {
+	expectedSeq, expectedPrev := int64(1), ""
+	if prev != nil {
+		expectedSeq, expectedPrev = prev.Sequence+1, prev.Hash
+	}
+	if entry.Sequence != expectedSeq {
+		return &AuditChainBreak{Sequence: expectedSeq, Problem: AuditEntryMissing}, nil
+	}
+
+	hash, err := entry.ComputeHash()
+	if err != nil {
+		return nil, err
+	}
+	if hash != entry.Hash {
+		return &AuditChainBreak{Sequence: entry.Sequence, Problem: AuditEntryAltered}, nil
+	}
+	if entry.PrevHash != expectedPrev {
+		return &AuditChainBreak{Sequence: entry.Sequence, Problem: AuditLinkBroken}, nil
+	}
+	return nil, nil
+}
+
+// Value stores the metadata as JSONB
+func (m AuditMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan reads the metadata from a JSONB column
+func (m *AuditMetadata) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = AuditMetadata{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return fmt.Errorf("unsupported audit metadata type %T", src)
+	}
+}
+
+// AuditRepository defines the interface for audit log persistence
+type AuditRepository interface {
+	// Create links the entry after the last one of the chain and appends it
+	Create(entry *AuditLog) error
+	// GetBySequence returns the entry at the given position of the chain
+	GetBySequence(seq int64) (*AuditLog, error)
+	// ListChain returns up to limit entries with a sequence of at least from
+	// and at most to, in chain order
+	ListChain(from, to int64, limit int) ([]*AuditLog, error)
+	// LastSequence returns the sequence of the newest entry, 0 when there is none
+	LastSequence() (int64, error)
+	// ListByUser returns a page of the user's entries, newest first, and the
+	// total number of them
+	ListByUser(userID uuid.UUID, limit, offset int) ([]*AuditLog, int, error)
+}
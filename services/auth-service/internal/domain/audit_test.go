@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// auditChain links n entries of the same user into a chain
+func auditChain(t *testing.T, n int) []*AuditLog {
+	t.Helper()
+	userID := uuid.New()
+	var chain []*AuditLog
+	var prev *AuditLog
+	for i := 0; i < n; i++ {
+		entry := NewAuditLog(userID, AuditLoginSucceeded, "192.0.2.1", "test-agent", AuditMetadata{"attempt": i, "method": "password"})
+		if err := entry.Link(prev); err != nil {
+			t.Fatalf("Link: %v", err)
+		}
+		chain = append(chain, entry)
+		prev = entry
+	}
+	return chain
+}
+
+// firstBreak checks the links of chain in order
+func firstBreak(t *testing.T, chain []*AuditLog) *AuditChainBreak {
+	t.Helper()
+	var prev *AuditLog
+	for _, entry := range chain {
+		broken, err := CheckAuditLink(prev, entry)
+		if err != nil {
+			t.Fatalf("CheckAuditLink: %v", err)
+		}
+		if broken != nil {
+			return broken
+		}
+		prev = entry
+	}
+	return nil
+}
+
+func TestAuditChainIntact(t *testing.T) {
+	chain := auditChain(t, 3)
+	for i, entry := range chain {
+		if entry.Sequence != int64(i+1) || entry.Hash == "" {
+			t.Errorf("entry %d linked as %d with hash %q", i, entry.Sequence, entry.Hash)
+		}
+	}
+	if chain[0].PrevHash != "" || chain[2].PrevHash != chain[1].Hash {
+		t.Error("entries not chained to their predecessors")
+	}
+	if broken := firstBreak(t, chain); broken != nil {
+		t.Errorf("intact chain broken at %+v", broken)
+	}
+
+	// metadata reads back from JSONB with float numbers
+	stored, err := chain[1].Metadata.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	var read AuditMetadata
+	if err := read.Scan(stored); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	chain[1].Metadata = read
+	if broken := firstBreak(t, chain); broken != nil {
+		t.Errorf("chain broken by a round trip through the database at %+v", broken)
+	}
+}
+
+func TestAuditChainTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]*AuditLog) []*AuditLog
+		want   AuditChainBreak
+	}{
+		{"content edited", func(c []*AuditLog) []*AuditLog {
+			c[1].Action = AuditPasswordChanged
+			return c
+		}, AuditChainBreak{Sequence: 2, Problem: AuditEntryAltered}},
+		{"metadata edited", func(c []*AuditLog) []*AuditLog {
+			c[2].Metadata["method"] = "passkey"
+			return c
+		}, AuditChainBreak{Sequence: 3, Problem: AuditEntryAltered}},
+		{"edited and resealed", func(c []*AuditLog) []*AuditLog {
+			c[1].IPAddress = "198.51.100.7"
+			c[1].Hash, _ = c[1].ComputeHash()
+			return c
+		}, AuditChainBreak{Sequence: 3, Problem: AuditLinkBroken}},
+		{"entry deleted", func(c []*AuditLog) []*AuditLog {
+			return append(c[:1], c[2:]...)
+		}, AuditChainBreak{Sequence: 2, Problem: AuditEntryMissing}},
+		{"first entry deleted", func(c []*AuditLog) []*AuditLog {
+			return c[1:]
+		}, AuditChainBreak{Sequence: 1, Problem: AuditEntryMissing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broken := firstBreak(t, tt.tamper(auditChain(t, 4)))
+			if broken == nil || *broken != tt.want {
+				t.Errorf("break = %+v, want %+v", broken, tt.want)
+			}
+		})
+	}
+}
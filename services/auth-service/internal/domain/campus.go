@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// campusIDPattern is the canonical campus ID: lowercase letters and digits in
+// hyphen-separated words, e.g. "aau-main". A UUID in its lowercase form is
+// also a valid campus ID.
+var campusIDPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CheckCampusID fails unless id is a canonical campus ID. It is the single
+// check for every campus ID a client submits.
+func CheckCampusID(field, id string) error {
+	if err := checkLength(field, id, MaxCampusIDLength); err != nil {
+		return err
+	}
+	if !campusIDPattern.MatchString(id) {
+		return ValidationError{Field: field, Message: "must be a campus slug of lowercase letters, digits and single hyphens, or a lowercase UUID"}
+	}
+	return nil
+}
+
+// Failures of a campus scoped action
+var (
+	ErrNoCampus      = errors.New("moderator has no campus")
+	ErrOutsideCampus = errors.New("user is outside your campus")
+)
+
+// CampusScope is the reach of a staff member over other users' accounts.
+// Admins reach every campus; moderators only the users of their own campus,
+// and none when they have no campus.
+type CampusScope struct {
+	Role     Role
+	CampusID *string
+}
+
+// Unrestricted reports whether the scope reaches every campus
+func (s CampusScope) Unrestricted() bool {
+	return s.Role == RoleAdmin
+}
+
+// Narrow restricts the filter to the campus of the scope, refusing a filter
+// on another campus
+func (s CampusScope) Narrow(filter UserFilter) (UserFilter, error) {
+	if s.Unrestricted() {
+		return filter, nil
+	}
+	if s.CampusID == nil {
+		return filter, ErrNoCampus
+	}
+	if filter.CampusID != nil && *filter.CampusID != *s.CampusID {
+		return filter, ErrOutsideCampus
+	}
+	filter.CampusID = s.CampusID
+	return filter, nil
+}
+
+// Check fails unless the scope reaches the user
+func (s CampusScope) Check(user *User) error {
+	if s.Unrestricted() {
+		return nil
+	}
+	if s.CampusID == nil {
+		return ErrNoCampus
+	}
+	if user.CampusID == nil || *user.CampusID != *s.CampusID {
+		return ErrOutsideCampus
+	}
+	return nil
+}
+
+// CampusUserRecord is one user in a campus offboarding export: account data
+// only, without credentials, secrets or recovery contacts
+type CampusUserRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Email       string     `json:"email"`
+	FirstName   string     `json:"first_name"`
+	LastName    string     `json:"last_name"`
+	Role        Role       `json:"role"`
+	IsActive    bool       `json:"is_active"`
+	IsVerified  bool       `json:"is_verified"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// CampusUserRecordFields are the CSV columns of CampusUserRecord, in order
+var CampusUserRecordFields = []string{"id", "email", "first_name", "last_name", "role", "is_active", "is_verified", "created_at", "last_login_at"}
+
+// CampusRecord returns the user's campus export record
+func (u *User) CampusRecord() *CampusUserRecord {
+	return &CampusUserRecord{
+		ID:          u.ID,
+		Email:       u.Email,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		Role:        u.Role,
+		IsActive:    u.IsActive,
+		IsVerified:  u.IsVerified,
+		CreatedAt:   u.CreatedAt,
+		LastLoginAt: u.LastLoginAt,
+	}
+}
+
+// CSV returns the record's values in CampusUserRecordFields order
+func (r *CampusUserRecord) CSV() []string {
+	lastLogin := ""
+	if r.LastLoginAt != nil {
+		lastLogin = r.LastLoginAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		r.ID.String(),
+		r.Email,
+		r.FirstName,
+		r.LastName,
+		string(r.Role),
+		strconv.FormatBool(r.IsActive),
+		strconv.FormatBool(r.IsVerified),
+		r.CreatedAt.UTC().Format(time.RFC3339),
+		lastLogin,
+	}
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckCampusID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"main", true},
+		{"aau-main", true},
+		{"campus-2", true},
+		{"3f2b8c1e-9d4a-4f6b-8e2c-1a7d5b9c0e4f", true},
+		{"", false},
+		{"AAU-main", false},
+		{"3F2B8C1E-9D4A-4F6B-8E2C-1A7D5B9C0E4F", false},
+		{"aau--main", false},
+		{"-main", false},
+		{"main-", false},
+		{"aau_main", false},
+		{"aau main", false},
+		{"aau/main", false},
+		{"cámpus", false},
+		{strings.Repeat("a", MaxCampusIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			err := CheckCampusID("campus_id", tt.id)
+			if tt.valid && err != nil {
+				t.Errorf("CheckCampusID = %v, want valid", err)
+			}
+			var validation ValidationError
+			if !tt.valid && (!errors.As(err, &validation) || validation.Field != "campus_id") {
+				t.Errorf("CheckCampusID = %v, want a ValidationError on campus_id", err)
+			}
+		})
+	}
+}
+
+func TestCampusIDCheckedWhereAccepted(t *testing.T) {
+	malformed := "Main Campus"
+	var validation ValidationError
+
+	reg := UserRegistration{Email: "ada@example.edu", Password: "password-123", FirstName: "Ada", LastName: "Lovelace", CampusID: malformed}
+	if _, err := NewUser(reg, Peppers{}); !errors.As(err, &validation) {
+		t.Errorf("NewUser = %v, want a ValidationError", err)
+	}
+	if err := (UserProfile{CampusID: &malformed}).Validate(); !errors.As(err, &validation) {
+		t.Errorf("UserProfile.Validate = %v, want a ValidationError", err)
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords holds the embedded common password list, lowercased
+var commonPasswords = parseCommonPasswords(commonPasswordList)
+
+func parseCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
+
+// isCommonPassword reports whether password is on the common password list,
+// ignoring case
+func isCommonPassword(password string) bool {
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
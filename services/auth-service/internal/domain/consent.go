@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConsentPurpose identifies a kind of data processing a user can consent to
+type ConsentPurpose string
+
+// Consent purposes
+const (
+	ConsentMarketing   ConsentPurpose = "marketing"
+	ConsentAnalytics   ConsentPurpose = "analytics"
+	ConsentDataSharing ConsentPurpose = "data_sharing"
+)
+
+// Consent is one recorded consent decision. Records are append-only: a change
+// of mind adds a new record, so the history shows what the user agreed to,
+// under which version of the consent text, and when.
+type Consent struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	UserID      uuid.UUID      `json:"user_id" db:"user_id"`
+	Purpose     ConsentPurpose `json:"purpose" db:"purpose"`
+	Granted     bool           `json:"granted" db:"granted"`
+	TextVersion string         `json:"text_version" db:"text_version"`
+	IPAddress   string         `json:"ip_address" db:"ip_address"`
+	RecordedAt  time.Time      `json:"recorded_at" db:"recorded_at"`
+}
+
+// ConsentDecision is a single consent choice submitted by the user
+type ConsentDecision struct {
+	Purpose     ConsentPurpose `json:"purpose" validate:"required,oneof=marketing analytics data_sharing"`
+	Granted     bool           `json:"granted"`
+	TextVersion string         `json:"text_version" validate:"required,max=32"`
+}
+
+// ConsentUpdate is the request body for recording consent decisions
+type ConsentUpdate struct {
+	Consents []ConsentDecision `json:"consents" validate:"required,min=1,dive"`
+}
+
+// NewConsent creates a consent record for a decision
+func NewConsent(userID uuid.UUID, decision ConsentDecision, ipAddress string) *Consent {
+	return &Consent{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Purpose:     decision.Purpose,
+		Granted:     decision.Granted,
+		TextVersion: decision.TextVersion,
+		IPAddress:   truncate(ipAddress, MaxIPAddressLength),
+		RecordedAt:  time.Now(),
+	}
+}
+
+// CurrentConsents reduces a consent history, newest first, to the latest
+// record per purpose
+func CurrentConsents(history []*Consent) []*Consent {
+	seen := make(map[ConsentPurpose]bool)
+	var current []*Consent
+	for _, consent := range history {
+		if seen[consent.Purpose] {
+			continue
+		}
+		seen[consent.Purpose] = true
+		current = append(current, consent)
+	}
+	return current
+}
+
+// DataExport is the personal data held about a user, returned on request
+type DataExport struct {
+	ExportedAt     time.Time        `json:"exported_at"`
+	User           *User            `json:"user"`
+	Sessions       []*Session       `json:"sessions"`
+	TrustedDevices []*TrustedDevice `json:"trusted_devices"`
+	Consents       []*Consent       `json:"consents"`
+}
+
+// ConsentRepository defines the interface for consent persistence
+type ConsentRepository interface {
+	Create(consent *Consent) error
+	ListByUserID(userID uuid.UUID) ([]*Consent, error)
+	GetLatest(userID uuid.UUID, purpose ConsentPurpose) (*Consent, error)
+}
@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DelegationScope is a part of a user's account a delegate may read
+type DelegationScope string
+
+// Delegation scopes
+const (
+	// DelegationProfile covers the limited profile: name, campus, avatar and
+	// whether the email is verified
+	DelegationProfile DelegationScope = "profile"
+	// DelegationContact covers the login email and verified phone number
+	DelegationContact DelegationScope = "contact"
+)
+
+// Delegation lets a delegate, such as an advisor or guardian, read parts of
+// the grantor's account with tokens of their own. It lasts until it expires
+// or either of them revokes it.
+type Delegation struct {
+	ID         uuid.UUID         `json:"id" db:"id"`
+	GrantorID  uuid.UUID         `json:"grantor_id" db:"grantor_id"`
+	DelegateID uuid.UUID         `json:"delegate_id" db:"delegate_id"`
+	Scopes     []DelegationScope `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
+	// ExpiresAt ends the delegation; nil keeps it until revoked
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// DelegationRequest is the request body a user sends to delegate access
+type DelegationRequest struct {
+	DelegateEmail string   `json:"delegate_email" validate:"required,email,max=254"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,max=2,dive,oneof=profile contact"`
+	// ExpiresInDays limits the delegation; zero keeps it until revoked
+	ExpiresInDays int `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// DelegatedProfile is the view of the grantor's account a delegate gets;
+// fields outside the scope read are left empty
+type DelegatedProfile struct {
+	ID         uuid.UUID `json:"id"`
+	FirstName  string    `json:"first_name,omitempty"`
+	LastName   string    `json:"last_name,omitempty"`
+	CampusID   *string   `json:"campus_id,omitempty"`
+	AvatarURL  *string   `json:"avatar_url,omitempty"`
+	IsVerified *bool     `json:"is_verified,omitempty"`
+	Email      string    `json:"email,omitempty"`
+	Phone      *string   `json:"phone,omitempty"`
+}
+
+// DelegatedToken is an access token a delegate reads the grantor's account
+// with; it reaches the delegated routes only
+type DelegatedToken struct {
+	AccessToken string            `json:"access_token"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	GrantorID   uuid.UUID         `json:"grantor_id"`
+	Scopes      []DelegationScope `json:"scopes"`
+}
+
+// NewDelegation creates a delegation of the scopes from grantor to delegate
+func NewDelegation(grantorID, delegateID uuid.UUID, req DelegationRequest) *Delegation {
+	now := time.Now()
+	delegation := &Delegation{
+		ID:         uuid.New(),
+		GrantorID:  grantorID,
+		DelegateID: delegateID,
+		CreatedAt:  now,
+	}
+	for _, scope := range req.Scopes {
+		if !delegation.Allows(DelegationScope(scope)) {
+			delegation.Scopes = append(delegation.Scopes, DelegationScope(scope))
+		}
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		delegation.ExpiresAt = &expiresAt
+	}
+	return delegation
+}
+
+// IsActive reports whether the delegation is neither revoked nor expired
+func (d *Delegation) IsActive(now time.Time) bool {
+	return d.RevokedAt == nil && (d.ExpiresAt == nil || now.Before(*d.ExpiresAt))
+}
+
+// Allows reports whether the delegation covers scope
+func (d *Delegation) Allows(scope DelegationScope) bool {
+	for _, held := range d.Scopes {
+		if held == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// View returns the part of the grantor's account under scope
+func View(grantor *User, scope DelegationScope) DelegatedProfile {
+	profile := DelegatedProfile{ID: grantor.ID}
+	switch scope {
+	case DelegationProfile:
+		verified := grantor.IsVerified
+		profile.FirstName = grantor.FirstName
+		profile.LastName = grantor.LastName
+		profile.CampusID = grantor.CampusID
+		profile.AvatarURL = grantor.AvatarURL
+		profile.IsVerified = &verified
+	case DelegationContact:
+		profile.Email = grantor.Email
+		if grantor.PhoneVerified {
+			profile.Phone = grantor.Phone
+		}
+	}
+	return profile
+}
+
+// DelegationRepository defines the interface for delegation persistence
+type DelegationRepository interface {
+	Create(delegation *Delegation) error
+	GetByID(id uuid.UUID) (*Delegation, error)
+	// ListByUserID returns the delegations the user granted or received,
+	// newest first
+	ListByUserID(userID uuid.UUID) ([]*Delegation, error)
+	// Revoke ends an active delegation the user granted or received,
+	// failing with sql.ErrNoRows when there is none
+	Revoke(id, userID uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceLogin is a login started on a shared kiosk and approved by the
+// student from a device they are already logged in on. The kiosk shows the
+// user code (or a QR code of the verification link) and polls with the
+// device code until the login is approved or expires.
+type DeviceLogin struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	KioskID        string     `json:"kiosk_id" db:"kiosk_id"`
+	DeviceCodeHash string     `json:"-" db:"device_code_hash"`
+	UserCode       string     `json:"user_code" db:"user_code"`
+	UserID         *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	ApprovedAt     *time.Time `json:"approved_at,omitempty" db:"approved_at"`
+	LastPolledAt   *time.Time `json:"last_polled_at,omitempty" db:"last_polled_at"`
+	ConsumedAt     *time.Time `json:"consumed_at,omitempty" db:"consumed_at"`
+}
+
+// DeviceAuthorization is returned to the kiosk when it starts a device login
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceLoginApproval is the request body a student sends to approve a kiosk login
+type DeviceLoginApproval struct {
+	UserCode string `json:"user_code" validate:"required,max=16"`
+}
+
+// NewDeviceLogin creates a pending device login for the kiosk. Only the hash
+// of the device code is kept.
+func NewDeviceLogin(kioskID, deviceCode, userCode string, ttl time.Duration) *DeviceLogin {
+	now := time.Now()
+	return &DeviceLogin{
+		ID:             uuid.New(),
+		KioskID:        kioskID,
+		DeviceCodeHash: HashToken(deviceCode),
+		UserCode:       NormalizeUserCode(userCode),
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+	}
+}
+
+// NormalizeUserCode drops separators and case so "abcd-efgh" matches "ABCDEFGH"
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// FormatUserCode splits a user code in two halves for display
+func FormatUserCode(code string) string {
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// IsExpired checks if the login can no longer be approved or completed
+func (d *DeviceLogin) IsExpired() bool {
+	return time.Now().After(d.ExpiresAt)
+}
+
+// IsApproved checks if a user has approved the login
+func (d *DeviceLogin) IsApproved() bool {
+	return d.UserID != nil
+}
+
+// IsConsumed checks if the kiosk has already received tokens for the login
+func (d *DeviceLogin) IsConsumed() bool {
+	return d.ConsumedAt != nil
+}
+
+// Approve records the user logging in on the kiosk
+func (d *DeviceLogin) Approve(userID uuid.UUID) {
+	now := time.Now()
+	d.UserID = &userID
+	d.ApprovedAt = &now
+}
+
+// RecordPoll records a poll by the kiosk and reports whether it came sooner
+// than the polling interval allows
+func (d *DeviceLogin) RecordPoll(interval time.Duration) bool {
+	now := time.Now()
+	tooSoon := d.LastPolledAt != nil && now.Sub(*d.LastPolledAt) < interval
+	d.LastPolledAt = &now
+	return tooSoon
+}
+
+// DeviceLoginRepository defines the interface for device login persistence
+type DeviceLoginRepository interface {
+	Create(login *DeviceLogin) error
+	GetByDeviceCodeHash(hash string) (*DeviceLogin, error)
+	// GetPendingByUserCode returns the unapproved, unexpired login with the code
+	GetPendingByUserCode(userCode string) (*DeviceLogin, error)
+	Update(login *DeviceLogin) error
+	// Consume marks an approved login as consumed, failing with sql.ErrNoRows
+	// when it already was
+	Consume(id uuid.UUID) error
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "strings"
+
+// aliasingDomains are the mail domains that ignore dots in the local part
+// and deliver user+tag to user, so each address has many spellings
+var aliasingDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// NormalizeEmail trims and lowercases an email. Stored emails are normalized,
+// so lookups must normalize the address they are given too.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// StripEmailAliases returns the normalized email with the dots and plus tag
+// of its local part dropped, and whether its domain treats those as aliases
+// of one mailbox. Emails of other domains are returned normalized.
+func StripEmailAliases(email string) (string, bool) {
+	email = NormalizeEmail(email)
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || !aliasingDomains[domain] {
+		return email, false
+	}
+
+	local, _, _ = strings.Cut(local, "+")
+	return strings.ReplaceAll(local, ".", "") + "@" + domain, true
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Maximum lengths of stored string fields, matching the database column sizes
+const (
+	MaxEmailLength     = 254
+	MaxPasswordLength  = 72 // bcrypt ignores bytes beyond 72
+	MaxNameLength      = 50
+	MaxCampusIDLength  = 64
+	MaxAvatarURLLength = 512
+	MaxIPAddressLength = 45
+	MaxUserAgentLength = 512
+	MaxPhoneLength     = 16 // E.164: "+" and up to 15 digits
+)
+
+// ValidationError reports an invalid field value
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("validation error on field %s: %s", e.Field, e.Message)
+}
+
+// checkLength fails when value is longer than max characters
+func checkLength(field, value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return ValidationError{Field: field, Message: fmt.Sprintf("must be at most %d characters", max)}
+	}
+	return nil
+}
+
+// checkPasswordLength fails when password exceeds what bcrypt can hash
+func checkPasswordLength(field, password string) error {
+	if len(password) > MaxPasswordLength {
+		return ValidationError{Field: field, Message: fmt.Sprintf("must be at most %d bytes", MaxPasswordLength)}
+	}
+	return nil
+}
+
+// truncate shortens value to max characters; used for client-supplied metadata
+// that should never fail a request
+func truncate(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	return string([]rune(value)[:max])
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ofLength returns a value of n characters in the shape a field accepts
+func ofLength(n int, shape string) string {
+	switch shape {
+	case "email":
+		return strings.Repeat("a", n-len("@example.edu")) + "@example.edu"
+	case "url":
+		return "https://cdn.example.edu/" + strings.Repeat("a", n-len("https://cdn.example.edu/"))
+	case "phone":
+		return "+" + strings.Repeat("1", n-1)
+	case "name":
+		// multi-byte letters: limits count characters, not bytes
+		return strings.Repeat("é", n)
+	}
+	return strings.Repeat("a", n)
+}
+
+func validRegistration() UserRegistration {
+	return UserRegistration{
+		Email:     "ada@example.edu",
+		Password:  "password-123",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		CampusID:  "main-campus",
+	}
+}
+
+func TestFieldLengthLimits(t *testing.T) {
+	tests := []struct {
+		field string
+		max   int
+		shape string
+		check func(value string) error
+	}{
+		{"email", MaxEmailLength, "email", func(v string) error {
+			reg := validRegistration()
+			reg.Email = v
+			return reg.checkLengths()
+		}},
+		{"password", MaxPasswordLength, "", func(v string) error {
+			reg := validRegistration()
+			reg.Password = v
+			return reg.checkLengths()
+		}},
+		{"first_name", MaxNameLength, "name", func(v string) error {
+			reg := validRegistration()
+			reg.FirstName = v
+			return reg.checkLengths()
+		}},
+		{"last_name", MaxNameLength, "name", func(v string) error {
+			reg := validRegistration()
+			reg.LastName = v
+			return reg.checkLengths()
+		}},
+		{"campus_id", MaxCampusIDLength, "", func(v string) error {
+			reg := validRegistration()
+			reg.CampusID = v
+			return reg.checkLengths()
+		}},
+		{"first_name", MaxNameLength, "name", func(v string) error {
+			return UserProfile{FirstName: v}.Validate()
+		}},
+		{"last_name", MaxNameLength, "name", func(v string) error {
+			return UserProfile{LastName: v}.Validate()
+		}},
+		{"campus_id", MaxCampusIDLength, "", func(v string) error {
+			return UserProfile{CampusID: &v}.Validate()
+		}},
+		{"avatar_url", MaxAvatarURLLength, "url", func(v string) error {
+			return UserProfile{AvatarURL: &v}.Validate()
+		}},
+		{"email", MaxEmailLength, "email", func(v string) error {
+			return (&User{}).ChangeEmail(v)
+		}},
+		{"recovery_email", MaxEmailLength, "email", func(v string) error {
+			return (&User{}).SetRecoveryEmail(v)
+		}},
+		{"phone", MaxPhoneLength, "phone", func(v string) error {
+			return (&User{}).SetPhone(v)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if err := tt.check(ofLength(tt.max, tt.shape)); err != nil {
+				t.Errorf("at the limit of %d: %v", tt.max, err)
+			}
+
+			err := tt.check(ofLength(tt.max+1, tt.shape))
+			var validation ValidationError
+			if !errors.As(err, &validation) {
+				t.Fatalf("beyond the limit of %d: got %v, want a ValidationError", tt.max, err)
+			}
+			if validation.Field != tt.field {
+				t.Errorf("error names field %q, want %q", validation.Field, tt.field)
+			}
+		})
+	}
+}
+
+// bcrypt limits passwords in bytes, so multi-byte characters count double
+func TestPasswordLimitCountsBytes(t *testing.T) {
+	reg := validRegistration()
+	reg.Password = strings.Repeat("é", MaxPasswordLength/2+1)
+	if err := reg.checkLengths(); err == nil {
+		t.Fatalf("a password of %d bytes was accepted", len(reg.Password))
+	}
+}
+
+func TestNewUserRejectsOversizedFields(t *testing.T) {
+	reg := validRegistration()
+	reg.FirstName = ofLength(MaxNameLength+1, "name")
+	if _, err := NewUser(reg, Peppers{}); !errors.As(err, new(ValidationError)) {
+		t.Fatalf("NewUser = %v, want a ValidationError", err)
+	}
+
+	user := &User{FirstName: "Ada"}
+	if err := user.UpdateProfile(UserProfile{FirstName: ofLength(MaxNameLength+1, "name")}); err == nil {
+		t.Fatal("UpdateProfile accepted an oversized first name")
+	}
+	if user.FirstName != "Ada" {
+		t.Errorf("rejected update changed the first name to %q", user.FirstName)
+	}
+
+	profile := ExternalProfile{Subject: "sub", Email: ofLength(MaxEmailLength+1, "email")}
+	if _, err := NewExternalUser(profile, "password-123", "", Peppers{}); !errors.As(err, new(ValidationError)) {
+		t.Fatalf("NewExternalUser = %v, want a ValidationError", err)
+	}
+}
+
+// Client-supplied metadata is cut to fit rather than failing the request
+func TestSessionMetadataIsTruncated(t *testing.T) {
+	session := NewSession(uuid.New(), "", ofLength(MaxIPAddressLength+10, ""), ofLength(MaxUserAgentLength+1, "name"), time.Now().Add(time.Hour))
+	if got := len(session.IPAddress); got != MaxIPAddressLength {
+		t.Errorf("IP address of %d characters stored", got)
+	}
+	if got := len([]rune(session.UserAgent)); got != MaxUserAgentLength {
+		t.Errorf("user agent of %d characters stored", got)
+	}
+}
@@ -0,0 +1,197 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MergePolicy decides which account's value a merge keeps for a field both
+// accounts have set to different values
+type MergePolicy string
+
+// Merge policies
+const (
+	MergePreferTarget MergePolicy = "prefer_target"
+	MergePreferSource MergePolicy = "prefer_source"
+	// MergePreferNewer keeps the value of the most recently updated account
+	MergePreferNewer MergePolicy = "prefer_newer"
+	// MergeManual leaves the conflict unresolved unless a choice is given
+	MergeManual MergePolicy = "manual"
+)
+
+// Sides of a merge a field value can be taken from
+const (
+	MergeSideSource = "source"
+	MergeSideTarget = "target"
+)
+
+// MergeRequest asks to fold the source account into the target account. The
+// source is retired; the target keeps its email, password and factors.
+type MergeRequest struct {
+	SourceID uuid.UUID `json:"source_id" validate:"required"`
+	TargetID uuid.UUID `json:"target_id" validate:"required"`
+	// DefaultPolicy applies to fields without an entry in Policies
+	DefaultPolicy MergePolicy            `json:"default_policy" validate:"omitempty,oneof=prefer_target prefer_source prefer_newer manual"`
+	Policies      map[string]MergePolicy `json:"policies" validate:"omitempty,dive,keys,oneof=first_name last_name campus_id role avatar_url recovery_email phone,endkeys,oneof=prefer_target prefer_source prefer_newer manual"`
+	// Choices pick a side per field and override any policy
+	Choices map[string]string `json:"choices" validate:"omitempty,dive,keys,oneof=first_name last_name campus_id role avatar_url recovery_email phone,endkeys,oneof=source target"`
+	// RequireExplicit ignores the policies: every conflict needs a choice
+	RequireExplicit bool `json:"require_explicit"`
+	// DryRun only reports the conflicts and their resolution
+	DryRun bool `json:"dry_run"`
+}
+
+// MergeConflict is a field both accounts set to different values
+type MergeConflict struct {
+	Field       string      `json:"field"`
+	SourceValue interface{} `json:"source_value"`
+	TargetValue interface{} `json:"target_value"`
+	Policy      MergePolicy `json:"policy,omitempty"`
+	// Resolution is the side kept, empty while unresolved
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// MergeReport describes how a merge resolves each field
+type MergeReport struct {
+	SourceID uuid.UUID `json:"source_id"`
+	TargetID uuid.UUID `json:"target_id"`
+	// Filled lists fields only the source had set, copied to the target
+	Filled     []string        `json:"filled"`
+	Conflicts  []MergeConflict `json:"conflicts"`
+	Unresolved []string        `json:"unresolved"`
+	Merged     bool            `json:"merged"`
+}
+
+// Resolved reports whether every conflict has a resolution
+func (r *MergeReport) Resolved() bool {
+	return len(r.Unresolved) == 0
+}
+
+// TakesFromSource reports whether the merge copies the field from the source
+func (r *MergeReport) TakesFromSource(field string) bool {
+	if contains(r.Filled, field) {
+		return true
+	}
+	for _, conflict := range r.Conflicts {
+		if conflict.Field == field {
+			return conflict.Resolution == MergeSideSource
+		}
+	}
+	return false
+}
+
+// mergeField reads and copies one mergeable user field
+type mergeField struct {
+	name  string
+	value func(u *User) interface{}
+	// take copies the field from src to dst and clears it on src
+	take func(dst, src *User)
+}
+
+// mergeFields lists the fields a merge reconciles, in report order
+var mergeFields = []mergeField{
+	{"first_name", func(u *User) interface{} { return u.FirstName }, func(dst, src *User) { dst.FirstName = src.FirstName }},
+	{"last_name", func(u *User) interface{} { return u.LastName }, func(dst, src *User) { dst.LastName = src.LastName }},
+	{"campus_id", func(u *User) interface{} { return deref(u.CampusID) }, func(dst, src *User) { dst.CampusID = src.CampusID }},
+	{"role", func(u *User) interface{} { return string(u.Role) }, func(dst, src *User) { dst.Role = src.Role }},
+	{"avatar_url", func(u *User) interface{} { return deref(u.AvatarURL) }, func(dst, src *User) { dst.AvatarURL = src.AvatarURL }},
+	{"recovery_email", func(u *User) interface{} { return deref(u.RecoveryEmail) }, func(dst, src *User) {
+		dst.RecoveryEmail, dst.RecoveryEmailVerified = src.RecoveryEmail, src.RecoveryEmailVerified
+		src.RecoveryEmail, src.RecoveryEmailVerified = nil, false
+	}},
+	{"phone", func(u *User) interface{} { return deref(u.Phone) }, func(dst, src *User) {
+		dst.Phone, dst.PhoneVerified = src.Phone, src.PhoneVerified
+		src.Phone, src.PhoneVerified = nil, false
+	}},
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// PlanMerge compares source and target field by field. Fields only the
+// source has set are filled in; fields both set differently are conflicts
+// resolved by explicit choice, then by field policy, then by the default
+// policy, which is prefer_target when not given.
+func PlanMerge(source, target *User, req MergeRequest) *MergeReport {
+	report := &MergeReport{
+		SourceID:   source.ID,
+		TargetID:   target.ID,
+		Filled:     []string{},
+		Conflicts:  []MergeConflict{},
+		Unresolved: []string{},
+	}
+
+	for _, field := range mergeFields {
+		sourceValue, targetValue := field.value(source), field.value(target)
+		switch {
+		case sourceValue == "" || sourceValue == targetValue:
+			continue
+		case targetValue == "":
+			report.Filled = append(report.Filled, field.name)
+			continue
+		}
+
+		conflict := MergeConflict{Field: field.name, SourceValue: sourceValue, TargetValue: targetValue}
+		if choice, ok := req.Choices[field.name]; ok {
+			conflict.Resolution = choice
+		} else if !req.RequireExplicit {
+			conflict.Policy = req.policy(field.name)
+			conflict.Resolution = conflict.Policy.resolve(source.UpdatedAt, target.UpdatedAt)
+		}
+		if conflict.Resolution == "" {
+			report.Unresolved = append(report.Unresolved, field.name)
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+	return report
+}
+
+// policy returns the policy that applies to the field
+func (r MergeRequest) policy(field string) MergePolicy {
+	if policy, ok := r.Policies[field]; ok {
+		return policy
+	}
+	if r.DefaultPolicy != "" {
+		return r.DefaultPolicy
+	}
+	return MergePreferTarget
+}
+
+// resolve picks the side the policy keeps; ties of prefer_newer keep the target
+func (p MergePolicy) resolve(sourceUpdated, targetUpdated time.Time) string {
+	switch p {
+	case MergePreferTarget:
+		return MergeSideTarget
+	case MergePreferSource:
+		return MergeSideSource
+	case MergePreferNewer:
+		if sourceUpdated.After(targetUpdated) {
+			return MergeSideSource
+		}
+		return MergeSideTarget
+	}
+	return ""
+}
+
+// ApplyMerge copies the fields the report takes from the source into the
+// target and retires the source, which can no longer log in or reactivate
+func ApplyMerge(source, target *User, report *MergeReport) {
+	now := time.Now()
+	for _, field := range mergeFields {
+		if report.TakesFromSource(field.name) {
+			field.take(target, source)
+		}
+	}
+	target.UpdatedAt = now
+
+	source.MergedInto = &target.ID
+	source.IsActive = false
+	source.DeactivatedAt = &now
+	source.UpdatedAt = now
+	report.Merged = true
+}
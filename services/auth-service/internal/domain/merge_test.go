@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mergeAccounts returns a source and a target differing in both names, the
+// target updated an hour before the source
+func mergeAccounts() (*User, *User) {
+	campus := "north"
+	target := &User{ID: uuid.New(), FirstName: "Ada", LastName: "Lovelace", CampusID: &campus, Role: RoleStudent, UpdatedAt: time.Now().Add(-time.Hour)}
+	source := &User{ID: uuid.New(), FirstName: "Augusta", LastName: "King", CampusID: &campus, Role: RoleStudent, UpdatedAt: time.Now()}
+	return source, target
+}
+
+// resolutions returns the side each conflict resolves to, by field
+func resolutions(report *MergeReport) string {
+	var sides []string
+	for _, conflict := range report.Conflicts {
+		sides = append(sides, conflict.Field+"="+conflict.Resolution)
+	}
+	return fmt.Sprint(sides)
+}
+
+func TestPlanMergePolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		req  MergeRequest
+		want string
+	}{
+		{"default keeps the target", MergeRequest{}, "[first_name=target last_name=target]"},
+		{"prefer target", MergeRequest{DefaultPolicy: MergePreferTarget}, "[first_name=target last_name=target]"},
+		{"prefer source", MergeRequest{DefaultPolicy: MergePreferSource}, "[first_name=source last_name=source]"},
+		{"prefer newer", MergeRequest{DefaultPolicy: MergePreferNewer}, "[first_name=source last_name=source]"},
+		{"field policy over default", MergeRequest{
+			DefaultPolicy: MergePreferSource,
+			Policies:      map[string]MergePolicy{"last_name": MergePreferTarget},
+		}, "[first_name=source last_name=target]"},
+		{"choice over policy", MergeRequest{
+			DefaultPolicy: MergePreferSource,
+			Choices:       map[string]string{"first_name": MergeSideTarget},
+		}, "[first_name=target last_name=source]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, target := mergeAccounts()
+			report := PlanMerge(source, target, tt.req)
+			if got := resolutions(report); got != tt.want {
+				t.Errorf("resolutions = %s, want %s", got, tt.want)
+			}
+			if !report.Resolved() {
+				t.Errorf("unresolved %v", report.Unresolved)
+			}
+		})
+	}
+}
+
+func TestPlanMergePreferNewerKeepsNewerTarget(t *testing.T) {
+	source, target := mergeAccounts()
+	target.UpdatedAt = source.UpdatedAt.Add(time.Minute)
+
+	report := PlanMerge(source, target, MergeRequest{DefaultPolicy: MergePreferNewer})
+	if got := resolutions(report); got != "[first_name=target last_name=target]" {
+		t.Errorf("resolutions = %s, want the newer target's", got)
+	}
+}
+
+func TestPlanMergeReportsUnresolvedConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		req  MergeRequest
+		want string
+	}{
+		{"manual policy", MergeRequest{DefaultPolicy: MergeManual}, "[first_name last_name]"},
+		{"manual field", MergeRequest{Policies: map[string]MergePolicy{"first_name": MergeManual}}, "[first_name]"},
+		{"explicit choices required", MergeRequest{
+			DefaultPolicy:   MergePreferSource,
+			Choices:         map[string]string{"last_name": MergeSideSource},
+			RequireExplicit: true,
+		}, "[first_name]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, target := mergeAccounts()
+			report := PlanMerge(source, target, tt.req)
+			if report.Resolved() {
+				t.Fatal("report resolved")
+			}
+			if got := fmt.Sprint(report.Unresolved); got != tt.want {
+				t.Errorf("unresolved = %s, want %s", got, tt.want)
+			}
+			for _, conflict := range report.Conflicts {
+				if conflict.SourceValue == nil || conflict.TargetValue == nil {
+					t.Errorf("conflict on %s reported without both values", conflict.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanMergeFillsFieldsOnlySourceHas(t *testing.T) {
+	source, target := mergeAccounts()
+	phone, avatar := "+15555550100", "https://cdn.example.edu/a.png"
+	source.Phone, source.PhoneVerified = &phone, true
+	source.AvatarURL = &avatar
+	target.FirstName, target.LastName = source.FirstName, source.LastName
+
+	report := PlanMerge(source, target, MergeRequest{RequireExplicit: true})
+	if !report.Resolved() || len(report.Conflicts) != 0 {
+		t.Fatalf("conflicts %v, unresolved %v; want none", report.Conflicts, report.Unresolved)
+	}
+	if got := fmt.Sprint(report.Filled); got != "[avatar_url phone]" {
+		t.Errorf("filled = %s, want [avatar_url phone]", got)
+	}
+
+	ApplyMerge(source, target, report)
+	if target.Phone == nil || *target.Phone != phone || !target.PhoneVerified {
+		t.Error("phone not moved to the target")
+	}
+	// the verified phone is unique, so the source gives it up
+	if source.Phone != nil || source.PhoneVerified {
+		t.Error("source kept the phone")
+	}
+	if target.AvatarURL == nil || *target.AvatarURL != avatar {
+		t.Error("avatar not copied to the target")
+	}
+}
+
+func TestApplyMergeRetiresSource(t *testing.T) {
+	source, target := mergeAccounts()
+	report := PlanMerge(source, target, MergeRequest{Choices: map[string]string{"first_name": MergeSideSource}})
+
+	ApplyMerge(source, target, report)
+	if target.FirstName != "Augusta" || target.LastName != "Lovelace" {
+		t.Errorf("target named %s %s, want Augusta Lovelace", target.FirstName, target.LastName)
+	}
+	if source.MergedInto == nil || *source.MergedInto != target.ID || source.IsActive {
+		t.Error("source not retired into the target")
+	}
+	if !report.Merged {
+		t.Error("report not marked merged")
+	}
+}
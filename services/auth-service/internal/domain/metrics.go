@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// SignupFunnelRow counts the users registered on one day, optionally on one
+// campus, and how far they got: verifying their email and logging in
+type SignupFunnelRow struct {
+	Day        string  `json:"day"`
+	CampusID   *string `json:"campus_id,omitempty"`
+	Registered int     `json:"registered"`
+	Verified   int     `json:"verified"`
+	FirstLogin int     `json:"first_login"`
+}
+
+// SignupFunnelQuery selects the registrations counted by the signup funnel
+type SignupFunnelQuery struct {
+	From     time.Time
+	To       time.Time
+	ByCampus bool
+}
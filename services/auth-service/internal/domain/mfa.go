@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAMethodType identifies a kind of second factor
+type MFAMethodType string
+
+// Second factor types. Only TOTP can be enrolled so far; the others are
+// reserved so stored methods and clients share one vocabulary.
+const (
+	MFATOTP     MFAMethodType = "totp"
+	MFAWebAuthn MFAMethodType = "webauthn"
+	MFASMS      MFAMethodType = "sms"
+)
+
+// MFAMethod is a second factor enrolled by a user. Secrets stay with the
+// factor's own storage; this record only describes the enrollment.
+type MFAMethod struct {
+	ID        uuid.UUID     `json:"id" db:"id"`
+	UserID    uuid.UUID     `json:"user_id" db:"user_id"`
+	Type      MFAMethodType `json:"type" db:"type"`
+	Label     string        `json:"label" db:"label"`
+	Preferred bool          `json:"preferred" db:"preferred"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// NewMFAMethod creates an enrolled second factor
+func NewMFAMethod(userID uuid.UUID, methodType MFAMethodType, label string, preferred bool) *MFAMethod {
+	return &MFAMethod{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      methodType,
+		Label:     label,
+		Preferred: preferred,
+		CreatedAt: time.Now(),
+	}
+}
+
+// MFAMethodRepository defines the interface for MFA method persistence
+type MFAMethodRepository interface {
+	Create(method *MFAMethod) error
+	GetByID(id uuid.UUID) (*MFAMethod, error)
+	// ListByUserID returns the user's methods, preferred first
+	ListByUserID(userID uuid.UUID) ([]*MFAMethod, error)
+	// SetPreferred marks one method as preferred and clears the flag on the others
+	SetPreferred(userID, id uuid.UUID) error
+	Delete(id uuid.UUID) error
+	DeleteByType(userID uuid.UUID, methodType MFAMethodType) error
+}
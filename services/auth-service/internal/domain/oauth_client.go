@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth grant types a client can be allowed to use
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// OAuthClient is an application registered to use the OAuth flows. Only
+// the hash of the client secret is stored.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	ClientID     string    `json:"client_id" db:"client_id"`
+	SecretHash   string    `json:"-" db:"secret_hash"`
+	Name         string    `json:"name" db:"name"`
+	RedirectURIs []string  `json:"redirect_uris" db:"redirect_uris"`
+	Scopes       []string  `json:"scopes" db:"scopes"`
+	GrantTypes   []string  `json:"grant_types" db:"grant_types"`
+	// AccessTokenFormat is the form of the access tokens the client receives
+	AccessTokenFormat TokenFormat `json:"access_token_format,omitempty" db:"access_token_format"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// OAuthClientRequest is the request body for registering or updating a client
+type OAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"max=20,dive,required,max=512"`
+	Scopes       []string `json:"scopes" validate:"max=50,dive,required,max=64"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,dive,oneof=authorization_code refresh_token client_credentials urn:ietf:params:oauth:grant-type:token-exchange"`
+	// AccessTokenFormat defaults to JWTs signed with the service's algorithm
+	AccessTokenFormat TokenFormat `json:"access_token_format,omitempty" validate:"omitempty,oneof=jwt_hs256 jwt_rs256 opaque"`
+}
+
+// RegisteredOAuthClient is returned when a client is registered or its
+// secret rotated; the plaintext secret is never shown again
+type RegisteredOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret"`
+}
+
+// NewOAuthClient creates a client with the given credentials
+func NewOAuthClient(clientID, secret string, req OAuthClientRequest) (*OAuthClient, error) {
+	now := time.Now()
+	client := &OAuthClient{
+		ID:         uuid.New(),
+		ClientID:   clientID,
+		SecretHash: HashToken(secret),
+		CreatedAt:  now,
+	}
+	if err := client.Apply(req); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Apply replaces the client's settings with the request
+func (c *OAuthClient) Apply(req OAuthClientRequest) error {
+	for _, uri := range req.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	if contains(req.GrantTypes, GrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		return ValidationError{Field: "redirect_uris", Message: "authorization_code clients need at least one redirect URI"}
+	}
+
+	c.Name = req.Name
+	c.RedirectURIs = append([]string{}, req.RedirectURIs...)
+	c.Scopes = append([]string{}, req.Scopes...)
+	c.GrantTypes = append([]string{}, req.GrantTypes...)
+	c.AccessTokenFormat = req.AccessTokenFormat
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// RotateSecret replaces the client secret
+func (c *OAuthClient) RotateSecret(secret string) {
+	c.SecretHash = HashToken(secret)
+	c.UpdatedAt = time.Now()
+}
+
+// AllowsRedirectURI reports whether uri exactly matches a registered redirect URI
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return contains(c.RedirectURIs, uri)
+}
+
+// AllowsGrant reports whether the client may use the grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// AllowsScopes reports whether every requested scope is allowed for the client
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRedirectURI accepts absolute http(s) URIs without a fragment, as
+// redirect URIs are compared verbatim and must not carry wildcards
+func validateRedirectURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" || strings.Contains(uri, "#") ||
+		(parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return ValidationError{Field: "redirect_uris", Message: "invalid redirect URI: " + uri}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthClientRepository defines the interface for OAuth client persistence
+type OAuthClientRepository interface {
+	Create(client *OAuthClient) error
+	GetByID(id uuid.UUID) (*OAuthClient, error)
+	GetByClientID(clientID string) (*OAuthClient, error)
+	List() ([]*OAuthClient, error)
+	Update(client *OAuthClient) error
+	Delete(id uuid.UUID) error
+}
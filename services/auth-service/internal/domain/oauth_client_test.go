@@ -0,0 +1,52 @@
+package domain
+
+import "testing"
+
+func TestValidateRedirectURI(t *testing.T) {
+	for uri, valid := range map[string]bool{
+		"https://app.example.edu/callback":       true,
+		"http://localhost:3000/callback":         true,
+		"https://app.example.edu/callback?app=1": true,
+		"/callback":                              false,
+		"app.example.edu/callback":               false,
+		"https:///callback":                      false,
+		"javascript:alert(1)":                    false,
+		"myapp://callback":                       false,
+		"https://app.example.edu/callback#top":   false,
+		"https://app.example.edu/callback#":      false,
+	} {
+		if err := validateRedirectURI(uri); (err == nil) != valid {
+			t.Errorf("validateRedirectURI(%q) = %v, want valid %v", uri, err, valid)
+		}
+	}
+}
+
+func TestAllowsRedirectURIMatchesExactly(t *testing.T) {
+	client := &OAuthClient{RedirectURIs: []string{"https://app.example.edu/callback"}}
+
+	if !client.AllowsRedirectURI("https://app.example.edu/callback") {
+		t.Error("registered redirect URI was refused")
+	}
+	for _, uri := range []string{
+		"https://app.example.edu/callback/",
+		"https://app.example.edu/callback/more",
+		"https://app.example.edu/callback?x=1",
+		"https://APP.example.edu/callback",
+		"https://app.example.edu",
+	} {
+		if client.AllowsRedirectURI(uri) {
+			t.Errorf("redirect URI %q was allowed", uri)
+		}
+	}
+}
+
+func TestAllowsScopes(t *testing.T) {
+	client := &OAuthClient{Scopes: []string{"openid", "profile"}}
+
+	if !client.AllowsScopes(nil) || !client.AllowsScopes([]string{"openid", "profile"}) {
+		t.Error("allowed scopes were refused")
+	}
+	if client.AllowsScopes([]string{"openid", "email"}) {
+		t.Error("a scope not registered was allowed")
+	}
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthIdentity links a user to their account at an external identity
+// provider, such as Google or a university SSO, so they can log in with it
+type OAuthIdentity struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	Provider string    `json:"provider" db:"provider"`
+	// Subject is the provider's stable ID of the account
+	Subject string `json:"subject" db:"subject"`
+	// Email is the address the provider reported when the identity was linked
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ExternalProfile is what an identity provider tells about the account that
+// logged in
+type ExternalProfile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// NewOAuthIdentity links the provider account of the profile to the user
+func NewOAuthIdentity(userID uuid.UUID, provider string, profile ExternalProfile) *OAuthIdentity {
+	return &OAuthIdentity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   profile.Subject,
+		Email:     profile.Email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewExternalUser creates a user signing up through an identity provider.
+// They get the random password given, which they can replace through a
+// password reset, and are verified when the provider vouches for the email.
+// campusID may be empty when the provider serves no single campus.
+func NewExternalUser(profile ExternalProfile, password, campusID string, peppers Peppers) (*User, error) {
+	if err := checkLength("email", profile.Email, MaxEmailLength); err != nil {
+		return nil, err
+	}
+	if err := checkLength("first_name", profile.FirstName, MaxNameLength); err != nil {
+		return nil, err
+	}
+	if err := checkLength("last_name", profile.LastName, MaxNameLength); err != nil {
+		return nil, err
+	}
+	if campusID != "" {
+		if err := CheckCampusID("campus_id", campusID); err != nil {
+			return nil, err
+		}
+	}
+
+	hashedPassword, err := hashPassword(password, peppers)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:               uuid.New(),
+		Email:            NormalizeEmail(profile.Email),
+		Password:         hashedPassword,
+		PasswordStrength: MeasurePassword(password),
+		PepperVersion:    peppers.Current,
+		FirstName:        profile.FirstName,
+		LastName:         profile.LastName,
+		Role:             RoleStudent,
+		IsActive:         true,
+		IsVerified:       profile.EmailVerified,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if campusID != "" {
+		user.CampusID = &campusID
+	}
+	return user, nil
+}
+
+// OAuthIdentityRepository defines the interface for external identity persistence
+type OAuthIdentityRepository interface {
+	Create(identity *OAuthIdentity) error
+	// GetBySubject returns the identity of the provider account, failing
+	// with sql.ErrNoRows when it is not linked to any user
+	GetBySubject(provider, subject string) (*OAuthIdentity, error)
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "strings"
+
+// ScopeOpenID is the scope that makes a login return an ID token
+const ScopeOpenID = "openid"
+
+// OIDCRequest carries the optional OpenID Connect parameters of a login
+type OIDCRequest struct {
+	ClientID string `json:"client_id,omitempty" validate:"required_with=Scope,max=64"`
+	// Scope is a space separated list of scopes, as in OAuth
+	Scope string `json:"scope,omitempty" validate:"max=512"`
+	// Nonce is copied into the ID token so the client can detect replays
+	Nonce string `json:"nonce,omitempty" validate:"max=255"`
+}
+
+// Scopes splits the requested scope into its parts
+func (r OIDCRequest) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// WantsIDToken reports whether the openid scope was requested
+func (r OIDCRequest) WantsIDToken() bool {
+	return contains(r.Scopes(), ScopeOpenID)
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it
+// announces, and published to the bus afterwards, so a committed change is
+// never left unannounced
+type OutboxEvent struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// DedupeKey is the ID of the event, which consumers dedupe deliveries by
+	DedupeKey string `json:"dedupe_key" db:"dedupe_key"`
+	EventType string `json:"event_type" db:"event_type"`
+	// Payload is the JSON encoded event envelope
+	Payload   []byte    `json:"payload" db:"payload"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Attempts counts the failed publishes; LastError is the latest failure
+	Attempts  int    `json:"attempts" db:"attempts"`
+	LastError string `json:"last_error,omitempty" db:"last_error"`
+	// LockedUntil is when a dispatcher's claim on the event lapses
+	LockedUntil  *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty" db:"dispatched_at"`
+}
+
+// NewOutboxEvent creates an undispatched outbox event
+func NewOutboxEvent(dedupeKey, eventType string, payload []byte) *OutboxEvent {
+	return &OutboxEvent{
+		ID:        uuid.New(),
+		DedupeKey: dedupeKey,
+		EventType: eventType,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+}
+
+// OutboxRepository defines the interface for outbox persistence
+type OutboxRepository interface {
+	// Create adds an event; one with the same dedupe key is kept as is
+	Create(event *OutboxEvent) error
+	// Claim locks up to limit undispatched events, oldest first, until the
+	// given time, skipping events another dispatcher holds
+	Claim(limit int, until time.Time) ([]*OutboxEvent, error)
+	MarkDispatched(id uuid.UUID, at time.Time) error
+	// RecordFailure counts a failed publish and releases the claim
+	RecordFailure(id uuid.UUID, reason string) error
+	// DeleteDispatchedBefore removes events dispatched before the cutoff,
+	// returning how many were removed
+	DeleteDispatchedBefore(cutoff time.Time) (int64, error)
+}
@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordStrength is what is kept about a password to re-check it against a
+// stricter rule later, e.g. when its owner is given a privileged role. Both
+// fields are zero for passwords set before strength was recorded.
+type PasswordStrength struct {
+	Length int `json:"-" db:"password_length"`
+	// Classes counts the character classes used: lowercase, uppercase, digits, symbols
+	Classes int `json:"-" db:"password_classes"`
+}
+
+// PasswordClasses are the character classes a password uses, or a policy requires
+type PasswordClasses struct {
+	Lower  bool `json:"lowercase"`
+	Upper  bool `json:"uppercase"`
+	Digit  bool `json:"digit"`
+	Symbol bool `json:"symbol"`
+}
+
+// classesOf returns the character classes used in password
+func classesOf(password string) PasswordClasses {
+	var classes PasswordClasses
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			classes.Lower = true
+		case unicode.IsUpper(r):
+			classes.Upper = true
+		case unicode.IsDigit(r):
+			classes.Digit = true
+		default:
+			classes.Symbol = true
+		}
+	}
+	return classes
+}
+
+// count returns the number of classes set
+func (c PasswordClasses) count() int {
+	count := 0
+	for _, used := range []bool{c.Lower, c.Upper, c.Digit, c.Symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// MeasurePassword returns the strength of a password
+func MeasurePassword(password string) PasswordStrength {
+	return PasswordStrength{Length: utf8.RuneCountInString(password), Classes: classesOf(password).count()}
+}
+
+// PasswordRule is the bar a password must clear
+type PasswordRule struct {
+	MinLength  int `json:"min_length"`
+	MinClasses int `json:"min_classes"`
+}
+
+// Allows reports whether a password of the given strength clears the rule
+func (r PasswordRule) Allows(strength PasswordStrength) bool {
+	return strength.Length >= r.MinLength && strength.Classes >= r.MinClasses
+}
+
+// PasswordPolicy is the default password rule plus stricter rules for
+// privileged roles, and the checks every password must pass whatever the role
+type PasswordPolicy struct {
+	Default PasswordRule
+	Roles   map[Role]PasswordRule
+	// MaxLength caps passwords in characters; zero leaves only the bcrypt
+	// limit of MaxPasswordLength bytes
+	MaxLength int
+	// Require are the classes every password must use
+	Require PasswordClasses
+	// RejectCommon refuses the passwords of the embedded common password list
+	RejectCommon bool
+}
+
+// RuleFor returns the rule passwords of users holding role must clear
+func (p PasswordPolicy) RuleFor(role Role) PasswordRule {
+	if rule, ok := p.Roles[role]; ok {
+		return rule
+	}
+	return p.Default
+}
+
+// Check validates a new password for a user holding role. personal are the
+// user's email and names, which the password may not contain. Every rule the
+// password fails is listed in the error message.
+func (p PasswordPolicy) Check(role Role, field, password string, personal ...string) error {
+	rule := p.RuleFor(role)
+	length := utf8.RuneCountInString(password)
+	classes := classesOf(password)
+
+	var failures []string
+	if length < rule.MinLength {
+		failures = append(failures, fmt.Sprintf("must be at least %d characters", rule.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		failures = append(failures, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+	if classes.count() < rule.MinClasses {
+		failures = append(failures, fmt.Sprintf("must mix %d of lowercase, uppercase, digits and symbols", rule.MinClasses))
+	}
+	for _, required := range []struct {
+		wanted, used bool
+		name         string
+	}{
+		{p.Require.Lower, classes.Lower, "a lowercase letter"},
+		{p.Require.Upper, classes.Upper, "an uppercase letter"},
+		{p.Require.Digit, classes.Digit, "a digit"},
+		{p.Require.Symbol, classes.Symbol, "a symbol"},
+	} {
+		if required.wanted && !required.used {
+			failures = append(failures, "must contain "+required.name)
+		}
+	}
+	if p.RejectCommon && isCommonPassword(password) {
+		failures = append(failures, "is too common")
+	}
+	if containsPersonalInfo(password, personal) {
+		failures = append(failures, "must not contain your email address or name")
+	}
+
+	if len(failures) > 0 {
+		return ValidationError{Field: field, Message: strings.Join(failures, "; ")}
+	}
+	return nil
+}
+
+// PasswordRequirements describes a password policy for clients to check
+// passwords against before submitting them
+type PasswordRequirements struct {
+	PasswordRule
+	MaxLength      int                   `json:"max_length"`
+	MaxBytes       int                   `json:"max_bytes"`
+	Require        PasswordClasses       `json:"require"`
+	RejectCommon   bool                  `json:"reject_common"`
+	RejectPersonal bool                  `json:"reject_personal_info"`
+	RoleRules      map[Role]PasswordRule `json:"role_rules,omitempty"`
+}
+
+// Requirements describes the policy; the embedded rule is the default one
+func (p PasswordPolicy) Requirements() PasswordRequirements {
+	return PasswordRequirements{
+		PasswordRule:   p.Default,
+		MaxLength:      p.MaxLength,
+		MaxBytes:       MaxPasswordLength,
+		Require:        p.Require,
+		RejectCommon:   p.RejectCommon,
+		RejectPersonal: true,
+		RoleRules:      p.Roles,
+	}
+}
+
+// minPersonalLength is the shortest name or email part a password is checked
+// for, so that short names do not rule out unrelated passwords
+const minPersonalLength = 3
+
+// containsPersonalInfo reports whether password contains, ignoring case, one
+// of the values or the local part of one of them when it is an email address
+func containsPersonalInfo(password string, values []string) bool {
+	password = strings.ToLower(password)
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= minPersonalLength && strings.Contains(password, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireStrongerPassword forces a password change when the user's current
+// password does not clear the rule of their role, e.g. after an elevation.
+// It reports whether a change was required.
+func (u *User) RequireStrongerPassword(policy PasswordPolicy) bool {
+	if policy.RuleFor(u.Role).Allows(u.PasswordStrength) {
+		return false
+	}
+	u.RequirePasswordChange()
+	return true
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// privilegedPolicy asks students for 8 characters of any kind and admins for
+// 14 characters mixing 3 classes
+func privilegedPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		Default: PasswordRule{MinLength: 8, MinClasses: 1},
+		Roles:   map[Role]PasswordRule{RoleAdmin: {MinLength: 14, MinClasses: 3}},
+	}
+}
+
+func TestMeasurePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     PasswordStrength
+	}{
+		{"password", PasswordStrength{Length: 8, Classes: 1}},
+		{"Password1", PasswordStrength{Length: 9, Classes: 3}},
+		{"Pass word1!", PasswordStrength{Length: 11, Classes: 4}},
+		{"pässwörd", PasswordStrength{Length: 8, Classes: 1}},
+	}
+	for _, tt := range tests {
+		if got := MeasurePassword(tt.password); got != tt.want {
+			t.Errorf("MeasurePassword(%q) = %+v, want %+v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyChecksRoleRule(t *testing.T) {
+	policy := privilegedPolicy()
+	tests := []struct {
+		name     string
+		role     Role
+		password string
+		valid    bool
+	}{
+		{"student with a plain password", RoleStudent, "plainpassword", true},
+		{"student with a short password", RoleStudent, "short", false},
+		{"moderator falls back to the default", RoleModerator, "plainpassword", true},
+		{"admin with a plain password", RoleAdmin, "plainpassword", false},
+		{"admin with a short mixed password", RoleAdmin, "Short-pass1", false},
+		{"admin with a long mixed password", RoleAdmin, "Correct-horse-42", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.role, "password", tt.password)
+			if tt.valid && err != nil {
+				t.Errorf("Check = %v, want valid", err)
+			}
+			var validation ValidationError
+			if !tt.valid && !errors.As(err, &validation) {
+				t.Errorf("Check = %v, want a ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyListsEveryFailure(t *testing.T) {
+	err := privilegedPolicy().Check(RoleAdmin, "password", "ada", "ada@example.edu")
+	for _, want := range []string{"at least 14 characters", "mix 3", "email address or name"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Check = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestRequireStrongerPasswordOnElevation(t *testing.T) {
+	tests := []struct {
+		name     string
+		strength PasswordStrength
+		role     Role
+		want     bool
+	}{
+		{"weak password elevated to admin", MeasurePassword("plainpassword"), RoleAdmin, true},
+		{"strong password elevated to admin", MeasurePassword("Correct-horse-42"), RoleAdmin, false},
+		{"weak password elevated to moderator", MeasurePassword("plainpassword"), RoleModerator, false},
+		// passwords set before strength was recorded count as too weak
+		{"unmeasured password elevated to admin", PasswordStrength{}, RoleAdmin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Role: tt.role, PasswordStrength: tt.strength}
+			if got := user.RequireStrongerPassword(privilegedPolicy()); got != tt.want {
+				t.Errorf("RequireStrongerPassword = %v, want %v", got, tt.want)
+			}
+			if user.MustChangePassword != tt.want {
+				t.Errorf("MustChangePassword = %v, want %v", user.MustChangePassword, tt.want)
+			}
+		})
+	}
+}
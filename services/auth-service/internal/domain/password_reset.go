@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordReset represents a single-use password reset token. Only the hash
+// of the token is stored; the plaintext is handed to the user once.
+type PasswordReset struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PasswordResetRequest represents a forgotten password report
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// PasswordResetConfirm represents the data needed to set a new password
+type PasswordResetConfirm struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,max=72"`
+}
+
+// NewPasswordReset creates a reset for the user from the plaintext token
+func NewPasswordReset(userID uuid.UUID, token string, expiresAt time.Time) *PasswordReset {
+	return &PasswordReset{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsExpired checks if the reset token is expired
+func (r *PasswordReset) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+// IsUsed checks if the reset token was already consumed
+func (r *PasswordReset) IsUsed() bool {
+	return r.UsedAt != nil
+}
+
+// IsValid checks if the reset token can still be used
+func (r *PasswordReset) IsValid() bool {
+	return !r.IsUsed() && !r.IsExpired()
+}
+
+// RemainingLifetime returns how long the token stays valid, zero when it no longer is
+func (r *PasswordReset) RemainingLifetime() time.Duration {
+	if !r.IsValid() {
+		return 0
+	}
+	return time.Until(r.ExpiresAt)
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PasswordResetRepository defines the interface for password reset persistence
+type PasswordResetRepository interface {
+	Create(reset *PasswordReset) error
+	GetByTokenHash(tokenHash string) (*PasswordReset, error)
+	// Consume marks the reset used, setting its UsedAt, unless it already
+	// was or has expired, in which case it returns sql.ErrNoRows. Of
+	// concurrent calls for one reset, only one succeeds.
+	Consume(reset *PasswordReset) error
+}
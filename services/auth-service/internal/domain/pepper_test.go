@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newPepperedUser(t *testing.T, password string, peppers Peppers) *User {
+	t.Helper()
+	user, err := NewUser(UserRegistration{Email: "ada@example.edu", Password: password, FirstName: "Ada", LastName: "Lovelace", CampusID: "main-campus"}, peppers)
+	if err != nil {
+		t.Fatalf("NewUser: %v", err)
+	}
+	return user
+}
+
+func TestPepperApplied(t *testing.T) {
+	peppers := Peppers{Current: 1, Secrets: map[int][]byte{1: []byte("first-secret")}}
+
+	plain := newPepperedUser(t, "password-123", Peppers{})
+	if plain.PepperVersion != 0 || bcrypt.CompareHashAndPassword([]byte(plain.Password), []byte("password-123")) != nil {
+		t.Error("without a pepper the hash is not plain bcrypt of the password")
+	}
+
+	user := newPepperedUser(t, "password-123", peppers)
+	if user.PepperVersion != 1 {
+		t.Errorf("pepper version = %d, want 1", user.PepperVersion)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password-123")) == nil {
+		t.Error("peppered hash matches the bare password")
+	}
+	if !user.CheckPassword("password-123", peppers) || user.CheckPassword("password-124", peppers) {
+		t.Error("CheckPassword does not verify the peppered hash")
+	}
+	if user.CheckPassword("password-123", Peppers{Current: 1, Secrets: map[int][]byte{1: []byte("other-secret")}}) {
+		t.Error("peppered hash verified with another secret")
+	}
+	if user.CheckPassword("password-123", Peppers{}) {
+		t.Error("hash of a pepper no longer configured verified")
+	}
+}
+
+func TestPepperMigration(t *testing.T) {
+	v1 := Peppers{Current: 1, Secrets: map[int][]byte{1: []byte("first-secret")}}
+	v2 := Peppers{Current: 2, Secrets: map[int][]byte{1: []byte("first-secret"), 2: []byte("second-secret")}}
+
+	user := newPepperedUser(t, "password-123", Peppers{})
+	for _, step := range []struct {
+		name    string
+		peppers Peppers
+		from    int
+	}{
+		{"enabled", v1, 0},
+		{"rotated", v2, 1},
+	} {
+		// the old hash keeps verifying until the user is rehashed
+		if !user.CheckPassword("password-123", step.peppers) || !user.NeedsRehash(step.peppers) {
+			t.Fatalf("%s: hash of version %d not verified or not due a rehash", step.name, step.from)
+		}
+		if err := user.RehashPassword("password-123", step.peppers); err != nil {
+			t.Fatalf("%s: RehashPassword: %v", step.name, err)
+		}
+		if user.PepperVersion != step.peppers.Current || user.NeedsRehash(step.peppers) {
+			t.Errorf("%s: pepper version = %d, want %d", step.name, user.PepperVersion, step.peppers.Current)
+		}
+		if !user.CheckPassword("password-123", step.peppers) {
+			t.Errorf("%s: rehashed password does not verify", step.name)
+		}
+	}
+
+	// once migrated, the older secrets can be dropped
+	if !user.CheckPassword("password-123", Peppers{Current: 2, Secrets: map[int][]byte{2: []byte("second-secret")}}) {
+		t.Error("hash depends on a retired pepper")
+	}
+	if err := user.SetPassword("password-456", v1); err != nil || user.PepperVersion != 1 {
+		t.Errorf("SetPassword = %v, version %d; want the current pepper", err, user.PepperVersion)
+	}
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPinAttempts is how many wrong PINs a session tolerates; after that the
+// PIN no longer unlocks it and the user must log in with their password
+const MaxPinAttempts = 5
+
+// PinSetup represents a request to set the account PIN
+type PinSetup struct {
+	Pin             string `json:"pin" validate:"required,numeric,min=4,max=8"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// PinUnlock represents a quick re-authentication of a session with the PIN
+type PinUnlock struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	Pin          string `json:"pin" validate:"required,numeric,min=4,max=8"`
+}
+
+// HasPin checks if the user has set a PIN
+func (u *User) HasPin() bool {
+	return u.PinHash != ""
+}
+
+// SetPin replaces the PIN, hashed like passwords with the current pepper
+func (u *User) SetPin(pin string, peppers Peppers) error {
+	hashedPin, err := hashPassword(pin, peppers)
+	if err != nil {
+		return err
+	}
+	u.PinHash = hashedPin
+	u.PinPepperVersion = peppers.Current
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// CheckPin compares pin with the stored PIN hash
+func (u *User) CheckPin(pin string, peppers Peppers) bool {
+	if !u.HasPin() {
+		return false
+	}
+	peppered, err := peppers.apply(u.PinPepperVersion, pin)
+	if err != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PinHash), []byte(peppered)) == nil
+}
+
+// RemovePin turns PIN unlock off
+func (u *User) RemovePin() {
+	u.PinHash = ""
+	u.PinPepperVersion = 0
+	u.UpdatedAt = time.Now()
+}
+
+// PinLocked checks if the session has used up its PIN attempts
+func (s *Session) PinLocked() bool {
+	return s.PinFailures >= MaxPinAttempts
+}
+
+// FailPin records a wrong PIN and reports whether the session is now locked
+func (s *Session) FailPin() bool {
+	s.PinFailures++
+	return s.PinLocked()
+}
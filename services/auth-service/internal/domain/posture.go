@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthMethod is how the user proved their identity when a session was opened
+type AuthMethod string
+
+// Authentication methods
+const (
+	AuthPassword AuthMethod = "password"
+	// AuthDeviceLogin is a kiosk login approved from another logged-in device
+	AuthDeviceLogin AuthMethod = "device_login"
+	// AuthOAuth is a login through an external identity provider
+	AuthOAuth AuthMethod = "oauth"
+)
+
+// SessionAuth records how a session was authenticated
+type SessionAuth struct {
+	Method AuthMethod `json:"auth_method" db:"auth_method"`
+	// MFAUsed is set when a second factor was verified at login
+	MFAUsed bool `json:"mfa_used" db:"mfa_used"`
+	// TrustedDevice is set when a trusted device token stood in for the second factor
+	TrustedDevice bool `json:"trusted_device" db:"trusted_device"`
+}
+
+// SessionPosture is the security context of the caller's session, shown by
+// clients to warn about weak logins and to drive step-up prompts
+type SessionPosture struct {
+	SessionID uuid.UUID `json:"session_id"`
+	SessionAuth
+	// Scopes are the scopes the session holds, after any narrowing at login
+	Scopes SessionScopes `json:"scopes"`
+	// TwoFactorEnabled tells whether the user could have used a second factor
+	TwoFactorEnabled   bool        `json:"two_factor_enabled"`
+	CreatedAt          time.Time   `json:"created_at"`
+	AgeSeconds         int64       `json:"age_seconds"`
+	RiskScore          int         `json:"risk_score"`
+	RiskSignals        RiskSignals `json:"risk_signals"`
+	MustChangePassword bool        `json:"must_change_password"`
+}
+
+// Posture describes the session's security context for its owner
+func (s *Session) Posture(user *User) *SessionPosture {
+	return &SessionPosture{
+		SessionID:          s.ID,
+		SessionAuth:        s.SessionAuth,
+		Scopes:             s.Scopes.Effective(user.Role),
+		TwoFactorEnabled:   user.TwoFactorEnabled,
+		CreatedAt:          s.CreatedAt,
+		AgeSeconds:         int64(time.Since(s.CreatedAt).Seconds()),
+		RiskScore:          s.RiskScore,
+		RiskSignals:        s.RiskSignals,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+)
+
+// SetRefreshToken stores the hash of token as the session's refresh token.
+// Only hashes are stored, so a leaked sessions table holds no usable token.
+func (s *Session) SetRefreshToken(token string) {
+	s.RefreshTokenHash = HashToken(token)
+	s.refreshToken = token
+}
+
+// RefreshToken returns the plaintext refresh token, which only the session
+// that issued or rotated it holds; sessions loaded from storage return ""
+func (s *Session) RefreshToken() string {
+	return s.refreshToken
+}
+
+// RotateRefreshToken replaces the presented refresh token with one derived
+// from it and nonce, remembering the hash of the old one. Deriving the token
+// lets RecoverRefreshToken hand it again to a client racing the rotation,
+// which the stored hash alone could not.
+func (s *Session) RotateRefreshToken(presented, nonce string) {
+	now := time.Now()
+	s.PreviousRefreshTokenHash = HashToken(presented)
+	s.RotationNonce = nonce
+	s.SetRefreshToken(deriveRefreshToken(presented, nonce))
+	s.RotatedAt = &now
+	s.LastUsedAt = now
+}
+
+// RecoverRefreshToken derives the current refresh token again from the
+// previous one, reporting false when previous did not produce it. Sessions
+// rotated before tokens were derived cannot recover theirs.
+func (s *Session) RecoverRefreshToken(previous string) bool {
+	if s.RotationNonce == "" || HashToken(previous) != s.PreviousRefreshTokenHash {
+		return false
+	}
+	token := deriveRefreshToken(previous, s.RotationNonce)
+	if HashToken(token) != s.RefreshTokenHash {
+		return false
+	}
+	s.refreshToken = token
+	return true
+}
+
+// deriveRefreshToken returns the URL-safe HMAC-SHA256 of nonce keyed with the
+// previous token; without the previous token it cannot be recomputed
+func deriveRefreshToken(previous, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(previous))
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobStatus is the state of a background job
+type JobStatus string
+
+// Job states
+const (
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+// ReverificationRequest starts a bulk email re-verification. Requests with an
+// idempotency key already used return the existing job instead of a new one.
+type ReverificationRequest struct {
+	CampusID       *string `json:"campus_id" validate:"omitempty,max=64"`
+	IdempotencyKey string  `json:"idempotency_key" validate:"omitempty,max=64"`
+}
+
+// ReverificationJob re-sends verification emails to every unverified user
+// matching its filter. Users are processed in ID order and Cursor records the
+// last one handled, so an interrupted job resumes where it stopped.
+type ReverificationJob struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty" db:"idempotency_key"`
+	CampusID       *string    `json:"campus_id,omitempty" db:"campus_id"`
+	Status         JobStatus  `json:"status" db:"status"`
+	Cursor         uuid.UUID  `json:"-" db:"cursor"`
+	Processed      int        `json:"processed" db:"processed"`
+	Sent           int        `json:"sent" db:"sent"`
+	LastError      string     `json:"last_error,omitempty" db:"last_error"`
+	CreatedBy      uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// NewReverificationJob creates a running job for the request
+func NewReverificationJob(req ReverificationRequest, createdBy uuid.UUID) *ReverificationJob {
+	now := time.Now()
+	return &ReverificationJob{
+		ID:             uuid.New(),
+		IdempotencyKey: req.IdempotencyKey,
+		CampusID:       req.CampusID,
+		Status:         JobRunning,
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Advance records a processed user
+func (j *ReverificationJob) Advance(userID uuid.UUID, sent bool) {
+	j.Cursor = userID
+	j.Processed++
+	if sent {
+		j.Sent++
+	}
+	j.UpdatedAt = time.Now()
+}
+
+// Complete marks the job as finished
+func (j *ReverificationJob) Complete() {
+	now := time.Now()
+	j.Status = JobCompleted
+	j.CompletedAt = &now
+	j.UpdatedAt = now
+}
+
+// Fail marks the job as failed; it can be resumed from its cursor
+func (j *ReverificationJob) Fail(err error) {
+	j.Status = JobFailed
+	j.LastError = truncate(err.Error(), 512)
+	j.UpdatedAt = time.Now()
+}
+
+// ReverificationJobRepository defines the interface for re-verification job persistence
+type ReverificationJobRepository interface {
+	Create(job *ReverificationJob) error
+	GetByID(id uuid.UUID) (*ReverificationJob, error)
+	GetByIdempotencyKey(key string) (*ReverificationJob, error)
+	ListRunning() ([]*ReverificationJob, error)
+	Update(job *ReverificationJob) error
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationCutoff revokes every session created before CutoffAt once that
+// time is reached. A nil UserID applies the cutoff to all users. Cutoffs can
+// be scheduled ahead so clients get a chance to log in again in time.
+type RevocationCutoff struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	CutoffAt  time.Time  `json:"cutoff_at" db:"cutoff_at"`
+	CreatedBy uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RevocationRequest schedules a revocation cutoff; without a user ID it applies to everyone
+type RevocationRequest struct {
+	UserID   *uuid.UUID `json:"user_id"`
+	CutoffAt time.Time  `json:"cutoff_at" validate:"required"`
+}
+
+// TokenRevocationRequest identifies one refresh token to revoke, by value or
+// by its SHA-256 hex digest when the value itself should not be sent around
+type TokenRevocationRequest struct {
+	RefreshToken     string `json:"refresh_token" validate:"required_without=RefreshTokenHash,max=512"`
+	RefreshTokenHash string `json:"refresh_token_hash" validate:"omitempty,len=64,hexadecimal"`
+}
+
+// DeviceRevocationRequest names a device whose sessions are all revoked
+type DeviceRevocationRequest struct {
+	DeviceID string `json:"device_id" validate:"required,max=128"`
+}
+
+// NewRevocationCutoff creates a revocation cutoff
+func NewRevocationCutoff(userID *uuid.UUID, cutoffAt time.Time, createdBy uuid.UUID) *RevocationCutoff {
+	return &RevocationCutoff{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CutoffAt:  cutoffAt,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+// RevocationTime returns when the session is revoked by the given cutoffs:
+// the earliest cutoff that postdates the session's creation, or nil
+func (s *Session) RevocationTime(cutoffs []*RevocationCutoff) *time.Time {
+	var earliest *time.Time
+	for _, cutoff := range cutoffs {
+		if !cutoff.CutoffAt.After(s.CreatedAt) {
+			continue
+		}
+		if earliest == nil || cutoff.CutoffAt.Before(*earliest) {
+			at := cutoff.CutoffAt
+			earliest = &at
+		}
+	}
+	return earliest
+}
+
+// SessionStatus describes the caller's current session
+type SessionStatus struct {
+	SessionID uuid.UUID `json:"session_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RiskScore int       `json:"risk_score"`
+	// RevocationScheduledAt is set when a cutoff will revoke the session in the future
+	RevocationScheduledAt *time.Time `json:"revocation_scheduled_at,omitempty"`
+}
+
+// RevocationCutoffRepository defines the interface for revocation cutoff persistence
+type RevocationCutoffRepository interface {
+	Create(cutoff *RevocationCutoff) error
+	// ListForUser returns the global cutoffs and those of the given user
+	ListForUser(userID uuid.UUID) ([]*RevocationCutoff, error)
+}
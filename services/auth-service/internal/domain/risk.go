@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Risk signal weights; a session's score is the sum of its raised signals, capped at MaxRiskScore
+const (
+	RiskWeightNewDevice          = 30
+	RiskWeightAnonymizingNetwork = 40
+	RiskWeightImpossibleTravel   = 50
+
+	MaxRiskScore      = 100
+	HighRiskThreshold = 70
+)
+
+// RiskSignals are the observations a session's risk score was computed from
+type RiskSignals struct {
+	NewDevice          bool `json:"new_device"`
+	AnonymizingNetwork bool `json:"anonymizing_network"`
+	ImpossibleTravel   bool `json:"impossible_travel"`
+}
+
+// Score computes the risk score of the signals
+func (s RiskSignals) Score() int {
+	score := 0
+	if s.NewDevice {
+		score += RiskWeightNewDevice
+	}
+	if s.AnonymizingNetwork {
+		score += RiskWeightAnonymizingNetwork
+	}
+	if s.ImpossibleTravel {
+		score += RiskWeightImpossibleTravel
+	}
+	if score > MaxRiskScore {
+		score = MaxRiskScore
+	}
+	return score
+}
+
+// Value stores the signals as JSONB
+func (s RiskSignals) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan reads the signals from a JSONB column
+func (s *RiskSignals) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = RiskSignals{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported risk signals type %T", src)
+	}
+}
+
+// GeoLocation is where an IP address is, as precisely as it could be resolved
+type GeoLocation struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// LoginOrigin is the address and device a user logged in from
+type LoginOrigin struct {
+	IPAddress string
+	UserAgent string
+	At        time.Time
+}
+
+// SuspiciousLogin describes what is unfamiliar about a login: a device, a
+// location, or both. Location is nil when the address could not be located.
+type SuspiciousLogin struct {
+	NewDevice   bool
+	NewLocation bool
+	Location    *GeoLocation
+}
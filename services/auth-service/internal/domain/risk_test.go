@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestRiskSignalsScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals RiskSignals
+		want    int
+	}{
+		{"none", RiskSignals{}, 0},
+		{"new device", RiskSignals{NewDevice: true}, RiskWeightNewDevice},
+		{"anonymizing network", RiskSignals{AnonymizingNetwork: true}, RiskWeightAnonymizingNetwork},
+		{"impossible travel", RiskSignals{ImpossibleTravel: true}, RiskWeightImpossibleTravel},
+		{"new device on a VPN", RiskSignals{NewDevice: true, AnonymizingNetwork: true}, RiskWeightNewDevice + RiskWeightAnonymizingNetwork},
+		{"all, capped", RiskSignals{NewDevice: true, AnonymizingNetwork: true, ImpossibleTravel: true}, MaxRiskScore},
+	}
+	for _, tt := range tests {
+		if got := tt.signals.Score(); got != tt.want {
+			t.Errorf("%s: score = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSessionAssessRisk(t *testing.T) {
+	session := &Session{}
+	session.AssessRisk(RiskSignals{NewDevice: true})
+	if session.RiskScore != RiskWeightNewDevice || session.IsHighRisk() {
+		t.Errorf("new device: score %d, high risk %v", session.RiskScore, session.IsHighRisk())
+	}
+
+	session.AssessRisk(RiskSignals{NewDevice: true, AnonymizingNetwork: true})
+	if !session.IsHighRisk() {
+		t.Errorf("score %d is not high risk", session.RiskScore)
+	}
+	if !session.RiskSignals.AnonymizingNetwork {
+		t.Error("signals were not recorded")
+	}
+}
+
+func TestRiskSignalsRoundTrip(t *testing.T) {
+	signals := RiskSignals{NewDevice: true, ImpossibleTravel: true}
+	value, err := signals.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+
+	for _, src := range []interface{}{value, string(value.([]byte))} {
+		var scanned RiskSignals
+		if err := scanned.Scan(src); err != nil {
+			t.Fatalf("Scan(%T): %v", src, err)
+		}
+		if scanned != signals {
+			t.Errorf("Scan(%T) = %+v, want %+v", src, scanned, signals)
+		}
+	}
+
+	scanned := RiskSignals{NewDevice: true}
+	if err := scanned.Scan(nil); err != nil || scanned != (RiskSignals{}) {
+		t.Errorf("Scan(nil) = %+v, %v", scanned, err)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("Scan accepted an int")
+	}
+}
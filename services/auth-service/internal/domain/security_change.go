@@ -0,0 +1,41 @@
+package domain
+
+// SecurityChange is a change to how an account signs in or is recovered,
+// which the user is alerted about in case it was not theirs
+type SecurityChange string
+
+// Security changes the user is alerted about
+const (
+	SecurityPasswordChanged      SecurityChange = "password_changed"
+	SecurityPasswordReset        SecurityChange = "password_reset"
+	SecurityEmailChanged         SecurityChange = "email_changed"
+	SecurityPhoneChanged         SecurityChange = "phone_changed"
+	SecurityTwoFactorEnabled     SecurityChange = "two_factor_enabled"
+	SecurityTwoFactorDisabled    SecurityChange = "two_factor_disabled"
+	SecurityMFAMethodRemoved     SecurityChange = "mfa_method_removed"
+	SecurityRecoveryEmailChanged SecurityChange = "recovery_email_changed"
+)
+
+// Critical reports whether the alert must be sent whatever the user's
+// notification preferences: changes that replace a credential or a way to
+// recover the account, or weaken its second factor
+func (c SecurityChange) Critical() bool {
+	switch c {
+	case SecurityPhoneChanged, SecurityTwoFactorEnabled:
+		return false
+	}
+	return true
+}
+
+// VerifiedAddresses returns the emails the user proved to control, which
+// security alerts are sent to
+func (u *User) VerifiedAddresses() []string {
+	var addresses []string
+	if u.IsVerified {
+		addresses = append(addresses, u.Email)
+	}
+	if u.RecoveryEmail != nil && u.RecoveryEmailVerified {
+		addresses = append(addresses, *u.RecoveryEmail)
+	}
+	return addresses
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// SessionScope is a capability the tokens of a session may exercise
+type SessionScope string
+
+// Session scopes. A login may narrow its session to a subset of the scopes
+// of the user's role, e.g. read only on a shared computer.
+const (
+	// ScopeRead covers reading the account's own data
+	ScopeRead SessionScope = "read"
+	// ScopeWrite covers every change to the account
+	ScopeWrite SessionScope = "write"
+	// ScopeAdmin covers the administration routes; admins and moderators
+	// hold it
+	ScopeAdmin SessionScope = "admin"
+	// ScopeDelegated is the only scope of tokens issued for a delegation.
+	// Holding neither read nor write, they reach the delegated routes only.
+	ScopeDelegated SessionScope = "delegated"
+)
+
+// SessionScopeRequest carries the optional scope narrowing of a login
+type SessionScopeRequest struct {
+	// SessionScope is a space separated subset of the scopes of the user's
+	// role; empty keeps all of them
+	SessionScope string `json:"session_scope,omitempty" validate:"max=64"`
+}
+
+// SessionScopes is the set of capabilities of a session. Nil means every
+// scope of the user's role, as for sessions opened without narrowing.
+type SessionScopes []SessionScope
+
+// RoleScopes returns every scope the role may hold
+func RoleScopes(role Role) SessionScopes {
+	if role == RoleAdmin || role == RoleModerator {
+		return SessionScopes{ScopeRead, ScopeWrite, ScopeAdmin}
+	}
+	return SessionScopes{ScopeRead, ScopeWrite}
+}
+
+// Narrow returns the requested scopes, or nil when none were requested. A
+// session can only be narrowed: scopes beyond the role's are rejected.
+func (r SessionScopeRequest) Narrow(role Role) (SessionScopes, error) {
+	requested := ParseSessionScopes(r.SessionScope)
+	if requested == nil {
+		return nil, nil
+	}
+
+	allowed := RoleScopes(role)
+	for _, scope := range requested {
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+		default:
+			return nil, ValidationError{Field: "session_scope", Message: fmt.Sprintf("unknown scope %q", scope)}
+		}
+		if !allowed.Allows(scope) {
+			return nil, ValidationError{Field: "session_scope", Message: fmt.Sprintf("scope %q exceeds the permissions of the account", scope)}
+		}
+	}
+	return requested, nil
+}
+
+// ParseSessionScopes splits a space separated scope list, dropping duplicates;
+// an empty list gives nil
+func ParseSessionScopes(value string) SessionScopes {
+	var scopes SessionScopes
+	for _, field := range strings.Fields(value) {
+		if scope := SessionScope(field); !scopes.includes(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Allows reports whether the session may exercise scope
+func (s SessionScopes) Allows(scope SessionScope) bool {
+	return s == nil || s.includes(scope)
+}
+
+// Effective returns the scopes the session holds for a user of role
+func (s SessionScopes) Effective(role Role) SessionScopes {
+	if s == nil {
+		return RoleScopes(role)
+	}
+	return s
+}
+
+// String joins the scopes with spaces, as in the scope claim of access tokens
+func (s SessionScopes) String() string {
+	parts := make([]string, len(s))
+	for i, scope := range s {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s SessionScopes) includes(scope SessionScope) bool {
+	for _, held := range s {
+		if held == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the scopes as a space separated list, or NULL when unrestricted
+func (s SessionScopes) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.String(), nil
+}
+
+// Scan reads the scopes from a nullable text column
+func (s *SessionScopes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		*s = ParseSessionScopes(string(v))
+		return nil
+	case string:
+		*s = ParseSessionScopes(v)
+		return nil
+	default:
+		return fmt.Errorf("unsupported session scopes type %T", src)
+	}
+}
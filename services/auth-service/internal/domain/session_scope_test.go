@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNarrowSessionScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    Role
+		request string
+		want    string
+		valid   bool
+	}{
+		{"no narrowing", RoleStudent, "", "", true},
+		{"read only", RoleStudent, "read", "read", true},
+		{"duplicates dropped", RoleStudent, "read  read write", "read write", true},
+		{"admin without the admin scope", RoleAdmin, "read write", "read write", true},
+		{"moderator read only admin", RoleModerator, "read admin", "read admin", true},
+		{"student asking for admin", RoleStudent, "read admin", "", false},
+		{"unknown scope", RoleAdmin, "read everything", "", false},
+		// delegation tokens are issued by the service, never asked for at login
+		{"delegated", RoleStudent, "delegated", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes, err := SessionScopeRequest{SessionScope: tt.request}.Narrow(tt.role)
+			if !tt.valid {
+				var validation ValidationError
+				if !errors.As(err, &validation) || validation.Field != "session_scope" {
+					t.Errorf("Narrow = %v, %v; want a ValidationError on session_scope", scopes, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Narrow: %v", err)
+			}
+			if tt.request == "" && scopes != nil {
+				t.Errorf("Narrow without a request = %v, want nil", scopes)
+			}
+			if scopes.String() != tt.want {
+				t.Errorf("Narrow = %q, want %q", scopes, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionScopesAllows(t *testing.T) {
+	var unrestricted SessionScopes
+	if !unrestricted.Allows(ScopeWrite) || !unrestricted.Allows(ScopeAdmin) {
+		t.Error("an unnarrowed session was denied a scope")
+	}
+	if got := unrestricted.Effective(RoleStudent).String(); got != "read write" {
+		t.Errorf("effective scopes of a student = %q, want read write", got)
+	}
+
+	readOnly := SessionScopes{ScopeRead}
+	if !readOnly.Allows(ScopeRead) || readOnly.Allows(ScopeWrite) || readOnly.Allows(ScopeAdmin) {
+		t.Errorf("read only session allows write %v, admin %v", readOnly.Allows(ScopeWrite), readOnly.Allows(ScopeAdmin))
+	}
+	if got := readOnly.Effective(RoleAdmin).String(); got != "read" {
+		t.Errorf("effective scopes of a narrowed admin session = %q, want read", got)
+	}
+}
+
+func TestSessionScopesColumn(t *testing.T) {
+	var unrestricted SessionScopes
+	if value, err := unrestricted.Value(); value != nil || err != nil {
+		t.Errorf("Value of nil scopes = %v, %v; want NULL", value, err)
+	}
+
+	narrowed := SessionScopes{ScopeRead, ScopeAdmin}
+	value, err := narrowed.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	var scanned SessionScopes
+	if err := scanned.Scan([]byte(value.(string))); err != nil || scanned.String() != "read admin" {
+		t.Errorf("Scan = %q, %v; want read admin", scanned, err)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned != nil {
+		t.Errorf("Scan(NULL) = %v, %v; want nil", scanned, err)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("Scan accepted an integer")
+	}
+}
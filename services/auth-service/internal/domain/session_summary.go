@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionSummary describes one of the user's active sessions, so they can
+// recognise their devices and log the others out
+type SessionSummary struct {
+	ID         uuid.UUID `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was made from
+	Current bool `json:"current"`
+}
+
+// Summary describes the session to its owner; currentID is the session the
+// owner is looking from
+func (s *Session) Summary(currentID uuid.UUID) SessionSummary {
+	return SessionSummary{
+		ID:         s.ID,
+		IPAddress:  s.IPAddress,
+		UserAgent:  s.UserAgent,
+		DeviceID:   s.DeviceID,
+		ClientID:   s.ClientID,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentID,
+	}
+}
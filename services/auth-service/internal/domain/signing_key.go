@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SigningKeyState is where a key is in its rotation lifecycle
+type SigningKeyState string
+
+// Signing key states. A key is published as pending before it signs, so
+// every instance and every consumer caching the public keys can verify its
+// tokens by then; once superseded it keeps verifying until the tokens it
+// signed have expired.
+const (
+	KeyPending  SigningKeyState = "pending"
+	KeyActive   SigningKeyState = "active"
+	KeyRetiring SigningKeyState = "retiring"
+	KeyRetired  SigningKeyState = "retired"
+)
+
+// SigningKey is one generation of token signing key material
+type SigningKey struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	Generation int       `json:"generation" db:"generation"`
+	Algorithm  string    `json:"algorithm" db:"algorithm"`
+	// Material is the PEM private key for RS256 or the base64 secret for HS256
+	Material    string     `json:"-" db:"material"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at" db:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty" db:"retires_at"`
+}
+
+// NewSigningKey creates the next generation of signing key, signing from activatesAt
+func NewSigningKey(generation int, algorithm, material string, activatesAt time.Time) *SigningKey {
+	return &SigningKey{
+		ID:          uuid.New(),
+		Generation:  generation,
+		Algorithm:   algorithm,
+		Material:    material,
+		CreatedAt:   time.Now(),
+		ActivatesAt: activatesAt,
+	}
+}
+
+// State returns the key's lifecycle state at now. A key is retiring once a
+// retirement time has been set, i.e. once a newer key was scheduled.
+func (k *SigningKey) State(now time.Time) SigningKeyState {
+	switch {
+	case k.RetiresAt != nil && !now.Before(*k.RetiresAt):
+		return KeyRetired
+	case now.Before(k.ActivatesAt):
+		return KeyPending
+	case k.RetiresAt != nil:
+		return KeyRetiring
+	}
+	return KeyActive
+}
+
+// KeyRing is the set of unretired signing keys, oldest generation first
+type KeyRing []*SigningKey
+
+// Signer returns the newest key that has activated at now
+func (r KeyRing) Signer(now time.Time) *SigningKey {
+	var signer *SigningKey
+	for _, key := range r {
+		if !now.Before(key.ActivatesAt) && (signer == nil || key.Generation > signer.Generation) {
+			signer = key
+		}
+	}
+	return signer
+}
+
+// Newest returns the key of the highest generation
+func (r KeyRing) Newest() *SigningKey {
+	var newest *SigningKey
+	for _, key := range r {
+		if newest == nil || key.Generation > newest.Generation {
+			newest = key
+		}
+	}
+	return newest
+}
+
+// NextRotation returns when the next key should be scheduled: one interval
+// after the newest key activates, less the lead time it is published ahead
+func (r KeyRing) NextRotation(interval, lead time.Duration) time.Time {
+	newest := r.Newest()
+	if newest == nil {
+		return time.Time{}
+	}
+	return newest.ActivatesAt.Add(interval - lead)
+}
+
+// SigningKeyRepository defines the interface for signing key persistence
+type SigningKeyRepository interface {
+	// CreateNext inserts the key unless a key of the same or a later
+	// generation exists, failing with sql.ErrNoRows; this way concurrent
+	// instances schedule each rotation once
+	CreateNext(key *SigningKey) error
+	// RetireBefore sets the retirement time of unretiring keys older than generation
+	RetireBefore(generation int, retiresAt time.Time) error
+	// ListUnretired returns the keys not retired at now, oldest generation first
+	ListUnretired(now time.Time) (KeyRing, error)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSigningKeyStates(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	key := NewSigningKey(2, "HS256", "c2VjcmV0", start.Add(time.Hour))
+
+	if got := key.State(start); got != KeyPending {
+		t.Errorf("before activation: %s, want pending", got)
+	}
+	if got := key.State(start.Add(time.Hour)); got != KeyActive {
+		t.Errorf("at activation: %s, want active", got)
+	}
+
+	// a newer key was scheduled
+	retiresAt := start.Add(3 * time.Hour)
+	key.RetiresAt = &retiresAt
+	if got := key.State(start.Add(2 * time.Hour)); got != KeyRetiring {
+		t.Errorf("superseded: %s, want retiring", got)
+	}
+	if got := key.State(retiresAt); got != KeyRetired {
+		t.Errorf("at retirement: %s, want retired", got)
+	}
+}
+
+func TestKeyRingSignerAndNextRotation(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ring := KeyRing{
+		NewSigningKey(1, "HS256", "b25l", start),
+		NewSigningKey(2, "HS256", "dHdv", start.Add(24*time.Hour)),
+	}
+
+	if signer := ring.Signer(start.Add(time.Hour)); signer == nil || signer.Generation != 1 {
+		t.Errorf("signer before the pending key activates = %v, want generation 1", signer)
+	}
+	if signer := ring.Signer(start.Add(24 * time.Hour)); signer == nil || signer.Generation != 2 {
+		t.Errorf("signer once it activates = %v, want generation 2", signer)
+	}
+	if signer := ring.Signer(start.Add(-time.Second)); signer != nil {
+		t.Errorf("signer before any key activates = generation %d, want none", signer.Generation)
+	}
+
+	if newest := ring.Newest(); newest.Generation != 2 {
+		t.Errorf("newest = generation %d, want 2", newest.Generation)
+	}
+	// one interval after the newest activates, less the lead
+	if got, want := ring.NextRotation(7*24*time.Hour, time.Hour), start.Add(8*24*time.Hour-time.Hour); !got.Equal(want) {
+		t.Errorf("next rotation = %s, want %s", got, want)
+	}
+	if got := (KeyRing{}).NextRotation(time.Hour, time.Minute); !got.IsZero() {
+		t.Errorf("next rotation of an empty ring = %s, want zero", got)
+	}
+}
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// MaxTimezoneLength bounds IANA time zone names such as "America/Argentina/Buenos_Aires"
+const MaxTimezoneLength = 64
+
+// checkTimezone fails unless name is a time zone of the IANA database
+func checkTimezone(field, name string) error {
+	if err := checkLength(field, name, MaxTimezoneLength); err != nil {
+		return err
+	}
+	// LoadLocation maps "" and "Local" to the server's zone, which is no user's choice
+	if name == "" || name == "Local" {
+		return ValidationError{Field: field, Message: "must be an IANA time zone name"}
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ValidationError{Field: field, Message: "must be an IANA time zone name"}
+	}
+	return nil
+}
+
+// TimezoneDefaults supply the time zone of users who have not chosen one:
+// their campus's zone, or Fallback for campuses without one
+type TimezoneDefaults struct {
+	Campuses map[string]string
+	Fallback string
+}
+
+// InferTimezone fills in the default time zone when the user has not chosen
+// one. It is for responses and events only; the inferred zone is never saved.
+func (u *User) InferTimezone(defaults TimezoneDefaults) {
+	if u.Timezone != nil {
+		return
+	}
+	zone := defaults.Fallback
+	if u.CampusID != nil {
+		if campusZone, ok := defaults.Campuses[*u.CampusID]; ok {
+			zone = campusZone
+		}
+	}
+	u.Timezone = &zone
+	u.TimezoneInferred = true
+}
+
+// ChosenTimezone returns the time zone the user chose, or nil when it is unset
+// or only inferred
+func (u *User) ChosenTimezone() *string {
+	if u.TimezoneInferred {
+		return nil
+	}
+	return u.Timezone
+}
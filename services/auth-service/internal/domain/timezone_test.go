@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProfileTimezoneValidation(t *testing.T) {
+	tests := []struct {
+		zone  string
+		valid bool
+	}{
+		{"Africa/Addis_Ababa", true},
+		{"America/Argentina/Buenos_Aires", true},
+		{"UTC", true},
+		// an empty zone reverts to the campus default
+		{"", true},
+		{"Mars/Olympus_Mons", false},
+		{"EAT", false},
+		{"Local", false},
+		{"../../etc/passwd", false},
+		{strings.Repeat("A", MaxTimezoneLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			zone := tt.zone
+			err := UserProfile{Timezone: &zone}.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate = %v, want valid", err)
+			}
+			var validation ValidationError
+			if !tt.valid && (!errors.As(err, &validation) || validation.Field != "timezone") {
+				t.Errorf("Validate = %v, want a ValidationError on timezone", err)
+			}
+		})
+	}
+}
+
+func TestInferTimezone(t *testing.T) {
+	defaults := TimezoneDefaults{Campuses: map[string]string{"main": "Africa/Addis_Ababa"}, Fallback: "UTC"}
+	chosen := "Europe/London"
+	main, other := "main", "north"
+	tests := []struct {
+		name     string
+		user     User
+		want     string
+		inferred bool
+	}{
+		{"chosen zone kept", User{Timezone: &chosen, CampusID: &main}, chosen, false},
+		{"campus default", User{CampusID: &main}, "Africa/Addis_Ababa", true},
+		{"campus without a zone", User{CampusID: &other}, "UTC", true},
+		{"no campus", User{}, "UTC", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.InferTimezone(defaults)
+			if user.Timezone == nil || *user.Timezone != tt.want || user.TimezoneInferred != tt.inferred {
+				t.Errorf("timezone %v, inferred %v; want %s, %v", user.Timezone, user.TimezoneInferred, tt.want, tt.inferred)
+			}
+			// an inferred zone is never saved as the user's choice
+			if got := user.ChosenTimezone(); tt.inferred != (got == nil) {
+				t.Errorf("ChosenTimezone = %v", got)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileTimezone(t *testing.T) {
+	chosen, empty := "Europe/London", ""
+	user := &User{}
+	user.InferTimezone(TimezoneDefaults{Fallback: "UTC"})
+
+	if err := user.UpdateProfile(UserProfile{Timezone: &chosen}); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if got := user.ChosenTimezone(); got == nil || *got != chosen {
+		t.Errorf("chosen timezone = %v, want %s", got, chosen)
+	}
+
+	if err := user.UpdateProfile(UserProfile{Timezone: &empty}); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if user.Timezone != nil || user.TimezoneInferred {
+		t.Errorf("timezone %v after reverting to the default, want unset", user.Timezone)
+	}
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TwoFactorLogin completes a login that was challenged for a second factor
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,len=6,numeric"`
+	// TrustDevice issues a device-trust token that skips this step on later logins
+	TrustDevice bool `json:"trust_device"`
+	// DeviceID is the client's identifier of the machine, recorded on the session
+	DeviceID string `json:"device_id,omitempty" validate:"omitempty,max=128"`
+	OIDCRequest
+}
+
+// TwoFactorCode carries a one-time code used to confirm 2FA changes
+type TwoFactorCode struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// TwoFactorSetup is returned when a user starts enrolling an authenticator app
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TrustedDevice remembers a device that completed 2FA so later logins from
+// it can skip the second factor. Only the hash of the device token is stored.
+type TrustedDevice struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Name       string     `json:"name" db:"name"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// NewTrustedDevice creates a trusted device from the plaintext device token
+func NewTrustedDevice(userID uuid.UUID, token, name string, expiresAt time.Time) *TrustedDevice {
+	now := time.Now()
+	return &TrustedDevice{
+		ID:         uuid.New(),
+		UserID:     userID,
+		TokenHash:  HashToken(token),
+		Name:       truncate(name, MaxUserAgentLength),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// IsValid checks if the device trust is neither revoked nor expired
+func (d *TrustedDevice) IsValid() bool {
+	return d.RevokedAt == nil && time.Now().Before(d.ExpiresAt)
+}
+
+// Revoke withdraws the device trust
+func (d *TrustedDevice) Revoke() {
+	now := time.Now()
+	d.RevokedAt = &now
+}
+
+// UpdateLastUsed updates the last used timestamp
+func (d *TrustedDevice) UpdateLastUsed() {
+	d.LastUsedAt = time.Now()
+}
+
+// TrustedDeviceRepository defines the interface for trusted device persistence
+type TrustedDeviceRepository interface {
+	Create(device *TrustedDevice) error
+	GetByTokenHash(tokenHash string) (*TrustedDevice, error)
+	GetByID(id uuid.UUID) (*TrustedDevice, error)
+	ListActiveByUserID(userID uuid.UUID) ([]*TrustedDevice, error)
+	Update(device *TrustedDevice) error
+	RevokeAllByUserID(userID uuid.UUID) error
+}
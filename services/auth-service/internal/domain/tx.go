@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// Repositories groups the repositories bound to one transaction
+type Repositories struct {
+	Users              UserRepository
+	VerificationTokens VerificationTokenRepository
+	Consents           ConsentRepository
+	Audit              AuditRepository
+	Outbox             OutboxRepository
+	PasswordResets     PasswordResetRepository
+}
+
+// ErrServiceUnavailable is returned by repositories when the database cannot
+// take more work right now, such as when every pooled connection is in use;
+// the operation may succeed when retried shortly
+var ErrServiceUnavailable = errors.New("service is temporarily unavailable, retry shortly")
+
+// Transactor runs a unit of work atomically: either every write made
+// through the given repositories is committed, or none is
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(repos Repositories) error) error
+}
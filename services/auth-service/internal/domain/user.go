@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,37 +24,188 @@ type User struct {
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	// LastLoginIP and LastLoginUserAgent are where the last login came from,
+	// the baseline new logins are compared against
+	LastLoginIP        *string `json:"last_login_ip,omitempty" db:"last_login_ip"`
+	LastLoginUserAgent *string `json:"last_login_user_agent,omitempty" db:"last_login_user_agent"`
+
+	// RecoveryEmail receives account recovery links; it can never be used to log in
+	RecoveryEmail         *string `json:"recovery_email,omitempty" db:"recovery_email"`
+	RecoveryEmailVerified bool    `json:"recovery_email_verified" db:"recovery_email_verified"`
+	// Phone is an E.164 number, stored only once verified by a code sent to it
+	Phone         *string `json:"phone,omitempty" db:"phone"`
+	PhoneVerified bool    `json:"phone_verified" db:"phone_verified"`
+
+	AvatarURL *string `json:"avatar_url,omitempty" db:"avatar_url"`
+	// Timezone is an IANA time zone name; when the user has not chosen one it
+	// may hold their campus default, with TimezoneInferred set
+	Timezone         *string `json:"timezone,omitempty" db:"timezone"`
+	TimezoneInferred bool    `json:"timezone_inferred,omitempty" db:"-"`
+	// ProfileHidden hides the public profile from other non-admin users
+	ProfileHidden bool `json:"profile_hidden" db:"profile_hidden"`
+
+	DeactivatedAt  *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty" db:"suspended_until"`
+	BannedAt       *time.Time `json:"banned_at,omitempty" db:"banned_at"`
+	// DisabledBy is the administrator who deactivated the account; only an
+	// administrator can activate it again
+	DisabledBy *uuid.UUID `json:"disabled_by,omitempty" db:"disabled_by"`
+	// MergedInto is the account this one was merged into; merged accounts are retired
+	MergedInto *uuid.UUID `json:"merged_into,omitempty" db:"merged_into"`
+	// DeletedAt is when the account was deleted; deleted accounts are hidden
+	// from every lookup until restored or purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	TwoFactorEnabled bool   `json:"two_factor_enabled" db:"two_factor_enabled"`
+	TwoFactorSecret  string `json:"-" db:"two_factor_secret"`
+
+	// MustChangePassword restricts the account to changing its password
+	MustChangePassword bool `json:"must_change_password" db:"must_change_password"`
+	// PasswordStrength is re-checked when a stricter password rule starts to apply
+	PasswordStrength PasswordStrength `json:"-"`
+	// PepperVersion is the version of the pepper the password hash was made with
+	PepperVersion int `json:"-" db:"password_pepper_version"`
+
+	// PinHash is the optional numeric PIN that unlocks existing sessions;
+	// empty when none is set
+	PinHash          string `json:"-" db:"pin_hash"`
+	PinPepperVersion int    `json:"-" db:"pin_pepper_version"`
+}
+
+// PublicProfile is the limited view of a user shown to other users
+type PublicProfile struct {
+	ID        uuid.UUID `json:"id"`
+	FirstName string    `json:"first_name"`
+	AvatarURL *string   `json:"avatar_url,omitempty"`
+	CampusID  *string   `json:"campus_id,omitempty"`
+}
+
+// InternalProfile is the minimal view of a user other services resolve user
+// IDs to, such as to display names; it never carries contact details
+type InternalProfile struct {
+	ID        uuid.UUID `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	CampusID  *string   `json:"campus_id"`
+	IsActive  bool      `json:"is_active"`
 }
 
 // Role represents user roles in the system
 type Role string
 
 const (
-	RoleStudent Role = "student"
-	RoleAdmin   Role = "admin"
+	RoleStudent   Role = "student"
+	RoleAdmin     Role = "admin"
 	RoleModerator Role = "moderator"
 )
 
+// Roles lists every known role
+var Roles = []Role{RoleStudent, RoleModerator, RoleAdmin}
+
+// rank orders roles by authority
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 3
+	case RoleModerator:
+		return 2
+	case RoleStudent:
+		return 1
+	}
+	return 0
+}
+
+// IsValid checks if the role is one of the known roles
+func (r Role) IsValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // UserRegistration represents user registration data
 type UserRegistration struct {
-	Email     string `json:"email" validate:"required,email"`
-	Password  string `json:"password" validate:"required,min=8"`
+	Email     string `json:"email" validate:"required,email,max=254"`
+	Password  string `json:"password" validate:"required,max=72"`
 	FirstName string `json:"first_name" validate:"required,min=2,max=50"`
 	LastName  string `json:"last_name" validate:"required,min=2,max=50"`
-	CampusID  string `json:"campus_id" validate:"required"`
+	CampusID  string `json:"campus_id" validate:"required,max=64"`
+}
+
+// BulkRoleAssignment assigns one role to many users
+type BulkRoleAssignment struct {
+	UserIDs []uuid.UUID `json:"user_ids" validate:"required,min=1,max=500"`
+	Role    Role        `json:"role" validate:"required"`
+}
+
+// UserBatchLookup asks for many users at once
+type UserBatchLookup struct {
+	IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=200"`
+}
+
+// RoleChange represents an admin's request to change a user's role
+type RoleChange struct {
+	Role Role `json:"role" validate:"required"`
+}
+
+// PasswordChange represents a request to change the password
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,max=72"`
+}
+
+// PasswordChangeRequirement is an admin request to force a password change
+type PasswordChangeRequirement struct {
+	RevokeSessions bool `json:"revoke_sessions"`
+}
+
+// PhoneRequest represents a request to add or change the phone number
+type PhoneRequest struct {
+	Phone string `json:"phone" validate:"required,e164"`
+}
+
+// PhoneVerification confirms a phone number with the code sent to it
+type PhoneVerification struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
 }
 
 // UserLogin represents login credentials
 type UserLogin struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	// DeviceToken is a device-trust token from a previous 2FA login
+	DeviceToken string `json:"device_token,omitempty"`
+	// DeviceID is the client's identifier of the machine, recorded on the session
+	DeviceID string `json:"device_id,omitempty" validate:"omitempty,max=128"`
+	// RememberMe asks for a long-lived session; without it the session
+	// ends after the short session lifetime
+	RememberMe bool `json:"remember_me,omitempty"`
+	OIDCRequest
+	SessionScopeRequest
 }
 
 // UserProfile represents user profile update data
 type UserProfile struct {
 	FirstName string  `json:"first_name,omitempty" validate:"omitempty,min=2,max=50"`
 	LastName  string  `json:"last_name,omitempty" validate:"omitempty,min=2,max=50"`
-	CampusID  *string `json:"campus_id,omitempty"`
+	CampusID  *string `json:"campus_id,omitempty" validate:"omitempty,max=64"`
+	AvatarURL *string `json:"avatar_url,omitempty" validate:"omitempty,url,max=512"`
+	// Timezone sets the IANA time zone; an empty string reverts to the campus default
+	Timezone *string `json:"timezone,omitempty" validate:"omitempty,max=64"`
+	// ProfileHidden toggles the visibility of the public profile
+	ProfileHidden *bool `json:"profile_hidden,omitempty"`
+}
+
+// EmailChangeRequest represents a request to change the login email
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=254"`
+}
+
+// RecoveryEmailRequest represents a request to set the recovery email
+type RecoveryEmailRequest struct {
+	RecoveryEmail string `json:"recovery_email" validate:"required,email,max=254"`
 }
 
 // TokenPair represents JWT tokens
@@ -59,58 +213,167 @@ type TokenPair struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	// SessionExpiresAt is when the session, and so the refresh token, expires
+	SessionExpiresAt time.Time `json:"session_expires_at"`
+	// IDToken is only issued when the openid scope was requested
+	IDToken string `json:"id_token,omitempty"`
+	// Warning tells a logging in client about a side effect of the login,
+	// such as an old session being evicted
+	Warning string `json:"warning,omitempty"`
 }
 
 // Session represents a user session
 type Session struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	UserID       uuid.UUID `json:"user_id" db:"user_id"`
-	RefreshToken string    `json:"-" db:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	LastUsedAt   time.Time `json:"last_used_at" db:"last_used_at"`
-	IPAddress    string    `json:"ip_address" db:"ip_address"`
-	UserAgent    string    `json:"user_agent" db:"user_agent"`
-	IsRevoked    bool      `json:"is_revoked" db:"is_revoked"`
-}
-
-// NewUser creates a new user with hashed password
-func NewUser(reg UserRegistration) (*User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(reg.Password), bcrypt.DefaultCost)
+	ID     uuid.UUID `json:"id" db:"id"`
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	// RefreshTokenHash is the SHA-256 of the refresh token. The token itself
+	// is never stored; it is held in memory only to be returned once.
+	RefreshTokenHash string `json:"-" db:"refresh_token_hash"`
+	// PreviousRefreshTokenHash is the hash of the token replaced by the last
+	// rotation, kept to detect reuse
+	PreviousRefreshTokenHash string `json:"-" db:"previous_refresh_token_hash"`
+	// RotationNonce derived the current refresh token from the previous one
+	RotationNonce string      `json:"-" db:"rotation_nonce"`
+	RotatedAt     *time.Time  `json:"-" db:"rotated_at"`
+	ExpiresAt     time.Time   `json:"expires_at" db:"expires_at"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	LastUsedAt    time.Time   `json:"last_used_at" db:"last_used_at"`
+	IPAddress     string      `json:"ip_address" db:"ip_address"`
+	UserAgent     string      `json:"user_agent" db:"user_agent"`
+	IsRevoked     bool        `json:"is_revoked" db:"is_revoked"`
+	RiskScore     int         `json:"risk_score" db:"risk_score"`
+	RiskSignals   RiskSignals `json:"risk_signals" db:"risk_signals"`
+	// Scopes narrows what the session's tokens may do; nil allows everything the role allows
+	Scopes SessionScopes `json:"scopes,omitempty" db:"scopes"`
+	// PinFailures counts the wrong PINs since the session was last unlocked
+	PinFailures int `json:"-" db:"pin_failures"`
+	// TokenFormat is the form of the session's access tokens, taken from the
+	// client the session was opened for
+	TokenFormat TokenFormat `json:"token_format,omitempty" db:"token_format"`
+	// ClientID is the OAuth client the session was opened through, empty
+	// for first-party logins
+	ClientID string `json:"client_id,omitempty" db:"client_id"`
+	// DeviceID identifies the machine the session was opened on, shared by
+	// every user of it: the kiosk of a device login, or the ID the client
+	// reported at login. Empty when unknown.
+	DeviceID string `json:"device_id,omitempty" db:"device_id"`
+	// RememberMe records that the user asked to be remembered at login,
+	// which gave the session the long lifetime
+	RememberMe bool `json:"remember_me" db:"remember_me"`
+	SessionAuth
+
+	// refreshToken is the plaintext of RefreshTokenHash, set only on the
+	// session that issued or rotated it
+	refreshToken string
+}
+
+// NewUser creates a new user with the password hashed with the current pepper
+func NewUser(reg UserRegistration, peppers Peppers) (*User, error) {
+	if err := reg.checkLengths(); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hashPassword(reg.Password, peppers)
 	if err != nil {
 		return nil, err
 	}
 
 	return &User{
-		ID:         uuid.New(),
-		Email:      reg.Email,
-		Password:   string(hashedPassword),
-		FirstName:  reg.FirstName,
-		LastName:   reg.LastName,
-		CampusID:   &reg.CampusID,
-		Role:       RoleStudent,
-		IsActive:   true,
-		IsVerified: false,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:               uuid.New(),
+		Email:            NormalizeEmail(reg.Email),
+		Password:         hashedPassword,
+		PasswordStrength: MeasurePassword(reg.Password),
+		PepperVersion:    peppers.Current,
+		FirstName:        reg.FirstName,
+		LastName:         reg.LastName,
+		CampusID:         &reg.CampusID,
+		Role:             RoleStudent,
+		IsActive:         true,
+		IsVerified:       false,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}, nil
 }
 
-// CheckPassword verifies the password against the hash
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+// CheckPassword verifies the password against the hash, using the pepper
+// the hash was made with. Hashes of a pepper no longer configured never match.
+func (u *User) CheckPassword(password string, peppers Peppers) bool {
+	peppered, err := peppers.apply(u.PepperVersion, password)
+	if err != nil {
+		return false
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(peppered))
 	return err == nil
 }
 
-// UpdateLastLogin updates the last login timestamp
-func (u *User) UpdateLastLogin() {
+// NeedsRehash reports whether the password hash was made with another pepper
+// than the current one
+func (u *User) NeedsRehash(peppers Peppers) bool {
+	return u.PepperVersion != peppers.Current
+}
+
+// RehashPassword hashes the password again with the current pepper. The
+// caller must have checked the password first.
+func (u *User) RehashPassword(password string, peppers Peppers) error {
+	hashedPassword, err := hashPassword(password, peppers)
+	if err != nil {
+		return err
+	}
+	u.Password = hashedPassword
+	u.PepperVersion = peppers.Current
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetPassword replaces the password with a bcrypt hash of the new one, made
+// with the current pepper
+func (u *User) SetPassword(password string, peppers Peppers) error {
+	if err := checkPasswordLength("password", password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := hashPassword(password, peppers)
+	if err != nil {
+		return err
+	}
+	u.Password = hashedPassword
+	u.PepperVersion = peppers.Current
+	u.PasswordStrength = MeasurePassword(password)
+	u.MustChangePassword = false
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// RequirePasswordChange restricts the account until its password is changed
+func (u *User) RequirePasswordChange() {
+	u.MustChangePassword = true
+	u.UpdatedAt = time.Now()
+}
+
+// UpdateLastLogin records a login from the address and user agent
+func (u *User) UpdateLastLogin(ipAddress, userAgent string) {
 	now := time.Now()
 	u.LastLoginAt = &now
+	u.LastLoginIP = &ipAddress
+	u.LastLoginUserAgent = &userAgent
 	u.UpdatedAt = now
 }
 
+// LastLoginOrigin returns where the user last logged in from, or nil when
+// that was not recorded
+func (u *User) LastLoginOrigin() *LoginOrigin {
+	if u.LastLoginAt == nil || u.LastLoginIP == nil || u.LastLoginUserAgent == nil {
+		return nil
+	}
+	return &LoginOrigin{IPAddress: *u.LastLoginIP, UserAgent: *u.LastLoginUserAgent, At: *u.LastLoginAt}
+}
+
 // UpdateProfile updates user profile information
-func (u *User) UpdateProfile(profile UserProfile) {
+func (u *User) UpdateProfile(profile UserProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
 	if profile.FirstName != "" {
 		u.FirstName = profile.FirstName
 	}
@@ -120,12 +383,178 @@ func (u *User) UpdateProfile(profile UserProfile) {
 	if profile.CampusID != nil {
 		u.CampusID = profile.CampusID
 	}
+	if profile.AvatarURL != nil {
+		u.AvatarURL = profile.AvatarURL
+	}
+	if profile.ProfileHidden != nil {
+		u.ProfileHidden = *profile.ProfileHidden
+	}
+	if profile.Timezone != nil {
+		u.Timezone, u.TimezoneInferred = nil, false
+		if *profile.Timezone != "" {
+			u.Timezone = profile.Timezone
+		}
+	}
 	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// IsAdmin checks if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// CanAssignRole checks if u may give target the role. Nobody changes their
+// own role; admins may assign any role to non-admins, other staff may only
+// assign roles below their own to users below their own.
+func (u *User) CanAssignRole(target *User, role Role) bool {
+	if u.ID == target.ID || target.Role == RoleAdmin {
+		return false
+	}
+	if u.IsAdmin() {
+		return true
+	}
+	return role.rank() < u.Role.rank() && target.Role.rank() < u.Role.rank()
+}
+
+// PublicProfile returns the limited view of the user shown to other users
+func (u *User) PublicProfile() PublicProfile {
+	return PublicProfile{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		AvatarURL: u.AvatarURL,
+		CampusID:  u.CampusID,
+	}
+}
+
+// InternalProfile returns the view of the user shown to other services
+func (u *User) InternalProfile() InternalProfile {
+	return InternalProfile{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		CampusID:  u.CampusID,
+		IsActive:  u.IsActive,
+	}
+}
+
+// ChangeEmail replaces the login email with a new address whose ownership
+// has been confirmed, which makes the email verified. A recovery email equal
+// to the new address is dropped, as it would no longer be a second address.
+func (u *User) ChangeEmail(email string) error {
+	if err := checkLength("email", email, MaxEmailLength); err != nil {
+		return err
+	}
+
+	u.Email = NormalizeEmail(email)
+	u.IsVerified = true
+	if u.RecoveryEmail != nil && strings.EqualFold(*u.RecoveryEmail, email) {
+		u.RecoveryEmail = nil
+		u.RecoveryEmailVerified = false
+	}
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetRecoveryEmail stores a recovery email whose ownership has been confirmed
+func (u *User) SetRecoveryEmail(email string) error {
+	if err := checkLength("recovery_email", email, MaxEmailLength); err != nil {
+		return err
+	}
+
+	u.RecoveryEmail = &email
+	u.RecoveryEmailVerified = true
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetPhone stores a phone number whose ownership has been verified
+func (u *User) SetPhone(phone string) error {
+	if err := checkLength("phone", phone, MaxPhoneLength); err != nil {
+		return err
+	}
+
+	u.Phone = &phone
+	u.PhoneVerified = true
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// RecoveryDestinations returns the addresses account recovery links may be sent to
+func (u *User) RecoveryDestinations() []string {
+	destinations := []string{u.Email}
+	if u.RecoveryEmail != nil && u.RecoveryEmailVerified {
+		destinations = append(destinations, *u.RecoveryEmail)
+	}
+	return destinations
 }
 
 // Deactivate marks the user as inactive
 func (u *User) Deactivate() {
+	now := time.Now()
 	u.IsActive = false
+	u.DeactivatedAt = &now
+	u.UpdatedAt = now
+}
+
+// Disable deactivates the user on behalf of an administrator
+func (u *User) Disable(adminID uuid.UUID) {
+	u.Deactivate()
+	u.DisabledBy = &adminID
+}
+
+// Reactivate marks a deactivated or disabled user as active again
+func (u *User) Reactivate() {
+	u.IsActive = true
+	u.DeactivatedAt = nil
+	u.DisabledBy = nil
+	u.UpdatedAt = time.Now()
+}
+
+// IsBanned checks if the user has been banned
+func (u *User) IsBanned() bool {
+	return u.Status().State == AccountBanned
+}
+
+// IsSuspended checks if the user is currently suspended
+func (u *User) IsSuspended() bool {
+	return u.Status().State == AccountSuspended
+}
+
+// CanSelfReactivate checks if the user may reactivate their own account;
+// suspended and banned accounts never can, nor can deactivated accounts past
+// their reactivation window
+func (u *User) CanSelfReactivate(window time.Duration) bool {
+	return u.Status().State == AccountDeactivated && !u.ReactivationExpired(window)
+}
+
+// ReactivationDeadline returns when the reactivation window of a
+// self-deactivated account ends, or nil when window is zero (no limit)
+func (u *User) ReactivationDeadline(window time.Duration) *time.Time {
+	if window <= 0 || u.DeactivatedAt == nil {
+		return nil
+	}
+	deadline := u.DeactivatedAt.Add(window)
+	return &deadline
+}
+
+// ReactivationExpired reports whether the reactivation window of the account
+// has elapsed; such accounts are due for anonymization
+func (u *User) ReactivationExpired(window time.Duration) bool {
+	deadline := u.ReactivationDeadline(window)
+	return deadline != nil && !time.Now().Before(*deadline)
+}
+
+// EnableTwoFactor turns on 2FA using the confirmed secret
+func (u *User) EnableTwoFactor() {
+	u.TwoFactorEnabled = true
+	u.UpdatedAt = time.Now()
+}
+
+// DisableTwoFactor turns off 2FA and forgets the secret
+func (u *User) DisableTwoFactor() {
+	u.TwoFactorEnabled = false
+	u.TwoFactorSecret = ""
 	u.UpdatedAt = time.Now()
 }
 
@@ -135,19 +564,88 @@ func (u *User) Verify() {
 	u.UpdatedAt = time.Now()
 }
 
-// NewSession creates a new session for the user
+func (r UserRegistration) checkLengths() error {
+	if err := checkLength("email", r.Email, MaxEmailLength); err != nil {
+		return err
+	}
+	if err := checkPasswordLength("password", r.Password); err != nil {
+		return err
+	}
+	if err := checkLength("first_name", r.FirstName, MaxNameLength); err != nil {
+		return err
+	}
+	if err := checkLength("last_name", r.LastName, MaxNameLength); err != nil {
+		return err
+	}
+	return CheckCampusID("campus_id", r.CampusID)
+}
+
+// Validate checks the profile fields before any of them is applied
+func (p UserProfile) Validate() error {
+	if err := checkLength("first_name", p.FirstName, MaxNameLength); err != nil {
+		return err
+	}
+	if err := checkLength("last_name", p.LastName, MaxNameLength); err != nil {
+		return err
+	}
+	if p.CampusID != nil {
+		if err := CheckCampusID("campus_id", *p.CampusID); err != nil {
+			return err
+		}
+	}
+	if p.AvatarURL != nil {
+		if err := checkLength("avatar_url", *p.AvatarURL, MaxAvatarURLLength); err != nil {
+			return err
+		}
+	}
+	if p.Timezone != nil && *p.Timezone != "" {
+		return checkTimezone("timezone", *p.Timezone)
+	}
+	return nil
+}
+
+// Fields names the fields the profile update sets, for audit records that
+// must not hold the values themselves
+func (p UserProfile) Fields() []string {
+	fields := []string{}
+	if p.FirstName != "" {
+		fields = append(fields, "first_name")
+	}
+	if p.LastName != "" {
+		fields = append(fields, "last_name")
+	}
+	if p.CampusID != nil {
+		fields = append(fields, "campus_id")
+	}
+	if p.AvatarURL != nil {
+		fields = append(fields, "avatar_url")
+	}
+	if p.Timezone != nil {
+		fields = append(fields, "timezone")
+	}
+	if p.ProfileHidden != nil {
+		fields = append(fields, "profile_hidden")
+	}
+	return fields
+}
+
+// NewSession creates a new session for the user,
+// holding the hash of refreshToken. An empty refreshToken leaves it unset.
 func NewSession(userID uuid.UUID, refreshToken, ipAddress, userAgent string, expiresAt time.Time) *Session {
-	return &Session{
-		ID:           uuid.New(),
-		UserID:       userID,
-		RefreshToken: refreshToken,
-		ExpiresAt:    expiresAt,
-		CreatedAt:    time.Now(),
-		LastUsedAt:   time.Now(),
-		IPAddress:    ipAddress,
-		UserAgent:    userAgent,
-		IsRevoked:    false,
+	session := &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+		LastUsedAt: time.Now(),
+		IPAddress:  truncate(ipAddress, MaxIPAddressLength),
+		UserAgent:  truncate(userAgent, MaxUserAgentLength),
+		IsRevoked:  false,
 	}
+	if refreshToken != "" {
+		session.SetRefreshToken(refreshToken)
+	}
+	return session
 }
 
 // IsExpired checks if the session is expired
@@ -155,32 +653,158 @@ func (s *Session) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
 
+// IsActive reports whether the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return !s.IsRevoked && !s.IsExpired()
+}
+
+// ExceedsMaxAge checks if the session is older than the absolute maximum
+// session age; such sessions cannot be refreshed whatever their expiry
+func (s *Session) ExceedsMaxAge(maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(s.CreatedAt) > maxAge
+}
+
+// AssessRisk records the risk signals observed at session creation and their score
+func (s *Session) AssessRisk(signals RiskSignals) {
+	s.RiskSignals = signals
+	s.RiskScore = signals.Score()
+}
+
+// IsHighRisk checks if the session requires step-up authentication for sensitive actions
+func (s *Session) IsHighRisk() bool {
+	return s.RiskScore >= HighRiskThreshold
+}
+
 // Revoke marks the session as revoked
 func (s *Session) Revoke() {
 	s.IsRevoked = true
 }
 
+// WithinRotationGrace checks if the last rotation happened less than grace
+// ago, during which the previous refresh token is still honoured
+func (s *Session) WithinRotationGrace(grace time.Duration) bool {
+	return s.RotatedAt != nil && time.Since(*s.RotatedAt) <= grace
+}
+
 // UpdateLastUsed updates the last used timestamp
 func (s *Session) UpdateLastUsed() {
 	s.LastUsedAt = time.Now()
 }
 
-// UserRepository defines the interface for user persistence
+// UserFilter selects a page of users; nil fields match every user
+type UserFilter struct {
+	Role       *Role
+	IsActive   *bool
+	IsVerified *bool
+	CampusID   *string
+	Limit      int
+	Offset     int
+}
+
+// Lookup and uniqueness failures of the user and session repositories. The
+// not-found errors also match sql.ErrNoRows, which callers checked before.
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrEmailAlreadyExists is returned when a user is stored with an email
+	// another user already has
+	ErrEmailAlreadyExists = errors.New("email already registered")
+	// ErrPhoneAlreadyVerified is returned when a user is stored with a
+	// verified phone number another user has verified
+	ErrPhoneAlreadyVerified = errors.New("phone number already verified on another account")
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+)
+
+// UserRepository defines the interface for user persistence. Lookups return
+// ErrUserNotFound, and Create and Update ErrEmailAlreadyExists when the
+// email is taken and Update ErrPhoneAlreadyVerified when the verified phone
+// number is.
 type UserRepository interface {
-	Create(user *User) error
-	GetByID(id uuid.UUID) (*User, error)
-	GetByEmail(email string) (*User, error)
-	Update(user *User) error
-	Delete(id uuid.UUID) error
-	List(limit, offset int) ([]*User, error)
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
+	// GetByIDs fetches the users with the IDs in one query; IDs of no user
+	// are skipped
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*User, error)
+	// GetByEmail fetches the user with the email, compared case-insensitively
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// GetByEmailAlias fetches a user whose email is a spelling of the
+	// aliases-stripped email, as returned by StripEmailAliases
+	GetByEmailAlias(ctx context.Context, stripped string) (*User, error)
+	GetByPhone(ctx context.Context, phone string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	// Delete soft-deletes and deactivates the user, failing with
+	// sql.ErrNoRows when there is no such user or it is already deleted
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Restore undoes the deletion of a soft-deleted user, failing with
+	// sql.ErrNoRows when there is no such deleted user
+	Restore(ctx context.Context, id uuid.UUID) error
+	// NormalizeEmails lowercases and trims the stored emails that are not
+	// yet, and returns how many it changed and how many it had to leave
+	// because the normalized email belongs to another user
+	NormalizeEmails(ctx context.Context) (normalized, conflicting int64, err error)
+	// PurgeDeleted permanently removes the users deleted before olderThan
+	PurgeDeleted(ctx context.Context, olderThan time.Time) (int64, error)
+	List(ctx context.Context, limit, offset int) ([]*User, error)
+	ListByRole(ctx context.Context, role Role, limit, offset int) ([]*User, error)
+	CountByRole(ctx context.Context, role Role) (int, error)
+	// ListFiltered returns a page of the users matching the filter, newest
+	// first, with the number of users matching it on every page
+	ListFiltered(ctx context.Context, filter UserFilter) ([]*User, int, error)
+	SetRole(ctx context.Context, ids []uuid.UUID, role Role) error
+	// UseTOTPStep records that the user's TOTP code of the time step was
+	// accepted, failing with sql.ErrNoRows when the step or a later one
+	// already was
+	UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) error
+	// LockRole locks the users holding the role until the transaction ends
+	// and returns how many there are
+	LockRole(ctx context.Context, role Role) (int, error)
+	// ListUnverified returns active, unbanned users with an unverified email
+	// and an ID greater than after, ordered by ID. A nil campusID matches every campus.
+	ListUnverified(ctx context.Context, campusID *string, after uuid.UUID, limit int) ([]*User, error)
+	// ListByCampus returns the campus's users with an ID greater than after, ordered by ID
+	ListByCampus(ctx context.Context, campusID string, after uuid.UUID, limit int) ([]*User, error)
+	// SignupFunnel counts the registrations in [From, To) per UTC day
+	SignupFunnel(ctx context.Context, query SignupFunnelQuery) ([]*SignupFunnelRow, error)
 }
 
-// SessionRepository defines the interface for session persistence
+// Refresh tokens are unique across sessions
+var (
+	// ErrRefreshTokenTaken is returned when a session is stored with a
+	// refresh token another session already holds
+	ErrRefreshTokenTaken = errors.New("refresh token is held by another session")
+	// ErrAmbiguousRefreshToken is returned when a refresh token lookup
+	// matches more than one session
+	ErrAmbiguousRefreshToken = errors.New("refresh token matches more than one session")
+)
+
+// SessionRepository defines the interface for session persistence. Create and
+// Update return ErrRefreshTokenTaken when the refresh token is not unique.
 type SessionRepository interface {
-	Create(session *Session) error
-	GetByRefreshToken(token string) (*Session, error)
-	GetByUserID(userID uuid.UUID) ([]*Session, error)
-	Update(session *Session) error
-	Delete(id uuid.UUID) error
-	RevokeAllByUserID(userID uuid.UUID) error
+	Create(ctx context.Context, session *Session) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Session, error)
+	// GetByRefreshToken looks the token up by its hash, and returns
+	// ErrAmbiguousRefreshToken rather than pick one of several sessions holding it
+	GetByRefreshToken(ctx context.Context, token string) (*Session, error)
+	GetByPreviousRefreshToken(ctx context.Context, token string) (*Session, error)
+	// GetByRefreshTokenHash finds the session by the SHA-256 hex digest of its current refresh token
+	GetByRefreshTokenHash(ctx context.Context, hash string) (*Session, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]*Session, error)
+	// CountActive counts the unrevoked, unexpired sessions of all users
+	CountActive(ctx context.Context) (int, error)
+	// CountActiveByUserID counts the unrevoked, unexpired sessions of a user
+	CountActiveByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+	// GetOldestActiveByUserID returns the active session of a user that was
+	// used least recently
+	GetOldestActiveByUserID(ctx context.Context, userID uuid.UUID) (*Session, error)
+	Update(ctx context.Context, session *Session) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	RevokeAllByUserID(ctx context.Context, userID uuid.UUID) error
+	// RevokeByClient revokes every active session the user opened through the client
+	RevokeByClient(ctx context.Context, userID uuid.UUID, clientID string) error
+	// ListActiveByDevice returns the active sessions of every user opened on
+	// the device, newest first
+	ListActiveByDevice(ctx context.Context, deviceID string) ([]*Session, error)
+	// RevokeByDevice revokes every active session opened on the device,
+	// whoever it belongs to, returning the sessions it revoked
+	RevokeByDevice(ctx context.Context, deviceID string) ([]*Session, error)
 }
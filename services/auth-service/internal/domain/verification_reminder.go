@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VerificationReminder counts the reminders sent to a user who has not
+// verified their email yet
+type VerificationReminder struct {
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	Count      int       `json:"count" db:"count"`
+	LastSentAt time.Time `json:"last_sent_at" db:"last_sent_at"`
+}
+
+// VerificationReminderRepository defines the interface for verification reminder persistence
+type VerificationReminderRepository interface {
+	// Get returns the user's reminder count, failing with sql.ErrNoRows when
+	// none was sent yet
+	Get(userID uuid.UUID) (*VerificationReminder, error)
+	// Record counts one more reminder sent to the user at sentAt
+	Record(userID uuid.UUID, sentAt time.Time) error
+}
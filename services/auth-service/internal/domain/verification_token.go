@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenPurpose identifies what a verification token confirms
+type TokenPurpose string
+
+const (
+	PurposeEmail         TokenPurpose = "email"
+	PurposeRecoveryEmail TokenPurpose = "recovery_email"
+	PurposePhone         TokenPurpose = "phone"
+	// PurposeEmailChange confirms a new login email; Target is the new address
+	PurposeEmailChange TokenPurpose = "email_change"
+)
+
+// VerificationToken is a single-use token proving control of an address.
+// Target holds the address being confirmed; only the token hash is stored.
+type VerificationToken struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	UserID    uuid.UUID    `json:"user_id" db:"user_id"`
+	Purpose   TokenPurpose `json:"purpose" db:"purpose"`
+	Target    string       `json:"target" db:"target"`
+	TokenHash string       `json:"-" db:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+// NewVerificationToken creates a verification token from the plaintext token
+func NewVerificationToken(userID uuid.UUID, purpose TokenPurpose, target, token string, expiresAt time.Time) *VerificationToken {
+	return &VerificationToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Target:    target,
+		TokenHash: HashToken(token),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsExpired checks if the token is past its expiry
+func (t *VerificationToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// IsValid checks if the token is unused and unexpired
+func (t *VerificationToken) IsValid() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// MarkUsed consumes the token
+func (t *VerificationToken) MarkUsed() {
+	now := time.Now()
+	t.UsedAt = &now
+}
+
+// VerificationTokenRepository defines the interface for verification token persistence
+type VerificationTokenRepository interface {
+	Create(token *VerificationToken) error
+	GetByTokenHash(tokenHash string) (*VerificationToken, error)
+	GetLatestByUserID(userID uuid.UUID, purpose TokenPurpose) (*VerificationToken, error)
+	Update(token *VerificationToken) error
+}
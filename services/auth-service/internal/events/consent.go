@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// requiredConsent lists the event types that may only be published for users
+// who granted the given purpose
+var requiredConsent = map[string]domain.ConsentPurpose{
+	UserLoggedIn: domain.ConsentAnalytics,
+}
+
+// ConsentChecker reports whether a user currently grants a consent purpose
+type ConsentChecker interface {
+	HasConsent(ctx context.Context, userID uuid.UUID, purpose domain.ConsentPurpose) (bool, error)
+}
+
+// ConsentPublisher drops consent-gated events for users who have not granted
+// the required purpose, and forwards everything else unchanged
+type ConsentPublisher struct {
+	Publisher
+	consents ConsentChecker
+}
+
+// NewConsentPublisher wraps next with consent checks
+func NewConsentPublisher(next Publisher, consents ConsentChecker) *ConsentPublisher {
+	return &ConsentPublisher{Publisher: next, consents: consents}
+}
+
+// Publish forwards the event when its subject has granted the required consent.
+// Events without a subject user, or whose consent cannot be checked, are dropped.
+func (p *ConsentPublisher) Publish(ctx context.Context, event DomainEvent) error {
+	purpose, gated := requiredConsent[event.EventType]
+	if !gated {
+		return p.Publisher.Publish(ctx, event)
+	}
+
+	userID, ok := event.UserID()
+	if !ok {
+		return nil
+	}
+
+	granted, err := p.consents.HasConsent(ctx, userID, purpose)
+	if err != nil {
+		log.Printf("Dropping %s event, consent check failed: %v", event.EventType, err)
+		return nil
+	}
+	if !granted {
+		return nil
+	}
+	return p.Publisher.Publish(ctx, event)
+}
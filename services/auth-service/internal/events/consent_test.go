@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+type recordingPublisher struct {
+	Publisher
+	published []DomainEvent
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event DomainEvent) error {
+	p.published = append(p.published, event)
+	return nil
+}
+
+// consentChecker grants the purposes listed per user
+type consentChecker struct {
+	granted map[uuid.UUID]domain.ConsentPurpose
+	err     error
+}
+
+func (c consentChecker) HasConsent(_ context.Context, userID uuid.UUID, purpose domain.ConsentPurpose) (bool, error) {
+	return c.granted[userID] == purpose, c.err
+}
+
+func TestConsentPublisher(t *testing.T) {
+	consenting, refusing := uuid.New(), uuid.New()
+	checker := consentChecker{granted: map[uuid.UUID]domain.ConsentPurpose{consenting: domain.ConsentAnalytics}}
+
+	tests := []struct {
+		name    string
+		checker consentChecker
+		event   DomainEvent
+		want    bool
+	}{
+		{"analytics with consent", checker, NewDomainEvent(UserLoggedIn, nil).WithUserID(consenting), true},
+		{"analytics without consent", checker, NewDomainEvent(UserLoggedIn, nil).WithUserID(refusing), false},
+		{"analytics without a subject", checker, NewDomainEvent(UserLoggedIn, nil), false},
+		{"analytics when the check fails", consentChecker{err: errors.New("database down")}, NewDomainEvent(UserLoggedIn, nil).WithUserID(consenting), false},
+		{"ungated event", checker, NewDomainEvent(UserDeactivated, nil).WithUserID(refusing), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &recordingPublisher{}
+			if err := NewConsentPublisher(next, tt.checker).Publish(context.Background(), tt.event); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if published := len(next.published) == 1; published != tt.want {
+				t.Errorf("published = %v, want %v", published, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationType tells the notification service which template renders an
+// event. Values are part of the event contract and must stay stable.
+type NotificationType string
+
+// Notification types of the events published by the auth service
+const (
+	NotificationWelcome                   NotificationType = "welcome"
+	NotificationEmailVerification         NotificationType = "email_verification"
+	NotificationVerificationReminder      NotificationType = "verification_reminder"
+	NotificationEmailChangeConfirmation   NotificationType = "email_change_confirmation"
+	NotificationEmailChangePending        NotificationType = "email_change_pending"
+	NotificationEmailChanged              NotificationType = "email_changed"
+	NotificationRecoveryEmailConfirmation NotificationType = "recovery_email_confirmation"
+	NotificationRecoveryEmailChanged      NotificationType = "recovery_email_changed"
+	NotificationPhoneVerification         NotificationType = "phone_verification"
+	NotificationPasswordReset             NotificationType = "password_reset"
+	NotificationSecurityChanged           NotificationType = "security_changed"
+	NotificationNewSignIn                 NotificationType = "new_sign_in"
+)
+
+// UserRegisteredData is the payload of UserRegistered
+type UserRegisteredData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	FirstName        string           `json:"firstName"`
+	LastName         string           `json:"lastName"`
+	CampusID         *string          `json:"campusId"`
+	Role             string           `json:"role"`
+	// Timezone is the user's IANA time zone, for localized notifications
+	Timezone string `json:"timezone"`
+}
+
+// UserVerifiedData is the payload of UserVerified. It carries the welcome
+// notification when registration deferred it until the email was verified.
+type UserVerifiedData struct {
+	NotificationType NotificationType `json:"notificationType,omitempty"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	FirstName        string           `json:"firstName"`
+	LastName         string           `json:"lastName"`
+	CampusID         *string          `json:"campusId"`
+	Role             string           `json:"role"`
+	Timezone         *string          `json:"timezone"`
+}
+
+// EmailVerificationData is the payload of EmailVerificationRequested
+type EmailVerificationData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	VerificationLink string           `json:"verificationLink"`
+	Token            string           `json:"token"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+}
+
+// VerificationReminderData is the payload of EmailVerificationReminder.
+// Reminder counts the reminders sent so far, this one included.
+type VerificationReminderData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	VerificationLink string           `json:"verificationLink"`
+	Token            string           `json:"token"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+	Reminder         int              `json:"reminder"`
+}
+
+// EmailChangeConfirmationData is the payload of EmailChangeRequested, sent
+// to the new address
+type EmailChangeConfirmationData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	NewEmail         string           `json:"newEmail"`
+	ConfirmationLink string           `json:"confirmationLink"`
+	Token            string           `json:"token"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+}
+
+// EmailChangeNoticeData is the payload of EmailChangePending and
+// EmailChanged, sent to the address being replaced
+type EmailChangeNoticeData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	NewEmail         string           `json:"newEmail"`
+}
+
+// RecoveryEmailConfirmationData is the payload of RecoveryEmailConfirmationRequested
+type RecoveryEmailConfirmationData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	RecoveryEmail    string           `json:"recoveryEmail"`
+	ConfirmationLink string           `json:"confirmationLink"`
+	Token            string           `json:"token"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+}
+
+// RecoveryEmailConfirmedData is the payload of RecoveryEmailConfirmed
+type RecoveryEmailConfirmedData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	RecoveryEmail    string           `json:"recoveryEmail"`
+}
+
+// PhoneVerificationData is the payload of PhoneVerificationRequested
+type PhoneVerificationData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Phone            string           `json:"phone"`
+	Code             string           `json:"code"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+}
+
+// PasswordResetRequestedData is the payload of PasswordResetRequested. The
+// token is submitted to POST /api/v1/auth/reset-password with the new password.
+// The link goes to every address in Recipients: the login email and the
+// verified recovery email, if any.
+type PasswordResetRequestedData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Email            string           `json:"email"`
+	Recipients       []string         `json:"recipients"`
+	FirstName        string           `json:"firstName"`
+	Token            string           `json:"token"`
+	ExpiresAt        time.Time        `json:"expiresAt"`
+}
+
+// SecurityChangedData is the payload of UserSecurityChanged. The alert goes
+// to every address in Recipients. Critical alerts must be sent even to users
+// who opted out of security notifications.
+type SecurityChangedData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	Change           string           `json:"change"`
+	Critical         bool             `json:"critical"`
+	Recipients       []string         `json:"recipients"`
+	IPAddress        string           `json:"ipAddress"`
+	UserAgent        string           `json:"userAgent"`
+	ChangedAt        time.Time        `json:"changedAt"`
+}
+
+// SuspiciousLoginData is the payload of LoginSuspicious, sent to every
+// address in Recipients. The location fields are empty when the address
+// could not be located.
+type SuspiciousLoginData struct {
+	NotificationType NotificationType `json:"notificationType"`
+	UserID           uuid.UUID        `json:"userId"`
+	SessionID        uuid.UUID        `json:"sessionId"`
+	Recipients       []string         `json:"recipients"`
+	NewDevice        bool             `json:"newDevice"`
+	NewLocation      bool             `json:"newLocation"`
+	IPAddress        string           `json:"ipAddress"`
+	UserAgent        string           `json:"userAgent"`
+	Country          string           `json:"country,omitempty"`
+	Region           string           `json:"region,omitempty"`
+	City             string           `json:"city,omitempty"`
+	LoggedInAt       time.Time        `json:"loggedInAt"`
+}
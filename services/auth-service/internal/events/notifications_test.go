@@ -0,0 +1,26 @@
+package events
+
+import "testing"
+
+// Notification types are part of the event contract; renaming one breaks
+// the notification service's template mapping
+func TestNotificationTypesAreStable(t *testing.T) {
+	for notification, want := range map[NotificationType]string{
+		NotificationWelcome:                   "welcome",
+		NotificationEmailVerification:         "email_verification",
+		NotificationVerificationReminder:      "verification_reminder",
+		NotificationEmailChangeConfirmation:   "email_change_confirmation",
+		NotificationEmailChangePending:        "email_change_pending",
+		NotificationEmailChanged:              "email_changed",
+		NotificationRecoveryEmailConfirmation: "recovery_email_confirmation",
+		NotificationRecoveryEmailChanged:      "recovery_email_changed",
+		NotificationPhoneVerification:         "phone_verification",
+		NotificationPasswordReset:             "password_reset",
+		NotificationSecurityChanged:           "security_changed",
+		NotificationNewSignIn:                 "new_sign_in",
+	} {
+		if string(notification) != want {
+			t.Errorf("notification type %q, want %q", notification, want)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// Exchange is the topic exchange all UniBazzar domain events are published to
+	Exchange = "unibazzar.events"
+	// Source identifies this service in published events
+	Source = "auth-service"
+	// Version is the schema version of events published by this service
+	Version = "1.1.0"
+)
+
+// Event types published by the auth service. Events that trigger a user
+// notification carry one of the typed payloads in notifications.go.
+const (
+	UserRegistered  = "user.registered"
+	UserUpdated     = "user.updated"
+	UserDeleted     = "user.deleted"
+	UserDeactivated = "user.deactivated"
+	UserReactivated = "user.reactivated"
+	UserRestored    = "user.restored"
+	UserRoleChanged = "user.role.changed"
+	// UserVerified is published when a user confirms their login email
+	UserVerified = "user.verified"
+	// UserMerged tells other services to re-own the source account's data
+	UserMerged = "user.merged"
+	// UserLoggedIn feeds analytics and is only published with analytics consent
+	UserLoggedIn = "user.logged_in"
+	// SessionEvicted records a session revoked because a login took the
+	// user past the cap on active sessions
+	SessionEvicted = "session.evicted"
+
+	EmailVerificationRequested = "user.email.verification_requested"
+	// EmailVerificationReminder re-sends a verification link to a user who
+	// has not used the previous ones
+	EmailVerificationReminder = "user.email.verification_reminder"
+
+	// EmailChangeRequested asks the new address to confirm an email change,
+	// EmailChangePending tells the current address about it, and EmailChanged
+	// is published once it is confirmed
+	EmailChangeRequested = "user.email.change_requested"
+	EmailChangePending   = "user.email.change_pending"
+	EmailChanged         = "user.email.changed"
+
+	RecoveryEmailConfirmationRequested = "user.recovery_email.confirmation_requested"
+	RecoveryEmailConfirmed             = "user.recovery_email.confirmed"
+
+	PhoneVerificationRequested = "user.phone.verification_requested"
+	PhoneVerified              = "user.phone.verified"
+
+	PasswordResetRequested = "password.reset.requested"
+
+	// UserSecurityChanged alerts the user to a change of their password,
+	// login or recovery email, phone or second factors
+	UserSecurityChanged = "user.security.changed"
+	// LoginSuspicious alerts the user to a login from a device or location
+	// they have not recently logged in from
+	LoginSuspicious = "login.suspicious"
+
+	// SystemTest is published on demand by operators to check the event pipeline
+	SystemTest = "system.test"
+)
+
+// DomainEvent is the envelope shared by every event on the bus (see ADR-0002)
+type DomainEvent struct {
+	EventID       string                 `json:"eventId"`
+	EventType     string                 `json:"eventType"`
+	EventVersion  string                 `json:"eventVersion"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Source        string                 `json:"source"`
+	CorrelationID string                 `json:"correlationId"`
+	CausationID   string                 `json:"causationId,omitempty"`
+	Data          interface{}            `json:"data"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// NewDomainEvent wraps data in a new event envelope
+func NewDomainEvent(eventType string, data interface{}) DomainEvent {
+	id := uuid.New().String()
+	return DomainEvent{
+		EventID:       id,
+		EventType:     eventType,
+		EventVersion:  Version,
+		Timestamp:     time.Now().UTC(),
+		Source:        Source,
+		CorrelationID: id,
+		Data:          data,
+	}
+}
+
+// WithUserID records the user the event is about in its metadata
+func (e DomainEvent) WithUserID(userID uuid.UUID) DomainEvent {
+	return e.withMetadata("userId", userID.String())
+}
+
+// withMetadata returns the event with key set in a copy of its metadata
+func (e DomainEvent) withMetadata(key string, value interface{}) DomainEvent {
+	metadata := make(map[string]interface{}, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	e.Metadata = metadata
+	return e
+}
+
+// UserID returns the user recorded by WithUserID
+func (e DomainEvent) UserID() (uuid.UUID, bool) {
+	value, ok := e.Metadata["userId"].(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(value)
+	return userID, err == nil
+}
+
+// Publisher publishes domain events to the message bus
+type Publisher interface {
+	Publish(ctx context.Context, event DomainEvent) error
+	Close() error
+}
+
+// Publish confirm failures. Either way the broker may not hold the event,
+// so callers should retry the publish.
+var (
+	ErrPublishNacked         = errors.New("broker rejected the event")
+	ErrPublishConfirmTimeout = errors.New("timed out waiting for the broker to confirm the event")
+)
+
+// ErrPublishBufferFull is returned while the broker is unreachable and no
+// more events can be held for when it is back
+var ErrPublishBufferFull = errors.New("broker is unreachable and the event buffer is full")
+
+// ErrBrokerUnavailable is returned by PublishUnbuffered while the broker is
+// unreachable
+var ErrBrokerUnavailable = errors.New("broker is unreachable")
+
+const (
+	// publishRetryBackoff is the wait before the first retry of a failed
+	// publish, doubling with every further retry
+	publishRetryBackoff = 100 * time.Millisecond
+	// reconnectMinBackoff and reconnectMaxBackoff bound the wait between
+	// attempts to reach a lost broker, doubling from one to the other
+	reconnectMinBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff = 30 * time.Second
+)
+
+// amqpChannel is the part of *amqp.Channel the publisher uses
+type amqpChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// PublisherConfig tunes how a RabbitMQPublisher rides out broker outages
+type PublisherConfig struct {
+	// ConfirmTimeout bounds the wait for the broker to acknowledge an event
+	ConfirmTimeout time.Duration
+	// PublishRetries is how often a failed publish is retried, with
+	// exponential backoff, before Publish fails
+	PublishRetries int
+	// BufferSize bounds the events held while the broker is unreachable
+	BufferSize int
+	// UnhealthyAfter is how long the broker may be unreachable before
+	// HealthCheck fails
+	UnhealthyAfter time.Duration
+}
+
+// RabbitMQPublisher publishes events to a RabbitMQ topic exchange. The
+// channel runs in confirm mode: a publish only succeeds once the broker has
+// acknowledged the event.
+//
+// When the connection or channel closes, the publisher reconnects in the
+// background with exponential backoff. Events published meanwhile are held
+// in a bounded buffer and sent, in order, once the broker is back. Delivery
+// is at least once: an event whose confirm timed out may be sent again, so
+// consumers dedupe by message ID, the event ID.
+type RabbitMQPublisher struct {
+	url    string
+	config PublisherConfig
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel amqpChannel
+	// confirms and deliveryTag belong to the current channel; the broker
+	// numbers confirms from 1 in publish order
+	confirms    <-chan amqp.Confirmation
+	deliveryTag uint64
+	// disconnectedAt is when the broker was lost, zero while connected
+	disconnectedAt time.Time
+	// buffer holds the events published while disconnected, oldest first
+	buffer []amqp.Publishing
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRabbitMQPublisher connects to RabbitMQ, declares the events exchange and
+// enables publisher confirms. The broker must be reachable at startup; later
+// outages are ridden out as config allows.
+func NewRabbitMQPublisher(url string, config PublisherConfig) (*RabbitMQPublisher, error) {
+	p := &RabbitMQPublisher{url: url, config: config, done: make(chan struct{})}
+
+	connClosed, channelClosed, err := p.connect()
+	if err != nil {
+		return nil, err
+	}
+	go p.watch(connClosed, channelClosed)
+	return p, nil
+}
+
+// connect opens a connection and a confirming channel, makes them current
+// and sends the buffered events. It returns the notifications of either
+// closing.
+func (p *RabbitMQPublisher) connect() (<-chan *amqp.Error, <-chan *amqp.Error, error) {
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := channel.ExchangeDeclare(Exchange, "topic", true, false, false, false, nil); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conn = conn
+	p.channel = channel
+	p.confirms = confirms
+	p.deliveryTag = 0
+	p.disconnectedAt = time.Time{}
+	// flushing under the lock keeps newer events behind the buffered ones
+	p.flushLocked()
+	return connClosed, channelClosed, nil
+}
+
+// watch waits for the connection or channel to close and reconnects,
+// until the publisher is closed
+func (p *RabbitMQPublisher) watch(connClosed, channelClosed <-chan *amqp.Error) {
+	for {
+		var reason *amqp.Error
+		select {
+		case <-p.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-channelClosed:
+		}
+		p.disconnect(reason)
+
+		var ok bool
+		if connClosed, channelClosed, ok = p.reconnect(); !ok {
+			return
+		}
+	}
+}
+
+// disconnect drops the current connection, so events are buffered until
+// reconnect replaces it
+func (p *RabbitMQPublisher) disconnect(reason *amqp.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.channel == nil {
+		return
+	}
+	log.Printf("RabbitMQ connection lost, buffering events until it is back: %v", reason)
+	p.dropLocked()
+}
+
+// reconnect dials the broker with exponential backoff until it succeeds or
+// the publisher is closed
+func (p *RabbitMQPublisher) reconnect() (<-chan *amqp.Error, <-chan *amqp.Error, bool) {
+	backoff := reconnectMinBackoff
+	for {
+		select {
+		case <-p.done:
+			return nil, nil, false
+		case <-time.After(backoff):
+		}
+
+		connClosed, channelClosed, err := p.connect()
+		if err == nil {
+			log.Printf("RabbitMQ connection restored")
+			return connClosed, channelClosed, true
+		}
+		log.Printf("RabbitMQ reconnect failed, retrying in %s: %v", backoff, err)
+
+		if backoff *= 2; backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// dropLocked closes the current connection and marks the broker lost
+func (p *RabbitMQPublisher) dropLocked() {
+	p.channel.Close()
+	p.conn.Close()
+	p.channel = nil
+	p.conn = nil
+	p.confirms = nil
+	p.disconnectedAt = time.Now()
+}
+
+// flushLocked sends the buffered events. A failure leaves the rest buffered
+// and drops the connection, so the next reconnect sends them again.
+func (p *RabbitMQPublisher) flushLocked() {
+	sent := 0
+	for len(p.buffer) > 0 {
+		if err := p.publishLocked(context.Background(), p.buffer[0]); err != nil {
+			log.Printf("Failed to send buffered %s event, %d left buffered: %v", p.buffer[0].Type, len(p.buffer), err)
+			p.dropLocked()
+			break
+		}
+		p.buffer = p.buffer[1:]
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("Sent %d events buffered while RabbitMQ was unreachable", sent)
+	}
+}
+
+// Publish sends the event to the exchange using its type as routing key and
+// waits for the broker to confirm it. A failed publish is retried with
+// exponential backoff; Publish fails once the retries are used up. While the
+// broker is unreachable the event is buffered instead, failing only when
+// the buffer is full.
+func (p *RabbitMQPublisher) Publish(ctx context.Context, event DomainEvent) error {
+	return p.publish(ctx, event, true)
+}
+
+// PublishUnbuffered is Publish for callers that keep the event until it is
+// confirmed, such as the outbox: it fails with ErrBrokerUnavailable while the
+// broker is unreachable instead of buffering the event in memory.
+func (p *RabbitMQPublisher) PublishUnbuffered(ctx context.Context, event DomainEvent) error {
+	return p.publish(ctx, event, false)
+}
+
+func (p *RabbitMQPublisher) publish(ctx context.Context, event DomainEvent, buffer bool) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	msg := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.EventID,
+		Timestamp:    event.Timestamp,
+		Type:         event.EventType,
+		Body:         body,
+	}
+	if requestID := event.requestID(ctx); requestID != "" {
+		msg.Headers = amqp.Table{RequestIDHeader: requestID}
+	}
+
+	backoff := publishRetryBackoff
+	for attempt := 0; ; attempt++ {
+		p.mu.Lock()
+		if p.channel == nil {
+			err = ErrBrokerUnavailable
+			if buffer {
+				err = p.bufferLocked(msg)
+			}
+			p.mu.Unlock()
+			if err != nil {
+				return fmt.Errorf("failed to publish %s: %w", event.EventType, err)
+			}
+			return nil
+		}
+		err = p.publishLocked(ctx, msg)
+		p.mu.Unlock()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= p.config.PublishRetries {
+			return fmt.Errorf("failed to publish %s after %d attempts: %w", event.EventType, attempt+1, err)
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return fmt.Errorf("failed to publish %s: %w", event.EventType, ctx.Err())
+		}
+	}
+}
+
+// bufferLocked holds an event until the broker is back
+func (p *RabbitMQPublisher) bufferLocked(msg amqp.Publishing) error {
+	if len(p.buffer) >= p.config.BufferSize {
+		return ErrPublishBufferFull
+	}
+	p.buffer = append(p.buffer, msg)
+	return nil
+}
+
+// publishLocked sends one event on the current channel and waits for its confirm
+func (p *RabbitMQPublisher) publishLocked(ctx context.Context, msg amqp.Publishing) error {
+	if err := p.channel.Publish(Exchange, msg.Type, false, false, msg); err != nil {
+		return err
+	}
+	p.deliveryTag++
+	return p.awaitConfirm(ctx, p.deliveryTag)
+}
+
+// awaitConfirm waits for the confirm of the given delivery tag. Late confirms
+// of earlier publishes that already timed out are skipped.
+func (p *RabbitMQPublisher) awaitConfirm(ctx context.Context, tag uint64) error {
+	timer := time.NewTimer(p.config.ConfirmTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-p.confirms:
+			if !ok {
+				return errors.New("channel closed before the broker confirmed the event")
+			}
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+			if !confirm.Ack {
+				return ErrPublishNacked
+			}
+			return nil
+		case <-timer.C:
+			return ErrPublishConfirmTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// HealthCheck fails once the broker has been unreachable for longer than
+// UnhealthyAfter; shorter outages are covered by the buffer
+func (p *RabbitMQPublisher) HealthCheck() error {
+	p.mu.Lock()
+	disconnectedAt, buffered := p.disconnectedAt, len(p.buffer)
+	p.mu.Unlock()
+
+	if disconnectedAt.IsZero() {
+		return nil
+	}
+	if down := time.Since(disconnectedAt); down > p.config.UnhealthyAfter {
+		return fmt.Errorf("RabbitMQ unreachable for %s, %d events buffered", down.Round(time.Second), buffered)
+	}
+	return nil
+}
+
+// Close stops reconnecting and closes the channel and the underlying
+// connection. Events still buffered are lost.
+func (p *RabbitMQPublisher) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buffer) > 0 {
+		log.Printf("Dropping %d events buffered while RabbitMQ was unreachable", len(p.buffer))
+		p.buffer = nil
+	}
+	if p.channel == nil {
+		return nil
+	}
+
+	err := p.channel.Close()
+	if connErr := p.conn.Close(); err == nil {
+		err = connErr
+	}
+	p.channel = nil
+	p.conn = nil
+	return err
+}
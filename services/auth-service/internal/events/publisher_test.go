@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// confirmReply is how fakeChannel answers a publish
+type confirmReply int
+
+const (
+	replyAck confirmReply = iota
+	replyNack
+	replyNone
+)
+
+// fakeChannel stands in for a confirming *amqp.Channel. Each publish is
+// answered by the next reply, acking when none are left.
+type fakeChannel struct {
+	confirms   chan amqp.Confirmation
+	replies    []confirmReply
+	publishErr error
+	published  []amqp.Publishing
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.publishErr != nil {
+		return c.publishErr
+	}
+	c.published = append(c.published, msg)
+	reply := replyAck
+	if len(c.replies) > 0 {
+		reply, c.replies = c.replies[0], c.replies[1:]
+	}
+	if reply != replyNone {
+		c.confirms <- amqp.Confirmation{DeliveryTag: uint64(len(c.published)), Ack: reply == replyAck}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+func newTestPublisher(retries int, replies ...confirmReply) (*RabbitMQPublisher, *fakeChannel) {
+	channel := &fakeChannel{confirms: make(chan amqp.Confirmation, 8), replies: replies}
+	p := &RabbitMQPublisher{
+		config: PublisherConfig{
+			ConfirmTimeout: 20 * time.Millisecond,
+			PublishRetries: retries,
+			BufferSize:     2,
+		},
+		channel:  channel,
+		confirms: channel.confirms,
+		done:     make(chan struct{}),
+	}
+	return p, channel
+}
+
+func TestPublishWaitsForAck(t *testing.T) {
+	p, channel := newTestPublisher(0, replyAck)
+	event := NewDomainEvent(UserRegistered, map[string]string{"email": "a@example.com"})
+
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(channel.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(channel.published))
+	}
+	msg := channel.published[0]
+	if msg.Type != UserRegistered || msg.MessageId != event.EventID || msg.DeliveryMode != amqp.Persistent {
+		t.Errorf("message type %q, id %q, mode %d", msg.Type, msg.MessageId, msg.DeliveryMode)
+	}
+}
+
+func TestPublishFailsOnNack(t *testing.T) {
+	p, _ := newTestPublisher(0, replyNack)
+
+	err := p.Publish(context.Background(), NewDomainEvent(UserRegistered, nil))
+	if !errors.Is(err, ErrPublishNacked) {
+		t.Fatalf("Publish = %v, want ErrPublishNacked", err)
+	}
+}
+
+func TestPublishFailsWithoutConfirm(t *testing.T) {
+	p, _ := newTestPublisher(0, replyNone)
+
+	err := p.Publish(context.Background(), NewDomainEvent(UserRegistered, nil))
+	if !errors.Is(err, ErrPublishConfirmTimeout) {
+		t.Fatalf("Publish = %v, want ErrPublishConfirmTimeout", err)
+	}
+}
+
+func TestPublishFailsWhenChannelRejects(t *testing.T) {
+	p, channel := newTestPublisher(0)
+	channel.publishErr = amqp.ErrClosed
+
+	err := p.Publish(context.Background(), NewDomainEvent(UserRegistered, nil))
+	if !errors.Is(err, amqp.ErrClosed) {
+		t.Fatalf("Publish = %v, want the channel error", err)
+	}
+}
+
+func TestPublishRetriesAfterNack(t *testing.T) {
+	p, channel := newTestPublisher(1, replyNack, replyAck)
+
+	if err := p.Publish(context.Background(), NewDomainEvent(UserRegistered, nil)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(channel.published) != 2 {
+		t.Errorf("published %d times, want 2", len(channel.published))
+	}
+}
+
+func TestPublishSkipsLateConfirm(t *testing.T) {
+	p, channel := newTestPublisher(0, replyNone, replyAck)
+
+	if err := p.Publish(context.Background(), NewDomainEvent(UserRegistered, nil)); !errors.Is(err, ErrPublishConfirmTimeout) {
+		t.Fatalf("first Publish = %v, want ErrPublishConfirmTimeout", err)
+	}
+	// the broker rejects the timed-out event only after it was given up on
+	channel.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+
+	if err := p.Publish(context.Background(), NewDomainEvent(UserUpdated, nil)); err != nil {
+		t.Fatalf("second Publish = %v, the late nack must not count for it", err)
+	}
+}
+
+func TestPublishStopsWhenContextEnds(t *testing.T) {
+	p, _ := newTestPublisher(0, replyNone)
+	p.config.ConfirmTimeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := p.Publish(ctx, NewDomainEvent(UserRegistered, nil))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Publish = %v, want context.Canceled", err)
+	}
+}
+
+func TestPublishBuffersWhileDisconnected(t *testing.T) {
+	p, channel := newTestPublisher(0)
+	p.channel, p.confirms = nil, nil
+	ctx := context.Background()
+
+	for _, eventType := range []string{UserRegistered, UserUpdated} {
+		if err := p.Publish(ctx, NewDomainEvent(eventType, nil)); err != nil {
+			t.Fatalf("Publish %s while disconnected: %v", eventType, err)
+		}
+	}
+	if err := p.Publish(ctx, NewDomainEvent(UserDeleted, nil)); !errors.Is(err, ErrPublishBufferFull) {
+		t.Fatalf("Publish past the buffer = %v, want ErrPublishBufferFull", err)
+	}
+	if err := p.PublishUnbuffered(ctx, NewDomainEvent(UserDeleted, nil)); !errors.Is(err, ErrBrokerUnavailable) {
+		t.Fatalf("PublishUnbuffered = %v, want ErrBrokerUnavailable", err)
+	}
+
+	// once the broker is back the buffered events go out in order
+	p.channel, p.confirms = channel, channel.confirms
+	p.flushLocked()
+	if len(p.buffer) != 0 || len(channel.published) != 2 {
+		t.Fatalf("%d events left buffered, %d published", len(p.buffer), len(channel.published))
+	}
+	if channel.published[0].Type != UserRegistered || channel.published[1].Type != UserUpdated {
+		t.Errorf("flushed %s then %s", channel.published[0].Type, channel.published[1].Type)
+	}
+}
+
+func TestHealthCheckToleratesShortOutages(t *testing.T) {
+	p, _ := newTestPublisher(0)
+	p.config.UnhealthyAfter = time.Minute
+
+	p.disconnectedAt = time.Now()
+	if err := p.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck right after the broker was lost: %v", err)
+	}
+	p.disconnectedAt = time.Now().Add(-2 * time.Minute)
+	if err := p.HealthCheck(); err == nil {
+		t.Error("HealthCheck passed after a long outage")
+	}
+}
@@ -0,0 +1,39 @@
+package events
+
+import "context"
+
+// RequestIDHeader names the ID of the request that caused an event, both as
+// the HTTP header clients send and as the AMQP header of published events
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns ctx carrying the ID of the request it serves
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithRequestID records the request ID of ctx in the event's metadata, so
+// events stored and published later, such as through the outbox, keep it
+func (e DomainEvent) WithRequestID(ctx context.Context) DomainEvent {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		return e
+	}
+	return e.withMetadata("requestId", requestID)
+}
+
+// requestID returns the request ID recorded by WithRequestID, falling back
+// to the one of ctx
+func (e DomainEvent) requestID(ctx context.Context) string {
+	if requestID, ok := e.Metadata["requestId"].(string); ok {
+		return requestID
+	}
+	return RequestIDFromContext(ctx)
+}
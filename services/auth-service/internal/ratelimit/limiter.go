@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Window, refilled continuously (token bucket)
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Result describes the outcome of a rate-limit check
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again; only set by Peek
+	ResetAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key may proceed under limit
+type Limiter interface {
+	Allow(key string, limit Limit) Result
+	// Peek reports the bucket of key as Allow would see it, without
+	// consuming a token
+	Peek(key string, limit Limit) Result
+}
+
+// peekResult describes a bucket holding tokens, refilled at rate tokens a second
+func peekResult(tokens float64, limit Limit, rate float64) Result {
+	result := Result{
+		Allowed:    tokens >= 1,
+		Limit:      limit.Requests,
+		Remaining:  int(tokens),
+		ResetAfter: time.Duration((float64(limit.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+	// window is that of the limit the bucket was last checked against; once
+	// idle for that long the bucket is full again
+	window time.Duration
+}
+
+// MemoryLimiter is an in-process token-bucket limiter
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryLimiter creates an empty in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow consumes one token from the bucket of key if one is available
+func (l *MemoryLimiter) Allow(key string, limit Limit) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Window.Seconds()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, lastSeen: now, window: limit.Window}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.lastSeen).Seconds()*rate)
+		b.lastSeen = now
+		b.window = limit.Window
+	}
+
+	l.sweep(now, limit.Window)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return Result{Allowed: false, Limit: limit.Requests, Remaining: 0, RetryAfter: wait}
+	}
+
+	b.tokens--
+	return Result{Allowed: true, Limit: limit.Requests, Remaining: int(b.tokens)}
+}
+
+// Peek reports the bucket of key without consuming a token
+func (l *MemoryLimiter) Peek(key string, limit Limit) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Window.Seconds()
+
+	tokens := capacity
+	if b, ok := l.buckets[key]; ok {
+		tokens = math.Min(capacity, b.tokens+l.now().Sub(b.lastSeen).Seconds()*rate)
+	}
+	return peekResult(tokens, limit, rate)
+}
+
+// sweep drops buckets idle for longer than their own window; an idle bucket
+// is full again anyway. Limits of different windows share the limiter, so
+// the window of the caller only paces the sweeps.
+func (l *MemoryLimiter) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(l.lastSweep) < interval {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > b.window {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for MemoryLimiter
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+func newTestLimiter() (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	l := NewMemoryLimiter()
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+func TestMemoryLimiterAllowsUpToLimit(t *testing.T) {
+	l, _ := newTestLimiter()
+	limit := Limit{Requests: 3, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		result := l.Allow("ip:1", limit)
+		if !result.Allowed {
+			t.Fatalf("request %d was rejected", i+1)
+		}
+		if result.Remaining != 2-i {
+			t.Errorf("request %d: remaining = %d, want %d", i+1, result.Remaining, 2-i)
+		}
+	}
+
+	result := l.Allow("ip:1", limit)
+	if result.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+	if result.RetryAfter != 20*time.Second {
+		t.Errorf("retry after = %s, want 20s", result.RetryAfter)
+	}
+	if !l.Allow("ip:2", limit).Allowed {
+		t.Error("another key shares the exhausted bucket")
+	}
+}
+
+func TestMemoryLimiterRefills(t *testing.T) {
+	l, clock := newTestLimiter()
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	l.Allow("ip:1", limit)
+	l.Allow("ip:1", limit)
+	if l.Allow("ip:1", limit).Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+
+	clock.Advance(30 * time.Second)
+	if !l.Allow("ip:1", limit).Allowed {
+		t.Fatal("bucket did not refill one token after half a window")
+	}
+	if l.Allow("ip:1", limit).Allowed {
+		t.Fatal("bucket refilled more than one token")
+	}
+}
+
+func TestMemoryLimiterPeekDoesNotConsume(t *testing.T) {
+	l, _ := newTestLimiter()
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	if result := l.Peek("ip:1", limit); !result.Allowed || result.Remaining != 2 || result.ResetAfter != 0 {
+		t.Fatalf("peek of unknown key = %+v, want a full bucket", result)
+	}
+	l.Allow("ip:1", limit)
+	for i := 0; i < 2; i++ {
+		result := l.Peek("ip:1", limit)
+		if result.Remaining != 1 {
+			t.Fatalf("peek %d: remaining = %d, want 1", i+1, result.Remaining)
+		}
+		if result.ResetAfter != 30*time.Second {
+			t.Errorf("peek %d: reset after = %s, want 30s", i+1, result.ResetAfter)
+		}
+	}
+}
+
+// A caller with a short window must not sweep the buckets of a long one
+// before they have refilled
+func TestMemoryLimiterSweepKeepsLongWindowBuckets(t *testing.T) {
+	l, clock := newTestLimiter()
+	long := Limit{Requests: 1, Window: time.Hour}
+	short := Limit{Requests: 10, Window: time.Second}
+
+	if !l.Allow("login:a@example.com", long).Allowed {
+		t.Fatal("first request was rejected")
+	}
+
+	clock.Advance(time.Minute)
+	l.Allow("ip:1", short) // sweeps: a minute is past the short window
+
+	if _, ok := l.buckets["login:a@example.com"]; !ok {
+		t.Fatal("long window bucket was swept after a minute")
+	}
+	if l.Allow("login:a@example.com", long).Allowed {
+		t.Fatal("long window limit was reset by a short window caller")
+	}
+}
+
+func TestMemoryLimiterSweepDropsIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter()
+	limit := Limit{Requests: 1, Window: time.Minute}
+
+	l.Allow("ip:1", limit)
+	clock.Advance(2 * time.Minute)
+	l.Allow("ip:2", limit)
+
+	if _, ok := l.buckets["ip:1"]; ok {
+		t.Error("bucket idle for longer than its window was kept")
+	}
+	if _, ok := l.buckets["ip:2"]; !ok {
+		t.Error("bucket in use was swept")
+	}
+}
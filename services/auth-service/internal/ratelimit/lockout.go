@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockoutScope decides what failed login attempts are counted against
+type LockoutScope string
+
+// Lockout scopes
+const (
+	// ScopeAccount locks the account for everyone, which lets anyone who
+	// knows an email address lock its owner out
+	ScopeAccount LockoutScope = "account"
+	// ScopeIP locks the client address out of every account
+	ScopeIP LockoutScope = "ip"
+	// ScopeAccountIP locks one address out of one account: guessing is slowed
+	// down without locking the owner out from elsewhere
+	ScopeAccountIP LockoutScope = "account_ip"
+)
+
+// ParseLockoutScope checks that value names a lockout scope
+func ParseLockoutScope(value string) (LockoutScope, error) {
+	switch scope := LockoutScope(value); scope {
+	case ScopeAccount, ScopeIP, ScopeAccountIP:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown lockout scope %q", value)
+}
+
+// LockoutPolicy locks a scope out for Duration after MaxFailures failed
+// attempts within Window. A zero MaxFailures disables the lockout.
+type LockoutPolicy struct {
+	Scope       LockoutScope
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+type lockoutEntry struct {
+	failures    int
+	firstFailed time.Time
+	lockedUntil time.Time
+}
+
+// Lockout is an in-process tracker of failed login attempts
+type Lockout struct {
+	mu        sync.Mutex
+	policy    LockoutPolicy
+	entries   map[string]*lockoutEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLockout creates an empty failed-attempt tracker
+func NewLockout(policy LockoutPolicy) *Lockout {
+	return &Lockout{
+		policy:    policy,
+		entries:   make(map[string]*lockoutEntry),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// key returns what the attempt counts against under the configured scope
+func (l *Lockout) key(account, ip string) string {
+	account = strings.ToLower(strings.TrimSpace(account))
+	switch l.policy.Scope {
+	case ScopeAccount:
+		return "account:" + account
+	case ScopeIP:
+		return "ip:" + ip
+	}
+	return "account_ip:" + account + "|" + ip
+}
+
+// Check reports whether attempts for the account from ip are locked out and
+// for how much longer
+func (l *Lockout) Check(account, ip string) (time.Duration, bool) {
+	if l == nil || l.policy.MaxFailures <= 0 {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[l.key(account, ip)]
+	if !ok {
+		return 0, false
+	}
+	if remaining := entry.lockedUntil.Sub(l.now()); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt and reports whether it triggered a lockout
+func (l *Lockout) Fail(account, ip string) bool {
+	if l == nil || l.policy.MaxFailures <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	key := l.key(account, ip)
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.firstFailed) > l.policy.Window {
+		entry = &lockoutEntry{firstFailed: now}
+		l.entries[key] = entry
+	}
+
+	entry.failures++
+	if entry.failures < l.policy.MaxFailures {
+		return false
+	}
+	entry.lockedUntil = now.Add(l.policy.Duration)
+	entry.failures = 0
+	entry.firstFailed = now
+	return true
+}
+
+// Reset forgets the failed attempts after a successful login
+func (l *Lockout) Reset(account, ip string) {
+	if l == nil || l.policy.MaxFailures <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := l.key(account, ip)
+	if entry, ok := l.entries[key]; ok && !l.now().Before(entry.lockedUntil) {
+		delete(l.entries, key)
+	}
+}
+
+// sweep drops entries whose window and lockout have both passed
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.policy.Window {
+		return
+	}
+	for key, entry := range l.entries {
+		if now.Sub(entry.firstFailed) > l.policy.Window && now.After(entry.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
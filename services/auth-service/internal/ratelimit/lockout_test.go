@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLockout(scope LockoutScope) (*Lockout, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	l := NewLockout(LockoutPolicy{Scope: scope, MaxFailures: 3, Window: time.Minute, Duration: 15 * time.Minute})
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+// failTimes records n failed attempts and reports whether the last locked out
+func failTimes(l *Lockout, n int, account, ip string) bool {
+	locked := false
+	for i := 0; i < n; i++ {
+		locked = l.Fail(account, ip)
+	}
+	return locked
+}
+
+func TestLockoutScopes(t *testing.T) {
+	const victim, attacker, other = "ada@example.edu", "203.0.113.7", "198.51.100.1"
+	tests := []struct {
+		scope LockoutScope
+		// whether the lockout of the victim's account from the attacker's
+		// address also stops the victim from their own address, and the
+		// attacker on another account
+		victimLocked, otherAccountLocked bool
+	}{
+		{ScopeAccount, true, false},
+		{ScopeIP, false, true},
+		{ScopeAccountIP, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			l, _ := newTestLockout(tt.scope)
+			if !failTimes(l, 3, victim, attacker) {
+				t.Fatal("third failure did not lock out")
+			}
+
+			if _, locked := l.Check(victim, attacker); !locked {
+				t.Error("attacker not locked out of the account")
+			}
+			if _, locked := l.Check(victim, other); locked != tt.victimLocked {
+				t.Errorf("victim from their own address locked = %v, want %v", locked, tt.victimLocked)
+			}
+			if _, locked := l.Check("alan@example.edu", attacker); locked != tt.otherAccountLocked {
+				t.Errorf("attacker on another account locked = %v, want %v", locked, tt.otherAccountLocked)
+			}
+		})
+	}
+}
+
+func TestLockoutMatchesAccountIgnoringCase(t *testing.T) {
+	l, _ := newTestLockout(ScopeAccount)
+	failTimes(l, 3, " Ada@Example.edu", "203.0.113.7")
+
+	if _, locked := l.Check("ada@example.edu", "198.51.100.1"); !locked {
+		t.Error("account locked under another spelling of its email")
+	}
+}
+
+func TestLockoutExpires(t *testing.T) {
+	l, clock := newTestLockout(ScopeAccountIP)
+	failTimes(l, 3, "ada@example.edu", "203.0.113.7")
+
+	clock.Advance(10 * time.Minute)
+	if remaining, locked := l.Check("ada@example.edu", "203.0.113.7"); !locked || remaining != 5*time.Minute {
+		t.Errorf("Check = %s, %v; want locked for 5m more", remaining, locked)
+	}
+	clock.Advance(5 * time.Minute)
+	if _, locked := l.Check("ada@example.edu", "203.0.113.7"); locked {
+		t.Error("still locked after the lockout duration")
+	}
+}
+
+func TestLockoutCountsWithinWindow(t *testing.T) {
+	l, clock := newTestLockout(ScopeAccountIP)
+	failTimes(l, 2, "ada@example.edu", "203.0.113.7")
+
+	clock.Advance(2 * time.Minute)
+	if l.Fail("ada@example.edu", "203.0.113.7") {
+		t.Error("failures outside the window added up to a lockout")
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	l, _ := newTestLockout(ScopeAccountIP)
+	failTimes(l, 2, "ada@example.edu", "203.0.113.7")
+	l.Reset("ada@example.edu", "203.0.113.7")
+
+	if l.Fail("ada@example.edu", "203.0.113.7") {
+		t.Error("failures before a successful login still counted")
+	}
+
+	// a login made while locked out does not lift the lockout
+	failTimes(l, 2, "ada@example.edu", "203.0.113.7")
+	l.Reset("ada@example.edu", "203.0.113.7")
+	if _, locked := l.Check("ada@example.edu", "203.0.113.7"); !locked {
+		t.Error("reset lifted an active lockout")
+	}
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	var none *Lockout
+	if none.Fail("ada@example.edu", "203.0.113.7") {
+		t.Error("nil lockout locked out")
+	}
+	if _, locked := none.Check("ada@example.edu", "203.0.113.7"); locked {
+		t.Error("nil lockout reported a lockout")
+	}
+
+	l := NewLockout(LockoutPolicy{Scope: ScopeAccount})
+	if failTimes(l, 100, "ada@example.edu", "203.0.113.7") {
+		t.Error("lockout without MaxFailures locked out")
+	}
+}
+
+func TestParseLockoutScope(t *testing.T) {
+	for _, value := range []string{"account", "ip", "account_ip"} {
+		if scope, err := ParseLockoutScope(value); err != nil || string(scope) != value {
+			t.Errorf("ParseLockoutScope(%q) = %q, %v", value, scope, err)
+		}
+	}
+	if _, err := ParseLockoutScope("user"); err == nil {
+		t.Error("unknown scope accepted")
+	}
+}
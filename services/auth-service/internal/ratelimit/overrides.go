@@ -0,0 +1,32 @@
+package ratelimit
+
+// OverrideStore looks up a per-identity request allowance that replaces the
+// default tier. Identities are prefixed by kind, e.g. "user:<uuid>".
+type OverrideStore interface {
+	Lookup(identity string) (requests int, ok bool)
+}
+
+// StaticOverrides is an OverrideStore backed by a fixed map, typically loaded from config
+type StaticOverrides map[string]int
+
+// Lookup returns the configured allowance for identity
+func (o StaticOverrides) Lookup(identity string) (int, bool) {
+	requests, ok := o[identity]
+	return requests, ok
+}
+
+// Policy resolves the limit that applies to an identity
+type Policy struct {
+	Default   Limit
+	Overrides OverrideStore
+}
+
+// LimitFor returns the override for identity when one exists, the default tier otherwise
+func (p *Policy) LimitFor(identity string) Limit {
+	if p.Overrides != nil {
+		if requests, ok := p.Overrides.Lookup(identity); ok && requests > 0 {
+			return Limit{Requests: requests, Window: p.Default.Window}
+		}
+	}
+	return p.Default
+}
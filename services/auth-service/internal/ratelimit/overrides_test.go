@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyLimitFor(t *testing.T) {
+	policy := &Policy{
+		Default: Limit{Requests: 100, Window: time.Minute},
+		Overrides: StaticOverrides{
+			"user:vip":      1000,
+			"user:disabled": 0,
+		},
+	}
+
+	tests := []struct {
+		identity string
+		want     Limit
+	}{
+		{"user:vip", Limit{Requests: 1000, Window: time.Minute}},
+		{"user:other", Limit{Requests: 100, Window: time.Minute}},
+		{"ip:10.0.0.1", Limit{Requests: 100, Window: time.Minute}},
+		// a non-positive override is ignored rather than blocking everything
+		{"user:disabled", Limit{Requests: 100, Window: time.Minute}},
+	}
+	for _, tt := range tests {
+		if got := policy.LimitFor(tt.identity); got != tt.want {
+			t.Errorf("LimitFor(%q) = %+v, want %+v", tt.identity, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyWithoutOverrides(t *testing.T) {
+	policy := &Policy{Default: Limit{Requests: 5, Window: time.Second}}
+	if got := policy.LimitFor("user:any"); got != policy.Default {
+		t.Errorf("LimitFor = %+v, want the default tier", got)
+	}
+}
@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and consumes the bucket of KEYS[1] atomically on
+// the Redis server, using the server clock so every instance agrees on time.
+// ARGV holds the capacity, the refill rate per millisecond and the bucket TTL
+// in milliseconds. It returns whether the request is allowed and the tokens left.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+else
+	tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {allowed, tostring(tokens)}
+`)
+
+// peekBucketScript returns the tokens in the bucket of KEYS[1] as
+// tokenBucketScript would see them, without consuming one. ARGV holds the
+// capacity and the refill rate per millisecond.
+var peekBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	return tostring(capacity)
+end
+return tostring(math.min(capacity, tokens + math.max(0, now - ts) * rate))
+`)
+
+// redisPoolSize bounds the connections a RedisLimiter keeps open
+const redisPoolSize = 8
+
+// redisTimeout bounds a round trip to Redis; a slow Redis must not stall logins
+const redisTimeout = 250 * time.Millisecond
+
+// RedisLimiter is a token-bucket limiter whose buckets live in Redis, so every
+// instance of the service shares them. While Redis cannot be reached it falls
+// back to the in-process limiter, which still throttles per instance.
+type RedisLimiter struct {
+	client   *redis.Client
+	fallback Limiter
+	degraded atomic.Bool
+}
+
+// NewRedisLimiter creates a limiter for the Redis server at rawURL, e.g.
+// redis://localhost:6379/0. A password or database in the URL takes
+// precedence over the password and db given.
+func NewRedisLimiter(rawURL, password string, db int, fallback Limiter) (*RedisLimiter, error) {
+	options, err := RedisOptions(rawURL, password, db)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisLimiter{client: redis.NewClient(options), fallback: fallback}, nil
+}
+
+// RedisOptions returns the client options for the Redis server at rawURL,
+// bounded so a slow Redis answers with an error instead of stalling requests.
+// A password or database in the URL takes precedence over those given.
+func RedisOptions(rawURL, password string, db int) (*redis.Options, error) {
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL %q", rawURL)
+	}
+	if options.Password == "" {
+		options.Password = password
+	}
+	if parsed, err := url.Parse(rawURL); err == nil && strings.Trim(parsed.Path, "/") == "" {
+		options.DB = db
+	}
+
+	options.PoolSize = redisPoolSize
+	options.DialTimeout = redisTimeout
+	options.ReadTimeout = redisTimeout
+	options.WriteTimeout = redisTimeout
+	// a failed call returns at once rather than retrying against a slow Redis
+	options.MaxRetries = -1
+	return options, nil
+}
+
+// Close closes the connections to Redis
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
+
+// Allow consumes one token from the shared bucket of key if one is available
+func (l *RedisLimiter) Allow(key string, limit Limit) Result {
+	rate := float64(limit.Requests) / float64(limit.Window.Milliseconds())
+	reply, err := l.run(tokenBucketScript, key,
+		limit.Requests,
+		strconv.FormatFloat(rate, 'g', -1, 64),
+		limit.Window.Milliseconds(),
+	)
+	if err == nil {
+		var result Result
+		if result, err = bucketResult(reply, limit, rate); err == nil {
+			l.recovered()
+			return result
+		}
+	}
+
+	l.degrade(err)
+	return l.fallback.Allow(key, limit)
+}
+
+// Peek reports the shared bucket of key without consuming a token
+func (l *RedisLimiter) Peek(key string, limit Limit) Result {
+	rate := float64(limit.Requests) / float64(limit.Window.Milliseconds())
+	reply, err := l.run(peekBucketScript, key,
+		limit.Requests,
+		strconv.FormatFloat(rate, 'g', -1, 64),
+	)
+	if err == nil {
+		raw, _ := reply.(string)
+		var tokens float64
+		if tokens, err = strconv.ParseFloat(raw, 64); err == nil {
+			l.recovered()
+			return peekResult(tokens, limit, rate*1000)
+		}
+		err = fmt.Errorf("unexpected rate limit tokens %q", raw)
+	}
+
+	l.degrade(err)
+	return l.fallback.Peek(key, limit)
+}
+
+// run calls script on the bucket of key by its SHA with EVALSHA. The server
+// loads the script from an EVAL the first time it lacks it, e.g. after a
+// restart, and serves EVALSHA from then on.
+func (l *RedisLimiter) run(script *redis.Script, key string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return script.Run(ctx, l.client, []string{"ratelimit:" + key}, args...).Result()
+}
+
+// degrade logs the switch to the in-process limiter once per outage
+func (l *RedisLimiter) degrade(err error) {
+	if l.degraded.CompareAndSwap(false, true) {
+		log.Printf("Redis rate limiter at %s unavailable, limiting per instance: %v", l.client.Options().Addr, err)
+	}
+}
+
+// recovered logs the return to the shared buckets once per outage
+func (l *RedisLimiter) recovered() {
+	if l.degraded.CompareAndSwap(true, false) {
+		log.Printf("Redis rate limiter at %s recovered", l.client.Options().Addr)
+	}
+}
+
+// bucketResult reads the reply of tokenBucketScript
+func bucketResult(reply interface{}, limit Limit, rate float64) (Result, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	raw, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected rate limit tokens %q", raw)
+	}
+
+	if allowed != 1 {
+		wait := time.Duration((1 - tokens) / rate * float64(time.Millisecond))
+		return Result{Allowed: false, Limit: limit.Requests, Remaining: 0, RetryAfter: wait}, nil
+	}
+	return Result{Allowed: true, Limit: limit.Requests, Remaining: int(tokens)}, nil
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+
+	l, err := NewRedisLimiter("redis://"+server.Addr(), "", 0, NewMemoryLimiter())
+	if err != nil {
+		t.Fatalf("NewRedisLimiter: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, server
+}
+
+func TestRedisLimiterAllowsUpToLimit(t *testing.T) {
+	l, _ := newTestRedisLimiter(t)
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		result := l.Allow("ip:1", limit)
+		if !result.Allowed || result.Remaining != 1-i {
+			t.Fatalf("request %d = %+v", i+1, result)
+		}
+	}
+
+	result := l.Allow("ip:1", limit)
+	if result.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+	if result.RetryAfter != 30*time.Second {
+		t.Errorf("retry after = %s, want 30s", result.RetryAfter)
+	}
+	if l.degraded.Load() {
+		t.Error("limiter fell back with Redis available")
+	}
+}
+
+func TestRedisLimiterSharesBucketsAcrossInstances(t *testing.T) {
+	first, server := newTestRedisLimiter(t)
+	second, err := NewRedisLimiter("redis://"+server.Addr(), "", 0, NewMemoryLimiter())
+	if err != nil {
+		t.Fatalf("NewRedisLimiter: %v", err)
+	}
+	defer second.Close()
+	limit := Limit{Requests: 1, Window: time.Minute}
+
+	if !first.Allow("ip:1", limit).Allowed {
+		t.Fatal("first request was rejected")
+	}
+	if second.Allow("ip:1", limit).Allowed {
+		t.Fatal("second instance did not see the bucket of the first")
+	}
+}
+
+func TestRedisLimiterRefillsOnServerClock(t *testing.T) {
+	l, server := newTestRedisLimiter(t)
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	l.Allow("ip:1", limit)
+	l.Allow("ip:1", limit)
+	server.SetTime(time.Date(2026, 10, 17, 12, 0, 30, 0, time.UTC))
+
+	if !l.Allow("ip:1", limit).Allowed {
+		t.Fatal("bucket did not refill one token after half a window")
+	}
+	if l.Allow("ip:1", limit).Allowed {
+		t.Fatal("bucket refilled more than one token")
+	}
+}
+
+func TestRedisLimiterPeekDoesNotConsume(t *testing.T) {
+	l, _ := newTestRedisLimiter(t)
+	limit := Limit{Requests: 4, Window: time.Minute}
+
+	if result := l.Peek("ip:1", limit); result.Remaining != 4 {
+		t.Fatalf("peek of unknown key = %+v, want a full bucket", result)
+	}
+	l.Allow("ip:1", limit)
+	for i := 0; i < 2; i++ {
+		result := l.Peek("ip:1", limit)
+		if result.Remaining != 3 || result.ResetAfter != 15*time.Second {
+			t.Fatalf("peek %d = %+v, want 3 remaining and a reset after 15s", i+1, result)
+		}
+	}
+}
+
+func TestRedisLimiterCallsScriptsBySHA(t *testing.T) {
+	l, server := newTestRedisLimiter(t)
+	limit := Limit{Requests: 5, Window: time.Minute}
+
+	l.Allow("ip:1", limit)
+	l.Peek("ip:1", limit)
+
+	exists, err := l.client.ScriptExists(context.Background(), tokenBucketScript.Hash(), peekBucketScript.Hash()).Result()
+	if err != nil {
+		t.Fatalf("SCRIPT EXISTS: %v", err)
+	}
+	if !exists[0] || !exists[1] {
+		t.Fatalf("scripts not cached on the server: %v", exists)
+	}
+
+	// a flushed script cache is repopulated on the next call
+	server.FlushAll()
+	server.Close()
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if result := l.Allow("ip:1", limit); !result.Allowed || l.degraded.Load() {
+		t.Fatalf("Allow after restart = %+v, degraded %v", result, l.degraded.Load())
+	}
+}
+
+func TestRedisLimiterFallsBackWhileUnavailable(t *testing.T) {
+	l, server := newTestRedisLimiter(t)
+	limit := Limit{Requests: 1, Window: time.Minute}
+	server.Close()
+
+	if !l.Allow("ip:1", limit).Allowed {
+		t.Fatal("fallback rejected the first request")
+	}
+	if !l.degraded.Load() {
+		t.Fatal("limiter did not report the outage")
+	}
+	if l.Allow("ip:1", limit).Allowed {
+		t.Fatal("fallback does not limit per instance")
+	}
+	if result := l.Peek("ip:1", limit); result.Allowed {
+		t.Fatalf("fallback peek = %+v, want the exhausted local bucket", result)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if !l.Allow("ip:1", limit).Allowed {
+		t.Fatal("shared bucket not used once Redis is back")
+	}
+	if l.degraded.Load() {
+		t.Error("limiter did not recover")
+	}
+}
+
+func TestNewRedisLimiterOptions(t *testing.T) {
+	tests := []struct {
+		url      string
+		password string
+		db       int
+		wantAddr string
+		wantPass string
+		wantDB   int
+	}{
+		{"redis://cache:6380", "secret", 2, "cache:6380", "secret", 2},
+		{"redis://:fromurl@cache:6380/5", "secret", 2, "cache:6380", "fromurl", 5},
+		{"redis://cache", "", 0, "cache:6379", "", 0},
+	}
+	for _, tt := range tests {
+		l, err := NewRedisLimiter(tt.url, tt.password, tt.db, NewMemoryLimiter())
+		if err != nil {
+			t.Fatalf("NewRedisLimiter(%q): %v", tt.url, err)
+		}
+		options := l.client.Options()
+		if options.Addr != tt.wantAddr || options.Password != tt.wantPass || options.DB != tt.wantDB {
+			t.Errorf("NewRedisLimiter(%q) = addr %s password %q db %d", tt.url, options.Addr, options.Password, options.DB)
+		}
+		l.Close()
+	}
+
+	if _, err := NewRedisLimiter("http://cache:6379", "", 0, NewMemoryLimiter()); err == nil {
+		t.Error("accepted a URL that is not redis://")
+	}
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const accessTokenColumns = `token_hash, session_id, claims, expires_at, created_at`
+
+// PostgresAccessTokenRepo implements domain.AccessTokenRepository on top of PostgreSQL
+type PostgresAccessTokenRepo struct {
+	db dbtx
+}
+
+// NewPostgresAccessTokenRepo creates a new PostgreSQL backed opaque access token repository
+func NewPostgresAccessTokenRepo(db *sql.DB) *PostgresAccessTokenRepo {
+	return &PostgresAccessTokenRepo{db: db}
+}
+
+func scanAccessToken(s scanner) (*domain.AccessToken, error) {
+	var token domain.AccessToken
+	err := s.Scan(
+		&token.TokenHash,
+		&token.SessionID,
+		&token.Claims,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Create inserts a new opaque access token
+func (r *PostgresAccessTokenRepo) Create(token *domain.AccessToken) error {
+	query := `INSERT INTO access_tokens (` + accessTokenColumns + `) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.Exec(query,
+		token.TokenHash,
+		token.SessionID,
+		token.Claims,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+	return nil
+}
+
+// GetActive fetches an unexpired token whose session has not been revoked
+func (r *PostgresAccessTokenRepo) GetActive(tokenHash string, now time.Time) (*domain.AccessToken, error) {
+	query := `SELECT t.token_hash, t.session_id, t.claims, t.expires_at, t.created_at
+		FROM access_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1 AND t.expires_at > $2 AND s.is_revoked = FALSE`
+	return scanAccessToken(r.db.QueryRow(query, tokenHash, now))
+}
+
+// DeleteExpired removes the tokens that expired before the given time
+func (r *PostgresAccessTokenRepo) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM access_tokens WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired access tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// PostgresAppAuthorizationRepo implements domain.AppAuthorizationRepository on top of PostgreSQL
+type PostgresAppAuthorizationRepo struct {
+	db dbtx
+}
+
+// NewPostgresAppAuthorizationRepo creates a new PostgreSQL backed app authorization repository
+func NewPostgresAppAuthorizationRepo(db *sql.DB) *PostgresAppAuthorizationRepo {
+	return &PostgresAppAuthorizationRepo{db: db}
+}
+
+// Grant inserts the authorization, or merges its scopes into the existing one
+func (r *PostgresAppAuthorizationRepo) Grant(authorization *domain.AppAuthorization) error {
+	query := `INSERT INTO app_authorizations (user_id, client_id, scopes, granted_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET
+			scopes = ARRAY(SELECT DISTINCT unnest(app_authorizations.scopes || EXCLUDED.scopes)),
+			last_used_at = EXCLUDED.last_used_at`
+
+	_, err := r.db.Exec(query,
+		authorization.UserID,
+		authorization.ClientID,
+		pq.Array(authorization.Scopes),
+		authorization.GrantedAt,
+		authorization.LastUsedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to grant app authorization: %w", err)
+	}
+	return nil
+}
+
+// Touch updates when the authorization was last used
+func (r *PostgresAppAuthorizationRepo) Touch(userID uuid.UUID, clientID string, at time.Time) error {
+	query := `UPDATE app_authorizations SET last_used_at = $3 WHERE user_id = $1 AND client_id = $2`
+
+	if _, err := r.db.Exec(query, userID, clientID, at); err != nil {
+		return fmt.Errorf("failed to touch app authorization: %w", err)
+	}
+	return nil
+}
+
+// ListByUserID returns the user's authorizations of registered clients,
+// most recently used first
+func (r *PostgresAppAuthorizationRepo) ListByUserID(userID uuid.UUID) ([]*domain.AppAuthorization, error) {
+	query := `SELECT a.user_id, a.client_id, c.name, a.scopes, a.granted_at, a.last_used_at
+		FROM app_authorizations a
+		JOIN oauth_clients c ON c.client_id = a.client_id
+		WHERE a.user_id = $1
+		ORDER BY a.last_used_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list app authorizations: %w", err)
+	}
+	defer rows.Close()
+
+	var authorizations []*domain.AppAuthorization
+	for rows.Next() {
+		var authorization domain.AppAuthorization
+		err := rows.Scan(
+			&authorization.UserID,
+			&authorization.ClientID,
+			&authorization.ClientName,
+			pq.Array(&authorization.Scopes),
+			&authorization.GrantedAt,
+			&authorization.LastUsedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app authorization: %w", err)
+		}
+		authorizations = append(authorizations, &authorization)
+	}
+	return authorizations, rows.Err()
+}
+
+// Delete removes the user's authorization of the client
+func (r *PostgresAppAuthorizationRepo) Delete(userID uuid.UUID, clientID string) error {
+	result, err := r.db.Exec(`DELETE FROM app_authorizations WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete app authorization: %w", err)
+	}
+	return expectRows(result)
+}
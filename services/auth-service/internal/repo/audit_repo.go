@@ -0,0 +1,172 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const auditColumns = `id, user_id, action, ip_address, user_agent, metadata, created_at, seq, prev_hash, hash`
+
+// auditChainLock is the advisory lock key serializing appends to the audit
+// chain, so every entry links to the one committed before it
+const auditChainLock = 0x61756469 // "audi"
+
+// PostgresAuditRepo implements domain.AuditRepository on top of PostgreSQL
+type PostgresAuditRepo struct {
+	db dbtx
+	// pool starts the transaction appends run in; nil when db already is one
+	pool *sql.DB
+}
+
+// NewPostgresAuditRepo creates a new PostgreSQL backed audit repository
+func NewPostgresAuditRepo(db *sql.DB) *PostgresAuditRepo {
+	return &PostgresAuditRepo{db: db, pool: db}
+}
+
+func scanAuditLog(s scanner) (*domain.AuditLog, error) {
+	var entry domain.AuditLog
+	err := s.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.Action,
+		&entry.IPAddress,
+		&entry.UserAgent,
+		&entry.Metadata,
+		&entry.CreatedAt,
+		&entry.Sequence,
+		&entry.PrevHash,
+		&entry.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Create links the entry after the newest one and appends it. Appends are
+// serialized by an advisory lock held until their transaction ends.
+func (r *PostgresAuditRepo) Create(entry *domain.AuditLog) error {
+	if r.pool == nil {
+		return r.append(r.db, entry)
+	}
+
+	tx, err := r.pool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := r.append(tx, entry); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit entry: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresAuditRepo) append(db dbtx, entry *domain.AuditLog) error {
+	if _, err := db.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	prev, err := scanAuditLog(db.QueryRow(`SELECT ` + auditColumns + ` FROM audit_logs
+		WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get last audit entry: %w", err)
+	}
+	if err := entry.Link(prev); err != nil {
+		return fmt.Errorf("failed to seal audit entry: %w", err)
+	}
+
+	query := `INSERT INTO audit_logs (` + auditColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err = db.Exec(query,
+		entry.ID,
+		entry.UserID,
+		entry.Action,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.Metadata,
+		entry.CreatedAt,
+		entry.Sequence,
+		entry.PrevHash,
+		entry.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetBySequence fetches the entry at the given position of the chain
+func (r *PostgresAuditRepo) GetBySequence(seq int64) (*domain.AuditLog, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_logs WHERE seq = $1`
+	return scanAuditLog(r.db.QueryRow(query, seq))
+}
+
+// ListChain returns up to limit entries with a sequence in [from, to], in chain order
+func (r *PostgresAuditRepo) ListChain(from, to int64, limit int) ([]*domain.AuditLog, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_logs
+		WHERE seq >= $1 AND seq <= $2
+		ORDER BY seq
+		LIMIT $3`
+
+	rows, err := r.db.Query(query, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*domain.AuditLog
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// LastSequence returns the sequence of the newest entry, 0 when the chain is empty
+func (r *PostgresAuditRepo) LastSequence() (int64, error) {
+	var seq int64
+	if err := r.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM audit_logs`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to get last audit sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// ListByUser returns a page of the user's entries, newest first, with the
+// total number of them
+func (r *PostgresAuditRepo) ListByUser(userID uuid.UUID, limit, offset int) ([]*domain.AuditLog, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_logs WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_logs
+		WHERE user_id = $1
+		ORDER BY created_at DESC, seq DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*domain.AuditLog
+	for rows.Next() {
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
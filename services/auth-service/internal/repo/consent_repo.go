@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const consentColumns = `id, user_id, purpose, granted, text_version, ip_address, recorded_at`
+
+// PostgresConsentRepo implements domain.ConsentRepository on top of PostgreSQL
+type PostgresConsentRepo struct {
+	db dbtx
+}
+
+// NewPostgresConsentRepo creates a new PostgreSQL backed consent repository
+func NewPostgresConsentRepo(db *sql.DB) *PostgresConsentRepo {
+	return &PostgresConsentRepo{db: db}
+}
+
+func scanConsent(s scanner) (*domain.Consent, error) {
+	var consent domain.Consent
+	err := s.Scan(
+		&consent.ID,
+		&consent.UserID,
+		&consent.Purpose,
+		&consent.Granted,
+		&consent.TextVersion,
+		&consent.IPAddress,
+		&consent.RecordedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// Create inserts a new consent record
+func (r *PostgresConsentRepo) Create(consent *domain.Consent) error {
+	query := `INSERT INTO consents (` + consentColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(query,
+		consent.ID,
+		consent.UserID,
+		consent.Purpose,
+		consent.Granted,
+		consent.TextVersion,
+		consent.IPAddress,
+		consent.RecordedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create consent: %w", err)
+	}
+	return nil
+}
+
+// ListByUserID returns the full consent history of a user, newest first
+func (r *PostgresConsentRepo) ListByUserID(userID uuid.UUID) ([]*domain.Consent, error) {
+	query := `SELECT ` + consentColumns + ` FROM consents WHERE user_id = $1 ORDER BY recorded_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consents: %w", err)
+	}
+	defer rows.Close()
+
+	var consents []*domain.Consent
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consent: %w", err)
+		}
+		consents = append(consents, consent)
+	}
+	return consents, rows.Err()
+}
+
+// GetLatest fetches the most recent consent record of a user for a purpose
+func (r *PostgresConsentRepo) GetLatest(userID uuid.UUID, purpose domain.ConsentPurpose) (*domain.Consent, error) {
+	query := `SELECT ` + consentColumns + ` FROM consents
+		WHERE user_id = $1 AND purpose = $2
+		ORDER BY recorded_at DESC LIMIT 1`
+	return scanConsent(r.db.QueryRow(query, userID, purpose))
+}
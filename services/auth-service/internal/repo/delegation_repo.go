@@ -0,0 +1,109 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const delegationColumns = `id, grantor_id, delegate_id, scopes, created_at, expires_at, revoked_at`
+
+// PostgresDelegationRepo implements domain.DelegationRepository on top of PostgreSQL
+type PostgresDelegationRepo struct {
+	db dbtx
+}
+
+// NewPostgresDelegationRepo creates a new PostgreSQL backed delegation repository
+func NewPostgresDelegationRepo(db *sql.DB) *PostgresDelegationRepo {
+	return &PostgresDelegationRepo{db: db}
+}
+
+func scanDelegation(s scanner) (*domain.Delegation, error) {
+	var delegation domain.Delegation
+	var scopes []string
+	err := s.Scan(
+		&delegation.ID,
+		&delegation.GrantorID,
+		&delegation.DelegateID,
+		pq.Array(&scopes),
+		&delegation.CreatedAt,
+		&delegation.ExpiresAt,
+		&delegation.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		delegation.Scopes = append(delegation.Scopes, domain.DelegationScope(scope))
+	}
+	return &delegation, nil
+}
+
+// Create inserts a new delegation
+func (r *PostgresDelegationRepo) Create(delegation *domain.Delegation) error {
+	query := `INSERT INTO delegations (` + delegationColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	scopes := make([]string, len(delegation.Scopes))
+	for i, scope := range delegation.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	_, err := r.db.Exec(query,
+		delegation.ID,
+		delegation.GrantorID,
+		delegation.DelegateID,
+		pq.Array(scopes),
+		delegation.CreatedAt,
+		delegation.ExpiresAt,
+		delegation.RevokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create delegation: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches a delegation by its ID
+func (r *PostgresDelegationRepo) GetByID(id uuid.UUID) (*domain.Delegation, error) {
+	query := `SELECT ` + delegationColumns + ` FROM delegations WHERE id = $1`
+	return scanDelegation(r.db.QueryRow(query, id))
+}
+
+// ListByUserID returns the delegations a user granted or received, newest first
+func (r *PostgresDelegationRepo) ListByUserID(userID uuid.UUID) ([]*domain.Delegation, error) {
+	query := `SELECT ` + delegationColumns + ` FROM delegations
+		WHERE grantor_id = $1 OR delegate_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delegations: %w", err)
+	}
+	defer rows.Close()
+
+	var delegations []*domain.Delegation
+	for rows.Next() {
+		delegation, err := scanDelegation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delegation: %w", err)
+		}
+		delegations = append(delegations, delegation)
+	}
+	return delegations, rows.Err()
+}
+
+// Revoke ends an unrevoked delegation the user granted or received
+func (r *PostgresDelegationRepo) Revoke(id, userID uuid.UUID, at time.Time) error {
+	query := `UPDATE delegations SET revoked_at = $3
+		WHERE id = $1 AND (grantor_id = $2 OR delegate_id = $2) AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, id, userID, at)
+	if err != nil {
+		return fmt.Errorf("failed to revoke delegation: %w", err)
+	}
+	return expectRows(result)
+}
@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const deviceLoginColumns = `id, kiosk_id, device_code_hash, user_code, user_id, created_at, expires_at, approved_at, last_polled_at, consumed_at`
+
+// PostgresDeviceLoginRepo implements domain.DeviceLoginRepository on top of PostgreSQL
+type PostgresDeviceLoginRepo struct {
+	db dbtx
+}
+
+// NewPostgresDeviceLoginRepo creates a new PostgreSQL backed device login repository
+func NewPostgresDeviceLoginRepo(db *sql.DB) *PostgresDeviceLoginRepo {
+	return &PostgresDeviceLoginRepo{db: db}
+}
+
+func scanDeviceLogin(s scanner) (*domain.DeviceLogin, error) {
+	var login domain.DeviceLogin
+	err := s.Scan(
+		&login.ID,
+		&login.KioskID,
+		&login.DeviceCodeHash,
+		&login.UserCode,
+		&login.UserID,
+		&login.CreatedAt,
+		&login.ExpiresAt,
+		&login.ApprovedAt,
+		&login.LastPolledAt,
+		&login.ConsumedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// Create inserts a new device login
+func (r *PostgresDeviceLoginRepo) Create(login *domain.DeviceLogin) error {
+	query := `INSERT INTO device_logins (` + deviceLoginColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := r.db.Exec(query,
+		login.ID,
+		login.KioskID,
+		login.DeviceCodeHash,
+		login.UserCode,
+		login.UserID,
+		login.CreatedAt,
+		login.ExpiresAt,
+		login.ApprovedAt,
+		login.LastPolledAt,
+		login.ConsumedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create device login: %w", err)
+	}
+	return nil
+}
+
+// GetByDeviceCodeHash fetches a device login by the hash of its device code
+func (r *PostgresDeviceLoginRepo) GetByDeviceCodeHash(hash string) (*domain.DeviceLogin, error) {
+	query := `SELECT ` + deviceLoginColumns + ` FROM device_logins WHERE device_code_hash = $1`
+	return scanDeviceLogin(r.db.QueryRow(query, hash))
+}
+
+// GetPendingByUserCode fetches the unapproved, unexpired device login with the user code
+func (r *PostgresDeviceLoginRepo) GetPendingByUserCode(userCode string) (*domain.DeviceLogin, error) {
+	query := `SELECT ` + deviceLoginColumns + ` FROM device_logins
+		WHERE user_code = $1 AND user_id IS NULL AND expires_at > NOW()`
+	return scanDeviceLogin(r.db.QueryRow(query, userCode))
+}
+
+// Update persists the approval and polling state of a device login
+func (r *PostgresDeviceLoginRepo) Update(login *domain.DeviceLogin) error {
+	query := `UPDATE device_logins SET user_id = $2, approved_at = $3, last_polled_at = $4 WHERE id = $1`
+
+	result, err := r.db.Exec(query, login.ID, login.UserID, login.ApprovedAt, login.LastPolledAt)
+	if err != nil {
+		return fmt.Errorf("failed to update device login: %w", err)
+	}
+	return expectRows(result)
+}
+
+// Consume marks an approved device login as consumed. The condition on
+// consumed_at makes concurrent polls race for a single set of tokens.
+func (r *PostgresDeviceLoginRepo) Consume(id uuid.UUID) error {
+	query := `UPDATE device_logins SET consumed_at = NOW()
+		WHERE id = $1 AND user_id IS NOT NULL AND consumed_at IS NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to consume device login: %w", err)
+	}
+	return expectRows(result)
+}
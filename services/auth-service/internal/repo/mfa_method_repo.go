@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const mfaMethodColumns = `id, user_id, type, label, preferred, created_at`
+
+// PostgresMFAMethodRepo implements domain.MFAMethodRepository on top of PostgreSQL
+type PostgresMFAMethodRepo struct {
+	db dbtx
+}
+
+// NewPostgresMFAMethodRepo creates a new PostgreSQL backed MFA method repository
+func NewPostgresMFAMethodRepo(db *sql.DB) *PostgresMFAMethodRepo {
+	return &PostgresMFAMethodRepo{db: db}
+}
+
+func scanMFAMethod(s scanner) (*domain.MFAMethod, error) {
+	var method domain.MFAMethod
+	err := s.Scan(
+		&method.ID,
+		&method.UserID,
+		&method.Type,
+		&method.Label,
+		&method.Preferred,
+		&method.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// Create inserts a new MFA method
+func (r *PostgresMFAMethodRepo) Create(method *domain.MFAMethod) error {
+	query := `INSERT INTO mfa_methods (` + mfaMethodColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query,
+		method.ID,
+		method.UserID,
+		method.Type,
+		method.Label,
+		method.Preferred,
+		method.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create MFA method: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches an MFA method by its ID
+func (r *PostgresMFAMethodRepo) GetByID(id uuid.UUID) (*domain.MFAMethod, error) {
+	query := `SELECT ` + mfaMethodColumns + ` FROM mfa_methods WHERE id = $1`
+	return scanMFAMethod(r.db.QueryRow(query, id))
+}
+
+// ListByUserID returns the user's methods, preferred first, then oldest first
+func (r *PostgresMFAMethodRepo) ListByUserID(userID uuid.UUID) ([]*domain.MFAMethod, error) {
+	query := `SELECT ` + mfaMethodColumns + ` FROM mfa_methods
+		WHERE user_id = $1
+		ORDER BY preferred DESC, created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MFA methods: %w", err)
+	}
+	defer rows.Close()
+
+	var methods []*domain.MFAMethod
+	for rows.Next() {
+		method, err := scanMFAMethod(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan MFA method: %w", err)
+		}
+		methods = append(methods, method)
+	}
+	return methods, rows.Err()
+}
+
+// SetPreferred marks one method as preferred and clears the flag on the user's other methods
+func (r *PostgresMFAMethodRepo) SetPreferred(userID, id uuid.UUID) error {
+	result, err := r.db.Exec(`UPDATE mfa_methods SET preferred = (id = $2) WHERE user_id = $1`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to set preferred MFA method: %w", err)
+	}
+	return expectRows(result)
+}
+
+// Delete removes an MFA method
+func (r *PostgresMFAMethodRepo) Delete(id uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM mfa_methods WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete MFA method: %w", err)
+	}
+	return expectRows(result)
+}
+
+// DeleteByType removes every method of the given type from a user
+func (r *PostgresMFAMethodRepo) DeleteByType(userID uuid.UUID, methodType domain.MFAMethodType) error {
+	_, err := r.db.Exec(`DELETE FROM mfa_methods WHERE user_id = $1 AND type = $2`, userID, methodType)
+	if err != nil {
+		return fmt.Errorf("failed to delete MFA methods: %w", err)
+	}
+	return nil
+}
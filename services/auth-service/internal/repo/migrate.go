@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// migrationLockID keys the advisory lock held while migrating, so instances
+// starting together apply each migration once
+const migrationLockID = 7_466_137_402
+
+// migrationFile matches the YYYYMMDDHHMMSS_description.sql names of
+// migrations; fixed-width versions sort in the order they were created
+var migrationFile = regexp.MustCompile(`^(\d{14})_(\w+)\.sql$`)
+
+// migration is one versioned schema change
+type migration struct {
+	version string
+	name    string
+	up      string
+}
+
+// Migrate applies the migrations of fsys not yet recorded in
+// schema_migrations, in version order, and returns how many it applied.
+// Each runs in its own transaction together with its record, so a failed
+// migration leaves nothing behind and is retried on the next run.
+func Migrate(ctx context.Context, db *sql.DB, fsys fs.FS) (int, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return 0, err
+	}
+
+	// the lock belongs to the session, so every statement runs on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return 0, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(14) PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		done, err := applyMigration(ctx, conn, m)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply migration %s_%s: %w", m.version, m.name, err)
+		}
+		if done {
+			log.Printf("Applied migration %s_%s", m.version, m.name)
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration runs m unless it is already recorded, reporting whether it ran
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var recorded bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&recorded)
+	if err != nil || recorded {
+		return false, err
+	}
+
+	// without arguments the statements go out as one simple query, so a
+	// file may hold several
+	if _, err := tx.ExecContext(ctx, m.up); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// readMigrations parses the migration files of fsys, ordered by version
+func readMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var migrations []migration
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		if other, ok := seen[match[1]]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %s", other, entry.Name(), match[1])
+		}
+		seen[match[1]] = entry.Name()
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: match[1], name: match[2], up: upSection(string(content))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// upSection returns the statements between "-- +migrate Up" and
+// "-- +migrate Down", or the whole file when it has no markers
+func upSection(content string) string {
+	if _, after, ok := strings.Cut(content, "-- +migrate Up"); ok {
+		content = after
+	}
+	up, _, _ := strings.Cut(content, "-- +migrate Down")
+	return up
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/unibazzar/auth-service/migrations"
+)
+
+func TestReadMigrationsOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20261017120000_second.sql": {Data: []byte("-- +migrate Up\nSELECT 2;\n-- +migrate Down\nSELECT -2;\n")},
+		"20261017110000_first.sql":  {Data: []byte("SELECT 1;\n")},
+		"0003_short_version.sql":    {Data: []byte("SELECT 3;\n")},
+		"README.md":                 {Data: []byte("not a migration")},
+	}
+
+	got, err := readMigrations(fsys)
+	if err != nil {
+		t.Fatalf("readMigrations: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d migrations, want 2: %+v", len(got), got)
+	}
+	if got[0].version != "20261017110000" || got[0].name != "first" {
+		t.Errorf("first migration = %s_%s", got[0].version, got[0].name)
+	}
+	if got[1].version != "20261017120000" || got[1].name != "second" {
+		t.Errorf("second migration = %s_%s", got[1].version, got[1].name)
+	}
+	if strings.TrimSpace(got[0].up) != "SELECT 1;" {
+		t.Errorf("file without markers: up = %q", got[0].up)
+	}
+	if strings.TrimSpace(got[1].up) != "SELECT 2;" {
+		t.Errorf("up section = %q", got[1].up)
+	}
+}
+
+func TestReadMigrationsRejectsSharedVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20261017110000_one.sql": {Data: []byte("SELECT 1;")},
+		"20261017110000_two.sql": {Data: []byte("SELECT 2;")},
+	}
+	if _, err := readMigrations(fsys); err == nil {
+		t.Fatal("expected an error for two migrations sharing a version")
+	}
+}
+
+// The embedded migrations must all be picked up, both by Migrate and by
+// tools/db/migrate.sh, which only reads 14-digit versions
+func TestEmbeddedMigrationsAreVersioned(t *testing.T) {
+	entries, err := migrations.FS.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	got, err := readMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("readMigrations: %v", err)
+	}
+
+	files := 0
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".sql") {
+			files++
+			if !migrationFile.MatchString(entry.Name()) {
+				t.Errorf("migration %s is not named YYYYMMDDHHMMSS_description.sql", entry.Name())
+			}
+		}
+	}
+	if len(got) != files {
+		t.Errorf("read %d of %d migration files", len(got), files)
+	}
+	for _, m := range got {
+		if strings.TrimSpace(m.up) == "" {
+			t.Errorf("migration %s_%s has no up statements", m.version, m.name)
+		}
+	}
+}
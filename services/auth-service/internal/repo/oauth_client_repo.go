@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const oauthClientColumns = `id, client_id, secret_hash, name, redirect_uris, scopes, grant_types, access_token_format, created_at, updated_at`
+
+// PostgresOAuthClientRepo implements domain.OAuthClientRepository on top of PostgreSQL
+type PostgresOAuthClientRepo struct {
+	db dbtx
+}
+
+// NewPostgresOAuthClientRepo creates a new PostgreSQL backed OAuth client repository
+func NewPostgresOAuthClientRepo(db *sql.DB) *PostgresOAuthClientRepo {
+	return &PostgresOAuthClientRepo{db: db}
+}
+
+func scanOAuthClient(s scanner) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	err := s.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.SecretHash,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.Scopes),
+		pq.Array(&client.GrantTypes),
+		&client.AccessTokenFormat,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Create inserts a new OAuth client
+func (r *PostgresOAuthClientRepo) Create(client *domain.OAuthClient) error {
+	query := `INSERT INTO oauth_clients (` + oauthClientColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := r.db.Exec(query,
+		client.ID,
+		client.ClientID,
+		client.SecretHash,
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.Scopes),
+		pq.Array(client.GrantTypes),
+		client.AccessTokenFormat,
+		client.CreatedAt,
+		client.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create OAuth client: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches an OAuth client by its ID
+func (r *PostgresOAuthClientRepo) GetByID(id uuid.UUID) (*domain.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE id = $1`
+	return scanOAuthClient(r.db.QueryRow(query, id))
+}
+
+// GetByClientID fetches an OAuth client by its public client ID
+func (r *PostgresOAuthClientRepo) GetByClientID(clientID string) (*domain.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE client_id = $1`
+	return scanOAuthClient(r.db.QueryRow(query, clientID))
+}
+
+// List returns every registered OAuth client ordered by name
+func (r *PostgresOAuthClientRepo) List() ([]*domain.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients ORDER BY name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OAuth clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []*domain.OAuthClient
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan OAuth client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// Update persists changes to an existing OAuth client
+func (r *PostgresOAuthClientRepo) Update(client *domain.OAuthClient) error {
+	query := `UPDATE oauth_clients
+		SET secret_hash = $2, name = $3, redirect_uris = $4, scopes = $5, grant_types = $6, updated_at = $7,
+			access_token_format = $8
+		WHERE id = $1`
+
+	result, err := r.db.Exec(query,
+		client.ID,
+		client.SecretHash,
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.Scopes),
+		pq.Array(client.GrantTypes),
+		client.UpdatedAt,
+		client.AccessTokenFormat,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update OAuth client: %w", err)
+	}
+	return expectRows(result)
+}
+
+// Delete removes an OAuth client
+func (r *PostgresOAuthClientRepo) Delete(id uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete OAuth client: %w", err)
+	}
+	return expectRows(result)
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// PostgresOAuthIdentityRepo implements domain.OAuthIdentityRepository on top of PostgreSQL
+type PostgresOAuthIdentityRepo struct {
+	db dbtx
+}
+
+// NewPostgresOAuthIdentityRepo creates a new PostgreSQL backed external identity repository
+func NewPostgresOAuthIdentityRepo(db *sql.DB) *PostgresOAuthIdentityRepo {
+	return &PostgresOAuthIdentityRepo{db: db}
+}
+
+// Create links a new external identity
+func (r *PostgresOAuthIdentityRepo) Create(identity *domain.OAuthIdentity) error {
+	query := `INSERT INTO oauth_identities (id, user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query,
+		identity.ID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create OAuth identity: %w", err)
+	}
+	return nil
+}
+
+// GetBySubject returns the identity of the provider account
+func (r *PostgresOAuthIdentityRepo) GetBySubject(provider, subject string) (*domain.OAuthIdentity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at
+		FROM oauth_identities WHERE provider = $1 AND subject = $2`
+
+	var identity domain.OAuthIdentity
+	err := r.db.QueryRow(query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
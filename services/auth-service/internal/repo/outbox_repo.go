@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const outboxColumns = `id, dedupe_key, event_type, payload, created_at, attempts, last_error, locked_until, dispatched_at`
+
+// PostgresOutboxRepo implements domain.OutboxRepository on top of PostgreSQL
+type PostgresOutboxRepo struct {
+	db dbtx
+}
+
+// NewPostgresOutboxRepo creates a new PostgreSQL backed outbox repository
+func NewPostgresOutboxRepo(db *sql.DB) *PostgresOutboxRepo {
+	return &PostgresOutboxRepo{db: db}
+}
+
+func scanOutboxEvent(s scanner) (*domain.OutboxEvent, error) {
+	var event domain.OutboxEvent
+	var lastError sql.NullString
+	err := s.Scan(
+		&event.ID,
+		&event.DedupeKey,
+		&event.EventType,
+		&event.Payload,
+		&event.CreatedAt,
+		&event.Attempts,
+		&lastError,
+		&event.LockedUntil,
+		&event.DispatchedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	event.LastError = lastError.String
+	return &event, nil
+}
+
+// Create adds an event, ignoring one whose dedupe key is already stored
+func (r *PostgresOutboxRepo) Create(event *domain.OutboxEvent) error {
+	query := `INSERT INTO outbox_events (id, dedupe_key, event_type, payload, created_at, attempts)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dedupe_key) DO NOTHING`
+
+	_, err := r.db.Exec(query,
+		event.ID,
+		event.DedupeKey,
+		event.EventType,
+		event.Payload,
+		event.CreatedAt,
+		event.Attempts,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox event: %w", err)
+	}
+	return nil
+}
+
+// Claim locks the oldest undispatched events that no other dispatcher holds
+func (r *PostgresOutboxRepo) Claim(limit int, until time.Time) ([]*domain.OutboxEvent, error) {
+	query := `UPDATE outbox_events SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := r.db.Query(query, limit, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// MarkDispatched records that the event reached the bus
+func (r *PostgresOutboxRepo) MarkDispatched(id uuid.UUID, at time.Time) error {
+	query := `UPDATE outbox_events SET dispatched_at = $2, locked_until = NULL WHERE id = $1`
+
+	result, err := r.db.Exec(query, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event dispatched: %w", err)
+	}
+	return expectRows(result)
+}
+
+// RecordFailure counts a failed publish and releases the claim on the event
+func (r *PostgresOutboxRepo) RecordFailure(id uuid.UUID, reason string) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, locked_until = NULL WHERE id = $1`
+
+	result, err := r.db.Exec(query, id, reason)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	return expectRows(result)
+}
+
+// DeleteDispatchedBefore removes the events dispatched before the cutoff
+func (r *PostgresOutboxRepo) DeleteDispatchedBefore(cutoff time.Time) (int64, error) {
+	query := `DELETE FROM outbox_events WHERE dispatched_at IS NOT NULL AND dispatched_at < $1`
+
+	result, err := r.db.Exec(query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete dispatched outbox events: %w", err)
+	}
+	return result.RowsAffected()
+}
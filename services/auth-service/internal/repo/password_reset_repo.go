@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const passwordResetColumns = `id, user_id, token_hash, expires_at, used_at, created_at`
+
+// PostgresPasswordResetRepo implements domain.PasswordResetRepository on top of PostgreSQL
+type PostgresPasswordResetRepo struct {
+	db dbtx
+}
+
+// NewPostgresPasswordResetRepo creates a new PostgreSQL backed password reset repository
+func NewPostgresPasswordResetRepo(db *sql.DB) *PostgresPasswordResetRepo {
+	return &PostgresPasswordResetRepo{db: db}
+}
+
+func scanPasswordReset(s scanner) (*domain.PasswordReset, error) {
+	var reset domain.PasswordReset
+	err := s.Scan(
+		&reset.ID,
+		&reset.UserID,
+		&reset.TokenHash,
+		&reset.ExpiresAt,
+		&reset.UsedAt,
+		&reset.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// Create inserts a new password reset
+func (r *PostgresPasswordResetRepo) Create(reset *domain.PasswordReset) error {
+	query := `INSERT INTO password_resets (` + passwordResetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query,
+		reset.ID,
+		reset.UserID,
+		reset.TokenHash,
+		reset.ExpiresAt,
+		reset.UsedAt,
+		reset.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+	return nil
+}
+
+// GetByTokenHash fetches a password reset by the hash of its token
+func (r *PostgresPasswordResetRepo) GetByTokenHash(tokenHash string) (*domain.PasswordReset, error) {
+	query := `SELECT ` + passwordResetColumns + ` FROM password_resets WHERE token_hash = $1`
+	return scanPasswordReset(r.db.QueryRow(query, tokenHash))
+}
+
+// Consume marks an unused, unexpired password reset used. The conditions are
+// checked by the update itself, so of concurrent calls only one matches.
+func (r *PostgresPasswordResetRepo) Consume(reset *domain.PasswordReset) error {
+	query := `UPDATE password_resets SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING used_at`
+
+	err := r.db.QueryRow(query, reset.ID).Scan(&reset.UsedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to consume password reset: %w", err)
+	}
+	return err
+}
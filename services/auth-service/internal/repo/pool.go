@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// tooManyConnections is the PostgreSQL error code of a server refusing
+// connections beyond its max_connections
+const tooManyConnections = "53300"
+
+var poolExhausted = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "auth_db_pool_exhausted_total",
+	Help: "Database operations refused because every connection was in use.",
+})
+
+// PoolConfig tunes the connection pool of a database
+type PoolConfig struct {
+	// MaxOpenConns caps the open connections; zero means no cap
+	MaxOpenConns int
+	// MaxIdleConns is how many unused connections are kept open
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections older than this; zero keeps them
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime closes connections unused for this long; zero keeps them
+	ConnMaxIdleTime time.Duration
+}
+
+// PoolStats describes the connection pool of a database, as /readyz reports it
+type PoolStats struct {
+	MaxOpen int `json:"max_open"`
+	Open    int `json:"open"`
+	InUse   int `json:"in_use"`
+	Idle    int `json:"idle"`
+}
+
+// PoolStatus returns the stats of a pool opened with the given cap
+func PoolStatus(db *sql.DB, maxOpen int) PoolStats {
+	stats := db.Stats()
+	return PoolStats{MaxOpen: maxOpen, Open: stats.OpenConnections, InUse: stats.InUse, Idle: stats.Idle}
+}
+
+// RegisterPoolMetrics exposes the in-use and idle connections of the pool
+// as auth_db_connections, labeled with the pool's name
+func RegisterPoolMetrics(name string, db *sql.DB) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "auth_db_connections",
+		Help:        "Open database connections, by pool and state.",
+		ConstLabels: prometheus.Labels{"pool": name, "state": "in_use"},
+	}, func() float64 { return float64(db.Stats().InUse) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "auth_db_connections",
+		Help:        "Open database connections, by pool and state.",
+		ConstLabels: prometheus.Labels{"pool": name, "state": "idle"},
+	}, func() float64 { return float64(db.Stats().Idle) })
+}
+
+// limitedConnector caps the connections it opens. database/sql queues callers
+// until a connection frees up once its own cap is reached, which under load
+// holds requests until they time out; the cap is enforced here instead, so
+// an operation needing a connection beyond it fails at once with
+// domain.ErrServiceUnavailable.
+type limitedConnector struct {
+	driver.Connector
+	limit int64
+	open  atomic.Int64
+}
+
+// Connect opens a connection unless the cap is reached. A server refusing
+// connections is reported as unavailable too.
+func (c *limitedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if open := c.open.Add(1); c.limit > 0 && open > c.limit {
+		c.open.Add(-1)
+		poolExhausted.Inc()
+		return nil, domain.ErrServiceUnavailable
+	}
+
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		c.open.Add(-1)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == tooManyConnections {
+			poolExhausted.Inc()
+			return nil, fmt.Errorf("%w: %v", domain.ErrServiceUnavailable, err)
+		}
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: func() { c.open.Add(-1) }}, nil
+}
+
+// limitedConn gives its slot back when closed, and passes the optional
+// driver interfaces through to the connection it wraps
+type limitedConn struct {
+	driver.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+func (c *limitedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if conn, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return conn.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *limitedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if conn, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return conn.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *limitedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if conn, ok := c.Conn.(driver.QueryerContext); ok {
+		return conn.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *limitedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if conn, ok := c.Conn.(driver.ExecerContext); ok {
+		return conn.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *limitedConn) Ping(ctx context.Context) error {
+	if conn, ok := c.Conn.(driver.Pinger); ok {
+		return conn.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *limitedConn) ResetSession(ctx context.Context) error {
+	if conn, ok := c.Conn.(driver.SessionResetter); ok {
+		return conn.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *limitedConn) IsValid() bool {
+	if conn, ok := c.Conn.(driver.Validator); ok {
+		return conn.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// isUniqueViolation reports whether err was caused by a unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// emailConstraint is matched against the constraint name of a unique
+// violation on users to tell a taken email from other duplicates
+const emailConstraint = "email"
+
+// isEmailViolation reports whether err was caused by the unique email of users
+func isEmailViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && strings.Contains(pqErr.Constraint, emailConstraint)
+}
+
+// phoneConstraint is matched like emailConstraint, for the unique verified
+// phone numbers of users
+const phoneConstraint = "phone"
+
+// isPhoneViolation reports whether err was caused by the unique verified phone of users
+func isPhoneViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && strings.Contains(pqErr.Constraint, phoneConstraint)
+}
+
+// notFoundError reports a missing row as the domain error of its kind while
+// still matching sql.ErrNoRows
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string { return e.err.Error() }
+
+func (e notFoundError) Unwrap() error { return e.err }
+
+func (e notFoundError) Is(target error) bool { return target == sql.ErrNoRows }
+
+// notFound replaces sql.ErrNoRows with the given domain error, leaving
+// other errors as they are
+func notFound(err, sentinel error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundError{err: sentinel}
+	}
+	return err
+}
+
+// NewPostgresDB opens a connection pool to PostgreSQL and verifies it is
+// reachable. Once pool.MaxOpenConns connections are in use, operations
+// needing another fail with domain.ErrServiceUnavailable instead of waiting.
+func NewPostgresDB(databaseURL string, pool PoolConfig) (*sql.DB, error) {
+	connector, err := pq.NewConnector(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// the connector enforces the cap, so database/sql must not queue below it
+	db := sql.OpenDB(&limitedConnector{Connector: connector, limit: int64(pool.MaxOpenConns)})
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so repositories can run
+// inside or outside a transaction
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// placeholders returns n positional parameters starting at $from, e.g. "$2, $3, $4"
+func placeholders(from, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", from+i)
+	}
+	return strings.Join(params, ", ")
+}
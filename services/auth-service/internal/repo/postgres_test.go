@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestUniqueViolationsOfUsers(t *testing.T) {
+	email := fmt.Errorf("failed to update user: %w", &pq.Error{Code: uniqueViolation, Constraint: "users_email_key"})
+	phone := &pq.Error{Code: uniqueViolation, Constraint: "users_phone_key"}
+	other := &pq.Error{Code: "23503", Constraint: "users_phone_key"}
+
+	if !isEmailViolation(email) || isPhoneViolation(email) {
+		t.Error("email violation misclassified")
+	}
+	if !isPhoneViolation(phone) || isEmailViolation(phone) {
+		t.Error("phone violation misclassified")
+	}
+	if isPhoneViolation(other) || isPhoneViolation(errors.New("connection lost")) {
+		t.Error("other errors taken for a phone violation")
+	}
+}
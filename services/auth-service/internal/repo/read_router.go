@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// ReadRouter sends reads to a replica when one is configured, except for keys
+// written within the consistency window, which are read from the primary so
+// a user always sees their own writes despite replication lag. Write markers
+// are kept in memory, so the guarantee holds per service instance.
+type ReadRouter struct {
+	primary *sql.DB
+	replica *sql.DB
+	window  time.Duration
+
+	mu        sync.Mutex
+	writes    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewReadRouter creates a router; a nil replica sends every read to the primary
+func NewReadRouter(primary, replica *sql.DB, window time.Duration) *ReadRouter {
+	return &ReadRouter{
+		primary:   primary,
+		replica:   replica,
+		window:    window,
+		writes:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Primary returns the primary database, used for all writes
+func (r *ReadRouter) Primary() *sql.DB {
+	return r.primary
+}
+
+// Reader returns the database to read the given keys from
+func (r *ReadRouter) Reader(keys ...string) *sql.DB {
+	if r.replica == nil {
+		return r.primary
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, key := range keys {
+		if writtenAt, ok := r.writes[key]; ok && now.Sub(writtenAt) < r.window {
+			return r.primary
+		}
+	}
+	return r.replica
+}
+
+// MarkWritten records a write to the given keys, pinning their reads to the
+// primary for the consistency window
+func (r *ReadRouter) MarkWritten(keys ...string) {
+	if r.replica == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, key := range keys {
+		r.writes[key] = now
+	}
+	if now.Sub(r.lastSweep) >= r.window {
+		r.sweep(now)
+	}
+}
+
+// sweep drops markers older than the window; callers hold mu
+func (r *ReadRouter) sweep(now time.Time) {
+	for key, writtenAt := range r.writes {
+		if now.Sub(writtenAt) >= r.window {
+			delete(r.writes, key)
+		}
+	}
+	r.lastSweep = now
+}
@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// queryLog records which database each statement ran on
+type queryLog struct {
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func (l *queryLog) record(dsn string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries[dsn]++
+}
+
+func (l *queryLog) count(dsn string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queries[dsn]
+}
+
+var statements = &queryLog{queries: make(map[string]int)}
+
+func init() {
+	sql.Register("recording", recordingDriver{})
+}
+
+// recordingDriver is a database whose writes affect one row and whose reads
+// find nothing. Each statement is logged under the DSN of its database.
+type recordingDriver struct{}
+
+func (recordingDriver) Open(dsn string) (driver.Conn, error) { return recordingConn{dsn: dsn}, nil }
+
+type recordingConn struct{ dsn string }
+
+func (c recordingConn) Prepare(string) (driver.Stmt, error) { return recordingStmt(c), nil }
+func (recordingConn) Close() error                          { return nil }
+func (recordingConn) Begin() (driver.Tx, error)             { return nil, errors.New("no transactions") }
+
+type recordingStmt struct{ dsn string }
+
+func (recordingStmt) Close() error  { return nil }
+func (recordingStmt) NumInput() int { return -1 }
+
+func (s recordingStmt) Exec([]driver.Value) (driver.Result, error) {
+	statements.record(s.dsn)
+	return driver.RowsAffected(1), nil
+}
+
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	statements.record(s.dsn)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// openRecording opens a database of the recording driver under a DSN unique to the test
+func openRecording(t *testing.T, name string) (*sql.DB, string) {
+	t.Helper()
+	dsn := t.Name() + "/" + name
+	db, err := sql.Open("recording", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, dsn
+}
+
+func TestReadRouterPinsRecentWritesToPrimary(t *testing.T) {
+	primary, _ := openRecording(t, "primary")
+	replica, _ := openRecording(t, "replica")
+	router := NewReadRouter(primary, replica, 5*time.Second)
+	now := time.Now()
+	router.now = func() time.Time { return now }
+
+	if router.Reader("user:1") != replica {
+		t.Fatal("a key never written is not read from the replica")
+	}
+
+	router.MarkWritten("user:1")
+	if router.Reader("user:1") != primary {
+		t.Error("a key just written is read from the lagging replica")
+	}
+	if router.Reader("user:2") != replica {
+		t.Error("a key not written is read from the primary")
+	}
+	if router.Reader("user:2", "user:1") != primary {
+		t.Error("a read of several keys including a written one is not pinned")
+	}
+
+	now = now.Add(5 * time.Second)
+	if router.Reader("user:1") != replica {
+		t.Error("a key is still pinned to the primary after the window")
+	}
+}
+
+func TestReadRouterSweepsExpiredMarkers(t *testing.T) {
+	primary, _ := openRecording(t, "primary")
+	replica, _ := openRecording(t, "replica")
+	router := NewReadRouter(primary, replica, time.Second)
+	now := time.Now()
+	router.now = func() time.Time { return now }
+
+	router.MarkWritten("user:1", "user:2")
+	now = now.Add(time.Second)
+	router.MarkWritten("user:3")
+
+	if len(router.writes) != 1 {
+		t.Errorf("%d write markers kept, want only the one within the window", len(router.writes))
+	}
+}
+
+func TestReadRouterWithoutReplica(t *testing.T) {
+	primary, _ := openRecording(t, "primary")
+	router := NewReadRouter(primary, nil, time.Second)
+
+	router.MarkWritten("user:1")
+	if router.Reader("user:1") != primary || router.Reader("user:2") != primary {
+		t.Error("reads without a replica do not go to the primary")
+	}
+	if len(router.writes) != 0 {
+		t.Error("writes are tracked without a replica")
+	}
+}
+
+// Simulates replica lag through the user repository: after a user is
+// written, reads of that user go to the primary, while other users are
+// still read from the replica
+func TestUserRepoReadsItsWritesFromPrimary(t *testing.T) {
+	primary, primaryDSN := openRecording(t, "primary")
+	replica, replicaDSN := openRecording(t, "replica")
+	users := NewPostgresUserRepo(NewReadRouter(primary, replica, time.Minute))
+	ctx := context.Background()
+	written, other := uuid.New(), uuid.New()
+
+	readFrom := func(id uuid.UUID) string {
+		t.Helper()
+		beforePrimary, beforeReplica := statements.count(primaryDSN), statements.count(replicaDSN)
+		if _, err := users.GetByID(ctx, id); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("GetByID = %v, want not found", err)
+		}
+		switch {
+		case statements.count(primaryDSN) > beforePrimary:
+			return "primary"
+		case statements.count(replicaDSN) > beforeReplica:
+			return "replica"
+		}
+		t.Fatal("GetByID ran no query")
+		return ""
+	}
+
+	if got := readFrom(written); got != "replica" {
+		t.Fatalf("read before any write went to the %s", got)
+	}
+
+	if err := users.Delete(ctx, written); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if statements.count(replicaDSN) != 1 {
+		t.Error("a write went to the replica")
+	}
+
+	if got := readFrom(written); got != "primary" {
+		t.Errorf("read after a write went to the %s, want the primary", got)
+	}
+	if got := readFrom(other); got != "replica" {
+		t.Errorf("read of another user went to the %s, want the replica", got)
+	}
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const reverificationJobColumns = `id, idempotency_key, campus_id, status, cursor, processed, sent, last_error,
+	created_by, created_at, updated_at, completed_at`
+
+// PostgresReverificationJobRepo implements domain.ReverificationJobRepository on top of PostgreSQL
+type PostgresReverificationJobRepo struct {
+	db dbtx
+}
+
+// NewPostgresReverificationJobRepo creates a new PostgreSQL backed re-verification job repository
+func NewPostgresReverificationJobRepo(db *sql.DB) *PostgresReverificationJobRepo {
+	return &PostgresReverificationJobRepo{db: db}
+}
+
+func scanReverificationJob(s scanner) (*domain.ReverificationJob, error) {
+	var job domain.ReverificationJob
+	err := s.Scan(
+		&job.ID,
+		&job.IdempotencyKey,
+		&job.CampusID,
+		&job.Status,
+		&job.Cursor,
+		&job.Processed,
+		&job.Sent,
+		&job.LastError,
+		&job.CreatedBy,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Create inserts a new re-verification job. Idempotency keys are unique,
+// backed by a partial unique index over the non-empty keys.
+func (r *PostgresReverificationJobRepo) Create(job *domain.ReverificationJob) error {
+	query := `INSERT INTO reverification_jobs (` + reverificationJobColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := r.db.Exec(query,
+		job.ID,
+		job.IdempotencyKey,
+		job.CampusID,
+		job.Status,
+		job.Cursor,
+		job.Processed,
+		job.Sent,
+		job.LastError,
+		job.CreatedBy,
+		job.CreatedAt,
+		job.UpdatedAt,
+		job.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create re-verification job: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches a re-verification job by its ID
+func (r *PostgresReverificationJobRepo) GetByID(id uuid.UUID) (*domain.ReverificationJob, error) {
+	query := `SELECT ` + reverificationJobColumns + ` FROM reverification_jobs WHERE id = $1`
+	return scanReverificationJob(r.db.QueryRow(query, id))
+}
+
+// GetByIdempotencyKey fetches the job started with the given idempotency key
+func (r *PostgresReverificationJobRepo) GetByIdempotencyKey(key string) (*domain.ReverificationJob, error) {
+	query := `SELECT ` + reverificationJobColumns + ` FROM reverification_jobs WHERE idempotency_key = $1`
+	return scanReverificationJob(r.db.QueryRow(query, key))
+}
+
+// ListRunning returns the jobs that have not finished or failed
+func (r *PostgresReverificationJobRepo) ListRunning() ([]*domain.ReverificationJob, error) {
+	query := `SELECT ` + reverificationJobColumns + ` FROM reverification_jobs WHERE status = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(query, domain.JobRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list re-verification jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*domain.ReverificationJob
+	for rows.Next() {
+		job, err := scanReverificationJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan re-verification job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Update persists the progress of a re-verification job
+func (r *PostgresReverificationJobRepo) Update(job *domain.ReverificationJob) error {
+	query := `UPDATE reverification_jobs
+		SET status = $2, cursor = $3, processed = $4, sent = $5, last_error = $6, updated_at = $7, completed_at = $8
+		WHERE id = $1`
+
+	result, err := r.db.Exec(query,
+		job.ID,
+		job.Status,
+		job.Cursor,
+		job.Processed,
+		job.Sent,
+		job.LastError,
+		job.UpdatedAt,
+		job.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update re-verification job: %w", err)
+	}
+	return expectRows(result)
+}
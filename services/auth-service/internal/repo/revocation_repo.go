@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const revocationCutoffColumns = `id, user_id, cutoff_at, created_by, created_at`
+
+// PostgresRevocationCutoffRepo implements domain.RevocationCutoffRepository on top of PostgreSQL
+type PostgresRevocationCutoffRepo struct {
+	db dbtx
+}
+
+// NewPostgresRevocationCutoffRepo creates a new PostgreSQL backed revocation cutoff repository
+func NewPostgresRevocationCutoffRepo(db *sql.DB) *PostgresRevocationCutoffRepo {
+	return &PostgresRevocationCutoffRepo{db: db}
+}
+
+func scanRevocationCutoff(s scanner) (*domain.RevocationCutoff, error) {
+	var cutoff domain.RevocationCutoff
+	err := s.Scan(
+		&cutoff.ID,
+		&cutoff.UserID,
+		&cutoff.CutoffAt,
+		&cutoff.CreatedBy,
+		&cutoff.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cutoff, nil
+}
+
+// Create inserts a new revocation cutoff
+func (r *PostgresRevocationCutoffRepo) Create(cutoff *domain.RevocationCutoff) error {
+	query := `INSERT INTO revocation_cutoffs (` + revocationCutoffColumns + `) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.Exec(query,
+		cutoff.ID,
+		cutoff.UserID,
+		cutoff.CutoffAt,
+		cutoff.CreatedBy,
+		cutoff.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create revocation cutoff: %w", err)
+	}
+	return nil
+}
+
+// ListForUser returns the global cutoffs and those of the given user
+func (r *PostgresRevocationCutoffRepo) ListForUser(userID uuid.UUID) ([]*domain.RevocationCutoff, error) {
+	query := `SELECT ` + revocationCutoffColumns + ` FROM revocation_cutoffs
+		WHERE user_id IS NULL OR user_id = $1
+		ORDER BY cutoff_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revocation cutoffs: %w", err)
+	}
+	defer rows.Close()
+
+	var cutoffs []*domain.RevocationCutoff
+	for rows.Next() {
+		cutoff, err := scanRevocationCutoff(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revocation cutoff: %w", err)
+		}
+		cutoffs = append(cutoffs, cutoff)
+	}
+	return cutoffs, rows.Err()
+}
@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const sessionColumns = `id, user_id, refresh_token_hash, previous_refresh_token_hash, rotated_at, expires_at,
+	created_at, last_used_at, ip_address, user_agent, is_revoked, risk_score, risk_signals,
+	auth_method, mfa_used, trusted_device, scopes, pin_failures, token_format, client_id, device_id,
+	rotation_nonce, remember_me`
+
+// PostgresSessionRepo implements domain.SessionRepository on top of PostgreSQL
+type PostgresSessionRepo struct {
+	db dbtx
+}
+
+// NewPostgresSessionRepo creates a new PostgreSQL backed session repository
+func NewPostgresSessionRepo(db *sql.DB) *PostgresSessionRepo {
+	return &PostgresSessionRepo{db: db}
+}
+
+func scanSession(s scanner) (*domain.Session, error) {
+	var session domain.Session
+	err := s.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenHash,
+		&session.PreviousRefreshTokenHash,
+		&session.RotatedAt,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.IsRevoked,
+		&session.RiskScore,
+		&session.RiskSignals,
+		&session.Method,
+		&session.MFAUsed,
+		&session.TrustedDevice,
+		&session.Scopes,
+		&session.PinFailures,
+		&session.TokenFormat,
+		&session.ClientID,
+		&session.DeviceID,
+		&session.RotationNonce,
+		&session.RememberMe,
+	)
+	if err != nil {
+		return nil, notFound(err, domain.ErrSessionNotFound)
+	}
+	return &session, nil
+}
+
+// Create inserts a new session
+func (r *PostgresSessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	query := `INSERT INTO sessions (` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.PreviousRefreshTokenHash,
+		session.RotatedAt,
+		session.ExpiresAt,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.IPAddress,
+		session.UserAgent,
+		session.IsRevoked,
+		session.RiskScore,
+		session.RiskSignals,
+		session.Method,
+		session.MFAUsed,
+		session.TrustedDevice,
+		session.Scopes,
+		session.PinFailures,
+		session.TokenFormat,
+		session.ClientID,
+		session.DeviceID,
+		session.RotationNonce,
+		session.RememberMe,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrRefreshTokenTaken
+		}
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// GetByID fetches a session by its ID
+func (r *PostgresSessionRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	return scanSession(r.db.QueryRowContext(ctx, query, id))
+}
+
+// GetByRefreshToken fetches the session owning the given refresh token, looked
+// up by its hash
+func (r *PostgresSessionRepo) GetByRefreshToken(ctx context.Context, token string) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE refresh_token_hash = $1 LIMIT 2`
+	return r.getOne(ctx, query, domain.HashToken(token))
+}
+
+// GetByPreviousRefreshToken fetches the session whose last rotation replaced the given refresh token
+func (r *PostgresSessionRepo) GetByPreviousRefreshToken(ctx context.Context, token string) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE previous_refresh_token_hash = $1 LIMIT 2`
+	return r.getOne(ctx, query, domain.HashToken(token))
+}
+
+// getOne returns the only session matched by a refresh token query, which
+// must select at least two rows to detect duplicates
+func (r *PostgresSessionRepo) getOne(ctx context.Context, query string, hash string) (*domain.Session, error) {
+	rows, err := r.db.QueryContext(ctx, query, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, notFound(sql.ErrNoRows, domain.ErrSessionNotFound)
+	}
+	session, err := scanSession(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan session: %w", err)
+	}
+	if rows.Next() {
+		return nil, domain.ErrAmbiguousRefreshToken
+	}
+	return session, rows.Err()
+}
+
+// GetByRefreshTokenHash fetches the session whose current refresh token has
+// the given SHA-256 hex digest
+func (r *PostgresSessionRepo) GetByRefreshTokenHash(ctx context.Context, hash string) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE refresh_token_hash = lower($1)`
+	return scanSession(r.db.QueryRowContext(ctx, query, hash))
+}
+
+// GetByUserID returns all sessions of a user, newest first
+func (r *PostgresSessionRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// CountActive counts the unrevoked, unexpired sessions of all users
+func (r *PostgresSessionRepo) CountActive(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM sessions WHERE is_revoked = FALSE AND expires_at > NOW()`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// CountActiveByUserID counts the unrevoked, unexpired sessions of a user
+func (r *PostgresSessionRepo) CountActiveByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM sessions WHERE user_id = $1 AND is_revoked = FALSE AND expires_at > NOW()`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// GetOldestActiveByUserID returns the unrevoked, unexpired session of a user
+// with the earliest last use
+func (r *PostgresSessionRepo) GetOldestActiveByUserID(ctx context.Context, userID uuid.UUID) (*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+		WHERE user_id = $1 AND is_revoked = FALSE AND expires_at > NOW()
+		ORDER BY last_used_at ASC LIMIT 1`
+	return scanSession(r.db.QueryRowContext(ctx, query, userID))
+}
+
+// Update persists changes to an existing session
+func (r *PostgresSessionRepo) Update(ctx context.Context, session *domain.Session) error {
+	query := `UPDATE sessions SET
+		refresh_token_hash = $2, previous_refresh_token_hash = $3, rotated_at = $4,
+		expires_at = $5, last_used_at = $6, is_revoked = $7, pin_failures = $8,
+		rotation_nonce = $9
+		WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query,
+		session.ID,
+		session.RefreshTokenHash,
+		session.PreviousRefreshTokenHash,
+		session.RotatedAt,
+		session.ExpiresAt,
+		session.LastUsedAt,
+		session.IsRevoked,
+		session.PinFailures,
+		session.RotationNonce,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrRefreshTokenTaken
+		}
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return notFound(expectRows(result), domain.ErrSessionNotFound)
+}
+
+// Delete removes a session
+func (r *PostgresSessionRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return notFound(expectRows(result), domain.ErrSessionNotFound)
+}
+
+// RevokeAllByUserID revokes every active session of a user
+func (r *PostgresSessionRepo) RevokeAllByUserID(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET is_revoked = TRUE WHERE user_id = $1 AND is_revoked = FALSE`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// RevokeByClient revokes every active session of a user opened through the client
+func (r *PostgresSessionRepo) RevokeByClient(ctx context.Context, userID uuid.UUID, clientID string) error {
+	query := `UPDATE sessions SET is_revoked = TRUE WHERE user_id = $1 AND client_id = $2 AND is_revoked = FALSE`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, clientID); err != nil {
+		return fmt.Errorf("failed to revoke client sessions: %w", err)
+	}
+	return nil
+}
+
+// ListActiveByDevice returns the unrevoked, unexpired sessions opened on the
+// device by any user, newest first
+func (r *PostgresSessionRepo) ListActiveByDevice(ctx context.Context, deviceID string) ([]*domain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+		WHERE device_id = $1 AND is_revoked = FALSE AND expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeByDevice revokes every active session opened on the device by any
+// user, returning the revoked sessions
+func (r *PostgresSessionRepo) RevokeByDevice(ctx context.Context, deviceID string) ([]*domain.Session, error) {
+	query := `UPDATE sessions SET is_revoked = TRUE
+		WHERE device_id = $1 AND is_revoked = FALSE
+		RETURNING ` + sessionColumns
+
+	rows, err := r.db.QueryContext(ctx, query, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke device sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// sessionScript is how a database of the sessions driver answers: writes
+// fail with execErr and reads find the same session row rows times
+type sessionScript struct {
+	execErr error
+	rows    int
+}
+
+var sessionScripts sync.Map
+
+func init() {
+	sql.Register("sessions", sessionDriver{})
+}
+
+type sessionDriver struct{}
+
+func (sessionDriver) Open(dsn string) (driver.Conn, error) {
+	script, _ := sessionScripts.Load(dsn)
+	return sessionConn{script.(sessionScript)}, nil
+}
+
+type sessionConn struct{ script sessionScript }
+
+func (c sessionConn) Prepare(string) (driver.Stmt, error) { return sessionStmt(c), nil }
+func (sessionConn) Close() error                          { return nil }
+func (sessionConn) Begin() (driver.Tx, error)             { return nil, errors.New("no transactions") }
+
+type sessionStmt struct{ script sessionScript }
+
+func (sessionStmt) Close() error  { return nil }
+func (sessionStmt) NumInput() int { return -1 }
+
+func (s sessionStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.script.execErr != nil {
+		return nil, s.script.execErr
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s sessionStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &sessionRows{left: s.script.rows}, nil
+}
+
+// sessionRows returns the same stored session row until none are left
+type sessionRows struct{ left int }
+
+func (*sessionRows) Columns() []string {
+	columns := strings.Split(sessionColumns, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
+
+func (*sessionRows) Close() error { return nil }
+
+func (r *sessionRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	now := time.Now()
+	copy(dest, []driver.Value{
+		"7c9e6679-7425-40de-944b-e07fc1f90ae7", "9b2d4f3e-1c5a-4b8e-8f6d-2a7c3e5b9d10",
+		domain.HashToken("refresh-token"), "", nil, now.Add(time.Hour),
+		now, now, "192.0.2.1", "test-agent", false, int64(0), []byte("{}"),
+		"password", false, false, "", int64(0), "", "", "",
+		"", false,
+	})
+	return nil
+}
+
+// openSessionRepo opens a session repository on a database following script
+func openSessionRepo(t *testing.T, script sessionScript) *PostgresSessionRepo {
+	t.Helper()
+	sessionScripts.Store(t.Name(), script)
+	db, err := sql.Open("sessions", t.Name())
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewPostgresSessionRepo(db)
+}
+
+func TestSessionWritesReportTakenRefreshTokens(t *testing.T) {
+	session := domain.NewSession(uuid.New(), "refresh-token", "192.0.2.1", "test-agent", time.Now().Add(time.Hour))
+	tests := []struct {
+		name    string
+		execErr error
+		taken   bool
+	}{
+		{"refresh token held by another session", &pq.Error{Code: uniqueViolation, Constraint: "sessions_refresh_token_hash_key"}, true},
+		{"other failure", &pq.Error{Code: "57014"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := openSessionRepo(t, sessionScript{execErr: tt.execErr})
+			for op, err := range map[string]error{
+				"Create": repo.Create(context.Background(), session),
+				"Update": repo.Update(context.Background(), session),
+			} {
+				if err == nil || errors.Is(err, domain.ErrRefreshTokenTaken) != tt.taken {
+					t.Errorf("%s = %v, taken %v, want %v", op, err, !tt.taken, tt.taken)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshTokenLookupsRefuseDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		wantErr error
+	}{
+		{"no session", 0, domain.ErrSessionNotFound},
+		{"one session", 1, nil},
+		{"two sessions", 2, domain.ErrAmbiguousRefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := openSessionRepo(t, sessionScript{rows: tt.rows})
+			lookups := map[string]func(context.Context, string) (*domain.Session, error){
+				"GetByRefreshToken":         repo.GetByRefreshToken,
+				"GetByPreviousRefreshToken": repo.GetByPreviousRefreshToken,
+			}
+			for name, lookup := range lookups {
+				session, err := lookup(context.Background(), "refresh-token")
+				if tt.wantErr == nil {
+					if err != nil || session.RefreshTokenHash != domain.HashToken("refresh-token") {
+						t.Errorf("%s = %+v, %v", name, session, err)
+					}
+				} else if !errors.Is(err, tt.wantErr) || session != nil {
+					t.Errorf("%s = %+v, %v, want %v", name, session, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
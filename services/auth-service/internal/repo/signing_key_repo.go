@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const signingKeyColumns = `id, generation, algorithm, material, created_at, activates_at, retires_at`
+
+// PostgresSigningKeyRepo implements domain.SigningKeyRepository on top of PostgreSQL
+type PostgresSigningKeyRepo struct {
+	db dbtx
+}
+
+// NewPostgresSigningKeyRepo creates a new PostgreSQL backed signing key repository
+func NewPostgresSigningKeyRepo(db *sql.DB) *PostgresSigningKeyRepo {
+	return &PostgresSigningKeyRepo{db: db}
+}
+
+func scanSigningKey(s scanner) (*domain.SigningKey, error) {
+	var key domain.SigningKey
+	err := s.Scan(
+		&key.ID,
+		&key.Generation,
+		&key.Algorithm,
+		&key.Material,
+		&key.CreatedAt,
+		&key.ActivatesAt,
+		&key.RetiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateNext inserts the key unless its generation is already taken. The
+// unique index on generation settles races the NOT EXISTS check misses.
+func (r *PostgresSigningKeyRepo) CreateNext(key *domain.SigningKey) error {
+	query := `INSERT INTO signing_keys (` + signingKeyColumns + `)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (SELECT 1 FROM signing_keys WHERE generation >= $2)`
+
+	result, err := r.db.Exec(query,
+		key.ID,
+		key.Generation,
+		key.Algorithm,
+		key.Material,
+		key.CreatedAt,
+		key.ActivatesAt,
+		key.RetiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+	return expectRows(result)
+}
+
+// RetireBefore schedules the retirement of the keys older than generation
+func (r *PostgresSigningKeyRepo) RetireBefore(generation int, retiresAt time.Time) error {
+	query := `UPDATE signing_keys SET retires_at = $2 WHERE generation < $1 AND retires_at IS NULL`
+
+	if _, err := r.db.Exec(query, generation, retiresAt); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+	return nil
+}
+
+// ListUnretired returns the keys not yet retired at now, oldest generation first
+func (r *PostgresSigningKeyRepo) ListUnretired(now time.Time) (domain.KeyRing, error) {
+	query := `SELECT ` + signingKeyColumns + ` FROM signing_keys
+		WHERE retires_at IS NULL OR retires_at > $1
+		ORDER BY generation`
+
+	rows, err := r.db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys domain.KeyRing
+	for rows.Next() {
+		key, err := scanSigningKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/migrations"
+)
+
+// openTestDB migrates a fresh schema of the scratch Postgres database named
+// by TEST_DATABASE_URL and returns a single connection pool bound to it.
+// Tests using it are skipped when the variable is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	// one connection, so the search path set below holds for every statement
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := db.ExecContext(ctx, `CREATE SCHEMA `+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { db.ExecContext(context.Background(), `DROP SCHEMA `+schema+` CASCADE`) })
+	if _, err := db.ExecContext(ctx, `SET search_path TO `+schema); err != nil {
+		t.Fatalf("set search path: %v", err)
+	}
+
+	if _, err := Migrate(ctx, db, migrations.FS); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const trustedDeviceColumns = `id, user_id, token_hash, name, created_at, last_used_at, expires_at, revoked_at`
+
+// PostgresTrustedDeviceRepo implements domain.TrustedDeviceRepository on top of PostgreSQL
+type PostgresTrustedDeviceRepo struct {
+	db dbtx
+}
+
+// NewPostgresTrustedDeviceRepo creates a new PostgreSQL backed trusted device repository
+func NewPostgresTrustedDeviceRepo(db *sql.DB) *PostgresTrustedDeviceRepo {
+	return &PostgresTrustedDeviceRepo{db: db}
+}
+
+func scanTrustedDevice(s scanner) (*domain.TrustedDevice, error) {
+	var device domain.TrustedDevice
+	err := s.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.TokenHash,
+		&device.Name,
+		&device.CreatedAt,
+		&device.LastUsedAt,
+		&device.ExpiresAt,
+		&device.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// Create inserts a new trusted device
+func (r *PostgresTrustedDeviceRepo) Create(device *domain.TrustedDevice) error {
+	query := `INSERT INTO trusted_devices (` + trustedDeviceColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(query,
+		device.ID,
+		device.UserID,
+		device.TokenHash,
+		device.Name,
+		device.CreatedAt,
+		device.LastUsedAt,
+		device.ExpiresAt,
+		device.RevokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create trusted device: %w", err)
+	}
+	return nil
+}
+
+// GetByTokenHash fetches a trusted device by the hash of its token
+func (r *PostgresTrustedDeviceRepo) GetByTokenHash(tokenHash string) (*domain.TrustedDevice, error) {
+	query := `SELECT ` + trustedDeviceColumns + ` FROM trusted_devices WHERE token_hash = $1`
+	return scanTrustedDevice(r.db.QueryRow(query, tokenHash))
+}
+
+// GetByID fetches a trusted device by its ID
+func (r *PostgresTrustedDeviceRepo) GetByID(id uuid.UUID) (*domain.TrustedDevice, error) {
+	query := `SELECT ` + trustedDeviceColumns + ` FROM trusted_devices WHERE id = $1`
+	return scanTrustedDevice(r.db.QueryRow(query, id))
+}
+
+// ListActiveByUserID returns the unrevoked, unexpired trusted devices of a user
+func (r *PostgresTrustedDeviceRepo) ListActiveByUserID(userID uuid.UUID) ([]*domain.TrustedDevice, error) {
+	query := `SELECT ` + trustedDeviceColumns + ` FROM trusted_devices
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*domain.TrustedDevice
+	for rows.Next() {
+		device, err := scanTrustedDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trusted device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// Update persists changes to an existing trusted device
+func (r *PostgresTrustedDeviceRepo) Update(device *domain.TrustedDevice) error {
+	result, err := r.db.Exec(`UPDATE trusted_devices SET last_used_at = $2, revoked_at = $3 WHERE id = $1`,
+		device.ID, device.LastUsedAt, device.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update trusted device: %w", err)
+	}
+	return expectRows(result)
+}
+
+// RevokeAllByUserID revokes every trusted device of a user
+func (r *PostgresTrustedDeviceRepo) RevokeAllByUserID(userID uuid.UUID) error {
+	_, err := r.db.Exec(`UPDATE trusted_devices SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke trusted devices: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// PostgresTransactor implements domain.Transactor on top of PostgreSQL
+type PostgresTransactor struct {
+	db    *sql.DB
+	reads *ReadRouter
+}
+
+// NewPostgresTransactor creates a transactor on the primary database of reads
+func NewPostgresTransactor(reads *ReadRouter) *PostgresTransactor {
+	return &PostgresTransactor{db: reads.Primary(), reads: reads}
+}
+
+// WithTx runs fn with repositories bound to a single transaction. The
+// transaction commits when fn returns nil and rolls back when it returns an
+// error or panics.
+func (t *PostgresTransactor) WithTx(ctx context.Context, fn func(repos domain.Repositories) error) (err error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	repos := domain.Repositories{
+		Users:              &PostgresUserRepo{db: tx, reads: t.reads, inTx: true},
+		VerificationTokens: &PostgresVerificationTokenRepo{db: tx},
+		Consents:           &PostgresConsentRepo{db: tx},
+		Audit:              &PostgresAuditRepo{db: tx},
+		Outbox:             &PostgresOutboxRepo{db: tx},
+		PasswordResets:     &PostgresPasswordResetRepo{db: tx},
+	}
+
+	if err := fn(repos); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
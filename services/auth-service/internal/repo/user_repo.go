@@ -0,0 +1,528 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// userFields lists the persisted user columns; the order must match userArgs and scanUser
+var userFields = []string{
+	"id", "email", "password_hash", "first_name", "last_name", "campus_id", "role",
+	"is_active", "is_verified", "created_at", "updated_at", "last_login_at",
+	"last_login_ip", "last_login_user_agent",
+	"recovery_email", "recovery_email_verified", "phone", "phone_verified",
+	"avatar_url", "timezone", "profile_hidden",
+	"deactivated_at", "disabled_by", "suspended_until", "banned_at", "merged_into",
+	"two_factor_enabled", "two_factor_secret", "must_change_password",
+	"password_length", "password_classes", "password_pepper_version",
+	"pin_hash", "pin_pepper_version", "deleted_at",
+}
+
+var userColumns = strings.Join(userFields, ", ")
+
+// PostgresUserRepo implements domain.UserRepository on top of PostgreSQL.
+// Reads may be served by a replica; see ReadRouter.
+type PostgresUserRepo struct {
+	db    dbtx
+	reads *ReadRouter
+	// inTx pins reads to db so a transaction sees its own writes
+	inTx bool
+}
+
+// NewPostgresUserRepo creates a new PostgreSQL backed user repository
+func NewPostgresUserRepo(reads *ReadRouter) *PostgresUserRepo {
+	return &PostgresUserRepo{db: reads.Primary(), reads: reads}
+}
+
+// reader returns where to read the given keys from
+func (r *PostgresUserRepo) reader(keys ...string) dbtx {
+	if r.inTx {
+		return r.db
+	}
+	return r.reads.Reader(keys...)
+}
+
+func userIDKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
+
+func userEmailKey(email string) string {
+	return "email:" + email
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(s scanner) (*domain.User, error) {
+	var user domain.User
+	err := s.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&user.FirstName,
+		&user.LastName,
+		&user.CampusID,
+		&user.Role,
+		&user.IsActive,
+		&user.IsVerified,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.LastLoginAt,
+		&user.LastLoginIP,
+		&user.LastLoginUserAgent,
+		&user.RecoveryEmail,
+		&user.RecoveryEmailVerified,
+		&user.Phone,
+		&user.PhoneVerified,
+		&user.AvatarURL,
+		&user.Timezone,
+		&user.ProfileHidden,
+		&user.DeactivatedAt,
+		&user.DisabledBy,
+		&user.SuspendedUntil,
+		&user.BannedAt,
+		&user.MergedInto,
+		&user.TwoFactorEnabled,
+		&user.TwoFactorSecret,
+		&user.MustChangePassword,
+		&user.PasswordStrength.Length,
+		&user.PasswordStrength.Classes,
+		&user.PepperVersion,
+		&user.PinHash,
+		&user.PinPepperVersion,
+		&user.DeletedAt,
+	)
+	if err != nil {
+		return nil, notFound(err, domain.ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+func userArgs(user *domain.User) []interface{} {
+	return []interface{}{
+		user.ID,
+		user.Email,
+		user.Password,
+		user.FirstName,
+		user.LastName,
+		user.CampusID,
+		user.Role,
+		user.IsActive,
+		user.IsVerified,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.LastLoginAt,
+		user.LastLoginIP,
+		user.LastLoginUserAgent,
+		user.RecoveryEmail,
+		user.RecoveryEmailVerified,
+		user.Phone,
+		user.PhoneVerified,
+		user.AvatarURL,
+		user.ChosenTimezone(),
+		user.ProfileHidden,
+		user.DeactivatedAt,
+		user.DisabledBy,
+		user.SuspendedUntil,
+		user.BannedAt,
+		user.MergedInto,
+		user.TwoFactorEnabled,
+		user.TwoFactorSecret,
+		user.MustChangePassword,
+		user.PasswordStrength.Length,
+		user.PasswordStrength.Classes,
+		user.PepperVersion,
+		user.PinHash,
+		user.PinPepperVersion,
+		user.DeletedAt,
+	}
+}
+
+// Create inserts a new user
+func (r *PostgresUserRepo) Create(ctx context.Context, user *domain.User) error {
+	query := `INSERT INTO users (` + userColumns + `) VALUES (` + placeholders(1, len(userFields)) + `)`
+
+	if _, err := r.db.ExecContext(ctx, query, userArgs(user)...); err != nil {
+		if isEmailViolation(err) {
+			return domain.ErrEmailAlreadyExists
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	r.reads.MarkWritten(userIDKey(user.ID), userEmailKey(user.Email))
+	return nil
+}
+
+// GetByID fetches a user by its ID
+func (r *PostgresUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+	return scanUser(r.reader(userIDKey(id)).QueryRowContext(ctx, query, id))
+}
+
+// GetByIDs fetches the users with the given IDs, in no particular order
+func (r *PostgresUserRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userIDKey(id)
+	}
+	rows, err := r.reader(keys...).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetByEmail fetches a user by email address
+func (r *PostgresUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	email = domain.NormalizeEmail(email)
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = $1 AND deleted_at IS NULL`
+	return scanUser(r.reader(userEmailKey(email)).QueryRowContext(ctx, query, email))
+}
+
+// GetByEmailAlias fetches a user whose email, with the dots and plus tag of
+// its local part dropped, is the stripped email. It scans the users of the
+// email's domain, so it is meant for the registration check only.
+func (r *PostgresUserRepo) GetByEmailAlias(ctx context.Context, stripped string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE split_part(lower(email), '@', 2) = split_part($1, '@', 2)
+		AND replace(split_part(split_part(lower(email), '@', 1), '+', 1), '.', '') = split_part($1, '@', 1)
+		AND deleted_at IS NULL
+		LIMIT 1`
+	return scanUser(r.db.QueryRowContext(ctx, query, stripped))
+}
+
+// GetByPhone fetches the user holding a verified phone number. Verified
+// numbers are unique, backed by a partial unique index on (phone) WHERE
+// phone_verified; the lookup always reads the primary.
+func (r *PostgresUserRepo) GetByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE phone = $1 AND phone_verified AND deleted_at IS NULL`
+	return scanUser(r.db.QueryRowContext(ctx, query, phone))
+}
+
+// Update persists changes to an existing user
+func (r *PostgresUserRepo) Update(ctx context.Context, user *domain.User) error {
+	query := `UPDATE users SET (` + strings.Join(userFields[1:], ", ") + `) = (` +
+		placeholders(2, len(userFields)-1) + `) WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userArgs(user)...)
+	if err != nil {
+		if isEmailViolation(err) {
+			return domain.ErrEmailAlreadyExists
+		}
+		if isPhoneViolation(err) {
+			return domain.ErrPhoneAlreadyVerified
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	r.reads.MarkWritten(userIDKey(user.ID), userEmailKey(user.Email))
+	return notFound(expectRows(result), domain.ErrUserNotFound)
+}
+
+// SetRole assigns role to all the given users in a single statement, so
+// either every user is changed or none is
+func (r *PostgresUserRepo) SetRole(ctx context.Context, ids []uuid.UUID, role domain.Role) error {
+	query := `UPDATE users SET role = $1, updated_at = NOW() WHERE id = ANY($2)`
+
+	if _, err := r.db.ExecContext(ctx, query, role, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userIDKey(id)
+	}
+	r.reads.MarkWritten(keys...)
+	return nil
+}
+
+// UseTOTPStep advances the user's last accepted TOTP step in one statement,
+// so of two concurrent uses of a code only one succeeds
+func (r *PostgresUserRepo) UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) error {
+	query := `UPDATE users SET two_factor_last_step = $2
+		WHERE id = $1 AND deleted_at IS NULL AND (two_factor_last_step IS NULL OR two_factor_last_step < $2)`
+
+	result, err := r.db.ExecContext(ctx, query, id, step)
+	if err != nil {
+		return fmt.Errorf("failed to use TOTP step: %w", err)
+	}
+	return expectRows(result)
+}
+
+// Delete soft-deletes and deactivates a user, keeping the row for the
+// references other services hold and for the audit history
+func (r *PostgresUserRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET deleted_at = NOW(), is_active = FALSE, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	r.reads.MarkWritten(userIDKey(id))
+	return notFound(expectRows(result), domain.ErrUserNotFound)
+}
+
+// Restore undoes a soft delete. The user is active again unless they had
+// deactivated their account before it was deleted.
+func (r *PostgresUserRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET deleted_at = NULL, is_active = deactivated_at IS NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+	r.reads.MarkWritten(userIDKey(id))
+	return notFound(expectRows(result), domain.ErrUserNotFound)
+}
+
+// NormalizeEmails lowercases and trims the emails stored before emails were
+// normalized. Rows whose normalized email another user already has are left
+// for an operator to resolve.
+func (r *PostgresUserRepo) NormalizeEmails(ctx context.Context) (normalized, conflicting int64, err error) {
+	query := `UPDATE users u SET email = lower(trim(u.email)), updated_at = NOW()
+		WHERE u.email <> lower(trim(u.email))
+		AND NOT EXISTS (
+			SELECT 1 FROM users o WHERE o.id <> u.id AND lower(trim(o.email)) = lower(trim(u.email))
+		)`
+
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to normalize emails: %w", err)
+	}
+	if normalized, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE email <> lower(trim(email))`).Scan(&conflicting); err != nil {
+		return normalized, 0, fmt.Errorf("failed to count unnormalized emails: %w", err)
+	}
+	return normalized, conflicting, nil
+}
+
+// PurgeDeleted permanently removes the users soft-deleted before olderThan
+func (r *PostgresUserRepo) PurgeDeleted(ctx context.Context, olderThan time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE deleted_at < $1`, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// List returns a page of users ordered by creation time
+func (r *PostgresUserRepo) List(ctx context.Context, limit, offset int) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+
+	rows, err := r.reader().QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// ListByRole returns a page of users with the given role ordered by creation time
+func (r *PostgresUserRepo) ListByRole(ctx context.Context, role domain.Role, limit, offset int) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE role = $1 AND deleted_at IS NULL ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+
+	rows, err := r.reader().QueryContext(ctx, query, role, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users by role: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// userFilterCondition matches the users of a domain.UserFilter, given its
+// role, is_active, is_verified and campus_id as $1 to $4
+const userFilterCondition = `deleted_at IS NULL
+		AND ($1::text IS NULL OR role = $1)
+		AND ($2::boolean IS NULL OR is_active = $2)
+		AND ($3::boolean IS NULL OR is_verified = $3)
+		AND ($4::text IS NULL OR campus_id = $4)`
+
+// ListFiltered returns a page of the users matching the filter ordered by
+// creation time, and the number of users matching it
+func (r *PostgresUserRepo) ListFiltered(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int, error) {
+	args := []interface{}{filter.Role, filter.IsActive, filter.IsVerified, filter.CampusID}
+
+	var total int
+	if err := r.reader().QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+userFilterCondition, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + userFilterCondition + `
+		ORDER BY created_at DESC LIMIT $5 OFFSET $6`
+
+	rows, err := r.reader().QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// ListUnverified returns active, unbanned users with an unverified email after
+// the given ID, in ID order so callers can page through them with a cursor
+func (r *PostgresUserRepo) ListUnverified(ctx context.Context, campusID *string, after uuid.UUID, limit int) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE NOT is_verified AND is_active AND banned_at IS NULL AND deleted_at IS NULL
+			AND ($1::text IS NULL OR campus_id = $1) AND id > $2
+		ORDER BY id LIMIT $3`
+
+	rows, err := r.reader().QueryContext(ctx, query, campusID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unverified users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// ListByCampus returns the users of a campus after the given ID, in ID order
+// so callers can page through them with a cursor
+func (r *PostgresUserRepo) ListByCampus(ctx context.Context, campusID string, after uuid.UUID, limit int) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE campus_id = $1 AND id > $2 AND deleted_at IS NULL ORDER BY id LIMIT $3`
+
+	rows, err := r.reader().QueryContext(ctx, query, campusID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users by campus: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// SignupFunnel counts the registrations in [From, To) per UTC day, and per
+// campus when asked. A user counts as logged in once last_login_at is set.
+// The range scan relies on the index on users (created_at).
+func (r *PostgresUserRepo) SignupFunnel(ctx context.Context, query domain.SignupFunnelQuery) ([]*domain.SignupFunnelRow, error) {
+	campus := "NULL::text"
+	if query.ByCampus {
+		campus = "campus_id"
+	}
+	sqlQuery := `SELECT (created_at AT TIME ZONE 'UTC')::date AS day, ` + campus + ` AS campus,
+			COUNT(*), COUNT(*) FILTER (WHERE is_verified), COUNT(last_login_at)
+		FROM users
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY day, campus
+		ORDER BY day, campus`
+
+	rows, err := r.reader().QueryContext(ctx, sqlQuery, query.From, query.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute signup funnel: %w", err)
+	}
+	defer rows.Close()
+
+	var funnel []*domain.SignupFunnelRow
+	for rows.Next() {
+		var row domain.SignupFunnelRow
+		var day time.Time
+		if err := rows.Scan(&day, &row.CampusID, &row.Registered, &row.Verified, &row.FirstLogin); err != nil {
+			return nil, fmt.Errorf("failed to scan signup funnel: %w", err)
+		}
+		row.Day = day.Format("2006-01-02")
+		funnel = append(funnel, &row)
+	}
+	return funnel, rows.Err()
+}
+
+// CountByRole returns the number of users with the given role
+func (r *PostgresUserRepo) CountByRole(ctx context.Context, role domain.Role) (int, error) {
+	var count int
+	if err := r.reader().QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = $1 AND deleted_at IS NULL`, role).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users by role: %w", err)
+	}
+	return count, nil
+}
+
+// LockRole locks the rows of the users holding the role; it only holds them
+// when run in a transaction
+func (r *PostgresUserRepo) LockRole(ctx context.Context, role domain.Role) (int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM users WHERE role = $1 AND deleted_at IS NULL FOR UPDATE`, role)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock users by role: %w", err)
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		count++
+	}
+	return count, rows.Err()
+}
+
+// expectRows reports sql.ErrNoRows when a write did not touch any row
+func expectRows(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
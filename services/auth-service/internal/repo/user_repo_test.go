@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// seedUser stores a user registered at createdAt
+func seedUser(t *testing.T, users *PostgresUserRepo, campusID string, createdAt time.Time, verified, loggedIn bool) {
+	t.Helper()
+	user := &domain.User{
+		ID:         uuid.New(),
+		Email:      uuid.NewString() + "@example.edu",
+		CampusID:   &campusID,
+		Role:       domain.RoleStudent,
+		IsActive:   true,
+		IsVerified: verified,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}
+	if loggedIn {
+		lastLogin := createdAt.Add(time.Hour)
+		user.LastLoginAt = &lastLogin
+	}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+func TestSignupFunnelAggregatesPerDay(t *testing.T) {
+	db := openTestDB(t)
+	users := NewPostgresUserRepo(NewReadRouter(db, nil, time.Second))
+	eastern := time.FixedZone("EST", -5*60*60)
+
+	seedUser(t, users, "north", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), true, true)
+	seedUser(t, users, "south", time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC), false, false)
+	// still March 1st locally, but days are counted in UTC
+	seedUser(t, users, "north", time.Date(2026, 3, 1, 23, 30, 0, 0, eastern), false, true)
+	seedUser(t, users, "north", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), true, false)
+	// outside [From, To)
+	seedUser(t, users, "north", time.Date(2026, 2, 28, 23, 59, 59, 0, time.UTC), true, true)
+	seedUser(t, users, "north", time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), true, true)
+
+	query := domain.SignupFunnelQuery{
+		From: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		byCampus bool
+		want     []string
+	}{
+		{"per day", false, []string{
+			"2026-03-01 - 2/1/1",
+			"2026-03-02 - 2/1/1",
+		}},
+		{"per day and campus", true, []string{
+			"2026-03-01 north 1/1/1",
+			"2026-03-01 south 1/0/0",
+			"2026-03-02 north 2/1/1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query.ByCampus = tt.byCampus
+			funnel, err := users.SignupFunnel(context.Background(), query)
+			if err != nil {
+				t.Fatalf("SignupFunnel: %v", err)
+			}
+
+			var got []string
+			for _, row := range funnel {
+				campus := "-"
+				if row.CampusID != nil {
+					campus = *row.CampusID
+				}
+				got = append(got, fmt.Sprintf("%s %s %d/%d/%d", row.Day, campus, row.Registered, row.Verified, row.FirstLogin))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("funnel = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The request deadline reaches the database: a repository call made after
+// it fails with the deadline error, which the API answers with a 504
+func TestUserRepoHonoursRequestDeadline(t *testing.T) {
+	primary, _ := openRecording(t, "primary")
+	users := NewPostgresUserRepo(NewReadRouter(primary, nil, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if _, err := users.GetByID(ctx, uuid.New()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetByID past the deadline = %v, want context.DeadlineExceeded", err)
+	}
+	if err := users.Update(ctx, &domain.User{ID: uuid.New()}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Update past the deadline = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// PostgresVerificationReminderRepo implements domain.VerificationReminderRepository on top of PostgreSQL
+type PostgresVerificationReminderRepo struct {
+	db dbtx
+}
+
+// NewPostgresVerificationReminderRepo creates a new PostgreSQL backed verification reminder repository
+func NewPostgresVerificationReminderRepo(db *sql.DB) *PostgresVerificationReminderRepo {
+	return &PostgresVerificationReminderRepo{db: db}
+}
+
+// Get fetches the reminder count of a user
+func (r *PostgresVerificationReminderRepo) Get(userID uuid.UUID) (*domain.VerificationReminder, error) {
+	query := `SELECT user_id, count, last_sent_at FROM verification_reminders WHERE user_id = $1`
+
+	var reminder domain.VerificationReminder
+	if err := r.db.QueryRow(query, userID).Scan(&reminder.UserID, &reminder.Count, &reminder.LastSentAt); err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// Record increments the reminder count of a user, starting it at one
+func (r *PostgresVerificationReminderRepo) Record(userID uuid.UUID, sentAt time.Time) error {
+	query := `INSERT INTO verification_reminders (user_id, count, last_sent_at) VALUES ($1, 1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			count = verification_reminders.count + 1,
+			last_sent_at = EXCLUDED.last_sent_at`
+
+	if _, err := r.db.Exec(query, userID, sentAt); err != nil {
+		return fmt.Errorf("failed to record verification reminder: %w", err)
+	}
+	return nil
+}
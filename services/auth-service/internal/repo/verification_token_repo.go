@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const verificationTokenColumns = `id, user_id, purpose, target, token_hash, expires_at, used_at, created_at`
+
+// PostgresVerificationTokenRepo implements domain.VerificationTokenRepository on top of PostgreSQL
+type PostgresVerificationTokenRepo struct {
+	db dbtx
+}
+
+// NewPostgresVerificationTokenRepo creates a new PostgreSQL backed verification token repository
+func NewPostgresVerificationTokenRepo(db *sql.DB) *PostgresVerificationTokenRepo {
+	return &PostgresVerificationTokenRepo{db: db}
+}
+
+func scanVerificationToken(s scanner) (*domain.VerificationToken, error) {
+	var token domain.VerificationToken
+	err := s.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.Target,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Create inserts a new verification token
+func (r *PostgresVerificationTokenRepo) Create(token *domain.VerificationToken) error {
+	query := `INSERT INTO verification_tokens (` + verificationTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(query,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.Target,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.UsedAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create verification token: %w", err)
+	}
+	return nil
+}
+
+// GetByTokenHash fetches a verification token by the hash of its token
+func (r *PostgresVerificationTokenRepo) GetByTokenHash(tokenHash string) (*domain.VerificationToken, error) {
+	query := `SELECT ` + verificationTokenColumns + ` FROM verification_tokens WHERE token_hash = $1`
+	return scanVerificationToken(r.db.QueryRow(query, tokenHash))
+}
+
+// GetLatestByUserID fetches the most recently issued token of a user for a purpose
+func (r *PostgresVerificationTokenRepo) GetLatestByUserID(userID uuid.UUID, purpose domain.TokenPurpose) (*domain.VerificationToken, error) {
+	query := `SELECT ` + verificationTokenColumns + ` FROM verification_tokens
+		WHERE user_id = $1 AND purpose = $2
+		ORDER BY created_at DESC LIMIT 1`
+	return scanVerificationToken(r.db.QueryRow(query, userID, purpose))
+}
+
+// Update persists changes to an existing verification token
+func (r *PostgresVerificationTokenRepo) Update(token *domain.VerificationToken) error {
+	result, err := r.db.Exec(`UPDATE verification_tokens SET used_at = $2 WHERE id = $1`, token.ID, token.UsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update verification token: %w", err)
+	}
+	return expectRows(result)
+}
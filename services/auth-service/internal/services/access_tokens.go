@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// accessTokenPurgeInterval is how often expired opaque access tokens are deleted
+const accessTokenPurgeInterval = time.Hour
+
+// AccessTokens issues access tokens in the format of each session and
+// validates tokens of every format. JWTs are signed with the service's keys,
+// or with the keys of the other algorithm for clients registered for it;
+// opaque tokens are stored and looked up. Tokens of any format revoked
+// before their expiry are blacklisted by their jti.
+type AccessTokens struct {
+	keys      *SigningKeys
+	alternate *SigningKeys
+	repo      domain.AccessTokenRepository
+	blacklist TokenBlacklist
+	// ttl is the lifetime of the tokens issued
+	ttl time.Duration
+}
+
+// NewAccessTokens creates AccessTokens signing default JWTs with keys and
+// issuing tokens valid for ttl. alternate holds the keys of the other
+// algorithm, or nil when only one algorithm is configured.
+func NewAccessTokens(keys, alternate *SigningKeys, repo domain.AccessTokenRepository, blacklist TokenBlacklist, ttl time.Duration) *AccessTokens {
+	return &AccessTokens{keys: keys, alternate: alternate, repo: repo, blacklist: blacklist, ttl: ttl}
+}
+
+// Token types reported by introspection, as the token_type_hint values of
+// RFC 7009 name them
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// TokenIntrospection describes an access or refresh token, as returned by
+// the introspection endpoint; inactive tokens carry nothing else
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	// JTI identifies the token: the jti of an access token, or the session
+	// of a refresh token
+	JTI       string `json:"jti,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// Supports reports whether tokens of the format can be issued with the
+// configured keys
+func (t *AccessTokens) Supports(format domain.TokenFormat) bool {
+	if format == domain.TokenFormatDefault || format == domain.TokenFormatOpaque {
+		return true
+	}
+	return t.signingKeys(format) != nil
+}
+
+// signingKeys returns the keys JWTs of the format are signed with, or nil
+// when its algorithm is not configured
+func (t *AccessTokens) signingKeys(format domain.TokenFormat) *SigningKeys {
+	var algorithm string
+	switch format {
+	case domain.TokenFormatDefault:
+		return t.keys
+	case domain.TokenFormatJWTHS256:
+		algorithm = jwt.SigningMethodHS256.Alg()
+	case domain.TokenFormatJWTRS256:
+		algorithm = jwt.SigningMethodRS256.Alg()
+	default:
+		return nil
+	}
+
+	for _, keys := range []*SigningKeys{t.keys, t.alternate} {
+		if keys != nil && keys.Algorithm() == algorithm {
+			return keys
+		}
+	}
+	return nil
+}
+
+// issue returns an access token carrying the claims, in the session's format
+func (t *AccessTokens) issue(session *domain.Session, claims Claims) (string, error) {
+	if session.TokenFormat == domain.TokenFormatOpaque {
+		return t.issueOpaque(session, claims)
+	}
+
+	keys := t.signingKeys(session.TokenFormat)
+	if keys == nil {
+		return "", fmt.Errorf("no signing keys for access token format %q", session.TokenFormat)
+	}
+	accessToken, err := keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return accessToken, nil
+}
+
+// issueOpaque stores the claims under a new random reference token
+func (t *AccessTokens) issueOpaque(session *domain.Session, claims Claims) (string, error) {
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode access token claims: %w", err)
+	}
+	accessToken, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	if err := t.repo.Create(domain.NewAccessToken(accessToken, session.ID, encoded, claims.ExpiresAt.Time)); err != nil {
+		return "", err
+	}
+	return accessToken, nil
+}
+
+// Validate returns the claims of a valid access token of any format. JWTs
+// are checked against the keys of both algorithms, each accepting only its
+// own algorithm; anything else is looked up as an opaque token, which also
+// stops validating once its session is revoked. Blacklisted tokens are
+// rejected whatever their format, as are tokens of a user issued before their
+// user's tokens were revoked.
+func (t *AccessTokens) Validate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := t.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID != "" {
+		revoked, err := t.blacklist.IsRevoked(claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token blacklist: %w", err)
+		}
+		if revoked {
+			return nil, ErrInvalidToken
+		}
+	}
+	if claims.IssuedAt != nil {
+		revoked, err := t.blacklist.IsUserRevoked(claims.UserID, claims.IssuedAt.Time)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token blacklist: %w", err)
+		}
+		if revoked {
+			return nil, ErrInvalidToken
+		}
+	}
+	return claims, nil
+}
+
+// parse returns the claims of a JWT or opaque access token
+func (t *AccessTokens) parse(tokenString string) (*Claims, error) {
+	if !isJWT(tokenString) {
+		return t.validateOpaque(tokenString)
+	}
+
+	claims, err := ParseAccessToken(tokenString, t.keys)
+	if err != nil && t.alternate != nil {
+		claims, err = ParseAccessToken(tokenString, t.alternate)
+	}
+	return claims, err
+}
+
+// revoke blacklists the token of the claims until it expires
+func (t *AccessTokens) revoke(claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return t.blacklist.Revoke(claims.ID, claims.ExpiresAt.Time)
+}
+
+// revokeUser blacklists every access token of the user issued so far; their
+// sessions stay valid and refresh into new tokens
+func (t *AccessTokens) revokeUser(userID uuid.UUID) error {
+	now := time.Now()
+	return t.blacklist.RevokeUser(userID.String(), now, now.Add(t.ttl))
+}
+
+// validateOpaque looks up an opaque token and decodes its claims
+func (t *AccessTokens) validateOpaque(tokenString string) (*Claims, error) {
+	stored, err := t.repo.GetActive(domain.HashToken(tokenString), time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(stored.Claims, claims); err != nil {
+		return nil, fmt.Errorf("failed to decode access token claims: %w", err)
+	}
+	// the lookup checked the expiry; nbf is checked here, as the JWT parser
+	// checks it for signed tokens
+	if claims.NotBefore != nil && time.Now().Before(claims.NotBefore.Time) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Introspect describes an access token of any format. Invalid, expired and
+// revoked tokens are reported as inactive without a reason.
+func (t *AccessTokens) Introspect(ctx context.Context, tokenString string) (*TokenIntrospection, error) {
+	claims, err := t.Validate(ctx, tokenString)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return &TokenIntrospection{}, nil
+		}
+		return nil, err
+	}
+
+	introspection := &TokenIntrospection{
+		Active:    true,
+		TokenType: TokenTypeAccess,
+		Subject:   claims.Subject,
+		JTI:       claims.ID,
+		Email:     claims.Email,
+		Role:      claims.Role,
+		SessionID: claims.SessionID,
+		Scope:     claims.Scope,
+	}
+	if claims.IssuedAt != nil {
+		introspection.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.ExpiresAt != nil {
+		introspection.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	return introspection, nil
+}
+
+// Run deletes expired opaque access tokens periodically until ctx is done
+func (t *AccessTokens) Run(ctx context.Context) {
+	ticker := time.NewTicker(accessTokenPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := t.repo.DeleteExpired(time.Now()); err != nil {
+				log.Printf("Failed to purge expired access tokens: %v", err)
+			}
+		}
+	}
+}
+
+// isJWT reports whether the token has the three dot separated parts of a
+// JWS; opaque tokens are unpadded base64url and never contain a dot
+func isJWT(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 2
+}
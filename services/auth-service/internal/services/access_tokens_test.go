@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// accessClaims returns the claims of an access token of the session
+func accessClaims(session *domain.Session, ttl time.Duration) Claims {
+	now := time.Now()
+	return Claims{
+		UserID:    session.UserID.String(),
+		Email:     "ada@example.edu",
+		Role:      string(domain.RoleStudent),
+		SessionID: session.ID.String(),
+		Scope:     "read",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   session.UserID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// jwtAlgorithm returns the alg header of a JWT, empty for other tokens
+func jwtAlgorithm(t *testing.T, token string) string {
+	t.Helper()
+	if !isJWT(token) {
+		return ""
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	return parsed.Method.Alg()
+}
+
+func TestAccessTokenFormats(t *testing.T) {
+	sessions := newMemSessionRepo()
+	repo := newMemAccessTokenRepo(sessions)
+	tokens := NewAccessTokens(NewHMACKeys("test-secret"), NewRSAKeys(generateRSAKey(t)), repo, NewMemoryBlacklist(), 15*time.Minute)
+	ctx := context.Background()
+
+	tests := []struct {
+		format  domain.TokenFormat
+		wantAlg string
+	}{
+		{domain.TokenFormatDefault, "HS256"},
+		{domain.TokenFormatJWTHS256, "HS256"},
+		{domain.TokenFormatJWTRS256, "RS256"},
+		{domain.TokenFormatOpaque, ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			session := domain.NewSession(uuid.New(), "refresh-token", "192.0.2.1", "test-agent", farFuture())
+			session.TokenFormat = tt.format
+			sessions.Create(ctx, session)
+			claims := accessClaims(session, 15*time.Minute)
+
+			if !tokens.Supports(tt.format) {
+				t.Fatalf("format %q not supported with both keys configured", tt.format)
+			}
+			token, err := tokens.issue(session, claims)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			if alg := jwtAlgorithm(t, token); alg != tt.wantAlg {
+				t.Errorf("token algorithm = %q, want %q", alg, tt.wantAlg)
+			}
+			if tt.format == domain.TokenFormatOpaque && repo.tokens[domain.HashToken(token)] == nil {
+				t.Error("opaque token not stored by its hash")
+			}
+
+			validated, err := tokens.Validate(ctx, token)
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if validated.SessionID != claims.SessionID || validated.ID != claims.ID || validated.Scope != "read" {
+				t.Errorf("validated claims = %+v, want those issued", validated)
+			}
+			introspection, err := tokens.Introspect(ctx, token)
+			if err != nil || !introspection.Active || introspection.TokenType != TokenTypeAccess || introspection.SessionID != claims.SessionID || introspection.ExpiresAt != claims.ExpiresAt.Unix() {
+				t.Errorf("introspection = %+v, %v", introspection, err)
+			}
+
+			if err := tokens.revoke(validated); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+			if _, err := tokens.Validate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Validate of a revoked token = %v, want ErrInvalidToken", err)
+			}
+			if introspection, err := tokens.Introspect(ctx, token); err != nil || introspection.Active {
+				t.Errorf("introspection of a revoked token = %+v, %v, want inactive", introspection, err)
+			}
+		})
+	}
+}
+
+func TestAccessTokenFormatsNeedTheirKeys(t *testing.T) {
+	hmacOnly := NewAccessTokens(NewHMACKeys("test-secret"), nil, nil, NewMemoryBlacklist(), 15*time.Minute)
+	rsaOnly := NewAccessTokens(NewRSAKeys(generateRSAKey(t)), nil, nil, NewMemoryBlacklist(), 15*time.Minute)
+
+	if hmacOnly.Supports(domain.TokenFormatJWTRS256) || rsaOnly.Supports(domain.TokenFormatJWTHS256) {
+		t.Error("a JWT format is supported without the keys of its algorithm")
+	}
+	if !hmacOnly.Supports(domain.TokenFormatOpaque) || !rsaOnly.Supports(domain.TokenFormatDefault) {
+		t.Error("opaque or default tokens not supported")
+	}
+
+	session := domain.NewSession(uuid.New(), "refresh-token", "192.0.2.1", "test-agent", farFuture())
+	session.TokenFormat = domain.TokenFormatJWTRS256
+	if _, err := hmacOnly.issue(session, accessClaims(session, time.Minute)); err == nil {
+		t.Error("RS256 token issued without an RSA key")
+	}
+
+	// a token of the other algorithm is not accepted by a service without its keys
+	session.TokenFormat = domain.TokenFormatDefault
+	token, err := rsaOnly.issue(session, accessClaims(session, time.Minute))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if _, err := hmacOnly.Validate(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("RS256 token validated by HMAC keys: %v", err)
+	}
+}
+
+func TestOpaqueAccessTokenLifetime(t *testing.T) {
+	sessions := newMemSessionRepo()
+	tokens := NewAccessTokens(NewHMACKeys("test-secret"), nil, newMemAccessTokenRepo(sessions), NewMemoryBlacklist(), 15*time.Minute)
+	ctx := context.Background()
+	session := domain.NewSession(uuid.New(), "refresh-token", "192.0.2.1", "test-agent", farFuture())
+	session.TokenFormat = domain.TokenFormatOpaque
+	sessions.Create(ctx, session)
+
+	expired, err := tokens.issue(session, accessClaims(session, -time.Minute))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if _, err := tokens.Validate(ctx, expired); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate of an expired opaque token = %v, want ErrInvalidToken", err)
+	}
+
+	token, err := tokens.issue(session, accessClaims(session, time.Minute))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if strings.Contains(token, ".") {
+		t.Errorf("opaque token %q looks like a JWT", token)
+	}
+	session.Revoke()
+	sessions.Update(ctx, session)
+	if _, err := tokens.Validate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate of a token of a revoked session = %v, want ErrInvalidToken", err)
+	}
+	if _, err := tokens.Validate(ctx, "not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate of an unknown token = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshKeepsSessionTokenFormat(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+
+	result := f.login(t, user.Email, "")
+	session, err := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("session of the login: %v", err)
+	}
+	session.TokenFormat = domain.TokenFormatOpaque
+	f.sessions.Update(ctx, session)
+
+	tokens, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if isJWT(tokens.AccessToken) {
+		t.Fatalf("refresh of an opaque session issued a JWT")
+	}
+	claims, err := f.service.tokens.Validate(ctx, tokens.AccessToken)
+	if err != nil || claims.SessionID != session.ID.String() || claims.UserID != user.ID.String() {
+		t.Errorf("refreshed opaque token = %+v, %v", claims, err)
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+func TestIssueActionToken(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+
+	issued, err := f.service.IssueActionToken(context.Background(), user.ID, "media.upload_avatar")
+	if err != nil {
+		t.Fatalf("IssueActionToken: %v", err)
+	}
+	if issued.Action != "media.upload_avatar" || issued.Audience != "media-service" || issued.ExpiresIn != int(actionTokenTTL.Seconds()) {
+		t.Errorf("action token = %+v", issued)
+	}
+
+	claims, err := ParseActionToken(issued.Token, f.service.keys, "media-service")
+	if err != nil {
+		t.Fatalf("ParseActionToken: %v", err)
+	}
+	if claims.Action != "media.upload_avatar" || claims.Subject != user.ID.String() {
+		t.Errorf("claims = action %q, subject %q", claims.Action, claims.Subject)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != actionTokenTTL {
+		t.Errorf("token lives %v, want %v", ttl, actionTokenTTL)
+	}
+
+	// the token is only good at the service of its action, and only for actions
+	if _, err := ParseActionToken(issued.Token, f.service.keys, "listing-service"); err == nil {
+		t.Error("action token accepted by another service")
+	}
+	if _, err := f.service.tokens.Validate(context.Background(), issued.Token); err == nil {
+		t.Error("action token accepted as an access token")
+	}
+}
+
+func TestIssueActionTokenChecksPermissions(t *testing.T) {
+	student := newTestUser(t, "ada@example.edu", "password-123")
+	moderator := newTestUser(t, "grace@example.edu", "password-123")
+	moderator.Role = domain.RoleModerator
+	banned := newTestUser(t, "alan@example.edu", "password-123")
+	bannedAt := time.Now()
+	banned.BannedAt = &bannedAt
+	f := newAuthFixture(t, AuthConfig{}, student, moderator, banned)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		action string
+		want   error
+	}{
+		{"moderator moderates listings", moderator.ID, "listing.moderate", nil},
+		{"student moderates listings", student.ID, "listing.moderate", ErrActionNotPermitted},
+		{"unknown action", student.ID, "media.delete_everything", ErrUnknownAction},
+		{"banned user", banned.ID, "media.upload_avatar", ErrAccountBanned},
+		{"unknown user", uuid.New(), "media.upload_avatar", ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := f.service.IssueActionToken(ctx, tt.userID, tt.action)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("IssueActionToken = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && token != nil {
+				t.Error("a token was issued along with the error")
+			}
+		})
+	}
+}
+
+func TestParseActionTokenRejectsExpired(t *testing.T) {
+	keys := NewHMACKeys("test-secret")
+	issuedAt := time.Now().Add(-2 * actionTokenTTL)
+	token, err := keys.Sign(ActionClaims{
+		Action: "media.upload_avatar",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			Audience:  jwt.ClaimStrings{"media-service"},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(actionTokenTTL)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if _, err := ParseActionToken(token, keys, "media-service"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ParseActionToken = %v, want ErrInvalidToken", err)
+	}
+}
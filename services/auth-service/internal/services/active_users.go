@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// activeUserSweepInterval is how often ActiveUsers drops its expired entries
+const activeUserSweepInterval = time.Minute
+
+// activeUserEntry is a cached answer of ActiveUsers
+type activeUserEntry struct {
+	active  bool
+	expires time.Time
+}
+
+// ActiveUsers tells whether the account behind an access token is still
+// active, caching each answer for ttl so authenticating a request rarely
+// reads the database. Each instance only forgets the users changed through
+// it; other instances notice a deactivation within ttl.
+type ActiveUsers struct {
+	userRepo  domain.UserRepository
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[uuid.UUID]activeUserEntry
+	lastSweep time.Time
+}
+
+// NewActiveUsers creates an ActiveUsers caching answers for ttl; zero reads
+// the database on every call
+func NewActiveUsers(userRepo domain.UserRepository, ttl time.Duration) *ActiveUsers {
+	return &ActiveUsers{
+		userRepo:  userRepo,
+		ttl:       ttl,
+		entries:   make(map[uuid.UUID]activeUserEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// IsActive reports whether the user's account is active. Deleted and unknown
+// users are not.
+func (a *ActiveUsers) IsActive(ctx context.Context, userID uuid.UUID) (bool, error) {
+	now := time.Now()
+	a.mu.Lock()
+	entry, ok := a.entries[userID]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.active, nil
+	}
+
+	active := false
+	user, err := a.userRepo.GetByID(ctx, userID)
+	switch {
+	case err == nil:
+		active = user.IsActive
+	case !errors.Is(err, domain.ErrUserNotFound):
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if a.ttl > 0 {
+		a.mu.Lock()
+		a.entries[userID] = activeUserEntry{active: active, expires: now.Add(a.ttl)}
+		a.sweep(now)
+		a.mu.Unlock()
+	}
+	return active, nil
+}
+
+// Forget drops the cached answer for the user, whose account just changed
+func (a *ActiveUsers) Forget(userID uuid.UUID) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	delete(a.entries, userID)
+	a.mu.Unlock()
+}
+
+// sweep drops expired entries; callers hold the lock
+func (a *ActiveUsers) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < activeUserSweepInterval {
+		return
+	}
+	for userID, entry := range a.entries {
+		if !now.Before(entry.expires) {
+			delete(a.entries, userID)
+		}
+	}
+	a.lastSweep = now
+}
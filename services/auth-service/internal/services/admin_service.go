@@ -0,0 +1,820 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// AdminService backs the administrator dashboards and user management
+type AdminService struct {
+	userRepo    domain.UserRepository
+	sessionRepo domain.SessionRepository
+	auditRepo   domain.AuditRepository
+	cutoffRepo  domain.RevocationCutoffRepository
+	transactor  domain.Transactor
+	publisher   events.Publisher
+	// passwordPolicy decides whether an elevated user must pick a stronger password
+	passwordPolicy domain.PasswordPolicy
+	tokens         *AccessTokens
+	// activeUsers is told about deactivations so tokens stop working at once
+	activeUsers *ActiveUsers
+}
+
+// NewAdminService creates a new AdminService
+func NewAdminService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, auditRepo domain.AuditRepository, cutoffRepo domain.RevocationCutoffRepository, transactor domain.Transactor, publisher events.Publisher, passwordPolicy domain.PasswordPolicy, tokens *AccessTokens, activeUsers *ActiveUsers) *AdminService {
+	return &AdminService{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		auditRepo:      auditRepo,
+		cutoffRepo:     cutoffRepo,
+		transactor:     transactor,
+		publisher:      publisher,
+		passwordPolicy: passwordPolicy,
+		tokens:         tokens,
+		activeUsers:    activeUsers,
+	}
+}
+
+// Outcomes of a bulk role assignment for a single user
+const (
+	RoleAssignmentUpdated   = "updated"
+	RoleAssignmentUnchanged = "unchanged"
+	RoleAssignmentNotFound  = "not_found"
+	RoleAssignmentForbidden = "forbidden"
+)
+
+// RoleAssignmentResult reports what happened to one user of a bulk role assignment
+type RoleAssignmentResult struct {
+	UserID uuid.UUID `json:"user_id"`
+	Status string    `json:"status"`
+}
+
+// UserPage is one page of a user listing together with the total match count
+type UserPage struct {
+	Users  []*domain.User `json:"data"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// AuditPage is one page of a user's audit entries together with their total count
+type AuditPage struct {
+	Entries []*domain.AuditLog `json:"data"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+// maxFunnelRange bounds the period of a signup funnel query
+const maxFunnelRange = 366 * 24 * time.Hour
+
+// SignupFunnel counts the registrations of the period and how many of them
+// went on to verify their email and log in
+func (s *AdminService) SignupFunnel(ctx context.Context, query domain.SignupFunnelQuery) ([]*domain.SignupFunnelRow, error) {
+	if !query.From.Before(query.To) || query.To.Sub(query.From) > maxFunnelRange {
+		return nil, ErrInvalidRange
+	}
+
+	funnel, err := s.userRepo.SignupFunnel(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if funnel == nil {
+		funnel = []*domain.SignupFunnelRow{}
+	}
+	return funnel, nil
+}
+
+// ListUsers returns a page of the users matching the filter, within the
+// campus scope of the caller
+func (s *AdminService) ListUsers(ctx context.Context, scope domain.CampusScope, filter domain.UserFilter) (*UserPage, error) {
+	if filter.Role != nil && !filter.Role.IsValid() {
+		return nil, ErrUnknownRole
+	}
+	if filter.CampusID != nil {
+		if err := domain.CheckCampusID("campus_id", *filter.CampusID); err != nil {
+			return nil, err
+		}
+	}
+	filter, err := scope.Narrow(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.userRepo.ListFiltered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*domain.User{}
+	}
+
+	return &UserPage{Users: users, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// GetUser returns a user within the campus scope of the caller
+func (s *AdminService) GetUser(ctx context.Context, scope domain.CampusScope, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.Check(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUserAudit returns a page of the audit entries of a user within the
+// campus scope of the caller, newest first
+func (s *AdminService) ListUserAudit(ctx context.Context, scope domain.CampusScope, userID uuid.UUID, limit, offset int) (*AuditPage, error) {
+	if _, err := s.GetUser(ctx, scope, userID); err != nil {
+		return nil, err
+	}
+
+	entries, total, err := s.auditRepo.ListByUser(userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*domain.AuditLog{}
+	}
+
+	return &AuditPage{Entries: entries, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// CountUsersByRole returns the number of users holding each known role
+func (s *AdminService) CountUsersByRole(ctx context.Context) (map[domain.Role]int, error) {
+	counts := make(map[domain.Role]int, len(domain.Roles))
+	for _, role := range domain.Roles {
+		count, err := s.userRepo.CountByRole(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		counts[role] = count
+	}
+	return counts, nil
+}
+
+// BulkAssignRole gives the role to every listed user the caller has authority
+// over. All permitted changes are applied atomically; the result reports the
+// outcome per user. Each change is audited and announced on the event bus.
+// Users whose password is too weak for the new role must change it.
+func (s *AdminService) BulkAssignRole(ctx context.Context, callerID uuid.UUID, req domain.BulkRoleAssignment, ipAddress, userAgent string) ([]RoleAssignmentResult, error) {
+	if !req.Role.IsValid() {
+		return nil, ErrUnknownRole
+	}
+
+	caller, err := s.userRepo.GetByID(ctx, callerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get caller: %w", err)
+	}
+
+	results := make([]RoleAssignmentResult, 0, len(req.UserIDs))
+	seen := make(map[uuid.UUID]bool, len(req.UserIDs))
+	changed := make(map[uuid.UUID]*domain.User)
+	var ids []uuid.UUID
+
+	for _, id := range req.UserIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		target, err := s.userRepo.GetByID(ctx, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			results = append(results, RoleAssignmentResult{UserID: id, Status: RoleAssignmentNotFound})
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		switch {
+		case !caller.CanAssignRole(target, req.Role):
+			results = append(results, RoleAssignmentResult{UserID: id, Status: RoleAssignmentForbidden})
+		case target.Role == req.Role:
+			results = append(results, RoleAssignmentResult{UserID: id, Status: RoleAssignmentUnchanged})
+		default:
+			changed[id] = target
+			ids = append(ids, id)
+			results = append(results, RoleAssignmentResult{UserID: id, Status: RoleAssignmentUpdated})
+		}
+	}
+
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	// the forced password changes commit with the elevation, so no user holds
+	// the new role with a password too weak for it
+	previous := make(map[uuid.UUID]domain.Role, len(ids))
+	mustChange := make(map[uuid.UUID]bool, len(ids))
+	err = s.transactor.WithTx(ctx, func(repos domain.Repositories) error {
+		if err := repos.Users.SetRole(ctx, ids, req.Role); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			target := changed[id]
+			previous[id] = target.Role
+			target.Role = req.Role
+			mustChange[id] = target.RequireStrongerPassword(s.passwordPolicy)
+			if mustChange[id] {
+				if err := repos.Users.Update(ctx, target); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		entry := domain.NewAuditLog(id, domain.AuditRoleChanged, ipAddress, userAgent, domain.AuditMetadata{
+			"actorId":                caller.ID,
+			"previousRole":           previous[id],
+			"newRole":                req.Role,
+			"passwordChangeRequired": mustChange[id],
+		})
+		if err := s.auditRepo.Create(entry); err != nil {
+			log.Printf("Failed to audit role change of %s: %v", id, err)
+		}
+
+		if err := s.publisher.Publish(ctx, userRoleChangedEvent(id, previous[id], req.Role, caller.ID)); err != nil {
+			log.Printf("Failed to publish %s event: %v", events.UserRoleChanged, err)
+		}
+	}
+
+	return results, nil
+}
+
+// ChangeRole gives the user the role. Unlike bulk assignment it may demote
+// admins, the caller included, as long as another admin remains. The user's
+// access tokens are revoked so the new role applies at their next refresh;
+// the change is audited and announced on the event bus.
+func (s *AdminService) ChangeRole(ctx context.Context, callerID, userID uuid.UUID, role domain.Role, ipAddress, userAgent string) (*domain.User, error) {
+	if !role.IsValid() {
+		return nil, ErrUnknownRole
+	}
+
+	var user *domain.User
+	var previous domain.Role
+	var mustChange bool
+	err := s.transactor.WithTx(ctx, func(repos domain.Repositories) error {
+		// locking the admins serializes role changes, so two concurrent
+		// demotions cannot both see another admin left
+		admins, err := repos.Users.LockRole(ctx, domain.RoleAdmin)
+		if err != nil {
+			return err
+		}
+
+		user, err = repos.Users.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		previous = user.Role
+		if previous == role {
+			return nil
+		}
+		if previous == domain.RoleAdmin && admins <= 1 {
+			return ErrLastAdmin
+		}
+
+		user.Role = role
+		mustChange = user.RequireStrongerPassword(s.passwordPolicy)
+		return repos.Users.Update(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if previous == role {
+		return user, nil
+	}
+
+	if err := s.tokens.revokeUser(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens of %s: %v", user.ID, err)
+	}
+
+	entry := domain.NewAuditLog(user.ID, domain.AuditRoleChanged, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":                callerID,
+		"previousRole":           previous,
+		"newRole":                role,
+		"passwordChangeRequired": mustChange,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit role change of %s: %v", user.ID, err)
+	}
+
+	if err := s.publisher.Publish(ctx, userRoleChangedEvent(user.ID, previous, role, callerID)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserRoleChanged, err)
+	}
+
+	return user, nil
+}
+
+// RequirePasswordChange forces the user to change their password on next
+// login. The user's access tokens are revoked so their sessions refresh into
+// restricted ones; with RevokeSessions the sessions end as well.
+func (s *AdminService) RequirePasswordChange(ctx context.Context, callerID, userID uuid.UUID, req domain.PasswordChangeRequirement, ipAddress, userAgent string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	user.RequirePasswordChange()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.tokens.revokeUser(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens of %s: %v", user.ID, err)
+	}
+	if req.RevokeSessions {
+		if err := s.sessionRepo.RevokeAllByUserID(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	entry := domain.NewAuditLog(user.ID, domain.AuditPasswordChangeRequired, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":         callerID,
+		"sessionsRevoked": req.RevokeSessions,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit password change requirement of %s: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// RestoreUser undoes the deletion of a user's account
+func (s *AdminService) RestoreUser(ctx context.Context, callerID, userID uuid.UUID, ipAddress, userAgent string) error {
+	if err := s.userRepo.Restore(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	entry := domain.NewAuditLog(userID, domain.AuditUserRestored, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId": callerID,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit restore of %s: %v", userID, err)
+	}
+
+	if err := s.publisher.Publish(ctx, accountEvent(events.UserRestored, userID)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserRestored, err)
+	}
+
+	return nil
+}
+
+// DeactivateUser disables a user's account: its sessions are revoked, its
+// access tokens stop working and it cannot log in until an administrator
+// activates it again. Admins cannot disable their own account.
+func (s *AdminService) DeactivateUser(ctx context.Context, callerID, userID uuid.UUID, ipAddress, userAgent string) (*domain.User, error) {
+	if callerID == userID {
+		return nil, ErrActionNotPermitted
+	}
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch user.Status().State {
+	case domain.AccountMerged:
+		return nil, ErrAccountMerged
+	case domain.AccountDisabled:
+		return nil, ErrAlreadyDisabled
+	}
+
+	user.Disable(callerID)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.activeUsers.Forget(user.ID)
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.revokeUser(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens of %s: %v", user.ID, err)
+	}
+
+	entry := domain.NewAuditLog(user.ID, domain.AuditUserDeactivated, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId": callerID,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit deactivation of %s: %v", user.ID, err)
+	}
+
+	if err := s.publisher.Publish(ctx, accountEvent(events.UserDeactivated, user.ID)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserDeactivated, err)
+	}
+
+	return user, nil
+}
+
+// ActivateUser activates an account disabled by an administrator or
+// deactivated by its owner, whatever its reactivation window
+func (s *AdminService) ActivateUser(ctx context.Context, callerID, userID uuid.UUID, ipAddress, userAgent string) (*domain.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MergedInto != nil {
+		return nil, ErrAccountMerged
+	}
+	if user.IsActive {
+		return nil, ErrAccountActive
+	}
+
+	user.Reactivate()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.activeUsers.Forget(user.ID)
+
+	entry := domain.NewAuditLog(user.ID, domain.AuditUserReactivated, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId": callerID,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit reactivation of %s: %v", user.ID, err)
+	}
+
+	if err := s.publisher.Publish(ctx, accountEvent(events.UserReactivated, user.ID)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserReactivated, err)
+	}
+
+	return user, nil
+}
+
+// ScheduleRevocation schedules a cutoff revoking every session, or every
+// session of one user, created before the cutoff time. Clients see the
+// pending revocation in their session status until it takes effect.
+func (s *AdminService) ScheduleRevocation(ctx context.Context, callerID uuid.UUID, req domain.RevocationRequest, ipAddress, userAgent string) (*domain.RevocationCutoff, error) {
+	subject, scope := callerID, "global"
+	if req.UserID != nil {
+		if _, err := s.userRepo.GetByID(ctx, *req.UserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrUserNotFound
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		subject, scope = *req.UserID, "user"
+	}
+
+	cutoff := domain.NewRevocationCutoff(req.UserID, req.CutoffAt, callerID)
+	if err := s.cutoffRepo.Create(cutoff); err != nil {
+		return nil, err
+	}
+
+	entry := domain.NewAuditLog(subject, domain.AuditRevocationScheduled, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":  callerID,
+		"scope":    scope,
+		"cutoffAt": cutoff.CutoffAt,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit revocation cutoff %s: %v", cutoff.ID, err)
+	}
+
+	return cutoff, nil
+}
+
+// RevokeRefreshToken revokes the session holding a leaked refresh token. A
+// token value also matches the token its session last rotated away from.
+// The revocation is audited on the session's owner.
+func (s *AdminService) RevokeRefreshToken(ctx context.Context, callerID uuid.UUID, req domain.TokenRevocationRequest, ipAddress, userAgent string) (*domain.Session, error) {
+	session, matchedBy, err := s.findSessionByToken(ctx, req)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	alreadyRevoked := session.IsRevoked
+	if !alreadyRevoked {
+		session.Revoke()
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := domain.NewAuditLog(session.UserID, domain.AuditRefreshTokenRevoked, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":        callerID,
+		"sessionId":      session.ID,
+		"matchedBy":      matchedBy,
+		"alreadyRevoked": alreadyRevoked,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit refresh token revocation of session %s: %v", session.ID, err)
+	}
+
+	return session, nil
+}
+
+// DeviceSessionRevocation reports the sessions revoked on a device
+type DeviceSessionRevocation struct {
+	DeviceID string      `json:"device_id"`
+	Revoked  int         `json:"revoked"`
+	UserIDs  []uuid.UUID `json:"user_ids"`
+}
+
+// ListDeviceSessions returns the active sessions of every user opened on the device
+func (s *AdminService) ListDeviceSessions(ctx context.Context, deviceID string) ([]*domain.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*domain.Session{}
+	}
+	return sessions, nil
+}
+
+// RevokeDeviceSessions revokes every active session opened on a device,
+// such as a compromised lab machine, whoever it belongs to. The revocation
+// is audited on each affected user's account.
+func (s *AdminService) RevokeDeviceSessions(ctx context.Context, callerID uuid.UUID, req domain.DeviceRevocationRequest, ipAddress, userAgent string) (*DeviceSessionRevocation, error) {
+	sessions, err := s.sessionRepo.RevokeByDevice(ctx, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make(map[uuid.UUID][]uuid.UUID)
+	result := &DeviceSessionRevocation{DeviceID: req.DeviceID, Revoked: len(sessions), UserIDs: []uuid.UUID{}}
+	for _, session := range sessions {
+		if _, seen := revoked[session.UserID]; !seen {
+			result.UserIDs = append(result.UserIDs, session.UserID)
+		}
+		revoked[session.UserID] = append(revoked[session.UserID], session.ID)
+	}
+
+	for _, userID := range result.UserIDs {
+		entry := domain.NewAuditLog(userID, domain.AuditDeviceSessionsRevoked, ipAddress, userAgent, domain.AuditMetadata{
+			"actorId":    callerID,
+			"deviceId":   req.DeviceID,
+			"sessionIds": revoked[userID],
+		})
+		if err := s.auditRepo.Create(entry); err != nil {
+			log.Printf("Failed to audit device session revocation of %s: %v", userID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// findSessionByToken looks the session up by token hash, current token or
+// previous token, reporting which one matched
+func (s *AdminService) findSessionByToken(ctx context.Context, req domain.TokenRevocationRequest) (*domain.Session, string, error) {
+	if req.RefreshToken == "" {
+		session, err := s.sessionRepo.GetByRefreshTokenHash(ctx, req.RefreshTokenHash)
+		return session, "hash", err
+	}
+
+	session, err := s.sessionRepo.GetByRefreshToken(ctx, req.RefreshToken)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return session, "token", err
+	}
+	session, err = s.sessionRepo.GetByPreviousRefreshToken(ctx, req.RefreshToken)
+	return session, "previous_token", err
+}
+
+// MergeAccounts folds the source account into the target. Conflicting fields
+// are resolved as the request directs; when some remain unresolved nothing is
+// changed and the report is returned with ErrMergeConflict. The source is
+// retired and its sessions revoked, and other services are told to re-own its
+// data through a user.merged event.
+func (s *AdminService) MergeAccounts(ctx context.Context, callerID uuid.UUID, req domain.MergeRequest, ipAddress, userAgent string) (*domain.MergeReport, error) {
+	if req.SourceID == req.TargetID {
+		return nil, ErrInvalidMerge
+	}
+
+	caller, err := s.getUser(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.getUser(ctx, req.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getUser(ctx, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if source.MergedInto != nil || target.MergedInto != nil {
+		return nil, ErrInvalidMerge
+	}
+
+	report := domain.PlanMerge(source, target, req)
+	if req.DryRun {
+		return report, nil
+	}
+	if !report.Resolved() {
+		return report, ErrMergeConflict
+	}
+	if report.TakesFromSource("role") && !caller.CanAssignRole(target, source.Role) {
+		return nil, ErrActionNotPermitted
+	}
+
+	domain.ApplyMerge(source, target, report)
+	if report.TakesFromSource("role") {
+		target.RequireStrongerPassword(s.passwordPolicy)
+	}
+	err = s.transactor.WithTx(ctx, func(repos domain.Repositories) error {
+		// the source goes first to release its verified phone number
+		if err := repos.Users.Update(ctx, source); err != nil {
+			return err
+		}
+		if err := repos.Users.Update(ctx, target); err != nil {
+			return err
+		}
+		return repos.Audit.Create(domain.NewAuditLog(target.ID, domain.AuditAccountsMerged, ipAddress, userAgent, domain.AuditMetadata{
+			"actorId":   caller.ID,
+			"sourceId":  source.ID,
+			"conflicts": report.Conflicts,
+			"filled":    report.Filled,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, source.ID); err != nil {
+		log.Printf("Failed to revoke sessions of merged account %s: %v", source.ID, err)
+	}
+
+	if err := s.publisher.Publish(ctx, userMergedEvent(source.ID, target.ID, caller.ID)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserMerged, err)
+	}
+
+	return report, nil
+}
+
+// getUser fetches a user, translating a missing row to ErrUserNotFound
+func (s *AdminService) getUser(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// campusExportPageSize is how many users a campus export reads per query
+const campusExportPageSize = 500
+
+// ExportCampusUsers passes every user of the campus to emit, one page at a
+// time in ID order, so large campuses are streamed rather than loaded at
+// once. Every admin holds authority over every campus. The export is
+// audited with the number of users emitted, also when it fails midway.
+func (s *AdminService) ExportCampusUsers(ctx context.Context, callerID uuid.UUID, campusID, format string, emit func([]*domain.CampusUserRecord) error, ipAddress, userAgent string) (int, error) {
+	if err := domain.CheckCampusID("campus_id", campusID); err != nil {
+		return 0, err
+	}
+
+	exported, err := s.streamCampusUsers(ctx, campusID, emit)
+
+	entry := domain.NewAuditLog(callerID, domain.AuditCampusUsersExported, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":   callerID,
+		"campusId":  campusID,
+		"format":    format,
+		"users":     exported,
+		"completed": err == nil,
+	})
+	if auditErr := s.auditRepo.Create(entry); auditErr != nil {
+		log.Printf("Failed to audit export of campus %s: %v", campusID, auditErr)
+	}
+
+	return exported, err
+}
+
+func (s *AdminService) streamCampusUsers(ctx context.Context, campusID string, emit func([]*domain.CampusUserRecord) error) (int, error) {
+	exported := 0
+	cursor := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return exported, err
+		}
+
+		users, err := s.userRepo.ListByCampus(ctx, campusID, cursor, campusExportPageSize)
+		if err != nil {
+			return exported, err
+		}
+		if len(users) == 0 {
+			return exported, nil
+		}
+
+		records := make([]*domain.CampusUserRecord, len(users))
+		for i, user := range users {
+			records[i] = user.CampusRecord()
+		}
+		if err := emit(records); err != nil {
+			return exported, err
+		}
+		exported += len(records)
+		cursor = users[len(users)-1].ID
+	}
+}
+
+// EventDeliveryTest is the outcome of publishing a test event
+type EventDeliveryTest struct {
+	EventID       string `json:"event_id"`
+	CorrelationID string `json:"correlation_id"`
+	// Confirmed is set once the broker acknowledged the event
+	Confirmed bool   `json:"confirmed"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TestEventDelivery publishes a system.test event through the configured
+// publisher, which only returns once the broker confirmed it, and reports how
+// long that took. Failures return the report with ErrEventDeliveryFailed.
+func (s *AdminService) TestEventDelivery(ctx context.Context, callerID uuid.UUID, ipAddress, userAgent string) (*EventDeliveryTest, error) {
+	event := systemTestEvent(callerID)
+
+	start := time.Now()
+	publishErr := s.publisher.Publish(ctx, event)
+	result := &EventDeliveryTest{
+		EventID:       event.EventID,
+		CorrelationID: event.CorrelationID,
+		Confirmed:     publishErr == nil,
+		LatencyMs:     time.Since(start).Milliseconds(),
+	}
+	if publishErr != nil {
+		result.Error = publishErr.Error()
+	}
+
+	entry := domain.NewAuditLog(callerID, domain.AuditEventDeliveryTested, ipAddress, userAgent, domain.AuditMetadata{
+		"actorId":       callerID,
+		"correlationId": event.CorrelationID,
+		"confirmed":     result.Confirmed,
+		"latencyMs":     result.LatencyMs,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit event delivery test %s: %v", event.EventID, err)
+	}
+
+	if publishErr != nil {
+		log.Printf("Test event %s was not delivered: %v", event.EventID, publishErr)
+		return result, ErrEventDeliveryFailed
+	}
+	return result, nil
+}
+
+// DecodeToken verifies and decodes an access token for debugging, including
+// the state of its session. It has no side effects: nothing is audited or
+// touched, and the token itself is never logged.
+func (s *AdminService) DecodeToken(ctx context.Context, token string) (*TokenDecoding, error) {
+	decoding, err := s.tokens.Decode(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, _ := decoding.Claims["sid"].(string)
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return decoding, nil
+	}
+
+	decoding.Session = &TokenSession{ID: sessionID.String(), Status: "not_found"}
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decoding, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	decoding.Session.UserID = session.UserID.String()
+	decoding.Session.ExpiresAt = &session.ExpiresAt
+	decoding.Session.LastUsedAt = &session.LastUsedAt
+	switch {
+	case session.IsRevoked:
+		decoding.Session.Status = "revoked"
+	case session.IsExpired():
+		decoding.Session.Status = "expired"
+	default:
+		decoding.Session.Status = "active"
+	}
+	return decoding, nil
+}
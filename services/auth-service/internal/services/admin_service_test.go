@@ -0,0 +1,949 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// usersWithRoles creates users holding the roles, with distinct creation
+// times. Their passwords are not usable.
+func usersWithRoles(campusID string, roles ...domain.Role) []*domain.User {
+	var users []*domain.User
+	created := time.Now().Add(-time.Hour)
+	for i, role := range roles {
+		campus := campusID
+		users = append(users, &domain.User{
+			ID:        uuid.New(),
+			Email:     fmt.Sprintf("%s-%d@%s.example.edu", role, i, campusID),
+			Role:      role,
+			CampusID:  &campus,
+			IsActive:  true,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return users
+}
+
+func TestListUsersByRole(t *testing.T) {
+	roles := []domain.Role{domain.RoleStudent, domain.RoleStudent, domain.RoleStudent, domain.RoleModerator, domain.RoleModerator, domain.RoleAdmin}
+	service := &AdminService{userRepo: newMemUserRepo(usersWithRoles("main", roles...)...)}
+	ctx := context.Background()
+	admin := domain.CampusScope{Role: domain.RoleAdmin}
+
+	want := map[domain.Role]int{domain.RoleStudent: 3, domain.RoleModerator: 2, domain.RoleAdmin: 1}
+	for _, role := range domain.Roles {
+		role := role
+		page, err := service.ListUsers(ctx, admin, domain.UserFilter{Role: &role, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListUsers(%s): %v", role, err)
+		}
+		if page.Total != want[role] || len(page.Users) != want[role] {
+			t.Errorf("%s: %d of %d users listed, want %d", role, len(page.Users), page.Total, want[role])
+		}
+		for _, user := range page.Users {
+			if user.Role != role {
+				t.Errorf("listing %s users returned a %s", role, user.Role)
+			}
+		}
+	}
+
+	counts, err := service.CountUsersByRole(ctx)
+	if err != nil {
+		t.Fatalf("CountUsersByRole: %v", err)
+	}
+	for _, role := range domain.Roles {
+		if counts[role] != want[role] {
+			t.Errorf("%s count = %d, want %d", role, counts[role], want[role])
+		}
+	}
+}
+
+func TestListUsersPaginates(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleStudent, domain.RoleStudent, domain.RoleStudent)
+	service := &AdminService{userRepo: newMemUserRepo(users...)}
+	student := domain.RoleStudent
+
+	page, err := service.ListUsers(context.Background(), domain.CampusScope{Role: domain.RoleAdmin}, domain.UserFilter{Role: &student, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if page.Total != 3 || page.Limit != 2 || page.Offset != 1 {
+		t.Errorf("page = total %d, limit %d, offset %d", page.Total, page.Limit, page.Offset)
+	}
+	// newest first: skipping the newest leaves the middle and the oldest
+	if len(page.Users) != 2 || page.Users[0].ID != users[1].ID || page.Users[1].ID != users[0].ID {
+		t.Errorf("page holds the wrong users")
+	}
+}
+
+func TestListUsersRejectsUnknownRole(t *testing.T) {
+	service := &AdminService{userRepo: newMemUserRepo()}
+	bogus := domain.Role("superuser")
+
+	_, err := service.ListUsers(context.Background(), domain.CampusScope{Role: domain.RoleAdmin}, domain.UserFilter{Role: &bogus, Limit: 10})
+	if !errors.Is(err, ErrUnknownRole) {
+		t.Fatalf("ListUsers = %v, want ErrUnknownRole", err)
+	}
+}
+
+// Malformed campus IDs are refused before any user is read
+func TestAdminRejectsMalformedCampusID(t *testing.T) {
+	audit := &memAuditRepo{}
+	service := &AdminService{userRepo: newMemUserRepo(), auditRepo: audit}
+	ctx := context.Background()
+	malformed := "Main_Campus"
+	var validation domain.ValidationError
+
+	_, err := service.ListUsers(ctx, domain.CampusScope{Role: domain.RoleAdmin}, domain.UserFilter{CampusID: &malformed, Limit: 10})
+	if !errors.As(err, &validation) || validation.Field != "campus_id" {
+		t.Errorf("ListUsers = %v, want a ValidationError on campus_id", err)
+	}
+
+	emit := func([]*domain.CampusUserRecord) error {
+		t.Error("users of a malformed campus were exported")
+		return nil
+	}
+	if _, err := service.ExportCampusUsers(ctx, uuid.New(), malformed, "csv", emit, "", ""); !errors.As(err, &validation) {
+		t.Errorf("ExportCampusUsers = %v, want a ValidationError", err)
+	}
+	if len(audit.entries) != 0 {
+		t.Error("a refused export was audited")
+	}
+}
+
+func TestSignupFunnelChecksRange(t *testing.T) {
+	service := &AdminService{userRepo: newMemUserRepo()}
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, to := range map[string]time.Time{
+		"empty":       from,
+		"reversed":    from.AddDate(0, 0, -1),
+		"over a year": from.AddDate(1, 0, 2),
+	} {
+		if _, err := service.SignupFunnel(context.Background(), domain.SignupFunnelQuery{From: from, To: to}); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%s range: SignupFunnel = %v, want ErrInvalidRange", name, err)
+		}
+	}
+
+	funnel, err := service.SignupFunnel(context.Background(), domain.SignupFunnelQuery{From: from, To: from.AddDate(1, 0, 0)})
+	if err != nil {
+		t.Fatalf("SignupFunnel over a year: %v", err)
+	}
+	if funnel == nil {
+		t.Error("funnel without registrations is nil, want an empty list")
+	}
+}
+
+// Moderators only ever see the users of their own campus
+func TestListUsersWithinCampusScope(t *testing.T) {
+	users := append(usersWithRoles("main", domain.RoleStudent, domain.RoleStudent), usersWithRoles("north", domain.RoleStudent)...)
+	service := &AdminService{userRepo: newMemUserRepo(users...)}
+	ctx := context.Background()
+	mainCampus, north := "main", "north"
+	moderator := domain.CampusScope{Role: domain.RoleModerator, CampusID: &mainCampus}
+
+	page, err := service.ListUsers(ctx, moderator, domain.UserFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if page.Total != 2 {
+		t.Errorf("moderator listed %d users, want the 2 of their campus", page.Total)
+	}
+
+	if _, err := service.ListUsers(ctx, moderator, domain.UserFilter{CampusID: &north, Limit: 10}); !errors.Is(err, ErrOutsideCampus) {
+		t.Errorf("listing another campus = %v, want ErrOutsideCampus", err)
+	}
+	if _, err := service.ListUsers(ctx, domain.CampusScope{Role: domain.RoleModerator}, domain.UserFilter{Limit: 10}); !errors.Is(err, ErrNoCampus) {
+		t.Errorf("moderator without a campus = %v, want ErrNoCampus", err)
+	}
+}
+
+type bulkFixture struct {
+	service   *AdminService
+	users     *memUserRepo
+	audit     *memAuditRepo
+	publisher *recordingPublisher
+}
+
+func newBulkFixture(users ...*domain.User) *bulkFixture {
+	f := &bulkFixture{users: newMemUserRepo(users...), audit: &memAuditRepo{}, publisher: &recordingPublisher{}}
+	tx := &memTransactor{users: f.users, tokens: newMemVerificationTokenRepo(), audit: &memAuditRepo{}, outbox: &memOutboxRepo{}}
+	f.service = &AdminService{userRepo: f.users, transactor: tx, auditRepo: f.audit, publisher: f.publisher}
+	return f
+}
+
+func TestBulkAssignRolePartialSuccess(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent, domain.RoleStudent, domain.RoleModerator, domain.RoleAdmin)
+	caller, first, second, moderator, otherAdmin := users[0], users[1], users[2], users[3], users[4]
+	missing := uuid.New()
+	f := newBulkFixture(users...)
+
+	results, err := f.service.BulkAssignRole(context.Background(), caller.ID, domain.BulkRoleAssignment{
+		UserIDs: []uuid.UUID{first.ID, missing, moderator.ID, otherAdmin.ID, caller.ID, second.ID, first.ID},
+		Role:    domain.RoleModerator,
+	}, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("BulkAssignRole: %v", err)
+	}
+
+	want := []RoleAssignmentResult{
+		{first.ID, RoleAssignmentUpdated},
+		{missing, RoleAssignmentNotFound},
+		{moderator.ID, RoleAssignmentUnchanged},
+		{otherAdmin.ID, RoleAssignmentForbidden},
+		{caller.ID, RoleAssignmentForbidden},
+		{second.ID, RoleAssignmentUpdated},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want one per distinct user: %+v", len(results), results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	for _, user := range []*domain.User{first, second} {
+		if got := f.users.get(t, user.ID).Role; got != domain.RoleModerator {
+			t.Errorf("%s has role %s, want moderator", user.Email, got)
+		}
+	}
+	if got := f.users.get(t, otherAdmin.ID).Role; got != domain.RoleAdmin {
+		t.Errorf("forbidden change applied: admin is now %s", got)
+	}
+
+	if got := len(f.publisher.ofType(events.UserRoleChanged)); got != 2 {
+		t.Errorf("published %d user.role.changed events, want one per updated user", got)
+	}
+	entries := f.audit.ofAction(domain.AuditRoleChanged)
+	if len(entries) != 2 {
+		t.Fatalf("audited %d role changes, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Metadata["actorId"] != caller.ID || entry.Metadata["previousRole"] != domain.RoleStudent {
+			t.Errorf("audit entry = %+v", entry.Metadata)
+		}
+	}
+}
+
+func TestBulkAssignRoleAuthority(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleModerator, domain.RoleStudent, domain.RoleModerator)
+	caller, student, peer := users[0], users[1], users[2]
+
+	tests := []struct {
+		name   string
+		target *domain.User
+		role   domain.Role
+		want   string
+	}{
+		{"promote a student to their own rank", student, domain.RoleModerator, RoleAssignmentForbidden},
+		{"promote a student to admin", student, domain.RoleAdmin, RoleAssignmentForbidden},
+		{"demote a peer", peer, domain.RoleStudent, RoleAssignmentForbidden},
+		{"keep a student a student", student, domain.RoleStudent, RoleAssignmentUnchanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newBulkFixture(users...)
+			results, err := f.service.BulkAssignRole(context.Background(), caller.ID, domain.BulkRoleAssignment{
+				UserIDs: []uuid.UUID{tt.target.ID},
+				Role:    tt.role,
+			}, "", "")
+			if err != nil {
+				t.Fatalf("BulkAssignRole: %v", err)
+			}
+			if results[0].Status != tt.want {
+				t.Errorf("status = %s, want %s", results[0].Status, tt.want)
+			}
+			if len(f.publisher.events) != 0 || len(f.audit.entries) != 0 {
+				t.Error("a role change that was not made was announced or audited")
+			}
+		})
+	}
+}
+
+// The permitted changes are one statement: when it fails nothing changes
+// and nothing is announced
+func TestBulkAssignRoleIsAtomic(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent, domain.RoleStudent)
+	f := newBulkFixture(users...)
+	f.users.failWrites = errors.New("connection lost")
+
+	_, err := f.service.BulkAssignRole(context.Background(), users[0].ID, domain.BulkRoleAssignment{
+		UserIDs: []uuid.UUID{users[1].ID, users[2].ID},
+		Role:    domain.RoleModerator,
+	}, "", "")
+	if err == nil {
+		t.Fatal("BulkAssignRole succeeded although the update failed")
+	}
+	for _, user := range users[1:] {
+		if got := f.users.get(t, user.ID).Role; got != domain.RoleStudent {
+			t.Errorf("%s has role %s after a failed assignment", user.Email, got)
+		}
+	}
+	if len(f.publisher.events) != 0 || len(f.audit.entries) != 0 {
+		t.Error("a failed assignment was announced or audited")
+	}
+}
+
+func TestBulkAssignRoleRejectsUnknownRole(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent)
+	_, err := newBulkFixture(users...).service.BulkAssignRole(context.Background(), users[0].ID, domain.BulkRoleAssignment{
+		UserIDs: []uuid.UUID{users[1].ID},
+		Role:    "superuser",
+	}, "", "")
+	if !errors.Is(err, ErrUnknownRole) {
+		t.Fatalf("BulkAssignRole = %v, want ErrUnknownRole", err)
+	}
+}
+
+// elevationPolicy asks moderators and admins for 14 characters mixing 3 classes
+func elevationPolicy() domain.PasswordPolicy {
+	privileged := domain.PasswordRule{MinLength: 14, MinClasses: 3}
+	return domain.PasswordPolicy{
+		Default: domain.PasswordRule{MinLength: 8, MinClasses: 1},
+		Roles:   map[domain.Role]domain.PasswordRule{domain.RoleModerator: privileged, domain.RoleAdmin: privileged},
+	}
+}
+
+func TestBulkAssignRoleRequiresStrongerPassword(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent, domain.RoleStudent)
+	caller, weak, strong := users[0], users[1], users[2]
+	weak.PasswordStrength = domain.MeasurePassword("plainpassword")
+	strong.PasswordStrength = domain.MeasurePassword("Correct-horse-42")
+	f := newBulkFixture(users...)
+	f.service.passwordPolicy = elevationPolicy()
+
+	_, err := f.service.BulkAssignRole(context.Background(), caller.ID, domain.BulkRoleAssignment{
+		UserIDs: []uuid.UUID{weak.ID, strong.ID},
+		Role:    domain.RoleModerator,
+	}, "", "")
+	if err != nil {
+		t.Fatalf("BulkAssignRole: %v", err)
+	}
+
+	if !f.users.get(t, weak.ID).MustChangePassword {
+		t.Error("user with a password too weak for moderators need not change it")
+	}
+	if f.users.get(t, strong.ID).MustChangePassword {
+		t.Error("user with a strong enough password must change it")
+	}
+	for _, entry := range f.audit.ofAction(domain.AuditRoleChanged) {
+		if want := entry.UserID == weak.ID; entry.Metadata["passwordChangeRequired"] != want {
+			t.Errorf("audit of %s records passwordChangeRequired %v, want %v", entry.UserID, entry.Metadata["passwordChangeRequired"], want)
+		}
+	}
+}
+
+// A failed forced password change takes the elevation back with it
+func TestBulkAssignRoleRollsBackWithPasswordChange(t *testing.T) {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent, domain.RoleStudent)
+	caller, weak, strong := users[0], users[1], users[2]
+	weak.PasswordStrength = domain.MeasurePassword("plainpassword")
+	strong.PasswordStrength = domain.MeasurePassword("Correct-horse-42")
+	f := newBulkFixture(users...)
+	f.service.passwordPolicy = elevationPolicy()
+	f.users.failUpdates = errors.New("connection lost")
+
+	_, err := f.service.BulkAssignRole(context.Background(), caller.ID, domain.BulkRoleAssignment{
+		UserIDs: []uuid.UUID{strong.ID, weak.ID},
+		Role:    domain.RoleModerator,
+	}, "", "")
+	if err == nil {
+		t.Fatal("BulkAssignRole succeeded although the password change failed")
+	}
+	for _, user := range []*domain.User{weak, strong} {
+		if got := f.users.get(t, user.ID).Role; got != domain.RoleStudent {
+			t.Errorf("%s has role %s after a failed assignment", user.Email, got)
+		}
+	}
+	if len(f.publisher.events) != 0 || len(f.audit.entries) != 0 {
+		t.Error("a failed assignment was announced or audited")
+	}
+}
+
+func TestChangeRoleRequiresStrongerPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		role     domain.Role
+		want     bool
+	}{
+		{"weak password elevated to admin", "plainpassword", domain.RoleAdmin, true},
+		{"strong password elevated to admin", "Correct-horse-42", domain.RoleAdmin, false},
+		{"weak password demoted to student", "plainpassword", domain.RoleStudent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := usersWithRoles("main", domain.RoleAdmin, domain.RoleModerator)
+			caller, user := users[0], users[1]
+			user.PasswordStrength = domain.MeasurePassword(tt.password)
+			tx := newMemTransactor(users...)
+			audit := &memAuditRepo{}
+			s := &AdminService{
+				userRepo:       tx.users,
+				auditRepo:      audit,
+				transactor:     tx,
+				publisher:      &recordingPublisher{},
+				passwordPolicy: elevationPolicy(),
+				tokens:         NewAccessTokens(NewHMACKeys("test-secret"), nil, nil, NewMemoryBlacklist(), time.Minute),
+			}
+
+			changed, err := s.ChangeRole(context.Background(), caller.ID, user.ID, tt.role, "", "")
+			if err != nil {
+				t.Fatalf("ChangeRole: %v", err)
+			}
+			if changed.MustChangePassword != tt.want || tx.users.get(t, user.ID).MustChangePassword != tt.want {
+				t.Errorf("MustChangePassword = %v, want %v", changed.MustChangePassword, tt.want)
+			}
+			entries := audit.ofAction(domain.AuditRoleChanged)
+			if len(entries) != 1 || entries[0].Metadata["passwordChangeRequired"] != tt.want {
+				t.Errorf("audit entries %v, want one recording passwordChangeRequired %v", entries, tt.want)
+			}
+		})
+	}
+}
+
+// revokeTokenFixture is an admin service over the sessions of an auth
+// fixture in which a student has logged in
+type revokeTokenFixture struct {
+	*authFixture
+	admin   *AdminService
+	audit   *memAuditRepo
+	user    *domain.User
+	adminID uuid.UUID
+	tokens  *domain.TokenPair
+}
+
+func newRevokeTokenFixture(t *testing.T) *revokeTokenFixture {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := &revokeTokenFixture{authFixture: newAuthFixture(t, AuthConfig{}, user), audit: &memAuditRepo{}, user: user, adminID: uuid.New()}
+	f.admin = &AdminService{userRepo: f.users, sessionRepo: f.sessions, auditRepo: f.audit}
+	f.tokens = f.login(t, user.Email, "").TokenPair
+	return f
+}
+
+func (f *revokeTokenFixture) revoke(req domain.TokenRevocationRequest) (*domain.Session, error) {
+	return f.admin.RevokeRefreshToken(context.Background(), f.adminID, req, "192.0.2.9", "admin-agent")
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       func(refreshToken string) domain.TokenRevocationRequest
+		matchedBy string
+	}{
+		{"by token", func(token string) domain.TokenRevocationRequest {
+			return domain.TokenRevocationRequest{RefreshToken: token}
+		}, "token"},
+		{"by hash", func(token string) domain.TokenRevocationRequest {
+			return domain.TokenRevocationRequest{RefreshTokenHash: domain.HashToken(token)}
+		}, "hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRevokeTokenFixture(t)
+			ctx := context.Background()
+
+			session, err := f.revoke(tt.req(f.tokens.RefreshToken))
+			if err != nil {
+				t.Fatalf("RevokeRefreshToken: %v", err)
+			}
+			if session.UserID != f.user.ID || !session.IsRevoked {
+				t.Errorf("revoked session of %s, revoked %v; want the user's, revoked", session.UserID, session.IsRevoked)
+			}
+			if _, err := f.service.RefreshToken(ctx, f.tokens.RefreshToken); err == nil {
+				t.Error("revoked refresh token still refreshes")
+			}
+
+			entries := f.audit.ofAction(domain.AuditRefreshTokenRevoked)
+			if len(entries) != 1 {
+				t.Fatalf("%d audit entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.UserID != f.user.ID || entry.Metadata["actorId"] != f.adminID || entry.Metadata["matchedBy"] != tt.matchedBy || entry.Metadata["alreadyRevoked"] != false {
+				t.Errorf("audit entry on %s: %v", entry.UserID, entry.Metadata)
+			}
+		})
+	}
+}
+
+// A leaked token the session has since rotated away from still finds it
+func TestRevokeRotatedRefreshToken(t *testing.T) {
+	f := newRevokeTokenFixture(t)
+	if _, err := f.service.RefreshToken(context.Background(), f.tokens.RefreshToken); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	session, err := f.revoke(domain.TokenRevocationRequest{RefreshToken: f.tokens.RefreshToken})
+	if err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+	if !session.IsRevoked {
+		t.Error("session not revoked")
+	}
+	if entries := f.audit.ofAction(domain.AuditRefreshTokenRevoked); len(entries) != 1 || entries[0].Metadata["matchedBy"] != "previous_token" {
+		t.Errorf("audit entries %v, want one matched by the previous token", entries)
+	}
+}
+
+func TestRevokeRefreshTokenTwice(t *testing.T) {
+	f := newRevokeTokenFixture(t)
+	req := domain.TokenRevocationRequest{RefreshToken: f.tokens.RefreshToken}
+	if _, err := f.revoke(req); err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+
+	if _, err := f.revoke(req); err != nil {
+		t.Fatalf("second RevokeRefreshToken: %v", err)
+	}
+	entries := f.audit.ofAction(domain.AuditRefreshTokenRevoked)
+	if len(entries) != 2 || entries[1].Metadata["alreadyRevoked"] != true {
+		t.Errorf("audit entries %v, want the second recording the session already revoked", entries)
+	}
+}
+
+func TestRevokeUnknownRefreshToken(t *testing.T) {
+	f := newRevokeTokenFixture(t)
+
+	for _, req := range []domain.TokenRevocationRequest{
+		{RefreshToken: "not-a-refresh-token"},
+		{RefreshTokenHash: domain.HashToken("not-a-refresh-token")},
+	} {
+		if _, err := f.revoke(req); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("RevokeRefreshToken(%+v) = %v, want ErrSessionNotFound", req, err)
+		}
+	}
+	if len(f.audit.entries) != 0 {
+		t.Error("revocation of an unknown token audited")
+	}
+	sessions, _ := f.sessions.GetByUserID(context.Background(), f.user.ID)
+	if len(sessions) != 1 || sessions[0].IsRevoked {
+		t.Error("an unknown token revoked the user's session")
+	}
+}
+
+func TestRequirePasswordChangeEnforcedAtLogin(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	adminID := uuid.New()
+	f := newAuthFixture(t, AuthConfig{}, user)
+	audit := &memAuditRepo{}
+	admin := &AdminService{userRepo: f.users, sessionRepo: f.sessions, auditRepo: audit, tokens: f.service.tokens}
+	users := &UserService{userRepo: f.users, publisher: f.publisher}
+	ctx := context.Background()
+
+	before := f.login(t, user.Email, "")
+	if before.MustChangePassword {
+		t.Fatal("login requires a password change before an admin asked for one")
+	}
+
+	if err := admin.RequirePasswordChange(ctx, adminID, user.ID, domain.PasswordChangeRequirement{}, "192.0.2.9", "admin-agent"); err != nil {
+		t.Fatalf("RequirePasswordChange: %v", err)
+	}
+	if !f.users.get(t, user.ID).MustChangePassword {
+		t.Error("flag not stored")
+	}
+	entries := audit.ofAction(domain.AuditPasswordChangeRequired)
+	if len(entries) != 1 || entries[0].Metadata["actorId"] != adminID || entries[0].Metadata["sessionsRevoked"] != false {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	// tokens issued before carry no restriction, so they stop working; the
+	// session itself continues
+	if _, err := f.service.tokens.Validate(ctx, before.AccessToken); err == nil {
+		t.Error("unrestricted access token still valid")
+	}
+	sessions, _ := f.sessions.GetByUserID(ctx, user.ID)
+	if len(sessions) != 1 || !sessions[0].IsActive() {
+		t.Error("session revoked although not asked to")
+	}
+
+	restricted := f.login(t, user.Email, "")
+	claims, err := ParseAccessToken(restricted.AccessToken, f.service.keys)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if !restricted.MustChangePassword || !claims.MustChangePassword {
+		t.Errorf("login after the requirement: result %v, token %v, want both restricted", restricted.MustChangePassword, claims.MustChangePassword)
+	}
+
+	change := domain.PasswordChange{CurrentPassword: "password-123", NewPassword: "another-password-456"}
+	if err := users.ChangePassword(ctx, user.ID, change, "192.0.2.1", "test-agent"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	result, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: change.NewPassword}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+	if result.MustChangePassword {
+		t.Error("login still restricted after the password was changed")
+	}
+}
+
+func TestRequirePasswordChangeRevokingSessions(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	audit := &memAuditRepo{}
+	admin := &AdminService{userRepo: f.users, sessionRepo: f.sessions, auditRepo: audit, tokens: f.service.tokens}
+	ctx := context.Background()
+	f.login(t, user.Email, "")
+	f.login(t, user.Email, "")
+
+	if err := admin.RequirePasswordChange(ctx, uuid.New(), user.ID, domain.PasswordChangeRequirement{RevokeSessions: true}, "", ""); err != nil {
+		t.Fatalf("RequirePasswordChange: %v", err)
+	}
+	sessions, _ := f.sessions.GetByUserID(ctx, user.ID)
+	for _, session := range sessions {
+		if session.IsActive() {
+			t.Errorf("session %s still valid", session.ID)
+		}
+	}
+	if entries := audit.ofAction(domain.AuditPasswordChangeRequired); len(entries) != 1 || entries[0].Metadata["sessionsRevoked"] != true {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	if err := admin.RequirePasswordChange(ctx, uuid.New(), uuid.New(), domain.PasswordChangeRequirement{}, "", ""); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user = %v, want ErrUserNotFound", err)
+	}
+}
+
+// mergeFixture is an AdminService merging accounts through a memTransactor
+type mergeFixture struct {
+	service   *AdminService
+	tx        *memTransactor
+	sessions  *memSessionRepo
+	publisher *recordingPublisher
+	admin     *domain.User
+	source    *domain.User
+	target    *domain.User
+}
+
+func newMergeFixture() *mergeFixture {
+	users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent, domain.RoleStudent)
+	users[1].FirstName, users[1].LastName = "Augusta", "King"
+	users[2].FirstName, users[2].LastName = "Ada", "Lovelace"
+	f := &mergeFixture{
+		tx:        newMemTransactor(users...),
+		sessions:  newMemSessionRepo(),
+		publisher: &recordingPublisher{},
+		admin:     users[0],
+		source:    users[1],
+		target:    users[2],
+	}
+	f.service = &AdminService{userRepo: f.tx.users, sessionRepo: f.sessions, transactor: f.tx, publisher: f.publisher}
+	return f
+}
+
+func (f *mergeFixture) merge(req domain.MergeRequest) (*domain.MergeReport, error) {
+	req.SourceID, req.TargetID = f.source.ID, f.target.ID
+	return f.service.MergeAccounts(context.Background(), f.admin.ID, req, "192.0.2.1", "test-agent")
+}
+
+func TestMergeAccountsRetiresSource(t *testing.T) {
+	f := newMergeFixture()
+	ctx := context.Background()
+	f.sessions.Create(ctx, &domain.Session{ID: uuid.New(), UserID: f.source.ID, ExpiresAt: farFuture()})
+
+	report, err := f.merge(domain.MergeRequest{Choices: map[string]string{"first_name": domain.MergeSideSource}})
+	if err != nil {
+		t.Fatalf("MergeAccounts: %v", err)
+	}
+	if !report.Merged {
+		t.Error("report not marked merged")
+	}
+
+	target := f.tx.users.get(t, f.target.ID)
+	if target.FirstName != "Augusta" || target.LastName != "Lovelace" {
+		t.Errorf("target named %s %s, want Augusta Lovelace", target.FirstName, target.LastName)
+	}
+	source := f.tx.users.get(t, f.source.ID)
+	if source.MergedInto == nil || *source.MergedInto != f.target.ID || source.IsActive {
+		t.Error("source not retired into the target")
+	}
+	if sessions, _ := f.sessions.GetByUserID(ctx, f.source.ID); len(sessions) != 1 || !sessions[0].IsRevoked {
+		t.Error("sessions of the source not revoked")
+	}
+	if entries := f.tx.audit.ofAction(domain.AuditAccountsMerged); len(entries) != 1 {
+		t.Errorf("%d audit entries, want 1", len(entries))
+	}
+	if merged := f.publisher.ofType(events.UserMerged); len(merged) != 1 {
+		t.Errorf("%d %s events, want 1", len(merged), events.UserMerged)
+	}
+}
+
+func TestMergeAccountsWithUnresolvedConflicts(t *testing.T) {
+	f := newMergeFixture()
+
+	report, err := f.merge(domain.MergeRequest{DefaultPolicy: domain.MergeManual})
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("MergeAccounts = %v, want ErrMergeConflict", err)
+	}
+	if report == nil || fmt.Sprint(report.Unresolved) != "[first_name last_name]" {
+		t.Fatalf("report = %+v, want first_name and last_name unresolved", report)
+	}
+	if f.tx.users.get(t, f.source.ID).MergedInto != nil || f.tx.users.get(t, f.target.ID).FirstName != "Ada" {
+		t.Error("accounts changed by a merge with unresolved conflicts")
+	}
+	if len(f.tx.audit.entries) != 0 || len(f.publisher.events) != 0 {
+		t.Error("merge with unresolved conflicts was audited or announced")
+	}
+}
+
+func TestMergeAccountsDryRun(t *testing.T) {
+	f := newMergeFixture()
+
+	report, err := f.merge(domain.MergeRequest{DefaultPolicy: domain.MergePreferSource, DryRun: true})
+	if err != nil {
+		t.Fatalf("MergeAccounts: %v", err)
+	}
+	if report.Merged || len(report.Conflicts) != 2 {
+		t.Errorf("dry run report = %+v, want 2 conflicts and no merge", report)
+	}
+	if f.tx.users.get(t, f.source.ID).MergedInto != nil || f.tx.users.get(t, f.target.ID).FirstName != "Ada" {
+		t.Error("dry run changed the accounts")
+	}
+}
+
+func TestMergeAccountsRejectsInvalidPairs(t *testing.T) {
+	f := newMergeFixture()
+	ctx := context.Background()
+
+	same := domain.MergeRequest{SourceID: f.source.ID, TargetID: f.source.ID}
+	if _, err := f.service.MergeAccounts(ctx, f.admin.ID, same, "", ""); !errors.Is(err, ErrInvalidMerge) {
+		t.Errorf("merge into itself = %v, want ErrInvalidMerge", err)
+	}
+
+	if _, err := f.merge(domain.MergeRequest{}); err != nil {
+		t.Fatalf("MergeAccounts: %v", err)
+	}
+	if _, err := f.merge(domain.MergeRequest{}); !errors.Is(err, ErrInvalidMerge) {
+		t.Errorf("merge of a merged account = %v, want ErrInvalidMerge", err)
+	}
+}
+
+func TestEventDeliveryReportsConfirmedPublish(t *testing.T) {
+	caller := usersWithRoles("main", domain.RoleAdmin)[0]
+	f := newBulkFixture(caller)
+
+	result, err := f.service.TestEventDelivery(context.Background(), caller.ID, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("TestEventDelivery: %v", err)
+	}
+	if !result.Confirmed || result.Error != "" || result.LatencyMs < 0 {
+		t.Errorf("result = %+v, want a confirmed publish", result)
+	}
+
+	published := f.publisher.ofType(events.SystemTest)
+	if len(published) != 1 {
+		t.Fatalf("published %d system.test events, want 1", len(published))
+	}
+	if published[0].EventID != result.EventID || published[0].CorrelationID != result.CorrelationID {
+		t.Errorf("result %+v does not identify the published event %+v", result, published[0])
+	}
+
+	entries := f.audit.ofAction(domain.AuditEventDeliveryTested)
+	if len(entries) != 1 {
+		t.Fatalf("audited %d delivery tests, want 1", len(entries))
+	}
+	if entries[0].UserID != caller.ID || entries[0].Metadata["correlationId"] != result.CorrelationID || entries[0].Metadata["confirmed"] != true {
+		t.Errorf("audit entry = %+v", entries[0])
+	}
+}
+
+func TestEventDeliveryReportsFailedPublish(t *testing.T) {
+	caller := usersWithRoles("main", domain.RoleAdmin)[0]
+	f := newBulkFixture(caller)
+	f.publisher.err = errors.New("broker nacked the message")
+
+	result, err := f.service.TestEventDelivery(context.Background(), caller.ID, "192.0.2.1", "test")
+	if !errors.Is(err, ErrEventDeliveryFailed) {
+		t.Fatalf("TestEventDelivery = %v, want ErrEventDeliveryFailed", err)
+	}
+	if result == nil || result.Confirmed || result.Error != "broker nacked the message" || result.CorrelationID == "" {
+		t.Errorf("result = %+v, want an unconfirmed report with the error", result)
+	}
+
+	entries := f.audit.ofAction(domain.AuditEventDeliveryTested)
+	if len(entries) != 1 || entries[0].Metadata["confirmed"] != false {
+		t.Errorf("audit entries = %+v, want one unconfirmed test", entries)
+	}
+}
+
+// campusStudents returns n students of the campus
+func campusStudents(campusID string, n int) []*domain.User {
+	roles := make([]domain.Role, n)
+	for i := range roles {
+		roles[i] = domain.RoleStudent
+	}
+	return usersWithRoles(campusID, roles...)
+}
+
+func TestExportCampusUsersStreamsEveryUser(t *testing.T) {
+	north := campusStudents("north", 2*campusExportPageSize+1)
+	deleted := north[0]
+	deleted.DeletedAt = &deleted.CreatedAt
+	f := newBulkFixture(append(north, campusStudents("south", 3)...)...)
+	caller := uuid.New()
+
+	var pages []int
+	seen := make(map[uuid.UUID]bool)
+	last := ""
+	emit := func(records []*domain.CampusUserRecord) error {
+		pages = append(pages, len(records))
+		for _, record := range records {
+			if seen[record.ID] || record.ID.String() <= last {
+				t.Fatalf("user %s exported out of order or twice", record.ID)
+			}
+			seen[record.ID] = true
+			last = record.ID.String()
+		}
+		return nil
+	}
+	exported, err := f.service.ExportCampusUsers(context.Background(), caller, "north", "csv", emit, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("ExportCampusUsers: %v", err)
+	}
+
+	if exported != len(north)-1 || len(seen) != exported {
+		t.Errorf("exported %d users (%d distinct), want %d", exported, len(seen), len(north)-1)
+	}
+	for _, user := range north[1:] {
+		if !seen[user.ID] {
+			t.Fatalf("user %s was not exported", user.ID)
+		}
+	}
+	if seen[deleted.ID] {
+		t.Error("deleted user exported")
+	}
+	if want := []int{campusExportPageSize, campusExportPageSize}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	entries := f.audit.ofAction(domain.AuditCampusUsersExported)
+	if len(entries) != 1 {
+		t.Fatalf("audited %d exports, want 1", len(entries))
+	}
+	if m := entries[0].Metadata; entries[0].UserID != caller || m["campusId"] != "north" || m["users"] != exported || m["completed"] != true {
+		t.Errorf("audit entry = %+v", entries[0])
+	}
+}
+
+func TestExportCampusUsersAuditsFailureMidway(t *testing.T) {
+	f := newBulkFixture(campusStudents("north", campusExportPageSize+1)...)
+	broken := errors.New("client went away")
+
+	pages := 0
+	emit := func([]*domain.CampusUserRecord) error {
+		pages++
+		if pages == 2 {
+			return broken
+		}
+		return nil
+	}
+	exported, err := f.service.ExportCampusUsers(context.Background(), uuid.New(), "north", "json", emit, "192.0.2.1", "test")
+	if !errors.Is(err, broken) || exported != campusExportPageSize {
+		t.Fatalf("ExportCampusUsers = %d, %v; want %d, the emit error", exported, err, campusExportPageSize)
+	}
+
+	entries := f.audit.ofAction(domain.AuditCampusUsersExported)
+	if len(entries) != 1 || entries[0].Metadata["completed"] != false || entries[0].Metadata["users"] != campusExportPageSize {
+		t.Errorf("audit entries = %+v, want one incomplete export of a page", entries)
+	}
+}
+
+func TestExportEmptyCampus(t *testing.T) {
+	f := newBulkFixture(campusStudents("south", 2)...)
+	emit := func([]*domain.CampusUserRecord) error {
+		t.Error("emitted a page for an empty campus")
+		return nil
+	}
+	if exported, err := f.service.ExportCampusUsers(context.Background(), uuid.New(), "north", "csv", emit, "", ""); err != nil || exported != 0 {
+		t.Errorf("ExportCampusUsers = %d, %v; want nothing exported", exported, err)
+	}
+}
+
+func TestDeviceSessionsAcrossUsers(t *testing.T) {
+	ada := newTestUser(t, "ada@example.edu", "password-123")
+	alan := newTestUser(t, "alan@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, ada, alan)
+	audit := &memAuditRepo{}
+	service := &AdminService{sessionRepo: f.sessions, auditRepo: audit}
+	ctx := context.Background()
+	callerID := uuid.New()
+
+	loginOn := func(user *domain.User, deviceID string) *LoginResult {
+		t.Helper()
+		result, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "password-123", DeviceID: deviceID}, "192.0.2.1", "test-agent")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return result
+	}
+	onLab := []*LoginResult{loginOn(ada, "lab-42"), loginOn(alan, "lab-42"), loginOn(ada, "lab-42")}
+	elsewhere := loginOn(ada, "laptop-7")
+	loggedOut := loginOn(alan, "lab-42")
+	if err := f.service.Logout(ctx, loggedOut.RefreshToken, "", "192.0.2.1", "test-agent"); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	listed, err := service.ListDeviceSessions(ctx, "lab-42")
+	if err != nil {
+		t.Fatalf("ListDeviceSessions: %v", err)
+	}
+	users := make(map[uuid.UUID]int)
+	for _, session := range listed {
+		users[session.UserID]++
+		if session.DeviceID != "lab-42" || session.IsRevoked {
+			t.Errorf("listed session %+v", session)
+		}
+	}
+	if len(listed) != 3 || users[ada.ID] != 2 || users[alan.ID] != 1 {
+		t.Fatalf("listed %d sessions of %v, want the 3 active ones of both users", len(listed), users)
+	}
+	if unknown, err := service.ListDeviceSessions(ctx, "unknown"); err != nil || unknown == nil || len(unknown) != 0 {
+		t.Errorf("sessions of an unknown device = %v, %v, want an empty list", unknown, err)
+	}
+
+	result, err := service.RevokeDeviceSessions(ctx, callerID, domain.DeviceRevocationRequest{DeviceID: "lab-42"}, "198.51.100.1", "admin-agent")
+	if err != nil {
+		t.Fatalf("RevokeDeviceSessions: %v", err)
+	}
+	if result.Revoked != 3 || len(result.UserIDs) != 2 {
+		t.Errorf("revocation = %+v, want 3 sessions of 2 users", result)
+	}
+	for i, login := range onLab {
+		if _, err := f.service.RefreshToken(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("refresh of lab session %d = %v, want ErrInvalidToken", i, err)
+		}
+	}
+	if _, err := f.service.RefreshToken(ctx, elsewhere.RefreshToken); err != nil {
+		t.Errorf("refresh of a session on another device: %v", err)
+	}
+
+	audited := audit.ofAction(domain.AuditDeviceSessionsRevoked)
+	if len(audited) != 2 {
+		t.Fatalf("audited %d revocations, want one per user", len(audited))
+	}
+	for _, entry := range audited {
+		sessionIDs, _ := entry.Metadata["sessionIds"].([]uuid.UUID)
+		if entry.Metadata["actorId"] != callerID || entry.Metadata["deviceId"] != "lab-42" || len(sessionIDs) != users[entry.UserID] {
+			t.Errorf("audit of %s = %+v, want its %d sessions", entry.UserID, entry.Metadata, users[entry.UserID])
+		}
+	}
+
+	again, err := service.RevokeDeviceSessions(ctx, callerID, domain.DeviceRevocationRequest{DeviceID: "lab-42"}, "198.51.100.1", "admin-agent")
+	if err != nil || again.Revoked != 0 || again.UserIDs == nil || len(again.UserIDs) != 0 {
+		t.Errorf("second revocation = %+v, %v, want nothing revoked", again, err)
+	}
+	if len(audit.ofAction(domain.AuditDeviceSessionsRevoked)) != 2 {
+		t.Error("a revocation of no sessions was audited")
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// AppAuthorizationService lets users review the OAuth clients they
+// authorized and take that access back
+type AppAuthorizationService struct {
+	grantRepo   domain.AppAuthorizationRepository
+	sessionRepo domain.SessionRepository
+}
+
+// NewAppAuthorizationService creates a new AppAuthorizationService
+func NewAppAuthorizationService(grantRepo domain.AppAuthorizationRepository, sessionRepo domain.SessionRepository) *AppAuthorizationService {
+	return &AppAuthorizationService{grantRepo: grantRepo, sessionRepo: sessionRepo}
+}
+
+// List returns the clients the user authorized, most recently used first
+func (s *AppAuthorizationService) List(ctx context.Context, userID uuid.UUID) ([]*domain.AppAuthorization, error) {
+	authorizations, err := s.grantRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if authorizations == nil {
+		authorizations = []*domain.AppAuthorization{}
+	}
+	return authorizations, nil
+}
+
+// Revoke withdraws the user's authorization of the client. Every session
+// opened through it is revoked, so its refresh tokens and opaque access
+// tokens stop working; JWT access tokens lapse when they expire.
+func (s *AppAuthorizationService) Revoke(ctx context.Context, userID uuid.UUID, clientID string) error {
+	if err := s.sessionRepo.RevokeByClient(ctx, userID, clientID); err != nil {
+		return err
+	}
+	if err := s.grantRepo.Delete(userID, clientID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAppAuthorizationNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// addClient stores a client allowed the openid and profile scopes
+func addClient(t *testing.T, f *authFixture, clientID, name string, format domain.TokenFormat) *domain.OAuthClient {
+	t.Helper()
+	client, err := domain.NewOAuthClient(clientID, "client-secret", domain.OAuthClientRequest{
+		Name:              name,
+		RedirectURIs:      []string{"https://app.example.edu/callback"},
+		Scopes:            []string{"openid", "profile"},
+		GrantTypes:        []string{domain.GrantAuthorizationCode},
+		AccessTokenFormat: format,
+	})
+	if err != nil {
+		t.Fatalf("NewOAuthClient: %v", err)
+	}
+	f.clients.Create(client)
+	return client
+}
+
+func TestListAppAuthorizations(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	other := newTestUser(t, "alan@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user, other)
+	service := NewAppAuthorizationService(f.grants, f.sessions)
+	ctx := context.Background()
+	addClient(t, f, "campus-app", "Campus app", domain.TokenFormatDefault)
+	addClient(t, f, "library-app", "Library", domain.TokenFormatDefault)
+
+	if listed, err := service.List(ctx, user.ID); err != nil || listed == nil || len(listed) != 0 {
+		t.Fatalf("authorizations before any = %v, %v, want an empty list", listed, err)
+	}
+
+	f.login(t, user.Email, "")
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: "campus-app", Scope: "openid"}); err != nil {
+		t.Fatalf("login through campus-app: %v", err)
+	}
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: "library-app", Scope: "profile"}); err != nil {
+		t.Fatalf("login through library-app: %v", err)
+	}
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: "campus-app", Scope: "profile"}); err != nil {
+		t.Fatalf("second login through campus-app: %v", err)
+	}
+	// using a session of the library app makes it the most recent
+	library, _ := f.loginWith(domain.OIDCRequest{ClientID: "library-app"})
+	if _, err := f.service.RefreshToken(ctx, library.RefreshToken); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	listed, err := service.List(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("listed %d authorizations, want one per client: %+v", len(listed), listed)
+	}
+	if listed[0].ClientID != "library-app" || listed[0].ClientName != "Library" {
+		t.Errorf("most recently used = %+v, want library-app", listed[0])
+	}
+	if got := listed[1]; got.ClientID != "campus-app" || got.ClientName != "Campus app" || len(got.Scopes) != 2 || got.Scopes[0] != "openid" || got.Scopes[1] != "profile" {
+		t.Errorf("campus-app authorization = %+v, want the scopes of both logins", got)
+	}
+	if listed[1].GrantedAt.After(listed[1].LastUsedAt) {
+		t.Errorf("campus-app used at %v before it was granted at %v", listed[1].LastUsedAt, listed[1].GrantedAt)
+	}
+
+	if others, _ := service.List(ctx, other.ID); len(others) != 0 {
+		t.Errorf("another user lists %+v", others)
+	}
+}
+
+func TestRevokeAppAuthorization(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	service := NewAppAuthorizationService(f.grants, f.sessions)
+	ctx := context.Background()
+	addClient(t, f, "campus-app", "Campus app", domain.TokenFormatOpaque)
+	addClient(t, f, "library-app", "Library", domain.TokenFormatDefault)
+
+	direct := f.login(t, user.Email, "")
+	var campus []*LoginResult
+	for i := 0; i < 2; i++ {
+		result, err := f.loginWith(domain.OIDCRequest{ClientID: "campus-app", Scope: "openid"})
+		if err != nil {
+			t.Fatalf("login through campus-app: %v", err)
+		}
+		campus = append(campus, result)
+	}
+	library, err := f.loginWith(domain.OIDCRequest{ClientID: "library-app", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("login through library-app: %v", err)
+	}
+
+	if err := service.Revoke(ctx, user.ID, "campus-app"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	for i, result := range campus {
+		if _, err := f.service.RefreshToken(ctx, result.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("refresh of campus-app session %d = %v, want ErrInvalidToken", i, err)
+		}
+		if _, err := f.service.tokens.Validate(ctx, result.AccessToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("opaque access token of campus-app session %d = %v, want ErrInvalidToken", i, err)
+		}
+	}
+	for name, result := range map[string]*LoginResult{"library-app": library, "direct": direct} {
+		if _, err := f.service.RefreshToken(ctx, result.RefreshToken); err != nil {
+			t.Errorf("refresh of the %s session: %v", name, err)
+		}
+	}
+
+	listed, _ := service.List(ctx, user.ID)
+	if len(listed) != 1 || listed[0].ClientID != "library-app" {
+		t.Errorf("authorizations after the revocation = %+v, want only library-app", listed)
+	}
+	if err := service.Revoke(ctx, user.ID, "campus-app"); !errors.Is(err, ErrAppAuthorizationNotFound) {
+		t.Errorf("second revocation = %v, want ErrAppAuthorizationNotFound", err)
+	}
+
+	// logging in through the app again authorizes it anew
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: "campus-app", Scope: "openid"}); err != nil {
+		t.Fatalf("login after the revocation: %v", err)
+	}
+	if listed, _ := service.List(ctx, user.ID); len(listed) != 2 {
+		t.Errorf("authorizations after logging in again = %+v, want both apps", listed)
+	}
+}
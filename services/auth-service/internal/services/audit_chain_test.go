@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// chainedAudit returns a service whose audit log holds n chained entries
+func chainedAudit(t *testing.T, n int) (*AdminService, *memAuditRepo) {
+	t.Helper()
+	audit := &memAuditRepo{}
+	userID := uuid.New()
+	for i := 0; i < n; i++ {
+		if err := audit.Create(domain.NewAuditLog(userID, domain.AuditLoginSucceeded, "192.0.2.1", "test-agent", domain.AuditMetadata{"attempt": i})); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	return &AdminService{auditRepo: audit}, audit
+}
+
+func TestVerifyAuditChainIntact(t *testing.T) {
+	// more entries than one verification batch
+	service, _ := chainedAudit(t, auditVerifyBatch+5)
+	last := int64(auditVerifyBatch + 5)
+
+	tests := []struct {
+		name             string
+		from, to         int64
+		wantFrom, wantTo int64
+		wantChecked      int
+	}{
+		{"whole chain", 0, 0, 1, last, auditVerifyBatch + 5},
+		{"range", 10, 20, 10, 20, 11},
+		{"range past the end", last - 2, last + 10, last - 2, last, 3},
+		{"single entry", 7, 7, 7, 7, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := service.VerifyAuditChain(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("VerifyAuditChain: %v", err)
+			}
+			if !report.Valid || report.Break != nil || report.From != tt.wantFrom || report.To != tt.wantTo || report.Checked != tt.wantChecked {
+				t.Errorf("report = %+v, want a valid [%d, %d] of %d entries", report, tt.wantFrom, tt.wantTo, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int64
+		tamper   func(*memAuditRepo)
+		want     domain.AuditChainBreak
+		checked  int
+	}{
+		{"edited entry", 0, 0, func(r *memAuditRepo) {
+			r.entries[3].UserID = uuid.New()
+		}, domain.AuditChainBreak{Sequence: 4, Problem: domain.AuditEntryAltered}, 3},
+		{"edited and resealed entry", 0, 0, func(r *memAuditRepo) {
+			r.entries[3].Metadata = domain.AuditMetadata{"attempt": 99}
+			r.entries[3].Hash, _ = r.entries[3].ComputeHash()
+		}, domain.AuditChainBreak{Sequence: 5, Problem: domain.AuditLinkBroken}, 4},
+		{"deleted entry", 0, 0, func(r *memAuditRepo) {
+			r.entries = append(r.entries[:5], r.entries[6:]...)
+		}, domain.AuditChainBreak{Sequence: 6, Problem: domain.AuditEntryMissing}, 5},
+		{"deleted entry before the range", 4, 8, func(r *memAuditRepo) {
+			r.entries = append(r.entries[:2], r.entries[3:]...)
+		}, domain.AuditChainBreak{Sequence: 3, Problem: domain.AuditEntryMissing}, 0},
+		{"tampering outside the range", 1, 3, func(r *memAuditRepo) {
+			r.entries[6].Action = domain.AuditPasswordChanged
+		}, domain.AuditChainBreak{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, audit := chainedAudit(t, 10)
+			tt.tamper(audit)
+
+			report, err := service.VerifyAuditChain(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("VerifyAuditChain: %v", err)
+			}
+			if report.Checked != tt.checked {
+				t.Errorf("checked %d entries, want %d", report.Checked, tt.checked)
+			}
+			if tt.want == (domain.AuditChainBreak{}) {
+				if !report.Valid || report.Break != nil {
+					t.Errorf("report = %+v, want valid", report)
+				}
+				return
+			}
+			if report.Valid || report.Break == nil || *report.Break != tt.want {
+				t.Errorf("report = %+v with break %+v, want break %+v", report, report.Break, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditChainBounds(t *testing.T) {
+	empty, _ := chainedAudit(t, 0)
+	if report, err := empty.VerifyAuditChain(context.Background(), 0, 0); err != nil || !report.Valid || report.Checked != 0 {
+		t.Errorf("empty chain = %+v, %v, want valid", report, err)
+	}
+
+	service, _ := chainedAudit(t, 5)
+	var validationErr domain.ValidationError
+	if _, err := service.VerifyAuditChain(context.Background(), 4, 2); !errors.As(err, &validationErr) || validationErr.Field != "from" {
+		t.Errorf("reversed range = %v, want a ValidationError on from", err)
+	}
+	if _, err := service.VerifyAuditChain(context.Background(), 9, 0); !errors.As(err, &validationErr) {
+		t.Errorf("range past the chain = %v, want a ValidationError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.VerifyAuditChain(ctx, 0, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("verification with a cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// AuditWriter appends audit entries off the request path. Entries are queued
+// on a buffered channel and written in order by Run; when the buffer is full
+// the entry is written by the caller instead, so none is lost under load.
+type AuditWriter struct {
+	auditRepo domain.AuditRepository
+	entries   chan *domain.AuditLog
+}
+
+// NewAuditWriter creates an AuditWriter queueing up to bufferSize entries
+func NewAuditWriter(auditRepo domain.AuditRepository, bufferSize int) *AuditWriter {
+	return &AuditWriter{
+		auditRepo: auditRepo,
+		entries:   make(chan *domain.AuditLog, bufferSize),
+	}
+}
+
+// Record queues the entry for writing. A nil writer records nothing.
+func (w *AuditWriter) Record(entry *domain.AuditLog) {
+	if w == nil {
+		return
+	}
+
+	select {
+	case w.entries <- entry:
+	default:
+		log.Printf("Audit buffer full (%d entries), writing %s entry for user %s synchronously", cap(w.entries), entry.Action, entry.UserID)
+		w.write(entry)
+	}
+}
+
+// Run writes queued entries until ctx is done, then writes the ones still
+// queued before returning
+func (w *AuditWriter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			w.Flush()
+			return
+		case entry := <-w.entries:
+			w.write(entry)
+		}
+	}
+}
+
+// Flush writes the entries queued so far; called on shutdown once requests
+// have drained
+func (w *AuditWriter) Flush() {
+	for {
+		select {
+		case entry := <-w.entries:
+			w.write(entry)
+		default:
+			return
+		}
+	}
+}
+
+func (w *AuditWriter) write(entry *domain.AuditLog) {
+	if err := w.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to write %s audit entry for user %s: %v", entry.Action, entry.UserID, err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// loginSucceeded is the result of logins that opened a session; the other
+// results of auth_logins_total are the LoginFailureReason values
+const loginSucceeded = "success"
+
+// Results of auth_token_refresh_total
+const (
+	refreshSucceeded = "success"
+	refreshInvalid   = "invalid"
+	refreshReused    = "reused"
+	refreshMaxAge    = "max_age"
+	refreshInactive  = "inactive"
+	refreshError     = "error"
+)
+
+// activeSessionsTimeout bounds the count behind auth_active_sessions, taken
+// on every scrape
+const activeSessionsTimeout = 2 * time.Second
+
+var (
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts, by result: success or the reason the login did not complete.",
+	}, []string{"result"})
+	loginDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "auth_login_duration_seconds",
+		Help:    "Time taken to handle a password login, whatever its result.",
+		Buckets: prometheus.DefBuckets,
+	})
+	registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Accounts registered.",
+	})
+	tokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_token_refresh_total",
+		Help: "Refresh token uses, by result.",
+	}, []string{"result"})
+)
+
+// recordRefresh counts a refresh by the error it ended with
+func recordRefresh(err error) {
+	result := refreshError
+	switch {
+	case err == nil:
+		result = refreshSucceeded
+	case errors.Is(err, errRefreshTokenReused):
+		result = refreshReused
+	case errors.Is(err, ErrInvalidToken):
+		result = refreshInvalid
+	case errors.Is(err, ErrSessionMaxAge):
+		result = refreshMaxAge
+	case errors.Is(err, ErrAccountInactive):
+		result = refreshInactive
+	}
+	tokenRefreshes.WithLabelValues(result).Inc()
+}
+
+// RegisterSessionMetrics exports the number of active sessions of all users
+// as auth_active_sessions, counted in the database on every scrape so every
+// instance reports the same figure
+func RegisterSessionMetrics(sessionRepo domain.SessionRepository) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "auth_active_sessions",
+		Help: "Unrevoked, unexpired sessions of all users.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), activeSessionsTimeout)
+		defer cancel()
+
+		count, err := sessionRepo.CountActive(ctx)
+		if err != nil {
+			log.Printf("Failed to count active sessions: %v", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+}
@@ -0,0 +1,938 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	twoFactorAudience     = "unibazzar-2fa"
+
+	actionTokenTTL = 5 * time.Minute
+)
+
+// Claims are the JWT claims carried by access tokens. Of the registered
+// claims, jti identifies the token for revocation, sub is the user ID, iat
+// and nbf are the time of issue, and exp is iat plus the access token TTL;
+// tokens are rejected before nbf and from exp.
+type Claims struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	RiskScore int    `json:"risk_score"`
+	// MustChangePassword limits the token to changing the password
+	MustChangePassword bool `json:"mcp,omitempty"`
+	// EmailVerified tells whether the user had verified their email when
+	// the token was issued
+	EmailVerified bool `json:"email_verified,omitempty"`
+	// Scope lists the session's scopes when it was narrowed at login
+	Scope string `json:"scope,omitempty"`
+	// CampusID is the user's campus, the reach of a moderator
+	CampusID string `json:"campus_id,omitempty"`
+	// Delegation is set on tokens a delegate reads the grantor's account with
+	Delegation *DelegationContext `json:"dlg,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// DelegationContext names the delegation an access token was issued for
+type DelegationContext struct {
+	ID        string `json:"id"`
+	GrantorID string `json:"grantor"`
+}
+
+// ChallengeClaims are the JWT claims carried by 2FA challenge tokens
+type ChallengeClaims struct {
+	// Scope carries the session scope requested at login over to the second
+	// step, and RememberMe the choice of session lifetime
+	Scope      string `json:"scope,omitempty"`
+	RememberMe bool   `json:"rm,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActionClaims are the JWT claims carried by action tokens
+type ActionClaims struct {
+	Action string `json:"act"`
+	jwt.RegisteredClaims
+}
+
+// AuthConfig holds the AuthService settings taken from the service configuration
+type AuthConfig struct {
+	Keys           *SigningKeys
+	DeviceTrustTTL time.Duration
+	// AccessTokens issues access tokens in the format of each session
+	AccessTokens *AccessTokens
+	// RefreshTokenTTL is the lifetime of a session, and so of its refresh
+	// tokens; ShortSessionTTL that of a session opened without remember me
+	RefreshTokenTTL time.Duration
+	ShortSessionTTL time.Duration
+	// SessionMaxAge caps how long a session can be kept alive by refreshing
+	SessionMaxAge time.Duration
+	// Issuer is the iss claim of ID tokens: the service's public URL
+	Issuer string
+	// RefreshGraceWindow is how long a rotated refresh token keeps returning
+	// the current tokens instead of being treated as reused
+	RefreshGraceWindow time.Duration
+	// Lockout tracks failed password attempts; nil disables lockouts
+	Lockout *ratelimit.Lockout
+	// TwoFactorLockout tracks failed TOTP codes by user ID; nil disables it.
+	// Whoever is challenged knows the password, so it should lock the
+	// account rather than one address.
+	TwoFactorLockout *ratelimit.Lockout
+	// Peppers are mixed into passwords; logins rehash passwords of older peppers
+	Peppers domain.Peppers
+	// ReactivationWindow is how long a self-deactivated account can be
+	// reactivated; zero means indefinitely
+	ReactivationWindow time.Duration
+	// PinLockoutRevokes revokes a session once its PIN attempts are used up,
+	// instead of only refusing further PIN unlocks
+	PinLockoutRevokes bool
+	// MaxSessionsPerUser caps the active sessions of a user, evicting the
+	// least recently used when a login would exceed it; zero disables the cap
+	MaxSessionsPerUser int
+	// Audit records logins, logouts and session revocations; nil records nothing
+	Audit *AuditWriter
+}
+
+// AuthService handles authentication and token issuance
+type AuthService struct {
+	userRepo    domain.UserRepository
+	sessionRepo domain.SessionRepository
+	deviceRepo  domain.TrustedDeviceRepository
+	methodRepo  domain.MFAMethodRepository
+	cutoffRepo  domain.RevocationCutoffRepository
+	clientRepo  domain.OAuthClientRepository
+	grantRepo   domain.AppAuthorizationRepository
+	risk        *RiskAssessor
+	publisher   events.Publisher
+	keys        *SigningKeys
+	tokens      *AccessTokens
+	config      AuthConfig
+}
+
+// NewAuthService creates a new AuthService
+func NewAuthService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, deviceRepo domain.TrustedDeviceRepository, methodRepo domain.MFAMethodRepository, cutoffRepo domain.RevocationCutoffRepository, clientRepo domain.OAuthClientRepository, grantRepo domain.AppAuthorizationRepository, risk *RiskAssessor, publisher events.Publisher, config AuthConfig) *AuthService {
+	return &AuthService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		deviceRepo:  deviceRepo,
+		methodRepo:  methodRepo,
+		cutoffRepo:  cutoffRepo,
+		clientRepo:  clientRepo,
+		grantRepo:   grantRepo,
+		risk:        risk,
+		publisher:   publisher,
+		keys:        config.Keys,
+		tokens:      config.AccessTokens,
+		config:      config,
+	}
+}
+
+// LoginResult is the outcome of a login step: either a token pair, or a
+// challenge that must be completed with VerifyTwoFactor
+type LoginResult struct {
+	*domain.TokenPair
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	// MFAMethods are the second factors the challenge can be completed with, preferred first
+	MFAMethods []*domain.MFAMethod `json:"mfa_methods,omitempty"`
+	// DeviceToken is returned once when the device was marked as trusted
+	DeviceToken string `json:"device_token,omitempty"`
+	// MustChangePassword tells the client the session can only change the password
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+}
+
+// Login verifies the credentials and opens a new session. Users with 2FA
+// enabled get a challenge instead, unless they present a trusted device token.
+// Logins requesting the openid scope also receive an ID token.
+func (s *AuthService) Login(ctx context.Context, login domain.UserLogin, ipAddress, userAgent string) (*LoginResult, error) {
+	defer prometheus.NewTimer(loginDuration).ObserveDuration()
+
+	client, err := s.loginClient(login.OIDCRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, locked := s.config.Lockout.Check(login.Email, ipAddress); locked {
+		s.loginFailed(LoginAccountLocked, nil, ipAddress, userAgent)
+		return nil, ErrTooManyLoginAttempts
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, login.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// unknown accounts count too, so lockouts do not reveal which exist
+			s.config.Lockout.Fail(login.Email, ipAddress)
+			s.loginFailed(LoginUnknownUser, nil, ipAddress, userAgent)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.CheckPassword(login.Password, s.config.Peppers) {
+		s.config.Lockout.Fail(login.Email, ipAddress)
+		s.loginFailed(LoginBadPassword, &user.ID, ipAddress, userAgent)
+		return nil, ErrInvalidCredentials
+	}
+	s.config.Lockout.Reset(login.Email, ipAddress)
+	s.rehashPassword(ctx, user, login.Password)
+	if err := s.checkLoginAllowed(user); err != nil {
+		s.loginFailed(accountFailureReason(err), &user.ID, ipAddress, userAgent)
+		return nil, err
+	}
+	scopes, err := login.Narrow(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled && !s.isTrustedDevice(user, login.DeviceToken) {
+		challenge, err := s.signChallenge(user, scopes, login.RememberMe)
+		if err != nil {
+			return nil, err
+		}
+		methods, err := enrolledMethods(s.methodRepo, user)
+		if err != nil {
+			return nil, err
+		}
+		s.loginFailed(LoginTwoFactorRequired, &user.ID, ipAddress, userAgent)
+		return &LoginResult{TwoFactorRequired: true, ChallengeToken: challenge, MFAMethods: methods}, nil
+	}
+
+	if err := s.grantClient(user, client, login.OIDCRequest); err != nil {
+		return nil, err
+	}
+
+	// past the challenge, a 2FA user must have presented a trusted device
+	tokens, err := s.openSession(ctx, user, domain.SessionAuth{
+		Method:        domain.AuthPassword,
+		TrustedDevice: user.TwoFactorEnabled,
+	}, scopes, login.RememberMe, client, login.DeviceID, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(tokens, user, client, login.OIDCRequest); err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokens, MustChangePassword: user.MustChangePassword}, nil
+}
+
+// VerifyTwoFactor completes a challenged login with a TOTP code, optionally
+// remembering the device so the second factor is skipped for DeviceTrustTTL.
+// Each code is accepted once, and failed codes count towards the
+// TwoFactorLockout of the user.
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, req domain.TwoFactorLogin, ipAddress, userAgent string) (*LoginResult, error) {
+	userID, challenge, err := s.parseChallenge(req.ChallengeToken)
+	if err != nil {
+		s.loginFailed(LoginTwoFactorFailed, nil, ipAddress, userAgent)
+		return nil, err
+	}
+	client, err := s.loginClient(req.OIDCRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.checkLoginAllowed(user); err != nil {
+		s.loginFailed(accountFailureReason(err), &user.ID, ipAddress, userAgent)
+		return nil, err
+	}
+	if _, locked := s.config.TwoFactorLockout.Check(user.ID.String(), ipAddress); locked {
+		s.loginFailed(LoginAccountLocked, &user.ID, ipAddress, userAgent)
+		return nil, ErrTooManyLoginAttempts
+	}
+	step, valid := matchTOTP(user.TwoFactorSecret, req.Code, time.Now())
+	if valid && user.TwoFactorEnabled {
+		// a replayed code, or one older than the last accepted, is refused
+		err := s.userRepo.UseTOTPStep(ctx, user.ID, step)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		valid = err == nil
+	}
+	if !valid || !user.TwoFactorEnabled {
+		s.config.TwoFactorLockout.Fail(user.ID.String(), ipAddress)
+		s.loginFailed(LoginTwoFactorFailed, &user.ID, ipAddress, userAgent)
+		return nil, ErrInvalidTwoFactorCode
+	}
+	s.config.TwoFactorLockout.Reset(user.ID.String(), ipAddress)
+
+	if err := s.grantClient(user, client, req.OIDCRequest); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.openSession(ctx, user, domain.SessionAuth{Method: domain.AuthPassword, MFAUsed: true}, domain.ParseSessionScopes(challenge.Scope), challenge.RememberMe, client, req.DeviceID, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachIDToken(tokens, user, client, req.OIDCRequest); err != nil {
+		return nil, err
+	}
+	result := &LoginResult{TokenPair: tokens, MustChangePassword: user.MustChangePassword}
+
+	if req.TrustDevice {
+		deviceToken, err := generateToken()
+		if err != nil {
+			return nil, err
+		}
+		device := domain.NewTrustedDevice(user.ID, deviceToken, userAgent, time.Now().Add(s.config.DeviceTrustTTL))
+		if err := s.deviceRepo.Create(device); err != nil {
+			return nil, err
+		}
+		result.DeviceToken = deviceToken
+	}
+
+	return result, nil
+}
+
+// IssueActionToken mints a short-lived token that lets the user perform a
+// single action on the service named by the action's audience
+func (s *AuthService) IssueActionToken(ctx context.Context, userID uuid.UUID, actionName string) (*domain.ActionToken, error) {
+	action, ok := domain.Actions[actionName]
+	if !ok {
+		return nil, ErrUnknownAction
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.checkLoginAllowed(user); err != nil {
+		return nil, err
+	}
+	if !action.Allows(user.Role) {
+		return nil, ErrActionNotPermitted
+	}
+
+	now := time.Now()
+	claims := ActionClaims{
+		Action: action.Name,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{action.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(actionTokenTTL)),
+		},
+	}
+
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign action token: %w", err)
+	}
+
+	return &domain.ActionToken{
+		Token:     token,
+		Action:    action.Name,
+		Audience:  action.Audience,
+		ExpiresIn: int(actionTokenTTL.Seconds()),
+	}, nil
+}
+
+// openSession records the login and creates a new session with its token
+// pair; auth tells how the user authenticated and client, when not nil, the
+// app the session is opened through, whose registration picks the form of
+// the session's access tokens. rememberMe picks the long session lifetime
+// over the short one. deviceID identifies the machine, empty when unknown.
+func (s *AuthService) openSession(ctx context.Context, user *domain.User, auth domain.SessionAuth, scopes domain.SessionScopes, rememberMe bool, client *domain.OAuthClient, deviceID, ipAddress, userAgent string) (*domain.TokenPair, error) {
+	lastLogin := user.LastLoginOrigin()
+	user.UpdateLastLogin(ipAddress, userAgent)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	previous, err := s.sessionRepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		log.Printf("Failed to load previous sessions for risk scoring: %v", err)
+	}
+	familiar := familiarOrigins(lastLogin, previous)
+
+	session := domain.NewSession(user.ID, "", ipAddress, userAgent, s.sessionExpiry(time.Now(), rememberMe))
+	session.RememberMe = rememberMe
+	session.SessionAuth = auth
+	session.Scopes = scopes
+	session.DeviceID = deviceID
+	if client != nil {
+		session.ClientID = client.ClientID
+		session.TokenFormat = client.AccessTokenFormat
+	}
+	session.AssessRisk(s.risk.Assess(ctx, user.ID, ipAddress, userAgent, previous))
+	evicted, err := s.evictOldSessions(ctx, user.ID, session)
+	if err != nil {
+		return nil, err
+	}
+	err = withUniqueRefreshToken(func(refreshToken string) error {
+		session.SetRefreshToken(refreshToken)
+		return s.sessionRepo.Create(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logins.WithLabelValues(loginSucceeded).Inc()
+	s.config.Audit.Record(domain.NewAuditLog(user.ID, domain.AuditLoginSucceeded, ipAddress, userAgent, domain.AuditMetadata{
+		"session_id": session.ID.String(),
+		"method":     string(auth.Method),
+	}))
+
+	if err := s.publisher.Publish(ctx, userLoggedInEvent(user, session)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserLoggedIn, err)
+	}
+	s.alertSuspiciousLogin(ctx, user, session, familiar)
+
+	tokens, err := s.issueTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
+	if evicted > 0 {
+		tokens.Warning = fmt.Sprintf("signed out of %d older session(s) to stay within the limit of %d active sessions", evicted, s.config.MaxSessionsPerUser)
+	}
+	return tokens, nil
+}
+
+// evictOldSessions revokes the least recently used sessions of the user
+// until opening replacement keeps them within MaxSessionsPerUser, and
+// returns how many it revoked
+func (s *AuthService) evictOldSessions(ctx context.Context, userID uuid.UUID, replacement *domain.Session) (int, error) {
+	limit := s.config.MaxSessionsPerUser
+	if limit <= 0 {
+		return 0, nil
+	}
+	active, err := s.sessionRepo.CountActiveByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	evicted := 0
+	for ; active >= limit; active-- {
+		oldest, err := s.sessionRepo.GetOldestActiveByUserID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			return evicted, fmt.Errorf("failed to get oldest session: %w", err)
+		}
+		oldest.Revoke()
+		if err := s.sessionRepo.Update(ctx, oldest); err != nil {
+			return evicted, err
+		}
+		evicted++
+
+		if err := s.publisher.Publish(ctx, sessionEvictedEvent(oldest, replacement, limit)); err != nil {
+			log.Printf("Failed to publish %s event: %v", events.SessionEvicted, err)
+		}
+	}
+	return evicted, nil
+}
+
+// sessionExpiry returns when a session created at now expires: after the
+// refresh TTL, but never beyond the absolute maximum session age
+func (s *AuthService) sessionExpiry(now time.Time, rememberMe bool) time.Time {
+	ttl := s.config.RefreshTokenTTL
+	if !rememberMe {
+		ttl = s.config.ShortSessionTTL
+	}
+	if s.config.SessionMaxAge > 0 && s.config.SessionMaxAge < ttl {
+		ttl = s.config.SessionMaxAge
+	}
+	return now.Add(ttl)
+}
+
+// isTrustedDevice checks a device-trust token presented at login
+func (s *AuthService) isTrustedDevice(user *domain.User, deviceToken string) bool {
+	if deviceToken == "" {
+		return false
+	}
+
+	device, err := s.deviceRepo.GetByTokenHash(domain.HashToken(deviceToken))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to look up trusted device: %v", err)
+		}
+		return false
+	}
+	if device.UserID != user.ID || !device.IsValid() {
+		return false
+	}
+
+	device.UpdateLastUsed()
+	if err := s.deviceRepo.Update(device); err != nil {
+		log.Printf("Failed to update trusted device: %v", err)
+	}
+	return true
+}
+
+// rehashPassword moves a password hashed with an older pepper to the current
+// one. Failures are only logged; the old hash keeps working meanwhile.
+func (s *AuthService) rehashPassword(ctx context.Context, user *domain.User, password string) {
+	if !user.NeedsRehash(s.config.Peppers) {
+		return
+	}
+	if err := user.RehashPassword(password, s.config.Peppers); err != nil {
+		log.Printf("Failed to rehash password of user %s: %v", user.ID, err)
+		return
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		log.Printf("Failed to store rehashed password of user %s: %v", user.ID, err)
+	}
+}
+
+// signChallenge issues a short-lived token proving the first factor succeeded
+func (s *AuthService) signChallenge(user *domain.User, scopes domain.SessionScopes, rememberMe bool) (string, error) {
+	now := time.Now()
+	claims := ChallengeClaims{
+		Scope:      scopes.String(),
+		RememberMe: rememberMe,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{twoFactorAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeTTL)),
+		},
+	}
+
+	challenge, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign 2FA challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// parseChallenge validates a 2FA challenge token and returns its user ID and
+// claims, which carry the session options chosen at login
+func (s *AuthService) parseChallenge(challenge string) (uuid.UUID, *ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+	if err := s.keys.Parse(challenge, claims, jwt.WithAudience(twoFactorAudience)); err != nil {
+		return uuid.Nil, nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, ErrInvalidToken
+	}
+	return userID, claims, nil
+}
+
+// RefreshToken rotates the refresh token of a session and issues a new token
+// pair. Presenting the token replaced by the last rotation within
+// RefreshGraceWindow returns the current tokens, so clients racing each other
+// stay logged in; presenting it later is treated as token theft and revokes
+// the session.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+	tokens, err := s.refreshToken(ctx, refreshToken)
+	recordRefresh(err)
+	return tokens, err
+}
+
+func (s *AuthService) refreshToken(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+	session, err := s.sessionRepo.GetByRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.refreshWithRotatedToken(ctx, refreshToken)
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	user, err := s.refreshableSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withUniqueRefreshToken(func(nonce string) error {
+		session.RotateRefreshToken(refreshToken, nonce)
+		return s.sessionRepo.Update(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.touchGrant(session)
+
+	return s.issueTokens(user, session)
+}
+
+// refreshWithRotatedToken handles a refresh token that was already rotated away
+func (s *AuthService) refreshWithRotatedToken(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+	session, err := s.sessionRepo.GetByPreviousRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.IsRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	if !session.WithinRotationGrace(s.config.RefreshGraceWindow) {
+		log.Printf("Refresh token reuse detected on session %s of user %s; revoking the session", session.ID, session.UserID)
+		session.Revoke()
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			log.Printf("Failed to revoke session after refresh token reuse: %v", err)
+		}
+		return nil, errRefreshTokenReused
+	}
+
+	user, err := s.refreshableSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if !session.RecoverRefreshToken(refreshToken) {
+		return nil, ErrInvalidToken
+	}
+	return s.issueTokens(user, session)
+}
+
+// refreshableSession checks that the session may still be refreshed and
+// returns its user. Sessions past a revocation cutoff or their maximum age
+// are revoked.
+func (s *AuthService) refreshableSession(ctx context.Context, session *domain.Session) (*domain.User, error) {
+	if session.IsRevoked || session.IsExpired() {
+		return nil, ErrInvalidToken
+	}
+	revokeAt, err := s.revocationTime(session)
+	if err != nil {
+		return nil, err
+	}
+	if revokeAt != nil && !time.Now().Before(*revokeAt) {
+		session.Revoke()
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			log.Printf("Failed to revoke session past its revocation cutoff: %v", err)
+		}
+		return nil, ErrInvalidToken
+	}
+	if session.ExceedsMaxAge(s.config.SessionMaxAge) {
+		session.Revoke()
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			log.Printf("Failed to revoke session past its maximum age: %v", err)
+		}
+		return nil, ErrSessionMaxAge
+	}
+
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrAccountInactive
+	}
+	return user, nil
+}
+
+// alertSuspiciousLogin tells the user of a login from a device or location
+// they have not recently logged in from. It is best effort: a failure is
+// only logged and never fails the login.
+func (s *AuthService) alertSuspiciousLogin(ctx context.Context, user *domain.User, session *domain.Session, familiar []domain.LoginOrigin) {
+	login := s.risk.Unfamiliar(ctx, session.IPAddress, session.UserAgent, familiar)
+	if login == nil {
+		return
+	}
+	if err := s.publisher.Publish(ctx, loginSuspiciousEvent(user, session, login)); err != nil {
+		log.Printf("Failed to publish %s event for session %s of user %s: %v", events.LoginSuspicious, session.ID, user.ID, err)
+	}
+}
+
+// IntrospectRefreshToken describes a refresh token, as AccessTokens.Introspect
+// does an access token. A token is active while a refresh with it would
+// succeed; a rotated token is not, even within the grace window. Unlike a
+// refresh, introspection never revokes the session.
+func (s *AuthService) IntrospectRefreshToken(ctx context.Context, refreshToken string) (*TokenIntrospection, error) {
+	inactive := &TokenIntrospection{}
+
+	session, err := s.sessionRepo.GetByRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return inactive, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !session.IsActive() || session.ExceedsMaxAge(s.config.SessionMaxAge) {
+		return inactive, nil
+	}
+	revokeAt, err := s.revocationTime(session)
+	if err != nil {
+		return nil, err
+	}
+	if revokeAt != nil && !time.Now().Before(*revokeAt) {
+		return inactive, nil
+	}
+
+	issuedAt := session.CreatedAt
+	if session.RotatedAt != nil {
+		issuedAt = *session.RotatedAt
+	}
+	revoked, err := s.tokens.blacklist.IsUserRevoked(session.UserID.String(), issuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token blacklist: %w", err)
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return inactive, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return inactive, nil
+	}
+
+	return &TokenIntrospection{
+		Active:    true,
+		TokenType: TokenTypeRefresh,
+		Subject:   user.ID.String(),
+		JTI:       session.ID.String(),
+		Email:     user.Email,
+		Role:      string(user.Role),
+		SessionID: session.ID.String(),
+		Scope:     session.Scopes.String(),
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: session.ExpiresAt.Unix(),
+	}, nil
+}
+
+// SessionStatus describes the caller's session, including any revocation
+// scheduled for it so the client can log in again ahead of time
+func (s *AuthService) SessionStatus(ctx context.Context, userID, sessionID uuid.UUID) (*domain.SessionStatus, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != userID || session.IsRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	revokeAt, err := s.revocationTime(session)
+	if err != nil {
+		return nil, err
+	}
+	// a cutoff already reached has ended the session, as a refresh would find
+	if revokeAt != nil && !time.Now().Before(*revokeAt) {
+		return nil, ErrInvalidToken
+	}
+
+	return &domain.SessionStatus{
+		SessionID:             session.ID,
+		UserID:                session.UserID,
+		CreatedAt:             session.CreatedAt,
+		ExpiresAt:             session.ExpiresAt,
+		RiskScore:             session.RiskScore,
+		RevocationScheduledAt: revokeAt,
+	}, nil
+}
+
+// SessionPosture describes how the caller's session was authenticated and
+// how risky it looks
+func (s *AuthService) SessionPosture(ctx context.Context, userID, sessionID uuid.UUID) (*domain.SessionPosture, error) {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != userID || session.IsRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return session.Posture(user), nil
+}
+
+// revocationTime returns when a revocation cutoff revokes the session, if ever
+func (s *AuthService) revocationTime(session *domain.Session) (*time.Time, error) {
+	cutoffs, err := s.cutoffRepo.ListForUser(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return session.RevocationTime(cutoffs), nil
+}
+
+// Logout revokes the session owning the refresh token and blacklists the
+// session's access token presented with it, if any
+func (s *AuthService) Logout(ctx context.Context, refreshToken, accessToken, ipAddress, userAgent string) error {
+	session, err := s.sessionRepo.GetByRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	session.Revoke()
+	if err := s.sessionRepo.Update(ctx, session); err != nil {
+		return err
+	}
+	s.config.Audit.Record(domain.NewAuditLog(session.UserID, domain.AuditLoggedOut, ipAddress, userAgent, domain.AuditMetadata{
+		"session_id": session.ID.String(),
+	}))
+	return s.revokeAccessToken(ctx, session, accessToken)
+}
+
+// revokeAccessToken blacklists an access token of the session, so it stops
+// working before it expires. Tokens that are invalid already, or belong to
+// another session, are left alone.
+func (s *AuthService) revokeAccessToken(ctx context.Context, session *domain.Session, accessToken string) error {
+	if accessToken == "" {
+		return nil
+	}
+	claims, err := s.tokens.Validate(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return nil
+		}
+		return err
+	}
+	if claims.SessionID != session.ID.String() {
+		return nil
+	}
+	return s.tokens.revoke(claims)
+}
+
+// checkLoginAllowed reports why an account with valid credentials may not
+// log in. A deactivated account is told how long it can still be reactivated.
+func (s *AuthService) checkLoginAllowed(user *domain.User) error {
+	switch user.Status().State {
+	case domain.AccountMerged:
+		return ErrAccountMerged
+	case domain.AccountBanned:
+		return ErrAccountBanned
+	case domain.AccountSuspended:
+		return ErrAccountSuspended
+	case domain.AccountDisabled:
+		return ErrAccountDisabled
+	case domain.AccountDeactivated:
+		if user.ReactivationExpired(s.config.ReactivationWindow) {
+			return ErrReactivationClosed
+		}
+		return AccountDeactivatedError{ReactivateBefore: user.ReactivationDeadline(s.config.ReactivationWindow)}
+	}
+	return nil
+}
+
+// issueTokens returns a new access token for the session, valid for the
+// configured access token TTL, with the session's refresh token. ExpiresAt
+// is the expiry of the access token.
+func (s *AuthService) issueTokens(user *domain.User, session *domain.Session) (*domain.TokenPair, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.tokens.ttl)
+
+	claims := Claims{
+		UserID:             user.ID.String(),
+		Email:              user.Email,
+		Role:               string(user.Role),
+		SessionID:          session.ID.String(),
+		RiskScore:          session.RiskScore,
+		MustChangePassword: user.MustChangePassword,
+		EmailVerified:      user.IsVerified,
+		Scope:              session.Scopes.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if user.CampusID != nil {
+		claims.CampusID = *user.CampusID
+	}
+
+	accessToken, err := s.tokens.issue(session, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     session.RefreshToken(),
+		ExpiresAt:        expiresAt,
+		SessionExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// ParseAccessToken validates an access token signed with keys and returns its
+// claims. Other tokens signed with the same keys, such as action, challenge and
+// ID tokens, name no session and are rejected.
+func ParseAccessToken(tokenString string, keys *SigningKeys) (*Claims, error) {
+	claims := &Claims{}
+	if err := keys.Parse(tokenString, claims); err != nil || claims.UserID == "" || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseActionToken validates an action token for the given audience and returns its claims
+func ParseActionToken(tokenString string, keys *SigningKeys, audience string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	if err := keys.Parse(tokenString, claims, jwt.WithAudience(audience)); err != nil || claims.Action == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// refreshTokenAttempts bounds how many refresh tokens are generated for a
+// session when the previous ones collide with tokens of other sessions
+const refreshTokenAttempts = 3
+
+// withUniqueRefreshToken passes a new random token to store, as the refresh
+// token of a new session or the nonce of a rotation, generating another one
+// while store reports the resulting token is held by another session
+func withUniqueRefreshToken(store func(refreshToken string) error) error {
+	for attempt := 1; ; attempt++ {
+		refreshToken, err := generateToken()
+		if err != nil {
+			return err
+		}
+		err = store(refreshToken)
+		if !errors.Is(err, domain.ErrRefreshTokenTaken) || attempt == refreshTokenAttempts {
+			return err
+		}
+		log.Printf("Generated refresh token is held by another session, retrying (attempt %d)", attempt)
+	}
+}
+
+// generateToken returns a random URL-safe opaque token
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
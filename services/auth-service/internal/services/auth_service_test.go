@@ -0,0 +1,937 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// authFixture is an AuthService over in-memory repositories
+type authFixture struct {
+	service   *AuthService
+	users     *memUserRepo
+	sessions  *memSessionRepo
+	devices   *memDeviceRepo
+	methods   *memMFAMethodRepo
+	cutoffs   *memCutoffRepo
+	clients   *memOAuthClientRepo
+	grants    *memGrantRepo
+	publisher *recordingPublisher
+}
+
+func newAuthFixture(t *testing.T, config AuthConfig, users ...*domain.User) *authFixture {
+	t.Helper()
+	keys := NewHMACKeys("test-secret")
+	sessions := newMemSessionRepo()
+	clients := newMemOAuthClientRepo()
+	config.Keys = keys
+	config.AccessTokens = NewAccessTokens(keys, nil, newMemAccessTokenRepo(sessions), NewMemoryBlacklist(), 15*time.Minute)
+	if config.RefreshTokenTTL == 0 {
+		config.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if config.ShortSessionTTL == 0 {
+		config.ShortSessionTTL = 24 * time.Hour
+	}
+
+	f := &authFixture{
+		users:     newMemUserRepo(users...),
+		sessions:  sessions,
+		devices:   newMemDeviceRepo(),
+		methods:   &memMFAMethodRepo{},
+		cutoffs:   &memCutoffRepo{},
+		clients:   clients,
+		grants:    &memGrantRepo{clients: clients},
+		publisher: &recordingPublisher{},
+	}
+	f.service = NewAuthService(f.users, f.sessions, f.devices, f.methods, f.cutoffs, f.clients, f.grants, NewRiskAssessor(nil, nil, nil), f.publisher, config)
+	return f
+}
+
+// login logs in with the password every test user has
+func (f *authFixture) login(t *testing.T, email, deviceToken string) *LoginResult {
+	t.Helper()
+	result, err := f.service.Login(context.Background(), domain.UserLogin{Email: email, Password: "password-123", DeviceToken: deviceToken}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return result
+}
+
+// enableTOTP turns on 2FA for the user and returns its secret
+func enableTOTP(t *testing.T, user *domain.User) string {
+	t.Helper()
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generateTOTPSecret: %v", err)
+	}
+	user.TwoFactorSecret = secret
+	user.EnableTwoFactor()
+	return secret
+}
+
+// currentTOTP returns the code of the secret for now
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	return stepTOTP(t, secret, time.Now().Unix()/int64(totpPeriod.Seconds()))
+}
+
+// stepTOTP returns the code of the secret for the time step
+func stepTOTP(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatalf("decode TOTP secret: %v", err)
+	}
+	return totpCode(key, uint64(step))
+}
+
+func TestTrustedDeviceSkipsTwoFactor(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	secret := enableTOTP(t, user)
+	f := newAuthFixture(t, AuthConfig{DeviceTrustTTL: 30 * 24 * time.Hour}, user)
+	ctx := context.Background()
+
+	challenged := f.login(t, user.Email, "")
+	if !challenged.TwoFactorRequired || challenged.TokenPair != nil {
+		t.Fatalf("login without a trusted device was not challenged: %+v", challenged)
+	}
+
+	verified, err := f.service.VerifyTwoFactor(ctx, domain.TwoFactorLogin{
+		ChallengeToken: challenged.ChallengeToken,
+		Code:           currentTOTP(t, secret),
+		TrustDevice:    true,
+	}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("VerifyTwoFactor: %v", err)
+	}
+	if verified.TokenPair == nil || verified.DeviceToken == "" {
+		t.Fatalf("VerifyTwoFactor = %+v, want tokens and a device token", verified)
+	}
+
+	devices, err := NewTwoFactorService(f.users, f.devices, f.methods, f.publisher, nil).ListTrustedDevices(ctx, user.ID)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("ListTrustedDevices = %d devices, %v", len(devices), err)
+	}
+	if devices[0].TokenHash == verified.DeviceToken {
+		t.Error("the device token is stored in plaintext")
+	}
+	if until := time.Until(devices[0].ExpiresAt); until < 29*24*time.Hour || until > 30*24*time.Hour {
+		t.Errorf("device trusted for %v, want the configured 30 days", until)
+	}
+
+	trusted := f.login(t, user.Email, verified.DeviceToken)
+	if trusted.TwoFactorRequired || trusted.TokenPair == nil {
+		t.Fatalf("login from the trusted device was challenged: %+v", trusted)
+	}
+
+	// trust belongs to the user it was granted to
+	other := newTestUser(t, "alan@example.edu", "password-123")
+	enableTOTP(t, other)
+	if err := f.users.Create(ctx, other); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if result := f.login(t, other.Email, verified.DeviceToken); !result.TwoFactorRequired {
+		t.Error("another user's device token skipped the second factor")
+	}
+}
+
+func TestVerifyTwoFactorRefusesUsedCode(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	secret := enableTOTP(t, user)
+	f := newAuthFixture(t, AuthConfig{}, user)
+	step := time.Now().Unix() / int64(totpPeriod.Seconds())
+
+	verify := func(code string) error {
+		challenged := f.login(t, user.Email, "")
+		_, err := f.service.VerifyTwoFactor(context.Background(), domain.TwoFactorLogin{ChallengeToken: challenged.ChallengeToken, Code: code}, "192.0.2.1", "test-agent")
+		return err
+	}
+
+	if err := verify(stepTOTP(t, secret, step)); err != nil {
+		t.Fatalf("VerifyTwoFactor: %v", err)
+	}
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"the same code", stepTOTP(t, secret, step)},
+		{"the code of the previous step", stepTOTP(t, secret, step-1)},
+	}
+	for _, tt := range tests {
+		if err := verify(tt.code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Errorf("%s: VerifyTwoFactor = %v, want ErrInvalidTwoFactorCode", tt.name, err)
+		}
+	}
+
+	// the next step's code, within the skew, is still fresh
+	if err := verify(stepTOTP(t, secret, step+1)); err != nil {
+		t.Errorf("VerifyTwoFactor with the next code: %v", err)
+	}
+}
+
+func TestVerifyTwoFactorLockout(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	secret := enableTOTP(t, user)
+	f := newAuthFixture(t, AuthConfig{TwoFactorLockout: ratelimit.NewLockout(ratelimit.LockoutPolicy{
+		Scope:       ratelimit.ScopeAccount,
+		MaxFailures: 3,
+		Window:      time.Minute,
+		Duration:    time.Minute,
+	})}, user)
+	ctx := context.Background()
+	challenged := f.login(t, user.Email, "")
+
+	// guesses from different addresses on one challenge count together
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		_, err := f.service.VerifyTwoFactor(ctx, domain.TwoFactorLogin{ChallengeToken: challenged.ChallengeToken, Code: "12345"}, ip, "test-agent")
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("guess %d: VerifyTwoFactor = %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+
+	// a fresh challenge and the right code do not get past the lockout
+	challenged = f.login(t, user.Email, "")
+	_, err := f.service.VerifyTwoFactor(ctx, domain.TwoFactorLogin{ChallengeToken: challenged.ChallengeToken, Code: currentTOTP(t, secret)}, "192.0.2.4", "test-agent")
+	if !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("VerifyTwoFactor after the lockout = %v, want ErrTooManyLoginAttempts", err)
+	}
+}
+
+func TestRevokedOrExpiredDeviceIsChallenged(t *testing.T) {
+	tests := []struct {
+		name    string
+		untrust func(f *authFixture, device *domain.TrustedDevice) error
+	}{
+		{"revoked", func(f *authFixture, device *domain.TrustedDevice) error {
+			service := NewTwoFactorService(f.users, f.devices, f.methods, f.publisher, nil)
+			return service.RevokeTrustedDevice(context.Background(), device.UserID, device.ID)
+		}},
+		{"expired", func(f *authFixture, device *domain.TrustedDevice) error {
+			device.ExpiresAt = time.Now().Add(-time.Second)
+			return f.devices.Update(device)
+		}},
+		{"all revoked", func(f *authFixture, device *domain.TrustedDevice) error {
+			return f.devices.RevokeAllByUserID(device.UserID)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			enableTOTP(t, user)
+			f := newAuthFixture(t, AuthConfig{}, user)
+
+			device := domain.NewTrustedDevice(user.ID, "device-token", "laptop", time.Now().Add(time.Hour))
+			if err := f.devices.Create(device); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if result := f.login(t, user.Email, "device-token"); result.TwoFactorRequired {
+				t.Fatal("login from the trusted device was challenged")
+			}
+
+			if err := tt.untrust(f, device); err != nil {
+				t.Fatalf("untrust device: %v", err)
+			}
+			if result := f.login(t, user.Email, "device-token"); !result.TwoFactorRequired {
+				t.Error("login from an untrusted device skipped the second factor")
+			}
+		})
+	}
+}
+
+func TestLoginNarrowsSessionScope(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	secret := enableTOTP(t, user)
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+	readOnly := domain.UserLogin{Email: user.Email, Password: "password-123", SessionScopeRequest: domain.SessionScopeRequest{SessionScope: "read"}}
+
+	// the narrowing is carried over the second factor challenge
+	challenged, err := f.service.Login(ctx, readOnly, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	result, err := f.service.VerifyTwoFactor(ctx, domain.TwoFactorLogin{ChallengeToken: challenged.ChallengeToken, Code: currentTOTP(t, secret)}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("VerifyTwoFactor: %v", err)
+	}
+
+	session, _ := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+	if session.Scopes.String() != "read" {
+		t.Errorf("session scopes = %q, want read", session.Scopes)
+	}
+	claims, err := ParseAccessToken(result.AccessToken, f.service.keys)
+	if err != nil || claims.Scope != "read" {
+		t.Fatalf("access token scope = %v, %v; want read", claims, err)
+	}
+
+	// a refresh cannot widen the session
+	refreshed, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if claims, err := ParseAccessToken(refreshed.AccessToken, f.service.keys); err != nil || claims.Scope != "read" {
+		t.Errorf("refreshed token scope = %v, %v; want read", claims, err)
+	}
+}
+
+func TestLoginCannotExceedRoleScopes(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+
+	_, err := f.service.Login(context.Background(), domain.UserLogin{
+		Email:               user.Email,
+		Password:            "password-123",
+		SessionScopeRequest: domain.SessionScopeRequest{SessionScope: "read write admin"},
+	}, "192.0.2.1", "test-agent")
+	var validation domain.ValidationError
+	if !errors.As(err, &validation) {
+		t.Errorf("student login asking for admin = %v, want a ValidationError", err)
+	}
+	if sessions, _ := f.sessions.GetByUserID(context.Background(), user.ID); len(sessions) != 0 {
+		t.Error("a session was opened beyond the account's permissions")
+	}
+}
+
+// Signed access tokens carry the user's role for RequireRole
+func TestAccessTokenCarriesRole(t *testing.T) {
+	student := newTestUser(t, "ada@example.edu", "password-123")
+	admin := newTestUser(t, "grace@example.edu", "password-123")
+	admin.Role = domain.RoleAdmin
+	f := newAuthFixture(t, AuthConfig{}, student, admin)
+
+	for _, user := range []*domain.User{student, admin} {
+		result := f.login(t, user.Email, "")
+		claims, err := ParseAccessToken(result.AccessToken, f.service.keys)
+		if err != nil || claims.Role != string(user.Role) {
+			t.Errorf("access token of %s = %+v, %v; want role %s", user.Email, claims, err, user.Role)
+		}
+		refreshed, err := f.service.RefreshToken(context.Background(), result.RefreshToken)
+		if err != nil {
+			t.Fatalf("RefreshToken: %v", err)
+		}
+		if claims, err := ParseAccessToken(refreshed.AccessToken, f.service.keys); err != nil || claims.Role != string(user.Role) {
+			t.Errorf("refreshed token of %s = %+v, %v; want role %s", user.Email, claims, err, user.Role)
+		}
+	}
+}
+
+// posture returns the posture of the session a login opened
+func (f *authFixture) posture(t *testing.T, result *LoginResult) *domain.SessionPosture {
+	t.Helper()
+	ctx := context.Background()
+	session, err := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("session of the login: %v", err)
+	}
+	posture, err := f.service.SessionPosture(ctx, session.UserID, session.ID)
+	if err != nil {
+		t.Fatalf("SessionPosture: %v", err)
+	}
+	return posture
+}
+
+func TestSessionPosture(t *testing.T) {
+	tests := []struct {
+		name       string
+		twoFactor  bool
+		login      func(t *testing.T, f *authFixture, user *domain.User, secret string) *LoginResult
+		want       domain.SessionAuth
+		mfaEnabled bool
+	}{
+		{"password without 2FA", false, func(t *testing.T, f *authFixture, user *domain.User, _ string) *LoginResult {
+			return f.login(t, user.Email, "")
+		}, domain.SessionAuth{Method: domain.AuthPassword}, false},
+		{"password and second factor", true, func(t *testing.T, f *authFixture, user *domain.User, secret string) *LoginResult {
+			challenged := f.login(t, user.Email, "")
+			verified, err := f.service.VerifyTwoFactor(context.Background(), domain.TwoFactorLogin{
+				ChallengeToken: challenged.ChallengeToken,
+				Code:           currentTOTP(t, secret),
+			}, "192.0.2.1", "test-agent")
+			if err != nil {
+				t.Fatalf("VerifyTwoFactor: %v", err)
+			}
+			return verified
+		}, domain.SessionAuth{Method: domain.AuthPassword, MFAUsed: true}, true},
+		{"password from a trusted device", true, func(t *testing.T, f *authFixture, user *domain.User, _ string) *LoginResult {
+			device := domain.NewTrustedDevice(user.ID, "device-token", "laptop", time.Now().Add(time.Hour))
+			if err := f.devices.Create(device); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			return f.login(t, user.Email, "device-token")
+		}, domain.SessionAuth{Method: domain.AuthPassword, TrustedDevice: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			var secret string
+			if tt.twoFactor {
+				secret = enableTOTP(t, user)
+			}
+			f := newAuthFixture(t, AuthConfig{}, user)
+
+			posture := f.posture(t, tt.login(t, f, user, secret))
+			if posture.SessionAuth != tt.want {
+				t.Errorf("auth = %+v, want %+v", posture.SessionAuth, tt.want)
+			}
+			if posture.TwoFactorEnabled != tt.mfaEnabled {
+				t.Errorf("two factor enabled = %v, want %v", posture.TwoFactorEnabled, tt.mfaEnabled)
+			}
+			if posture.AgeSeconds < 0 || posture.AgeSeconds > 5 {
+				t.Errorf("age = %ds, want a fresh session", posture.AgeSeconds)
+			}
+			if posture.Scopes.String() != domain.RoleScopes(domain.RoleStudent).String() {
+				t.Errorf("scopes = %v, want every scope of a student", posture.Scopes)
+			}
+		})
+	}
+}
+
+func TestSessionPostureReportsAge(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+	session := &domain.Session{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		ExpiresAt:   farFuture(),
+		CreatedAt:   time.Now().Add(-90 * time.Minute),
+		RiskScore:   40,
+		SessionAuth: domain.SessionAuth{Method: domain.AuthOAuth},
+	}
+	f.sessions.Create(ctx, session)
+
+	posture, err := f.service.SessionPosture(ctx, user.ID, session.ID)
+	if err != nil {
+		t.Fatalf("SessionPosture: %v", err)
+	}
+	if posture.AgeSeconds < 90*60 || posture.AgeSeconds > 90*60+5 {
+		t.Errorf("age = %ds, want 90 minutes", posture.AgeSeconds)
+	}
+	if posture.RiskScore != 40 || posture.Method != domain.AuthOAuth {
+		t.Errorf("risk %d, method %s; want the session's", posture.RiskScore, posture.Method)
+	}
+}
+
+func TestSessionPostureRefusesOtherSessions(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+	result := f.login(t, user.Email, "")
+	session, _ := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+
+	if _, err := f.service.SessionPosture(ctx, uuid.New(), session.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("posture of another user's session = %v, want ErrInvalidToken", err)
+	}
+	if _, err := f.service.SessionPosture(ctx, user.ID, uuid.New()); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("posture of an unknown session = %v, want ErrInvalidToken", err)
+	}
+	session.Revoke()
+	f.sessions.Update(ctx, session)
+	if _, err := f.service.SessionPosture(ctx, user.ID, session.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("posture of a revoked session = %v, want ErrInvalidToken", err)
+	}
+}
+
+// Login refuses accounts by their computed status, so the error matches the
+// state clients are shown
+func TestCheckLoginAllowedFollowsStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	adminID := uuid.New()
+	targetID := uuid.New()
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		setup  func(*domain.User)
+		want   error
+	}{
+		{"active", 0, func(u *domain.User) {}, nil},
+		{"merged", 0, func(u *domain.User) { u.Deactivate(); u.MergedInto = &targetID }, ErrAccountMerged},
+		{"banned and suspended", 0, func(u *domain.User) { u.BannedAt = &past; u.SuspendedUntil = &future }, ErrAccountBanned},
+		{"suspended and disabled", 0, func(u *domain.User) { u.Disable(adminID); u.SuspendedUntil = &future }, ErrAccountSuspended},
+		{"disabled", 0, func(u *domain.User) { u.Disable(adminID) }, ErrAccountDisabled},
+		{"deactivated", 24 * time.Hour, func(u *domain.User) { u.Deactivate() }, ErrAccountDeactivated},
+		{"deactivated too long ago", time.Minute, func(u *domain.User) { u.Deactivate(); u.DeactivatedAt = &past }, ErrReactivationClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			tt.setup(user)
+			service := &AuthService{config: AuthConfig{ReactivationWindow: tt.window}}
+			if err := service.checkLoginAllowed(user); !errors.Is(err, tt.want) {
+				t.Errorf("checkLoginAllowed = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{Lockout: ratelimit.NewLockout(ratelimit.LockoutPolicy{
+		Scope:       ratelimit.ScopeAccountIP,
+		MaxFailures: 3,
+		Window:      time.Minute,
+		Duration:    time.Hour,
+	})}, user)
+	ctx := context.Background()
+	attempt := func(email, password, ip string) error {
+		_, err := f.service.Login(ctx, domain.UserLogin{Email: email, Password: password}, ip, "test-agent")
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := attempt(user.Email, "wrong-password", "203.0.113.7"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed attempt %d = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	if err := attempt(user.Email, "password-123", "203.0.113.7"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("right password while locked out = %v, want ErrTooManyLoginAttempts", err)
+	}
+	if err := attempt(user.Email, "password-123", "198.51.100.1"); err != nil {
+		t.Errorf("owner from another address: %v", err)
+	}
+
+	// unknown accounts lock out the same way, so lockouts do not reveal which exist
+	for i := 0; i < 3; i++ {
+		attempt("nobody@example.edu", "wrong-password", "203.0.113.7")
+	}
+	if err := attempt("nobody@example.edu", "wrong-password", "203.0.113.7"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("unknown account after 3 failures = %v, want ErrTooManyLoginAttempts", err)
+	}
+}
+
+// loginRemembered logs in asking for a long-lived session and returns it
+func (f *authFixture) loginRemembered(t *testing.T, email string) (*LoginResult, *domain.Session) {
+	t.Helper()
+	result, err := f.service.Login(context.Background(), domain.UserLogin{Email: email, Password: "password-123", RememberMe: true}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	session, err := f.sessions.GetByRefreshToken(context.Background(), result.RefreshToken)
+	if err != nil {
+		t.Fatalf("session of the login: %v", err)
+	}
+	return result, session
+}
+
+// Remember me picks the session lifetime, not the access token's, and
+// refreshing keeps the choice
+func TestRememberMeSessionLifetime(t *testing.T) {
+	tests := []struct {
+		name       string
+		rememberMe bool
+		totp       bool
+		lifetime   time.Duration
+	}{
+		{"remembered", true, false, 30 * 24 * time.Hour},
+		{"not remembered", false, false, 24 * time.Hour},
+		{"remembered through 2FA", true, true, 30 * 24 * time.Hour},
+		{"not remembered through 2FA", false, true, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			var secret string
+			if tt.totp {
+				secret = enableTOTP(t, user)
+			}
+			f := newAuthFixture(t, AuthConfig{}, user)
+			ctx := context.Background()
+			before := time.Now()
+
+			result, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "password-123", RememberMe: tt.rememberMe}, "192.0.2.1", "test-agent")
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			if tt.totp {
+				result, err = f.service.VerifyTwoFactor(ctx, domain.TwoFactorLogin{ChallengeToken: result.ChallengeToken, Code: currentTOTP(t, secret)}, "192.0.2.1", "test-agent")
+				if err != nil {
+					t.Fatalf("VerifyTwoFactor: %v", err)
+				}
+			}
+			session, err := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+			if err != nil {
+				t.Fatalf("session of the login: %v", err)
+			}
+
+			if session.RememberMe != tt.rememberMe {
+				t.Errorf("session remembered %v, want %v", session.RememberMe, tt.rememberMe)
+			}
+			if lifetime := session.ExpiresAt.Sub(before); lifetime < tt.lifetime || lifetime > tt.lifetime+time.Minute {
+				t.Errorf("session lives %v, want %v", lifetime, tt.lifetime)
+			}
+			if !result.SessionExpiresAt.Equal(session.ExpiresAt) {
+				t.Errorf("token pair reports the session ending at %v, want %v", result.SessionExpiresAt, session.ExpiresAt)
+			}
+			if ttl := result.ExpiresAt.Sub(before); ttl < 15*time.Minute || ttl > 16*time.Minute {
+				t.Errorf("access token lives %v, want the access token TTL", ttl)
+			}
+
+			refreshed, err := f.service.RefreshToken(ctx, result.RefreshToken)
+			if err != nil {
+				t.Fatalf("RefreshToken: %v", err)
+			}
+			stored, _ := f.sessions.GetByID(ctx, session.ID)
+			if stored.RememberMe != tt.rememberMe || !stored.ExpiresAt.Equal(session.ExpiresAt) || !refreshed.SessionExpiresAt.Equal(session.ExpiresAt) {
+				t.Errorf("refreshed session remembered %v until %v, reported %v; want %v until %v",
+					stored.RememberMe, stored.ExpiresAt, refreshed.SessionExpiresAt, tt.rememberMe, session.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestRememberedSessionRespectsMaxAge(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{SessionMaxAge: 2 * time.Hour}, user)
+
+	_, session := f.loginRemembered(t, user.Email)
+	if lifetime := session.ExpiresAt.Sub(session.CreatedAt); lifetime > 2*time.Hour+time.Second {
+		t.Errorf("remembered session lives %v, beyond the maximum age", lifetime)
+	}
+}
+
+// Refreshing keeps a session alive only up to the maximum age counted from
+// its creation, even when its expiry is further out
+func TestRefreshStopsAtSessionMaxAge(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{SessionMaxAge: 2 * time.Hour}, user)
+	ctx := context.Background()
+
+	result, session := f.loginRemembered(t, user.Email)
+	tokens, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh of a young session: %v", err)
+	}
+
+	// as if created two hours and a minute ago, under a longer refresh TTL
+	session, _ = f.sessions.GetByID(ctx, session.ID)
+	session.CreatedAt = time.Now().Add(-2*time.Hour - time.Minute)
+	session.ExpiresAt = farFuture()
+	if err := f.sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if introspection, err := f.service.IntrospectRefreshToken(ctx, tokens.RefreshToken); err != nil || introspection.Active {
+		t.Errorf("introspection of an aged session = %+v, %v, want inactive", introspection, err)
+	}
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrSessionMaxAge) {
+		t.Fatalf("refresh of an aged session = %v, want ErrSessionMaxAge", err)
+	}
+	if stored, _ := f.sessions.GetByID(ctx, session.ID); !stored.IsRevoked {
+		t.Error("aged session not revoked")
+	}
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh after the revocation = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshWithoutSessionMaxAge(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+
+	result, session := f.loginRemembered(t, user.Email)
+	session.CreatedAt = time.Now().Add(-365 * 24 * time.Hour)
+	if err := f.sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); err != nil {
+		t.Errorf("refresh without a maximum age: %v", err)
+	}
+}
+
+// A client racing another over the same refresh token within the grace
+// window gets the tokens of the rotation it lost, without a new rotation
+func TestRotatedRefreshTokenWithinGraceWindow(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{RefreshGraceWindow: 10 * time.Second}, user)
+	ctx := context.Background()
+
+	result, session := f.loginRemembered(t, user.Email)
+	first, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	second, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("racing refresh within the grace window: %v", err)
+	}
+	if second.RefreshToken != first.RefreshToken {
+		t.Error("racing refresh rotated the refresh token again")
+	}
+	if second.AccessToken == "" {
+		t.Error("racing refresh returned no access token")
+	}
+
+	stored, _ := f.sessions.GetByID(ctx, session.ID)
+	if stored.IsRevoked {
+		t.Fatal("session revoked by a refresh within the grace window")
+	}
+	// both clients carry on with the current token
+	if _, err := f.service.RefreshToken(ctx, first.RefreshToken); err != nil {
+		t.Errorf("refresh with the current token: %v", err)
+	}
+}
+
+// Reusing a rotated refresh token after the grace window is taken as theft
+func TestRotatedRefreshTokenAfterGraceWindow(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{RefreshGraceWindow: 10 * time.Second}, user)
+	ctx := context.Background()
+
+	result, session := f.loginRemembered(t, user.Email)
+	tokens, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	// as if rotated a minute ago
+	session, _ = f.sessions.GetByID(ctx, session.ID)
+	rotatedAt := time.Now().Add(-time.Minute)
+	session.RotatedAt = &rotatedAt
+	if err := f.sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reuse after the grace window = %v, want ErrInvalidToken", err)
+	}
+	if stored, _ := f.sessions.GetByID(ctx, session.ID); !stored.IsRevoked {
+		t.Error("session not revoked after refresh token reuse")
+	}
+	// the thief's copy and the legitimate client's are both dead now
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh with the current token after reuse = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRotatedRefreshTokenWithoutGraceWindow(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+
+	result, session := f.loginRemembered(t, user.Email)
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reuse without a grace window = %v, want ErrInvalidToken", err)
+	}
+	if stored, _ := f.sessions.GetByID(ctx, session.ID); !stored.IsRevoked {
+		t.Error("session not revoked after refresh token reuse")
+	}
+}
+
+// Only the token replaced by the last rotation is honoured in the window
+func TestOlderRefreshTokenWithinGraceWindow(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{RefreshGraceWindow: 10 * time.Second}, user)
+	ctx := context.Background()
+
+	result, _ := f.loginRemembered(t, user.Email)
+	first, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if _, err := f.service.RefreshToken(ctx, first.RefreshToken); err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh with a token two rotations old = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestSessionStatusReportsScheduledRevocation(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	other := uuid.New()
+	adminID := uuid.New()
+	ctx := context.Background()
+	inAnHour := time.Now().Add(time.Hour).Truncate(time.Second)
+	inTwoHours := inAnHour.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		cutoffs func(userID uuid.UUID, created time.Time) []*domain.RevocationCutoff
+		want    *time.Time
+	}{
+		{"none", func(uuid.UUID, time.Time) []*domain.RevocationCutoff { return nil }, nil},
+		{"global", func(uuid.UUID, time.Time) []*domain.RevocationCutoff {
+			return []*domain.RevocationCutoff{domain.NewRevocationCutoff(nil, inTwoHours, adminID)}
+		}, &inTwoHours},
+		{"per-user before global", func(userID uuid.UUID, _ time.Time) []*domain.RevocationCutoff {
+			return []*domain.RevocationCutoff{
+				domain.NewRevocationCutoff(nil, inTwoHours, adminID),
+				domain.NewRevocationCutoff(&userID, inAnHour, adminID),
+			}
+		}, &inAnHour},
+		{"another user's", func(uuid.UUID, time.Time) []*domain.RevocationCutoff {
+			return []*domain.RevocationCutoff{domain.NewRevocationCutoff(&other, inAnHour, adminID)}
+		}, nil},
+		{"before the session began", func(_ uuid.UUID, created time.Time) []*domain.RevocationCutoff {
+			return []*domain.RevocationCutoff{domain.NewRevocationCutoff(nil, created.Add(-time.Minute), adminID)}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t, AuthConfig{}, user)
+			_, session := f.loginRemembered(t, user.Email)
+			f.cutoffs.cutoffs = tt.cutoffs(user.ID, session.CreatedAt)
+
+			status, err := f.service.SessionStatus(ctx, user.ID, session.ID)
+			if err != nil {
+				t.Fatalf("SessionStatus: %v", err)
+			}
+			got := status.RevocationScheduledAt
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("revocation scheduled at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionStatusOfEndedSession(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+	_, session := f.loginRemembered(t, user.Email)
+
+	if _, err := f.service.SessionStatus(ctx, uuid.New(), session.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("status of another user's session = %v, want ErrInvalidToken", err)
+	}
+
+	// the cutoff has passed: the session is over, not about to be
+	session.CreatedAt = time.Now().Add(-time.Hour)
+	if err := f.sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	f.cutoffs.cutoffs = []*domain.RevocationCutoff{domain.NewRevocationCutoff(nil, time.Now().Add(-time.Minute), uuid.New())}
+	if _, err := f.service.SessionStatus(ctx, user.ID, session.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("status past the cutoff = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	v1 := domain.Peppers{Current: 1, Secrets: map[int][]byte{1: []byte("first-secret")}}
+	v2 := domain.Peppers{Current: 2, Secrets: map[int][]byte{1: []byte("first-secret"), 2: []byte("second-secret")}}
+	ctx := context.Background()
+
+	for _, peppers := range []domain.Peppers{v1, v2} {
+		f := newAuthFixture(t, AuthConfig{Peppers: peppers}, user)
+
+		// a failed login leaves the hash alone
+		if _, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "wrong-password"}, "192.0.2.1", "test-agent"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("wrong password = %v, want ErrInvalidCredentials", err)
+		}
+		if f.users.get(t, user.ID).PepperVersion != user.PepperVersion {
+			t.Fatal("failed login rehashed the password")
+		}
+
+		f.login(t, user.Email, "")
+		user = f.users.get(t, user.ID)
+		if user.PepperVersion != peppers.Current || !user.CheckPassword("password-123", peppers) {
+			t.Fatalf("after login the hash has pepper version %d, want %d", user.PepperVersion, peppers.Current)
+		}
+	}
+
+	// the retired pepper is no longer needed
+	f := newAuthFixture(t, AuthConfig{Peppers: domain.Peppers{Current: 2, Secrets: map[int][]byte{2: []byte("second-secret")}}}, user)
+	f.login(t, user.Email, "")
+}
+
+func TestWithUniqueRefreshToken(t *testing.T) {
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name      string
+		results   []error
+		wantErr   error
+		wantTries int
+	}{
+		{"first token stored", []error{nil}, nil, 1},
+		{"stored after collisions", []error{domain.ErrRefreshTokenTaken, domain.ErrRefreshTokenTaken, nil}, nil, 3},
+		{"gives up after the last attempt", []error{domain.ErrRefreshTokenTaken, domain.ErrRefreshTokenTaken, domain.ErrRefreshTokenTaken}, domain.ErrRefreshTokenTaken, refreshTokenAttempts},
+		{"other failures are not retried", []error{failure}, failure, 1},
+		{"wrapped collisions are retried", []error{fmt.Errorf("failed to create session: %w", domain.ErrRefreshTokenTaken), nil}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			err := withUniqueRefreshToken(func(refreshToken string) error {
+				tokens = append(tokens, refreshToken)
+				return tt.results[len(tokens)-1]
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(tokens) != tt.wantTries {
+				t.Fatalf("tried %d tokens, want %d", len(tokens), tt.wantTries)
+			}
+			seen := make(map[string]bool)
+			for _, token := range tokens {
+				if token == "" || seen[token] {
+					t.Errorf("retry reused token %q of %v", token, tokens)
+				}
+				seen[token] = true
+			}
+		})
+	}
+}
+
+func TestLoginRetriesRefreshTokenCollision(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+
+	f.sessions.collisions = refreshTokenAttempts - 1
+	result := f.login(t, user.Email, "")
+	session, err := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("the refresh token returned is not the one stored: %v", err)
+	}
+	if _, err := f.service.RefreshToken(ctx, result.RefreshToken); err != nil {
+		t.Errorf("refresh after the collisions: %v", err)
+	}
+
+	f.sessions.collisions = refreshTokenAttempts
+	if _, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "password-123"}, "192.0.2.1", "test-agent"); !errors.Is(err, domain.ErrRefreshTokenTaken) {
+		t.Fatalf("login with every token colliding = %v, want ErrRefreshTokenTaken", err)
+	}
+	if sessions, _ := f.sessions.GetByUserID(ctx, user.ID); len(sessions) != 1 || sessions[0].ID != session.ID {
+		t.Errorf("sessions after the failed login = %d, want only the first", len(sessions))
+	}
+}
+
+func TestRefreshRetriesRotationCollision(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	ctx := context.Background()
+	result := f.login(t, user.Email, "")
+
+	f.sessions.collisions = refreshTokenAttempts - 1
+	tokens, err := f.service.RefreshToken(ctx, result.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh after the collisions: %v", err)
+	}
+	if _, err := f.sessions.GetByRefreshToken(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("the rotated token returned is not the one stored: %v", err)
+	}
+
+	f.sessions.collisions = refreshTokenAttempts
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenTaken) {
+		t.Fatalf("refresh with every rotation colliding = %v, want ErrRefreshTokenTaken", err)
+	}
+	// the failed rotation left the presented token current
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); err != nil {
+		t.Errorf("refresh after the failed rotation: %v", err)
+	}
+}
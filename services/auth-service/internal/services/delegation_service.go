@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// DelegationService lets users give another account, such as a guardian or
+// advisor, read access to parts of theirs, and issues the tokens delegates
+// read it with
+type DelegationService struct {
+	delegationRepo domain.DelegationRepository
+	userRepo       domain.UserRepository
+	sessionRepo    domain.SessionRepository
+	auditRepo      domain.AuditRepository
+	tokens         *AccessTokens
+}
+
+// NewDelegationService creates a new DelegationService
+func NewDelegationService(delegationRepo domain.DelegationRepository, userRepo domain.UserRepository, sessionRepo domain.SessionRepository, auditRepo domain.AuditRepository, tokens *AccessTokens) *DelegationService {
+	return &DelegationService{
+		delegationRepo: delegationRepo,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		auditRepo:      auditRepo,
+		tokens:         tokens,
+	}
+}
+
+// Grant delegates the requested scopes of the user's account to the active
+// account registered with the delegate email
+func (s *DelegationService) Grant(ctx context.Context, userID uuid.UUID, req domain.DelegationRequest, ipAddress, userAgent string) (*domain.Delegation, error) {
+	delegate, err := s.userRepo.GetByEmail(ctx, req.DelegateEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidDelegate
+		}
+		return nil, fmt.Errorf("failed to get delegate: %w", err)
+	}
+	if delegate.ID == userID || !delegate.IsActive {
+		return nil, ErrInvalidDelegate
+	}
+
+	delegation := domain.NewDelegation(userID, delegate.ID, req)
+	if err := s.delegationRepo.Create(delegation); err != nil {
+		return nil, fmt.Errorf("failed to create delegation: %w", err)
+	}
+
+	s.audit(userID, domain.AuditDelegationGranted, delegation, ipAddress, userAgent)
+	return delegation, nil
+}
+
+// List returns the delegations the user granted or received, newest first
+func (s *DelegationService) List(ctx context.Context, userID uuid.UUID) ([]*domain.Delegation, error) {
+	delegations, err := s.delegationRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delegations: %w", err)
+	}
+	if delegations == nil {
+		delegations = []*domain.Delegation{}
+	}
+	return delegations, nil
+}
+
+// Revoke ends a delegation the user granted or received. Tokens issued for
+// it stop working at once, as every delegated request checks the delegation.
+func (s *DelegationService) Revoke(ctx context.Context, userID, id uuid.UUID, ipAddress, userAgent string) error {
+	delegation, err := s.delegationRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDelegationNotFound
+		}
+		return fmt.Errorf("failed to get delegation: %w", err)
+	}
+
+	if err := s.delegationRepo.Revoke(id, userID, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDelegationNotFound
+		}
+		return fmt.Errorf("failed to revoke delegation: %w", err)
+	}
+
+	s.audit(userID, domain.AuditDelegationRevoked, delegation, ipAddress, userAgent)
+	return nil
+}
+
+// IssueToken issues the delegate an access token for an active delegation
+// they received, bound to the session they request it from
+func (s *DelegationService) IssueToken(ctx context.Context, delegateID, sessionID, id uuid.UUID) (*domain.DelegatedToken, error) {
+	delegation, err := s.received(delegateID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != delegateID || session.IsRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.tokens.ttl)
+	if delegation.ExpiresAt != nil && delegation.ExpiresAt.Before(expiresAt) {
+		expiresAt = *delegation.ExpiresAt
+	}
+
+	claims := Claims{
+		UserID:    delegateID.String(),
+		SessionID: session.ID.String(),
+		RiskScore: session.RiskScore,
+		Scope:     string(domain.ScopeDelegated),
+		Delegation: &DelegationContext{
+			ID:        delegation.ID.String(),
+			GrantorID: delegation.GrantorID.String(),
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   delegateID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	accessToken, err := s.tokens.issue(session, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.DelegatedToken{
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+		GrantorID:   delegation.GrantorID,
+		Scopes:      delegation.Scopes,
+	}, nil
+}
+
+// Authorize returns the delegation a delegated token was issued for when it
+// is still active and covers scope
+func (s *DelegationService) Authorize(ctx context.Context, delegateID, id uuid.UUID, scope domain.DelegationScope) (*domain.Delegation, error) {
+	delegation, err := s.received(delegateID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !delegation.Allows(scope) {
+		return nil, ErrDelegationScope
+	}
+	return delegation, nil
+}
+
+// Profile returns the part of the grantor's account under scope
+func (s *DelegationService) Profile(ctx context.Context, delegation *domain.Delegation, scope domain.DelegationScope) (*domain.DelegatedProfile, error) {
+	grantor, err := s.userRepo.GetByID(ctx, delegation.GrantorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDelegationNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	profile := domain.View(grantor, scope)
+	return &profile, nil
+}
+
+// received returns an active delegation the delegate received
+func (s *DelegationService) received(delegateID, id uuid.UUID) (*domain.Delegation, error) {
+	delegation, err := s.delegationRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDelegationNotFound
+		}
+		return nil, fmt.Errorf("failed to get delegation: %w", err)
+	}
+	if delegation.DelegateID != delegateID || !delegation.IsActive(time.Now()) {
+		return nil, ErrDelegationNotFound
+	}
+	return delegation, nil
+}
+
+func (s *DelegationService) audit(userID uuid.UUID, action string, delegation *domain.Delegation, ipAddress, userAgent string) {
+	metadata := domain.AuditMetadata{
+		"delegationId": delegation.ID.String(),
+		"grantorId":    delegation.GrantorID.String(),
+		"delegateId":   delegation.DelegateID.String(),
+	}
+	if err := s.auditRepo.Create(domain.NewAuditLog(userID, action, ipAddress, userAgent, metadata)); err != nil {
+		log.Printf("Failed to audit %s of delegation %s: %v", action, delegation.ID, err)
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// delegationFixture is a DelegationService sharing the repositories and
+// tokens of an auth fixture
+type delegationFixture struct {
+	*authFixture
+	delegationService *DelegationService
+	delegations       *memDelegationRepo
+	audit             *memAuditRepo
+}
+
+func newDelegationFixture(t *testing.T, users ...*domain.User) *delegationFixture {
+	t.Helper()
+	f := &delegationFixture{
+		authFixture: newAuthFixture(t, AuthConfig{}, users...),
+		delegations: &memDelegationRepo{},
+		audit:       &memAuditRepo{},
+	}
+	f.delegationService = NewDelegationService(f.delegations, f.users, f.sessions, f.audit, f.service.tokens)
+	return f
+}
+
+// sessionOf logs the user in and returns the ID of the new session
+func (f *delegationFixture) sessionOf(t *testing.T, user *domain.User) uuid.UUID {
+	t.Helper()
+	result := f.login(t, user.Email, "")
+	session, err := f.sessions.GetByRefreshToken(context.Background(), result.RefreshToken)
+	if err != nil {
+		t.Fatalf("session of the login: %v", err)
+	}
+	return session.ID
+}
+
+func TestGrantDelegation(t *testing.T) {
+	student := newTestUser(t, "ada@example.edu", "password-123")
+	advisor := newTestUser(t, "advisor@example.edu", "password-123")
+	inactive := newTestUser(t, "former@example.edu", "password-123")
+	inactive.IsActive = false
+	f := newDelegationFixture(t, student, advisor, inactive)
+	ctx := context.Background()
+
+	for _, email := range []string{"nobody@example.edu", student.Email, inactive.Email} {
+		req := domain.DelegationRequest{DelegateEmail: email, Scopes: []string{"profile"}}
+		if _, err := f.delegationService.Grant(ctx, student.ID, req, "192.0.2.1", "test-agent"); !errors.Is(err, ErrInvalidDelegate) {
+			t.Errorf("delegation to %s = %v, want ErrInvalidDelegate", email, err)
+		}
+	}
+
+	req := domain.DelegationRequest{DelegateEmail: "Advisor@Example.edu", Scopes: []string{"profile", "contact", "profile"}, ExpiresInDays: 7}
+	delegation, err := f.delegationService.Grant(ctx, student.ID, req, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	if delegation.GrantorID != student.ID || delegation.DelegateID != advisor.ID {
+		t.Errorf("delegation from %s to %s, want from the student to the advisor", delegation.GrantorID, delegation.DelegateID)
+	}
+	if len(delegation.Scopes) != 2 || !delegation.Allows(domain.DelegationProfile) || !delegation.Allows(domain.DelegationContact) {
+		t.Errorf("scopes = %v, want profile and contact once each", delegation.Scopes)
+	}
+	if delegation.ExpiresAt == nil || delegation.ExpiresAt.Sub(delegation.CreatedAt) != 7*24*time.Hour {
+		t.Errorf("expires at %v, want 7 days after %v", delegation.ExpiresAt, delegation.CreatedAt)
+	}
+
+	for _, user := range []*domain.User{student, advisor} {
+		listed, err := f.delegationService.List(ctx, user.ID)
+		if err != nil || len(listed) != 1 || listed[0].ID != delegation.ID {
+			t.Errorf("delegations of %s = %v, %v, want the new one", user.Email, listed, err)
+		}
+	}
+	if listed, err := f.delegationService.List(ctx, inactive.ID); err != nil || listed == nil || len(listed) != 0 {
+		t.Errorf("delegations of an uninvolved user = %v, %v, want an empty list", listed, err)
+	}
+
+	granted := f.audit.ofAction(domain.AuditDelegationGranted)
+	if len(granted) != 1 || granted[0].UserID != student.ID || granted[0].Metadata["delegationId"] != delegation.ID.String() {
+		t.Errorf("grant audit = %+v, want one entry of the student naming the delegation", granted)
+	}
+}
+
+func TestDelegatedAccessWithinScope(t *testing.T) {
+	student := newTestUser(t, "ada@example.edu", "password-123")
+	phone := "+15555550100"
+	student.Phone = &phone
+	advisor := newTestUser(t, "advisor@example.edu", "password-123")
+	stranger := newTestUser(t, "alan@example.edu", "password-123")
+	f := newDelegationFixture(t, student, advisor, stranger)
+	ctx := context.Background()
+
+	delegation, err := f.delegationService.Grant(ctx, student.ID, domain.DelegationRequest{DelegateEmail: advisor.Email, Scopes: []string{"profile"}}, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+
+	token, err := f.delegationService.IssueToken(ctx, advisor.ID, f.sessionOf(t, advisor), delegation.ID)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	claims, err := f.service.tokens.Validate(ctx, token.AccessToken)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if claims.UserID != advisor.ID.String() || claims.Scope != string(domain.ScopeDelegated) {
+		t.Errorf("token of %s with scope %q, want the advisor's with the delegated scope", claims.UserID, claims.Scope)
+	}
+	if claims.Delegation == nil || claims.Delegation.ID != delegation.ID.String() || claims.Delegation.GrantorID != student.ID.String() {
+		t.Errorf("delegation context = %+v, want the delegation", claims.Delegation)
+	}
+	if token.GrantorID != student.ID || len(token.Scopes) != 1 {
+		t.Errorf("delegated token = %+v", token)
+	}
+
+	// only the delegate can use the delegation, from a session of their own
+	if _, err := f.delegationService.IssueToken(ctx, stranger.ID, f.sessionOf(t, stranger), delegation.ID); !errors.Is(err, ErrDelegationNotFound) {
+		t.Errorf("token for another user = %v, want ErrDelegationNotFound", err)
+	}
+	if _, err := f.delegationService.IssueToken(ctx, advisor.ID, f.sessionOf(t, student), delegation.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token from the grantor's session = %v, want ErrInvalidToken", err)
+	}
+	if _, err := f.delegationService.Authorize(ctx, student.ID, delegation.ID, domain.DelegationProfile); !errors.Is(err, ErrDelegationNotFound) {
+		t.Errorf("grantor authorized as the delegate: %v", err)
+	}
+
+	authorized, err := f.delegationService.Authorize(ctx, advisor.ID, delegation.ID, domain.DelegationProfile)
+	if err != nil {
+		t.Fatalf("Authorize within scope: %v", err)
+	}
+	profile, err := f.delegationService.Profile(ctx, authorized, domain.DelegationProfile)
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if profile.ID != student.ID || profile.FirstName != student.FirstName || profile.IsVerified == nil || profile.Email != "" || profile.Phone != nil {
+		t.Errorf("profile view = %+v, want the limited profile only", profile)
+	}
+	if _, err := f.delegationService.Authorize(ctx, advisor.ID, delegation.ID, domain.DelegationContact); !errors.Is(err, ErrDelegationScope) {
+		t.Errorf("Authorize beyond scope = %v, want ErrDelegationScope", err)
+	}
+
+	// contact details leave out a phone number the grantor has not verified
+	contact, _ := f.delegationService.Profile(ctx, authorized, domain.DelegationContact)
+	if contact.Email != student.Email || contact.Phone != nil || contact.FirstName != "" {
+		t.Errorf("contact view = %+v, want the email only", contact)
+	}
+}
+
+func TestDelegationExpiry(t *testing.T) {
+	student := newTestUser(t, "ada@example.edu", "password-123")
+	advisor := newTestUser(t, "advisor@example.edu", "password-123")
+	f := newDelegationFixture(t, student, advisor)
+	ctx := context.Background()
+	session := f.sessionOf(t, advisor)
+
+	delegation := domain.NewDelegation(student.ID, advisor.ID, domain.DelegationRequest{Scopes: []string{"profile"}})
+	endsSoon := time.Now().Add(time.Minute)
+	delegation.ExpiresAt = &endsSoon
+	f.delegations.Create(delegation)
+
+	token, err := f.delegationService.IssueToken(ctx, advisor.ID, session, delegation.ID)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if !token.ExpiresAt.Equal(endsSoon) {
+		t.Errorf("token expires at %v, want with the delegation at %v", token.ExpiresAt, endsSoon)
+	}
+
+	ended := time.Now().Add(-time.Minute)
+	delegation.ExpiresAt = &ended
+	if _, err := f.delegationService.IssueToken(ctx, advisor.ID, session, delegation.ID); !errors.Is(err, ErrDelegationNotFound) {
+		t.Errorf("token for an expired delegation = %v, want ErrDelegationNotFound", err)
+	}
+	if _, err := f.delegationService.Authorize(ctx, advisor.ID, delegation.ID, domain.DelegationProfile); !errors.Is(err, ErrDelegationNotFound) {
+		t.Errorf("Authorize of an expired delegation = %v, want ErrDelegationNotFound", err)
+	}
+}
+
+func TestRevokeDelegation(t *testing.T) {
+	for _, revoker := range []string{"grantor", "delegate"} {
+		t.Run(revoker, func(t *testing.T) {
+			student := newTestUser(t, "ada@example.edu", "password-123")
+			advisor := newTestUser(t, "advisor@example.edu", "password-123")
+			stranger := newTestUser(t, "alan@example.edu", "password-123")
+			f := newDelegationFixture(t, student, advisor, stranger)
+			ctx := context.Background()
+			by := map[string]*domain.User{"grantor": student, "delegate": advisor}[revoker]
+
+			delegation, err := f.delegationService.Grant(ctx, student.ID, domain.DelegationRequest{DelegateEmail: advisor.Email, Scopes: []string{"profile"}}, "192.0.2.1", "test-agent")
+			if err != nil {
+				t.Fatalf("Grant: %v", err)
+			}
+			session := f.sessionOf(t, advisor)
+			if _, err := f.delegationService.IssueToken(ctx, advisor.ID, session, delegation.ID); err != nil {
+				t.Fatalf("IssueToken: %v", err)
+			}
+
+			if err := f.delegationService.Revoke(ctx, stranger.ID, delegation.ID, "192.0.2.1", "test-agent"); !errors.Is(err, ErrDelegationNotFound) {
+				t.Errorf("revocation by another user = %v, want ErrDelegationNotFound", err)
+			}
+			if err := f.delegationService.Revoke(ctx, by.ID, delegation.ID, "192.0.2.1", "test-agent"); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+
+			// tokens already issued are refused as the delegation is checked on each request
+			if _, err := f.delegationService.Authorize(ctx, advisor.ID, delegation.ID, domain.DelegationProfile); !errors.Is(err, ErrDelegationNotFound) {
+				t.Errorf("Authorize after revocation = %v, want ErrDelegationNotFound", err)
+			}
+			if _, err := f.delegationService.IssueToken(ctx, advisor.ID, session, delegation.ID); !errors.Is(err, ErrDelegationNotFound) {
+				t.Errorf("IssueToken after revocation = %v, want ErrDelegationNotFound", err)
+			}
+			if err := f.delegationService.Revoke(ctx, by.ID, delegation.ID, "192.0.2.1", "test-agent"); !errors.Is(err, ErrDelegationNotFound) {
+				t.Errorf("second revocation = %v, want ErrDelegationNotFound", err)
+			}
+
+			revoked := f.audit.ofAction(domain.AuditDelegationRevoked)
+			if len(revoked) != 1 || revoked[0].UserID != by.ID {
+				t.Errorf("revocation audit = %+v, want one entry of the %s", revoked, revoker)
+			}
+		})
+	}
+
+	f := newDelegationFixture(t)
+	if err := f.delegationService.Revoke(context.Background(), uuid.New(), uuid.New(), "192.0.2.1", "test-agent"); !errors.Is(err, ErrDelegationNotFound) {
+		t.Errorf("revocation of an unknown delegation = %v, want ErrDelegationNotFound", err)
+	}
+}
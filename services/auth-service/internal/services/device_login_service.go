@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// userCodeAlphabet avoids vowels and look-alike characters so codes are easy
+// to read off a kiosk screen and never spell words
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// DeviceLoginConfig holds the kiosk login settings
+type DeviceLoginConfig struct {
+	// TTL is how long a started login can be approved and completed
+	TTL time.Duration
+	// Interval is the minimum time between two polls of the kiosk
+	Interval time.Duration
+	// VerificationURI is the page where students enter the user code
+	VerificationURI string
+}
+
+// DeviceLoginService lets shared kiosks log students in without a password
+// being typed on them: the kiosk shows a code, the student approves it from
+// their own logged-in device, and the kiosk polls for the tokens
+type DeviceLoginService struct {
+	loginRepo   domain.DeviceLoginRepository
+	userRepo    domain.UserRepository
+	authService *AuthService
+	config      DeviceLoginConfig
+}
+
+// NewDeviceLoginService creates a new DeviceLoginService
+func NewDeviceLoginService(loginRepo domain.DeviceLoginRepository, userRepo domain.UserRepository, authService *AuthService, config DeviceLoginConfig) *DeviceLoginService {
+	return &DeviceLoginService{
+		loginRepo:   loginRepo,
+		userRepo:    userRepo,
+		authService: authService,
+		config:      config,
+	}
+}
+
+// Start begins a login on the kiosk and returns the codes it displays and polls with
+func (s *DeviceLoginService) Start(ctx context.Context, kioskID string) (*domain.DeviceAuthorization, error) {
+	deviceCode, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	login := domain.NewDeviceLogin(kioskID, deviceCode, userCode, s.config.TTL)
+	if err := s.loginRepo.Create(login); err != nil {
+		return nil, err
+	}
+
+	display := domain.FormatUserCode(login.UserCode)
+	return &domain.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         s.config.VerificationURI,
+		VerificationURIComplete: s.config.VerificationURI + "?user_code=" + url.QueryEscape(display),
+		ExpiresIn:               int(s.config.TTL.Seconds()),
+		Interval:                int(s.config.Interval.Seconds()),
+	}, nil
+}
+
+// Approve logs the user in on the kiosk showing the user code
+func (s *DeviceLoginService) Approve(ctx context.Context, userID uuid.UUID, userCode string) error {
+	login, err := s.loginRepo.GetPendingByUserCode(domain.NormalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get device login: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.authService.checkLoginAllowed(user); err != nil {
+		return err
+	}
+
+	login.Approve(user.ID)
+	return s.loginRepo.Update(login)
+}
+
+// Poll returns the tokens of an approved login, once. Until then it reports
+// ErrAuthorizationPending, or ErrSlowDown when the kiosk polls too often.
+func (s *DeviceLoginService) Poll(ctx context.Context, kioskID, deviceCode, ipAddress, userAgent string) (*domain.TokenPair, error) {
+	login, err := s.loginRepo.GetByDeviceCodeHash(domain.HashToken(deviceCode))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get device login: %w", err)
+	}
+	if login.KioskID != kioskID || login.IsConsumed() {
+		return nil, ErrInvalidToken
+	}
+	if login.IsExpired() {
+		return nil, ErrDeviceLoginExpired
+	}
+
+	tooSoon := login.RecordPoll(s.config.Interval)
+	if !login.IsApproved() {
+		if err := s.loginRepo.Update(login); err != nil {
+			return nil, err
+		}
+		if tooSoon {
+			return nil, ErrSlowDown
+		}
+		return nil, ErrAuthorizationPending
+	}
+
+	if err := s.loginRepo.Consume(login.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, *login.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.authService.checkLoginAllowed(user); err != nil {
+		return nil, err
+	}
+	// a kiosk is shared, so its sessions never get the remember me lifetime
+	return s.authService.openSession(ctx, user, domain.SessionAuth{Method: domain.AuthDeviceLogin}, nil, false, nil, kioskDeviceID(login.KioskID), ipAddress, userAgent)
+}
+
+// kioskDeviceID is the device ID of sessions opened on a kiosk, kept apart
+// from the IDs clients report
+func kioskDeviceID(kioskID string) string {
+	return "kiosk:" + kioskID
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate user code: %w", err)
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const testKioskID = "library-kiosk-1"
+
+// deviceLoginFixture is a DeviceLoginService over the repositories of an
+// auth fixture with one student
+type deviceLoginFixture struct {
+	*authFixture
+	service *DeviceLoginService
+	logins  *memDeviceLoginRepo
+	user    *domain.User
+}
+
+func newDeviceLoginFixture(t *testing.T, config DeviceLoginConfig) *deviceLoginFixture {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := &deviceLoginFixture{
+		authFixture: newAuthFixture(t, AuthConfig{}, user),
+		logins:      newMemDeviceLoginRepo(),
+		user:        user,
+	}
+	f.service = NewDeviceLoginService(f.logins, f.users, f.authFixture.service, config)
+	return f
+}
+
+func (f *deviceLoginFixture) start(t *testing.T) *domain.DeviceAuthorization {
+	t.Helper()
+	authorization, err := f.service.Start(context.Background(), testKioskID)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return authorization
+}
+
+func (f *deviceLoginFixture) poll(deviceCode string) (*domain.TokenPair, error) {
+	return f.service.Poll(context.Background(), testKioskID, deviceCode, "192.0.2.1", "kiosk-agent")
+}
+
+func TestDeviceLoginApproveThenPoll(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: time.Minute, VerificationURI: "https://auth.example.edu/device"})
+	ctx := context.Background()
+
+	authorization := f.start(t)
+	if !strings.HasSuffix(authorization.VerificationURIComplete, "?user_code="+authorization.UserCode) {
+		t.Errorf("verification link %s does not carry the user code", authorization.VerificationURIComplete)
+	}
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("Poll before approval = %v, want ErrAuthorizationPending", err)
+	}
+
+	// the student types the code without the dash and in lower case
+	typed := strings.ToLower(strings.ReplaceAll(authorization.UserCode, "-", ""))
+	if err := f.service.Approve(ctx, f.user.ID, typed); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+
+	tokens, err := f.poll(authorization.DeviceCode)
+	if err != nil {
+		t.Fatalf("Poll after approval: %v", err)
+	}
+	claims, err := ParseAccessToken(tokens.AccessToken, f.authFixture.service.keys)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims.UserID != f.user.ID.String() {
+		t.Errorf("tokens issued for %s, want the approving user", claims.UserID)
+	}
+	sessions, _ := f.sessions.GetByUserID(ctx, f.user.ID)
+	if len(sessions) != 1 {
+		t.Fatalf("%d sessions, want one on the kiosk", len(sessions))
+	}
+	if session := sessions[0]; session.DeviceID != kioskDeviceID(testKioskID) || session.Method != domain.AuthDeviceLogin || session.RememberMe {
+		t.Errorf("session on %q by %q, remembered %v; want a device login on the kiosk, not remembered", session.DeviceID, session.Method, session.RememberMe)
+	}
+	posture, err := f.authFixture.service.SessionPosture(ctx, f.user.ID, sessions[0].ID)
+	if err != nil {
+		t.Fatalf("SessionPosture: %v", err)
+	}
+	if posture.SessionAuth != (domain.SessionAuth{Method: domain.AuthDeviceLogin}) {
+		t.Errorf("posture auth = %+v, want a device login without a second factor", posture.SessionAuth)
+	}
+
+	// the tokens are handed out once
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Poll = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestDeviceLoginPollFromOtherKiosk(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: time.Minute})
+	authorization := f.start(t)
+	if err := f.service.Approve(context.Background(), f.user.ID, authorization.UserCode); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+
+	_, err := f.service.Poll(context.Background(), "cafeteria-kiosk", authorization.DeviceCode, "192.0.2.1", "kiosk-agent")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Poll from another kiosk = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestDeviceLoginSlowDown(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: time.Minute, Interval: time.Hour})
+	authorization := f.start(t)
+
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("first Poll = %v, want ErrAuthorizationPending", err)
+	}
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Errorf("Poll within the interval = %v, want ErrSlowDown", err)
+	}
+}
+
+func TestDeviceLoginExpiry(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: 20 * time.Millisecond})
+	authorization := f.start(t)
+	time.Sleep(30 * time.Millisecond)
+
+	if err := f.service.Approve(context.Background(), f.user.ID, authorization.UserCode); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Approve after expiry = %v, want ErrInvalidToken", err)
+	}
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrDeviceLoginExpired) {
+		t.Errorf("Poll after expiry = %v, want ErrDeviceLoginExpired", err)
+	}
+}
+
+func TestDeviceLoginApprovedThenExpired(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: 20 * time.Millisecond})
+	authorization := f.start(t)
+	if err := f.service.Approve(context.Background(), f.user.ID, authorization.UserCode); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrDeviceLoginExpired) {
+		t.Errorf("Poll after expiry = %v, want ErrDeviceLoginExpired", err)
+	}
+}
+
+func TestDeviceLoginApproveRefusesBlockedUser(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: time.Minute})
+	authorization := f.start(t)
+	user := f.users.get(t, f.user.ID)
+	user.IsActive = false
+	f.users.Update(context.Background(), user)
+
+	if err := f.service.Approve(context.Background(), f.user.ID, authorization.UserCode); err == nil {
+		t.Error("Approve succeeded for a disabled user")
+	}
+	if _, err := f.poll(authorization.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Errorf("Poll = %v, want ErrAuthorizationPending", err)
+	}
+}
+
+func TestDeviceLoginUnknownCodes(t *testing.T) {
+	f := newDeviceLoginFixture(t, DeviceLoginConfig{TTL: time.Minute})
+	f.start(t)
+
+	if err := f.service.Approve(context.Background(), f.user.ID, "BCDF-GHJK"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Approve with an unknown code = %v, want ErrInvalidToken", err)
+	}
+	if _, err := f.poll("not-a-device-code"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Poll with an unknown code = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// EmailChangeService changes the login email of users once they confirm
+// control of the new address
+type EmailChangeService struct {
+	userRepo  domain.UserRepository
+	tokenRepo domain.VerificationTokenRepository
+	publisher events.Publisher
+	baseURL   string
+	tokenTTL  time.Duration
+}
+
+// NewEmailChangeService creates a new EmailChangeService. baseURL is the
+// public address of this service, used to build confirmation links, which
+// stay usable for tokenTTL.
+func NewEmailChangeService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, publisher events.Publisher, baseURL string, tokenTTL time.Duration) *EmailChangeService {
+	return &EmailChangeService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		publisher: publisher,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		tokenTTL:  tokenTTL,
+	}
+}
+
+// RequestEmailChange sends a confirmation token to the new address and tells
+// the current address about the pending change. The user keeps logging in
+// with the current email until the change is confirmed.
+func (s *EmailChangeService) RequestEmailChange(ctx context.Context, userID uuid.UUID, email string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	email = strings.TrimSpace(email)
+	if strings.EqualFold(email, user.Email) {
+		return ErrEmailUnchanged
+	}
+	if err := s.checkAvailable(ctx, email); err != nil {
+		return err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	verification := domain.NewVerificationToken(user.ID, domain.PurposeEmailChange, email, token, time.Now().Add(s.tokenTTL))
+	if err := s.tokenRepo.Create(verification); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, emailChangeRequestedEvent(s.baseURL, verification, token)); err != nil {
+		return fmt.Errorf("failed to request email change confirmation: %w", err)
+	}
+	if err := s.publisher.Publish(ctx, emailChangePendingEvent(user, verification)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.EmailChangePending, err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange consumes a confirmation token and makes its address the
+// user's login email. The address is checked again, as another account may
+// have taken it since the change was requested.
+func (s *EmailChangeService) ConfirmEmailChange(ctx context.Context, token, ipAddress, userAgent string) error {
+	verification, err := s.tokenRepo.GetByTokenHash(domain.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if verification.Purpose != domain.PurposeEmailChange || !verification.IsValid() {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, verification.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.checkAvailable(ctx, verification.Target); err != nil {
+		return err
+	}
+
+	previous := user.Email
+	if err := user.ChangeEmail(verification.Target); err != nil {
+		return err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	verification.MarkUsed()
+	if err := s.tokenRepo.Update(verification); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, emailChangedEvent(user.ID, previous, user.Email)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.EmailChanged, err)
+	}
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityEmailChanged, ipAddress, userAgent)
+
+	return nil
+}
+
+// checkAvailable fails with ErrEmailTaken when an account logs in with email
+func (s *EmailChangeService) checkAvailable(ctx context.Context, email string) error {
+	existing, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if existing != nil {
+		return ErrEmailTaken
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// resendVerificationLimit allows each user one verification email per minute
+var resendVerificationLimit = ratelimit.Limit{Requests: 1, Window: time.Minute}
+
+// EmailVerificationService confirms that users control their login email
+type EmailVerificationService struct {
+	userRepo      domain.UserRepository
+	tokenRepo     domain.VerificationTokenRepository
+	publisher     events.Publisher
+	baseURL       string
+	tokenTTL      time.Duration
+	resendLimiter ratelimit.Limiter
+	// deferPII keeps the email and name of unverified accounts out of events
+	deferPII bool
+}
+
+// NewEmailVerificationService creates a new EmailVerificationService. baseURL
+// is the public address of this service, used to build verification links,
+// which stay usable for tokenTTL. With deferPII, registration events leave
+// out the email and name, which are published once the email is verified.
+func NewEmailVerificationService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, publisher events.Publisher, baseURL string, tokenTTL time.Duration, resendLimiter ratelimit.Limiter, deferPII bool) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		publisher:     publisher,
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		tokenTTL:      tokenTTL,
+		resendLimiter: resendLimiter,
+		deferPII:      deferPII,
+	}
+}
+
+// registeredEvent returns the event announcing a new account. Its email and
+// name are left out until verified when PII events are deferred; the
+// verification email itself still goes out, as the user asked for it.
+func (s *EmailVerificationService) registeredEvent(user *domain.User) events.DomainEvent {
+	if s.deferPII && !user.IsVerified {
+		return unverifiedUserRegisteredEvent(user)
+	}
+	return userRegisteredEvent(user)
+}
+
+// SendVerification issues a verification token for the user's email and asks
+// the notification service to send it
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *domain.User) error {
+	verification, token, err := s.issueToken(s.tokenRepo, user)
+	if err != nil {
+		return err
+	}
+	return s.requestVerification(ctx, user, verification, token)
+}
+
+// requestVerification asks the notification service to send an issued
+// verification token
+func (s *EmailVerificationService) requestVerification(ctx context.Context, user *domain.User, verification *domain.VerificationToken, token string) error {
+	if err := s.publisher.Publish(ctx, emailVerificationRequestedEvent(s.baseURL, user, verification, token)); err != nil {
+		return fmt.Errorf("failed to request email verification: %w", err)
+	}
+	return nil
+}
+
+// sendReminder sends the user's reminder-th reminder with a new verification
+// link. It shares the resend throttle, reporting false when a link was sent
+// too recently.
+func (s *EmailVerificationService) sendReminder(ctx context.Context, user *domain.User, reminder int) (bool, error) {
+	if !s.resendLimiter.Allow("verification:"+user.ID.String(), resendVerificationLimit).Allowed {
+		return false, nil
+	}
+
+	verification, token, err := s.issueToken(s.tokenRepo, user)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.publisher.Publish(ctx, verificationReminderEvent(s.baseURL, user, verification, token, reminder)); err != nil {
+		return false, fmt.Errorf("failed to send verification reminder: %w", err)
+	}
+
+	return true, nil
+}
+
+// issueToken stores a new verification token for the user's email in tokens
+func (s *EmailVerificationService) issueToken(tokens domain.VerificationTokenRepository, user *domain.User) (*domain.VerificationToken, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	verification := domain.NewVerificationToken(user.ID, domain.PurposeEmail, user.Email, token, time.Now().Add(s.tokenTTL))
+	if err := tokens.Create(verification); err != nil {
+		return nil, "", err
+	}
+	return verification, token, nil
+}
+
+// ResendVerification sends the user a new verification link, at most once per
+// minute
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	if !s.resendLimiter.Allow("verification:"+userID.String(), resendVerificationLimit).Allowed {
+		return ErrTooManyVerifications
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsVerified {
+		return ErrAlreadyVerified
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+// ConfirmEmail consumes a verification token and marks the user as verified.
+// Tokens issued for an address the user no longer uses are rejected; an
+// expired token gives ErrVerificationExpired so the user can ask for another.
+func (s *EmailVerificationService) ConfirmEmail(ctx context.Context, token string) error {
+	verification, err := s.tokenRepo.GetByTokenHash(domain.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if verification.Purpose != domain.PurposeEmail {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, verification.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !strings.EqualFold(user.Email, verification.Target) {
+		return ErrInvalidToken
+	}
+	if user.IsVerified {
+		return ErrAlreadyVerified
+	}
+	if verification.UsedAt != nil {
+		return ErrInvalidToken
+	}
+	if verification.IsExpired() {
+		return ErrVerificationExpired
+	}
+
+	user.Verify()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	verification.MarkUsed()
+	if err := s.tokenRepo.Update(verification); err != nil {
+		return err
+	}
+
+	// the email is verified either way; consumers catch up on the next update
+	if err := s.publisher.Publish(ctx, userVerifiedEvent(user, s.deferPII)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.UserVerified, err)
+	}
+	return nil
+}
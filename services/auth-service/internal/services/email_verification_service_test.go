@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// payload returns the event's data as it is published
+func payload(t *testing.T, event events.DomainEvent) map[string]interface{} {
+	t.Helper()
+	encoded, err := json.Marshal(event.Data)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		t.Fatalf("%s payload is not an object: %s", event.EventType, encoded)
+	}
+	return data
+}
+
+// onlyOfType returns the payload of the only event of the type
+func onlyOfType(t *testing.T, emitted []events.DomainEvent, eventType string) map[string]interface{} {
+	t.Helper()
+	var found []map[string]interface{}
+	for _, event := range emitted {
+		if event.EventType == eventType {
+			found = append(found, payload(t, event))
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("%d %s events, want 1", len(found), eventType)
+	}
+	return found[0]
+}
+
+func TestRegistrationPIIEmission(t *testing.T) {
+	for _, deferPII := range []bool{false, true} {
+		name := map[bool]string{false: "immediate", true: "deferred"}[deferPII]
+		t.Run(name, func(t *testing.T) {
+			registered := onlyOfType(t, registerEvents(t, deferPII), events.UserRegistered)
+			for _, field := range []string{"email", "firstName", "lastName", "notificationType"} {
+				if _, ok := registered[field]; ok == deferPII {
+					t.Errorf("user.registered has %s: %v, want %v", field, ok, !deferPII)
+				}
+			}
+			if registered["userId"] == nil || registered["role"] != string(domain.RoleStudent) {
+				t.Errorf("user.registered = %v, want the account ID and role", registered)
+			}
+
+			// the verification email is sent to the address either way
+			verification := onlyOfType(t, registerEvents(t, deferPII), events.EmailVerificationRequested)
+			if verification["email"] != "ada@example.edu" {
+				t.Errorf("verification email event = %v, want the address", verification)
+			}
+
+			verified := onlyOfType(t, confirmEmailEvents(t, deferPII), events.UserVerified)
+			if verified["email"] != "ada@example.edu" || verified["firstName"] == "" {
+				t.Errorf("user.verified = %v, want the email and name", verified)
+			}
+			if welcome := verified["notificationType"] == string(events.NotificationWelcome); welcome != deferPII {
+				t.Errorf("user.verified carries the welcome: %v, want %v", welcome, deferPII)
+			}
+		})
+	}
+}
+
+// Accounts of an identity provider that vouches for the email are verified
+// from the start, and have no time zone yet
+func TestVerifiedAccountsRegisterWithPII(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	user.IsVerified = true
+	user.Timezone = nil
+	verifier := &EmailVerificationService{deferPII: true}
+
+	registered := payload(t, verifier.registeredEvent(user))
+	if registered["email"] != user.Email || registered["notificationType"] != string(events.NotificationWelcome) {
+		t.Errorf("user.registered of a verified account = %v, want the email and welcome", registered)
+	}
+}
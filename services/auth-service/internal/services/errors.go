@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+var (
+	ErrInvalidCredentials = domain.ErrInvalidCredentials
+	ErrEmailTaken         = domain.ErrEmailAlreadyExists
+	ErrUserNotFound       = domain.ErrUserNotFound
+	ErrAccountInactive    = errors.New("account is inactive")
+	ErrAccountDeactivated = errors.New("account is deactivated")
+	ErrReactivationClosed = errors.New("account is deactivated and can no longer be reactivated")
+	ErrAccountSuspended   = errors.New("account is suspended")
+	ErrAccountBanned      = errors.New("account is banned")
+	ErrAccountActive      = errors.New("account is already active")
+	ErrAccountDisabled    = errors.New("account has been disabled by an administrator")
+	ErrAlreadyDisabled    = errors.New("account is already disabled")
+	ErrAccountMerged      = errors.New("account has been merged into another account")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrMalformedToken     = errors.New("token is not a well-formed JWT")
+	ErrInvalidSignature   = errors.New("token signature does not verify with any active signing key")
+	ErrTokenNotFound      = errors.New("opaque token is unknown or expired")
+	ErrSessionNotFound    = domain.ErrSessionNotFound
+	ErrSessionMaxAge      = errors.New("session has reached its maximum age, please log in again")
+	ErrSameAsLoginEmail   = errors.New("recovery email must differ from login email")
+	ErrEmailUnchanged     = errors.New("new email must differ from the current email")
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current password")
+
+	ErrAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationExpired = errors.New("verification link has expired")
+
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	ErrInvalidPin            = errors.New("invalid PIN")
+	ErrPinLocked             = errors.New("too many wrong PINs, log in with your password")
+	ErrPinNotSet             = errors.New("no PIN is set for this account")
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled     = errors.New("two-factor authentication is not enabled")
+	ErrTrustedDeviceNotFound = errors.New("trusted device not found")
+	ErrMFAMethodNotFound     = errors.New("MFA method not found")
+	ErrLastMFAMethod         = errors.New("cannot remove the last second factor while MFA is required")
+
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrLastAdmin          = errors.New("cannot demote the last remaining admin")
+	ErrUnknownAction      = errors.New("unknown action")
+	ErrActionNotPermitted = errors.New("action not permitted")
+	ErrNoCampus           = domain.ErrNoCampus
+	ErrOutsideCampus      = domain.ErrOutsideCampus
+
+	ErrPhoneTaken = errors.New("phone number is already verified on another account")
+
+	ErrInvalidMerge  = errors.New("source and target must be two distinct, unmerged accounts")
+	ErrMergeConflict = errors.New("merge has unresolved conflicts")
+
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	ErrInvalidClient       = errors.New("invalid client credentials")
+	ErrUnauthorizedClient  = errors.New("client is not allowed to use this grant type")
+	ErrInvalidRedirectURI  = errors.New("redirect URI is not registered for this client")
+	ErrInvalidScope        = errors.New("requested scope is not allowed for this client")
+
+	ErrAppAuthorizationNotFound = errors.New("app authorization not found")
+
+	ErrUnknownProvider      = errors.New("unknown identity provider")
+	ErrInvalidOAuthState    = errors.New("invalid or expired OAuth state, start the login again")
+	ErrOAuthEmailUnverified = errors.New("identity provider did not verify the account's email")
+	ErrProviderUnavailable  = errors.New("identity provider is unavailable, try again later")
+
+	ErrDelegationNotFound = errors.New("delegation not found")
+	ErrInvalidDelegate    = errors.New("delegate must be another active account")
+	ErrDelegationScope    = errors.New("delegation does not cover this scope")
+
+	ErrAuthorizationPending = errors.New("device login has not been approved yet")
+	ErrSlowDown             = errors.New("polling too frequently, slow down")
+	ErrDeviceLoginExpired   = errors.New("device login has expired, start a new one")
+
+	ErrJobNotFound  = errors.New("job not found")
+	ErrInvalidRange = errors.New("invalid date range: from must precede to and span at most a year")
+
+	ErrTooManyProfileUpdates = errors.New("too many profile updates, try again later")
+	ErrTooManyLoginAttempts  = errors.New("too many failed login attempts, try again later")
+	ErrTooManyVerifications  = errors.New("a verification email was sent recently, try again later")
+	ErrTooManyRegistrations  = errors.New("too many registrations for this campus, try again later")
+
+	ErrEventDeliveryFailed = errors.New("test event was not confirmed by the broker")
+)
+
+// errRefreshTokenReused is ErrInvalidToken for a rotated refresh token used
+// outside the grace window; clients see ErrInvalidToken, metrics tell it apart
+var errRefreshTokenReused error = refreshTokenReusedError{}
+
+type refreshTokenReusedError struct{}
+
+func (refreshTokenReusedError) Error() string { return ErrInvalidToken.Error() }
+
+func (refreshTokenReusedError) Unwrap() error { return ErrInvalidToken }
+
+// AccountDeactivatedError is ErrAccountDeactivated with the end of the
+// account's reactivation window
+type AccountDeactivatedError struct {
+	// ReactivateBefore is nil when reactivation is not time limited
+	ReactivateBefore *time.Time
+}
+
+func (e AccountDeactivatedError) Error() string { return ErrAccountDeactivated.Error() }
+
+func (e AccountDeactivatedError) Unwrap() error { return ErrAccountDeactivated }
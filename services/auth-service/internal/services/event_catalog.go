@@ -0,0 +1,468 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// The builders below are the only place event payloads are assembled: the
+// services publish what they return, and event previews call them with
+// sample data, so a preview always shows the envelope an action emits.
+
+// userRegisteredEvent announces a new account. Accounts created through an
+// identity provider have no time zone yet and are announced without one.
+func userRegisteredEvent(user *domain.User) events.DomainEvent {
+	var timezone string
+	if user.Timezone != nil {
+		timezone = *user.Timezone
+	}
+	return events.NewDomainEvent(events.UserRegistered, events.UserRegisteredData{
+		NotificationType: events.NotificationWelcome,
+		UserID:           user.ID,
+		Email:            user.Email,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		CampusID:         user.CampusID,
+		Role:             string(user.Role),
+		Timezone:         timezone,
+	})
+}
+
+// unverifiedUserRegisteredEvent announces an account whose email is not
+// verified yet, without the email and name; no welcome is sent until
+// userVerifiedEvent
+func unverifiedUserRegisteredEvent(user *domain.User) events.DomainEvent {
+	return events.NewDomainEvent(events.UserRegistered, map[string]interface{}{
+		"userId":   user.ID,
+		"campusId": user.CampusID,
+		"role":     user.Role,
+		"timezone": user.Timezone,
+	})
+}
+
+// userVerifiedEvent carries the welcome notification when registration
+// deferred it
+func userVerifiedEvent(user *domain.User, welcome bool) events.DomainEvent {
+	data := events.UserVerifiedData{
+		UserID:    user.ID,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		CampusID:  user.CampusID,
+		Role:      string(user.Role),
+		Timezone:  user.Timezone,
+	}
+	if welcome {
+		data.NotificationType = events.NotificationWelcome
+	}
+	return events.NewDomainEvent(events.UserVerified, data)
+}
+
+func userUpdatedEvent(user *domain.User) events.DomainEvent {
+	return events.NewDomainEvent(events.UserUpdated, map[string]interface{}{
+		"userId":    user.ID,
+		"firstName": user.FirstName,
+		"lastName":  user.LastName,
+		"campusId":  user.CampusID,
+		"timezone":  user.Timezone,
+	})
+}
+
+// accountEvent builds the events that only name the account, such as
+// user.deactivated
+func accountEvent(eventType string, userID uuid.UUID) events.DomainEvent {
+	return events.NewDomainEvent(eventType, map[string]interface{}{
+		"userId": userID,
+	})
+}
+
+func userLoggedInEvent(user *domain.User, session *domain.Session) events.DomainEvent {
+	return events.NewDomainEvent(events.UserLoggedIn, map[string]interface{}{
+		"userId":    user.ID,
+		"sessionId": session.ID,
+		"campusId":  user.CampusID,
+	}).WithUserID(user.ID)
+}
+
+func sessionEvictedEvent(evicted, replacement *domain.Session, limit int) events.DomainEvent {
+	return events.NewDomainEvent(events.SessionEvicted, map[string]interface{}{
+		"userId":       evicted.UserID,
+		"sessionId":    evicted.ID,
+		"lastUsedAt":   evicted.LastUsedAt,
+		"ipAddress":    evicted.IPAddress,
+		"userAgent":    evicted.UserAgent,
+		"replacedBy":   replacement.ID,
+		"sessionLimit": limit,
+	}).WithUserID(evicted.UserID)
+}
+
+func userRoleChangedEvent(userID uuid.UUID, previous, role domain.Role, changedBy uuid.UUID) events.DomainEvent {
+	return events.NewDomainEvent(events.UserRoleChanged, map[string]interface{}{
+		"userId":       userID,
+		"previousRole": previous,
+		"newRole":      role,
+		"changedBy":    changedBy,
+	}).WithUserID(userID)
+}
+
+func userMergedEvent(sourceID, targetID, mergedBy uuid.UUID) events.DomainEvent {
+	return events.NewDomainEvent(events.UserMerged, map[string]interface{}{
+		"sourceUserId": sourceID,
+		"targetUserId": targetID,
+		"mergedBy":     mergedBy,
+	}).WithUserID(targetID)
+}
+
+func emailVerificationRequestedEvent(baseURL string, user *domain.User, verification *domain.VerificationToken, token string) events.DomainEvent {
+	return events.NewDomainEvent(events.EmailVerificationRequested, events.EmailVerificationData{
+		NotificationType: events.NotificationEmailVerification,
+		UserID:           user.ID,
+		Email:            user.Email,
+		VerificationLink: verificationLink(baseURL, token),
+		Token:            token,
+		ExpiresAt:        verification.ExpiresAt,
+	}).WithUserID(user.ID)
+}
+
+func verificationReminderEvent(baseURL string, user *domain.User, verification *domain.VerificationToken, token string, reminder int) events.DomainEvent {
+	return events.NewDomainEvent(events.EmailVerificationReminder, events.VerificationReminderData{
+		NotificationType: events.NotificationVerificationReminder,
+		UserID:           user.ID,
+		Email:            user.Email,
+		VerificationLink: verificationLink(baseURL, token),
+		Token:            token,
+		ExpiresAt:        verification.ExpiresAt,
+		Reminder:         reminder,
+	}).WithUserID(user.ID)
+}
+
+func emailChangeRequestedEvent(baseURL string, verification *domain.VerificationToken, token string) events.DomainEvent {
+	return events.NewDomainEvent(events.EmailChangeRequested, events.EmailChangeConfirmationData{
+		NotificationType: events.NotificationEmailChangeConfirmation,
+		UserID:           verification.UserID,
+		NewEmail:         verification.Target,
+		ConfirmationLink: baseURL + "/api/v1/auth/confirm-email-change?token=" + url.QueryEscape(token),
+		Token:            token,
+		ExpiresAt:        verification.ExpiresAt,
+	}).WithUserID(verification.UserID)
+}
+
+func emailChangePendingEvent(user *domain.User, verification *domain.VerificationToken) events.DomainEvent {
+	return events.NewDomainEvent(events.EmailChangePending, events.EmailChangeNoticeData{
+		NotificationType: events.NotificationEmailChangePending,
+		UserID:           user.ID,
+		Email:            user.Email,
+		NewEmail:         verification.Target,
+	}).WithUserID(user.ID)
+}
+
+func emailChangedEvent(userID uuid.UUID, previous, email string) events.DomainEvent {
+	return events.NewDomainEvent(events.EmailChanged, events.EmailChangeNoticeData{
+		NotificationType: events.NotificationEmailChanged,
+		UserID:           userID,
+		Email:            previous,
+		NewEmail:         email,
+	}).WithUserID(userID)
+}
+
+// verificationLink is the link confirming the login email with the token
+func verificationLink(baseURL, token string) string {
+	return baseURL + "/api/v1/auth/verify-email?token=" + url.QueryEscape(token)
+}
+
+func recoveryEmailConfirmationRequestedEvent(baseURL string, verification *domain.VerificationToken, token string) events.DomainEvent {
+	return events.NewDomainEvent(events.RecoveryEmailConfirmationRequested, events.RecoveryEmailConfirmationData{
+		NotificationType: events.NotificationRecoveryEmailConfirmation,
+		UserID:           verification.UserID,
+		RecoveryEmail:    verification.Target,
+		ConfirmationLink: baseURL + "/api/v1/auth/confirm-recovery-email?token=" + url.QueryEscape(token),
+		Token:            token,
+		ExpiresAt:        verification.ExpiresAt,
+	})
+}
+
+func recoveryEmailConfirmedEvent(user *domain.User, verification *domain.VerificationToken) events.DomainEvent {
+	return events.NewDomainEvent(events.RecoveryEmailConfirmed, events.RecoveryEmailConfirmedData{
+		NotificationType: events.NotificationRecoveryEmailChanged,
+		UserID:           user.ID,
+		Email:            user.Email,
+		RecoveryEmail:    verification.Target,
+	})
+}
+
+func phoneVerificationRequestedEvent(verification *domain.VerificationToken, code string) events.DomainEvent {
+	return events.NewDomainEvent(events.PhoneVerificationRequested, events.PhoneVerificationData{
+		NotificationType: events.NotificationPhoneVerification,
+		UserID:           verification.UserID,
+		Phone:            verification.Target,
+		Code:             code,
+		ExpiresAt:        verification.ExpiresAt,
+	})
+}
+
+func phoneVerifiedEvent(userID uuid.UUID, phone string) events.DomainEvent {
+	return events.NewDomainEvent(events.PhoneVerified, map[string]interface{}{
+		"userId": userID,
+		"phone":  phone,
+	})
+}
+
+func passwordResetRequestedEvent(user *domain.User, reset *domain.PasswordReset, token string) events.DomainEvent {
+	return events.NewDomainEvent(events.PasswordResetRequested, events.PasswordResetRequestedData{
+		NotificationType: events.NotificationPasswordReset,
+		UserID:           user.ID,
+		Email:            user.Email,
+		Recipients:       user.RecoveryDestinations(),
+		FirstName:        user.FirstName,
+		Token:            token,
+		ExpiresAt:        reset.ExpiresAt,
+	}).WithUserID(user.ID)
+}
+
+// securityChangedEvent alerts the verified addresses of the user, as they
+// are after the change, with where it was made from
+func securityChangedEvent(user *domain.User, change domain.SecurityChange, ipAddress, userAgent string) events.DomainEvent {
+	recipients := user.VerifiedAddresses()
+	if recipients == nil {
+		recipients = []string{}
+	}
+	return events.NewDomainEvent(events.UserSecurityChanged, events.SecurityChangedData{
+		NotificationType: events.NotificationSecurityChanged,
+		UserID:           user.ID,
+		Change:           string(change),
+		Critical:         change.Critical(),
+		Recipients:       recipients,
+		IPAddress:        ipAddress,
+		UserAgent:        userAgent,
+		ChangedAt:        time.Now().UTC(),
+	}).WithUserID(user.ID)
+}
+
+func loginSuspiciousEvent(user *domain.User, session *domain.Session, login *domain.SuspiciousLogin) events.DomainEvent {
+	recipients := user.VerifiedAddresses()
+	if recipients == nil {
+		recipients = []string{}
+	}
+	data := events.SuspiciousLoginData{
+		NotificationType: events.NotificationNewSignIn,
+		UserID:           user.ID,
+		SessionID:        session.ID,
+		Recipients:       recipients,
+		NewDevice:        login.NewDevice,
+		NewLocation:      login.NewLocation,
+		IPAddress:        session.IPAddress,
+		UserAgent:        session.UserAgent,
+		LoggedInAt:       session.CreatedAt.UTC(),
+	}
+	if login.Location != nil {
+		data.Country = login.Location.Country
+		data.Region = login.Location.Region
+		data.City = login.Location.City
+	}
+	return events.NewDomainEvent(events.LoginSuspicious, data).WithUserID(user.ID)
+}
+
+func systemTestEvent(requestedBy uuid.UUID) events.DomainEvent {
+	return events.NewDomainEvent(events.SystemTest, map[string]interface{}{
+		"requestedBy": requestedBy,
+	})
+}
+
+// previewBaseURL stands in for the configured public URL in preview links
+const previewBaseURL = "https://auth.unibazzar.example"
+
+// previewVerificationTokenTTL stands in for the configured email
+// verification token lifetime, at its default
+const previewVerificationTokenTTL = 24 * time.Hour
+
+// EventPreview lists the events an action would emit, with sample payloads
+type EventPreview struct {
+	Action string               `json:"action"`
+	Events []events.DomainEvent `json:"events"`
+}
+
+// eventPreviews builds the events of each action from sample data
+var eventPreviews = map[string]func(s sampleData) []events.DomainEvent{
+	"register": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userRegisteredEvent(s.user), s.verificationRequested()}
+	},
+	"register_deferred": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{unverifiedUserRegisteredEvent(s.user), s.verificationRequested()}
+	},
+	"email_verify": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userVerifiedEvent(s.user, false)}
+	},
+	"email_verify_deferred": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userVerifiedEvent(s.user, true)}
+	},
+	"profile_update": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userUpdatedEvent(s.user)}
+	},
+	"deactivate": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{accountEvent(events.UserDeactivated, s.user.ID)}
+	},
+	"reactivate": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{accountEvent(events.UserReactivated, s.user.ID)}
+	},
+	"delete": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{accountEvent(events.UserDeleted, s.user.ID)}
+	},
+	"restore": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{accountEvent(events.UserRestored, s.user.ID)}
+	},
+	"login": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userLoggedInEvent(s.user, s.session)}
+	},
+	"suspicious_login": func(s sampleData) []events.DomainEvent {
+		login := &domain.SuspiciousLogin{
+			NewDevice:   true,
+			NewLocation: true,
+			Location:    &domain.GeoLocation{Country: "ET", Region: "Addis Ababa", City: "Addis Ababa"},
+		}
+		return []events.DomainEvent{userLoggedInEvent(s.user, s.session), loginSuspiciousEvent(s.user, s.session, login)}
+	},
+	"session_eviction": func(s sampleData) []events.DomainEvent {
+		replacement := domain.NewSession(s.user.ID, "", sampleIPAddress, sampleUserAgent, s.session.ExpiresAt)
+		return []events.DomainEvent{sessionEvictedEvent(s.session, replacement, 10), userLoggedInEvent(s.user, replacement)}
+	},
+	"role_change": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userRoleChangedEvent(s.user.ID, domain.RoleStudent, domain.RoleAdmin, s.admin)}
+	},
+	"merge": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{userMergedEvent(uuid.New(), s.user.ID, s.admin)}
+	},
+	"email_verification": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{s.verificationRequested()}
+	},
+	"verification_reminder": func(s sampleData) []events.DomainEvent {
+		verification := s.token(domain.PurposeEmail, s.user.Email, previewVerificationTokenTTL)
+		return []events.DomainEvent{verificationReminderEvent(previewBaseURL, s.user, verification, sampleToken, 1)}
+	},
+	"email_change_request": func(s sampleData) []events.DomainEvent {
+		verification := s.token(domain.PurposeEmailChange, sampleNewEmail, previewVerificationTokenTTL)
+		return []events.DomainEvent{
+			emailChangeRequestedEvent(previewBaseURL, verification, sampleToken),
+			emailChangePendingEvent(s.user, verification),
+		}
+	},
+	"email_change_confirm": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{
+			emailChangedEvent(s.user.ID, s.user.Email, sampleNewEmail),
+			securityChangedEvent(s.user, domain.SecurityEmailChanged, sampleIPAddress, sampleUserAgent),
+		}
+	},
+	"recovery_email_request": func(s sampleData) []events.DomainEvent {
+		verification := s.token(domain.PurposeRecoveryEmail, sampleRecoveryEmail, recoveryEmailTokenTTL)
+		return []events.DomainEvent{recoveryEmailConfirmationRequestedEvent(previewBaseURL, verification, sampleToken)}
+	},
+	"recovery_email_confirm": func(s sampleData) []events.DomainEvent {
+		verification := s.token(domain.PurposeRecoveryEmail, sampleRecoveryEmail, recoveryEmailTokenTTL)
+		return []events.DomainEvent{
+			recoveryEmailConfirmedEvent(s.user, verification),
+			securityChangedEvent(s.user, domain.SecurityRecoveryEmailChanged, sampleIPAddress, sampleUserAgent),
+		}
+	},
+	"phone_verification_request": func(s sampleData) []events.DomainEvent {
+		verification := s.token(domain.PurposePhone, samplePhone, phoneCodeTTL)
+		return []events.DomainEvent{phoneVerificationRequestedEvent(verification, sampleCode)}
+	},
+	"phone_verify": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{
+			phoneVerifiedEvent(s.user.ID, samplePhone),
+			securityChangedEvent(s.user, domain.SecurityPhoneChanged, sampleIPAddress, sampleUserAgent),
+		}
+	},
+	"forgot_password": func(s sampleData) []events.DomainEvent {
+		reset := domain.NewPasswordReset(s.user.ID, sampleToken, time.Now().Add(passwordResetTokenTTL))
+		return []events.DomainEvent{passwordResetRequestedEvent(s.user, reset, sampleToken)}
+	},
+	"security_change": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{securityChangedEvent(s.user, domain.SecurityPasswordChanged, sampleIPAddress, sampleUserAgent)}
+	},
+	"event_delivery_test": func(s sampleData) []events.DomainEvent {
+		return []events.DomainEvent{systemTestEvent(s.admin)}
+	},
+}
+
+// Sample values of previews; tokens and codes are placeholders, never issued
+const (
+	sampleToken         = "sample-token"
+	sampleCode          = "123456"
+	sampleRecoveryEmail = "jane.backup@example.com"
+	sampleNewEmail      = "jane.doe@astu.edu.et"
+	samplePhone         = "+251911000000"
+	sampleIPAddress     = "203.0.113.7"
+	sampleUserAgent     = "Mozilla/5.0"
+)
+
+// sampleData is the made-up account previews are built for
+type sampleData struct {
+	user    *domain.User
+	session *domain.Session
+	admin   uuid.UUID
+}
+
+func newSampleData() sampleData {
+	campus := "aau"
+	timezone := "Africa/Addis_Ababa"
+	now := time.Now()
+	user := &domain.User{
+		ID:         uuid.New(),
+		Email:      "jane.doe@aau.edu.et",
+		FirstName:  "Jane",
+		LastName:   "Doe",
+		CampusID:   &campus,
+		Role:       domain.RoleStudent,
+		IsActive:   true,
+		IsVerified: true,
+		Timezone:   &timezone,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	return sampleData{
+		user:    user,
+		session: domain.NewSession(user.ID, "", sampleIPAddress, sampleUserAgent, now.Add(24*time.Hour)),
+		admin:   uuid.New(),
+	}
+}
+
+func (s sampleData) token(purpose domain.TokenPurpose, target string, ttl time.Duration) *domain.VerificationToken {
+	return domain.NewVerificationToken(s.user.ID, purpose, target, sampleToken, time.Now().Add(ttl))
+}
+
+// verificationRequested is the email asking the user to verify their address
+func (s sampleData) verificationRequested() events.DomainEvent {
+	verification := s.token(domain.PurposeEmail, s.user.Email, previewVerificationTokenTTL)
+	return emailVerificationRequestedEvent(previewBaseURL, s.user, verification, sampleToken)
+}
+
+// eventPreviewActions returns the actions events can be previewed for
+func eventPreviewActions() []string {
+	actions := make([]string, 0, len(eventPreviews))
+	for action := range eventPreviews {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// PreviewEvents returns the envelopes action would emit, built from sample
+// data, so consumers can see the event contract. Nothing is performed or
+// published.
+func (s *AdminService) PreviewEvents(action string) (*EventPreview, error) {
+	build, ok := eventPreviews[action]
+	if !ok {
+		return nil, domain.ValidationError{
+			Field:   "action",
+			Message: fmt.Sprintf("unknown action %q, expected one of %s", action, strings.Join(eventPreviewActions(), ", ")),
+		}
+	}
+	return &EventPreview{Action: action, Events: build(newSampleData())}, nil
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// Every notification-triggering event carries a typed payload with its
+// notification type, so the notification service picks templates by type
+func TestNotificationEventPayloads(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	zone := "Europe/London"
+	user.Timezone = &zone
+	expiresAt := time.Now().Add(time.Hour)
+	verification := domain.NewVerificationToken(user.ID, domain.PurposeEmail, "new@example.edu", "secret-token", expiresAt)
+	reset := domain.NewPasswordReset(user.ID, "reset-token", expiresAt)
+	session := &domain.Session{ID: uuid.New(), UserID: user.ID, IPAddress: "192.0.2.1", UserAgent: "test-agent", CreatedAt: time.Now()}
+	const baseURL = "https://auth.example.edu"
+
+	tests := []struct {
+		name         string
+		event        events.DomainEvent
+		eventType    string
+		notification events.NotificationType
+		payload      interface{}
+		// link is a URL the payload must carry, empty when it has none
+		link string
+	}{
+		{"welcome", userRegisteredEvent(user), events.UserRegistered, events.NotificationWelcome, events.UserRegisteredData{}, ""},
+		{"deferred welcome", userVerifiedEvent(user, true), events.UserVerified, events.NotificationWelcome, events.UserVerifiedData{}, ""},
+		{"email verification", emailVerificationRequestedEvent(baseURL, user, verification, "secret-token"), events.EmailVerificationRequested, events.NotificationEmailVerification, events.EmailVerificationData{}, baseURL + "/api/v1/auth/verify-email?token=secret-token"},
+		{"verification reminder", verificationReminderEvent(baseURL, user, verification, "secret-token", 2), events.EmailVerificationReminder, events.NotificationVerificationReminder, events.VerificationReminderData{}, baseURL + "/api/v1/auth/verify-email?token=secret-token"},
+		{"email change confirmation", emailChangeRequestedEvent(baseURL, verification, "secret-token"), events.EmailChangeRequested, events.NotificationEmailChangeConfirmation, events.EmailChangeConfirmationData{}, baseURL + "/api/v1/auth/confirm-email-change?token=secret-token"},
+		{"email change pending", emailChangePendingEvent(user, verification), events.EmailChangePending, events.NotificationEmailChangePending, events.EmailChangeNoticeData{}, ""},
+		{"email changed", emailChangedEvent(user.ID, user.Email, "new@example.edu"), events.EmailChanged, events.NotificationEmailChanged, events.EmailChangeNoticeData{}, ""},
+		{"recovery email confirmation", recoveryEmailConfirmationRequestedEvent(baseURL, verification, "secret-token"), events.RecoveryEmailConfirmationRequested, events.NotificationRecoveryEmailConfirmation, events.RecoveryEmailConfirmationData{}, baseURL + "/api/v1/auth/confirm-recovery-email?token=secret-token"},
+		{"recovery email changed", recoveryEmailConfirmedEvent(user, verification), events.RecoveryEmailConfirmed, events.NotificationRecoveryEmailChanged, events.RecoveryEmailConfirmedData{}, ""},
+		{"phone verification", phoneVerificationRequestedEvent(verification, "123456"), events.PhoneVerificationRequested, events.NotificationPhoneVerification, events.PhoneVerificationData{}, ""},
+		{"password reset", passwordResetRequestedEvent(user, reset, "reset-token"), events.PasswordResetRequested, events.NotificationPasswordReset, events.PasswordResetRequestedData{}, ""},
+		{"security change", securityChangedEvent(user, domain.SecurityPasswordChanged, "192.0.2.1", "test-agent"), events.UserSecurityChanged, events.NotificationSecurityChanged, events.SecurityChangedData{}, ""},
+		{"new sign-in", loginSuspiciousEvent(user, session, &domain.SuspiciousLogin{NewDevice: true}), events.LoginSuspicious, events.NotificationNewSignIn, events.SuspiciousLoginData{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.event.EventType != tt.eventType {
+				t.Errorf("event type = %s, want %s", tt.event.EventType, tt.eventType)
+			}
+			if got, want := reflect.TypeOf(tt.event.Data), reflect.TypeOf(tt.payload); got != want {
+				t.Fatalf("payload is a %v, want %v", got, want)
+			}
+
+			encoded, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var envelope struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(encoded, &envelope); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got := envelope.Data["notificationType"]; got != string(tt.notification) {
+				t.Errorf("notificationType = %v, want %s", got, tt.notification)
+			}
+			if envelope.Data["userId"] != user.ID.String() {
+				t.Errorf("userId = %v, want %s", envelope.Data["userId"], user.ID)
+			}
+			if tt.link != "" && !strings.Contains(string(encoded), `"`+tt.link+`"`) {
+				t.Errorf("payload lacks the link %s: %s", tt.link, encoded)
+			}
+		})
+	}
+}
+
+// Events that notify nobody leave the notification type out
+func TestNonNotifyingEventsHaveNoNotificationType(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	for name, event := range map[string]events.DomainEvent{
+		"verified without welcome": userVerifiedEvent(user, false),
+		"unverified registration":  unverifiedUserRegisteredEvent(user),
+		"phone verified":           phoneVerifiedEvent(user.ID, testPhone),
+	} {
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", name, err)
+		}
+		if strings.Contains(string(encoded), "notificationType") {
+			t.Errorf("%s carries a notification type: %s", name, encoded)
+		}
+	}
+}
+
+// eventShapes describes events by their type and the fields of their
+// payload, which previews must share with the events actually emitted
+func eventShapes(t *testing.T, emitted []events.DomainEvent) []string {
+	t.Helper()
+	var shapes []string
+	for _, event := range emitted {
+		encoded, err := json.Marshal(event.Data)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			t.Fatalf("%s payload is not an object: %s", event.EventType, encoded)
+		}
+		fields := make([]string, 0, len(data))
+		for field := range data {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		shapes = append(shapes, event.EventType+"{"+strings.Join(fields, ",")+"}")
+	}
+	return shapes
+}
+
+// emittedByAction performs each previewable action on a fresh fixture and
+// returns the events it emitted
+var emittedByAction = map[string]func(t *testing.T) []events.DomainEvent{
+	"register":              func(t *testing.T) []events.DomainEvent { return registerEvents(t, false) },
+	"register_deferred":     func(t *testing.T) []events.DomainEvent { return registerEvents(t, true) },
+	"email_verify":          func(t *testing.T) []events.DomainEvent { return confirmEmailEvents(t, false) },
+	"email_verify_deferred": func(t *testing.T) []events.DomainEvent { return confirmEmailEvents(t, true) },
+	"profile_update": func(t *testing.T) []events.DomainEvent {
+		service, user, publisher := userServiceFor(t)
+		zone := "Europe/London"
+		if _, err := service.UpdateProfile(context.Background(), user.ID, domain.UserProfile{FirstName: "Augusta", Timezone: &zone}, "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("UpdateProfile: %v", err)
+		}
+		return publisher.events
+	},
+	"deactivate": func(t *testing.T) []events.DomainEvent {
+		service, user, publisher := userServiceFor(t)
+		if err := service.Deactivate(context.Background(), user.ID); err != nil {
+			t.Fatalf("Deactivate: %v", err)
+		}
+		return publisher.events
+	},
+	"reactivate": func(t *testing.T) []events.DomainEvent {
+		service, user, publisher := userServiceFor(t)
+		if err := service.Deactivate(context.Background(), user.ID); err != nil {
+			t.Fatalf("Deactivate: %v", err)
+		}
+		publisher.events = nil
+		if _, err := service.Reactivate(context.Background(), domain.UserLogin{Email: user.Email, Password: "password-123"}, "192.0.2.1"); err != nil {
+			t.Fatalf("Reactivate: %v", err)
+		}
+		return publisher.events
+	},
+	"delete": func(t *testing.T) []events.DomainEvent {
+		service, user, publisher := userServiceFor(t)
+		if err := service.DeleteUser(context.Background(), user.ID); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		return publisher.events
+	},
+	"restore": func(t *testing.T) []events.DomainEvent {
+		users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent)
+		f := newBulkFixture(users...)
+		f.users.Delete(context.Background(), users[1].ID)
+		if err := f.service.RestoreUser(context.Background(), users[0].ID, users[1].ID, "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("RestoreUser: %v", err)
+		}
+		return f.publisher.events
+	},
+	"login": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		f := newAuthFixture(t, AuthConfig{}, user)
+		f.login(t, user.Email, "")
+		return f.publisher.events
+	},
+	"suspicious_login": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		f := newAuthFixture(t, AuthConfig{}, user)
+		f.service.risk = NewRiskAssessor(nil, nil, geoByAddress{
+			"192.0.2.1":    {Country: "GB", Region: "England", City: "London"},
+			"198.51.100.4": {Country: "ET", Region: "Addis Ababa", City: "Addis Ababa"},
+		})
+		f.login(t, user.Email, "")
+		f.publisher.events = nil
+		login := domain.UserLogin{Email: user.Email, Password: "password-123"}
+		if _, err := f.service.Login(context.Background(), login, "198.51.100.4", "another-agent"); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return f.publisher.events
+	},
+	"session_eviction": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		f := newAuthFixture(t, AuthConfig{MaxSessionsPerUser: 1}, user)
+		f.login(t, user.Email, "")
+		f.publisher.events = nil
+		f.login(t, user.Email, "")
+		return f.publisher.events
+	},
+	"role_change": func(t *testing.T) []events.DomainEvent {
+		users := usersWithRoles("main", domain.RoleAdmin, domain.RoleStudent)
+		tx := newMemTransactor(users...)
+		publisher := &recordingPublisher{}
+		service := &AdminService{
+			userRepo:   tx.users,
+			auditRepo:  &memAuditRepo{},
+			transactor: tx,
+			publisher:  publisher,
+			tokens:     NewAccessTokens(NewHMACKeys("test-secret"), nil, nil, NewMemoryBlacklist(), time.Minute),
+		}
+		if _, err := service.ChangeRole(context.Background(), users[0].ID, users[1].ID, domain.RoleModerator, "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("ChangeRole: %v", err)
+		}
+		return publisher.events
+	},
+	"merge": func(t *testing.T) []events.DomainEvent {
+		f := newMergeFixture()
+		if _, err := f.merge(domain.MergeRequest{}); err != nil {
+			t.Fatalf("MergeAccounts: %v", err)
+		}
+		return f.publisher.events
+	},
+	"email_verification": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		publisher := &recordingPublisher{}
+		verifier := NewEmailVerificationService(newMemUserRepo(user), newMemVerificationTokenRepo(), publisher, "https://auth.example.edu", time.Hour, ratelimit.NewMemoryLimiter(), false)
+		if err := verifier.ResendVerification(context.Background(), user.ID); err != nil {
+			t.Fatalf("ResendVerification: %v", err)
+		}
+		return publisher.events
+	},
+	"verification_reminder": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		publisher := &recordingPublisher{}
+		verifier := NewEmailVerificationService(newMemUserRepo(user), newMemVerificationTokenRepo(), publisher, "https://auth.example.edu", time.Hour, ratelimit.NewMemoryLimiter(), false)
+		if sent, err := verifier.sendReminder(context.Background(), user, 1); !sent || err != nil {
+			t.Fatalf("sendReminder = %v, %v", sent, err)
+		}
+		return publisher.events
+	},
+	"email_change_request": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		publisher := &recordingPublisher{}
+		service := NewEmailChangeService(newMemUserRepo(user), newMemVerificationTokenRepo(), publisher, "https://auth.example.edu", time.Hour)
+		if err := service.RequestEmailChange(context.Background(), user.ID, "ada@new.example.edu"); err != nil {
+			t.Fatalf("RequestEmailChange: %v", err)
+		}
+		return publisher.events
+	},
+	"email_change_confirm": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		tokens := newMemVerificationTokenRepo()
+		tokens.Create(domain.NewVerificationToken(user.ID, domain.PurposeEmailChange, "ada@new.example.edu", "token", farFuture()))
+		publisher := &recordingPublisher{}
+		service := NewEmailChangeService(newMemUserRepo(user), tokens, publisher, "https://auth.example.edu", time.Hour)
+		if err := service.ConfirmEmailChange(context.Background(), "token", "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("ConfirmEmailChange: %v", err)
+		}
+		return publisher.events
+	},
+	"recovery_email_request": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		publisher := &recordingPublisher{}
+		service := NewRecoveryEmailService(newMemUserRepo(user), newMemVerificationTokenRepo(), publisher, "https://auth.example.edu")
+		if err := service.RequestRecoveryEmail(context.Background(), user.ID, "ada@backup.example.org"); err != nil {
+			t.Fatalf("RequestRecoveryEmail: %v", err)
+		}
+		return publisher.events
+	},
+	"recovery_email_confirm": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		tokens := newMemVerificationTokenRepo()
+		tokens.Create(domain.NewVerificationToken(user.ID, domain.PurposeRecoveryEmail, "ada@backup.example.org", "token", farFuture()))
+		publisher := &recordingPublisher{}
+		service := NewRecoveryEmailService(newMemUserRepo(user), tokens, publisher, "https://auth.example.edu")
+		if err := service.ConfirmRecoveryEmail(context.Background(), "token", "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("ConfirmRecoveryEmail: %v", err)
+		}
+		return publisher.events
+	},
+	"phone_verification_request": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		f := newPhoneFixture(false, user)
+		f.requestCode(t, user, testPhone)
+		return f.publisher.events
+	},
+	"phone_verify": func(t *testing.T) []events.DomainEvent {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		f := newPhoneFixture(false, user)
+		code := f.requestCode(t, user, testPhone)
+		f.publisher.events = nil
+		if err := f.confirm(user, code); err != nil {
+			t.Fatalf("ConfirmPhone: %v", err)
+		}
+		return f.publisher.events
+	},
+	"forgot_password": func(t *testing.T) []events.DomainEvent {
+		f := newPasswordResetFixture(t, time.Hour)
+		publisher := &recordingPublisher{}
+		f.service.publisher = publisher
+		f.service.issueReset(context.Background(), f.user.Email)
+		return publisher.events
+	},
+	"security_change": func(t *testing.T) []events.DomainEvent {
+		service, user, publisher := userServiceFor(t)
+		change := domain.PasswordChange{CurrentPassword: "password-123", NewPassword: "password-456"}
+		if err := service.ChangePassword(context.Background(), user.ID, change, "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("ChangePassword: %v", err)
+		}
+		return publisher.events
+	},
+	"event_delivery_test": func(t *testing.T) []events.DomainEvent {
+		admin := usersWithRoles("main", domain.RoleAdmin)[0]
+		f := newBulkFixture(admin)
+		if _, err := f.service.TestEventDelivery(context.Background(), admin.ID, "192.0.2.1", "test-agent"); err != nil {
+			t.Fatalf("TestEventDelivery: %v", err)
+		}
+		return f.publisher.events
+	},
+}
+
+// userServiceFor returns a user service holding a student
+func userServiceFor(t *testing.T) (*UserService, *domain.User, *recordingPublisher) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	publisher := &recordingPublisher{}
+	return &UserService{userRepo: newMemUserRepo(user), publisher: publisher, reactivationWindow: time.Hour}, user, publisher
+}
+
+// registerEvents registers a student, returning the events sent directly
+// and through the outbox
+func registerEvents(t *testing.T, deferPII bool) []events.DomainEvent {
+	tx := newMemTransactor()
+	publisher := &recordingPublisher{}
+	verifier := NewEmailVerificationService(tx.users, tx.tokens, publisher, "https://auth.example.edu", time.Hour, nil, deferPII)
+	service := NewUserService(tx.users, tx, publisher, verifier, domain.PasswordPolicy{}, domain.Peppers{}, domain.TimezoneDefaults{}, 0, nil, ratelimit.Limit{}, nil, &ratelimit.Policy{}, nil, false, nil, nil)
+	if _, err := service.CreateUser(context.Background(), registration("ada@example.edu")); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	var emitted []events.DomainEvent
+	for _, stored := range tx.outbox.events {
+		event, err := decodeOutboxEvent(stored)
+		if err != nil {
+			t.Fatalf("decodeOutboxEvent: %v", err)
+		}
+		emitted = append(emitted, event)
+	}
+	return append(emitted, publisher.events...)
+}
+
+func confirmEmailEvents(t *testing.T, deferPII bool) []events.DomainEvent {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	tokens := newMemVerificationTokenRepo()
+	tokens.Create(domain.NewVerificationToken(user.ID, domain.PurposeEmail, user.Email, "token", farFuture()))
+	publisher := &recordingPublisher{}
+	verifier := NewEmailVerificationService(newMemUserRepo(user), tokens, publisher, "https://auth.example.edu", time.Hour, nil, deferPII)
+	if err := verifier.ConfirmEmail(context.Background(), "token"); err != nil {
+		t.Fatalf("ConfirmEmail: %v", err)
+	}
+	return publisher.events
+}
+
+// Each preview lists the events its action emits, in order and with the
+// same payload fields
+func TestPreviewsMatchEmittedEvents(t *testing.T) {
+	service := &AdminService{}
+	for _, action := range eventPreviewActions() {
+		t.Run(action, func(t *testing.T) {
+			perform, ok := emittedByAction[action]
+			if !ok {
+				t.Fatalf("no test performs %s", action)
+			}
+			preview, err := service.PreviewEvents(action)
+			if err != nil {
+				t.Fatalf("PreviewEvents: %v", err)
+			}
+
+			got, want := eventShapes(t, preview.Events), eventShapes(t, perform(t))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("preview:\n\t%s\nemitted:\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+			}
+		})
+	}
+
+	var validation domain.ValidationError
+	if _, err := service.PreviewEvents("launch_rockets"); !errors.As(err, &validation) {
+		t.Errorf("unknown action = %v, want a ValidationError", err)
+	}
+}
@@ -0,0 +1,1339 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// notFoundError is the missing-row error of the Postgres repositories: the
+// domain error of its kind that still matches sql.ErrNoRows
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string { return e.err.Error() }
+
+func (e notFoundError) Unwrap() error { return e.err }
+
+func (e notFoundError) Is(target error) bool { return target == sql.ErrNoRows }
+
+func notFound(sentinel error) error {
+	return notFoundError{err: sentinel}
+}
+
+// memUserRepo keeps users in memory. Methods the tests do not need are left
+// to the embedded interface and panic when called.
+type memUserRepo struct {
+	domain.UserRepository
+	mu    sync.Mutex
+	users map[uuid.UUID]*domain.User
+	// totpSteps holds the last accepted TOTP step of each user
+	totpSteps map[uuid.UUID]int64
+	// failWrites fails the statement-level writes such as SetRole
+	failWrites error
+	// failUpdates fails Update
+	failUpdates error
+}
+
+func newMemUserRepo(users ...*domain.User) *memUserRepo {
+	r := &memUserRepo{users: make(map[uuid.UUID]*domain.User), totpSteps: make(map[uuid.UUID]int64)}
+	for _, user := range users {
+		stored := *user
+		r.users[user.ID] = &stored
+	}
+	return r
+}
+
+func (r *memUserRepo) Create(_ context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return domain.ErrEmailAlreadyExists
+		}
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *memUserRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, notFound(domain.ErrUserNotFound)
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memUserRepo) GetByEmail(_ context.Context, email string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == domain.NormalizeEmail(email) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, notFound(domain.ErrUserNotFound)
+}
+
+func (r *memUserRepo) GetByPhone(_ context.Context, phone string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.PhoneVerified && user.Phone != nil && *user.Phone == phone {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, notFound(domain.ErrUserNotFound)
+}
+
+func (r *memUserRepo) Update(_ context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failUpdates != nil {
+		return r.failUpdates
+	}
+	if _, ok := r.users[user.ID]; !ok {
+		return notFound(domain.ErrUserNotFound)
+	}
+	// like the partial unique index on verified phones
+	if user.Phone != nil && user.PhoneVerified {
+		for _, other := range r.users {
+			if other.ID != user.ID && other.PhoneVerified && other.Phone != nil && *other.Phone == *user.Phone {
+				return domain.ErrPhoneAlreadyVerified
+			}
+		}
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+// ListFiltered returns a page of the matching users, newest first
+func (r *memUserRepo) ListFiltered(_ context.Context, filter domain.UserFilter) ([]*domain.User, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []*domain.User
+	for _, user := range r.users {
+		switch {
+		case filter.Role != nil && user.Role != *filter.Role,
+			filter.IsActive != nil && user.IsActive != *filter.IsActive,
+			filter.IsVerified != nil && user.IsVerified != *filter.IsVerified,
+			filter.CampusID != nil && (user.CampusID == nil || *user.CampusID != *filter.CampusID):
+			continue
+		}
+		copied := *user
+		matching = append(matching, &copied)
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CreatedAt.After(matching[j].CreatedAt) })
+
+	total := len(matching)
+	if filter.Offset >= total {
+		return nil, total, nil
+	}
+	matching = matching[filter.Offset:]
+	if filter.Limit < len(matching) {
+		matching = matching[:filter.Limit]
+	}
+	return matching, total, nil
+}
+
+// ListUnverified returns the unverified active users after the cursor, in ID
+// order like Postgres orders UUIDs
+func (r *memUserRepo) ListUnverified(_ context.Context, campusID *string, after uuid.UUID, limit int) ([]*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []*domain.User
+	for _, user := range r.users {
+		switch {
+		case user.IsVerified, !user.IsActive, user.BannedAt != nil, user.DeletedAt != nil,
+			campusID != nil && (user.CampusID == nil || *user.CampusID != *campusID),
+			user.ID.String() <= after.String():
+			continue
+		}
+		copied := *user
+		matching = append(matching, &copied)
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID.String() < matching[j].ID.String() })
+	if limit < len(matching) {
+		matching = matching[:limit]
+	}
+	return matching, nil
+}
+
+// Delete soft-deletes the user, like the Postgres repository
+func (r *memUserRepo) Delete(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt != nil {
+		return notFound(domain.ErrUserNotFound)
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	user.IsActive = false
+	return nil
+}
+
+// Restore undoes a soft delete
+func (r *memUserRepo) Restore(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt == nil {
+		return notFound(domain.ErrUserNotFound)
+	}
+	user.DeletedAt = nil
+	user.IsActive = user.DeactivatedAt == nil
+	return nil
+}
+
+// ListByCampus returns the campus's users after the cursor, in ID order
+func (r *memUserRepo) ListByCampus(_ context.Context, campusID string, after uuid.UUID, limit int) ([]*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []*domain.User
+	for _, user := range r.users {
+		if user.CampusID == nil || *user.CampusID != campusID || user.DeletedAt != nil || user.ID.String() <= after.String() {
+			continue
+		}
+		copied := *user
+		matching = append(matching, &copied)
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID.String() < matching[j].ID.String() })
+	if limit < len(matching) {
+		matching = matching[:limit]
+	}
+	return matching, nil
+}
+
+// SignupFunnel counts nothing: the aggregation is Postgres's, tested in repo
+func (r *memUserRepo) SignupFunnel(_ context.Context, _ domain.SignupFunnelQuery) ([]*domain.SignupFunnelRow, error) {
+	return nil, nil
+}
+
+func (r *memUserRepo) CountByRole(_ context.Context, role domain.Role) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, user := range r.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memUserRepo) LockRole(ctx context.Context, role domain.Role) (int, error) {
+	return r.CountByRole(ctx, role)
+}
+
+func (r *memUserRepo) UseTOTPStep(_ context.Context, id uuid.UUID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if last, ok := r.totpSteps[id]; ok && last >= step {
+		return sql.ErrNoRows
+	}
+	r.totpSteps[id] = step
+	return nil
+}
+
+func (r *memUserRepo) SetRole(_ context.Context, ids []uuid.UUID, role domain.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failWrites != nil {
+		return r.failWrites
+	}
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			user.Role = role
+		}
+	}
+	return nil
+}
+
+// get returns the stored user, failing the test when there is none
+func (r *memUserRepo) get(t *testing.T, id uuid.UUID) *domain.User {
+	t.Helper()
+	user, err := r.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("user %s: %v", id, err)
+	}
+	return user
+}
+
+// memSessionRepo keeps sessions in memory, like memUserRepo
+type memSessionRepo struct {
+	domain.SessionRepository
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*domain.Session
+	// collisions is how many more writes are refused as if their refresh
+	// token were held by another session
+	collisions int
+}
+
+func newMemSessionRepo() *memSessionRepo {
+	return &memSessionRepo{sessions: make(map[uuid.UUID]*domain.Session)}
+}
+
+func (r *memSessionRepo) Create(_ context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.collide() {
+		return domain.ErrRefreshTokenTaken
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *memSessionRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, notFound(domain.ErrSessionNotFound)
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *memSessionRepo) GetByRefreshToken(_ context.Context, token string) (*domain.Session, error) {
+	return r.find(func(s *domain.Session) bool { return s.RefreshTokenHash == domain.HashToken(token) })
+}
+
+func (r *memSessionRepo) GetByRefreshTokenHash(_ context.Context, hash string) (*domain.Session, error) {
+	return r.find(func(s *domain.Session) bool { return s.RefreshTokenHash == hash })
+}
+
+func (r *memSessionRepo) GetByPreviousRefreshToken(_ context.Context, token string) (*domain.Session, error) {
+	return r.find(func(s *domain.Session) bool { return s.PreviousRefreshTokenHash == domain.HashToken(token) })
+}
+
+func (r *memSessionRepo) find(match func(*domain.Session) bool) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if match(session) {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, notFound(domain.ErrSessionNotFound)
+}
+
+// GetByUserID returns the user's sessions, newest first
+func (r *memSessionRepo) GetByUserID(_ context.Context, userID uuid.UUID) ([]*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *memSessionRepo) Update(_ context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[session.ID]; !ok {
+		return notFound(domain.ErrSessionNotFound)
+	}
+	if r.collide() {
+		return domain.ErrRefreshTokenTaken
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+// collide reports whether the current write is refused as a collision
+func (r *memSessionRepo) collide() bool {
+	if r.collisions == 0 {
+		return false
+	}
+	r.collisions--
+	return true
+}
+
+func (r *memSessionRepo) CountActiveByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	sessions, _ := r.GetByUserID(ctx, userID)
+	active := 0
+	for _, session := range sessions {
+		if session.IsActive() {
+			active++
+		}
+	}
+	return active, nil
+}
+
+func (r *memSessionRepo) GetOldestActiveByUserID(ctx context.Context, userID uuid.UUID) (*domain.Session, error) {
+	sessions, _ := r.GetByUserID(ctx, userID)
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if sessions[i].IsActive() {
+			return sessions[i], nil
+		}
+	}
+	return nil, notFound(domain.ErrSessionNotFound)
+}
+
+func (r *memSessionRepo) RevokeAllByUserID(_ context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			session.Revoke()
+		}
+	}
+	return nil
+}
+
+// ListActiveByDevice returns the active sessions of any user on the device,
+// newest first
+func (r *memSessionRepo) ListActiveByDevice(_ context.Context, deviceID string) ([]*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.DeviceID == deviceID && session.IsActive() {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *memSessionRepo) RevokeByDevice(_ context.Context, deviceID string) ([]*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revoked []*domain.Session
+	for _, session := range r.sessions {
+		if session.DeviceID == deviceID && !session.IsRevoked {
+			session.Revoke()
+			copied := *session
+			revoked = append(revoked, &copied)
+		}
+	}
+	return revoked, nil
+}
+
+func (r *memSessionRepo) RevokeByClient(_ context.Context, userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ClientID == clientID {
+			session.Revoke()
+		}
+	}
+	return nil
+}
+
+// memDeviceRepo keeps trusted devices in memory
+type memDeviceRepo struct {
+	mu      sync.Mutex
+	devices map[uuid.UUID]*domain.TrustedDevice
+}
+
+func newMemDeviceRepo() *memDeviceRepo {
+	return &memDeviceRepo{devices: make(map[uuid.UUID]*domain.TrustedDevice)}
+}
+
+func (r *memDeviceRepo) Create(device *domain.TrustedDevice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *device
+	r.devices[device.ID] = &stored
+	return nil
+}
+
+func (r *memDeviceRepo) GetByTokenHash(tokenHash string) (*domain.TrustedDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		if device.TokenHash == tokenHash {
+			copied := *device
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memDeviceRepo) GetByID(id uuid.UUID) (*domain.TrustedDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *device
+	return &copied, nil
+}
+
+func (r *memDeviceRepo) ListActiveByUserID(userID uuid.UUID) ([]*domain.TrustedDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var devices []*domain.TrustedDevice
+	for _, device := range r.devices {
+		if device.UserID == userID && device.IsValid() {
+			copied := *device
+			devices = append(devices, &copied)
+		}
+	}
+	return devices, nil
+}
+
+func (r *memDeviceRepo) Update(device *domain.TrustedDevice) error {
+	return r.Create(device)
+}
+
+func (r *memDeviceRepo) RevokeAllByUserID(userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		if device.UserID == userID && device.RevokedAt == nil {
+			device.Revoke()
+		}
+	}
+	return nil
+}
+
+// memCutoffRepo keeps revocation cutoffs in memory
+type memCutoffRepo struct {
+	mu      sync.Mutex
+	cutoffs []*domain.RevocationCutoff
+}
+
+func (r *memCutoffRepo) Create(cutoff *domain.RevocationCutoff) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs = append(r.cutoffs, cutoff)
+	return nil
+}
+
+func (r *memCutoffRepo) ListForUser(userID uuid.UUID) ([]*domain.RevocationCutoff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []*domain.RevocationCutoff
+	for _, cutoff := range r.cutoffs {
+		if cutoff.UserID == nil || *cutoff.UserID == userID {
+			matching = append(matching, cutoff)
+		}
+	}
+	return matching, nil
+}
+
+// memMFAMethodRepo keeps second factor methods in memory, like memUserRepo
+type memMFAMethodRepo struct {
+	domain.MFAMethodRepository
+	mu      sync.Mutex
+	methods []*domain.MFAMethod
+}
+
+func (r *memMFAMethodRepo) Create(method *domain.MFAMethod) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *method
+	r.methods = append(r.methods, &stored)
+	return nil
+}
+
+func (r *memMFAMethodRepo) GetByID(id uuid.UUID) (*domain.MFAMethod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, method := range r.methods {
+		if method.ID == id {
+			copied := *method
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ListByUserID returns the user's methods, preferred first, then oldest first
+func (r *memMFAMethodRepo) ListByUserID(userID uuid.UUID) ([]*domain.MFAMethod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var methods []*domain.MFAMethod
+	for _, method := range r.methods {
+		if method.UserID == userID {
+			copied := *method
+			methods = append(methods, &copied)
+		}
+	}
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i].Preferred && !methods[j].Preferred })
+	return methods, nil
+}
+
+func (r *memMFAMethodRepo) SetPreferred(userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, method := range r.methods {
+		if method.UserID == userID {
+			method.Preferred = method.ID == id
+		}
+	}
+	return nil
+}
+
+func (r *memMFAMethodRepo) Delete(id uuid.UUID) error {
+	return r.deleteWhere(func(m *domain.MFAMethod) bool { return m.ID == id })
+}
+
+func (r *memMFAMethodRepo) DeleteByType(userID uuid.UUID, methodType domain.MFAMethodType) error {
+	return r.deleteWhere(func(m *domain.MFAMethod) bool { return m.UserID == userID && m.Type == methodType })
+}
+
+func (r *memMFAMethodRepo) deleteWhere(match func(*domain.MFAMethod) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.methods[:0]
+	for _, method := range r.methods {
+		if !match(method) {
+			kept = append(kept, method)
+		}
+	}
+	r.methods = kept
+	return nil
+}
+
+// memConsentRepo keeps consent records in memory, in the order recorded
+type memConsentRepo struct {
+	mu       sync.Mutex
+	consents []*domain.Consent
+}
+
+func (r *memConsentRepo) Create(consent *domain.Consent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consents = append(r.consents, consent)
+	return nil
+}
+
+// ListByUserID returns the user's consent history, newest first
+func (r *memConsentRepo) ListByUserID(userID uuid.UUID) ([]*domain.Consent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var history []*domain.Consent
+	for i := len(r.consents) - 1; i >= 0; i-- {
+		if r.consents[i].UserID == userID {
+			history = append(history, r.consents[i])
+		}
+	}
+	return history, nil
+}
+
+func (r *memConsentRepo) GetLatest(userID uuid.UUID, purpose domain.ConsentPurpose) (*domain.Consent, error) {
+	history, _ := r.ListByUserID(userID)
+	for _, consent := range history {
+		if consent.Purpose == purpose {
+			return consent, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// memAuditRepo keeps audit entries in memory, like memUserRepo
+type memAuditRepo struct {
+	domain.AuditRepository
+	mu      sync.Mutex
+	entries []*domain.AuditLog
+}
+
+// Create links the entry after the last one, as the database does
+func (r *memAuditRepo) Create(entry *domain.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var prev *domain.AuditLog
+	if len(r.entries) > 0 {
+		prev = r.entries[len(r.entries)-1]
+	}
+	if err := entry.Link(prev); err != nil {
+		return err
+	}
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *memAuditRepo) GetBySequence(seq int64) (*domain.AuditLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		if entry.Sequence == seq {
+			return entry, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memAuditRepo) ListChain(from, to int64, limit int) ([]*domain.AuditLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []*domain.AuditLog
+	for _, entry := range r.entries {
+		if entry.Sequence >= from && entry.Sequence <= to && len(entries) < limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *memAuditRepo) LastSequence() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var last int64
+	for _, entry := range r.entries {
+		if entry.Sequence > last {
+			last = entry.Sequence
+		}
+	}
+	return last, nil
+}
+
+// ofAction returns the entries recorded for the action
+func (r *memAuditRepo) ofAction(action string) []*domain.AuditLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matching []*domain.AuditLog
+	for _, entry := range r.entries {
+		if entry.Action == action {
+			matching = append(matching, entry)
+		}
+	}
+	return matching
+}
+
+// memVerificationTokenRepo keeps verification tokens in memory by token hash
+type memVerificationTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*domain.VerificationToken
+	// failWrites fails Create and Update
+	failWrites error
+}
+
+func newMemVerificationTokenRepo() *memVerificationTokenRepo {
+	return &memVerificationTokenRepo{tokens: make(map[string]*domain.VerificationToken)}
+}
+
+func (r *memVerificationTokenRepo) Create(token *domain.VerificationToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failWrites != nil {
+		return r.failWrites
+	}
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *memVerificationTokenRepo) GetByTokenHash(tokenHash string) (*domain.VerificationToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *memVerificationTokenRepo) GetLatestByUserID(userID uuid.UUID, purpose domain.TokenPurpose) (*domain.VerificationToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *domain.VerificationToken
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && (latest == nil || token.CreatedAt.After(latest.CreatedAt)) {
+			latest = token
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (r *memVerificationTokenRepo) Update(token *domain.VerificationToken) error {
+	return r.Create(token)
+}
+
+// memOutboxRepo keeps outbox events in memory. Methods the tests do not need
+// are left to the embedded interface and panic when called.
+type memOutboxRepo struct {
+	domain.OutboxRepository
+	mu     sync.Mutex
+	events []*domain.OutboxEvent
+	// failWrites fails Create
+	failWrites error
+}
+
+func (r *memOutboxRepo) Create(event *domain.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failWrites != nil {
+		return r.failWrites
+	}
+	stored := *event
+	r.events = append(r.events, &stored)
+	return nil
+}
+
+// memTransactor hands out the in-memory repositories and emulates a rollback
+// by restoring their contents when the unit of work fails
+type memTransactor struct {
+	users  *memUserRepo
+	tokens *memVerificationTokenRepo
+	audit  *memAuditRepo
+	outbox *memOutboxRepo
+	resets *memResetRepo
+}
+
+func newMemTransactor(users ...*domain.User) *memTransactor {
+	return &memTransactor{users: newMemUserRepo(users...), tokens: newMemVerificationTokenRepo(), audit: &memAuditRepo{}, outbox: &memOutboxRepo{}}
+}
+
+func (t *memTransactor) WithTx(_ context.Context, fn func(repos domain.Repositories) error) error {
+	users := make(map[uuid.UUID]*domain.User, len(t.users.users))
+	for id, user := range t.users.users {
+		stored := *user
+		users[id] = &stored
+	}
+	tokens := make(map[string]*domain.VerificationToken, len(t.tokens.tokens))
+	for hash, token := range t.tokens.tokens {
+		tokens[hash] = token
+	}
+	audit := append([]*domain.AuditLog(nil), t.audit.entries...)
+	outbox := append([]*domain.OutboxEvent(nil), t.outbox.events...)
+	repos := domain.Repositories{Users: t.users, VerificationTokens: t.tokens, Audit: t.audit, Outbox: t.outbox}
+	var resets map[string]*domain.PasswordReset
+	if t.resets != nil {
+		repos.PasswordResets = t.resets
+		resets = t.resets.snapshot()
+	}
+
+	err := fn(repos)
+	if err != nil {
+		t.users.users, t.tokens.tokens, t.audit.entries, t.outbox.events = users, tokens, audit, outbox
+		if t.resets != nil {
+			t.resets.resets = resets
+		}
+	}
+	return err
+}
+
+// memOAuthClientRepo keeps OAuth clients in memory, keyed by ID. Methods the
+// tests do not need are left to the embedded interface and panic when called.
+type memOAuthClientRepo struct {
+	domain.OAuthClientRepository
+	mu      sync.Mutex
+	clients map[uuid.UUID]*domain.OAuthClient
+}
+
+func newMemOAuthClientRepo() *memOAuthClientRepo {
+	return &memOAuthClientRepo{clients: make(map[uuid.UUID]*domain.OAuthClient)}
+}
+
+func (r *memOAuthClientRepo) Create(client *domain.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *client
+	r.clients[client.ID] = &stored
+	return nil
+}
+
+func (r *memOAuthClientRepo) GetByID(id uuid.UUID) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *client
+	return &found, nil
+}
+
+func (r *memOAuthClientRepo) GetByClientID(clientID string) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, client := range r.clients {
+		if client.ClientID == clientID {
+			found := *client
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memOAuthClientRepo) Update(client *domain.OAuthClient) error {
+	return r.Create(client)
+}
+
+func (r *memOAuthClientRepo) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+// memJobRepo keeps re-verification jobs in memory and records every saved
+// state of them
+type memJobRepo struct {
+	mu    sync.Mutex
+	jobs  map[uuid.UUID]*domain.ReverificationJob
+	saves []domain.ReverificationJob
+}
+
+func newMemJobRepo() *memJobRepo {
+	return &memJobRepo{jobs: make(map[uuid.UUID]*domain.ReverificationJob)}
+}
+
+func (r *memJobRepo) Create(job *domain.ReverificationJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *job
+	r.jobs[job.ID] = &stored
+	return nil
+}
+
+func (r *memJobRepo) GetByID(id uuid.UUID) (*domain.ReverificationJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *job
+	return &found, nil
+}
+
+func (r *memJobRepo) GetByIdempotencyKey(key string) (*domain.ReverificationJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.IdempotencyKey == key {
+			found := *job
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memJobRepo) ListRunning() ([]*domain.ReverificationJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var running []*domain.ReverificationJob
+	for _, job := range r.jobs {
+		if job.Status == domain.JobRunning {
+			found := *job
+			running = append(running, &found)
+		}
+	}
+	return running, nil
+}
+
+func (r *memJobRepo) Update(job *domain.ReverificationJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *job
+	r.jobs[job.ID] = &stored
+	r.saves = append(r.saves, stored)
+	return nil
+}
+
+// memGrantRepo keeps app authorizations in memory, in the order granted.
+// Methods the tests do not need are left to the embedded interface and
+// panic when called.
+type memGrantRepo struct {
+	mu     sync.Mutex
+	grants []*domain.AppAuthorization
+	// clients names the clients when listing, when set
+	clients *memOAuthClientRepo
+}
+
+// Grant adds the scopes to an existing authorization of the client, as the
+// repository's upsert does
+func (r *memGrantRepo) Grant(authorization *domain.AppAuthorization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.find(authorization.UserID, authorization.ClientID); existing != nil {
+		for _, scope := range authorization.Scopes {
+			if !slices.Contains(existing.Scopes, scope) {
+				existing.Scopes = append(existing.Scopes, scope)
+			}
+		}
+		existing.LastUsedAt = authorization.LastUsedAt
+		return nil
+	}
+	stored := *authorization
+	r.grants = append(r.grants, &stored)
+	return nil
+}
+
+func (r *memGrantRepo) Touch(userID uuid.UUID, clientID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.find(userID, clientID); existing != nil {
+		existing.LastUsedAt = at
+	}
+	return nil
+}
+
+func (r *memGrantRepo) ListByUserID(userID uuid.UUID) ([]*domain.AppAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var authorizations []*domain.AppAuthorization
+	for _, grant := range r.grants {
+		if grant.UserID != userID {
+			continue
+		}
+		listed := *grant
+		if r.clients != nil {
+			if client, err := r.clients.GetByClientID(grant.ClientID); err == nil {
+				listed.ClientName = client.Name
+			}
+		}
+		authorizations = append(authorizations, &listed)
+	}
+	sort.Slice(authorizations, func(i, j int) bool { return authorizations[i].LastUsedAt.After(authorizations[j].LastUsedAt) })
+	return authorizations, nil
+}
+
+func (r *memGrantRepo) Delete(userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, grant := range r.grants {
+		if grant.UserID == userID && grant.ClientID == clientID {
+			r.grants = append(r.grants[:i], r.grants[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *memGrantRepo) find(userID uuid.UUID, clientID string) *domain.AppAuthorization {
+	for _, grant := range r.grants {
+		if grant.UserID == userID && grant.ClientID == clientID {
+			return grant
+		}
+	}
+	return nil
+}
+
+// memDeviceLoginRepo keeps kiosk logins in memory, keyed by ID
+type memDeviceLoginRepo struct {
+	mu     sync.Mutex
+	logins map[uuid.UUID]*domain.DeviceLogin
+}
+
+func newMemDeviceLoginRepo() *memDeviceLoginRepo {
+	return &memDeviceLoginRepo{logins: make(map[uuid.UUID]*domain.DeviceLogin)}
+}
+
+func (r *memDeviceLoginRepo) Create(login *domain.DeviceLogin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *login
+	r.logins[login.ID] = &stored
+	return nil
+}
+
+func (r *memDeviceLoginRepo) GetByDeviceCodeHash(hash string) (*domain.DeviceLogin, error) {
+	return r.find(func(login *domain.DeviceLogin) bool { return login.DeviceCodeHash == hash })
+}
+
+func (r *memDeviceLoginRepo) GetPendingByUserCode(userCode string) (*domain.DeviceLogin, error) {
+	return r.find(func(login *domain.DeviceLogin) bool {
+		return login.UserCode == userCode && !login.IsApproved() && !login.IsExpired()
+	})
+}
+
+func (r *memDeviceLoginRepo) find(match func(*domain.DeviceLogin) bool) (*domain.DeviceLogin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, login := range r.logins {
+		if match(login) {
+			found := *login
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memDeviceLoginRepo) Update(login *domain.DeviceLogin) error {
+	return r.Create(login)
+}
+
+func (r *memDeviceLoginRepo) Consume(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	login, ok := r.logins[id]
+	if !ok || !login.IsApproved() || login.IsConsumed() {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	login.ConsumedAt = &now
+	return nil
+}
+
+// memSigningKeyRepo keeps signing keys in memory, shared by every rotator
+// given it as instances share the database
+type memSigningKeyRepo struct {
+	mu   sync.Mutex
+	keys []*domain.SigningKey
+}
+
+func (r *memSigningKeyRepo) CreateNext(key *domain.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.keys {
+		if existing.Generation >= key.Generation {
+			return sql.ErrNoRows
+		}
+	}
+	stored := *key
+	r.keys = append(r.keys, &stored)
+	return nil
+}
+
+func (r *memSigningKeyRepo) RetireBefore(generation int, retiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Generation < generation && key.RetiresAt == nil {
+			at := retiresAt
+			key.RetiresAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memSigningKeyRepo) ListUnretired(now time.Time) (domain.KeyRing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ring domain.KeyRing
+	for _, key := range r.keys {
+		if key.RetiresAt == nil || key.RetiresAt.After(now) {
+			found := *key
+			ring = append(ring, &found)
+		}
+	}
+	return ring, nil
+}
+
+// geoByAddress locates the addresses it holds
+type geoByAddress map[string]*domain.GeoLocation
+
+func (g geoByAddress) Locate(_ context.Context, ipAddress string) (*domain.GeoLocation, error) {
+	return g[ipAddress], nil
+}
+
+// recordingPublisher records the events published through it
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []events.DomainEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event events.DomainEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+// ofType returns the published events of the type
+func (p *recordingPublisher) ofType(eventType string) []events.DomainEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var matching []events.DomainEvent
+	for _, event := range p.events {
+		if event.EventType == eventType {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+// newTestUser creates an active student with the password
+func newTestUser(t *testing.T, email, password string) *domain.User {
+	t.Helper()
+	user, err := domain.NewUser(domain.UserRegistration{
+		Email:     email,
+		Password:  password,
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		CampusID:  "main-campus",
+	}, domain.Peppers{})
+	if err != nil {
+		t.Fatalf("NewUser: %v", err)
+	}
+	return user
+}
+
+// farFuture is an expiry tokens do not reach during a test
+func farFuture() time.Time {
+	return time.Now().Add(time.Hour)
+}
+
+// memAccessTokenRepo keeps opaque access tokens in memory by hash. Tokens of
+// sessions revoked in sessions, when set, are not active.
+type memAccessTokenRepo struct {
+	domain.AccessTokenRepository
+	mu       sync.Mutex
+	tokens   map[string]*domain.AccessToken
+	sessions *memSessionRepo
+}
+
+func newMemAccessTokenRepo(sessions *memSessionRepo) *memAccessTokenRepo {
+	return &memAccessTokenRepo{tokens: make(map[string]*domain.AccessToken), sessions: sessions}
+}
+
+func (r *memAccessTokenRepo) Create(token *domain.AccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *memAccessTokenRepo) GetActive(tokenHash string, now time.Time) (*domain.AccessToken, error) {
+	r.mu.Lock()
+	token, ok := r.tokens[tokenHash]
+	r.mu.Unlock()
+	if !ok || !token.ExpiresAt.After(now) {
+		return nil, sql.ErrNoRows
+	}
+	if r.sessions != nil {
+		if session, err := r.sessions.GetByID(context.Background(), token.SessionID); err != nil || session.IsRevoked {
+			return nil, sql.ErrNoRows
+		}
+	}
+	return token, nil
+}
+
+// memReminderRepo counts verification reminders in memory
+type memReminderRepo struct {
+	mu        sync.Mutex
+	reminders map[uuid.UUID]*domain.VerificationReminder
+}
+
+func newMemReminderRepo() *memReminderRepo {
+	return &memReminderRepo{reminders: make(map[uuid.UUID]*domain.VerificationReminder)}
+}
+
+func (r *memReminderRepo) Get(userID uuid.UUID) (*domain.VerificationReminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reminder, ok := r.reminders[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *reminder
+	return &copied, nil
+}
+
+func (r *memReminderRepo) Record(userID uuid.UUID, sentAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reminder, ok := r.reminders[userID]
+	if !ok {
+		reminder = &domain.VerificationReminder{UserID: userID}
+		r.reminders[userID] = reminder
+	}
+	reminder.Count++
+	reminder.LastSentAt = sentAt
+	return nil
+}
+
+// fixedLimiter allows every request or none, whatever the key
+type fixedLimiter struct{ allowed bool }
+
+func (l fixedLimiter) Allow(_ string, limit ratelimit.Limit) ratelimit.Result {
+	return l.Peek("", limit)
+}
+
+func (l fixedLimiter) Peek(_ string, limit ratelimit.Limit) ratelimit.Result {
+	if l.allowed {
+		return ratelimit.Result{Allowed: true, Limit: limit.Requests, Remaining: limit.Requests}
+	}
+	return ratelimit.Result{Limit: limit.Requests, RetryAfter: limit.Window}
+}
+
+// memDelegationRepo keeps delegations in memory, in the order created
+type memDelegationRepo struct {
+	mu          sync.Mutex
+	delegations []*domain.Delegation
+}
+
+func (r *memDelegationRepo) Create(delegation *domain.Delegation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delegations = append(r.delegations, delegation)
+	return nil
+}
+
+func (r *memDelegationRepo) GetByID(id uuid.UUID) (*domain.Delegation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delegation := range r.delegations {
+		if delegation.ID == id {
+			copied := *delegation
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memDelegationRepo) ListByUserID(userID uuid.UUID) ([]*domain.Delegation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var delegations []*domain.Delegation
+	for i := len(r.delegations) - 1; i >= 0; i-- {
+		if delegation := r.delegations[i]; delegation.GrantorID == userID || delegation.DelegateID == userID {
+			copied := *delegation
+			delegations = append(delegations, &copied)
+		}
+	}
+	return delegations, nil
+}
+
+func (r *memDelegationRepo) Revoke(id, userID uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delegation := range r.delegations {
+		if delegation.ID == id && (delegation.GrantorID == userID || delegation.DelegateID == userID) && delegation.RevokedAt == nil {
+			delegation.RevokedAt = &at
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
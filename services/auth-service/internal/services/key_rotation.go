@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// maxTokenTTL is the longest lifetime of a token signed with the signing
+// keys (ID tokens); a superseded key keeps verifying for that long
+const maxTokenTTL = idTokenTTL
+
+// keyRotationCheckInterval is how often persisted keys are reloaded and
+// checked for a due rotation
+const keyRotationCheckInterval = time.Minute
+
+// KeyRotationConfig holds the signing key rotation settings
+type KeyRotationConfig struct {
+	// Interval is how long each key generation signs tokens
+	Interval time.Duration
+	// Lead is how long a new key is published before it signs, so other
+	// instances and consumers caching the public keys pick it up in time
+	Lead time.Duration
+}
+
+// KeyStatus describes one signing key generation
+type KeyStatus struct {
+	Generation int `json:"generation"`
+	// KeyID is the kid header of the tokens the key signs
+	KeyID       string                 `json:"kid,omitempty"`
+	State       domain.SigningKeyState `json:"state"`
+	ActivatesAt time.Time              `json:"activates_at"`
+	RetiresAt   *time.Time             `json:"retires_at,omitempty"`
+}
+
+// KeyRotationStatus reports the signing key generations and the next rotation
+type KeyRotationStatus struct {
+	Algorithm         string      `json:"algorithm"`
+	CurrentGeneration int         `json:"current_generation"`
+	NextRotationAt    time.Time   `json:"next_rotation_at"`
+	Keys              []KeyStatus `json:"keys"`
+}
+
+// KeyRotator rotates the signing keys on a schedule. Keys are persisted so
+// every instance signs and verifies with the same generations and restarts
+// keep them.
+type KeyRotator struct {
+	repo   domain.SigningKeyRepository
+	keys   *SigningKeys
+	config KeyRotationConfig
+
+	mu   sync.Mutex
+	ring domain.KeyRing
+}
+
+// NewKeyRotator creates a KeyRotator managing keys
+func NewKeyRotator(repo domain.SigningKeyRepository, keys *SigningKeys, config KeyRotationConfig) *KeyRotator {
+	return &KeyRotator{
+		repo:   repo,
+		keys:   keys,
+		config: config,
+	}
+}
+
+// Load installs the persisted keys. On first start the configured key is
+// persisted as generation 1, so tokens signed before rotation was enabled
+// stay valid.
+func (r *KeyRotator) Load(ctx context.Context) error {
+	now := time.Now()
+	ring, err := r.repo.ListUnretired(now)
+	if err != nil {
+		return err
+	}
+
+	if len(ring) == 0 {
+		material, err := r.keys.material()
+		if err != nil {
+			return err
+		}
+		seed := domain.NewSigningKey(1, r.keys.Algorithm(), material, now)
+		if err := r.repo.CreateNext(seed); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if ring, err = r.repo.ListUnretired(now); err != nil {
+			return err
+		}
+	}
+
+	return r.apply(ring, now)
+}
+
+// Run checks for due rotations until ctx is done
+func (r *KeyRotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(keyRotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.tick(time.Now()); err != nil {
+				log.Printf("Signing key rotation failed: %v", err)
+			}
+		}
+	}
+}
+
+// tick reloads the keys, schedules the next generation when it is due and
+// installs the result
+func (r *KeyRotator) tick(now time.Time) error {
+	ring, err := r.repo.ListUnretired(now)
+	if err != nil {
+		return err
+	}
+
+	if len(ring) > 0 && !now.Before(ring.NextRotation(r.config.Interval, r.config.Lead)) {
+		if err := r.rotate(ring.Newest(), now); err != nil {
+			return err
+		}
+		if ring, err = r.repo.ListUnretired(now); err != nil {
+			return err
+		}
+	}
+
+	return r.apply(ring, now)
+}
+
+// rotate schedules the generation after newest to sign from now+Lead, and
+// the retirement of the older keys once their last tokens have expired
+func (r *KeyRotator) rotate(newest *domain.SigningKey, now time.Time) error {
+	material, err := generateKeyMaterial(r.keys.Algorithm())
+	if err != nil {
+		return err
+	}
+
+	next := domain.NewSigningKey(newest.Generation+1, r.keys.Algorithm(), material, now.Add(r.config.Lead))
+	if err := r.repo.CreateNext(next); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// another instance scheduled this rotation first
+			return nil
+		}
+		return err
+	}
+	log.Printf("Scheduled signing key generation %d to activate at %s", next.Generation, next.ActivatesAt.Format(time.RFC3339))
+
+	return r.repo.RetireBefore(next.Generation, next.ActivatesAt.Add(maxTokenTTL))
+}
+
+// apply installs the ring: its current signer signs, every key verifies
+func (r *KeyRotator) apply(ring domain.KeyRing, now time.Time) error {
+	signer := ring.Signer(now)
+	if signer == nil {
+		return errors.New("no active signing key")
+	}
+
+	var signKey interface{}
+	var verifyKeys []interface{}
+	for _, key := range ring {
+		if key.Algorithm != r.keys.Algorithm() {
+			return fmt.Errorf("signing key generation %d uses %s, not %s", key.Generation, key.Algorithm, r.keys.Algorithm())
+		}
+		private, verify, err := parseKeyMaterial(key.Algorithm, key.Material)
+		if err != nil {
+			return fmt.Errorf("signing key generation %d: %w", key.Generation, err)
+		}
+		if key == signer {
+			signKey = private
+		}
+		verifyKeys = append(verifyKeys, verify)
+	}
+
+	r.keys.replace(signKey, verifyKeys)
+
+	r.mu.Lock()
+	r.ring = ring
+	r.mu.Unlock()
+	return nil
+}
+
+// Status reports the installed key generations and the next rotation
+func (r *KeyRotator) Status() *KeyRotationStatus {
+	return r.status(time.Now())
+}
+
+// status reports the installed keys in their states at now
+func (r *KeyRotator) status(now time.Time) *KeyRotationStatus {
+	r.mu.Lock()
+	ring := r.ring
+	r.mu.Unlock()
+
+	status := &KeyRotationStatus{
+		Algorithm:      r.keys.Algorithm(),
+		NextRotationAt: ring.NextRotation(r.config.Interval, r.config.Lead),
+		Keys:           make([]KeyStatus, 0, len(ring)),
+	}
+	if signer := ring.Signer(now); signer != nil {
+		status.CurrentGeneration = signer.Generation
+	}
+	for _, key := range ring {
+		var kid string
+		if _, verify, err := parseKeyMaterial(key.Algorithm, key.Material); err == nil {
+			kid = keyID(verify)
+		}
+		status.Keys = append(status.Keys, KeyStatus{
+			Generation:  key.Generation,
+			KeyID:       kid,
+			State:       key.State(now),
+			ActivatesAt: key.ActivatesAt,
+			RetiresAt:   key.RetiresAt,
+		})
+	}
+	return status
+}
+
+// material encodes the current signing key for persistence
+func (k *SigningKeys) material() (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	switch key := k.signKey.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(key), nil
+	case *rsa.PrivateKey:
+		return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})), nil
+	}
+	return "", fmt.Errorf("unsupported signing key type %T", k.signKey)
+}
+
+// generateKeyMaterial creates fresh key material for the algorithm
+func generateKeyMaterial(algorithm string) (string, error) {
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return "", fmt.Errorf("failed to generate signing secret: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(secret), nil
+	case jwt.SigningMethodRS256.Alg():
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})), nil
+	}
+	return "", fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
+
+// parseKeyMaterial decodes persisted key material into its signing and
+// verification keys
+func parseKeyMaterial(algorithm, material string) (interface{}, interface{}, error) {
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret, err := base64.StdEncoding.DecodeString(material)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode signing secret: %w", err)
+		}
+		return secret, secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		private, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(material))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		return private, &private.PublicKey, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+var testRotation = KeyRotationConfig{Interval: 24 * time.Hour, Lead: time.Hour}
+
+// newTestRotator loads a rotator of HMAC keys over repo
+func newTestRotator(t *testing.T, repo *memSigningKeyRepo) (*KeyRotator, *SigningKeys) {
+	t.Helper()
+	keys := NewHMACKeys("test-secret")
+	rotator := NewKeyRotator(repo, keys, testRotation)
+	if err := rotator.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return rotator, keys
+}
+
+// signTest signs a token that outlives the test, whatever the simulated clock says
+func signTest(t *testing.T, keys *SigningKeys) string {
+	t.Helper()
+	token, err := keys.Sign(&jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func verifies(keys *SigningKeys, token string) bool {
+	return keys.Parse(token, &jwt.RegisteredClaims{}) == nil
+}
+
+// states returns the state of each key generation at now
+func states(status *KeyRotationStatus) map[int]domain.SigningKeyState {
+	got := make(map[int]domain.SigningKeyState)
+	for _, key := range status.Keys {
+		got[key.Generation] = key.State
+	}
+	return got
+}
+
+func TestKeyRotatorSeedsConfiguredKey(t *testing.T) {
+	before := signTest(t, NewHMACKeys("test-secret"))
+	repo := &memSigningKeyRepo{}
+	rotator, keys := newTestRotator(t, repo)
+
+	if len(repo.keys) != 1 || repo.keys[0].Generation != 1 {
+		t.Fatalf("persisted %d keys, want the configured key as generation 1", len(repo.keys))
+	}
+	if !verifies(keys, before) {
+		t.Error("token signed before rotation was enabled no longer verifies")
+	}
+	status := rotator.Status()
+	if status.CurrentGeneration != 1 || status.Keys[0].State != domain.KeyActive {
+		t.Errorf("status %+v, want generation 1 active", status)
+	}
+	want := repo.keys[0].ActivatesAt.Add(testRotation.Interval - testRotation.Lead)
+	if !status.NextRotationAt.Equal(want) {
+		t.Errorf("next rotation at %s, want %s", status.NextRotationAt, want)
+	}
+}
+
+// A key is published before it signs and keeps verifying after it stops
+// signing, until the tokens it signed have expired
+func TestKeyRotationStateMachine(t *testing.T) {
+	repo := &memSigningKeyRepo{}
+	rotator, keys := newTestRotator(t, repo)
+	start := repo.keys[0].ActivatesAt
+	first := signTest(t, keys)
+
+	// not due yet: nothing changes
+	if err := rotator.tick(start.Add(testRotation.Interval - testRotation.Lead - time.Minute)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(repo.keys) != 1 {
+		t.Fatalf("rotated early: %d keys", len(repo.keys))
+	}
+
+	// due: generation 2 is published, generation 1 still signs
+	due := start.Add(testRotation.Interval - testRotation.Lead)
+	if err := rotator.tick(due); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if got := states(rotator.status(due)); got[1] != domain.KeyRetiring || got[2] != domain.KeyPending {
+		t.Errorf("states when due = %v, want 1 retiring and 2 pending", got)
+	}
+	if stillFirst := signTest(t, keys); !verifies(NewHMACKeys("test-secret"), stillFirst) {
+		t.Error("the pending key signed before it activated")
+	}
+
+	// after the lead time generation 2 signs and both verify
+	activated := due.Add(testRotation.Lead)
+	if err := rotator.tick(activated); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	second := signTest(t, keys)
+	if verifies(NewHMACKeys("test-secret"), second) {
+		t.Error("generation 1 still signs after generation 2 activated")
+	}
+	if !verifies(keys, first) || !verifies(keys, second) {
+		t.Error("a live token of either generation does not verify")
+	}
+	if status := rotator.status(activated); status.CurrentGeneration != 2 {
+		t.Errorf("current generation %d, want 2", status.CurrentGeneration)
+	}
+
+	// once the last token of generation 1 has expired it is dropped
+	if err := rotator.tick(activated.Add(maxTokenTTL)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if verifies(keys, first) {
+		t.Error("retired generation 1 still verifies")
+	}
+	if !verifies(keys, second) {
+		t.Error("generation 2 token no longer verifies")
+	}
+	if got := states(rotator.status(activated.Add(maxTokenTTL))); len(got) != 1 || got[2] != domain.KeyActive {
+		t.Errorf("states after retirement = %v, want only 2 active", got)
+	}
+}
+
+// Instances sharing the keys schedule each rotation once and sign with the
+// same generation; a restarted instance picks the keys up again
+func TestKeyRotationAcrossInstances(t *testing.T) {
+	repo := &memSigningKeyRepo{}
+	first, firstKeys := newTestRotator(t, repo)
+	second, secondKeys := newTestRotator(t, repo)
+	due := repo.keys[0].ActivatesAt.Add(testRotation.Interval - testRotation.Lead)
+
+	for _, rotator := range []*KeyRotator{first, second} {
+		if err := rotator.tick(due); err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+	}
+	if len(repo.keys) != 2 {
+		t.Fatalf("%d keys after both instances rotated, want 2", len(repo.keys))
+	}
+
+	activated := due.Add(testRotation.Lead)
+	for _, rotator := range []*KeyRotator{first, second} {
+		if err := rotator.tick(activated); err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+	}
+	if !verifies(secondKeys, signTest(t, firstKeys)) || !verifies(firstKeys, signTest(t, secondKeys)) {
+		t.Error("instances do not verify each other's tokens")
+	}
+
+	restarted := NewHMACKeys("another-configured-secret")
+	if err := NewKeyRotator(repo, restarted, testRotation).tick(activated); err != nil {
+		t.Fatalf("tick after restart: %v", err)
+	}
+	if !verifies(restarted, signTest(t, firstKeys)) {
+		t.Error("restarted instance lost the persisted keys")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// LoginFailureReason classifies why a login did not complete. Values are
+// metric labels and log fields, so they must stay stable.
+type LoginFailureReason string
+
+// Login failure reasons. Clients only ever see ErrInvalidCredentials for an
+// unknown user or a wrong password; the distinction is for operators.
+const (
+	LoginUnknownUser        LoginFailureReason = "unknown_user"
+	LoginBadPassword        LoginFailureReason = "bad_password"
+	LoginAccountLocked      LoginFailureReason = "account_locked"
+	LoginAccountSuspended   LoginFailureReason = "account_suspended"
+	LoginAccountBanned      LoginFailureReason = "account_banned"
+	LoginAccountDisabled    LoginFailureReason = "account_disabled"
+	LoginAccountDeactivated LoginFailureReason = "account_deactivated"
+	LoginAccountMerged      LoginFailureReason = "account_merged"
+	// LoginTwoFactorRequired is a password login stopped for a second factor
+	LoginTwoFactorRequired LoginFailureReason = "2fa_required"
+	LoginTwoFactorFailed   LoginFailureReason = "2fa_failed"
+)
+
+var loginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_login_failures_total",
+	Help: "Logins that did not complete, by reason.",
+}, []string{"reason"})
+
+// recordLoginFailure counts the failure and logs it as key=value fields. The
+// email is never logged; userID is nil when no account matched.
+func recordLoginFailure(reason LoginFailureReason, userID *uuid.UUID, ipAddress string) {
+	loginFailures.WithLabelValues(string(reason)).Inc()
+	logins.WithLabelValues(string(reason)).Inc()
+
+	user := "-"
+	if userID != nil {
+		user = userID.String()
+	}
+	log.Printf("login failed reason=%s user_id=%s ip=%s", reason, user, ipAddress)
+}
+
+// loginFailed records the failure, and audits it on the user's account when
+// one matched. A second factor being asked for is not audited, as the login
+// may still succeed.
+func (s *AuthService) loginFailed(reason LoginFailureReason, userID *uuid.UUID, ipAddress, userAgent string) {
+	recordLoginFailure(reason, userID, ipAddress)
+	if userID == nil || reason == LoginTwoFactorRequired {
+		return
+	}
+	s.config.Audit.Record(domain.NewAuditLog(*userID, domain.AuditLoginFailed, ipAddress, userAgent, domain.AuditMetadata{
+		"reason": string(reason),
+	}))
+}
+
+// accountFailureReason classifies an error of checkLoginAllowed
+func accountFailureReason(err error) LoginFailureReason {
+	switch {
+	case errors.Is(err, ErrAccountBanned):
+		return LoginAccountBanned
+	case errors.Is(err, ErrAccountSuspended):
+		return LoginAccountSuspended
+	case errors.Is(err, ErrAccountDisabled):
+		return LoginAccountDisabled
+	case errors.Is(err, ErrAccountMerged):
+		return LoginAccountMerged
+	}
+	return LoginAccountDeactivated
+}
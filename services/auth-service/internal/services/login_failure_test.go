@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+var allLoginFailureReasons = []LoginFailureReason{
+	LoginUnknownUser, LoginBadPassword, LoginAccountLocked, LoginAccountSuspended, LoginAccountBanned,
+	LoginAccountDisabled, LoginAccountDeactivated, LoginAccountMerged, LoginTwoFactorRequired, LoginTwoFactorFailed,
+}
+
+// loginFailureCounts reads the failure counter of every reason
+func loginFailureCounts() map[LoginFailureReason]float64 {
+	counts := make(map[LoginFailureReason]float64)
+	for _, reason := range allLoginFailureReasons {
+		counts[reason] = testutil.ToFloat64(loginFailures.WithLabelValues(string(reason)))
+	}
+	return counts
+}
+
+func TestLoginFailureClassification(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	password := func(password string) func(*testing.T, *authFixture, *domain.User) {
+		return func(t *testing.T, f *authFixture, user *domain.User) {
+			f.service.Login(context.Background(), domain.UserLogin{Email: user.Email, Password: password}, "192.0.2.1", "test-agent")
+		}
+	}
+	secondFactor := func(code func(*testing.T, string) string) func(*testing.T, *authFixture, *domain.User) {
+		return func(t *testing.T, f *authFixture, user *domain.User) {
+			secret := enableTOTP(t, user)
+			f.users.Update(context.Background(), user)
+			result, err := f.service.Login(context.Background(), domain.UserLogin{Email: user.Email, Password: "password-123"}, "192.0.2.1", "test-agent")
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			f.service.VerifyTwoFactor(context.Background(), domain.TwoFactorLogin{ChallengeToken: result.ChallengeToken, Code: code(t, secret)}, "192.0.2.1", "test-agent")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(*domain.User)
+		attempt func(*testing.T, *authFixture, *domain.User)
+		want    map[LoginFailureReason]float64
+	}{
+		{"unknown user", nil, func(t *testing.T, f *authFixture, _ *domain.User) {
+			f.service.Login(context.Background(), domain.UserLogin{Email: "nobody@example.edu", Password: "password-123"}, "192.0.2.1", "test-agent")
+		}, map[LoginFailureReason]float64{LoginUnknownUser: 1}},
+		{"bad password", nil, password("wrong-password"), map[LoginFailureReason]float64{LoginBadPassword: 1}},
+		{"locked", nil, func(t *testing.T, f *authFixture, user *domain.User) {
+			password("wrong-password")(t, f, user)
+			password("password-123")(t, f, user)
+		}, map[LoginFailureReason]float64{LoginBadPassword: 1, LoginAccountLocked: 1}},
+		{"suspended", func(u *domain.User) { u.SuspendedUntil = &future }, password("password-123"), map[LoginFailureReason]float64{LoginAccountSuspended: 1}},
+		{"banned", func(u *domain.User) { u.BannedAt = &past }, password("password-123"), map[LoginFailureReason]float64{LoginAccountBanned: 1}},
+		{"disabled", func(u *domain.User) { u.Disable(u.ID) }, password("password-123"), map[LoginFailureReason]float64{LoginAccountDisabled: 1}},
+		{"deactivated", func(u *domain.User) { u.Deactivate() }, password("password-123"), map[LoginFailureReason]float64{LoginAccountDeactivated: 1}},
+		{"merged", func(u *domain.User) { u.Deactivate(); u.MergedInto = &u.ID }, password("password-123"), map[LoginFailureReason]float64{LoginAccountMerged: 1}},
+		{"second factor asked for", nil, secondFactor(currentTOTP), map[LoginFailureReason]float64{LoginTwoFactorRequired: 1}},
+		{"wrong second factor", nil, secondFactor(func(*testing.T, string) string { return "000000" }), map[LoginFailureReason]float64{LoginTwoFactorRequired: 1, LoginTwoFactorFailed: 1}},
+		{"invalid challenge", nil, func(t *testing.T, f *authFixture, _ *domain.User) {
+			f.service.VerifyTwoFactor(context.Background(), domain.TwoFactorLogin{ChallengeToken: "not-a-challenge", Code: "123456"}, "192.0.2.1", "test-agent")
+		}, map[LoginFailureReason]float64{LoginTwoFactorFailed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			if tt.setup != nil {
+				tt.setup(user)
+			}
+			f := newAuthFixture(t, AuthConfig{
+				ReactivationWindow: 24 * time.Hour,
+				Lockout: ratelimit.NewLockout(ratelimit.LockoutPolicy{
+					Scope:       ratelimit.ScopeAccountIP,
+					MaxFailures: 1,
+					Window:      time.Minute,
+					Duration:    time.Hour,
+				}),
+			}, user)
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+			before := loginFailureCounts()
+			tt.attempt(t, f, user)
+			after := loginFailureCounts()
+
+			for _, reason := range allLoginFailureReasons {
+				if got := after[reason] - before[reason]; got != tt.want[reason] {
+					t.Errorf("%s failures counted %v, want %v", reason, got, tt.want[reason])
+				}
+			}
+			for reason := range tt.want {
+				if !strings.Contains(logs.String(), "login failed reason="+string(reason)+" ") {
+					t.Errorf("no log line for %s in:\n%s", reason, logs.String())
+				}
+			}
+			if strings.Contains(logs.String(), user.Email) {
+				t.Errorf("email logged:\n%s", logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// OAuthClientService manages the registry of OAuth client applications and
+// validates the clients presented to the OAuth flows
+type OAuthClientService struct {
+	clientRepo domain.OAuthClientRepository
+	tokens     *AccessTokens
+}
+
+// NewOAuthClientService creates a new OAuthClientService; tokens tells which
+// access token formats clients can be registered for
+func NewOAuthClientService(clientRepo domain.OAuthClientRepository, tokens *AccessTokens) *OAuthClientService {
+	return &OAuthClientService{clientRepo: clientRepo, tokens: tokens}
+}
+
+// Register creates a client with fresh credentials. The secret is only
+// returned here and on rotation.
+func (s *OAuthClientService) Register(ctx context.Context, req domain.OAuthClientRequest) (*domain.RegisteredOAuthClient, error) {
+	if err := s.checkTokenFormat(req.AccessTokenFormat); err != nil {
+		return nil, err
+	}
+
+	clientID, err := generateClientID()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := domain.NewOAuthClient(clientID, secret, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+
+	return &domain.RegisteredOAuthClient{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+// List returns every registered client
+func (s *OAuthClientService) List(ctx context.Context) ([]*domain.OAuthClient, error) {
+	return s.clientRepo.List()
+}
+
+// Get returns the client with the given ID
+func (s *OAuthClientService) Get(ctx context.Context, id uuid.UUID) (*domain.OAuthClient, error) {
+	client, err := s.clientRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+	return client, nil
+}
+
+// Update replaces the settings of a client; its credentials are kept
+func (s *OAuthClientService) Update(ctx context.Context, id uuid.UUID, req domain.OAuthClientRequest) (*domain.OAuthClient, error) {
+	if err := s.checkTokenFormat(req.AccessTokenFormat); err != nil {
+		return nil, err
+	}
+	client, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Apply(req); err != nil {
+		return nil, err
+	}
+	if err := s.clientRepo.Update(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// RotateSecret issues a new client secret; the old one stops working immediately
+func (s *OAuthClientService) RotateSecret(ctx context.Context, id uuid.UUID) (*domain.RegisteredOAuthClient, error) {
+	client, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	client.RotateSecret(secret)
+	if err := s.clientRepo.Update(client); err != nil {
+		return nil, err
+	}
+
+	return &domain.RegisteredOAuthClient{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+// Delete removes a client
+func (s *OAuthClientService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := s.clientRepo.Delete(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOAuthClientNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate checks a client's credentials and that it may use the grant type
+func (s *OAuthClientService) Authenticate(ctx context.Context, clientID, secret, grantType string) (*domain.OAuthClient, error) {
+	client, err := s.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(grantType) {
+		return nil, ErrUnauthorizedClient
+	}
+	return client, nil
+}
+
+// AuthenticateClient checks a client's credentials, for endpoints such as
+// token introspection that are not tied to a grant type
+func (s *OAuthClientService) AuthenticateClient(ctx context.Context, clientID, secret string) (*domain.OAuthClient, error) {
+	client, err := s.clientRepo.GetByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(domain.HashToken(secret))) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ValidateAuthorizationRequest checks that the client exists, may use the
+// authorization code grant, redirects to an exactly registered URI and asks
+// only for allowed scopes
+func (s *OAuthClientService) ValidateAuthorizationRequest(ctx context.Context, clientID, redirectURI string, scopes []string) (*domain.OAuthClient, error) {
+	client, err := s.clientRepo.GetByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, ErrInvalidRedirectURI
+	}
+	if !client.AllowsGrant(domain.GrantAuthorizationCode) {
+		return nil, ErrUnauthorizedClient
+	}
+	if !client.AllowsScopes(scopes) {
+		return nil, ErrInvalidScope
+	}
+	return client, nil
+}
+
+// checkTokenFormat rejects JWT formats whose algorithm has no configured key
+func (s *OAuthClientService) checkTokenFormat(format domain.TokenFormat) error {
+	if !s.tokens.Supports(format) {
+		return domain.ValidationError{
+			Field:   "access_token_format",
+			Message: fmt.Sprintf("%s tokens cannot be issued: no key is configured for that algorithm", format),
+		}
+	}
+	return nil
+}
+
+// generateClientID returns a random public client identifier
+func generateClientID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate client ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
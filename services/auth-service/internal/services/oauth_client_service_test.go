@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const testRedirectURI = "https://app.example.edu/callback"
+
+func newOAuthClientService() *OAuthClientService {
+	tokens := NewAccessTokens(NewHMACKeys("test-secret"), nil, nil, NewMemoryBlacklist(), 15*time.Minute)
+	return NewOAuthClientService(newMemOAuthClientRepo(), tokens)
+}
+
+// registerClient registers a client allowed the authorization code and
+// refresh token grants
+func registerClient(t *testing.T, s *OAuthClientService) *domain.RegisteredOAuthClient {
+	t.Helper()
+	client, err := s.Register(context.Background(), domain.OAuthClientRequest{
+		Name:         "Campus app",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{"openid", "profile"},
+		GrantTypes:   []string{domain.GrantAuthorizationCode, domain.GrantRefreshToken},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return client
+}
+
+func TestAuthenticateChecksClientCredentials(t *testing.T) {
+	s := newOAuthClientService()
+	client := registerClient(t, s)
+	ctx := context.Background()
+
+	if _, err := s.Authenticate(ctx, client.ClientID, client.ClientSecret, domain.GrantRefreshToken); err != nil {
+		t.Fatalf("Authenticate with the issued secret: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, client.ClientID, "wrong-secret", domain.GrantRefreshToken); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("Authenticate with a wrong secret = %v, want ErrInvalidClient", err)
+	}
+	if _, err := s.Authenticate(ctx, "unknown-client", client.ClientSecret, domain.GrantRefreshToken); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("Authenticate an unknown client = %v, want ErrInvalidClient", err)
+	}
+	if _, err := s.Authenticate(ctx, client.ClientID, client.ClientSecret, domain.GrantClientCredentials); !errors.Is(err, ErrUnauthorizedClient) {
+		t.Errorf("Authenticate for a grant not allowed = %v, want ErrUnauthorizedClient", err)
+	}
+}
+
+func TestRotateSecretRetiresTheOldSecret(t *testing.T) {
+	s := newOAuthClientService()
+	client := registerClient(t, s)
+	ctx := context.Background()
+
+	rotated, err := s.RotateSecret(ctx, client.ID)
+	if err != nil {
+		t.Fatalf("RotateSecret: %v", err)
+	}
+	if _, err := s.AuthenticateClient(ctx, client.ClientID, client.ClientSecret); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("old secret = %v, want ErrInvalidClient", err)
+	}
+	if _, err := s.AuthenticateClient(ctx, client.ClientID, rotated.ClientSecret); err != nil {
+		t.Errorf("new secret: %v", err)
+	}
+}
+
+func TestValidateAuthorizationRequestMatchesRedirectURIExactly(t *testing.T) {
+	s := newOAuthClientService()
+	client := registerClient(t, s)
+	ctx := context.Background()
+
+	if _, err := s.ValidateAuthorizationRequest(ctx, client.ClientID, testRedirectURI, []string{"openid"}); err != nil {
+		t.Fatalf("registered redirect URI: %v", err)
+	}
+	for _, uri := range []string{
+		testRedirectURI + "/",
+		testRedirectURI + "/evil",
+		testRedirectURI + "?next=https://evil.example.com",
+		"https://app.example.edu/Callback",
+		"http://app.example.edu/callback",
+		"https://app.example.edu.evil.example.com/callback",
+		"https://app.example.edu:8443/callback",
+		"",
+	} {
+		if _, err := s.ValidateAuthorizationRequest(ctx, client.ClientID, uri, nil); !errors.Is(err, ErrInvalidRedirectURI) {
+			t.Errorf("redirect URI %q = %v, want ErrInvalidRedirectURI", uri, err)
+		}
+	}
+}
+
+func TestValidateAuthorizationRequestChecksClientAndScopes(t *testing.T) {
+	s := newOAuthClientService()
+	client := registerClient(t, s)
+	ctx := context.Background()
+
+	if _, err := s.ValidateAuthorizationRequest(ctx, "unknown-client", testRedirectURI, nil); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("unknown client = %v, want ErrInvalidClient", err)
+	}
+	if _, err := s.ValidateAuthorizationRequest(ctx, client.ClientID, testRedirectURI, []string{"openid", "admin"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("scope not allowed = %v, want ErrInvalidScope", err)
+	}
+
+	// a client that lost the authorization code grant cannot start the flow
+	_, err := s.Update(ctx, client.ID, domain.OAuthClientRequest{
+		Name:         "Campus app",
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{domain.GrantClientCredentials},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := s.ValidateAuthorizationRequest(ctx, client.ClientID, testRedirectURI, nil); !errors.Is(err, ErrUnauthorizedClient) {
+		t.Errorf("client without the grant = %v, want ErrUnauthorizedClient", err)
+	}
+}
+
+func TestRegisterRejectsInvalidClients(t *testing.T) {
+	s := newOAuthClientService()
+	ctx := context.Background()
+
+	for name, req := range map[string]domain.OAuthClientRequest{
+		"relative redirect URI": {Name: "app", RedirectURIs: []string{"/callback"}, GrantTypes: []string{domain.GrantAuthorizationCode}},
+		"no redirect URI":       {Name: "app", GrantTypes: []string{domain.GrantAuthorizationCode}},
+		"unsupported format":    {Name: "app", GrantTypes: []string{domain.GrantClientCredentials}, AccessTokenFormat: domain.TokenFormatJWTRS256},
+	} {
+		var validationErr domain.ValidationError
+		if _, err := s.Register(ctx, req); !errors.As(err, &validationErr) {
+			t.Errorf("%s: Register = %v, want a validation error", name, err)
+		}
+	}
+}
+
+func TestOAuthClientNotFound(t *testing.T) {
+	s := newOAuthClientService()
+	client := registerClient(t, s)
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, client.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, client.ID); !errors.Is(err, ErrOAuthClientNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrOAuthClientNotFound", err)
+	}
+	if err := s.Delete(ctx, client.ID); !errors.Is(err, ErrOAuthClientNotFound) {
+		t.Errorf("second Delete = %v, want ErrOAuthClientNotFound", err)
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+const (
+	oauthStateTTL      = 10 * time.Minute
+	oauthStateAudience = "unibazzar-oauth-state"
+	// oauthTimeout bounds each call to an identity provider
+	oauthTimeout = 10 * time.Second
+	// maxProviderResponse bounds how much of a provider response is read
+	maxProviderResponse = 1 << 20
+)
+
+// OAuthProvider is an external identity provider users can log in with
+// through the authorization-code flow
+type OAuthProvider struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+	// CampusID is the campus of users signing up through the provider
+	CampusID string
+	// TrustEmail treats every email the provider reports as verified
+	TrustEmail bool
+}
+
+// OAuthStateClaims are the JWT claims carried by the state parameter of an
+// authorization request. Nonce is also kept in a cookie of the browser that
+// started the login, so a callback cannot be replayed into another browser.
+type OAuthStateClaims struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// OAuthStart is where to send the browser to log in with a provider, and the
+// nonce the callback must present back
+type OAuthStart struct {
+	AuthURL string
+	Nonce   string
+}
+
+// OAuthService logs users in with external identity providers such as
+// Google or a university SSO. Provider accounts are linked to our users by
+// verified email, and the login ends in a session of our own.
+type OAuthService struct {
+	providers    map[string]OAuthProvider
+	identityRepo domain.OAuthIdentityRepository
+	userRepo     domain.UserRepository
+	auditRepo    domain.AuditRepository
+	authService  *AuthService
+	verifier     *EmailVerificationService
+	// redirectBase is the public URL the provider redirects back to
+	redirectBase string
+	httpClient   *http.Client
+}
+
+// NewOAuthService creates a new OAuthService. Providers redirect back to
+// publicURL/api/v1/auth/oauth/:provider/callback.
+func NewOAuthService(providers map[string]OAuthProvider, identityRepo domain.OAuthIdentityRepository, userRepo domain.UserRepository, auditRepo domain.AuditRepository, authService *AuthService, verifier *EmailVerificationService, publicURL string) *OAuthService {
+	return &OAuthService{
+		providers:    providers,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		auditRepo:    auditRepo,
+		authService:  authService,
+		verifier:     verifier,
+		redirectBase: strings.TrimSuffix(publicURL, "/"),
+		httpClient:   &http.Client{Timeout: oauthTimeout},
+	}
+}
+
+// Start begins a login with the provider, returning the provider's
+// authorization URL and the nonce its state is bound to
+func (s *OAuthService) Start(ctx context.Context, providerName string) (*OAuthStart, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	nonce, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	state, err := s.authService.keys.Sign(OAuthStateClaims{
+		Provider: providerName,
+		Nonce:    nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oauthStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oauthStateTTL)),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign OAuth state: %w", err)
+	}
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {provider.ClientID},
+		"redirect_uri":  {s.redirectURI(providerName)},
+		"scope":         {strings.Join(provider.Scopes, " ")},
+		"state":         {state},
+	}
+	separator := "?"
+	if strings.Contains(provider.AuthURL, "?") {
+		separator = "&"
+	}
+	return &OAuthStart{AuthURL: provider.AuthURL + separator + query.Encode(), Nonce: nonce}, nil
+}
+
+// Callback completes a login with the code the provider redirected back
+// with. The provider account is matched to a linked identity first, then to
+// an account with the same email, which is linked rather than refused, and
+// otherwise a new account is created. Emails the provider has not verified
+// are never matched or registered.
+func (s *OAuthService) Callback(ctx context.Context, providerName, code, state, nonce, ipAddress, userAgent string) (*LoginResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if err := s.checkState(providerName, state, nonce); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.exchangeCode(ctx, providerName, provider, code)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.fetchProfile(ctx, provider, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, providerName, provider, profile, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return s.authService.externalLogin(ctx, user, domain.AuthOAuth, ipAddress, userAgent)
+}
+
+// checkState verifies the state was issued by Start for the provider and
+// the browser presenting the nonce
+func (s *OAuthService) checkState(providerName, state, nonce string) error {
+	claims := &OAuthStateClaims{}
+	if err := s.authService.keys.Parse(state, claims, jwt.WithAudience(oauthStateAudience)); err != nil {
+		return ErrInvalidOAuthState
+	}
+	if claims.Provider != providerName || nonce == "" || claims.Nonce != nonce {
+		return ErrInvalidOAuthState
+	}
+	return nil
+}
+
+// exchangeCode trades the authorization code for the provider's access token
+func (s *OAuthService) exchangeCode(ctx context.Context, providerName string, provider OAuthProvider, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.redirectURI(providerName)},
+		"client_id":     {provider.ClientID},
+		"client_secret": {provider.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	status, err := s.call(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusBadRequest || status == http.StatusUnauthorized {
+		// the code was wrong, expired or already used
+		return "", ErrInvalidToken
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		log.Printf("OAuth provider %s refused the code exchange with status %d: %s", providerName, status, token.Error)
+		return "", ErrProviderUnavailable
+	}
+	return token.AccessToken, nil
+}
+
+// fetchProfile reads the account the access token belongs to from the
+// provider's userinfo endpoint
+func (s *OAuthService) fetchProfile(ctx context.Context, provider OAuthProvider, accessToken string) (domain.ExternalProfile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.UserInfoURL, nil)
+	if err != nil {
+		return domain.ExternalProfile{}, fmt.Errorf("failed to build userinfo request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info struct {
+		Subject       string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		GivenName     string      `json:"given_name"`
+		FamilyName    string      `json:"family_name"`
+		Name          string      `json:"name"`
+	}
+	status, err := s.call(req, &info)
+	if err != nil {
+		return domain.ExternalProfile{}, err
+	}
+	if status != http.StatusOK || info.Subject == "" {
+		log.Printf("OAuth userinfo at %s failed with status %d", provider.UserInfoURL, status)
+		return domain.ExternalProfile{}, ErrProviderUnavailable
+	}
+
+	profile := domain.ExternalProfile{
+		Subject:       info.Subject,
+		Email:         strings.TrimSpace(info.Email),
+		EmailVerified: provider.TrustEmail || isTrue(info.EmailVerified),
+		FirstName:     info.GivenName,
+		LastName:      info.FamilyName,
+	}
+	if profile.FirstName == "" && profile.LastName == "" {
+		profile.FirstName, profile.LastName, _ = strings.Cut(strings.TrimSpace(info.Name), " ")
+	}
+	return profile, nil
+}
+
+// call sends a request to a provider and decodes its JSON response into out,
+// returning the status. Unreachable providers and unreadable responses fail
+// with ErrProviderUnavailable.
+func (s *OAuthService) call(req *http.Request, out interface{}) (int, error) {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		log.Printf("OAuth provider request to %s failed: %v", req.URL.Host, err)
+		return 0, ErrProviderUnavailable
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponse))
+	if err != nil {
+		log.Printf("Failed to read OAuth provider response from %s: %v", req.URL.Host, err)
+		return 0, ErrProviderUnavailable
+	}
+	if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+		log.Printf("OAuth provider %s sent an unreadable response: %v", req.URL.Host, err)
+		return 0, ErrProviderUnavailable
+	}
+	return resp.StatusCode, nil
+}
+
+// resolveUser returns the user the provider account is linked to, linking
+// or creating one on its first login
+func (s *OAuthService) resolveUser(ctx context.Context, providerName string, provider OAuthProvider, profile domain.ExternalProfile, ipAddress, userAgent string) (*domain.User, error) {
+	identity, err := s.identityRepo.GetBySubject(providerName, profile.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrUserNotFound
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get OAuth identity: %w", err)
+	}
+
+	// matching by an email the provider does not vouch for would let anyone
+	// take over the account registered with it
+	if profile.Email == "" || !profile.EmailVerified {
+		return nil, ErrOAuthEmailUnverified
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, profile.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		if user, err = s.register(ctx, provider, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	identity = domain.NewOAuthIdentity(user.ID, providerName, profile)
+	if err := s.identityRepo.Create(identity); err != nil {
+		return nil, fmt.Errorf("failed to link OAuth identity: %w", err)
+	}
+
+	metadata := domain.AuditMetadata{"provider": providerName, "subject": profile.Subject}
+	if err := s.auditRepo.Create(domain.NewAuditLog(user.ID, domain.AuditOAuthIdentityLinked, ipAddress, userAgent, metadata)); err != nil {
+		log.Printf("Failed to audit OAuth identity link of user %s: %v", user.ID, err)
+	}
+	return user, nil
+}
+
+// register creates the account of a provider user new to us. It gets a
+// random password the user never sees; a password reset sets a real one.
+func (s *OAuthService) register(ctx context.Context, provider OAuthProvider, profile domain.ExternalProfile) (*domain.User, error) {
+	password, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	user, err := domain.NewExternalUser(profile, password, provider.CampusID, s.authService.config.Peppers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user: %w", err)
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	event := s.verifier.registeredEvent(user)
+	if err := s.authService.publisher.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.EventType, err)
+	}
+	return user, nil
+}
+
+func (s *OAuthService) redirectURI(providerName string) string {
+	return s.redirectBase + "/api/v1/auth/oauth/" + url.PathEscape(providerName) + "/callback"
+}
+
+// isTrue reads a claim some providers send as a boolean and others as a string
+func isTrue(claim interface{}) bool {
+	switch value := claim.(type) {
+	case bool:
+		return value
+	case string:
+		parsed, _ := strconv.ParseBool(value)
+		return parsed
+	}
+	return false
+}
+
+// externalLogin opens a session for a user authenticated by an external
+// identity provider with method. Users with 2FA enabled get a challenge to
+// complete with VerifyTwoFactor instead, as after a password.
+func (s *AuthService) externalLogin(ctx context.Context, user *domain.User, method domain.AuthMethod, ipAddress, userAgent string) (*LoginResult, error) {
+	if err := s.checkLoginAllowed(user); err != nil {
+		s.loginFailed(accountFailureReason(err), &user.ID, ipAddress, userAgent)
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		challenge, err := s.signChallenge(user, nil, true)
+		if err != nil {
+			return nil, err
+		}
+		methods, err := enrolledMethods(s.methodRepo, user)
+		if err != nil {
+			return nil, err
+		}
+		s.loginFailed(LoginTwoFactorRequired, &user.ID, ipAddress, userAgent)
+		return &LoginResult{TwoFactorRequired: true, ChallengeToken: challenge, MFAMethods: methods}, nil
+	}
+
+	tokens, err := s.openSession(ctx, user, domain.SessionAuth{Method: method}, nil, true, nil, "", ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokens, MustChangePassword: user.MustChangePassword}, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// newOIDCFixture is an auth fixture with a verified user and a registered
+// client allowed the openid and profile scopes
+func newOIDCFixture(t *testing.T) (*authFixture, *domain.User, *domain.OAuthClient) {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	user.IsVerified = true
+	f := newAuthFixture(t, AuthConfig{Issuer: "https://auth.example.edu/"}, user)
+
+	client, err := domain.NewOAuthClient("campus-app", "client-secret", domain.OAuthClientRequest{
+		Name:         "Campus app",
+		RedirectURIs: []string{"https://app.example.edu/callback"},
+		Scopes:       []string{"openid", "profile"},
+		GrantTypes:   []string{domain.GrantAuthorizationCode},
+	})
+	if err != nil {
+		t.Fatalf("NewOAuthClient: %v", err)
+	}
+	f.clients.Create(client)
+	return f, user, client
+}
+
+func (f *authFixture) loginWith(req domain.OIDCRequest) (*LoginResult, error) {
+	return f.service.Login(context.Background(), domain.UserLogin{
+		Email:       "ada@example.edu",
+		Password:    "password-123",
+		OIDCRequest: req,
+	}, "192.0.2.1", "test-agent")
+}
+
+func TestLoginIssuesIDTokenForOpenIDScope(t *testing.T) {
+	f, user, client := newOIDCFixture(t)
+
+	result, err := f.loginWith(domain.OIDCRequest{ClientID: client.ClientID, Scope: "openid profile", Nonce: "n-0S6_WzA2Mj"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if result.IDToken == "" {
+		t.Fatal("no ID token issued for the openid scope")
+	}
+
+	var claims IDClaims
+	if err := f.service.keys.Parse(result.IDToken, &claims); err != nil {
+		t.Fatalf("parse ID token: %v", err)
+	}
+	if claims.Subject != user.ID.String() {
+		t.Errorf("sub = %q, want %q", claims.Subject, user.ID)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != client.ClientID {
+		t.Errorf("aud = %v, want [%s]", claims.Audience, client.ClientID)
+	}
+	if claims.Issuer != "https://auth.example.edu" {
+		t.Errorf("iss = %q, want the public URL without trailing slash", claims.Issuer)
+	}
+	if claims.Email != user.Email || !claims.EmailVerified || claims.Name != "Ada Lovelace" {
+		t.Errorf("email %q, email_verified %v, name %q", claims.Email, claims.EmailVerified, claims.Name)
+	}
+	if claims.Nonce != "n-0S6_WzA2Mj" {
+		t.Errorf("nonce = %q, want the request nonce unchanged", claims.Nonce)
+	}
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil || claims.AuthTime == 0 {
+		t.Error("ID token lacks exp, iat or auth_time")
+	}
+
+	// logging in through the client authorizes it
+	if len(f.grants.grants) != 1 || f.grants.grants[0].ClientID != client.ClientID {
+		t.Errorf("grants = %v, want one for the client", f.grants.grants)
+	}
+}
+
+func TestIDTokenNonceIsPerLogin(t *testing.T) {
+	f, _, client := newOIDCFixture(t)
+
+	nonces := map[string]string{"first": "nonce-1", "second": "nonce-2", "none": ""}
+	for name, nonce := range nonces {
+		result, err := f.loginWith(domain.OIDCRequest{ClientID: client.ClientID, Scope: "openid", Nonce: nonce})
+		if err != nil {
+			t.Fatalf("%s: Login: %v", name, err)
+		}
+		var claims IDClaims
+		if err := f.service.keys.Parse(result.IDToken, &claims); err != nil {
+			t.Fatalf("%s: parse ID token: %v", name, err)
+		}
+		if claims.Nonce != nonce {
+			t.Errorf("%s: nonce = %q, want %q", name, claims.Nonce, nonce)
+		}
+	}
+}
+
+func TestLoginWithoutOpenIDScopeHasNoIDToken(t *testing.T) {
+	f, _, client := newOIDCFixture(t)
+
+	for name, req := range map[string]domain.OIDCRequest{
+		"no client":     {},
+		"profile scope": {ClientID: client.ClientID, Scope: "profile", Nonce: "ignored"},
+	} {
+		result, err := f.loginWith(req)
+		if err != nil {
+			t.Fatalf("%s: Login: %v", name, err)
+		}
+		if result.IDToken != "" {
+			t.Errorf("%s: ID token issued without the openid scope", name)
+		}
+	}
+}
+
+func TestLoginRejectsUnknownClientOrScope(t *testing.T) {
+	f, _, client := newOIDCFixture(t)
+
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: "unknown", Scope: "openid"}); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("unknown client = %v, want ErrInvalidClient", err)
+	}
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: client.ClientID, Scope: "openid admin"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("scope not allowed = %v, want ErrInvalidScope", err)
+	}
+	if _, err := f.loginWith(domain.OIDCRequest{ClientID: client.ClientID, Scope: "openidx"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("scope resembling openid = %v, want ErrInvalidScope", err)
+	}
+	if len(f.sessions.sessions) != 0 {
+		t.Errorf("%d sessions opened by refused logins", len(f.sessions.sessions))
+	}
+}
+
+func TestIDTokenIsNotAnAccessToken(t *testing.T) {
+	f, _, client := newOIDCFixture(t)
+
+	result, err := f.loginWith(domain.OIDCRequest{ClientID: client.ClientID, Scope: "openid"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := ParseAccessToken(result.IDToken, f.service.keys); err == nil {
+		t.Error("ID token was accepted as an access token")
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+const (
+	// outboxClaimTTL is how long a dispatcher holds the events it claimed;
+	// events of a dispatcher that died are picked up again after it
+	outboxClaimTTL = time.Minute
+	// outboxCleanupInterval is how often dispatched events are purged
+	outboxCleanupInterval = time.Hour
+)
+
+// OutboxPublisher publishes outbox events to the bus. Unlike Publish, it
+// must fail rather than hold the event in memory when the broker is
+// unreachable, as the outbox keeps the event until it is confirmed.
+type OutboxPublisher interface {
+	PublishUnbuffered(ctx context.Context, event events.DomainEvent) error
+}
+
+// OutboxConfig controls how the outbox is dispatched and cleaned up
+type OutboxConfig struct {
+	// PollInterval is how often undispatched events are looked for
+	PollInterval time.Duration
+	// BatchSize is the number of events claimed at once
+	BatchSize int
+	// Retention is how long dispatched events are kept before being purged
+	Retention time.Duration
+}
+
+// OutboxDispatcher publishes the events written to the outbox and marks
+// them dispatched once the broker confirms them. Delivery is at least once:
+// an event published just before a crash is sent again, and consumers dedupe
+// by its event ID.
+type OutboxDispatcher struct {
+	outboxRepo domain.OutboxRepository
+	publisher  OutboxPublisher
+	config     OutboxConfig
+}
+
+// NewOutboxDispatcher creates a new OutboxDispatcher
+func NewOutboxDispatcher(outboxRepo domain.OutboxRepository, publisher OutboxPublisher, config OutboxConfig) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		outboxRepo: outboxRepo,
+		publisher:  publisher,
+		config:     config,
+	}
+}
+
+// Run dispatches pending events and purges old dispatched ones periodically
+// until ctx is done
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(d.config.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			if _, err := d.DispatchPending(ctx); err != nil {
+				log.Printf("Failed to dispatch outbox events: %v", err)
+			}
+		case <-cleanup.C:
+			purged, err := d.outboxRepo.DeleteDispatchedBefore(time.Now().Add(-d.config.Retention))
+			if err != nil {
+				log.Printf("Failed to purge dispatched outbox events: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("Purged %d dispatched outbox events", purged)
+			}
+		}
+	}
+}
+
+// DispatchPending publishes the undispatched events, oldest first, and
+// returns how many were dispatched. Events that fail are left for the next
+// poll.
+func (d *OutboxDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	var dispatched int
+	for {
+		claimed, err := d.outboxRepo.Claim(d.config.BatchSize, time.Now().Add(outboxClaimTTL))
+		if err != nil {
+			return dispatched, err
+		}
+
+		failed := 0
+		for _, outboxEvent := range claimed {
+			if err := ctx.Err(); err != nil {
+				return dispatched, err
+			}
+			if err := d.dispatch(ctx, outboxEvent); err != nil {
+				failed++
+				log.Printf("Failed to dispatch outbox event %s (%s): %v", outboxEvent.DedupeKey, outboxEvent.EventType, err)
+				if err := d.outboxRepo.RecordFailure(outboxEvent.ID, err.Error()); err != nil {
+					return dispatched, err
+				}
+				continue
+			}
+			dispatched++
+		}
+
+		// stop on a short batch, or while publishing fails, to retry next poll
+		if len(claimed) < d.config.BatchSize || failed > 0 {
+			return dispatched, nil
+		}
+	}
+}
+
+func (d *OutboxDispatcher) dispatch(ctx context.Context, outboxEvent *domain.OutboxEvent) error {
+	event, err := decodeOutboxEvent(outboxEvent)
+	if err != nil {
+		return err
+	}
+	if err := d.publisher.PublishUnbuffered(ctx, event); err != nil {
+		return err
+	}
+	return d.outboxRepo.MarkDispatched(outboxEvent.ID, time.Now())
+}
+
+// newOutboxEvent stores an event for the dispatcher, keyed by its event ID
+func newOutboxEvent(event events.DomainEvent) (*domain.OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", event.EventType, err)
+	}
+	return domain.NewOutboxEvent(event.EventID, event.EventType, payload), nil
+}
+
+// decodeOutboxEvent restores the stored envelope, keeping its data as the
+// JSON it was written as
+func decodeOutboxEvent(outboxEvent *domain.OutboxEvent) (events.DomainEvent, error) {
+	var event events.DomainEvent
+	var raw struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(outboxEvent.Payload, &event); err != nil {
+		return event, fmt.Errorf("failed to decode outbox event: %w", err)
+	}
+	if err := json.Unmarshal(outboxEvent.Payload, &raw); err != nil {
+		return event, fmt.Errorf("failed to decode outbox event: %w", err)
+	}
+	event.Data = raw.Data
+	return event, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// passwordResetTokenTTL is how long a password reset link stays usable
+const passwordResetTokenTTL = time.Hour
+
+// TokenStatus reports whether a reset token is usable without consuming it
+type TokenStatus struct {
+	Valid     bool       `json:"valid"`
+	ExpiresIn int64      `json:"expires_in"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// PasswordResetService handles password reset tokens
+type PasswordResetService struct {
+	userRepo       domain.UserRepository
+	resetRepo      domain.PasswordResetRepository
+	sessionRepo    domain.SessionRepository
+	transactor     domain.Transactor
+	publisher      events.Publisher
+	tokens         *AccessTokens
+	passwordPolicy domain.PasswordPolicy
+	peppers        domain.Peppers
+}
+
+// NewPasswordResetService creates a new PasswordResetService. A reset
+// consumes its token and sets the password in one transaction of transactor,
+// and revokes the access tokens of the account through tokens.
+func NewPasswordResetService(userRepo domain.UserRepository, resetRepo domain.PasswordResetRepository, sessionRepo domain.SessionRepository, transactor domain.Transactor, publisher events.Publisher, tokens *AccessTokens, passwordPolicy domain.PasswordPolicy, peppers domain.Peppers) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:       userRepo,
+		resetRepo:      resetRepo,
+		sessionRepo:    sessionRepo,
+		transactor:     transactor,
+		publisher:      publisher,
+		tokens:         tokens,
+		passwordPolicy: passwordPolicy,
+		peppers:        peppers,
+	}
+}
+
+// RequestReset sends a reset token to the active account registered with the
+// email. It reports nothing back: the token is issued in the background, so
+// neither the outcome nor the response time tells whether the email is
+// registered.
+func (s *PasswordResetService) RequestReset(ctx context.Context, req domain.PasswordResetRequest) {
+	go s.issueReset(context.WithoutCancel(ctx), req.Email)
+}
+
+// issueReset stores a new reset token for the account and asks the
+// notification service to email it. Failures are only logged.
+func (s *PasswordResetService) issueReset(ctx context.Context, email string) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to look up account for password reset: %v", err)
+		}
+		return
+	}
+	if user.Status().State != domain.AccountActive {
+		return
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		log.Printf("Failed to issue password reset for %s: %v", user.ID, err)
+		return
+	}
+	reset := domain.NewPasswordReset(user.ID, token, time.Now().Add(passwordResetTokenTTL))
+	if err := s.resetRepo.Create(reset); err != nil {
+		log.Printf("Failed to issue password reset for %s: %v", user.ID, err)
+		return
+	}
+
+	if err := s.publisher.Publish(ctx, passwordResetRequestedEvent(user, reset, token)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.PasswordResetRequested, err)
+	}
+}
+
+// ValidateToken reports whether token is usable and for how long. It never
+// marks the token as used; only ResetPassword consumes it.
+func (s *PasswordResetService) ValidateToken(ctx context.Context, token string) (*TokenStatus, error) {
+	reset, err := s.lookup(token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return &TokenStatus{Valid: false}, nil
+		}
+		return nil, err
+	}
+	if !reset.IsValid() {
+		return &TokenStatus{Valid: false}, nil
+	}
+
+	return &TokenStatus{
+		Valid:     true,
+		ExpiresIn: int64(reset.RemainingLifetime().Seconds()),
+		ExpiresAt: &reset.ExpiresAt,
+	}, nil
+}
+
+// ResetPassword consumes token and sets the new password of its owner. The
+// token is consumed in the transaction setting the password, so it sets it
+// at most once, even for concurrent requests. Every session and access token
+// of the account is revoked, so whoever knew the old password is logged out,
+// and the user is alerted.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, confirm domain.PasswordResetConfirm, ipAddress, userAgent string) error {
+	reset, err := s.lookup(confirm.Token)
+	if err != nil {
+		return err
+	}
+	if !reset.IsValid() {
+		return ErrInvalidToken
+	}
+
+	var user *domain.User
+	err = s.transactor.WithTx(ctx, func(repos domain.Repositories) error {
+		if err := repos.PasswordResets.Consume(reset); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		var err error
+		user, err = repos.Users.GetByID(ctx, reset.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if err := s.passwordPolicy.Check(user.Role, "new_password", confirm.NewPassword, user.Email, user.FirstName, user.LastName); err != nil {
+			return err
+		}
+		// a reset must replace the password, even one the user still remembers
+		if user.CheckPassword(confirm.NewPassword, s.peppers) {
+			return ErrPasswordUnchanged
+		}
+		if err := user.SetPassword(confirm.NewPassword, s.peppers); err != nil {
+			return fmt.Errorf("failed to set password: %w", err)
+		}
+		return repos.Users.Update(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := s.tokens.revokeUser(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityPasswordReset, ipAddress, userAgent)
+	return nil
+}
+
+func (s *PasswordResetService) lookup(token string) (*domain.PasswordReset, error) {
+	reset, err := s.resetRepo.GetByTokenHash(domain.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get password reset: %w", err)
+	}
+	return reset, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// memResetRepo keeps password resets in memory by token hash
+type memResetRepo struct {
+	mu     sync.Mutex
+	resets map[string]*domain.PasswordReset
+}
+
+func newMemResetRepo() *memResetRepo {
+	return &memResetRepo{resets: make(map[string]*domain.PasswordReset)}
+}
+
+func (r *memResetRepo) Create(reset *domain.PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *reset
+	r.resets[reset.TokenHash] = &stored
+	return nil
+}
+
+func (r *memResetRepo) GetByTokenHash(tokenHash string) (*domain.PasswordReset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reset, ok := r.resets[tokenHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *reset
+	return &copied, nil
+}
+
+func (r *memResetRepo) Consume(reset *domain.PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.resets[reset.TokenHash]
+	if !ok || !stored.IsValid() {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	stored.UsedAt = &now
+	reset.UsedAt = &now
+	return nil
+}
+
+// snapshot copies the resets, for a transaction to roll back to
+func (r *memResetRepo) snapshot() map[string]*domain.PasswordReset {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resets := make(map[string]*domain.PasswordReset, len(r.resets))
+	for hash, reset := range r.resets {
+		stored := *reset
+		resets[hash] = &stored
+	}
+	return resets
+}
+
+// newResetService creates a PasswordResetService whose transactions run on
+// the users and resets
+func newResetService(users *memUserRepo, resets *memResetRepo, sessions *memSessionRepo, publisher *recordingPublisher, tokens *AccessTokens, policy domain.PasswordPolicy) *PasswordResetService {
+	tx := &memTransactor{users: users, tokens: newMemVerificationTokenRepo(), audit: &memAuditRepo{}, outbox: &memOutboxRepo{}, resets: resets}
+	if tokens == nil {
+		tokens = NewAccessTokens(NewHMACKeys("test-secret"), nil, nil, NewMemoryBlacklist(), 15*time.Minute)
+	}
+	return NewPasswordResetService(users, resets, sessions, tx, publisher, tokens, policy, domain.Peppers{})
+}
+
+type passwordResetFixture struct {
+	service  *PasswordResetService
+	users    *memUserRepo
+	resets   *memResetRepo
+	user     *domain.User
+	token    string
+	sessions *memSessionRepo
+}
+
+func newPasswordResetFixture(t *testing.T, expiresIn time.Duration) *passwordResetFixture {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "old-password-1")
+	f := &passwordResetFixture{
+		users:    newMemUserRepo(user),
+		resets:   newMemResetRepo(),
+		sessions: newMemSessionRepo(),
+		user:     user,
+		token:    "reset-token",
+	}
+	f.service = newResetService(f.users, f.resets, f.sessions, &recordingPublisher{}, nil, domain.PasswordPolicy{Default: domain.PasswordRule{MinLength: 8}})
+	if err := f.resets.Create(domain.NewPasswordReset(user.ID, f.token, time.Now().Add(expiresIn))); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return f
+}
+
+func TestValidateTokenDoesNotConsume(t *testing.T) {
+	f := newPasswordResetFixture(t, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		status, err := f.service.ValidateToken(ctx, f.token)
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if !status.Valid || status.ExpiresAt == nil {
+			t.Fatalf("validation %d: %+v", i+1, status)
+		}
+		if status.ExpiresIn <= 3500 || status.ExpiresIn > 3600 {
+			t.Errorf("expires in %ds, want about an hour", status.ExpiresIn)
+		}
+	}
+
+	// the token still sets the password after being validated
+	if err := f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "new-password-1"}, "", ""); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if !f.users.get(t, f.user.ID).CheckPassword("new-password-1", domain.Peppers{}) {
+		t.Error("password was not changed")
+	}
+
+	// and only then is it used up
+	status, err := f.service.ValidateToken(ctx, f.token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if status.Valid {
+		t.Errorf("consumed token validates: %+v", status)
+	}
+	err = f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "another-password-1"}, "", "")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second reset = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateTokenReportsInvalid(t *testing.T) {
+	expired := newPasswordResetFixture(t, -time.Minute)
+	for name, tt := range map[string]struct {
+		fixture *passwordResetFixture
+		token   string
+	}{
+		"expired": {expired, expired.token},
+		"unknown": {expired, "no-such-token"},
+	} {
+		status, err := tt.fixture.service.ValidateToken(context.Background(), tt.token)
+		if err != nil {
+			t.Fatalf("%s: ValidateToken: %v", name, err)
+		}
+		if status.Valid || status.ExpiresIn != 0 || status.ExpiresAt != nil {
+			t.Errorf("%s: %+v, want an invalid token without lifetime", name, status)
+		}
+	}
+}
+
+func TestResetPasswordRejectsCurrentPassword(t *testing.T) {
+	f := newPasswordResetFixture(t, time.Hour)
+	ctx := context.Background()
+
+	err := f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "old-password-1"}, "", "")
+	if !errors.Is(err, ErrPasswordUnchanged) {
+		t.Fatalf("reset to the current password = %v, want ErrPasswordUnchanged", err)
+	}
+
+	// the token is kept so the user can pick another password
+	if status, err := f.service.ValidateToken(ctx, f.token); err != nil || !status.Valid {
+		t.Fatalf("token after the rejected reset = %+v, %v; want valid", status, err)
+	}
+	if err := f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "new-password-1"}, "", ""); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if !f.users.get(t, f.user.ID).CheckPassword("new-password-1", domain.Peppers{}) {
+		t.Error("password was not changed")
+	}
+}
+
+// Of two requests that both read the token unused, only the first to
+// consume it sets the password
+func TestResetPasswordConsumesTokenOnce(t *testing.T) {
+	f := newPasswordResetFixture(t, time.Hour)
+	ctx := context.Background()
+
+	// the second request read the token before the first consumed it
+	stale := newMemResetRepo()
+	unused, _ := f.resets.GetByTokenHash(domain.HashToken(f.token))
+	stale.Create(unused)
+	second := newResetService(f.users, f.resets, f.sessions, &recordingPublisher{}, nil, domain.PasswordPolicy{})
+	second.resetRepo = stale
+
+	if err := f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "new-password-1"}, "", ""); err != nil {
+		t.Fatalf("first reset: %v", err)
+	}
+	if err := second.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "attacker-password-1"}, "", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second reset = %v, want ErrInvalidToken", err)
+	}
+	if !f.users.get(t, f.user.ID).CheckPassword("new-password-1", domain.Peppers{}) {
+		t.Error("second reset replaced the password")
+	}
+}
+
+// A reset logs out the access tokens already issued, not only the sessions
+func TestResetPasswordRevokesAccessTokens(t *testing.T) {
+	f := newPasswordResetFixture(t, time.Hour)
+	ctx := context.Background()
+	issuedAt := time.Now().Add(-time.Minute)
+
+	if err := f.service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: f.token, NewPassword: "new-password-1"}, "", ""); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if revoked, err := f.service.tokens.blacklist.IsUserRevoked(f.user.ID.String(), issuedAt); err != nil || !revoked {
+		t.Errorf("access token issued before the reset revoked = %v, %v, want true", revoked, err)
+	}
+}
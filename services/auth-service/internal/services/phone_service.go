@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+const phoneCodeTTL = 10 * time.Minute
+
+// PhoneService manages adding and verifying user phone numbers
+type PhoneService struct {
+	userRepo      domain.UserRepository
+	tokenRepo     domain.VerificationTokenRepository
+	publisher     events.Publisher
+	enforceUnique bool
+}
+
+// NewPhoneService creates a new PhoneService. With enforceUnique set, a number
+// verified on one account cannot be verified on another.
+func NewPhoneService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, publisher events.Publisher, enforceUnique bool) *PhoneService {
+	return &PhoneService{
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		publisher:     publisher,
+		enforceUnique: enforceUnique,
+	}
+}
+
+// RequestPhone sends a verification code to the number. The number is only
+// stored on the user once the code is confirmed.
+func (s *PhoneService) RequestPhone(ctx context.Context, userID uuid.UUID, phone string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPhoneAvailable(ctx, user.ID, phone); err != nil {
+		return err
+	}
+
+	code, err := generateNumericCode()
+	if err != nil {
+		return err
+	}
+
+	verification := domain.NewVerificationToken(user.ID, domain.PurposePhone, phone, code, time.Now().Add(phoneCodeTTL))
+	if err := s.tokenRepo.Create(verification); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, phoneVerificationRequestedEvent(verification, code)); err != nil {
+		return fmt.Errorf("failed to request phone verification: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmPhone checks the code of the user's latest phone request and stores
+// the number. A wrong code invalidates the request so codes cannot be guessed.
+func (s *PhoneService) ConfirmPhone(ctx context.Context, userID uuid.UUID, code, ipAddress, userAgent string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	verification, err := s.tokenRepo.GetLatestByUserID(user.ID, domain.PurposePhone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if !verification.IsValid() {
+		return ErrInvalidToken
+	}
+
+	verification.MarkUsed()
+	if err := s.tokenRepo.Update(verification); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(domain.HashToken(code)), []byte(verification.TokenHash)) != 1 {
+		return ErrInvalidToken
+	}
+
+	if err := s.checkPhoneAvailable(ctx, user.ID, verification.Target); err != nil {
+		return err
+	}
+	if err := user.SetPhone(verification.Target); err != nil {
+		return err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		// another account verified the number since the check
+		if errors.Is(err, domain.ErrPhoneAlreadyVerified) {
+			return ErrPhoneTaken
+		}
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, phoneVerifiedEvent(user.ID, verification.Target)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.PhoneVerified, err)
+	}
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityPhoneChanged, ipAddress, userAgent)
+
+	return nil
+}
+
+// checkPhoneAvailable fails when another account has already verified the number
+func (s *PhoneService) checkPhoneAvailable(ctx context.Context, userID uuid.UUID, phone string) error {
+	if !s.enforceUnique {
+		return nil
+	}
+
+	owner, err := s.userRepo.GetByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to check phone: %w", err)
+	}
+	if owner.ID != userID {
+		return ErrPhoneTaken
+	}
+	return nil
+}
+
+func (s *PhoneService) getUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// generateNumericCode returns a random six digit code
+func generateNumericCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+type phoneFixture struct {
+	service   *PhoneService
+	users     *memUserRepo
+	publisher *recordingPublisher
+}
+
+func newPhoneFixture(enforceUnique bool, users ...*domain.User) *phoneFixture {
+	f := &phoneFixture{users: newMemUserRepo(users...), publisher: &recordingPublisher{}}
+	f.service = NewPhoneService(f.users, newMemVerificationTokenRepo(), f.publisher, enforceUnique)
+	return f
+}
+
+// requestCode requests a code for the phone and returns the code sent
+func (f *phoneFixture) requestCode(t *testing.T, user *domain.User, phone string) string {
+	t.Helper()
+	if err := f.service.RequestPhone(context.Background(), user.ID, phone); err != nil {
+		t.Fatalf("RequestPhone: %v", err)
+	}
+	sent := f.publisher.ofType(events.PhoneVerificationRequested)
+	return sent[len(sent)-1].Data.(events.PhoneVerificationData).Code
+}
+
+func (f *phoneFixture) confirm(user *domain.User, code string) error {
+	return f.service.ConfirmPhone(context.Background(), user.ID, code, "192.0.2.1", "test-agent")
+}
+
+const testPhone = "+15555550100"
+
+func TestConfirmPhone(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newPhoneFixture(true, user)
+
+	if err := f.confirm(user, f.requestCode(t, user, testPhone)); err != nil {
+		t.Fatalf("ConfirmPhone: %v", err)
+	}
+	stored := f.users.get(t, user.ID)
+	if stored.Phone == nil || *stored.Phone != testPhone || !stored.PhoneVerified {
+		t.Errorf("phone = %v, verified %v", stored.Phone, stored.PhoneVerified)
+	}
+
+	// verifying one's own number again is no conflict
+	if err := f.confirm(user, f.requestCode(t, user, testPhone)); err != nil {
+		t.Errorf("verifying the own number again: %v", err)
+	}
+}
+
+func TestRequestPhoneVerifiedElsewhere(t *testing.T) {
+	owner := newTestUser(t, "ada@example.edu", "password-123")
+	owner.SetPhone(testPhone)
+	other := newTestUser(t, "grace@example.edu", "password-123")
+	f := newPhoneFixture(true, owner, other)
+
+	if err := f.service.RequestPhone(context.Background(), other.ID, testPhone); !errors.Is(err, ErrPhoneTaken) {
+		t.Fatalf("RequestPhone = %v, want ErrPhoneTaken", err)
+	}
+	if len(f.publisher.events) != 0 {
+		t.Error("a code was sent for a number verified on another account")
+	}
+}
+
+// Unverified numbers need not be unique, so two accounts may request the
+// same number; only the first to verify it gets it
+func TestConfirmPhoneVerifiedElsewhereMeanwhile(t *testing.T) {
+	for _, enforceUnique := range []bool{true, false} {
+		first := newTestUser(t, "ada@example.edu", "password-123")
+		second := newTestUser(t, "grace@example.edu", "password-123")
+		f := newPhoneFixture(enforceUnique, first, second)
+
+		firstCode := f.requestCode(t, first, testPhone)
+		secondCode := f.requestCode(t, second, testPhone)
+		if err := f.confirm(first, firstCode); err != nil {
+			t.Fatalf("first ConfirmPhone: %v", err)
+		}
+
+		// without enforcement the service skips its check, leaving the
+		// unique index to reject the number
+		if err := f.confirm(second, secondCode); !errors.Is(err, ErrPhoneTaken) {
+			t.Errorf("enforceUnique %v: second ConfirmPhone = %v, want ErrPhoneTaken", enforceUnique, err)
+		}
+		if stored := f.users.get(t, second.ID); stored.Phone != nil || stored.PhoneVerified {
+			t.Errorf("enforceUnique %v: second account got the phone", enforceUnique)
+		}
+		if len(f.publisher.ofType(events.PhoneVerified)) != 1 {
+			t.Errorf("enforceUnique %v: %d phone verifications announced, want 1", enforceUnique, len(f.publisher.ofType(events.PhoneVerified)))
+		}
+	}
+}
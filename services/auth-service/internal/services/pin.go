@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// SetPin sets the PIN that unlocks the user's sessions, after checking their
+// password
+func (s *AuthService) SetPin(ctx context.Context, userID uuid.UUID, req domain.PinSetup) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.CheckPassword(req.CurrentPassword, s.config.Peppers) {
+		return ErrInvalidCredentials
+	}
+
+	if err := user.SetPin(req.Pin, s.config.Peppers); err != nil {
+		return fmt.Errorf("failed to set PIN: %w", err)
+	}
+	return s.userRepo.Update(ctx, user)
+}
+
+// RemovePin turns PIN unlock off for the user
+func (s *AuthService) RemovePin(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.HasPin() {
+		return ErrPinNotSet
+	}
+
+	user.RemovePin()
+	return s.userRepo.Update(ctx, user)
+}
+
+// PinUnlock re-authenticates a session with the user's PIN and rotates its
+// tokens like a refresh. After domain.MaxPinAttempts wrong PINs the session
+// only accepts a full login, and is revoked when PinLockoutRevokes is set.
+func (s *AuthService) PinUnlock(ctx context.Context, req domain.PinUnlock) (*domain.TokenPair, error) {
+	session, err := s.sessionRepo.GetByRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	user, err := s.refreshableSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasPin() {
+		return nil, ErrPinNotSet
+	}
+	if session.PinLocked() {
+		return nil, ErrPinLocked
+	}
+
+	if !user.CheckPin(req.Pin, s.config.Peppers) {
+		locked := session.FailPin()
+		if locked && s.config.PinLockoutRevokes {
+			session.Revoke()
+		}
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			return nil, err
+		}
+		if locked {
+			log.Printf("PIN unlock locked for session %s of user %s", session.ID, user.ID)
+			return nil, ErrPinLocked
+		}
+		return nil, ErrInvalidPin
+	}
+
+	session.PinFailures = 0
+	err = withUniqueRefreshToken(func(nonce string) error {
+		session.RotateRefreshToken(req.RefreshToken, nonce)
+		return s.sessionRepo.Update(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session)
+}
+
+func (s *AuthService) getUser(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
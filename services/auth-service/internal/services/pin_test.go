@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// pinFixture logs in a user who has set the PIN 4821
+func pinFixture(t *testing.T, config AuthConfig) (*authFixture, *domain.User, *LoginResult) {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, config, user)
+	if err := f.service.SetPin(context.Background(), user.ID, domain.PinSetup{Pin: "4821", CurrentPassword: "password-123"}); err != nil {
+		t.Fatalf("SetPin: %v", err)
+	}
+	return f, user, f.login(t, user.Email, "")
+}
+
+func TestPinUnlock(t *testing.T) {
+	f, user, result := pinFixture(t, AuthConfig{})
+	ctx := context.Background()
+
+	if err := f.service.SetPin(ctx, user.ID, domain.PinSetup{Pin: "1111", CurrentPassword: "wrong-password"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("SetPin with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if stored := f.users.get(t, user.ID); stored.PinHash == "" || stored.PinHash == "4821" || !stored.CheckPin("4821", f.service.config.Peppers) {
+		t.Fatalf("PIN stored as %q", stored.PinHash)
+	}
+
+	if _, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: result.RefreshToken, Pin: "0000"}); !errors.Is(err, ErrInvalidPin) {
+		t.Fatalf("wrong PIN = %v, want ErrInvalidPin", err)
+	}
+	tokens, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: result.RefreshToken, Pin: "4821"})
+	if err != nil {
+		t.Fatalf("correct PIN: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == result.RefreshToken {
+		t.Errorf("unlock did not rotate the tokens: %+v", tokens)
+	}
+	session, err := f.sessions.GetByRefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("rotated refresh token not stored: %v", err)
+	}
+	if session.PinFailures != 0 {
+		t.Errorf("PIN failures after an unlock = %d, want 0", session.PinFailures)
+	}
+	if _, err := f.service.RefreshToken(ctx, tokens.RefreshToken); err != nil {
+		t.Errorf("refresh after the unlock: %v", err)
+	}
+}
+
+func TestPinUnlockWithoutPin(t *testing.T) {
+	f, user, result := pinFixture(t, AuthConfig{})
+	ctx := context.Background()
+
+	if err := f.service.RemovePin(ctx, user.ID); err != nil {
+		t.Fatalf("RemovePin: %v", err)
+	}
+	if _, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: result.RefreshToken, Pin: "4821"}); !errors.Is(err, ErrPinNotSet) {
+		t.Errorf("unlock after the PIN was removed = %v, want ErrPinNotSet", err)
+	}
+	if err := f.service.RemovePin(ctx, user.ID); !errors.Is(err, ErrPinNotSet) {
+		t.Errorf("removing a removed PIN = %v, want ErrPinNotSet", err)
+	}
+	if _, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: "not-a-session", Pin: "4821"}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unlock of an unknown session = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestPinUnlockLockout(t *testing.T) {
+	for _, revokes := range []bool{false, true} {
+		t.Run(map[bool]string{false: "session kept", true: "session revoked"}[revokes], func(t *testing.T) {
+			f, user, result := pinFixture(t, AuthConfig{PinLockoutRevokes: revokes})
+			ctx := context.Background()
+			unlock := func(pin string) error {
+				_, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: result.RefreshToken, Pin: pin})
+				return err
+			}
+
+			for i := 1; i < domain.MaxPinAttempts; i++ {
+				if err := unlock("0000"); !errors.Is(err, ErrInvalidPin) {
+					t.Fatalf("wrong PIN %d = %v, want ErrInvalidPin", i, err)
+				}
+			}
+			if err := unlock("0000"); !errors.Is(err, ErrPinLocked) {
+				t.Fatalf("last wrong PIN = %v, want ErrPinLocked", err)
+			}
+			// the correct PIN no longer unlocks the session
+			refused := ErrPinLocked
+			if revokes {
+				refused = ErrInvalidToken
+			}
+			if err := unlock("4821"); !errors.Is(err, refused) {
+				t.Fatalf("correct PIN after the lockout = %v, want %v", err, refused)
+			}
+
+			session, err := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+			if err != nil {
+				t.Fatalf("locked session: %v", err)
+			}
+			if session.IsRevoked != revokes {
+				t.Errorf("session revoked = %v, want %v", session.IsRevoked, revokes)
+			}
+			_, err = f.service.RefreshToken(ctx, result.RefreshToken)
+			if revokes && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("refresh of the revoked session = %v, want ErrInvalidToken", err)
+			}
+			if !revokes && err != nil {
+				t.Errorf("refresh of the locked session: %v", err)
+			}
+
+			// falling back to a full login gives a session the PIN unlocks
+			fresh := f.login(t, user.Email, "")
+			if _, err := f.service.PinUnlock(ctx, domain.PinUnlock{RefreshToken: fresh.RefreshToken, Pin: "4821"}); err != nil {
+				t.Errorf("PIN unlock of a new session: %v", err)
+			}
+		})
+	}
+}
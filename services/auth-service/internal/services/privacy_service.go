@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// PrivacyService manages data processing consents and personal data exports
+type PrivacyService struct {
+	userRepo    domain.UserRepository
+	sessionRepo domain.SessionRepository
+	deviceRepo  domain.TrustedDeviceRepository
+	consentRepo domain.ConsentRepository
+}
+
+// NewPrivacyService creates a new PrivacyService
+func NewPrivacyService(userRepo domain.UserRepository, sessionRepo domain.SessionRepository, deviceRepo domain.TrustedDeviceRepository, consentRepo domain.ConsentRepository) *PrivacyService {
+	return &PrivacyService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		deviceRepo:  deviceRepo,
+		consentRepo: consentRepo,
+	}
+}
+
+// RecordConsents appends the user's consent decisions and returns the current consents
+func (s *PrivacyService) RecordConsents(ctx context.Context, userID uuid.UUID, update domain.ConsentUpdate, ipAddress string) ([]*domain.Consent, error) {
+	for _, decision := range update.Consents {
+		if err := s.consentRepo.Create(domain.NewConsent(userID, decision, ipAddress)); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetConsents(ctx, userID)
+}
+
+// GetConsents returns the latest consent decision per purpose
+func (s *PrivacyService) GetConsents(ctx context.Context, userID uuid.UUID) ([]*domain.Consent, error) {
+	history, err := s.consentRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return domain.CurrentConsents(history), nil
+}
+
+// HasConsent reports whether the user currently grants the purpose. Purposes
+// never decided on count as not granted.
+func (s *PrivacyService) HasConsent(ctx context.Context, userID uuid.UUID, purpose domain.ConsentPurpose) (bool, error) {
+	consent, err := s.consentRepo.GetLatest(userID, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get consent: %w", err)
+	}
+	return consent.Granted, nil
+}
+
+// Export collects the personal data held about the user, including the full consent history
+func (s *PrivacyService) Export(ctx context.Context, userID uuid.UUID) (*domain.DataExport, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	sessions, err := s.sessionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.deviceRepo.ListActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := s.consentRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.DataExport{
+		ExportedAt:     time.Now(),
+		User:           user,
+		Sessions:       sessions,
+		TrustedDevices: devices,
+		Consents:       consents,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+func decide(purpose domain.ConsentPurpose, granted bool, version string) domain.ConsentDecision {
+	return domain.ConsentDecision{Purpose: purpose, Granted: granted, TextVersion: version}
+}
+
+func TestRecordConsents(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	consents := &memConsentRepo{}
+	service := NewPrivacyService(newMemUserRepo(user), newMemSessionRepo(), newMemDeviceRepo(), consents)
+	ctx := context.Background()
+
+	if granted, err := service.HasConsent(ctx, user.ID, domain.ConsentAnalytics); err != nil || granted {
+		t.Fatalf("consent never decided on = %v, %v; want not granted", granted, err)
+	}
+
+	if _, err := service.RecordConsents(ctx, user.ID, domain.ConsentUpdate{Consents: []domain.ConsentDecision{
+		decide(domain.ConsentAnalytics, true, "v1"),
+		decide(domain.ConsentMarketing, true, "v1"),
+	}}, "192.0.2.1"); err != nil {
+		t.Fatalf("RecordConsents: %v", err)
+	}
+	current, err := service.RecordConsents(ctx, user.ID, domain.ConsentUpdate{Consents: []domain.ConsentDecision{
+		decide(domain.ConsentMarketing, false, "v2"),
+	}}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("RecordConsents: %v", err)
+	}
+
+	want := map[domain.ConsentPurpose]domain.ConsentDecision{
+		domain.ConsentMarketing: decide(domain.ConsentMarketing, false, "v2"),
+		domain.ConsentAnalytics: decide(domain.ConsentAnalytics, true, "v1"),
+	}
+	if len(current) != len(want) {
+		t.Fatalf("%d current consents, want %d", len(current), len(want))
+	}
+	for _, consent := range current {
+		got := decide(consent.Purpose, consent.Granted, consent.TextVersion)
+		if got != want[consent.Purpose] {
+			t.Errorf("current %s consent = %+v, want %+v", consent.Purpose, got, want[consent.Purpose])
+		}
+		if consent.RecordedAt.IsZero() || consent.IPAddress != "192.0.2.1" {
+			t.Errorf("%s consent not timestamped with its origin: %+v", consent.Purpose, consent)
+		}
+	}
+
+	if granted, _ := service.HasConsent(ctx, user.ID, domain.ConsentMarketing); granted {
+		t.Error("withdrawn marketing consent still granted")
+	}
+
+	export, err := service.Export(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if export.User.ID != user.ID || len(export.Consents) != 3 {
+		t.Errorf("export holds %d consent records, want the full history of 3", len(export.Consents))
+	}
+}
+
+// Analytics events of a login are only published once the user consents
+func TestLoginEventNeedsAnalyticsConsent(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := newAuthFixture(t, AuthConfig{}, user)
+	privacy := NewPrivacyService(f.users, f.sessions, f.devices, &memConsentRepo{})
+	f.service.publisher = events.NewConsentPublisher(f.publisher, privacy)
+	ctx := context.Background()
+
+	f.login(t, user.Email, "")
+	if got := len(f.publisher.ofType(events.UserLoggedIn)); got != 0 {
+		t.Fatalf("published %d user.logged_in events without consent", got)
+	}
+
+	if _, err := privacy.RecordConsents(ctx, user.ID, domain.ConsentUpdate{Consents: []domain.ConsentDecision{
+		decide(domain.ConsentAnalytics, true, "v1"),
+	}}, ""); err != nil {
+		t.Fatalf("RecordConsents: %v", err)
+	}
+	f.login(t, user.Email, "")
+	if got := len(f.publisher.ofType(events.UserLoggedIn)); got != 1 {
+		t.Fatalf("published %d user.logged_in events with consent, want 1", got)
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+const recoveryEmailTokenTTL = 24 * time.Hour
+
+// RecoveryEmailService manages the verified recovery address of users
+type RecoveryEmailService struct {
+	userRepo  domain.UserRepository
+	tokenRepo domain.VerificationTokenRepository
+	publisher events.Publisher
+	baseURL   string
+}
+
+// NewRecoveryEmailService creates a new RecoveryEmailService. baseURL is the
+// public address of this service, used to build confirmation links.
+func NewRecoveryEmailService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, publisher events.Publisher, baseURL string) *RecoveryEmailService {
+	return &RecoveryEmailService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		publisher: publisher,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// RequestRecoveryEmail sends a confirmation token to the new recovery address.
+// The address is only stored on the user once the token is confirmed.
+func (s *RecoveryEmailService) RequestRecoveryEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	email = strings.TrimSpace(email)
+	if strings.EqualFold(email, user.Email) {
+		return ErrSameAsLoginEmail
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	verification := domain.NewVerificationToken(user.ID, domain.PurposeRecoveryEmail, email, token, time.Now().Add(recoveryEmailTokenTTL))
+	if err := s.tokenRepo.Create(verification); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, recoveryEmailConfirmationRequestedEvent(s.baseURL, verification, token)); err != nil {
+		return fmt.Errorf("failed to request recovery email confirmation: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmRecoveryEmail consumes a confirmation token and stores its address as the recovery email
+func (s *RecoveryEmailService) ConfirmRecoveryEmail(ctx context.Context, token, ipAddress, userAgent string) error {
+	verification, err := s.tokenRepo.GetByTokenHash(domain.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if verification.Purpose != domain.PurposeRecoveryEmail || !verification.IsValid() {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, verification.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := user.SetRecoveryEmail(verification.Target); err != nil {
+		return err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	verification.MarkUsed()
+	if err := s.tokenRepo.Update(verification); err != nil {
+		return err
+	}
+
+	if err := s.publisher.Publish(ctx, recoveryEmailConfirmedEvent(user, verification)); err != nil {
+		log.Printf("Failed to publish %s event: %v", events.RecoveryEmailConfirmed, err)
+	}
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityRecoveryEmailChanged, ipAddress, userAgent)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+func TestRecoveryEmailFlow(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	users := newMemUserRepo(user)
+	publisher := &recordingPublisher{}
+	service := NewRecoveryEmailService(users, newMemVerificationTokenRepo(), publisher, "https://auth.example.edu/")
+	ctx := context.Background()
+
+	if err := service.RequestRecoveryEmail(ctx, user.ID, " ada.backup@example.org "); err != nil {
+		t.Fatalf("RequestRecoveryEmail: %v", err)
+	}
+	// nothing is stored until the address is confirmed
+	if got := users.get(t, user.ID); got.RecoveryEmail != nil {
+		t.Fatalf("recovery email stored before confirmation: %q", *got.RecoveryEmail)
+	}
+
+	requested := publisher.ofType(events.RecoveryEmailConfirmationRequested)
+	if len(requested) != 1 {
+		t.Fatalf("published %d confirmation requests, want 1", len(requested))
+	}
+	data := requested[0].Data.(events.RecoveryEmailConfirmationData)
+	if data.RecoveryEmail != "ada.backup@example.org" {
+		t.Errorf("confirmation sent to %q", data.RecoveryEmail)
+	}
+	if want := "https://auth.example.edu/api/v1/auth/confirm-recovery-email?token=" + data.Token; data.ConfirmationLink != want {
+		t.Errorf("confirmation link = %q, want %q", data.ConfirmationLink, want)
+	}
+
+	if err := service.ConfirmRecoveryEmail(ctx, data.Token, "192.0.2.1", "test"); err != nil {
+		t.Fatalf("ConfirmRecoveryEmail: %v", err)
+	}
+	confirmed := users.get(t, user.ID)
+	if confirmed.RecoveryEmail == nil || *confirmed.RecoveryEmail != "ada.backup@example.org" || !confirmed.RecoveryEmailVerified {
+		t.Fatalf("recovery email not stored: %+v", confirmed)
+	}
+	if len(publisher.ofType(events.UserSecurityChanged)) != 1 {
+		t.Error("confirming did not alert the user")
+	}
+
+	if err := service.ConfirmRecoveryEmail(ctx, data.Token, "", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reused token: %v, want ErrInvalidToken", err)
+	}
+
+	// reset links now go to both addresses
+	resets := newResetService(users, newMemResetRepo(), newMemSessionRepo(), publisher, nil, domain.PasswordPolicy{})
+	resets.issueReset(ctx, "ada@example.edu")
+	reset := publisher.ofType(events.PasswordResetRequested)
+	if len(reset) != 1 {
+		t.Fatalf("published %d reset requests, want 1", len(reset))
+	}
+	recipients := reset[0].Data.(events.PasswordResetRequestedData).Recipients
+	if len(recipients) != 2 || recipients[0] != "ada@example.edu" || recipients[1] != "ada.backup@example.org" {
+		t.Errorf("reset recipients = %q", recipients)
+	}
+
+	// the recovery address cannot be used to log in or start a reset
+	if _, err := users.GetByEmail(ctx, "ada.backup@example.org"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("lookup by recovery email = %v", err)
+	}
+	resets.issueReset(ctx, "ada.backup@example.org")
+	if got := len(publisher.ofType(events.PasswordResetRequested)); got != 1 {
+		t.Errorf("a reset requested for the recovery email was issued")
+	}
+}
+
+func TestRequestRecoveryEmailRejectsLoginEmail(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	service := NewRecoveryEmailService(newMemUserRepo(user), newMemVerificationTokenRepo(), &recordingPublisher{}, "")
+
+	err := service.RequestRecoveryEmail(context.Background(), user.ID, "ADA@example.edu")
+	if !errors.Is(err, ErrSameAsLoginEmail) {
+		t.Fatalf("RequestRecoveryEmail = %v, want ErrSameAsLoginEmail", err)
+	}
+}
+
+func TestConfirmRecoveryEmailRejectsOtherPurposes(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	tokens := newMemVerificationTokenRepo()
+	service := NewRecoveryEmailService(newMemUserRepo(user), tokens, &recordingPublisher{}, "")
+	if err := tokens.Create(domain.NewVerificationToken(user.ID, domain.PurposeEmailChange, "eve@example.org", "token", farFuture())); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := service.ConfirmRecoveryEmail(context.Background(), "token", "", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ConfirmRecoveryEmail = %v, want ErrInvalidToken", err)
+	}
+}
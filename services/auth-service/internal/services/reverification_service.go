@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// reverificationLimitKey is shared by every job and by verification
+// reminders so that together they stay within the send rate
+const reverificationLimitKey = "mail:reverification"
+
+// ReverificationConfig controls the pace of bulk re-verification jobs
+type ReverificationConfig struct {
+	// BatchSize is the number of users loaded per batch; progress is saved after each batch
+	BatchSize int
+	// Rate caps how many verification emails all jobs send together; zero requests disables the cap
+	Rate ratelimit.Limit
+}
+
+// ReverificationService re-sends verification emails to unverified users in
+// rate limited background jobs
+type ReverificationService struct {
+	userRepo  domain.UserRepository
+	tokenRepo domain.VerificationTokenRepository
+	jobRepo   domain.ReverificationJobRepository
+	auditRepo domain.AuditRepository
+	verifier  *EmailVerificationService
+	limiter   ratelimit.Limiter
+	config    ReverificationConfig
+
+	mu      sync.Mutex
+	running map[uuid.UUID]bool
+}
+
+// NewReverificationService creates a new ReverificationService
+func NewReverificationService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, jobRepo domain.ReverificationJobRepository, auditRepo domain.AuditRepository, verifier *EmailVerificationService, limiter ratelimit.Limiter, config ReverificationConfig) *ReverificationService {
+	return &ReverificationService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		jobRepo:   jobRepo,
+		auditRepo: auditRepo,
+		verifier:  verifier,
+		limiter:   limiter,
+		config:    config,
+		running:   make(map[uuid.UUID]bool),
+	}
+}
+
+// Start creates a job re-sending verification to every unverified user
+// matching the request and runs it in the background. A request repeating
+// an idempotency key returns the job it started.
+func (s *ReverificationService) Start(ctx context.Context, callerID uuid.UUID, req domain.ReverificationRequest, ipAddress, userAgent string) (*domain.ReverificationJob, error) {
+	if req.CampusID != nil {
+		if err := domain.CheckCampusID("campus_id", *req.CampusID); err != nil {
+			return nil, err
+		}
+	}
+	if req.IdempotencyKey != "" {
+		job, err := s.jobByIdempotencyKey(req.IdempotencyKey)
+		if job != nil || err != nil {
+			return job, err
+		}
+	}
+
+	job := domain.NewReverificationJob(req, callerID)
+	if err := s.jobRepo.Create(job); err != nil {
+		if req.IdempotencyKey != "" {
+			// A concurrent request with the same key may have won the insert
+			if existing, _ := s.jobByIdempotencyKey(req.IdempotencyKey); existing != nil {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+
+	entry := domain.NewAuditLog(callerID, domain.AuditBulkReverification, ipAddress, userAgent, domain.AuditMetadata{
+		"jobId":    job.ID,
+		"campusId": job.CampusID,
+	})
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to audit re-verification job %s: %v", job.ID, err)
+	}
+
+	s.launch(job)
+	return job, nil
+}
+
+// GetJob returns a job with its progress
+func (s *ReverificationService) GetJob(ctx context.Context, id uuid.UUID) (*domain.ReverificationJob, error) {
+	job, err := s.jobRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get re-verification job: %w", err)
+	}
+	return job, nil
+}
+
+// Resume restarts a failed job from where it stopped
+func (s *ReverificationService) Resume(ctx context.Context, id uuid.UUID) (*domain.ReverificationJob, error) {
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == domain.JobCompleted {
+		return job, nil
+	}
+
+	job.Status = domain.JobRunning
+	job.LastError = ""
+	job.UpdatedAt = time.Now()
+	if err := s.jobRepo.Update(job); err != nil {
+		return nil, err
+	}
+
+	s.launch(job)
+	return job, nil
+}
+
+// ResumeRunning restarts the jobs interrupted by a shutdown
+func (s *ReverificationService) ResumeRunning(ctx context.Context) error {
+	jobs, err := s.jobRepo.ListRunning()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		s.launch(job)
+	}
+	return nil
+}
+
+// launch runs a copy of the job in the background unless it is already
+// running here; the caller keeps the original to report
+func (s *ReverificationService) launch(original *domain.ReverificationJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[original.ID] {
+		return
+	}
+	s.running[original.ID] = true
+
+	job := new(domain.ReverificationJob)
+	*job = *original
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, job.ID)
+			s.mu.Unlock()
+		}()
+
+		if err := s.run(context.Background(), job); err != nil {
+			log.Printf("Re-verification job %s failed: %v", job.ID, err)
+			job.Fail(err)
+			if err := s.jobRepo.Update(job); err != nil {
+				log.Printf("Failed to record failure of re-verification job %s: %v", job.ID, err)
+			}
+		}
+	}()
+}
+
+// run works through the job's users batch by batch, saving progress after each batch
+func (s *ReverificationService) run(ctx context.Context, job *domain.ReverificationJob) error {
+	for {
+		users, err := s.userRepo.ListUnverified(ctx, job.CampusID, job.Cursor, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			sent, err := s.reverify(ctx, job, user)
+			if err != nil {
+				return err
+			}
+			job.Advance(user.ID, sent)
+		}
+
+		if len(users) < s.config.BatchSize {
+			job.Complete()
+		}
+		if err := s.jobRepo.Update(job); err != nil {
+			return err
+		}
+		if job.Status == domain.JobCompleted {
+			return nil
+		}
+	}
+}
+
+// reverify sends one verification email, waiting for the send rate to allow
+// it. Users already sent one by this job are skipped, so resuming a job
+// never mails anyone twice.
+func (s *ReverificationService) reverify(ctx context.Context, job *domain.ReverificationJob, user *domain.User) (bool, error) {
+	latest, err := s.tokenRepo.GetLatestByUserID(user.ID, domain.PurposeEmail)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if latest != nil && latest.UsedAt == nil && !latest.CreatedAt.Before(job.CreatedAt) {
+		return false, nil
+	}
+
+	for s.config.Rate.Requests > 0 {
+		result := s.limiter.Allow(reverificationLimitKey, s.config.Rate)
+		if result.Allowed {
+			break
+		}
+		select {
+		case <-time.After(result.RetryAfter):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	verification, token, err := s.verifier.issueToken(s.tokenRepo, user)
+	if err != nil {
+		return false, err
+	}
+	if err := s.verifier.requestVerification(ctx, user, verification, token); err != nil {
+		// the link never went out: retire it so a resumed job sends another
+		verification.MarkUsed()
+		if err := s.tokenRepo.Update(verification); err != nil {
+			log.Printf("Failed to retire unsent verification token of user %s: %v", user.ID, err)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *ReverificationService) jobByIdempotencyKey(key string) (*domain.ReverificationJob, error) {
+	job, err := s.jobRepo.GetByIdempotencyKey(key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get re-verification job: %w", err)
+	}
+	return job, nil
+}
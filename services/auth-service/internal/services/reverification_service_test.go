@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// reverifyFixture is a ReverificationService over in-memory repositories
+type reverifyFixture struct {
+	service   *ReverificationService
+	users     *memUserRepo
+	tokens    *memVerificationTokenRepo
+	jobs      *memJobRepo
+	audit     *memAuditRepo
+	publisher *recordingPublisher
+}
+
+func newReverifyFixture(config ReverificationConfig, limiter ratelimit.Limiter, users ...*domain.User) *reverifyFixture {
+	f := &reverifyFixture{
+		users:     newMemUserRepo(users...),
+		tokens:    newMemVerificationTokenRepo(),
+		jobs:      newMemJobRepo(),
+		audit:     &memAuditRepo{},
+		publisher: &recordingPublisher{},
+	}
+	verifier := NewEmailVerificationService(f.users, f.tokens, f.publisher, "https://auth.example.edu", time.Hour, nil, false)
+	f.service = NewReverificationService(f.users, f.tokens, f.jobs, f.audit, verifier, limiter, config)
+	return f
+}
+
+// unverifiedUsers creates n unverified users of the main campus
+func unverifiedUsers(t *testing.T, n int) []*domain.User {
+	t.Helper()
+	users := make([]*domain.User, n)
+	for i := range users {
+		users[i] = newTestUser(t, fmt.Sprintf("student%d@example.edu", i), "password-123")
+	}
+	return users
+}
+
+// runJob creates a job for the request and runs it to the end in the foreground
+func (f *reverifyFixture) runJob(t *testing.T, req domain.ReverificationRequest) (*domain.ReverificationJob, error) {
+	t.Helper()
+	job := domain.NewReverificationJob(req, uuid.New())
+	f.jobs.Create(job)
+	return job, f.service.run(context.Background(), job)
+}
+
+// sentTo returns the users a verification email was requested for
+func (f *reverifyFixture) sentTo() map[uuid.UUID]int {
+	sent := make(map[uuid.UUID]int)
+	for _, event := range f.publisher.ofType(events.EmailVerificationRequested) {
+		userID, _ := event.UserID()
+		sent[userID]++
+	}
+	return sent
+}
+
+func TestReverificationSavesProgressAfterEachBatch(t *testing.T) {
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 2}, nil, unverifiedUsers(t, 5)...)
+
+	job, err := f.runJob(t, domain.ReverificationRequest{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if job.Status != domain.JobCompleted || job.Processed != 5 || job.Sent != 5 {
+		t.Fatalf("job %s, processed %d, sent %d; want completed, 5, 5", job.Status, job.Processed, job.Sent)
+	}
+
+	var processed []int
+	for _, save := range f.jobs.saves {
+		processed = append(processed, save.Processed)
+	}
+	if fmt.Sprint(processed) != "[2 4 5]" {
+		t.Errorf("progress saved at %v users, want [2 4 5]", processed)
+	}
+	if sent := f.sentTo(); len(sent) != 5 {
+		t.Errorf("verification requested for %d users, want 5", len(sent))
+	}
+}
+
+func TestReverificationCompletesOnEmptyBatch(t *testing.T) {
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 2}, nil, unverifiedUsers(t, 4)...)
+
+	job, err := f.runJob(t, domain.ReverificationRequest{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if job.Status != domain.JobCompleted || job.Sent != 4 {
+		t.Errorf("job %s, sent %d; want completed, 4", job.Status, job.Sent)
+	}
+	if last := f.jobs.saves[len(f.jobs.saves)-1]; last.CompletedAt == nil {
+		t.Error("completion was not saved")
+	}
+}
+
+func TestReverificationFiltersUsers(t *testing.T) {
+	users := unverifiedUsers(t, 4)
+	users[0].IsVerified = true
+	users[1].IsActive = false
+	other := "north-campus"
+	users[2].CampusID = &other
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10}, nil, users...)
+
+	campus := "main-campus"
+	if _, err := f.runJob(t, domain.ReverificationRequest{CampusID: &campus}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	sent := f.sentTo()
+	if len(sent) != 1 || sent[users[3].ID] != 1 {
+		t.Errorf("verification requested for %v, want only the unverified active user of the campus", sent)
+	}
+}
+
+func TestReverificationIsRateLimited(t *testing.T) {
+	rate := ratelimit.Limit{Requests: 2, Window: 100 * time.Millisecond}
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10, Rate: rate}, ratelimit.NewMemoryLimiter(), unverifiedUsers(t, 5)...)
+
+	start := time.Now()
+	job, err := f.runJob(t, domain.ReverificationRequest{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// a burst of 2, then 3 more at one per 50ms
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("5 emails sent in %s, faster than 2 per 100ms", elapsed)
+	}
+	if job.Sent != 5 {
+		t.Errorf("sent %d, want 5", job.Sent)
+	}
+}
+
+func TestReverificationSharesSendRateWithOtherJobs(t *testing.T) {
+	rate := ratelimit.Limit{Requests: 3, Window: time.Hour}
+	limiter := ratelimit.NewMemoryLimiter()
+	limiter.Allow(reverificationLimitKey, rate)
+	limiter.Allow(reverificationLimitKey, rate)
+
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10, Rate: rate}, limiter, unverifiedUsers(t, 2)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job := domain.NewReverificationJob(domain.ReverificationRequest{}, uuid.New())
+	f.jobs.Create(job)
+
+	done := make(chan error, 1)
+	go func() { done <- f.service.run(ctx, job) }()
+	select {
+	case err := <-done:
+		t.Fatalf("job finished (%v) beyond the rate used up by earlier sends", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("run = %v after cancelling, want context.Canceled", err)
+	}
+	if sent := len(f.publisher.ofType(events.EmailVerificationRequested)); sent != 1 {
+		t.Errorf("sent %d emails, want only the 1 left in the shared budget", sent)
+	}
+}
+
+func TestResumedReverificationDoesNotResend(t *testing.T) {
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 2}, nil, unverifiedUsers(t, 3)...)
+
+	job, err := f.runJob(t, domain.ReverificationRequest{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// restart from scratch, as if the progress of every batch had been lost
+	job.Cursor = uuid.Nil
+	job.Status = domain.JobRunning
+	if err := f.service.run(context.Background(), job); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	for userID, count := range f.sentTo() {
+		if count != 1 {
+			t.Errorf("user %s was sent %d emails, want 1", userID, count)
+		}
+	}
+}
+
+func TestResumedReverificationRetriesFailedSend(t *testing.T) {
+	users := unverifiedUsers(t, 3)
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10}, nil, users...)
+	f.publisher.err = errors.New("broker unavailable")
+
+	job, err := f.runJob(t, domain.ReverificationRequest{})
+	if err == nil {
+		t.Fatal("run succeeded while the broker was unavailable")
+	}
+	job.Fail(err)
+
+	f.publisher.err = nil
+	job.Status = domain.JobRunning
+	if err := f.service.run(context.Background(), job); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if sent := f.sentTo(); len(sent) != 3 {
+		t.Errorf("verification requested for %d users after resuming, want 3", len(sent))
+	}
+}
+
+func TestStartWithIdempotencyKeyReturnsExistingJob(t *testing.T) {
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10}, nil, unverifiedUsers(t, 2)...)
+	ctx := context.Background()
+	req := domain.ReverificationRequest{IdempotencyKey: "incident-42"}
+
+	first, err := f.service.Start(ctx, uuid.New(), req, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitForJob(t, f.service, first.ID)
+
+	second, err := f.service.Start(ctx, uuid.New(), req, "192.0.2.1", "test-agent")
+	if err != nil {
+		t.Fatalf("repeated Start: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("repeated Start created job %s, want %s", second.ID, first.ID)
+	}
+	if entries := f.audit.ofAction(domain.AuditBulkReverification); len(entries) != 1 {
+		t.Errorf("%d audit entries, want 1", len(entries))
+	}
+	if sent := len(f.publisher.ofType(events.EmailVerificationRequested)); sent != 2 {
+		t.Errorf("sent %d emails, want 2", sent)
+	}
+}
+
+func TestStartRejectsMalformedCampusID(t *testing.T) {
+	f := newReverifyFixture(ReverificationConfig{BatchSize: 10}, nil, unverifiedUsers(t, 1)...)
+	malformed := "north campus"
+
+	_, err := f.service.Start(context.Background(), uuid.New(), domain.ReverificationRequest{CampusID: &malformed}, "", "")
+	var validation domain.ValidationError
+	if !errors.As(err, &validation) || validation.Field != "campus_id" {
+		t.Errorf("Start = %v, want a ValidationError on campus_id", err)
+	}
+	if len(f.jobs.jobs) != 0 || len(f.audit.entries) != 0 {
+		t.Error("a job was started for a malformed campus")
+	}
+}
+
+// waitForJob waits for a background job to complete
+func waitForJob(t *testing.T, s *ReverificationService, id uuid.UUID) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := s.GetJob(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if job.Status == domain.JobCompleted {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not complete", id)
+}
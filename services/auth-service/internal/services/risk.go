@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// NetworkReputationProvider flags IP addresses belonging to Tor exit nodes, VPNs or proxies
+type NetworkReputationProvider interface {
+	IsAnonymizing(ctx context.Context, ipAddress string) (bool, error)
+}
+
+// TravelAnalyzer flags logins that are geographically implausible given the previous session
+type TravelAnalyzer interface {
+	IsImpossibleTravel(ctx context.Context, userID uuid.UUID, previous *domain.Session, ipAddress string) (bool, error)
+}
+
+// GeoResolver locates IP addresses, such as with a GeoIP database
+type GeoResolver interface {
+	// Locate returns where the address is, or nil when it is unknown
+	Locate(ctx context.Context, ipAddress string) (*domain.GeoLocation, error)
+}
+
+// NoopNetworkReputation never flags an address
+type NoopNetworkReputation struct{}
+
+// IsAnonymizing always reports false
+func (NoopNetworkReputation) IsAnonymizing(ctx context.Context, ipAddress string) (bool, error) {
+	return false, nil
+}
+
+// NoopTravelAnalyzer never flags a login
+type NoopTravelAnalyzer struct{}
+
+// IsImpossibleTravel always reports false
+func (NoopTravelAnalyzer) IsImpossibleTravel(ctx context.Context, userID uuid.UUID, previous *domain.Session, ipAddress string) (bool, error) {
+	return false, nil
+}
+
+// NoopGeoResolver locates no address
+type NoopGeoResolver struct{}
+
+// Locate always reports the address unknown
+func (NoopGeoResolver) Locate(ctx context.Context, ipAddress string) (*domain.GeoLocation, error) {
+	return nil, nil
+}
+
+// RiskAssessor gathers the risk signals of a new session
+type RiskAssessor struct {
+	network NetworkReputationProvider
+	travel  TravelAnalyzer
+	geo     GeoResolver
+}
+
+// NewRiskAssessor creates a RiskAssessor; nil providers fall back to no-op implementations
+func NewRiskAssessor(network NetworkReputationProvider, travel TravelAnalyzer, geo GeoResolver) *RiskAssessor {
+	if network == nil {
+		network = NoopNetworkReputation{}
+	}
+	if travel == nil {
+		travel = NoopTravelAnalyzer{}
+	}
+	if geo == nil {
+		geo = NoopGeoResolver{}
+	}
+	return &RiskAssessor{network: network, travel: travel, geo: geo}
+}
+
+// Assess computes the signals for a login from ipAddress/userAgent given the
+// user's previous sessions (newest first). Provider failures are logged and
+// treated as "not flagged" so risk scoring never blocks a login.
+func (a *RiskAssessor) Assess(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string, previous []*domain.Session) domain.RiskSignals {
+	// A first login has no baseline to compare against, so it is not a "new" device
+	signals := domain.RiskSignals{NewDevice: len(previous) > 0}
+	for _, session := range previous {
+		if session.UserAgent == userAgent {
+			signals.NewDevice = false
+			break
+		}
+	}
+
+	anonymizing, err := a.network.IsAnonymizing(ctx, ipAddress)
+	if err != nil {
+		log.Printf("Network reputation lookup failed: %v", err)
+	}
+	signals.AnonymizingNetwork = anonymizing
+
+	if len(previous) > 0 {
+		impossible, err := a.travel.IsImpossibleTravel(ctx, userID, previous[0], ipAddress)
+		if err != nil {
+			log.Printf("Impossible travel check failed: %v", err)
+		}
+		signals.ImpossibleTravel = impossible
+	}
+
+	return signals
+}
+
+const (
+	// familiarLoginWindow is how far back the logins a new one is compared
+	// with go
+	familiarLoginWindow = 90 * 24 * time.Hour
+	// maxFamiliarOrigins caps the past logins compared with, and so the
+	// addresses located, per login
+	maxFamiliarOrigins = 20
+	// geoLookupTimeout bounds the location lookups of one login
+	geoLookupTimeout = 2 * time.Second
+)
+
+// familiarOrigins returns where the user recently logged in from: their last
+// login and their sessions opened within familiarLoginWindow, newest first
+func familiarOrigins(last *domain.LoginOrigin, previous []*domain.Session) []domain.LoginOrigin {
+	since := time.Now().Add(-familiarLoginWindow)
+	var origins []domain.LoginOrigin
+	if last != nil && last.At.After(since) {
+		origins = append(origins, *last)
+	}
+	for _, session := range previous {
+		if len(origins) == maxFamiliarOrigins {
+			break
+		}
+		if session.CreatedAt.After(since) {
+			origins = append(origins, domain.LoginOrigin{IPAddress: session.IPAddress, UserAgent: session.UserAgent, At: session.CreatedAt})
+		}
+	}
+	return origins
+}
+
+// Unfamiliar compares a login with the familiar origins and returns what is
+// new about it, or nil when nothing is. With no familiar origins there is no
+// baseline, so nothing is new. A location is only new when the address was
+// located and none of the familiar addresses is in the same place; lookup
+// failures are logged and count as familiar, so an outage raises no alerts.
+func (a *RiskAssessor) Unfamiliar(ctx context.Context, ipAddress, userAgent string, familiar []domain.LoginOrigin) *domain.SuspiciousLogin {
+	if len(familiar) == 0 {
+		return nil
+	}
+
+	login := &domain.SuspiciousLogin{NewDevice: true}
+	knownAddress := false
+	for _, origin := range familiar {
+		if origin.UserAgent == userAgent {
+			login.NewDevice = false
+		}
+		if origin.IPAddress == ipAddress {
+			knownAddress = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, geoLookupTimeout)
+	defer cancel()
+	location, err := a.geo.Locate(ctx, ipAddress)
+	if err != nil {
+		log.Printf("Geolocation lookup failed: %v", err)
+	}
+	login.Location = location
+	if location != nil && !knownAddress {
+		login.NewLocation = a.isNewLocation(ctx, *location, familiar)
+	}
+
+	if !login.NewDevice && !login.NewLocation {
+		return nil
+	}
+	return login
+}
+
+// isNewLocation reports whether none of the familiar addresses is located
+// at the location
+func (a *RiskAssessor) isNewLocation(ctx context.Context, location domain.GeoLocation, familiar []domain.LoginOrigin) bool {
+	located := make(map[string]bool, len(familiar))
+	for _, origin := range familiar {
+		if located[origin.IPAddress] {
+			continue
+		}
+		located[origin.IPAddress] = true
+
+		previous, err := a.geo.Locate(ctx, origin.IPAddress)
+		if err != nil {
+			log.Printf("Geolocation lookup failed: %v", err)
+			return false
+		}
+		if previous != nil && *previous == location {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+type fakeNetworkReputation struct {
+	anonymizing bool
+	err         error
+}
+
+func (f fakeNetworkReputation) IsAnonymizing(context.Context, string) (bool, error) {
+	return f.anonymizing, f.err
+}
+
+type fakeTravelAnalyzer struct {
+	impossible bool
+	err        error
+	called     *bool
+}
+
+func (f fakeTravelAnalyzer) IsImpossibleTravel(context.Context, uuid.UUID, *domain.Session, string) (bool, error) {
+	if f.called != nil {
+		*f.called = true
+	}
+	return f.impossible, f.err
+}
+
+func TestRiskAssessorAssess(t *testing.T) {
+	laptop := &domain.Session{UserAgent: "laptop"}
+	failure := errors.New("provider down")
+
+	tests := []struct {
+		name     string
+		network  NetworkReputationProvider
+		travel   TravelAnalyzer
+		agent    string
+		previous []*domain.Session
+		want     domain.RiskSignals
+	}{
+		{"first login", nil, nil, "laptop", nil, domain.RiskSignals{}},
+		{"known device", nil, nil, "laptop", []*domain.Session{laptop}, domain.RiskSignals{}},
+		{"new device", nil, nil, "phone", []*domain.Session{laptop}, domain.RiskSignals{NewDevice: true}},
+		{"anonymizing network", fakeNetworkReputation{anonymizing: true}, nil, "laptop", []*domain.Session{laptop}, domain.RiskSignals{AnonymizingNetwork: true}},
+		{"impossible travel", nil, fakeTravelAnalyzer{impossible: true}, "laptop", []*domain.Session{laptop}, domain.RiskSignals{ImpossibleTravel: true}},
+		{"every signal", fakeNetworkReputation{anonymizing: true}, fakeTravelAnalyzer{impossible: true}, "phone", []*domain.Session{laptop},
+			domain.RiskSignals{NewDevice: true, AnonymizingNetwork: true, ImpossibleTravel: true}},
+		{"failing providers", fakeNetworkReputation{err: failure}, fakeTravelAnalyzer{err: failure}, "laptop", []*domain.Session{laptop}, domain.RiskSignals{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessor := NewRiskAssessor(tt.network, tt.travel, nil)
+			got := assessor.Assess(context.Background(), uuid.New(), "192.0.2.1", tt.agent, tt.previous)
+			if got != tt.want {
+				t.Errorf("Assess = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskAssessorSkipsTravelWithoutPreviousSession(t *testing.T) {
+	called := false
+	assessor := NewRiskAssessor(nil, fakeTravelAnalyzer{impossible: true, called: &called}, nil)
+
+	if got := assessor.Assess(context.Background(), uuid.New(), "192.0.2.1", "laptop", nil); got.ImpossibleTravel {
+		t.Errorf("Assess = %+v", got)
+	}
+	if called {
+		t.Error("travel was analyzed without a previous session to compare with")
+	}
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// publishSecurityChange alerts the user to a security change already made.
+// A failed publish is only logged: the change stands either way.
+func publishSecurityChange(ctx context.Context, publisher events.Publisher, user *domain.User, change domain.SecurityChange, ipAddress, userAgent string) {
+	if err := publisher.Publish(ctx, securityChangedEvent(user, change, ipAddress, userAgent)); err != nil {
+		log.Printf("Failed to publish %s event for %s of user %s: %v", events.UserSecurityChanged, change, user.ID, err)
+	}
+}
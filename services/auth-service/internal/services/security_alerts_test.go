@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// lastData returns the payload of the latest event of the type
+func lastData(t *testing.T, publisher *recordingPublisher, eventType string) interface{} {
+	t.Helper()
+	published := publisher.ofType(eventType)
+	if len(published) == 0 {
+		t.Fatalf("no %s event published", eventType)
+	}
+	return published[len(published)-1].Data
+}
+
+// Every sensitive change alerts the user once, critical changes whatever
+// their notification preferences
+func TestSensitiveChangesAlertUser(t *testing.T) {
+	const ip, userAgent = "192.0.2.7", "alert-agent"
+
+	tests := []struct {
+		name   string
+		change domain.SecurityChange
+		// totp enables 2FA on the user before the change
+		totp   bool
+		mutate func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error
+		// recipients are the verified addresses after the change
+		recipients []string
+	}{
+		{
+			name:   "password change",
+			change: domain.SecurityPasswordChanged,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewUserService(users, newMemTransactor(), publisher, nil, domain.PasswordPolicy{}, domain.Peppers{}, domain.TimezoneDefaults{}, 0, nil, ratelimit.Limit{}, nil, &ratelimit.Policy{}, nil, false, nil, nil)
+				return service.ChangePassword(ctx, user.ID, domain.PasswordChange{CurrentPassword: "password-123", NewPassword: "new-password-456"}, ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "password reset",
+			change: domain.SecurityPasswordReset,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				resets := newMemResetRepo()
+				resets.Create(domain.NewPasswordReset(user.ID, "reset-token", farFuture()))
+				service := newResetService(users, resets, newMemSessionRepo(), publisher, nil, domain.PasswordPolicy{})
+				return service.ResetPassword(ctx, domain.PasswordResetConfirm{Token: "reset-token", NewPassword: "new-password-456"}, ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "email change",
+			change: domain.SecurityEmailChanged,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewEmailChangeService(users, newMemVerificationTokenRepo(), publisher, "https://auth.example.edu", time.Hour)
+				if err := service.RequestEmailChange(ctx, user.ID, "ada.new@example.edu"); err != nil {
+					t.Fatalf("RequestEmailChange: %v", err)
+				}
+				data := lastData(t, publisher, events.EmailChangeRequested).(events.EmailChangeConfirmationData)
+				return service.ConfirmEmailChange(ctx, data.Token, ip, userAgent)
+			},
+			recipients: []string{"ada.new@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "phone change",
+			change: domain.SecurityPhoneChanged,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewPhoneService(users, newMemVerificationTokenRepo(), publisher, true)
+				if err := service.RequestPhone(ctx, user.ID, testPhone); err != nil {
+					t.Fatalf("RequestPhone: %v", err)
+				}
+				data := lastData(t, publisher, events.PhoneVerificationRequested).(events.PhoneVerificationData)
+				return service.ConfirmPhone(ctx, user.ID, data.Code, ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "recovery email change",
+			change: domain.SecurityRecoveryEmailChanged,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewRecoveryEmailService(users, newMemVerificationTokenRepo(), publisher, "https://auth.example.edu")
+				if err := service.RequestRecoveryEmail(ctx, user.ID, "ada.other@example.org"); err != nil {
+					t.Fatalf("RequestRecoveryEmail: %v", err)
+				}
+				data := lastData(t, publisher, events.RecoveryEmailConfirmationRequested).(events.RecoveryEmailConfirmationData)
+				return service.ConfirmRecoveryEmail(ctx, data.Token, ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.other@example.org"},
+		},
+		{
+			name:   "2FA enabled",
+			change: domain.SecurityTwoFactorEnabled,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewTwoFactorService(users, newMemDeviceRepo(), &memMFAMethodRepo{}, publisher, nil)
+				setup, err := service.Setup(ctx, user.ID)
+				if err != nil {
+					t.Fatalf("Setup: %v", err)
+				}
+				return service.Enable(ctx, user.ID, currentTOTP(t, setup.Secret), ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "2FA disabled",
+			change: domain.SecurityTwoFactorDisabled,
+			totp:   true,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				service := NewTwoFactorService(users, newMemDeviceRepo(), &memMFAMethodRepo{}, publisher, nil)
+				return service.Disable(ctx, user.ID, currentTOTP(t, user.TwoFactorSecret), ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+		{
+			name:   "second factor removed",
+			change: domain.SecurityMFAMethodRemoved,
+			mutate: func(t *testing.T, ctx context.Context, users *memUserRepo, publisher *recordingPublisher, user *domain.User) error {
+				methods := &memMFAMethodRepo{}
+				sms := domain.NewMFAMethod(user.ID, domain.MFASMS, "sms", true)
+				methods.Create(sms)
+				service := NewTwoFactorService(users, newMemDeviceRepo(), methods, publisher, nil)
+				return service.RemoveMethod(ctx, user.ID, sms.ID, ip, userAgent)
+			},
+			recipients: []string{"ada@example.edu", "ada.backup@example.org"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			user.IsVerified = true
+			backup := "ada.backup@example.org"
+			user.RecoveryEmail, user.RecoveryEmailVerified = &backup, true
+			if tt.totp {
+				enableTOTP(t, user)
+			}
+			publisher := &recordingPublisher{}
+			before := time.Now()
+
+			if err := tt.mutate(t, context.Background(), newMemUserRepo(user), publisher, user); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+
+			alerts := publisher.ofType(events.UserSecurityChanged)
+			if len(alerts) != 1 {
+				t.Fatalf("published %d %s events, want 1", len(alerts), events.UserSecurityChanged)
+			}
+			if userID, _ := alerts[0].UserID(); userID != user.ID {
+				t.Errorf("alert keyed by user %s, want %s", userID, user.ID)
+			}
+			data := alerts[0].Data.(events.SecurityChangedData)
+			if data.Change != string(tt.change) || data.Critical != tt.change.Critical() || data.UserID != user.ID {
+				t.Errorf("alert of %s, critical %v, user %s; want %s, %v, %s", data.Change, data.Critical, data.UserID, tt.change, tt.change.Critical(), user.ID)
+			}
+			if data.IPAddress != ip || data.UserAgent != userAgent || data.ChangedAt.Before(before.UTC()) {
+				t.Errorf("alert made from %s with %q at %v", data.IPAddress, data.UserAgent, data.ChangedAt)
+			}
+			if !slices.Equal(data.Recipients, tt.recipients) {
+				t.Errorf("alert sent to %v, want %v", data.Recipients, tt.recipients)
+			}
+		})
+	}
+}
+
+func TestSecurityChangeCriticality(t *testing.T) {
+	for _, change := range []domain.SecurityChange{
+		domain.SecurityPasswordChanged, domain.SecurityPasswordReset, domain.SecurityEmailChanged,
+		domain.SecurityTwoFactorDisabled, domain.SecurityMFAMethodRemoved, domain.SecurityRecoveryEmailChanged,
+	} {
+		if !change.Critical() {
+			t.Errorf("%s is not critical", change)
+		}
+	}
+	for _, change := range []domain.SecurityChange{domain.SecurityPhoneChanged, domain.SecurityTwoFactorEnabled} {
+		if change.Critical() {
+			t.Errorf("%s is critical", change)
+		}
+	}
+}
+
+// A refused change alerts nobody
+func TestRefusedChangesDoNotAlert(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	users := newMemUserRepo(user)
+	publisher := &recordingPublisher{}
+	ctx := context.Background()
+
+	userService := NewUserService(users, newMemTransactor(), publisher, nil, domain.PasswordPolicy{}, domain.Peppers{}, domain.TimezoneDefaults{}, 0, nil, ratelimit.Limit{}, nil, &ratelimit.Policy{}, nil, false, nil, nil)
+	if err := userService.ChangePassword(ctx, user.ID, domain.PasswordChange{CurrentPassword: "wrong-password", NewPassword: "new-password-456"}, "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("ChangePassword with a wrong password = %v", err)
+	}
+	phones := NewPhoneService(users, newMemVerificationTokenRepo(), publisher, true)
+	if err := phones.RequestPhone(ctx, user.ID, testPhone); err != nil {
+		t.Fatalf("RequestPhone: %v", err)
+	}
+	if err := phones.ConfirmPhone(ctx, user.ID, "000000", "", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("ConfirmPhone with a wrong code = %v", err)
+	}
+
+	if alerts := publisher.ofType(events.UserSecurityChanged); len(alerts) != 0 {
+		t.Errorf("refused changes published %d alerts", len(alerts))
+	}
+}
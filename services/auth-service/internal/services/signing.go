@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKeys holds the key material used to sign and verify tokens. HMAC
+// keys share one secret; RSA keys sign with a private key and verify with
+// its public key plus any previous public keys still accepted during rotation.
+// Every key is identified by a key ID carried in the kid header of the
+// tokens it signs, so verification picks the key directly. A KeyRotator may
+// swap the keys while they are in use.
+type SigningKeys struct {
+	mu      sync.RWMutex
+	method  jwt.SigningMethod
+	signKey interface{}
+	signKID string
+	verify  []verificationKey
+}
+
+// verificationKey is a key tokens are verified with
+type verificationKey struct {
+	kid string
+	key interface{}
+	// retiresAt is when the key stops verifying; zero keeps it indefinitely
+	retiresAt time.Time
+}
+
+// JWK is a public key in JSON Web Key form, as published in the JWKS
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// NewHMACKeys creates HS256 signing keys from a shared secret
+func NewHMACKeys(secret string) *SigningKeys {
+	key := []byte(secret)
+	return &SigningKeys{
+		method:  jwt.SigningMethodHS256,
+		signKey: key,
+		signKID: keyID(key),
+		verify:  []verificationKey{{kid: keyID(key), key: key}},
+	}
+}
+
+// NewRSAKeys creates RS256 signing keys. Tokens signed by the previous keys
+// keep validating until they expire.
+func NewRSAKeys(private *rsa.PrivateKey, previous ...*rsa.PublicKey) *SigningKeys {
+	keys := &SigningKeys{
+		method:  jwt.SigningMethodRS256,
+		signKey: private,
+		signKID: keyID(&private.PublicKey),
+	}
+	for _, public := range append([]*rsa.PublicKey{&private.PublicKey}, previous...) {
+		keys.verify = append(keys.verify, verificationKey{kid: keyID(public), key: public})
+	}
+	return keys
+}
+
+// LoadRSAKeys reads a PEM private key and optional PEM public keys from disk
+func LoadRSAKeys(privateKeyFile string, publicKeyFiles []string) (*SigningKeys, error) {
+	data, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	var previous []*rsa.PublicKey
+	for _, file := range publicKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %s: %w", file, err)
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", file, err)
+		}
+		previous = append(previous, public)
+	}
+
+	return NewRSAKeys(private, previous...), nil
+}
+
+// Algorithm returns the JWS algorithm tokens are signed with
+func (k *SigningKeys) Algorithm() string {
+	return k.method.Alg()
+}
+
+// PublicKeys returns the active verification keys, or nil for HMAC keys
+func (k *SigningKeys) PublicKeys() []*rsa.PublicKey {
+	var publicKeys []*rsa.PublicKey
+	for _, key := range k.activeKeys(time.Now()) {
+		if public, ok := key.key.(*rsa.PublicKey); ok {
+			publicKeys = append(publicKeys, public)
+		}
+	}
+	return publicKeys
+}
+
+// JWKS returns the active verification keys as JSON Web Keys, or nil for
+// HMAC keys, whose secret cannot be published
+func (k *SigningKeys) JWKS() []JWK {
+	var jwks []JWK
+	for _, key := range k.activeKeys(time.Now()) {
+		public, ok := key.key.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		jwks = append(jwks, JWK{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			KeyID:     key.kid,
+			Modulus:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	return jwks
+}
+
+// Sign signs the claims with the current signing key, naming it in the kid header
+func (k *SigningKeys) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.signKID
+	return token.SignedString(k.signKey)
+}
+
+// Parse verifies the token and fills claims. A token naming a key ID is
+// verified with that key only; tokens signed before key IDs were added are
+// tried against each verification key.
+func (k *SigningKeys) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	_, err := k.parse(tokenString, claims, opts...)
+	return err
+}
+
+// parse is Parse returning the ID of the key that verified the token
+func (k *SigningKeys) parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (string, error) {
+	verifyKeys := k.activeKeys(time.Now())
+	opts = append(opts, jwt.WithValidMethods([]string{k.method.Alg()}))
+
+	var lastErr error = jwt.ErrTokenUnverifiable
+	for _, key := range verifyKeys {
+		key := key
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if kid, ok := token.Header["kid"].(string); ok && kid != key.kid {
+				return nil, jwt.ErrTokenUnverifiable
+			}
+			return key.key, nil
+		}, opts...)
+		if err == nil && token.Valid {
+			return key.kid, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// Rotate makes signKey, an HMAC secret or RSA private key matching the
+// algorithm, the signing key. The previous signing key only verifies from
+// now on, until the longest lived token it signed has expired.
+func (k *SigningKeys) Rotate(signKey interface{}) error {
+	var matches bool
+	switch signKey.(type) {
+	case []byte:
+		matches = k.method == jwt.SigningMethodHS256
+	case *rsa.PrivateKey:
+		matches = k.method == jwt.SigningMethodRS256
+	}
+	if !matches {
+		return fmt.Errorf("signing key %T does not match algorithm %s", signKey, k.method.Alg())
+	}
+	verifyKey := verificationFor(signKey)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	retiresAt := time.Now().Add(maxTokenTTL)
+	verify := make([]verificationKey, 0, len(k.verify)+1)
+	verify = append(verify, verificationKey{kid: keyID(verifyKey), key: verifyKey})
+	for _, key := range k.verify {
+		if key.kid == k.signKID && key.retiresAt.IsZero() {
+			key.retiresAt = retiresAt
+		}
+		verify = append(verify, key)
+	}
+	k.signKey = signKey
+	k.signKID = keyID(verifyKey)
+	k.verify = verify
+	return nil
+}
+
+// activeKeys returns the verification keys not retired at now
+func (k *SigningKeys) activeKeys(now time.Time) []verificationKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	active := make([]verificationKey, 0, len(k.verify))
+	for _, key := range k.verify {
+		if key.retiresAt.IsZero() || now.Before(key.retiresAt) {
+			active = append(active, key)
+		}
+	}
+	return active
+}
+
+// replace swaps in new keys of the same algorithm
+func (k *SigningKeys) replace(signKey interface{}, verifyKeys []interface{}) {
+	verify := make([]verificationKey, len(verifyKeys))
+	for i, key := range verifyKeys {
+		verify[i] = verificationKey{kid: keyID(key), key: key}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.signKey = signKey
+	k.signKID = keyID(verificationFor(signKey))
+	k.verify = verify
+}
+
+// keyID identifies a verification key: the RFC 7638 thumbprint of an RSA
+// public key, or the SHA-256 of an HMAC secret
+func keyID(key interface{}) string {
+	var data []byte
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		data = []byte(fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes())))
+	case []byte:
+		data = key
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// verificationFor returns the key verifying the signatures of signKey
+func verificationFor(signKey interface{}) interface{} {
+	if private, ok := signKey.(*rsa.PrivateKey); ok {
+		return &private.PublicKey
+	}
+	return signKey
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return key
+}
+
+func testClaims() *jwt.RegisteredClaims {
+	return &jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadRSAKeysAcceptsPreviousKeys(t *testing.T) {
+	current := generateRSAKey(t)
+	previous := generateRSAKey(t)
+	dir := t.TempDir()
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&previous.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	keys, err := LoadRSAKeys(
+		writePEM(t, dir, "current.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(current)),
+		[]string{writePEM(t, dir, "previous.pub", "PUBLIC KEY", publicDER)},
+	)
+	if err != nil {
+		t.Fatalf("LoadRSAKeys: %v", err)
+	}
+	if keys.Algorithm() != "RS256" {
+		t.Errorf("algorithm = %s, want RS256", keys.Algorithm())
+	}
+	if got := keys.PublicKeys(); len(got) != 2 || !got[0].Equal(&current.PublicKey) || !got[1].Equal(&previous.PublicKey) {
+		t.Errorf("public keys are not the current key followed by the previous one")
+	}
+
+	signed, err := keys.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := keys.Parse(signed, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the current key: %v", err)
+	}
+
+	// tokens signed before the rotation keep validating
+	old, err := NewRSAKeys(previous).Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := keys.Parse(old, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the previous key: %v", err)
+	}
+
+	foreign, err := NewRSAKeys(generateRSAKey(t)).Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := keys.Parse(foreign, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("token of an unknown key validated")
+	}
+}
+
+func TestLoadRSAKeysReportsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	private := writePEM(t, dir, "current.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(generateRSAKey(t)))
+
+	if _, err := LoadRSAKeys(filepath.Join(dir, "missing.pem"), nil); err == nil {
+		t.Error("a missing private key file was accepted")
+	}
+	if _, err := LoadRSAKeys(garbage, nil); err == nil {
+		t.Error("an unparsable private key was accepted")
+	}
+	if _, err := LoadRSAKeys(private, []string{garbage}); err == nil {
+		t.Error("an unparsable public key was accepted")
+	}
+}
+
+func TestHMACKeysPublishNoPublicKeys(t *testing.T) {
+	keys := NewHMACKeys("test-secret")
+	if keys.PublicKeys() != nil || keys.JWKS() != nil {
+		t.Error("HMAC keys published their secret as a public key")
+	}
+
+	// an RS256 token must not pass as HS256 with the public key as secret
+	rsaToken, err := NewRSAKeys(generateRSAKey(t)).Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := keys.Parse(rsaToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("an RS256 token validated against HMAC keys")
+	}
+}
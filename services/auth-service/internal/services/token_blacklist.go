@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// blacklistSweepInterval is how often a MemoryBlacklist drops the entries
+// of tokens that have expired anyway
+const blacklistSweepInterval = time.Minute
+
+// TokenBlacklist records access tokens revoked before their expiry, by the
+// jti claim or by user, for as long as they would otherwise stay valid
+type TokenBlacklist interface {
+	// Revoke blacklists the token with the jti until exp
+	Revoke(jti string, exp time.Time) error
+	// IsRevoked reports whether the token with the jti is blacklisted
+	IsRevoked(jti string) (bool, error)
+	// RevokeUser blacklists every token of the user issued up to cutoff,
+	// until exp, by which all of them have expired
+	RevokeUser(userID string, cutoff, exp time.Time) error
+	// IsUserRevoked reports whether a token of the user issued at iat is
+	// blacklisted
+	IsUserRevoked(userID string, iat time.Time) (bool, error)
+}
+
+// userCutoff revokes the tokens of a user issued up to cutoff
+type userCutoff struct {
+	cutoff time.Time
+	exp    time.Time
+}
+
+// MemoryBlacklist is an in-process TokenBlacklist. Each instance only knows
+// the tokens revoked through it, and forgets them on restart, so it suits
+// single instance deployments; RedisBlacklist shares them.
+type MemoryBlacklist struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	users     map[string]userCutoff
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryBlacklist creates an empty in-memory blacklist
+func NewMemoryBlacklist() *MemoryBlacklist {
+	return &MemoryBlacklist{
+		entries:   make(map[string]time.Time),
+		users:     make(map[string]userCutoff),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Revoke blacklists the jti until exp; tokens already expired are ignored
+func (b *MemoryBlacklist) Revoke(jti string, exp time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if exp.After(now) {
+		b.entries[jti] = exp
+	}
+	b.sweep(now)
+	return nil
+}
+
+// IsRevoked reports whether the jti is blacklisted and not yet expired
+func (b *MemoryBlacklist) IsRevoked(jti string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	exp, ok := b.entries[jti]
+	return ok && b.now().Before(exp), nil
+}
+
+// RevokeUser blacklists the user's tokens issued up to cutoff until exp. JWT
+// issue times have second precision, so tokens issued later in the second of
+// cutoff are revoked too. A later revocation replaces an earlier one.
+func (b *MemoryBlacklist) RevokeUser(userID string, cutoff, exp time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if exp.After(now) {
+		b.users[userID] = userCutoff{cutoff: cutoff.Truncate(time.Second), exp: exp}
+	}
+	b.sweep(now)
+	return nil
+}
+
+// IsUserRevoked reports whether the user's tokens issued at iat are revoked
+func (b *MemoryBlacklist) IsUserRevoked(userID string, iat time.Time) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	revocation, ok := b.users[userID]
+	return ok && b.now().Before(revocation.exp) && !iat.After(revocation.cutoff), nil
+}
+
+// sweep drops the entries of expired tokens, which fail validation anyway
+func (b *MemoryBlacklist) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < blacklistSweepInterval {
+		return
+	}
+	for jti, exp := range b.entries {
+		if !now.Before(exp) {
+			delete(b.entries, jti)
+		}
+	}
+	for userID, revocation := range b.users {
+		if !now.Before(revocation.exp) {
+			delete(b.users, userID)
+		}
+	}
+	b.lastSweep = now
+}
+
+// redisBlacklistTimeout bounds a round trip to Redis; tokens are checked on
+// every authenticated request
+const redisBlacklistTimeout = 250 * time.Millisecond
+
+// RedisBlacklist is a TokenBlacklist kept in Redis, so every instance of the
+// service sees the revocations of the others and they survive restarts.
+// Each entry expires with the tokens it revokes. Errors are returned rather
+// than hidden, so an unreachable Redis rejects tokens instead of accepting
+// revoked ones.
+type RedisBlacklist struct {
+	client *redis.Client
+}
+
+// NewRedisBlacklist creates a blacklist for the Redis server at rawURL, e.g.
+// redis://localhost:6379/0. A password or database in the URL takes
+// precedence over the password and db given.
+func NewRedisBlacklist(rawURL, password string, db int) (*RedisBlacklist, error) {
+	options, err := ratelimit.RedisOptions(rawURL, password, db)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBlacklist{client: redis.NewClient(options)}, nil
+}
+
+// Close closes the connections to Redis
+func (b *RedisBlacklist) Close() error {
+	return b.client.Close()
+}
+
+// Revoke blacklists the jti until exp; tokens already expired are ignored
+func (b *RedisBlacklist) Revoke(jti string, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisBlacklistTimeout)
+	defer cancel()
+	return b.client.Set(ctx, "blacklist:jti:"+jti, 1, ttl).Err()
+}
+
+// IsRevoked reports whether the jti is blacklisted and not yet expired
+func (b *RedisBlacklist) IsRevoked(jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisBlacklistTimeout)
+	defer cancel()
+	n, err := b.client.Exists(ctx, "blacklist:jti:"+jti).Result()
+	return n > 0, err
+}
+
+// RevokeUser blacklists the user's tokens issued up to cutoff until exp. JWT
+// issue times have second precision, so tokens issued later in the second of
+// cutoff are revoked too. A later revocation replaces an earlier one.
+func (b *RedisBlacklist) RevokeUser(userID string, cutoff, exp time.Time) error {
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisBlacklistTimeout)
+	defer cancel()
+	return b.client.Set(ctx, "blacklist:user:"+userID, cutoff.Unix(), ttl).Err()
+}
+
+// IsUserRevoked reports whether the user's tokens issued at iat are revoked
+func (b *RedisBlacklist) IsUserRevoked(userID string, iat time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisBlacklistTimeout)
+	defer cancel()
+	raw, err := b.client.Get(ctx, "blacklist:user:"+userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	cutoff, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid revocation cutoff %q of user %s", raw, userID)
+	}
+	return !iat.After(time.Unix(cutoff, 0)), nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// blacklists returns each TokenBlacklist with a function moving its clock
+// forward, so entries can be seen expiring
+func blacklists(t *testing.T) map[string]func() (TokenBlacklist, func(time.Duration)) {
+	return map[string]func() (TokenBlacklist, func(time.Duration)){
+		"memory": func() (TokenBlacklist, func(time.Duration)) {
+			b := NewMemoryBlacklist()
+			now := time.Now()
+			b.now = func() time.Time { return now }
+			return b, func(d time.Duration) { now = now.Add(d) }
+		},
+		"redis": func() (TokenBlacklist, func(time.Duration)) {
+			server := miniredis.RunT(t)
+			b, err := NewRedisBlacklist("redis://"+server.Addr(), "", 0)
+			if err != nil {
+				t.Fatalf("NewRedisBlacklist: %v", err)
+			}
+			t.Cleanup(func() { b.Close() })
+			return b, server.FastForward
+		},
+	}
+}
+
+func TestBlacklistRevoke(t *testing.T) {
+	for name, newBlacklist := range blacklists(t) {
+		t.Run(name, func(t *testing.T) {
+			b, advance := newBlacklist()
+			if err := b.Revoke("jti-1", time.Now().Add(time.Minute)); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+			if err := b.Revoke("jti-expired", time.Now().Add(-time.Minute)); err != nil {
+				t.Fatalf("Revoke of an expired token: %v", err)
+			}
+
+			for jti, want := range map[string]bool{"jti-1": true, "jti-2": false, "jti-expired": false} {
+				if revoked, err := b.IsRevoked(jti); err != nil || revoked != want {
+					t.Errorf("IsRevoked(%s) = %v, %v, want %v", jti, revoked, err, want)
+				}
+			}
+
+			// the entry lasts as long as the token would have
+			advance(2 * time.Minute)
+			if revoked, err := b.IsRevoked("jti-1"); err != nil || revoked {
+				t.Errorf("IsRevoked after the token expired = %v, %v, want false", revoked, err)
+			}
+		})
+	}
+}
+
+func TestBlacklistRevokeUser(t *testing.T) {
+	for name, newBlacklist := range blacklists(t) {
+		t.Run(name, func(t *testing.T) {
+			b, advance := newBlacklist()
+			cutoff := time.Now()
+			if err := b.RevokeUser("user-1", cutoff, cutoff.Add(15*time.Minute)); err != nil {
+				t.Fatalf("RevokeUser: %v", err)
+			}
+
+			tests := []struct {
+				name   string
+				userID string
+				iat    time.Time
+				want   bool
+			}{
+				{"issued before the cutoff", "user-1", cutoff.Add(-time.Minute), true},
+				{"issued in the second of the cutoff", "user-1", cutoff.Truncate(time.Second), true},
+				{"issued after the cutoff", "user-1", cutoff.Add(2 * time.Second), false},
+				{"another user", "user-2", cutoff.Add(-time.Minute), false},
+			}
+			for _, tt := range tests {
+				if revoked, err := b.IsUserRevoked(tt.userID, tt.iat); err != nil || revoked != tt.want {
+					t.Errorf("%s: IsUserRevoked = %v, %v, want %v", tt.name, revoked, err, tt.want)
+				}
+			}
+
+			// a later revocation moves the cutoff
+			later := cutoff.Add(time.Hour)
+			if err := b.RevokeUser("user-1", later, later.Add(15*time.Minute)); err != nil {
+				t.Fatalf("RevokeUser: %v", err)
+			}
+			if revoked, _ := b.IsUserRevoked("user-1", cutoff.Add(2*time.Second)); !revoked {
+				t.Error("token issued before the later cutoff not revoked")
+			}
+
+			advance(2 * time.Hour)
+			if revoked, err := b.IsUserRevoked("user-1", cutoff.Add(-time.Minute)); err != nil || revoked {
+				t.Errorf("IsUserRevoked after every token expired = %v, %v, want false", revoked, err)
+			}
+		})
+	}
+}
+
+// Revocations through one instance reach every other sharing the Redis
+func TestRedisBlacklistSharedAcrossInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	first, _ := NewRedisBlacklist("redis://"+server.Addr(), "", 0)
+	defer first.Close()
+	second, _ := NewRedisBlacklist("redis://"+server.Addr(), "", 0)
+	defer second.Close()
+
+	first.Revoke("jti-1", time.Now().Add(time.Minute))
+	first.RevokeUser("user-1", time.Now(), time.Now().Add(time.Minute))
+
+	if revoked, err := second.IsRevoked("jti-1"); err != nil || !revoked {
+		t.Errorf("jti revoked by another instance = %v, %v, want true", revoked, err)
+	}
+	if revoked, err := second.IsUserRevoked("user-1", time.Now().Add(-time.Minute)); err != nil || !revoked {
+		t.Errorf("user revoked by another instance = %v, %v, want true", revoked, err)
+	}
+	if ttl := server.TTL("blacklist:jti:jti-1"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("entry expires in %v, want with the token", ttl)
+	}
+}
+
+// An unreachable Redis fails the check rather than accepting revoked tokens
+func TestRedisBlacklistUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	b, _ := NewRedisBlacklist("redis://"+server.Addr(), "", 0)
+	defer b.Close()
+	server.Close()
+
+	if err := b.Revoke("jti-1", time.Now().Add(time.Minute)); err == nil {
+		t.Error("Revoke without Redis succeeded")
+	}
+	if _, err := b.IsRevoked("jti-1"); err == nil {
+		t.Error("IsRevoked without Redis succeeded")
+	}
+	if _, err := b.IsUserRevoked("user-1", time.Now()); err == nil {
+		t.Error("IsUserRevoked without Redis succeeded")
+	}
+}
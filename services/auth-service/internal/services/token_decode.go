@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// TokenDecoding describes an access token in full, for administrators
+// debugging integrations
+type TokenDecoding struct {
+	// Format is "jwt" or "opaque"
+	Format string `json:"format"`
+	// Header, Algorithm and KeyID describe the signature of a JWT; KeyID is
+	// the key that verified it
+	Header    map[string]interface{} `json:"header,omitempty"`
+	Algorithm string                 `json:"alg,omitempty"`
+	KeyID     string                 `json:"kid,omitempty"`
+	Claims    map[string]interface{} `json:"claims"`
+	// Valid is set when the token would be accepted now; Reason tells why
+	// it would not
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+	// Session is the state of the session named by the sid claim
+	Session *TokenSession `json:"session,omitempty"`
+}
+
+// TokenSession is the state of the session an access token belongs to
+type TokenSession struct {
+	ID string `json:"id"`
+	// Status is "active", "revoked", "expired" or "not_found"
+	Status     string     `json:"status"`
+	UserID     string     `json:"user_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Decode verifies an access token of any format and returns everything it
+// carries. Unlike Validate, a correctly signed token that expired or was
+// revoked is still decoded, with the reason it is invalid; a bad signature
+// is an error. Nothing is recorded about the token.
+func (t *AccessTokens) Decode(ctx context.Context, tokenString string) (*TokenDecoding, error) {
+	var decoding *TokenDecoding
+	var err error
+	if isJWT(tokenString) {
+		decoding, err = t.decodeJWT(tokenString)
+	} else {
+		decoding, err = t.decodeOpaque(tokenString)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoding.Reason, err = t.invalidReason(jwt.MapClaims(decoding.Claims))
+	if err != nil {
+		return nil, err
+	}
+	decoding.Valid = decoding.Reason == ""
+	return decoding, nil
+}
+
+// decodeJWT checks the signature of a JWT against the keys of both
+// algorithms, leaving the time based claims to invalidReason
+func (t *AccessTokens) decodeJWT(tokenString string) (*TokenDecoding, error) {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	kid, err := t.keys.parse(tokenString, jwt.MapClaims{}, jwt.WithoutClaimsValidation())
+	if err != nil && t.alternate != nil {
+		kid, err = t.alternate.parse(tokenString, jwt.MapClaims{}, jwt.WithoutClaimsValidation())
+	}
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return &TokenDecoding{
+		Format:    "jwt",
+		Header:    token.Header,
+		Algorithm: token.Method.Alg(),
+		KeyID:     kid,
+		Claims:    token.Claims.(jwt.MapClaims),
+	}, nil
+}
+
+// decodeOpaque looks up the claims stored for an opaque token. Tokens past
+// their expiry or of revoked sessions are gone from the lookup.
+func (t *AccessTokens) decodeOpaque(tokenString string) (*TokenDecoding, error) {
+	stored, err := t.repo.GetActive(domain.HashToken(tokenString), time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(stored.Claims, &claims); err != nil {
+		return nil, fmt.Errorf("failed to decode access token claims: %w", err)
+	}
+	return &TokenDecoding{Format: "opaque", Claims: claims}, nil
+}
+
+// invalidReason tells why a token with correctly signed claims would be
+// rejected now, or returns an empty string when it would be accepted
+func (t *AccessTokens) invalidReason(claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	if exp, err := claims.GetExpirationTime(); err != nil || exp == nil {
+		return "missing or invalid exp claim", nil
+	} else if !now.Before(exp.Time) {
+		return "expired", nil
+	}
+	if nbf, err := claims.GetNotBefore(); err != nil {
+		return "invalid nbf claim", nil
+	} else if nbf != nil && now.Before(nbf.Time) {
+		return "not yet valid", nil
+	}
+
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		revoked, err := t.blacklist.IsRevoked(jti)
+		if err != nil {
+			return "", fmt.Errorf("failed to check token blacklist: %w", err)
+		}
+		if revoked {
+			return "revoked", nil
+		}
+	}
+	userID, _ := claims["user_id"].(string)
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil && userID != "" {
+		revoked, err := t.blacklist.IsUserRevoked(userID, iat.Time)
+		if err != nil {
+			return "", fmt.Errorf("failed to check token blacklist: %w", err)
+		}
+		if revoked {
+			return "revoked", nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// decodeFixture is an AdminService decoding the tokens of an auth fixture
+type decodeFixture struct {
+	*authFixture
+	admin *AdminService
+	audit *memAuditRepo
+	user  *domain.User
+}
+
+func newDecodeFixture(t *testing.T) *decodeFixture {
+	t.Helper()
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	f := &decodeFixture{authFixture: newAuthFixture(t, AuthConfig{}, user), audit: &memAuditRepo{}, user: user}
+	f.admin = &AdminService{userRepo: f.users, sessionRepo: f.sessions, auditRepo: f.audit, tokens: f.service.tokens}
+	return f
+}
+
+// tamper rewrites one claim of a JWT's payload, keeping its signature
+func tamper(t *testing.T, token, claim, value string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	old, _ := claims[claim].(string)
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), old, value, 1)))
+	return strings.Join(parts, ".")
+}
+
+func TestDecodeValidToken(t *testing.T) {
+	f := newDecodeFixture(t)
+	ctx := context.Background()
+	result := f.login(t, f.user.Email, "")
+	session, _ := f.sessions.GetByRefreshToken(ctx, result.RefreshToken)
+
+	decoding, err := f.admin.DecodeToken(ctx, result.AccessToken)
+	if err != nil {
+		t.Fatalf("DecodeToken: %v", err)
+	}
+	if decoding.Format != "jwt" || decoding.Algorithm != "HS256" || !decoding.Valid || decoding.Reason != "" {
+		t.Errorf("decoding = %+v, want a valid HS256 JWT", decoding)
+	}
+	if decoding.KeyID == "" || decoding.Header["kid"] != decoding.KeyID {
+		t.Errorf("key ID %q, header %v, want the kid of the signing key", decoding.KeyID, decoding.Header)
+	}
+	if decoding.Claims["user_id"] != f.user.ID.String() || decoding.Claims["email"] != f.user.Email || decoding.Claims["jti"] == nil {
+		t.Errorf("claims = %v, want every claim of the token", decoding.Claims)
+	}
+	if got := decoding.Session; got == nil || got.ID != session.ID.String() || got.Status != "active" || got.UserID != f.user.ID.String() {
+		t.Errorf("session = %+v, want the active session of the login", got)
+	}
+
+	// decoding has no side effects: nothing is audited and the token still works
+	if len(f.audit.entries) != 0 {
+		t.Errorf("decoding audited %d entries", len(f.audit.entries))
+	}
+	if _, err := f.service.tokens.Validate(ctx, result.AccessToken); err != nil {
+		t.Errorf("token rejected after decoding: %v", err)
+	}
+}
+
+func TestDecodeTamperedToken(t *testing.T) {
+	f := newDecodeFixture(t)
+	token := f.login(t, f.user.Email, "").AccessToken
+	foreign, err := NewHMACKeys("another-secret").Sign(&Claims{UserID: f.user.ID.String(), RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	signature := token[strings.LastIndex(token, ".")+1:]
+	flipped := "A"
+	if signature[0] == 'A' {
+		flipped = "B"
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"changed claim", tamper(t, token, "role", string(domain.RoleAdmin)), ErrInvalidSignature},
+		{"changed signature", token[:len(token)-len(signature)] + flipped + signature[1:], ErrInvalidSignature},
+		{"signed by another key", foreign, ErrInvalidSignature},
+		{"stripped signature", token[:len(token)-len(signature)], ErrInvalidSignature},
+		{"not a JWT", "a.b.c", ErrMalformedToken},
+		{"unknown opaque token", "not-a-token", ErrTokenNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoding, err := f.admin.DecodeToken(context.Background(), tt.token)
+			if !errors.Is(err, tt.want) || decoding != nil {
+				t.Errorf("DecodeToken = %+v, %v, want %v", decoding, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidToken(t *testing.T) {
+	f := newDecodeFixture(t)
+	ctx := context.Background()
+
+	// a correctly signed token is decoded with the reason it is rejected
+	session := domain.NewSession(f.user.ID, "refresh-token", "192.0.2.1", "test-agent", farFuture())
+	f.sessions.Create(ctx, session)
+	expired, err := f.service.tokens.issue(session, accessClaims(session, -time.Minute))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	decoding, err := f.admin.DecodeToken(ctx, expired)
+	if err != nil || decoding.Valid || decoding.Reason != "expired" || decoding.Session.Status != "active" {
+		t.Errorf("expired token decoding = %+v, %v, want invalid as expired", decoding, err)
+	}
+
+	result := f.login(t, f.user.Email, "")
+	if err := f.service.Logout(ctx, result.RefreshToken, result.AccessToken, "192.0.2.1", "test-agent"); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	decoding, err = f.admin.DecodeToken(ctx, result.AccessToken)
+	if err != nil || decoding.Valid || decoding.Reason != "revoked" || decoding.Session.Status != "revoked" {
+		t.Errorf("logged out token decoding = %+v, %v, want revoked with its session", decoding, err)
+	}
+
+	orphan := accessClaims(&domain.Session{ID: uuid.New(), UserID: f.user.ID}, time.Minute)
+	token, _ := f.service.tokens.keys.Sign(&orphan)
+	if decoding, err := f.admin.DecodeToken(ctx, token); err != nil || decoding.Session.Status != "not_found" {
+		t.Errorf("token of an unknown session: %+v, %v, want session not_found", decoding, err)
+	}
+}
+
+func TestDecodeTokenAfterKeyRotation(t *testing.T) {
+	f := newDecodeFixture(t)
+	ctx := context.Background()
+	before := f.login(t, f.user.Email, "").AccessToken
+	if err := f.service.tokens.keys.Rotate([]byte("rotated-secret")); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	after := f.login(t, f.user.Email, "").AccessToken
+
+	old, err := f.admin.DecodeToken(ctx, before)
+	if err != nil || !old.Valid {
+		t.Fatalf("token of the previous key: %+v, %v", old, err)
+	}
+	current, err := f.admin.DecodeToken(ctx, after)
+	if err != nil || !current.Valid {
+		t.Fatalf("token of the current key: %+v, %v", current, err)
+	}
+	if old.KeyID == current.KeyID {
+		t.Errorf("both tokens verified by key %q, want the key each was signed with", old.KeyID)
+	}
+}
+
+func TestDecodeOpaqueToken(t *testing.T) {
+	f := newDecodeFixture(t)
+	ctx := context.Background()
+	session := domain.NewSession(f.user.ID, "refresh-token", "192.0.2.1", "test-agent", farFuture())
+	session.TokenFormat = domain.TokenFormatOpaque
+	f.sessions.Create(ctx, session)
+	claims := accessClaims(session, time.Minute)
+	token, err := f.service.tokens.issue(session, claims)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	decoding, err := f.admin.DecodeToken(ctx, token)
+	if err != nil {
+		t.Fatalf("DecodeToken: %v", err)
+	}
+	if decoding.Format != "opaque" || decoding.KeyID != "" || !decoding.Valid || decoding.Claims["jti"] != claims.ID {
+		t.Errorf("decoding = %+v, want the stored claims of a valid opaque token", decoding)
+	}
+	if decoding.Session == nil || decoding.Session.ID != session.ID.String() {
+		t.Errorf("session = %+v, want the token's", decoding.Session)
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// TwoFactorService manages 2FA enrollment, MFA methods and trusted devices
+type TwoFactorService struct {
+	userRepo   domain.UserRepository
+	deviceRepo domain.TrustedDeviceRepository
+	methodRepo domain.MFAMethodRepository
+	publisher  events.Publisher
+	// requiredRoles are the roles that may not drop their last second factor
+	requiredRoles []domain.Role
+}
+
+// NewTwoFactorService creates a new TwoFactorService. Users holding one of
+// requiredRoles cannot remove their last second factor. Users are alerted
+// through publisher when 2FA is turned on or off or a factor is removed.
+func NewTwoFactorService(userRepo domain.UserRepository, deviceRepo domain.TrustedDeviceRepository, methodRepo domain.MFAMethodRepository, publisher events.Publisher, requiredRoles []domain.Role) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:      userRepo,
+		deviceRepo:    deviceRepo,
+		methodRepo:    methodRepo,
+		publisher:     publisher,
+		requiredRoles: requiredRoles,
+	}
+}
+
+// Setup generates a new authenticator secret for the user. 2FA is only
+// enabled once a code generated from it is confirmed via Enable.
+func (s *TwoFactorService) Setup(ctx context.Context, userID uuid.UUID) (*domain.TwoFactorSetup, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorSecret = secret
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &domain.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(user.Email, secret),
+	}, nil
+}
+
+// Enable turns on 2FA after checking a code from the pending secret
+func (s *TwoFactorService) Enable(ctx context.Context, userID uuid.UUID, code, ipAddress, userAgent string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		return ErrTwoFactorEnabled
+	}
+	if user.TwoFactorSecret == "" || !validateTOTP(user.TwoFactorSecret, code, time.Now()) {
+		return ErrInvalidTwoFactorCode
+	}
+
+	methods, err := s.methodRepo.ListByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+
+	user.EnableTwoFactor()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if err := s.methodRepo.Create(domain.NewMFAMethod(user.ID, domain.MFATOTP, totpMethodLabel, len(methods) == 0)); err != nil {
+		return err
+	}
+
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityTwoFactorEnabled, ipAddress, userAgent)
+	return nil
+}
+
+// Disable turns off 2FA after checking a current code, and forgets every trusted device
+func (s *TwoFactorService) Disable(ctx context.Context, userID uuid.UUID, code, ipAddress, userAgent string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorDisabled
+	}
+	if !validateTOTP(user.TwoFactorSecret, code, time.Now()) {
+		return ErrInvalidTwoFactorCode
+	}
+
+	methods, err := enrolledMethods(s.methodRepo, user)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, method := range methods {
+		if method.Type != domain.MFATOTP {
+			remaining++
+		}
+	}
+	if remaining == 0 && s.mfaRequired(user) {
+		return ErrLastMFAMethod
+	}
+
+	if err := s.disableTOTP(ctx, user); err != nil {
+		return err
+	}
+	if err := s.keepPreferredMethod(user.ID); err != nil {
+		return err
+	}
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityTwoFactorDisabled, ipAddress, userAgent)
+	return nil
+}
+
+// ListMethods returns the user's enrolled second factors, preferred first
+func (s *TwoFactorService) ListMethods(ctx context.Context, userID uuid.UUID) ([]*domain.MFAMethod, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return enrolledMethods(s.methodRepo, user)
+}
+
+// SetPreferredMethod makes one of the user's methods the one offered first at login
+func (s *TwoFactorService) SetPreferredMethod(ctx context.Context, userID, methodID uuid.UUID) error {
+	if _, err := s.getMethod(userID, methodID); err != nil {
+		return err
+	}
+	return s.methodRepo.SetPreferred(userID, methodID)
+}
+
+// RemoveMethod removes one of the user's second factors. The last factor
+// cannot be removed while the user's role requires MFA.
+func (s *TwoFactorService) RemoveMethod(ctx context.Context, userID, methodID uuid.UUID, ipAddress, userAgent string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	methods, err := enrolledMethods(s.methodRepo, user)
+	if err != nil {
+		return err
+	}
+
+	var method *domain.MFAMethod
+	for _, m := range methods {
+		if m.ID == methodID {
+			method = m
+		}
+	}
+	if method == nil {
+		return ErrMFAMethodNotFound
+	}
+	if len(methods) == 1 && s.mfaRequired(user) {
+		return ErrLastMFAMethod
+	}
+
+	change := domain.SecurityMFAMethodRemoved
+	if method.Type == domain.MFATOTP {
+		err = s.disableTOTP(ctx, user)
+		change = domain.SecurityTwoFactorDisabled
+	} else {
+		err = s.methodRepo.Delete(method.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.keepPreferredMethod(user.ID); err != nil {
+		return err
+	}
+
+	publishSecurityChange(ctx, s.publisher, user, change, ipAddress, userAgent)
+	return nil
+}
+
+// disableTOTP removes the authenticator app, turning 2FA off and forgetting
+// every trusted device
+func (s *TwoFactorService) disableTOTP(ctx context.Context, user *domain.User) error {
+	if err := s.methodRepo.DeleteByType(user.ID, domain.MFATOTP); err != nil {
+		return err
+	}
+
+	user.DisableTwoFactor()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	return s.deviceRepo.RevokeAllByUserID(user.ID)
+}
+
+// keepPreferredMethod makes the user's oldest method preferred when the
+// preferred one was removed, so login challenges still offer one first
+func (s *TwoFactorService) keepPreferredMethod(userID uuid.UUID) error {
+	methods, err := s.methodRepo.ListByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if len(methods) == 0 || methods[0].Preferred {
+		return nil
+	}
+	return s.methodRepo.SetPreferred(userID, methods[0].ID)
+}
+
+// mfaRequired reports whether policy requires the user to keep a second factor
+func (s *TwoFactorService) mfaRequired(user *domain.User) bool {
+	for _, role := range s.requiredRoles {
+		if user.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TwoFactorService) getMethod(userID, methodID uuid.UUID) (*domain.MFAMethod, error) {
+	method, err := s.methodRepo.GetByID(methodID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFAMethodNotFound
+		}
+		return nil, fmt.Errorf("failed to get MFA method: %w", err)
+	}
+	if method.UserID != userID {
+		return nil, ErrMFAMethodNotFound
+	}
+	return method, nil
+}
+
+// ListTrustedDevices returns the user's active trusted devices
+func (s *TwoFactorService) ListTrustedDevices(ctx context.Context, userID uuid.UUID) ([]*domain.TrustedDevice, error) {
+	return s.deviceRepo.ListActiveByUserID(userID)
+}
+
+// RevokeTrustedDevice withdraws trust from one of the user's devices
+func (s *TwoFactorService) RevokeTrustedDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	device, err := s.deviceRepo.GetByID(deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTrustedDeviceNotFound
+		}
+		return fmt.Errorf("failed to get trusted device: %w", err)
+	}
+	if device.UserID != userID {
+		return ErrTrustedDeviceNotFound
+	}
+
+	device.Revoke()
+	return s.deviceRepo.Update(device)
+}
+
+// totpMethodLabel names the authenticator app method in method listings
+const totpMethodLabel = "Authenticator app"
+
+// enrolledMethods lists the user's second factors. Users who enabled TOTP
+// before methods were tracked get their method record created on first use.
+func enrolledMethods(methodRepo domain.MFAMethodRepository, user *domain.User) ([]*domain.MFAMethod, error) {
+	methods, err := methodRepo.ListByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return methods, nil
+	}
+	for _, method := range methods {
+		if method.Type == domain.MFATOTP {
+			return methods, nil
+		}
+	}
+
+	method := domain.NewMFAMethod(user.ID, domain.MFATOTP, totpMethodLabel, len(methods) == 0)
+	if err := methodRepo.Create(method); err != nil {
+		return nil, err
+	}
+	return append(methods, method), nil
+}
+
+func (s *TwoFactorService) getUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+type twoFactorFixture struct {
+	service   *TwoFactorService
+	users     *memUserRepo
+	devices   *memDeviceRepo
+	methods   *memMFAMethodRepo
+	publisher *recordingPublisher
+}
+
+// newTwoFactorFixture requires admins to keep a second factor
+func newTwoFactorFixture(users ...*domain.User) *twoFactorFixture {
+	f := &twoFactorFixture{users: newMemUserRepo(users...), devices: newMemDeviceRepo(), methods: &memMFAMethodRepo{}, publisher: &recordingPublisher{}}
+	f.service = NewTwoFactorService(f.users, f.devices, f.methods, f.publisher, []domain.Role{domain.RoleAdmin})
+	return f
+}
+
+// addMethod enrolls a second factor other than TOTP
+func (f *twoFactorFixture) addMethod(t *testing.T, user *domain.User, methodType domain.MFAMethodType, preferred bool) *domain.MFAMethod {
+	t.Helper()
+	method := domain.NewMFAMethod(user.ID, methodType, string(methodType), preferred)
+	if err := f.methods.Create(method); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return method
+}
+
+func methodTypes(methods []*domain.MFAMethod) []domain.MFAMethodType {
+	var types []domain.MFAMethodType
+	for _, method := range methods {
+		types = append(types, method.Type)
+	}
+	return types
+}
+
+func TestListMethods(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	enableTOTP(t, user)
+	other := newTestUser(t, "grace@example.edu", "password-123")
+	f := newTwoFactorFixture(user, other)
+	f.addMethod(t, user, domain.MFASMS, false)
+	f.addMethod(t, other, domain.MFAWebAuthn, true)
+
+	// TOTP enabled before methods were tracked gets its record on first use
+	methods, err := f.service.ListMethods(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("ListMethods: %v", err)
+	}
+	if got := methodTypes(methods); len(got) != 2 || got[0] != domain.MFASMS || got[1] != domain.MFATOTP {
+		t.Errorf("methods = %v, want sms and totp only", got)
+	}
+
+	if err := f.service.SetPreferredMethod(context.Background(), user.ID, methods[1].ID); err != nil {
+		t.Fatalf("SetPreferredMethod: %v", err)
+	}
+	methods, _ = f.service.ListMethods(context.Background(), user.ID)
+	if got := methodTypes(methods); got[0] != domain.MFATOTP || !methods[0].Preferred || methods[1].Preferred {
+		t.Errorf("methods after preferring totp = %v", got)
+	}
+}
+
+func TestSetPreferredMethodOfAnotherUser(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	other := newTestUser(t, "grace@example.edu", "password-123")
+	f := newTwoFactorFixture(user, other)
+	foreign := f.addMethod(t, other, domain.MFASMS, false)
+
+	for _, id := range []uuid.UUID{foreign.ID, uuid.New()} {
+		if err := f.service.SetPreferredMethod(context.Background(), user.ID, id); !errors.Is(err, ErrMFAMethodNotFound) {
+			t.Errorf("SetPreferredMethod(%s) = %v, want ErrMFAMethodNotFound", id, err)
+		}
+	}
+}
+
+// Roles requiring MFA cannot drop their last factor; other users can
+func TestRemoveLastMethod(t *testing.T) {
+	for role, want := range map[domain.Role]error{domain.RoleAdmin: ErrLastMFAMethod, domain.RoleStudent: nil} {
+		user := newTestUser(t, "ada@example.edu", "password-123")
+		user.Role = role
+		enableTOTP(t, user)
+		f := newTwoFactorFixture(user)
+		f.devices.Create(domain.NewTrustedDevice(user.ID, "device-token", "laptop", farFuture()))
+		methods, _ := f.service.ListMethods(context.Background(), user.ID)
+
+		err := f.service.RemoveMethod(context.Background(), user.ID, methods[0].ID, "192.0.2.1", "test-agent")
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: RemoveMethod = %v, want %v", role, err, want)
+		}
+
+		stored := f.users.get(t, user.ID)
+		remaining, _ := f.methods.ListByUserID(user.ID)
+		active, _ := f.devices.ListActiveByUserID(user.ID)
+		if want != nil {
+			if !stored.TwoFactorEnabled || len(remaining) != 1 || len(active) != 1 || len(f.publisher.events) != 0 {
+				t.Errorf("%s: refused removal changed the account", role)
+			}
+			continue
+		}
+		if stored.TwoFactorEnabled || len(remaining) != 0 || len(active) != 0 {
+			t.Errorf("%s: 2FA %v, %d methods and %d trusted devices left", role, stored.TwoFactorEnabled, len(remaining), len(active))
+		}
+		if len(f.publisher.ofType(events.UserSecurityChanged)) != 1 {
+			t.Errorf("%s: removal not alerted", role)
+		}
+	}
+}
+
+func TestRemoveMethodKeepsOneForRequiredRoles(t *testing.T) {
+	admin := newTestUser(t, "ada@example.edu", "password-123")
+	admin.Role = domain.RoleAdmin
+	f := newTwoFactorFixture(admin)
+	sms := f.addMethod(t, admin, domain.MFASMS, true)
+	webauthn := f.addMethod(t, admin, domain.MFAWebAuthn, false)
+	ctx := context.Background()
+
+	if err := f.service.RemoveMethod(ctx, admin.ID, uuid.New(), "", ""); !errors.Is(err, ErrMFAMethodNotFound) {
+		t.Errorf("removing an unknown method = %v, want ErrMFAMethodNotFound", err)
+	}
+
+	// removing the preferred method passes the preference on
+	if err := f.service.RemoveMethod(ctx, admin.ID, sms.ID, "", ""); err != nil {
+		t.Fatalf("removing one of two methods: %v", err)
+	}
+	methods, _ := f.service.ListMethods(ctx, admin.ID)
+	if len(methods) != 1 || methods[0].ID != webauthn.ID || !methods[0].Preferred {
+		t.Errorf("methods left = %+v, want the preferred webauthn method", methods)
+	}
+
+	if err := f.service.RemoveMethod(ctx, admin.ID, webauthn.ID, "", ""); !errors.Is(err, ErrLastMFAMethod) {
+		t.Errorf("removing the last method = %v, want ErrLastMFAMethod", err)
+	}
+}
+
+func TestDisableTwoFactorKeepsOneForRequiredRoles(t *testing.T) {
+	admin := newTestUser(t, "ada@example.edu", "password-123")
+	admin.Role = domain.RoleAdmin
+	secret := enableTOTP(t, admin)
+	f := newTwoFactorFixture(admin)
+	ctx := context.Background()
+
+	if err := f.service.Disable(ctx, admin.ID, currentTOTP(t, secret), "", ""); !errors.Is(err, ErrLastMFAMethod) {
+		t.Fatalf("disabling the only factor = %v, want ErrLastMFAMethod", err)
+	}
+
+	f.addMethod(t, admin, domain.MFASMS, false)
+	if err := f.service.Disable(ctx, admin.ID, currentTOTP(t, secret), "", ""); err != nil {
+		t.Fatalf("disabling TOTP with another factor left: %v", err)
+	}
+	methods, _ := f.service.ListMethods(ctx, admin.ID)
+	if got := methodTypes(methods); len(got) != 1 || got[0] != domain.MFASMS || !methods[0].Preferred {
+		t.Errorf("methods left = %v, want the sms method, preferred", got)
+	}
+}
+
+// The login challenge offers the enrolled methods, preferred first
+func TestLoginChallengeOffersMethods(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	enableTOTP(t, user)
+	f := newAuthFixture(t, AuthConfig{}, user)
+	f.methods.Create(domain.NewMFAMethod(user.ID, domain.MFATOTP, totpMethodLabel, false))
+	f.methods.Create(domain.NewMFAMethod(user.ID, domain.MFASMS, "Phone", true))
+
+	result := f.login(t, user.Email, "")
+	if !result.TwoFactorRequired {
+		t.Fatal("login did not ask for a second factor")
+	}
+	if got := methodTypes(result.MFAMethods); len(got) != 2 || got[0] != domain.MFASMS || got[1] != domain.MFATOTP {
+		t.Errorf("offered methods = %v, want sms then totp", got)
+	}
+}
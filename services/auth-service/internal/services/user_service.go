@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// UserService handles user registration and profile management
+type UserService struct {
+	userRepo       domain.UserRepository
+	transactor     domain.Transactor
+	publisher      events.Publisher
+	verifier       *EmailVerificationService
+	passwordPolicy domain.PasswordPolicy
+	peppers        domain.Peppers
+	timezones      domain.TimezoneDefaults
+	// reactivationWindow bounds self-reactivation after deactivation; zero
+	// means no limit
+	reactivationWindow time.Duration
+	updateLimiter      ratelimit.Limiter
+	updateLimit        ratelimit.Limit
+	// registrationLimiter throttles registrations per campus under registrationLimits
+	registrationLimiter ratelimit.Limiter
+	registrationLimits  *ratelimit.Policy
+	// audit records password changes and profile updates
+	audit *AuditWriter
+	// normalizeAliases refuses registrations whose email is a Gmail-style
+	// alias of a registered one
+	normalizeAliases bool
+	// lockout counts failed reactivations with failed logins
+	lockout *ratelimit.Lockout
+	// activeUsers is told about deactivations so tokens stop working at once
+	activeUsers *ActiveUsers
+}
+
+// NewUserService creates a new UserService. New users are sent a verification
+// link through verifier. Deactivated users can reactivate within
+// reactivationWindow, zero meaning indefinitely. Self-service profile updates
+// are limited to updateLimit per user; a zero limit disables the throttle.
+// Registrations are limited per campus by registrationLimits, whose
+// identities are campus IDs; campuses given a zero limit are not throttled.
+// Password changes and profile updates are recorded through audit. With
+// normalizeAliases, an email differing from a registered one only by dots or
+// a plus tag, on domains ignoring those, is taken. Reactivations check the
+// password under the login lockout; activeUsers learns of (re)activations.
+func NewUserService(userRepo domain.UserRepository, transactor domain.Transactor, publisher events.Publisher, verifier *EmailVerificationService, passwordPolicy domain.PasswordPolicy, peppers domain.Peppers, timezones domain.TimezoneDefaults, reactivationWindow time.Duration, updateLimiter ratelimit.Limiter, updateLimit ratelimit.Limit, registrationLimiter ratelimit.Limiter, registrationLimits *ratelimit.Policy, audit *AuditWriter, normalizeAliases bool, lockout *ratelimit.Lockout, activeUsers *ActiveUsers) *UserService {
+	return &UserService{
+		userRepo:           userRepo,
+		transactor:         transactor,
+		publisher:          publisher,
+		verifier:           verifier,
+		passwordPolicy:     passwordPolicy,
+		peppers:            peppers,
+		timezones:          timezones,
+		reactivationWindow: reactivationWindow,
+		updateLimiter:      updateLimiter,
+		updateLimit:        updateLimit,
+
+		registrationLimiter: registrationLimiter,
+		registrationLimits:  registrationLimits,
+		audit:               audit,
+		normalizeAliases:    normalizeAliases,
+		lockout:             lockout,
+		activeUsers:         activeUsers,
+	}
+}
+
+// CreateUser registers a new user and announces it on the event bus. All
+// registration writes, the user, its verification token and the outbox event
+// announcing it, share one transaction, so a failure in any of them leaves no
+// partial account behind and no account goes unannounced or unverifiable.
+func (s *UserService) CreateUser(ctx context.Context, reg domain.UserRegistration) (*domain.User, error) {
+	if err := s.passwordPolicy.Check(domain.RoleStudent, "password", reg.Password, reg.Email, reg.FirstName, reg.LastName); err != nil {
+		return nil, err
+	}
+	if limit := s.registrationLimits.LimitFor(reg.CampusID); limit.Requests > 0 && !s.registrationLimiter.Allow("registration:"+reg.CampusID, limit).Allowed {
+		return nil, ErrTooManyRegistrations
+	}
+
+	user, err := domain.NewUser(reg, s.peppers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user: %w", err)
+	}
+
+	var verification *domain.VerificationToken
+	var token string
+	err = s.transactor.WithTx(ctx, func(repos domain.Repositories) error {
+		if err := s.checkEmailAvailable(ctx, repos.Users, user.Email); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Create(ctx, user); err != nil {
+			return err
+		}
+
+		issued, plain, err := s.verifier.issueToken(repos.VerificationTokens, user)
+		if err != nil {
+			return err
+		}
+		verification, token = issued, plain
+
+		// the event is stored with the user, for the outbox dispatcher to send
+		user.InferTimezone(s.timezones)
+		outboxEvent, err := newOutboxEvent(s.verifier.registeredEvent(user).WithRequestID(ctx))
+		if err != nil {
+			return err
+		}
+		return repos.Outbox.Create(outboxEvent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	registrations.Inc()
+
+	// the account exists either way; a lost link can be sent again
+	if err := s.verifier.requestVerification(ctx, user, verification, token); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+// checkEmailAvailable fails with ErrEmailTaken when the email, or with
+// normalizeAliases an alias of it, is registered already
+func (s *UserService) checkEmailAvailable(ctx context.Context, users domain.UserRepository, email string) error {
+	_, err := users.GetByEmail(ctx, email)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+
+	stripped, aliasing := domain.StripEmailAliases(email)
+	if !s.normalizeAliases || !aliasing {
+		return nil
+	}
+	_, err = users.GetByEmailAlias(ctx, stripped)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check email aliases: %w", err)
+	}
+	return nil
+}
+
+// NormalizeEmails lowercases and trims the emails stored before emails were
+// normalized. It runs at startup and does nothing once every email is
+// normalized; emails colliding with another user's are logged for an operator.
+func (s *UserService) NormalizeEmails(ctx context.Context) error {
+	normalized, conflicting, err := s.userRepo.NormalizeEmails(ctx)
+	if err != nil {
+		return err
+	}
+	if normalized > 0 {
+		log.Printf("Normalized %d stored emails", normalized)
+	}
+	if conflicting > 0 {
+		log.Printf("%d stored emails were not normalized because another user has the normalized email; resolve them by merging or changing the accounts", conflicting)
+	}
+	return nil
+}
+
+// GetUser returns the user with the given ID
+func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetProfile returns the user's own profile, with the campus default time
+// zone filled in when they have not chosen one
+func (s *UserService) GetProfile(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.InferTimezone(s.timezones)
+	return user, nil
+}
+
+// GetUsers returns the users with the given IDs, skipping IDs of no user
+func (s *UserService) GetUsers(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error) {
+	return s.userRepo.GetByIDs(ctx, ids)
+}
+
+// GetUserView returns the view of target that viewer may see: the full user
+// for admins, the public profile for everyone else. Hidden or inactive
+// profiles are reported as not found to non-admins.
+func (s *UserService) GetUserView(ctx context.Context, viewerID, targetID uuid.UUID) (interface{}, error) {
+	viewer, err := s.GetUser(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.GetUser(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewer.IsAdmin() {
+		return target, nil
+	}
+	if viewer.ID != target.ID && (target.ProfileHidden || !target.IsActive) {
+		return nil, ErrUserNotFound
+	}
+	return target.PublicProfile(), nil
+}
+
+// UpdateProfile applies a self-service profile update to the given user
+func (s *UserService) UpdateProfile(ctx context.Context, id uuid.UUID, profile domain.UserProfile, ipAddress, userAgent string) (*domain.User, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if s.updateLimit.Requests > 0 && !s.updateLimiter.Allow("profile:"+id.String(), s.updateLimit).Allowed {
+		return nil, ErrTooManyProfileUpdates
+	}
+
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.UpdateProfile(profile); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	user.InferTimezone(s.timezones)
+	s.audit.Record(domain.NewAuditLog(user.ID, domain.AuditProfileUpdated, ipAddress, userAgent, domain.AuditMetadata{
+		"fields": profile.Fields(),
+	}))
+
+	s.publish(ctx, userUpdatedEvent(user))
+
+	return user, nil
+}
+
+// ChangePassword replaces the user's password after checking the current one,
+// and alerts the user. Changing the password lifts a forced password change;
+// the client must refresh its tokens to drop the restriction from its access
+// token.
+func (s *UserService) ChangePassword(ctx context.Context, id uuid.UUID, change domain.PasswordChange, ipAddress, userAgent string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.CheckPassword(change.CurrentPassword, s.peppers) {
+		return ErrInvalidCredentials
+	}
+	if err := s.passwordPolicy.Check(user.Role, "new_password", change.NewPassword, user.Email, user.FirstName, user.LastName); err != nil {
+		return err
+	}
+	if user.CheckPassword(change.NewPassword, s.peppers) {
+		return ErrPasswordUnchanged
+	}
+
+	if err := user.SetPassword(change.NewPassword, s.peppers); err != nil {
+		return err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.audit.Record(domain.NewAuditLog(user.ID, domain.AuditPasswordChanged, ipAddress, userAgent, nil))
+
+	publishSecurityChange(ctx, s.publisher, user, domain.SecurityPasswordChanged, ipAddress, userAgent)
+	return nil
+}
+
+// Deactivate lets a user deactivate their own account
+func (s *UserService) Deactivate(ctx context.Context, id uuid.UUID) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	user.Deactivate()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.activeUsers.Forget(user.ID)
+
+	s.publish(ctx, accountEvent(events.UserDeactivated, user.ID))
+
+	return nil
+}
+
+// Reactivate reactivates a self-deactivated account after checking its
+// credentials. Failed attempts count towards the login lockout, as a wrong
+// password is told apart from a right one here just as at login.
+func (s *UserService) Reactivate(ctx context.Context, login domain.UserLogin, ipAddress string) (*domain.User, error) {
+	if _, locked := s.lockout.Check(login.Email, ipAddress); locked {
+		return nil, ErrTooManyLoginAttempts
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, login.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.lockout.Fail(login.Email, ipAddress)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.CheckPassword(login.Password, s.peppers) {
+		s.lockout.Fail(login.Email, ipAddress)
+		return nil, ErrInvalidCredentials
+	}
+	s.lockout.Reset(login.Email, ipAddress)
+
+	switch user.Status().State {
+	case domain.AccountMerged:
+		return nil, ErrAccountMerged
+	case domain.AccountBanned:
+		return nil, ErrAccountBanned
+	case domain.AccountSuspended:
+		return nil, ErrAccountSuspended
+	case domain.AccountDisabled:
+		return nil, ErrAccountDisabled
+	case domain.AccountActive:
+		return nil, ErrAccountActive
+	}
+	if user.ReactivationExpired(s.reactivationWindow) {
+		return nil, ErrReactivationClosed
+	}
+
+	user.Reactivate()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.activeUsers.Forget(user.ID)
+
+	s.publish(ctx, accountEvent(events.UserReactivated, user.ID))
+
+	return user, nil
+}
+
+// DeleteUser soft-deletes the given user; an admin can restore them until
+// they are purged
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	s.publish(ctx, accountEvent(events.UserDeleted, id))
+
+	return nil
+}
+
+// PasswordRequirements describes the password policy enforced on
+// registration, password changes and resets
+func (s *UserService) PasswordRequirements() domain.PasswordRequirements {
+	return s.passwordPolicy.Requirements()
+}
+
+// publish emits an event; failures are logged but never fail the calling operation
+func (s *UserService) publish(ctx context.Context, event events.DomainEvent) {
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.EventType, err)
+	}
+}
@@ -0,0 +1,509 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+func TestGetUserView(t *testing.T) {
+	viewer := newTestUser(t, "ada@example.edu", "password-123")
+	admin := newTestUser(t, "grace@example.edu", "password-123")
+	admin.Role = domain.RoleAdmin
+	peer := newTestUser(t, "alan@example.edu", "password-123")
+	hidden := newTestUser(t, "hedy@example.edu", "password-123")
+	hidden.ProfileHidden = true
+	inactive := newTestUser(t, "emmy@example.edu", "password-123")
+	inactive.IsActive = false
+
+	service := &UserService{userRepo: newMemUserRepo(viewer, admin, peer, hidden, inactive)}
+	ctx := context.Background()
+
+	view, err := service.GetUserView(ctx, viewer.ID, peer.ID)
+	if err != nil {
+		t.Fatalf("peer profile: %v", err)
+	}
+	public, ok := view.(domain.PublicProfile)
+	if !ok {
+		t.Fatalf("peer profile is a %T, want the public profile", view)
+	}
+	if public.ID != peer.ID || public.FirstName != peer.FirstName || *public.CampusID != *peer.CampusID {
+		t.Errorf("public profile = %+v", public)
+	}
+
+	for _, target := range []*domain.User{hidden, inactive} {
+		if _, err := service.GetUserView(ctx, viewer.ID, target.ID); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("profile of %s: %v, want ErrUserNotFound", target.Email, err)
+		}
+	}
+
+	// users always see their own profile, even when it is hidden
+	if _, err := service.GetUserView(ctx, hidden.ID, hidden.ID); err != nil {
+		t.Errorf("own hidden profile: %v", err)
+	}
+
+	for _, target := range []*domain.User{peer, hidden, inactive} {
+		view, err := service.GetUserView(ctx, admin.ID, target.ID)
+		if err != nil {
+			t.Fatalf("admin view of %s: %v", target.Email, err)
+		}
+		if full, ok := view.(*domain.User); !ok || full.Email != target.Email {
+			t.Errorf("admin view of %s = %#v, want the full user", target.Email, view)
+		}
+	}
+}
+
+func TestReactivate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	adminID := uuid.New()
+
+	tests := []struct {
+		name    string
+		setup   func(*domain.User)
+		login   domain.UserLogin
+		wantErr error
+	}{
+		{"self-deactivated", func(u *domain.User) { u.Deactivate() }, domain.UserLogin{}, nil},
+		{"wrong password", func(u *domain.User) { u.Deactivate() }, domain.UserLogin{Password: "wrong-password"}, ErrInvalidCredentials},
+		{"unknown email", func(u *domain.User) { u.Deactivate() }, domain.UserLogin{Email: "nobody@example.edu"}, ErrInvalidCredentials},
+		{"active", func(u *domain.User) {}, domain.UserLogin{}, ErrAccountActive},
+		{"banned", func(u *domain.User) { u.Deactivate(); u.BannedAt = &past }, domain.UserLogin{}, ErrAccountBanned},
+		{"suspended", func(u *domain.User) { u.Deactivate(); u.SuspendedUntil = &future }, domain.UserLogin{}, ErrAccountSuspended},
+		{"disabled by an admin", func(u *domain.User) { u.Disable(adminID) }, domain.UserLogin{}, ErrAccountDisabled},
+		{"window elapsed", func(u *domain.User) { u.Deactivate(); u.DeactivatedAt = &past }, domain.UserLogin{}, ErrReactivationClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			tt.setup(user)
+			wasActive := user.IsActive
+			users := newMemUserRepo(user)
+			publisher := &recordingPublisher{}
+			service := &UserService{userRepo: users, publisher: publisher, reactivationWindow: 30 * time.Second}
+
+			login := domain.UserLogin{Email: "ada@example.edu", Password: "password-123"}
+			if tt.login.Email != "" {
+				login.Email = tt.login.Email
+			}
+			if tt.login.Password != "" {
+				login.Password = tt.login.Password
+			}
+
+			_, err := service.Reactivate(context.Background(), login, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reactivate = %v, want %v", err, tt.wantErr)
+			}
+
+			stored := users.get(t, user.ID)
+			reactivated := publisher.ofType(events.UserReactivated)
+			if tt.wantErr != nil {
+				if stored.IsActive != wasActive || len(reactivated) != 0 {
+					t.Errorf("failed reactivation changed the account: active %v, %d events", stored.IsActive, len(reactivated))
+				}
+				return
+			}
+			if !stored.IsActive || stored.DeactivatedAt != nil {
+				t.Errorf("account not reactivated: active %v, deactivated at %v", stored.IsActive, stored.DeactivatedAt)
+			}
+			if len(reactivated) != 1 {
+				t.Errorf("published %d user.reactivated events, want 1", len(reactivated))
+			}
+		})
+	}
+}
+
+// Reactivation tells a right password from a wrong one, so it shares the
+// login lockout: guesses there lock out logins and the other way round
+func TestReactivateLockout(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	user.Deactivate()
+	lockout := ratelimit.NewLockout(ratelimit.LockoutPolicy{
+		Scope:       ratelimit.ScopeAccountIP,
+		MaxFailures: 3,
+		Window:      time.Minute,
+		Duration:    time.Hour,
+	})
+	f := newAuthFixture(t, AuthConfig{Lockout: lockout}, user)
+	service := &UserService{userRepo: f.users, publisher: &recordingPublisher{}, lockout: lockout}
+	ctx := context.Background()
+	reactivate := func(password, ip string) error {
+		_, err := service.Reactivate(ctx, domain.UserLogin{Email: user.Email, Password: password}, ip)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := reactivate("wrong-password", "203.0.113.7"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed attempt %d = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	if err := reactivate("password-123", "203.0.113.7"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("right password while locked out = %v, want ErrTooManyLoginAttempts", err)
+	}
+	if _, err := f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "password-123"}, "203.0.113.7", "test-agent"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("login after failed reactivations = %v, want ErrTooManyLoginAttempts", err)
+	}
+	if stored := f.users.get(t, user.ID); stored.IsActive {
+		t.Error("account reactivated while locked out")
+	}
+
+	// failed logins lock out reactivation too
+	for i := 0; i < 3; i++ {
+		f.service.Login(ctx, domain.UserLogin{Email: user.Email, Password: "wrong-password"}, "198.51.100.1", "test-agent")
+	}
+	if err := reactivate("password-123", "198.51.100.1"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("reactivation after failed logins = %v, want ErrTooManyLoginAttempts", err)
+	}
+	if err := reactivate("password-123", "192.0.2.1"); err != nil {
+		t.Errorf("owner from another address: %v", err)
+	}
+}
+
+// Tokens stop and resume working at once, not when the cached answer expires
+func TestDeactivationReachesActiveUsers(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	users := newMemUserRepo(user)
+	activeUsers := NewActiveUsers(users, time.Hour)
+	service := &UserService{userRepo: users, publisher: &recordingPublisher{}, activeUsers: activeUsers}
+	ctx := context.Background()
+	isActive := func() bool {
+		active, err := activeUsers.IsActive(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("IsActive: %v", err)
+		}
+		return active
+	}
+
+	if !isActive() {
+		t.Fatal("active user reported inactive")
+	}
+	if err := service.Deactivate(ctx, user.ID); err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	if isActive() {
+		t.Error("deactivated user still reported active")
+	}
+	if _, err := service.Reactivate(ctx, domain.UserLogin{Email: user.Email, Password: "password-123"}, "192.0.2.1"); err != nil {
+		t.Fatalf("Reactivate: %v", err)
+	}
+	if !isActive() {
+		t.Error("reactivated user still reported inactive")
+	}
+}
+
+func TestLoginPromptsReactivation(t *testing.T) {
+	deactivated := newTestUser(t, "ada@example.edu", "password-123")
+	deactivated.Deactivate()
+	banned := newTestUser(t, "alan@example.edu", "password-123")
+	banned.Deactivate()
+	bannedAt := time.Now()
+	banned.BannedAt = &bannedAt
+
+	service := &AuthService{
+		userRepo: newMemUserRepo(deactivated, banned),
+		config:   AuthConfig{ReactivationWindow: time.Hour},
+	}
+	ctx := context.Background()
+
+	_, err := service.Login(ctx, domain.UserLogin{Email: "ada@example.edu", Password: "password-123"}, "192.0.2.1", "test")
+	var prompt AccountDeactivatedError
+	if !errors.As(err, &prompt) {
+		t.Fatalf("Login to a deactivated account = %v, want AccountDeactivatedError", err)
+	}
+	if prompt.ReactivateBefore == nil || !prompt.ReactivateBefore.Equal(deactivated.DeactivatedAt.Add(time.Hour)) {
+		t.Errorf("reactivate before %v, want the end of the window", prompt.ReactivateBefore)
+	}
+
+	_, err = service.Login(ctx, domain.UserLogin{Email: "alan@example.edu", Password: "password-123"}, "192.0.2.1", "test")
+	if !errors.Is(err, ErrAccountBanned) || errors.Is(err, ErrAccountDeactivated) {
+		t.Fatalf("Login to a banned account = %v, want ErrAccountBanned", err)
+	}
+}
+
+func TestUpdateProfileThrottle(t *testing.T) {
+	ada := newTestUser(t, "ada@example.edu", "password-123")
+	alan := newTestUser(t, "alan@example.edu", "password-123")
+	service := &UserService{
+		userRepo:      newMemUserRepo(ada, alan),
+		publisher:     &recordingPublisher{},
+		updateLimiter: ratelimit.NewMemoryLimiter(),
+		updateLimit:   ratelimit.Limit{Requests: 2, Window: time.Hour},
+	}
+	ctx := context.Background()
+	rename := domain.UserProfile{FirstName: "Augusta"}
+
+	// invalid updates are rejected before they count
+	if _, err := service.UpdateProfile(ctx, ada.ID, domain.UserProfile{FirstName: strings.Repeat("a", domain.MaxNameLength+1)}, "", ""); err == nil {
+		t.Fatal("invalid update accepted")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := service.UpdateProfile(ctx, ada.ID, rename, "", ""); err != nil {
+			t.Fatalf("update %d within the limit: %v", i+1, err)
+		}
+	}
+	if _, err := service.UpdateProfile(ctx, ada.ID, rename, "", ""); !errors.Is(err, ErrTooManyProfileUpdates) {
+		t.Fatalf("update over the limit = %v, want ErrTooManyProfileUpdates", err)
+	}
+
+	// the limit is per user
+	if _, err := service.UpdateProfile(ctx, alan.ID, rename, "", ""); err != nil {
+		t.Errorf("another user's update: %v", err)
+	}
+}
+
+func TestUpdateProfileThrottleDisabled(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	service := &UserService{
+		userRepo:      newMemUserRepo(user),
+		publisher:     &recordingPublisher{},
+		updateLimiter: ratelimit.NewMemoryLimiter(),
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := service.UpdateProfile(context.Background(), user.ID, domain.UserProfile{FirstName: "Augusta"}, "", ""); err != nil {
+			t.Fatalf("update %d with the throttle disabled: %v", i+1, err)
+		}
+	}
+}
+
+func TestProfileTimezone(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	users := newMemUserRepo(user)
+	publisher := &recordingPublisher{}
+	service := &UserService{
+		userRepo:  users,
+		publisher: publisher,
+		timezones: domain.TimezoneDefaults{Campuses: map[string]string{"main-campus": "Africa/Addis_Ababa"}, Fallback: "UTC"},
+	}
+	ctx := context.Background()
+
+	profile, err := service.GetProfile(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	if profile.Timezone == nil || *profile.Timezone != "Africa/Addis_Ababa" || !profile.TimezoneInferred {
+		t.Errorf("timezone %v, inferred %v; want the campus default", profile.Timezone, profile.TimezoneInferred)
+	}
+
+	invalid := "Mars/Olympus_Mons"
+	var validation domain.ValidationError
+	if _, err := service.UpdateProfile(ctx, user.ID, domain.UserProfile{Timezone: &invalid}, "", ""); !errors.As(err, &validation) {
+		t.Fatalf("UpdateProfile with %s = %v, want a ValidationError", invalid, err)
+	}
+
+	chosen := "Europe/London"
+	updated, err := service.UpdateProfile(ctx, user.ID, domain.UserProfile{Timezone: &chosen}, "", "")
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if updated.Timezone == nil || *updated.Timezone != chosen || updated.TimezoneInferred {
+		t.Errorf("timezone %v, inferred %v; want the chosen %s", updated.Timezone, updated.TimezoneInferred, chosen)
+	}
+	if stored := users.get(t, user.ID).Timezone; stored == nil || *stored != chosen {
+		t.Errorf("stored timezone %v, want %s", stored, chosen)
+	}
+	published := publisher.ofType(events.UserUpdated)
+	if len(published) != 1 {
+		t.Fatalf("published %d user.updated events, want 1", len(published))
+	}
+	if zone := published[0].Data.(map[string]interface{})["timezone"].(*string); *zone != chosen {
+		t.Errorf("user.updated carries timezone %s, want %s", *zone, chosen)
+	}
+}
+
+// A malformed campus ID is refused before the user is looked up, so even an
+// unknown user gets the validation error
+func TestUpdateProfileRejectsMalformedCampusID(t *testing.T) {
+	service := &UserService{userRepo: newMemUserRepo(), publisher: &recordingPublisher{}}
+	malformed := "Main Campus"
+
+	_, err := service.UpdateProfile(context.Background(), uuid.New(), domain.UserProfile{CampusID: &malformed}, "", "")
+	var validation domain.ValidationError
+	if !errors.As(err, &validation) || validation.Field != "campus_id" {
+		t.Errorf("UpdateProfile = %v, want a ValidationError on campus_id", err)
+	}
+}
+
+type registrationFixture struct {
+	service   *UserService
+	tx        *memTransactor
+	publisher *recordingPublisher
+}
+
+func newRegistrationFixture(users ...*domain.User) *registrationFixture {
+	f := &registrationFixture{tx: newMemTransactor(users...), publisher: &recordingPublisher{}}
+	verifier := NewEmailVerificationService(f.tx.users, f.tx.tokens, f.publisher, "https://auth.example.edu", time.Hour, nil, false)
+	f.service = NewUserService(f.tx.users, f.tx, f.publisher, verifier, domain.PasswordPolicy{}, domain.Peppers{}, domain.TimezoneDefaults{}, 0, nil, ratelimit.Limit{}, nil, &ratelimit.Policy{}, nil, false, nil, nil)
+	return f
+}
+
+func registration(email string) domain.UserRegistration {
+	return domain.UserRegistration{Email: email, Password: "password-123", FirstName: "Ada", LastName: "Lovelace", CampusID: "main"}
+}
+
+func TestCreateUserStoresRegistrationTogether(t *testing.T) {
+	f := newRegistrationFixture()
+
+	user, err := f.service.CreateUser(context.Background(), registration("ada@example.edu"))
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	f.tx.users.get(t, user.ID)
+	if _, err := f.tx.tokens.GetLatestByUserID(user.ID, domain.PurposeEmail); err != nil {
+		t.Errorf("no verification token stored: %v", err)
+	}
+	if len(f.tx.outbox.events) != 1 || f.tx.outbox.events[0].EventType != events.UserRegistered {
+		t.Errorf("outbox = %+v, want one %s event", f.tx.outbox.events, events.UserRegistered)
+	}
+	if len(f.publisher.ofType(events.EmailVerificationRequested)) != 1 {
+		t.Error("verification email not requested")
+	}
+}
+
+func TestCreateUserRejectsMalformedCampusID(t *testing.T) {
+	f := newRegistrationFixture()
+	reg := registration("ada@example.edu")
+	reg.CampusID = "AAU-Main"
+
+	_, err := f.service.CreateUser(context.Background(), reg)
+	var validation domain.ValidationError
+	if !errors.As(err, &validation) || validation.Field != "campus_id" {
+		t.Errorf("CreateUser = %v, want a ValidationError on campus_id", err)
+	}
+	if len(f.tx.users.users) != 0 {
+		t.Error("user with a malformed campus ID stored")
+	}
+}
+
+// A failure in any registration write leaves nothing behind: no user without
+// a verification token, and no announcement of a user that does not exist
+func TestCreateUserRollsBackOnFailure(t *testing.T) {
+	existing := newTestUser(t, "grace@example.edu", "password-123")
+	lost := errors.New("connection lost")
+
+	tests := []struct {
+		name  string
+		email string
+		fail  func(*memTransactor)
+		want  error
+	}{
+		{"verification token", "ada@example.edu", func(tx *memTransactor) { tx.tokens.failWrites = lost }, lost},
+		{"outbox event", "ada@example.edu", func(tx *memTransactor) { tx.outbox.failWrites = lost }, lost},
+		{"email taken", existing.Email, func(*memTransactor) {}, ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRegistrationFixture(existing)
+			tt.fail(f.tx)
+
+			_, err := f.service.CreateUser(context.Background(), registration(tt.email))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateUser = %v, want %v", err, tt.want)
+			}
+			if len(f.tx.users.users) != 1 {
+				t.Errorf("%d users stored, want only the existing one", len(f.tx.users.users))
+			}
+			if len(f.tx.tokens.tokens) != 0 || len(f.tx.outbox.events) != 0 || len(f.publisher.events) != 0 {
+				t.Errorf("failed registration left %d tokens, %d outbox events and %d published events",
+					len(f.tx.tokens.tokens), len(f.tx.outbox.events), len(f.publisher.events))
+			}
+		})
+	}
+}
+
+// Each campus has a registration bucket of its own, so one campus being
+// flooded does not hold back another
+func TestCreateUserCampusThrottle(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ratelimit.Policy
+		want   map[string]int
+	}{
+		{
+			name: "default and override",
+			policy: ratelimit.Policy{
+				Default:   ratelimit.Limit{Requests: 2, Window: time.Minute},
+				Overrides: ratelimit.StaticOverrides{"north": 3},
+			},
+			want: map[string]int{"main": 2, "south": 2, "north": 3},
+		},
+		{
+			name: "override without a default",
+			policy: ratelimit.Policy{
+				Default:   ratelimit.Limit{Window: time.Minute},
+				Overrides: ratelimit.StaticOverrides{"north": 1},
+			},
+			want: map[string]int{"main": 5, "north": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRegistrationFixture()
+			f.service.registrationLimiter = ratelimit.NewMemoryLimiter()
+			f.service.registrationLimits = &tt.policy
+
+			// campuses register in turns, up to 5 users each
+			registered := make(map[string]int)
+			for i := 0; i < 5; i++ {
+				for campus := range tt.want {
+					reg := registration(fmt.Sprintf("%s%d@example.edu", campus, i))
+					reg.CampusID = campus
+					_, err := f.service.CreateUser(context.Background(), reg)
+					switch {
+					case err == nil:
+						registered[campus]++
+					case !errors.Is(err, ErrTooManyRegistrations):
+						t.Fatalf("CreateUser on %s: %v", campus, err)
+					}
+				}
+			}
+
+			for campus, want := range tt.want {
+				if registered[campus] != want {
+					t.Errorf("%s registered %d users, want %d", campus, registered[campus], want)
+				}
+			}
+			var total int
+			for _, n := range registered {
+				total += n
+			}
+			if len(f.tx.users.users) != total {
+				t.Errorf("%d users stored, want the %d registered", len(f.tx.users.users), total)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRejectsCurrentPassword(t *testing.T) {
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	users := newMemUserRepo(user)
+	publisher := &recordingPublisher{}
+	service := &UserService{userRepo: users, publisher: publisher}
+	ctx := context.Background()
+
+	change := domain.PasswordChange{CurrentPassword: "password-123", NewPassword: "password-123"}
+	if err := service.ChangePassword(ctx, user.ID, change, "192.0.2.1", "test-agent"); !errors.Is(err, ErrPasswordUnchanged) {
+		t.Fatalf("ChangePassword to the current password = %v, want ErrPasswordUnchanged", err)
+	}
+	if len(publisher.events) != 0 {
+		t.Errorf("published %d events for a rejected change", len(publisher.events))
+	}
+
+	change.NewPassword = "password-456"
+	if err := service.ChangePassword(ctx, user.ID, change, "192.0.2.1", "test-agent"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if !users.get(t, user.ID).CheckPassword("password-456", domain.Peppers{}) {
+		t.Error("password was not changed")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+// ListSessions returns the user's active sessions, newest first, marking the
+// one the request was made from
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentID uuid.UUID) ([]domain.SessionSummary, error) {
+	sessions, err := s.sessionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []domain.SessionSummary{}
+	for _, session := range sessions {
+		if session.IsActive() {
+			summaries = append(summaries, session.Summary(currentID))
+		}
+	}
+	return summaries, nil
+}
+
+// RevokeSession logs one of the user's sessions out; its refresh token stops
+// working at once. Sessions of other users are reported as not found.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID, ipAddress, userAgent string) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != userID || !session.IsActive() {
+		return ErrSessionNotFound
+	}
+
+	session.Revoke()
+	if err := s.sessionRepo.Update(ctx, session); err != nil {
+		return err
+	}
+	s.config.Audit.Record(domain.NewAuditLog(userID, domain.AuditSessionRevoked, ipAddress, userAgent, domain.AuditMetadata{
+		"session_id": sessionID.String(),
+	}))
+	return nil
+}
+
+// RevokeOtherSessions logs the user out everywhere but the current session
+// and returns how many sessions it revoked
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, currentID uuid.UUID, ipAddress, userAgent string) (int, error) {
+	sessions, err := s.sessionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == currentID || !session.IsActive() {
+			continue
+		}
+		session.Revoke()
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			return revoked, err
+		}
+		s.config.Audit.Record(domain.NewAuditLog(userID, domain.AuditSessionRevoked, ipAddress, userAgent, domain.AuditMetadata{
+			"session_id": session.ID.String(),
+		}))
+		revoked++
+	}
+	return revoked, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// reminderScanInterval is how often unverified users are checked for a due reminder
+const reminderScanInterval = time.Hour
+
+// VerificationReminderConfig controls when unverified users are reminded
+type VerificationReminderConfig struct {
+	// Interval is the least time between any two verification emails a user
+	// gets, registration and resends included
+	Interval time.Duration
+	// MaxReminders caps the reminders each user gets
+	MaxReminders int
+	// BatchSize is the number of users loaded per batch
+	BatchSize int
+	// Rate caps how many verification emails reminders and re-verification
+	// jobs send together; zero requests disables the cap
+	Rate ratelimit.Limit
+}
+
+// VerificationReminderService periodically re-sends verification links to
+// users who have not verified their email, up to a maximum number of
+// reminders. Users who declined marketing consent are not reminded.
+type VerificationReminderService struct {
+	userRepo     domain.UserRepository
+	tokenRepo    domain.VerificationTokenRepository
+	reminderRepo domain.VerificationReminderRepository
+	consentRepo  domain.ConsentRepository
+	verifier     *EmailVerificationService
+	limiter      ratelimit.Limiter
+	config       VerificationReminderConfig
+}
+
+// NewVerificationReminderService creates a new VerificationReminderService.
+// limiter paces sending and should be shared with the re-verification jobs.
+func NewVerificationReminderService(userRepo domain.UserRepository, tokenRepo domain.VerificationTokenRepository, reminderRepo domain.VerificationReminderRepository, consentRepo domain.ConsentRepository, verifier *EmailVerificationService, limiter ratelimit.Limiter, config VerificationReminderConfig) *VerificationReminderService {
+	return &VerificationReminderService{
+		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		reminderRepo: reminderRepo,
+		consentRepo:  consentRepo,
+		verifier:     verifier,
+		limiter:      limiter,
+		config:       config,
+	}
+}
+
+// Run sends the due reminders periodically until ctx is done
+func (s *VerificationReminderService) Run(ctx context.Context) {
+	ticker := time.NewTicker(reminderScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.SendDue(ctx)
+			if err != nil {
+				log.Printf("Failed to send verification reminders: %v", err)
+			}
+			if sent > 0 {
+				log.Printf("Sent %d verification reminders", sent)
+			}
+		}
+	}
+}
+
+// SendDue reminds every unverified user whose reminder is due, returning how
+// many reminders were sent
+func (s *VerificationReminderService) SendDue(ctx context.Context) (int, error) {
+	var sent int
+	cursor := uuid.Nil
+	for {
+		users, err := s.userRepo.ListUnverified(ctx, nil, cursor, s.config.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, user := range users {
+			if err := ctx.Err(); err != nil {
+				return sent, err
+			}
+			reminded, err := s.remind(ctx, user, time.Now())
+			if err != nil {
+				return sent, err
+			}
+			if reminded {
+				sent++
+			}
+			cursor = user.ID
+		}
+
+		if len(users) < s.config.BatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// remind sends the user a reminder when they have reminders left, have not
+// been mailed a link within the interval and have not opted out
+func (s *VerificationReminderService) remind(ctx context.Context, user *domain.User, now time.Time) (bool, error) {
+	due, count, err := s.isDue(user, now)
+	if err != nil || !due {
+		return false, err
+	}
+
+	for s.config.Rate.Requests > 0 {
+		result := s.limiter.Allow(reverificationLimitKey, s.config.Rate)
+		if result.Allowed {
+			break
+		}
+		time.Sleep(result.RetryAfter)
+	}
+
+	sent, err := s.verifier.sendReminder(ctx, user, count+1)
+	if err != nil || !sent {
+		return false, err
+	}
+	if err := s.reminderRepo.Record(user.ID, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isDue reports whether the user should be reminded now, along with the
+// number of reminders they already got
+func (s *VerificationReminderService) isDue(user *domain.User, now time.Time) (bool, int, error) {
+	var count int
+	reminder, err := s.reminderRepo.Get(user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, fmt.Errorf("failed to get verification reminders: %w", err)
+	}
+	if reminder != nil {
+		count = reminder.Count
+	}
+	if count >= s.config.MaxReminders {
+		return false, count, nil
+	}
+
+	lastMailed := user.CreatedAt
+	latest, err := s.tokenRepo.GetLatestByUserID(user.ID, domain.PurposeEmail)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, count, fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if latest != nil {
+		lastMailed = latest.CreatedAt
+	}
+	if now.Sub(lastMailed) < s.config.Interval {
+		return false, count, nil
+	}
+
+	consent, err := s.consentRepo.GetLatest(user.ID, domain.ConsentMarketing)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, count, fmt.Errorf("failed to get consent: %w", err)
+	}
+	if consent != nil && !consent.Granted {
+		return false, count, nil
+	}
+	return true, count, nil
+}
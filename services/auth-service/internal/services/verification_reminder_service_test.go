@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/events"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+// reminderFixture is a VerificationReminderService over in-memory repositories
+type reminderFixture struct {
+	service   *VerificationReminderService
+	tokens    *memVerificationTokenRepo
+	reminders *memReminderRepo
+	consents  *memConsentRepo
+	publisher *recordingPublisher
+}
+
+func newReminderFixture(config VerificationReminderConfig, resendLimiter ratelimit.Limiter, users ...*domain.User) *reminderFixture {
+	userRepo := newMemUserRepo(users...)
+	f := &reminderFixture{
+		tokens:    newMemVerificationTokenRepo(),
+		reminders: newMemReminderRepo(),
+		consents:  &memConsentRepo{},
+		publisher: &recordingPublisher{},
+	}
+	verifier := NewEmailVerificationService(userRepo, f.tokens, f.publisher, "https://auth.example.edu", time.Hour, resendLimiter, false)
+	f.service = NewVerificationReminderService(userRepo, f.tokens, f.reminders, f.consents, verifier, ratelimit.NewMemoryLimiter(), config)
+	return f
+}
+
+// count returns the reminders recorded for the user
+func (f *reminderFixture) count(user *domain.User) int {
+	reminder, err := f.reminders.Get(user.ID)
+	if err != nil {
+		return 0
+	}
+	return reminder.Count
+}
+
+func TestVerificationReminderScheduling(t *testing.T) {
+	config := VerificationReminderConfig{Interval: 24 * time.Hour, MaxReminders: 3, BatchSize: 10}
+	registeredEarlier := time.Now().Add(-2 * config.Interval)
+
+	tests := []struct {
+		name      string
+		setup     func(f *reminderFixture, user *domain.User)
+		verified  bool
+		recent    bool
+		throttled bool
+		want      bool
+	}{
+		{name: "registered before the interval", want: true},
+		{name: "registered within the interval", recent: true},
+		{name: "verified", verified: true},
+		{
+			name: "mailed a link within the interval",
+			setup: func(f *reminderFixture, user *domain.User) {
+				f.tokens.Create(domain.NewVerificationToken(user.ID, domain.PurposeEmail, user.Email, "resent-token", farFuture()))
+			},
+		},
+		{
+			name: "declined marketing",
+			setup: func(f *reminderFixture, user *domain.User) {
+				f.consents.Create(domain.NewConsent(user.ID, domain.ConsentDecision{Purpose: domain.ConsentMarketing, TextVersion: "v1"}, "192.0.2.1"))
+			},
+		},
+		{
+			name: "granted marketing",
+			setup: func(f *reminderFixture, user *domain.User) {
+				f.consents.Create(domain.NewConsent(user.ID, domain.ConsentDecision{Purpose: domain.ConsentMarketing, Granted: true, TextVersion: "v1"}, "192.0.2.1"))
+			},
+			want: true,
+		},
+		{name: "email throttled", throttled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newTestUser(t, "ada@example.edu", "password-123")
+			user.IsVerified = tt.verified
+			if !tt.recent {
+				user.CreatedAt = registeredEarlier
+			}
+			f := newReminderFixture(config, fixedLimiter{allowed: !tt.throttled}, user)
+			if tt.setup != nil {
+				tt.setup(f, user)
+			}
+
+			sent, err := f.service.SendDue(context.Background())
+			if err != nil {
+				t.Fatalf("SendDue: %v", err)
+			}
+			reminders := len(f.publisher.ofType(events.EmailVerificationReminder))
+			if (sent == 1) != tt.want || reminders != sent || f.count(user) != sent {
+				t.Errorf("sent %d, published %d, recorded %d; want reminded %v", sent, reminders, f.count(user), tt.want)
+			}
+		})
+	}
+}
+
+func TestVerificationReminderBatches(t *testing.T) {
+	users := unverifiedUsers(t, 5)
+	for _, user := range users {
+		user.CreatedAt = time.Now().Add(-48 * time.Hour)
+	}
+	f := newReminderFixture(VerificationReminderConfig{Interval: 24 * time.Hour, MaxReminders: 1, BatchSize: 2}, ratelimit.NewMemoryLimiter(), users...)
+
+	sent, err := f.service.SendDue(context.Background())
+	if err != nil || sent != 5 {
+		t.Fatalf("SendDue = %d, %v, want every user of every batch reminded", sent, err)
+	}
+	for _, user := range users {
+		if f.count(user) != 1 {
+			t.Errorf("user %s got %d reminders, want 1", user.ID, f.count(user))
+		}
+	}
+}
+
+func TestVerificationReminderCap(t *testing.T) {
+	config := VerificationReminderConfig{Interval: 24 * time.Hour, MaxReminders: 3, BatchSize: 10}
+	user := newTestUser(t, "ada@example.edu", "password-123")
+	user.CreatedAt = time.Now().Add(-2 * config.Interval)
+	f := newReminderFixture(config, fixedLimiter{allowed: true}, user)
+	ctx := context.Background()
+
+	now := time.Now()
+	if reminded, err := f.service.remind(ctx, user, now); err != nil || !reminded {
+		t.Fatalf("first reminder = %v, %v", reminded, err)
+	}
+	// the link just sent restarts the interval
+	if reminded, _ := f.service.remind(ctx, user, now.Add(config.Interval/2)); reminded {
+		t.Error("reminded again within the interval")
+	}
+
+	for day := 2; day <= 5; day++ {
+		reminded, err := f.service.remind(ctx, user, now.Add(time.Duration(day)*config.Interval))
+		if err != nil {
+			t.Fatalf("reminder on day %d: %v", day, err)
+		}
+		if want := day <= config.MaxReminders; reminded != want {
+			t.Errorf("reminded on day %d = %v, want %v", day, reminded, want)
+		}
+	}
+
+	sent := f.publisher.ofType(events.EmailVerificationReminder)
+	if len(sent) != config.MaxReminders || f.count(user) != config.MaxReminders {
+		t.Fatalf("published %d reminders, recorded %d, want %d", len(sent), f.count(user), config.MaxReminders)
+	}
+	for i, event := range sent {
+		if number := payload(t, event)["reminder"]; number != float64(i+1) {
+			t.Errorf("reminder %d numbered %v", i+1, number)
+		}
+	}
+	if reminded, _ := f.service.SendDue(ctx); reminded != 0 {
+		t.Errorf("SendDue reminded %d users past the cap", reminded)
+	}
+}
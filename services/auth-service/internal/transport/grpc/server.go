@@ -0,0 +1,138 @@
+// Package grpc serves the internal gRPC API other UniBazzar services call to
+// validate access tokens and look up users.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+	"github.com/unibazzar/auth-service/internal/transport/grpc/authv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuthServer implements authv1.AuthServiceServer with the services the HTTP
+// API uses, so a token is checked here as AuthMiddleware checks it there
+type AuthServer struct {
+	authv1.UnimplementedAuthServiceServer
+	tokens      *services.AccessTokens
+	activeUsers *services.ActiveUsers
+	userService *services.UserService
+}
+
+// NewAuthServer creates a new AuthServer
+func NewAuthServer(tokens *services.AccessTokens, activeUsers *services.ActiveUsers, userService *services.UserService) *AuthServer {
+	return &AuthServer{tokens: tokens, activeUsers: activeUsers, userService: userService}
+}
+
+// NewServer creates a gRPC server exposing the AuthServer to callers
+// presenting authToken, over TLS when creds is not nil
+func NewServer(authServer *AuthServer, authToken string, creds credentials.TransportCredentials) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(RequireAuthToken(authToken))}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	authv1.RegisterAuthServiceServer(server, authServer)
+	return server
+}
+
+// ValidateToken reports whether the access token is valid, and whose it is.
+// An invalid token is a valid response, not an error. Tokens of inactive
+// accounts are invalid, as are delegated tokens and those of a session that
+// must change its password, which only the auth service's own routes accept.
+func (s *AuthServer) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	claims, err := s.tokens.Validate(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			return &authv1.ValidateTokenResponse{Valid: false}, nil
+		}
+		return nil, statusError(ctx, err)
+	}
+	if claims.Delegation != nil || claims.MustChangePassword {
+		return &authv1.ValidateTokenResponse{Valid: false}, nil
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return &authv1.ValidateTokenResponse{Valid: false}, nil
+	}
+	active, err := s.activeUsers.IsActive(ctx, userID)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	if !active {
+		return &authv1.ValidateTokenResponse{Valid: false}, nil
+	}
+
+	role := domain.Role(claims.Role)
+	response := &authv1.ValidateTokenResponse{
+		Valid:  true,
+		UserId: claims.UserID,
+		Role:   claims.Role,
+		Scope:  domain.ParseSessionScopes(claims.Scope).Effective(role).String(),
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	return response, nil
+}
+
+// GetUser returns the user with the given ID
+func (s *AuthServer) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.User, error) {
+	id, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user_id must be a UUID")
+	}
+
+	user, err := s.userService.GetUser(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toProtoUser(user), nil
+}
+
+func toProtoUser(user *domain.User) *authv1.User {
+	protoUser := &authv1.User{
+		Id:         user.ID.String(),
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Role:       string(user.Role),
+		IsActive:   user.IsActive,
+		IsVerified: user.IsVerified,
+		CreatedAt:  timestamppb.New(user.CreatedAt),
+	}
+	if user.CampusID != nil {
+		protoUser.CampusId = *user.CampusID
+	}
+	return protoUser
+}
+
+// statusError maps a service error to its gRPC status, as errorResponse
+// maps it to an HTTP one
+func statusError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, domain.ErrServiceUnavailable):
+		return status.Error(codes.Unavailable, domain.ErrServiceUnavailable.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	}
+	slog.ErrorContext(ctx, "unhandled gRPC error", "error", err)
+	return status.Error(codes.Internal, "internal server error")
+}
@@ -0,0 +1,212 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+	"github.com/unibazzar/auth-service/internal/transport/grpc/authv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testAuthToken = "internal-token-of-at-least-32-bytes!"
+
+// stubUserRepo serves the users ValidateToken checks are active
+type stubUserRepo struct {
+	domain.UserRepository
+	users map[uuid.UUID]*domain.User
+}
+
+func (r stubUserRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// newTestClient serves an AuthServer validating tokens signed with keys
+// for the users over an in-memory connection
+func newTestClient(t *testing.T, keys *services.SigningKeys, users ...*domain.User) authv1.AuthServiceClient {
+	t.Helper()
+	byID := make(map[uuid.UUID]*domain.User)
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	tokens := services.NewAccessTokens(keys, nil, nil, services.NewMemoryBlacklist(), 15*time.Minute)
+	activeUsers := services.NewActiveUsers(stubUserRepo{users: byID}, 0)
+	server := NewServer(NewAuthServer(tokens, activeUsers, nil), testAuthToken, nil)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return authv1.NewAuthServiceClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func signAccessToken(t *testing.T, keys *services.SigningKeys, userID string, exp time.Time) string {
+	t.Helper()
+	return signClaims(t, keys, services.Claims{UserID: userID}, exp)
+}
+
+// signClaims signs an access token of a student session with the claims
+func signClaims(t *testing.T, keys *services.SigningKeys, claims services.Claims, exp time.Time) string {
+	t.Helper()
+	claims.SessionID = uuid.NewString()
+	claims.Role = "student"
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(exp),
+	}
+	token, err := keys.Sign(&claims)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func TestRequireAuthToken(t *testing.T) {
+	client := newTestClient(t, services.NewHMACKeys("test-secret"))
+	req := &authv1.ValidateTokenRequest{Token: "x.y.z"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"missing", context.Background()},
+		{"wrong token", withToken("another-token-of-at-least-32-bytes")},
+		{"not a bearer token", metadata.AppendToOutgoingContext(context.Background(), "authorization", testAuthToken)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.ValidateToken(tt.ctx, req)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Fatalf("ValidateToken = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	keys := services.NewHMACKeys("test-secret")
+	user := &domain.User{ID: uuid.New(), IsActive: true}
+	client := newTestClient(t, keys, user)
+	ctx := withToken(testAuthToken)
+
+	userID := user.ID.String()
+	exp := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	resp, err := client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: signAccessToken(t, keys, userID, exp)})
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if !resp.GetValid() || resp.GetUserId() != userID || resp.GetRole() != "student" || !resp.GetExpiresAt().AsTime().Equal(exp) {
+		t.Errorf("valid token: %+v", resp)
+	}
+	if resp.GetScope() != "read write" {
+		t.Errorf("scope of an unnarrowed token = %q, want every scope of the role", resp.GetScope())
+	}
+
+	// an action token is signed with the same keys but is no access token
+	actionToken, err := keys.Sign(&services.ActionClaims{
+		Action: "media.upload_avatar",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{"media-service"},
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// expired, foreign and non-access tokens are answered, not failed
+	for name, token := range map[string]string{
+		"expired": signAccessToken(t, keys, userID, time.Now().Add(-time.Minute)),
+		"foreign": signAccessToken(t, services.NewHMACKeys("other-secret"), userID, exp),
+		"action":  actionToken,
+	} {
+		resp, err := client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: token})
+		if err != nil {
+			t.Fatalf("%s token: %v", name, err)
+		}
+		if resp.GetValid() || resp.GetUserId() != "" {
+			t.Errorf("%s token: %+v", name, resp)
+		}
+	}
+
+	if _, err := client.ValidateToken(ctx, &authv1.ValidateTokenRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty token: %v, want InvalidArgument", err)
+	}
+}
+
+// Tokens the HTTP API would refuse or restrict are reported as such
+func TestValidateRestrictedToken(t *testing.T) {
+	keys := services.NewHMACKeys("test-secret")
+	active := &domain.User{ID: uuid.New(), IsActive: true}
+	deactivated := &domain.User{ID: uuid.New(), IsActive: false}
+	client := newTestClient(t, keys, active, deactivated)
+	ctx := withToken(testAuthToken)
+	exp := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name   string
+		claims services.Claims
+		valid  bool
+		scope  string
+	}{
+		{"inactive account", services.Claims{UserID: deactivated.ID.String()}, false, ""},
+		{"deleted account", services.Claims{UserID: uuid.NewString()}, false, ""},
+		{"delegated", services.Claims{
+			UserID:     active.ID.String(),
+			Scope:      string(domain.ScopeDelegated),
+			Delegation: &services.DelegationContext{ID: uuid.NewString(), GrantorID: uuid.NewString()},
+		}, false, ""},
+		{"must change password", services.Claims{UserID: active.ID.String(), MustChangePassword: true}, false, ""},
+		{"narrowed to read", services.Claims{UserID: active.ID.String(), Scope: "read"}, true, "read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: signClaims(t, keys, tt.claims, exp)})
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if resp.GetValid() != tt.valid || resp.GetScope() != tt.scope {
+				t.Errorf("ValidateToken = %+v, want valid %v with scope %q", resp, tt.valid, tt.scope)
+			}
+			if !tt.valid && resp.GetUserId() != "" {
+				t.Errorf("invalid token reported for user %s", resp.GetUserId())
+			}
+		})
+	}
+}
+
+func TestGetUserRejectsMalformedID(t *testing.T) {
+	client := newTestClient(t, services.NewHMACKeys("test-secret"))
+
+	_, err := client.GetUser(withToken(testAuthToken), &authv1.GetUserRequest{UserId: "not-a-uuid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("GetUser = %v, want InvalidArgument", err)
+	}
+}
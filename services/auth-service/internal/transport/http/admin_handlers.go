@@ -0,0 +1,595 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// Pagination bounds for list endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// exportPageWriteTimeout is the time allowed to write each page of a
+// streamed export, replacing the server's overall write timeout
+const exportPageWriteTimeout = 15 * time.Second
+
+// AdminHandlers exposes the administrator endpoints
+type AdminHandlers struct {
+	adminService *services.AdminService
+}
+
+// NewAdminHandlers creates the admin handlers
+func NewAdminHandlers(adminService *services.AdminService) *AdminHandlers {
+	return &AdminHandlers{adminService: adminService}
+}
+
+// ListUsers lists a page of users, filtered by the optional role, is_active,
+// is_verified and campus_id query parameters
+func (h *AdminHandlers) ListUsers(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+	isActive, ok := boolParam(c, "is_active")
+	if !ok {
+		return
+	}
+	isVerified, ok := boolParam(c, "is_verified")
+	if !ok {
+		return
+	}
+
+	filter := domain.UserFilter{IsActive: isActive, IsVerified: isVerified, Limit: limit, Offset: offset}
+	if value := c.Query("role"); value != "" {
+		role := domain.Role(value)
+		filter.Role = &role
+	}
+	if value := c.Query("campus_id"); value != "" {
+		filter.CampusID = &value
+	}
+
+	page, err := h.adminService.ListUsers(c.Request.Context(), currentCampusScope(c), filter)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// CountUsersByRole returns the number of users per role for dashboard tiles
+func (h *AdminHandlers) CountUsersByRole(c *gin.Context) {
+	counts, err := h.adminService.CountUsersByRole(c.Request.Context())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"counts": counts})
+}
+
+// BulkAssignRole assigns one role to many users, reporting the outcome per user
+func (h *AdminHandlers) BulkAssignRole(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.BulkRoleAssignment
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	results, err := h.adminService.BulkAssignRole(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// ChangeRole sets a user's role
+func (h *AdminHandlers) ChangeRole(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req domain.RoleChange
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.adminService.ChangeRole(c.Request.Context(), callerID, userID, req.Role, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// RequirePasswordChange forces a user to change their password on next login
+func (h *AdminHandlers) RequirePasswordChange(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req domain.PasswordChangeRequirement
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.adminService.RequirePasswordChange(c.Request.Context(), callerID, userID, req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeactivateUser disables a user's account until an admin activates it
+func (h *AdminHandlers) DeactivateUser(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := h.adminService.DeactivateUser(c.Request.Context(), callerID, userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ActivateUser activates a disabled or deactivated user account
+func (h *AdminHandlers) ActivateUser(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := h.adminService.ActivateUser(c.Request.Context(), callerID, userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// RestoreUser restores a deleted user account
+func (h *AdminHandlers) RestoreUser(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.adminService.RestoreUser(c.Request.Context(), callerID, userID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUser returns a user's account
+func (h *AdminHandlers) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := h.adminService.GetUser(c.Request.Context(), currentCampusScope(c), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ListUserAudit returns a page of a user's audit trail, newest first
+func (h *AdminHandlers) ListUserAudit(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.adminService.ListUserAudit(c.Request.Context(), currentCampusScope(c), userID, limit, offset)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ScheduleRevocation schedules a global or per-user session revocation cutoff
+func (h *AdminHandlers) ScheduleRevocation(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.RevocationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	cutoff, err := h.adminService.ScheduleRevocation(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cutoff)
+}
+
+// RevokeRefreshToken revokes the session holding the given refresh token
+func (h *AdminHandlers) RevokeRefreshToken(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.TokenRevocationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	session, err := h.adminService.RevokeRefreshToken(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// DecodeToken verifies and decodes an access token, showing its claims,
+// signing key and session to help debug integrations
+func (h *AdminHandlers) DecodeToken(c *gin.Context) {
+	var req domain.TokenDecodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	decoding, err := h.adminService.DecodeToken(c.Request.Context(), req.Token)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, decoding)
+}
+
+// ListDeviceSessions lists the active sessions of every user opened on the
+// device given by the device_id query parameter
+func (h *AdminHandlers) ListDeviceSessions(c *gin.Context) {
+	deviceID := c.Query("device_id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+		return
+	}
+
+	sessions, err := h.adminService.ListDeviceSessions(c.Request.Context(), deviceID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeDeviceSessions revokes every active session opened on a device
+func (h *AdminHandlers) RevokeDeviceSessions(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.DeviceRevocationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.adminService.RevokeDeviceSessions(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MergeAccounts merges one account into another. A merge with unresolved
+// conflicts answers 409 with the conflict report so the caller can choose.
+func (h *AdminHandlers) MergeAccounts(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.MergeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	report, err := h.adminService.MergeAccounts(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if errors.Is(err, services.ErrMergeConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportCampusUsers streams the users of a campus for offboarding, as CSV
+// (format=csv, the default) or as a JSON array (format=json). Rows are
+// written as each page is read; when the export fails midway the response
+// is cut short, leaving a CSV without its last rows or an unterminated array.
+func (h *AdminHandlers) ExportCampusUsers(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+	campusID := c.Param("id")
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+
+	csvWriter := csv.NewWriter(c.Writer)
+	started, first := false, true
+	start := func() error {
+		started = true
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="campus-%s-users.%s"`, campusID, format))
+		if format == "json" {
+			c.Header("Content-Type", "application/json")
+			c.Status(http.StatusOK)
+			_, err := c.Writer.WriteString("[")
+			return err
+		}
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		return csvWriter.Write(domain.CampusUserRecordFields)
+	}
+
+	emit := func(records []*domain.CampusUserRecord) error {
+		// not every writer supports deadlines; the server timeout then applies
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(exportPageWriteTimeout))
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, record := range records {
+			if format == "json" {
+				data, err := json.Marshal(record)
+				if err != nil {
+					return err
+				}
+				if !first {
+					c.Writer.WriteString(",")
+				}
+				first = false
+				if _, err := c.Writer.Write(data); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := csvWriter.Write(record.CSV()); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		c.Writer.Flush()
+		return csvWriter.Error()
+	}
+
+	_, err := h.adminService.ExportCampusUsers(c.Request.Context(), callerID, campusID, format, emit, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if !started {
+			errorResponse(c, err)
+			return
+		}
+		log.Printf("Campus %s export failed midway: %v", campusID, err)
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	if format == "json" {
+		c.Writer.WriteString("]")
+	}
+	csvWriter.Flush()
+}
+
+// SignupFunnel reports registrations, verifications and first logins per day.
+// from and to are inclusive dates (YYYY-MM-DD) defaulting to the last 30 days;
+// by_campus=true splits each day per campus.
+func (h *AdminHandlers) SignupFunnel(c *gin.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, ok := dateParam(c, "from", today.AddDate(0, 0, -29))
+	if !ok {
+		return
+	}
+	to, ok := dateParam(c, "to", today)
+	if !ok {
+		return
+	}
+
+	funnel, err := h.adminService.SignupFunnel(c.Request.Context(), domain.SignupFunnelQuery{
+		From:     from,
+		To:       to.AddDate(0, 0, 1),
+		ByCampus: c.Query("by_campus") == "true",
+	})
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"funnel": funnel})
+}
+
+// dateParam reads a YYYY-MM-DD query parameter, writing a 400 when it is invalid
+func dateParam(c *gin.Context, name string, fallback time.Time) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, true
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a date formatted as YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// boolParam reads an optional true/false query parameter, writing a 400 when
+// it is invalid
+func boolParam(c *gin.Context, name string) (*bool, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be true or false"})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// VerifyAuditChain checks the integrity of the audit chain over the sequence
+// range given by the from and to query parameters, by default all of it
+func (h *AdminHandlers) VerifyAuditChain(c *gin.Context) {
+	from, ok := sequenceParam(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := sequenceParam(c, "to")
+	if !ok {
+		return
+	}
+
+	report, err := h.adminService.VerifyAuditChain(c.Request.Context(), from, to)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// sequenceParam reads an optional audit sequence query parameter, 0 when
+// absent, writing a 400 when it is invalid
+func sequenceParam(c *gin.Context, name string) (int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
+		return 0, false
+	}
+	return seq, true
+}
+
+// pagination reads the limit and offset query parameters, writing a 400 when they are invalid
+func pagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit, offset = defaultPageSize, 0
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxPageSize)})
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	return limit, offset, true
+}
+
+// TestEventDelivery publishes a system.test event and reports whether the
+// broker confirmed it and how long that took
+func (h *AdminHandlers) TestEventDelivery(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	result, err := h.adminService.TestEventDelivery(c.Request.Context(), callerID, c.ClientIP(), c.Request.UserAgent())
+	if errors.Is(err, services.ErrEventDeliveryFailed) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+type eventPreviewRequest struct {
+	Action string `json:"action" validate:"required"`
+}
+
+// PreviewEvents shows the events an action would emit, with sample payloads,
+// without performing it
+func (h *AdminHandlers) PreviewEvents(c *gin.Context) {
+	var req eventPreviewRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	preview, err := h.adminService.PreviewEvents(req.Action)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// campusUserRepo lists the users it holds by campus, in ID order
+type campusUserRepo struct {
+	domain.UserRepository
+	users []*domain.User
+}
+
+func (r campusUserRepo) ListByCampus(_ context.Context, campusID string, after uuid.UUID, limit int) ([]*domain.User, error) {
+	var matching []*domain.User
+	for _, user := range r.users {
+		if *user.CampusID == campusID && user.ID.String() > after.String() {
+			matching = append(matching, user)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID.String() < matching[j].ID.String() })
+	if limit < len(matching) {
+		matching = matching[:limit]
+	}
+	return matching, nil
+}
+
+// stubAuditRepo keeps the entries created
+type stubAuditRepo struct {
+	domain.AuditRepository
+	entries []*domain.AuditLog
+}
+
+func (r *stubAuditRepo) Create(entry *domain.AuditLog) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// exportRouter serves the campus export behind the admin group's checks
+func exportRouter(auth *testAuth, users []*domain.User, audit *stubAuditRepo) *gin.Engine {
+	adminService := services.NewAdminService(campusUserRepo{users: users}, nil, audit, nil, nil, nil, domain.PasswordPolicy{}, auth.tokens, auth.activeUsers)
+	router := gin.New()
+	admin := router.Group("/admin", AuthMiddleware(auth.tokens, auth.activeUsers), RequireRole(domain.RoleAdmin))
+	admin.GET("/campuses/:id/users/export", NewAdminHandlers(adminService).ExportCampusUsers)
+	return router
+}
+
+func campusUsers(campusID string, emails ...string) []*domain.User {
+	var users []*domain.User
+	for _, email := range emails {
+		campus := campusID
+		users = append(users, &domain.User{ID: uuid.New(), Email: email, FirstName: "Ada", LastName: "Lovelace, Jr.", Role: domain.RoleStudent, CampusID: &campus, IsActive: true})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	return users
+}
+
+func TestExportCampusUsersFormats(t *testing.T) {
+	auth := newTestAuth()
+	north := campusUsers("north", "ada@north.example.edu", "grace@north.example.edu")
+	audit := &stubAuditRepo{}
+	router := exportRouter(auth, append(north, campusUsers("south", "alan@south.example.edu")...), audit)
+	_, token := auth.token(t, "admin@example.edu", domain.RoleAdmin, "")
+
+	rec := serve(router, http.MethodGet, "/admin/campuses/north/users/export", token)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("csv export: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("csv export does not parse: %v", err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(domain.CampusUserRecordFields, ",") {
+		t.Fatalf("csv rows = %v, want the header and 2 users", rows)
+	}
+	for i, user := range north {
+		if rows[i+1][0] != user.ID.String() || rows[i+1][1] != user.Email || rows[i+1][3] != "Lovelace, Jr." {
+			t.Errorf("row %d = %v, want user %s", i+1, rows[i+1], user.Email)
+		}
+	}
+
+	rec = serve(router, http.MethodGet, "/admin/campuses/north/users/export?format=json", token)
+	var records []domain.CampusUserRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("json export: %d %v\n%s", rec.Code, err, rec.Body)
+	}
+	if len(records) != 2 || records[0].ID != north[0].ID || records[1].ID != north[1].ID {
+		t.Errorf("json records = %+v", records)
+	}
+
+	// an empty campus is still a well-formed export
+	rec = serve(router, http.MethodGet, "/admin/campuses/east/users/export?format=json", token)
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]" {
+		t.Errorf("empty campus: %d %s", rec.Code, rec.Body)
+	}
+
+	if len(audit.entries) != 3 {
+		t.Errorf("audited %d exports, want 3", len(audit.entries))
+	}
+}
+
+func TestExportCampusUsersAccess(t *testing.T) {
+	auth := newTestAuth()
+	audit := &stubAuditRepo{}
+	router := exportRouter(auth, campusUsers("north", "ada@north.example.edu"), audit)
+	_, admin := auth.token(t, "admin@example.edu", domain.RoleAdmin, "")
+
+	for _, role := range []domain.Role{domain.RoleStudent, domain.RoleModerator} {
+		_, token := auth.token(t, string(role)+"@example.edu", role, "")
+		if rec := serve(router, http.MethodGet, "/admin/campuses/north/users/export", token); rec.Code != http.StatusForbidden {
+			t.Errorf("%s export: status %d, want 403", role, rec.Code)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/admin/campuses/north/users/export", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous export: status %d, want 401", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/admin/campuses/Main_Campus/users/export", admin); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed campus: status %d, want 400", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/admin/campuses/north/users/export?format=xml", admin); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: status %d, want 400", rec.Code)
+	}
+	if len(audit.entries) != 0 {
+		t.Errorf("refused exports were audited: %+v", audit.entries)
+	}
+}
+
+func TestVerifyAuditChainRejectsBadSequences(t *testing.T) {
+	auth := newTestAuth()
+	router := gin.New()
+	admin := router.Group("/admin", AuthMiddleware(auth.tokens, auth.activeUsers), RequireRole(domain.RoleAdmin))
+	admin.GET("/audit/verify", NewAdminHandlers(nil).VerifyAuditChain)
+	_, token := auth.token(t, "admin@example.edu", domain.RoleAdmin, "")
+
+	for _, query := range []string{"from=abc", "from=0", "to=-3", "from=1&to=1.5"} {
+		if rec := serve(router, http.MethodGet, "/admin/audit/verify?"+query, token); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
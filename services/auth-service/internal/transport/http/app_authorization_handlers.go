@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// AppAuthorizationHandlers exposes the user endpoints reviewing and revoking
+// the OAuth clients the user authorized
+type AppAuthorizationHandlers struct {
+	authorizationService *services.AppAuthorizationService
+}
+
+// NewAppAuthorizationHandlers creates the app authorization handlers
+func NewAppAuthorizationHandlers(authorizationService *services.AppAuthorizationService) *AppAuthorizationHandlers {
+	return &AppAuthorizationHandlers{authorizationService: authorizationService}
+}
+
+// List returns the apps the authenticated user authorized
+func (h *AppAuthorizationHandlers) List(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	authorizations, err := h.authorizationService.List(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authorizations": authorizations})
+}
+
+// Revoke withdraws the authenticated user's authorization of an app
+func (h *AppAuthorizationHandlers) Revoke(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	if err := h.authorizationService.Revoke(c.Request.Context(), userID, c.Param("clientId")); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
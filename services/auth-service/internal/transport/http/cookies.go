@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/config"
+)
+
+// setCookie sets an HttpOnly cookie with the configured domain, path,
+// SameSite and Secure attributes, expiring after maxAge seconds
+func setCookie(c *gin.Context, settings config.CookieSettings, name, value string, maxAge int) {
+	c.SetSameSite(settings.SameSite)
+	c.SetCookie(name, value, maxAge, settings.Path, settings.Domain, settings.Secure, true)
+}
+
+// clearCookie expires a cookie set by setCookie. Browsers only drop it when
+// the domain and path match the ones it was set with.
+func clearCookie(c *gin.Context, settings config.CookieSettings, name string) {
+	setCookie(c, settings, name, "", -1)
+}
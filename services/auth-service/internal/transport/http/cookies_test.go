@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/config"
+)
+
+// cookieOf answers a request with handler and returns the one cookie it set
+func cookieOf(t *testing.T, handler gin.HandlerFunc) *http.Cookie {
+	t.Helper()
+	router := gin.New()
+	router.GET("/", handler)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("%d cookies set, want 1: %v", len(cookies), rec.Header()["Set-Cookie"])
+	}
+	return cookies[0]
+}
+
+func TestCookieAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.CookieSettings
+	}{
+		{"host only", config.CookieSettings{Path: "/api/v1/auth", SameSite: http.SameSiteLaxMode}},
+		{"shared across subdomains", config.CookieSettings{Domain: "campus.edu", Path: "/", SameSite: http.SameSiteNoneMode, Secure: true}},
+		{"strict", config.CookieSettings{Path: "/api/v1/auth/oauth", SameSite: http.SameSiteStrictMode, Secure: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := cookieOf(t, func(c *gin.Context) { setCookie(c, tt.settings, "state", "nonce", 600) })
+			cleared := cookieOf(t, func(c *gin.Context) { clearCookie(c, tt.settings, "state") })
+
+			for name, cookie := range map[string]*http.Cookie{"set": set, "cleared": cleared} {
+				if cookie.Name != "state" || cookie.Domain != tt.settings.Domain || cookie.Path != tt.settings.Path {
+					t.Errorf("%s cookie %q on domain %q and path %q, want the configured ones", name, cookie.Name, cookie.Domain, cookie.Path)
+				}
+				if cookie.SameSite != tt.settings.SameSite || cookie.Secure != tt.settings.Secure || !cookie.HttpOnly {
+					t.Errorf("%s cookie SameSite %v, Secure %v, HttpOnly %v; want %v, %v, true",
+						name, cookie.SameSite, cookie.Secure, cookie.HttpOnly, tt.settings.SameSite, tt.settings.Secure)
+				}
+			}
+			if set.Value != "nonce" || set.MaxAge != 600 {
+				t.Errorf("set cookie %q for %d seconds", set.Value, set.MaxAge)
+			}
+			if cleared.Value != "" || cleared.MaxAge >= 0 {
+				t.Errorf("cleared cookie %q for %d seconds, want expired at once", cleared.Value, cleared.MaxAge)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/events"
+)
+
+// corsMaxAge is how long browsers may cache a preflight response
+const corsMaxAge = 10 * time.Minute
+
+// corsRequiredHeaders are allowed whatever is configured: every API client
+// sends them
+var corsRequiredHeaders = []string{"Authorization", "Content-Type"}
+
+// corsExposedHeaders are the response headers scripts of allowed origins may read
+var corsExposedHeaders = []string{events.RequestIDHeader, "Retry-After", "ETag", "X-RateLimit-Limit", "X-RateLimit-Remaining"}
+
+// CORSConfig lists what cross-origin browser requests may do
+type CORSConfig struct {
+	// AllowedOrigins are the origins, e.g. https://app.campus.edu, allowed
+	// to call the API; "*" allows any, and none disables CORS
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies with cross-origin requests
+	AllowCredentials bool
+}
+
+// CORSMiddleware answers preflight requests and adds the CORS headers to
+// requests from allowed origins. The allowed origin is echoed back rather
+// than "*" whenever credentials are allowed. It must be installed on the
+// router itself, so preflights of routes without an OPTIONS handler reach it
+// and never hit the rate limits or AuthMiddleware.
+func CORSMiddleware(config CORSConfig) gin.HandlerFunc {
+	origins := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		origins[origin] = true
+	}
+	anyOrigin := origins["*"]
+
+	headers := append([]string{}, corsRequiredHeaders...)
+	for _, header := range config.AllowedHeaders {
+		if !containsFold(headers, header) {
+			headers = append(headers, header)
+		}
+	}
+	allowMethods := strings.Join(config.AllowedMethods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(corsMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(origins) == 0 {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin && !origins[origin] {
+			// without CORS headers the browser refuses the response
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin && !config.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+			header.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", exposeHeaders)
+		c.Next()
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// DelegationHandlers exposes the endpoints users delegate access to their
+// account with, and the read-only endpoints delegates use
+type DelegationHandlers struct {
+	delegationService *services.DelegationService
+}
+
+// NewDelegationHandlers creates the delegation handlers
+func NewDelegationHandlers(delegationService *services.DelegationService) *DelegationHandlers {
+	return &DelegationHandlers{delegationService: delegationService}
+}
+
+// Grant delegates read access to parts of the authenticated user's account
+func (h *DelegationHandlers) Grant(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.DelegationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	delegation, err := h.delegationService.Grant(c.Request.Context(), userID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, delegation)
+}
+
+// List returns the delegations the authenticated user granted or received
+func (h *DelegationHandlers) List(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	delegations, err := h.delegationService.List(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delegations": delegations})
+}
+
+// Revoke ends a delegation the authenticated user granted or received
+func (h *DelegationHandlers) Revoke(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delegation id"})
+		return
+	}
+
+	if err := h.delegationService.Revoke(c.Request.Context(), userID, id, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// IssueToken issues the authenticated delegate a token for a delegation
+func (h *DelegationHandlers) IssueToken(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delegation id"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.GetString(ContextSessionID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	token, err := h.delegationService.IssueToken(c.Request.Context(), userID, sessionID, id)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// Profile returns the grantor's limited profile to a delegate
+func (h *DelegationHandlers) Profile(c *gin.Context) {
+	h.view(c, domain.DelegationProfile)
+}
+
+// Contact returns the grantor's contact details to a delegate
+func (h *DelegationHandlers) Contact(c *gin.Context) {
+	h.view(c, domain.DelegationContact)
+}
+
+func (h *DelegationHandlers) view(c *gin.Context, scope domain.DelegationScope) {
+	profile, err := h.delegationService.Profile(c.Request.Context(), currentDelegation(c), scope)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
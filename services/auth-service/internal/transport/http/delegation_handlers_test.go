@@ -0,0 +1,121 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// stubDelegationRepo serves delegations by ID
+type stubDelegationRepo struct {
+	domain.DelegationRepository
+	delegations map[uuid.UUID]*domain.Delegation
+}
+
+func (r stubDelegationRepo) GetByID(id uuid.UUID) (*domain.Delegation, error) {
+	delegation, ok := r.delegations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return delegation, nil
+}
+
+// delegatedToken returns an access token the delegate reads the grantor's
+// account with, as DelegationService issues them
+func (a *testAuth) delegatedToken(t *testing.T, delegation *domain.Delegation) string {
+	t.Helper()
+	now := time.Now()
+	token, err := a.keys.Sign(&services.Claims{
+		UserID:    delegation.DelegateID.String(),
+		SessionID: uuid.NewString(),
+		Scope:     string(domain.ScopeDelegated),
+		Delegation: &services.DelegationContext{
+			ID:        delegation.ID.String(),
+			GrantorID: delegation.GrantorID.String(),
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(15 * time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
+
+func TestDelegatedRoutes(t *testing.T) {
+	auth := newTestAuth()
+	grantorID, grantorToken := auth.token(t, "ada@example.edu", domain.RoleStudent, "")
+	auth.users[grantorID].FirstName = "Ada"
+	advisorID, _ := auth.token(t, "advisor@example.edu", domain.RoleStudent, "")
+	strangerID, _ := auth.token(t, "alan@example.edu", domain.RoleStudent, "")
+
+	delegation := domain.NewDelegation(grantorID, advisorID, domain.DelegationRequest{Scopes: []string{"profile"}})
+	repo := stubDelegationRepo{delegations: map[uuid.UUID]*domain.Delegation{delegation.ID: delegation}}
+	delegationService := services.NewDelegationService(repo, stubUserRepo{users: auth.users}, nil, nil, auth.tokens)
+	handlers := NewDelegationHandlers(delegationService)
+
+	router := gin.New()
+	users := router.Group("/users", AuthMiddleware(auth.tokens, auth.activeUsers), RequireSessionScope())
+	users.GET("/profile", whoAmI)
+	users.PUT("/profile", whoAmI)
+	delegated := router.Group("/delegated", AuthMiddleware(auth.tokens, auth.activeUsers))
+	delegated.GET("/profile", RequireDelegation(delegationService, domain.DelegationProfile), handlers.Profile)
+	delegated.GET("/contact", RequireDelegation(delegationService, domain.DelegationContact), handlers.Contact)
+
+	token := auth.delegatedToken(t, delegation)
+	rec := serve(router, http.MethodGet, "/delegated/profile", token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("profile within scope: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var profile domain.DelegatedProfile
+	if err := json.Unmarshal(rec.Body.Bytes(), &profile); err != nil || profile.ID != grantorID || profile.FirstName != "Ada" || profile.Email != "" {
+		t.Errorf("delegated profile = %s, want the grantor's limited profile", rec.Body)
+	}
+
+	// theft of another delegation's ID does not let a stranger in
+	stolen := *delegation
+	stolen.DelegateID = strangerID
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+		code   string
+	}{
+		{"contact beyond scope", http.MethodGet, "/delegated/contact", token, http.StatusForbidden, "delegation_scope"},
+		{"own profile with a delegated token", http.MethodGet, "/users/profile", token, http.StatusForbidden, "insufficient_scope"},
+		{"profile change with a delegated token", http.MethodPut, "/users/profile", token, http.StatusForbidden, "insufficient_scope"},
+		{"regular token on a delegated route", http.MethodGet, "/delegated/profile", grantorToken, http.StatusForbidden, "delegation_required"},
+		{"token of another user", http.MethodGet, "/delegated/profile", auth.delegatedToken(t, &stolen), http.StatusNotFound, "delegation_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, tt.method, tt.path, tt.token)
+			// middleware answers with a code, service errors with an error_code
+			var body map[string]interface{}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if rec.Code != tt.want || (body["code"] != tt.code && body["error_code"] != tt.code) {
+				t.Errorf("status = %d, body %s, want %d with code %s", rec.Code, rec.Body, tt.want, tt.code)
+			}
+		})
+	}
+
+	// revoking the delegation locks out the tokens already issued for it
+	revokedAt := time.Now()
+	delegation.RevokedAt = &revokedAt
+	if rec := serve(router, http.MethodGet, "/delegated/profile", token); rec.Code != http.StatusNotFound {
+		t.Errorf("profile after revocation: status = %d, want 404", rec.Code)
+	}
+}
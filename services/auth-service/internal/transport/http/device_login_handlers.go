@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// DeviceLoginHandlers exposes the kiosk device login endpoints
+type DeviceLoginHandlers struct {
+	deviceLoginService *services.DeviceLoginService
+}
+
+// NewDeviceLoginHandlers creates the device login handlers
+func NewDeviceLoginHandlers(deviceLoginService *services.DeviceLoginService) *DeviceLoginHandlers {
+	return &DeviceLoginHandlers{deviceLoginService: deviceLoginService}
+}
+
+// Start begins a login on the calling kiosk. Mount after RequireKioskKey.
+func (h *DeviceLoginHandlers) Start(c *gin.Context) {
+	authorization, err := h.deviceLoginService.Start(c.Request.Context(), c.GetString(ContextKioskID))
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, authorization)
+}
+
+// Approve lets the authenticated user approve the login shown on a kiosk
+func (h *DeviceLoginHandlers) Approve(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.DeviceLoginApproval
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.deviceLoginService.Approve(c.Request.Context(), userID, req.UserCode); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "device login approved"})
+}
+
+// Poll returns the tokens once the login identified by the device_code query
+// parameter has been approved. Mount after RequireKioskKey.
+func (h *DeviceLoginHandlers) Poll(c *gin.Context) {
+	deviceCode := c.Query("device_code")
+	if deviceCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_code is required"})
+		return
+	}
+
+	tokens, err := h.deviceLoginService.Poll(c.Request.Context(), c.GetString(ContextKioskID), deviceCode, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// EmailChangeHandlers exposes the login email change endpoints
+type EmailChangeHandlers struct {
+	emailChangeService *services.EmailChangeService
+}
+
+// NewEmailChangeHandlers creates the email change handlers
+func NewEmailChangeHandlers(emailChangeService *services.EmailChangeService) *EmailChangeHandlers {
+	return &EmailChangeHandlers{emailChangeService: emailChangeService}
+}
+
+// RequestEmailChange sends a confirmation to the requested new login email
+func (h *EmailChangeHandlers) RequestEmailChange(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.EmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.emailChangeService.RequestEmailChange(c.Request.Context(), userID, req.NewEmail); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation sent to new email"})
+}
+
+// ConfirmEmailChange confirms ownership of the new login email and switches to it
+func (h *EmailChangeHandlers) ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	if err := h.emailChangeService.ConfirmEmailChange(c.Request.Context(), token, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email changed"})
+}
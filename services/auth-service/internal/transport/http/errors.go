@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// serviceUnavailableRetryAfter is the Retry-After, in seconds, of responses
+// refused while the database is saturated
+const serviceUnavailableRetryAfter = 1
+
+// errorMapping is the response of a service error. Codes are part of the API:
+// clients branch on error_code, so they must never change.
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+	// legacyCode also sets code, which clients predating error_code read
+	legacyCode bool
+}
+
+// errorMappings are checked in order with errors.Is; the first match wins
+var errorMappings = []errorMapping{
+	{services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials", false},
+	{services.ErrInvalidToken, http.StatusUnauthorized, "invalid_token", false},
+	{services.ErrInvalidTwoFactorCode, http.StatusUnauthorized, "invalid_2fa_code", false},
+	{services.ErrInvalidClient, http.StatusUnauthorized, "invalid_client", false},
+	{services.ErrInvalidPin, http.StatusUnauthorized, "invalid_pin", false},
+	{services.ErrPinLocked, http.StatusUnauthorized, "pin_locked", true},
+	{services.ErrSessionMaxAge, http.StatusUnauthorized, "session_expired", true},
+
+	{services.ErrAccountInactive, http.StatusForbidden, "account_inactive", false},
+	{services.ErrAccountSuspended, http.StatusForbidden, "account_suspended", false},
+	{services.ErrAccountBanned, http.StatusForbidden, "account_banned", false},
+	{services.ErrAccountDisabled, http.StatusForbidden, "account_disabled", false},
+	{services.ErrAccountMerged, http.StatusForbidden, "account_merged", false},
+	{services.ErrActionNotPermitted, http.StatusForbidden, "action_not_permitted", false},
+	{services.ErrNoCampus, http.StatusForbidden, "no_campus", false},
+	{services.ErrOutsideCampus, http.StatusForbidden, "outside_campus", false},
+	{services.ErrUnauthorizedClient, http.StatusForbidden, "unauthorized_client", false},
+	{services.ErrDelegationScope, http.StatusForbidden, "delegation_scope", false},
+	{services.ErrOAuthEmailUnverified, http.StatusForbidden, "oauth_email_unverified", false},
+
+	{services.ErrUserNotFound, http.StatusNotFound, "user_not_found", false},
+	{services.ErrSessionNotFound, http.StatusNotFound, "session_not_found", false},
+	{services.ErrTrustedDeviceNotFound, http.StatusNotFound, "trusted_device_not_found", false},
+	{services.ErrMFAMethodNotFound, http.StatusNotFound, "mfa_method_not_found", false},
+	{services.ErrOAuthClientNotFound, http.StatusNotFound, "oauth_client_not_found", false},
+	{services.ErrJobNotFound, http.StatusNotFound, "job_not_found", false},
+	{services.ErrAppAuthorizationNotFound, http.StatusNotFound, "app_authorization_not_found", false},
+	{services.ErrDelegationNotFound, http.StatusNotFound, "delegation_not_found", false},
+	{services.ErrTokenNotFound, http.StatusNotFound, "token_not_found", false},
+	{services.ErrUnknownProvider, http.StatusNotFound, "unknown_provider", false},
+
+	{services.ErrSameAsLoginEmail, http.StatusBadRequest, "same_as_login_email", false},
+	{services.ErrEmailUnchanged, http.StatusBadRequest, "email_unchanged", false},
+	{services.ErrUnknownRole, http.StatusBadRequest, "unknown_role", false},
+	{services.ErrUnknownAction, http.StatusBadRequest, "unknown_action", false},
+	{services.ErrInvalidRedirectURI, http.StatusBadRequest, "invalid_redirect_uri", false},
+	{services.ErrInvalidScope, http.StatusBadRequest, "invalid_scope", false},
+	{services.ErrInvalidRange, http.StatusBadRequest, "invalid_range", false},
+	{services.ErrInvalidMerge, http.StatusBadRequest, "invalid_merge", false},
+	{services.ErrInvalidDelegate, http.StatusBadRequest, "invalid_delegate", false},
+	{services.ErrInvalidOAuthState, http.StatusBadRequest, "invalid_oauth_state", false},
+	{services.ErrMalformedToken, http.StatusBadRequest, "malformed_token", true},
+	{services.ErrInvalidSignature, http.StatusBadRequest, "invalid_signature", true},
+	{services.ErrPinNotSet, http.StatusBadRequest, "pin_not_set", true},
+	{services.ErrPasswordUnchanged, http.StatusBadRequest, "password_unchanged", true},
+	{services.ErrAuthorizationPending, http.StatusBadRequest, "authorization_pending", true},
+	{services.ErrDeviceLoginExpired, http.StatusBadRequest, "expired_token", true},
+
+	{services.ErrEmailTaken, http.StatusConflict, "email_already_exists", false},
+	{services.ErrAccountActive, http.StatusConflict, "account_active", false},
+	{services.ErrAlreadyDisabled, http.StatusConflict, "already_disabled", false},
+	{services.ErrTwoFactorEnabled, http.StatusConflict, "two_factor_enabled", false},
+	{services.ErrTwoFactorDisabled, http.StatusConflict, "two_factor_disabled", false},
+	{services.ErrPhoneTaken, http.StatusConflict, "phone_taken", false},
+	{services.ErrLastMFAMethod, http.StatusConflict, "last_mfa_method", false},
+	{services.ErrMergeConflict, http.StatusConflict, "merge_conflict", false},
+	{services.ErrLastAdmin, http.StatusConflict, "last_admin", false},
+	{services.ErrAlreadyVerified, http.StatusConflict, "already_verified", false},
+
+	{services.ErrSlowDown, http.StatusTooManyRequests, "slow_down", true},
+	{services.ErrTooManyProfileUpdates, http.StatusTooManyRequests, "too_many_profile_updates", false},
+	{services.ErrTooManyLoginAttempts, http.StatusTooManyRequests, "too_many_login_attempts", false},
+	{services.ErrTooManyVerifications, http.StatusTooManyRequests, "too_many_verifications", false},
+	{services.ErrTooManyRegistrations, http.StatusTooManyRequests, "too_many_registrations", false},
+
+	{services.ErrProviderUnavailable, http.StatusBadGateway, "provider_unavailable", true},
+}
+
+// errorResponse maps service errors to HTTP responses carrying the error
+// message and a stable error_code. Errors of no known kind are logged and
+// answered with a bare 500.
+func errorResponse(c *gin.Context, err error) {
+	var validationErr domain.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error(), "error_code": "validation_failed", "field": validationErr.Field})
+		return
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out", "error_code": "timeout", "code": "timeout"})
+		return
+	case errors.Is(err, domain.ErrServiceUnavailable):
+		c.Header("Retry-After", strconv.Itoa(serviceUnavailableRetryAfter))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": domain.ErrServiceUnavailable.Error(), "error_code": "service_unavailable", "code": "service_unavailable"})
+		return
+	case errors.Is(err, services.ErrAccountDeactivated):
+		body := gin.H{"error": err.Error(), "error_code": "account_deactivated", "code": "account_deactivated", "reactivation_available": true}
+		var deactivated services.AccountDeactivatedError
+		if errors.As(err, &deactivated) && deactivated.ReactivateBefore != nil {
+			body["reactivate_before"] = deactivated.ReactivateBefore
+			body["reactivation_remaining_seconds"] = int64(time.Until(*deactivated.ReactivateBefore).Seconds())
+		}
+		c.JSON(http.StatusForbidden, body)
+		return
+	case errors.Is(err, services.ErrReactivationClosed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "error_code": "reactivation_window_expired", "code": "reactivation_window_expired", "reactivation_available": false})
+		return
+	case errors.Is(err, services.ErrVerificationExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error(), "error_code": "token_expired", "code": "token_expired", "resend": "POST /api/v1/auth/resend-verification"})
+		return
+	}
+
+	for _, mapping := range errorMappings {
+		if !errors.Is(err, mapping.err) {
+			continue
+		}
+		body := gin.H{"error": err.Error(), "error_code": mapping.code}
+		if mapping.legacyCode {
+			body["code"] = mapping.code
+		}
+		c.JSON(mapping.status, body)
+		return
+	}
+
+	slog.ErrorContext(c.Request.Context(), "unhandled error", "error", err, "request_id", c.GetString(ContextRequestID))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "error_code": "internal_error"})
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// respond answers a request with the response errorResponse gives err
+func respond(t *testing.T, err error) (int, map[string]interface{}) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) { errorResponse(c, err) })
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response body: %v\n%s", err, rec.Body)
+	}
+	return rec.Code, body
+}
+
+func TestDeactivatedAccountResponses(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Hour)
+
+	status, body := respond(t, services.AccountDeactivatedError{ReactivateBefore: &deadline})
+	if status != http.StatusForbidden || body["error_code"] != "account_deactivated" || body["reactivation_available"] != true {
+		t.Fatalf("within the window: %d %v", status, body)
+	}
+	if before, _ := time.Parse(time.RFC3339Nano, body["reactivate_before"].(string)); !before.Equal(deadline) {
+		t.Errorf("reactivate_before = %v, want %v", body["reactivate_before"], deadline)
+	}
+	if remaining := body["reactivation_remaining_seconds"].(float64); remaining < 7190 || remaining > 7200 {
+		t.Errorf("reactivation_remaining_seconds = %v, want about 7200", remaining)
+	}
+
+	status, body = respond(t, services.AccountDeactivatedError{})
+	if _, ok := body["reactivate_before"]; status != http.StatusForbidden || ok || body["reactivation_available"] != true {
+		t.Errorf("without a window: %d %v", status, body)
+	}
+
+	status, body = respond(t, services.ErrReactivationClosed)
+	if status != http.StatusForbidden || body["error_code"] != "reactivation_window_expired" || body["reactivation_available"] != false {
+		t.Errorf("after the window: %d %v", status, body)
+	}
+}
+
+// A campus over its registration limit gets a 429 of its own, told apart
+// from the per-IP limit by its code
+func TestTooManyRegistrationsResponse(t *testing.T) {
+	status, body := respond(t, services.ErrTooManyRegistrations)
+	if status != http.StatusTooManyRequests || body["error_code"] != "too_many_registrations" {
+		t.Errorf("response = %d %v, want 429 with too_many_registrations", status, body)
+	}
+}
+
+func TestTokenDecodeErrorResponses(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{services.ErrInvalidSignature, http.StatusBadRequest, "invalid_signature"},
+		{services.ErrMalformedToken, http.StatusBadRequest, "malformed_token"},
+		{services.ErrTokenNotFound, http.StatusNotFound, "token_not_found"},
+	}
+	for _, tt := range tests {
+		if status, body := respond(t, tt.err); status != tt.status || body["error_code"] != tt.code || body["error"] != tt.err.Error() {
+			t.Errorf("%v: response = %d %v, want %d with %s", tt.err, status, body, tt.status, tt.code)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonWithETag writes body as JSON with an ETag derived from its content, and
+// answers 304 Not Modified when the client already holds that version. Hashing
+// the response rather than updated_at alone keeps the tag correct for partial
+// views such as public profiles.
+func jsonWithETag(c *gin.Context, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// etagMatches checks an If-None-Match header against etag using weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+)
+
+func getWithETag(router *gin.Engine, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJSONWithETagAcrossUpdate(t *testing.T) {
+	user := &domain.User{ID: uuid.New(), Email: "ada@example.edu", FirstName: "Ada", UpdatedAt: time.Now()}
+	router := gin.New()
+	router.GET("/profile", func(c *gin.Context) { jsonWithETag(c, user) })
+
+	first := getWithETag(router, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.Len() == 0 {
+		t.Fatalf("first read = %d, ETag %q, body %q", first.Code, etag, first.Body)
+	}
+
+	unchanged := getWithETag(router, etag)
+	if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 {
+		t.Errorf("unchanged read = %d with body %q, want an empty 304", unchanged.Code, unchanged.Body)
+	}
+	if got := unchanged.Header().Get("ETag"); got != etag {
+		t.Errorf("304 ETag = %q, want %q", got, etag)
+	}
+
+	user.FirstName = "Augusta"
+	user.UpdatedAt = user.UpdatedAt.Add(time.Second)
+	updated := getWithETag(router, etag)
+	if updated.Code != http.StatusOK {
+		t.Fatalf("read after an update = %d, want 200", updated.Code)
+	}
+	if got := updated.Header().Get("ETag"); got == etag || got == "" {
+		t.Errorf("ETag after an update = %q, want a new tag", got)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`*`, true},
+		{`"xyz"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// Handlers exposes the auth-service HTTP API
+type Handlers struct {
+	userService *services.UserService
+	authService *services.AuthService
+}
+
+// validate checks the `validate` struct tags of request bodies
+var validate = newValidator()
+
+// NewHandlers creates the HTTP handlers
+func NewHandlers(userService *services.UserService, authService *services.AuthService) *Handlers {
+	return &Handlers{
+		userService: userService,
+		authService: authService,
+	}
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Register creates a new account
+func (h *Handlers) Register(c *gin.Context) {
+	var req domain.UserRegistration
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.CreateUser(c.Request.Context(), req)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// GetPasswordPolicy describes the password policy, for clients to give hints
+// matching what the server enforces
+func (h *Handlers) GetPasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.userService.PasswordRequirements())
+}
+
+// Login exchanges credentials for a token pair, or a 2FA challenge
+func (h *Handlers) Login(c *gin.Context) {
+	var req domain.UserLogin
+	if !bindJSON(c, &req) {
+		return
+	}
+	req.Email = domain.NormalizeEmail(req.Email)
+
+	result, err := h.authService.Login(c.Request.Context(), req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RefreshToken rotates a refresh token
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// PinUnlock re-authenticates a session with the account PIN and rotates its tokens
+func (h *Handlers) PinUnlock(c *gin.Context) {
+	var req domain.PinUnlock
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := h.authService.PinUnlock(c.Request.Context(), req)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session of a refresh token. An access token of the
+// session sent as a bearer token stops working too.
+func (h *Handlers) Logout(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken, bearerToken(c), c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetProfile returns the authenticated user
+func (h *Handlers) GetProfile(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	user, err := h.userService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	jsonWithETag(c, user)
+}
+
+// GetSessionStatus describes the caller's session, including any scheduled revocation
+func (h *Handlers) GetSessionStatus(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	sessionID, err := uuid.Parse(c.GetString(ContextSessionID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	status, err := h.authService.SessionStatus(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ListSessions returns the caller's active sessions, marking the current one
+func (h *Handlers) ListSessions(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	currentID, _ := uuid.Parse(c.GetString(ContextSessionID))
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID, currentID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession logs one of the caller's sessions out
+func (h *Handlers) RevokeSession(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(c.Request.Context(), userID, sessionID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions logs the caller out of every session but the current one
+func (h *Handlers) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	currentID, err := uuid.Parse(c.GetString(ContextSessionID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	revoked, err := h.authService.RevokeOtherSessions(c.Request.Context(), userID, currentID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// GetSessionPosture describes the security context of the caller's session
+func (h *Handlers) GetSessionPosture(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	sessionID, err := uuid.Parse(c.GetString(ContextSessionID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	posture, err := h.authService.SessionPosture(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, posture)
+}
+
+// GetUser returns another user's profile as visible to the caller
+func (h *Handlers) GetUser(c *gin.Context) {
+	viewerID, _ := currentUserID(c)
+
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	view, err := h.userService.GetUserView(c.Request.Context(), viewerID, targetID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	jsonWithETag(c, view)
+}
+
+// UpdateProfile updates the authenticated user's profile
+func (h *Handlers) UpdateProfile(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.UserProfile
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword changes the authenticated user's password
+func (h *Handlers) ChangePassword(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.PasswordChange
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed; refresh your tokens to continue"})
+}
+
+// SetPin sets the PIN that unlocks the authenticated user's sessions
+func (h *Handlers) SetPin(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.PinSetup
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.SetPin(c.Request.Context(), userID, req); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemovePin turns PIN unlock off for the authenticated user
+func (h *Handlers) RemovePin(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	if err := h.authService.RemovePin(c.Request.Context(), userID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeactivateAccount deactivates the authenticated user's account
+func (h *Handlers) DeactivateAccount(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	if err := h.userService.Deactivate(c.Request.Context(), userID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Reactivate reactivates a self-deactivated account using its credentials
+func (h *Handlers) Reactivate(c *gin.Context) {
+	var req domain.UserLogin
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.Reactivate(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// IssueActionToken mints a short-lived token scoped to one action on another service
+func (h *Handlers) IssueActionToken(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.ActionTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	token, err := h.authService.IssueActionToken(c.Request.Context(), userID, req.Action)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// DeleteProfile deletes the authenticated user's account
+func (h *Handlers) DeleteProfile(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	if err := h.userService.DeleteUser(c.Request.Context(), userID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindJSON decodes the JSON body into req and validates it, writing a 400
+// when the body cannot be decoded and a 422 listing the invalid fields
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return false
+	}
+	if err := validate.Struct(req); err != nil {
+		renderValidationError(c, err)
+		return false
+	}
+	return true
+}
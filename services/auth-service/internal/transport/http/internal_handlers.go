@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// InternalHandlers serves the routes other UniBazzar services call
+type InternalHandlers struct {
+	userService *services.UserService
+}
+
+// NewInternalHandlers creates the service-to-service handlers
+func NewInternalHandlers(userService *services.UserService) *InternalHandlers {
+	return &InternalHandlers{userService: userService}
+}
+
+// BatchGetUsers resolves up to 200 user IDs to their internal profiles in
+// one call, keyed by ID. IDs of no user, or of a
+// deleted one, are left out of the map.
+func (h *InternalHandlers) BatchGetUsers(c *gin.Context) {
+	var req domain.UserBatchLookup
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	users, err := h.userService.GetUsers(c.Request.Context(), req.IDs)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	profiles := make(map[string]domain.InternalProfile, len(users))
+	for _, user := range users {
+		profiles[user.ID.String()] = user.InternalProfile()
+	}
+	c.JSON(http.StatusOK, gin.H{"users": profiles})
+}
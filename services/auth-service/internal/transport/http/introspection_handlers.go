@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// IntrospectionHandlers exposes token introspection to registered clients,
+// so resource servers and the API gateway can check tokens in real time
+type IntrospectionHandlers struct {
+	clientService *services.OAuthClientService
+	authService   *services.AuthService
+	tokens        *services.AccessTokens
+}
+
+// NewIntrospectionHandlers creates the token introspection handlers
+func NewIntrospectionHandlers(clientService *services.OAuthClientService, authService *services.AuthService, tokens *services.AccessTokens) *IntrospectionHandlers {
+	return &IntrospectionHandlers{clientService: clientService, authService: authService, tokens: tokens}
+}
+
+// Introspect describes the access or refresh token posted in the token form
+// field, in the style of RFC 7662. The token_type_hint field only decides
+// which kind is looked up first. The caller authenticates as a registered
+// client with HTTP Basic credentials or the client_id and client_secret
+// fields.
+func (h *IntrospectionHandlers) Introspect(c *gin.Context) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if _, err := h.clientService.AuthenticateClient(c.Request.Context(), clientID, secret); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	introspectors := []func(context.Context, string) (*services.TokenIntrospection, error){
+		h.tokens.Introspect, h.authService.IntrospectRefreshToken,
+	}
+	if c.PostForm("token_type_hint") == services.TokenTypeRefresh {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+
+	var introspection *services.TokenIntrospection
+	for _, introspect := range introspectors {
+		var err error
+		introspection, err = introspect(c.Request.Context(), token)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		if introspection.Active {
+			break
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, introspection)
+}
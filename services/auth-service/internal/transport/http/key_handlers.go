@@ -0,0 +1,86 @@
+package http
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// publicKeyCacheControl lets consumers cache the keys while still picking up rotations within the hour
+const publicKeyCacheControl = "public, max-age=3600"
+
+// KeyHandlers exposes the token verification keys to other services
+type KeyHandlers struct {
+	keys    []*services.SigningKeys
+	rotator *services.KeyRotator
+}
+
+// NewKeyHandlers creates the key handlers. alternate holds the keys of the
+// other algorithm issued to some clients, or nil; rotator is nil when key
+// rotation is disabled.
+func NewKeyHandlers(keys, alternate *services.SigningKeys, rotator *services.KeyRotator) *KeyHandlers {
+	handlers := &KeyHandlers{keys: []*services.SigningKeys{keys}, rotator: rotator}
+	if alternate != nil {
+		handlers.keys = append(handlers.keys, alternate)
+	}
+	return handlers
+}
+
+// JWKS returns every active verification key as a JSON Web Key Set, each
+// key named by the kid that tokens signed with it carry. Services signing
+// with a shared HMAC secret have no public key to publish.
+func (h *KeyHandlers) JWKS(c *gin.Context) {
+	jwks := []services.JWK{}
+	for _, keys := range h.keys {
+		jwks = append(jwks, keys.JWKS()...)
+	}
+	if len(jwks) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no public keys: tokens are signed with a shared secret"})
+		return
+	}
+
+	c.Header("Cache-Control", publicKeyCacheControl)
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
+
+// PublicKeyPEM returns every active verification key as concatenated PEM blocks.
+// Services signing with a shared HMAC secret have no public key to publish.
+func (h *KeyHandlers) PublicKeyPEM(c *gin.Context) {
+	var publicKeys []*rsa.PublicKey
+	for _, keys := range h.keys {
+		publicKeys = append(publicKeys, keys.PublicKeys()...)
+	}
+	if len(publicKeys) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no public keys: tokens are signed with a shared secret"})
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, key := range publicKeys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			log.Printf("Failed to encode public key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		pem.Encode(&buf, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	c.Header("Cache-Control", publicKeyCacheControl)
+	c.Data(http.StatusOK, "application/x-pem-file", buf.Bytes())
+}
+
+// RotationStatus reports the current signing key generation and the next scheduled rotation
+func (h *KeyHandlers) RotationStatus(c *gin.Context) {
+	if h.rotator == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "signing key rotation is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, h.rotator.Status())
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return key
+}
+
+func keyRouter(handlers *KeyHandlers) *gin.Engine {
+	router := gin.New()
+	router.GET("/public-key.pem", handlers.PublicKeyPEM)
+	router.GET("/jwks.json", handlers.JWKS)
+	return router
+}
+
+func TestPublicKeyPEMServesEveryVerificationKey(t *testing.T) {
+	current := generateRSAKey(t)
+	previous := generateRSAKey(t)
+	router := keyRouter(NewKeyHandlers(services.NewRSAKeys(current, &previous.PublicKey), nil, nil))
+
+	rec := serve(router, http.MethodGet, "/public-key.pem", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-pem-file" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != publicKeyCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, publicKeyCacheControl)
+	}
+
+	var served []*rsa.PublicKey
+	rest := rec.Body.Bytes()
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			t.Fatalf("PEM block of type %q", block.Type)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			t.Fatalf("ParsePKIXPublicKey: %v", err)
+		}
+		served = append(served, key.(*rsa.PublicKey))
+	}
+
+	want := []*rsa.PublicKey{&current.PublicKey, &previous.PublicKey}
+	if len(served) != len(want) {
+		t.Fatalf("served %d keys, want %d", len(served), len(want))
+	}
+	for i := range want {
+		if !served[i].Equal(want[i]) {
+			t.Errorf("key %d does not match the verification key", i)
+		}
+	}
+}
+
+func TestPublicKeysNotFoundForHMAC(t *testing.T) {
+	router := keyRouter(NewKeyHandlers(services.NewHMACKeys("test-secret"), nil, nil))
+
+	for _, path := range []string{"/public-key.pem", "/jwks.json"} {
+		rec := serve(router, http.MethodGet, path, "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+		if rec.Header().Get("Cache-Control") != "" {
+			t.Errorf("GET %s: the 404 is cacheable", path)
+		}
+	}
+}
+
+// Clients issued RS256 tokens while the service signs with HMAC still get
+// the RSA keys
+func TestPublicKeyPEMIncludesAlternateKeys(t *testing.T) {
+	alternate := generateRSAKey(t)
+	router := keyRouter(NewKeyHandlers(services.NewHMACKeys("test-secret"), services.NewRSAKeys(alternate), nil))
+
+	rec := serve(router, http.MethodGet, "/public-key.pem", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	block, _ := pem.Decode(rec.Body.Bytes())
+	if block == nil {
+		t.Fatal("no PEM block served")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil || !key.(*rsa.PublicKey).Equal(&alternate.PublicKey) {
+		t.Errorf("served key does not match the alternate key: %v", err)
+	}
+}
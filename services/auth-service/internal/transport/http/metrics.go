@@ -0,0 +1,12 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsHandler serves the Prometheus metrics of the default registry
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,307 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// Context keys set by AuthMiddleware
+const (
+	ContextUserID    = "user_id"
+	ContextEmail     = "email"
+	ContextRole      = "role"
+	ContextSessionID = "session_id"
+	ContextRiskScore = "risk_score"
+	ContextScopes    = "scopes"
+	ContextCampusID  = "campus_id"
+
+	ContextMustChangePassword = "must_change_password"
+	ContextEmailVerified      = "email_verified"
+
+	// ContextKioskID identifies the kiosk authenticated by RequireKioskKey
+	ContextKioskID = "kiosk_id"
+
+	// ContextDelegationID names the delegation a delegated token was issued for
+	ContextDelegationID = "delegation_id"
+	// ContextDelegation holds the delegation checked by RequireDelegation
+	ContextDelegation = "delegation"
+)
+
+// OptionalAuthMiddleware authenticates the request like AuthMiddleware when
+// it carries a bearer token and lets it through anonymously when it does not
+func OptionalAuthMiddleware(tokens *services.AccessTokens, activeUsers *services.ActiveUsers) gin.HandlerFunc {
+	auth := AuthMiddleware(tokens, activeUsers)
+	return func(c *gin.Context) {
+		if bearerToken(c) == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// AuthMiddleware rejects requests without a valid bearer access token, in
+// any of the formats clients are issued, or whose account is no longer
+// active, and stores the authenticated identity in the gin context
+func AuthMiddleware(tokens *services.AccessTokens, activeUsers *services.ActiveUsers) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+
+		claims, err := tokens.Validate(c.Request.Context(), tokenString)
+		if err != nil {
+			if !errors.Is(err, services.ErrInvalidToken) {
+				log.Printf("Failed to validate access token: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		active, err := activeUsers.IsActive(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Failed to check account of access token: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": services.ErrAccountInactive.Error(), "code": "account_inactive"})
+			return
+		}
+
+		c.Set(ContextUserID, userID)
+		c.Set(ContextEmail, claims.Email)
+		c.Set(ContextRole, domain.Role(claims.Role))
+		c.Set(ContextSessionID, claims.SessionID)
+		c.Set(ContextRiskScore, claims.RiskScore)
+		c.Set(ContextMustChangePassword, claims.MustChangePassword)
+		c.Set(ContextEmailVerified, claims.EmailVerified)
+		c.Set(ContextScopes, domain.ParseSessionScopes(claims.Scope))
+		if claims.CampusID != "" {
+			c.Set(ContextCampusID, claims.CampusID)
+		}
+		if claims.Delegation != nil {
+			c.Set(ContextDelegationID, claims.Delegation.ID)
+		}
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of a bearer Authorization header, or an
+// empty string when there is none
+func bearerToken(c *gin.Context) string {
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return tokenString
+}
+
+// RequireLowRisk blocks sessions scored as high risk at login from sensitive
+// routes, signalling the client to step up authentication. Mount after AuthMiddleware.
+func RequireLowRisk() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetInt(ContextRiskScore) >= domain.HighRiskThreshold {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "step-up authentication required",
+				"code":  "step_up_required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePasswordChanged blocks sessions of accounts that must change their
+// password first. Mount after AuthMiddleware on every route except the
+// password change itself.
+func RequirePasswordChanged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(ContextMustChangePassword) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "password change required",
+				"code":  "password_change_required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSessionScope blocks requests outside the scope the session was
+// narrowed to at login: reads need the read scope, other methods the write
+// scope, and every scope in extra is needed too. Mount after AuthMiddleware.
+func RequireSessionScope(extra ...domain.SessionScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		needed := domain.ScopeWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			needed = domain.ScopeRead
+		}
+
+		scopes := currentScopes(c)
+		for _, scope := range append([]domain.SessionScope{needed}, extra...) {
+			if !scopes.Allows(scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": fmt.Sprintf("session scope does not include %s", scope),
+					"code":  "insufficient_scope",
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireRole restricts a route to users whose token carries one of the
+// given roles. Mount after AuthMiddleware.
+func RequireRole(roles ...domain.Role) gin.HandlerFunc {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	message := strings.Join(names, " or ") + " role required"
+
+	return func(c *gin.Context) {
+		role := currentRole(c)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message, "code": "insufficient_role"})
+	}
+}
+
+// RequireVerified blocks users who have not verified their email from
+// sensitive routes. The check reads the token, so a user who just verified
+// must refresh it first. Mount after AuthMiddleware.
+func RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(ContextEmailVerified) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "email verification required",
+				"code":  "email_unverified",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireDelegation restricts a route to delegated tokens whose delegation
+// is still active and covers scope, so a revoked delegation locks its tokens
+// out at once. Mount after AuthMiddleware.
+func RequireDelegation(delegationService *services.DelegationService, scope domain.DelegationScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		delegationID, err := uuid.Parse(c.GetString(ContextDelegationID))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "delegated token required",
+				"code":  "delegation_required",
+			})
+			return
+		}
+
+		userID, _ := currentUserID(c)
+		delegation, err := delegationService.Authorize(c.Request.Context(), userID, delegationID, scope)
+		if err != nil {
+			errorResponse(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextDelegation, delegation)
+		c.Next()
+	}
+}
+
+// RequireKioskKey restricts a route to kiosks presenting one of the
+// configured API keys, keyed by kiosk ID, in the X-API-Key header
+func RequireKioskKey(keys map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := c.GetHeader("X-API-Key")
+		for kioskID, key := range keys {
+			if presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+				c.Set(ContextKioskID, kioskID)
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+	}
+}
+
+// RequireInternalToken restricts a route to other UniBazzar services
+// presenting the shared internal token as a bearer token. With no token
+// configured every call is refused.
+func RequireInternalToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := bearerToken(c)
+		if token == "" || presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid internal token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentRole returns the role stored by AuthMiddleware
+func currentRole(c *gin.Context) domain.Role {
+	role, _ := c.Get(ContextRole)
+	value, _ := role.(domain.Role)
+	return value
+}
+
+// currentCampusScope returns the reach of the caller over other users'
+// accounts, from the role and campus stored by AuthMiddleware
+func currentCampusScope(c *gin.Context) domain.CampusScope {
+	scope := domain.CampusScope{Role: currentRole(c)}
+	if campusID := c.GetString(ContextCampusID); campusID != "" {
+		scope.CampusID = &campusID
+	}
+	return scope
+}
+
+// currentScopes returns the session scopes stored by AuthMiddleware; nil
+// when the session was not narrowed
+func currentScopes(c *gin.Context) domain.SessionScopes {
+	scopes, _ := c.Get(ContextScopes)
+	value, _ := scopes.(domain.SessionScopes)
+	return value
+}
+
+// currentDelegation returns the delegation stored by RequireDelegation
+func currentDelegation(c *gin.Context) *domain.Delegation {
+	delegation, _ := c.Get(ContextDelegation)
+	value, _ := delegation.(*domain.Delegation)
+	return value
+}
+
+// currentUserID returns the identity stored by AuthMiddleware
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	value, ok := c.Get(ContextUserID)
+	if !ok {
+		return uuid.Nil, false
+	}
+	userID, ok := value.(uuid.UUID)
+	return userID, ok
+}
@@ -0,0 +1,341 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// stubUserRepo serves the users AuthMiddleware checks are active
+type stubUserRepo struct {
+	domain.UserRepository
+	users map[uuid.UUID]*domain.User
+}
+
+func (r stubUserRepo) GetByID(_ context.Context, id uuid.UUID) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// testAuth issues access tokens AuthMiddleware accepts for its users
+type testAuth struct {
+	keys        *services.SigningKeys
+	tokens      *services.AccessTokens
+	activeUsers *services.ActiveUsers
+	users       map[uuid.UUID]*domain.User
+}
+
+func newTestAuth() *testAuth {
+	keys := services.NewHMACKeys("test-secret")
+	users := make(map[uuid.UUID]*domain.User)
+	return &testAuth{
+		keys:        keys,
+		tokens:      services.NewAccessTokens(keys, nil, nil, services.NewMemoryBlacklist(), 15*time.Minute),
+		activeUsers: services.NewActiveUsers(stubUserRepo{users: users}, 0),
+		users:       users,
+	}
+}
+
+// token registers an active user with the role and returns an access token
+// for them
+func (a *testAuth) token(t *testing.T, email string, role domain.Role, scope string) (uuid.UUID, string) {
+	t.Helper()
+	user := &domain.User{ID: uuid.New(), Email: email, Role: role, IsActive: true}
+	a.users[user.ID] = user
+
+	now := time.Now()
+	token, err := a.keys.Sign(&services.Claims{
+		UserID:        user.ID.String(),
+		Email:         email,
+		Role:          string(role),
+		SessionID:     uuid.NewString(),
+		EmailVerified: true,
+		Scope:         scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(15 * time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return user.ID, token
+}
+
+func serve(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// whoAmI reports the user the request was authenticated as
+func whoAmI(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"user_id": ""})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID.String()})
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	auth := newTestAuth()
+	userID, token := auth.token(t, "ada@example.edu", domain.RoleStudent, "")
+
+	router := gin.New()
+	router.GET("/status", OptionalAuthMiddleware(auth.tokens, auth.activeUsers), whoAmI)
+
+	if rec := serve(router, http.MethodGet, "/status", ""); rec.Code != http.StatusOK || rec.Body.String() != `{"user_id":""}` {
+		t.Errorf("anonymous request: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(router, http.MethodGet, "/status", token); rec.Code != http.StatusOK || rec.Body.String() != `{"user_id":"`+userID.String()+`"}` {
+		t.Errorf("authenticated request: %d %s", rec.Code, rec.Body)
+	}
+	_, forged := newTestAuth().token(t, "ada@example.edu", domain.RoleStudent, "")
+	forged = forged[:len(forged)-4] + "AAAA"
+	if rec := serve(router, http.MethodGet, "/status", forged); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: status = %d, want 401", rec.Code)
+	}
+}
+
+// withContext sets a context key, as AuthMiddleware would
+func withContext(key string, value interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(key, value)
+		c.Next()
+	}
+}
+
+func TestRequireLowRisk(t *testing.T) {
+	for score, want := range map[int]int{
+		0:                            http.StatusOK,
+		domain.HighRiskThreshold - 1: http.StatusOK,
+		domain.HighRiskThreshold:     http.StatusForbidden,
+		domain.MaxRiskScore:          http.StatusForbidden,
+	} {
+		router := gin.New()
+		router.POST("/sensitive", withContext(ContextRiskScore, score), RequireLowRisk(), whoAmI)
+		rec := serve(router, http.MethodPost, "/sensitive", "")
+		if rec.Code != want {
+			t.Errorf("risk score %d: status = %d, want %d", score, rec.Code, want)
+		}
+		if want == http.StatusForbidden && !strings.Contains(rec.Body.String(), "step_up_required") {
+			t.Errorf("risk score %d: body %s", score, rec.Body)
+		}
+	}
+}
+
+// A session narrowed at login is denied the operations outside its scope,
+// whatever its role allows
+func TestRequireSessionScope(t *testing.T) {
+	auth := newTestAuth()
+	router := gin.New()
+	users := router.Group("/users", AuthMiddleware(auth.tokens, auth.activeUsers), RequireSessionScope())
+	users.GET("/me", whoAmI)
+	users.PATCH("/me", whoAmI)
+	admin := router.Group("/admin", AuthMiddleware(auth.tokens, auth.activeUsers), RequireSessionScope(domain.ScopeAdmin), RequireRole(domain.RoleAdmin))
+	admin.GET("/users", whoAmI)
+	admin.POST("/users/bulk-role", whoAmI)
+
+	tests := []struct {
+		name  string
+		role  domain.Role
+		scope string
+		want  map[string]int
+	}{
+		{"unnarrowed student", domain.RoleStudent, "", map[string]int{
+			"GET /users/me": 200, "PATCH /users/me": 200, "GET /admin/users": 403,
+		}},
+		{"read only student", domain.RoleStudent, "read", map[string]int{
+			"GET /users/me": 200, "PATCH /users/me": 403,
+		}},
+		{"write only student", domain.RoleStudent, "write", map[string]int{
+			"GET /users/me": 403, "PATCH /users/me": 200,
+		}},
+		{"unnarrowed admin", domain.RoleAdmin, "", map[string]int{
+			"GET /users/me": 200, "PATCH /users/me": 200, "GET /admin/users": 200, "POST /admin/users/bulk-role": 200,
+		}},
+		{"admin without the admin scope", domain.RoleAdmin, "read write", map[string]int{
+			"GET /users/me": 200, "PATCH /users/me": 200, "GET /admin/users": 403, "POST /admin/users/bulk-role": 403,
+		}},
+		{"read only admin", domain.RoleAdmin, "read admin", map[string]int{
+			"GET /admin/users": 200, "POST /admin/users/bulk-role": 403, "PATCH /users/me": 403,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token := auth.token(t, "ada@example.edu", tt.role, tt.scope)
+			for route, want := range tt.want {
+				method, path, _ := strings.Cut(route, " ")
+				rec := serve(router, method, path, token)
+				if rec.Code != want {
+					t.Errorf("%s: status = %d, want %d", route, rec.Code, want)
+				}
+				if want == http.StatusForbidden && !strings.Contains(rec.Body.String(), "insufficient_") {
+					t.Errorf("%s: body %s", route, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRequirePasswordChanged(t *testing.T) {
+	for mustChange, want := range map[bool]int{false: http.StatusOK, true: http.StatusForbidden} {
+		router := gin.New()
+		router.GET("/profile", withContext(ContextMustChangePassword, mustChange), RequirePasswordChanged(), whoAmI)
+		rec := serve(router, http.MethodGet, "/profile", "")
+		if rec.Code != want {
+			t.Errorf("must change %v: status = %d, want %d", mustChange, rec.Code, want)
+		}
+		if want == http.StatusForbidden && !strings.Contains(rec.Body.String(), "password_change_required") {
+			t.Errorf("must change %v: body %s", mustChange, rec.Body)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	auth := newTestAuth()
+	router := gin.New()
+	admin := router.Group("/admin", AuthMiddleware(auth.tokens, auth.activeUsers), RequireRole(domain.RoleAdmin))
+	admin.GET("/users", whoAmI)
+	moderation := router.Group("/moderation", AuthMiddleware(auth.tokens, auth.activeUsers), RequireRole(domain.RoleAdmin, domain.RoleModerator))
+	moderation.GET("/reports", whoAmI)
+
+	tests := []struct {
+		role domain.Role
+		want map[string]int
+	}{
+		{domain.RoleStudent, map[string]int{"/admin/users": http.StatusForbidden, "/moderation/reports": http.StatusForbidden}},
+		{domain.RoleModerator, map[string]int{"/admin/users": http.StatusForbidden, "/moderation/reports": http.StatusOK}},
+		{domain.RoleAdmin, map[string]int{"/admin/users": http.StatusOK, "/moderation/reports": http.StatusOK}},
+		{"", map[string]int{"/admin/users": http.StatusForbidden, "/moderation/reports": http.StatusForbidden}},
+	}
+	for _, tt := range tests {
+		_, token := auth.token(t, "ada@example.edu", tt.role, "")
+		for path, want := range tt.want {
+			rec := serve(router, http.MethodGet, path, token)
+			if rec.Code != want {
+				t.Errorf("%q token on %s: status = %d, want %d", tt.role, path, rec.Code, want)
+			}
+			if want == http.StatusForbidden && !strings.Contains(rec.Body.String(), "insufficient_role") {
+				t.Errorf("%q token on %s: body %s", tt.role, path, rec.Body)
+			}
+		}
+	}
+
+	if rec := serve(router, http.MethodGet, "/admin/users", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestRequireVerified(t *testing.T) {
+	for verified, want := range map[bool]int{true: http.StatusOK, false: http.StatusForbidden} {
+		router := gin.New()
+		router.POST("/delegations", withContext(ContextEmailVerified, verified), RequireVerified(), whoAmI)
+		rec := serve(router, http.MethodPost, "/delegations", "")
+		if rec.Code != want {
+			t.Errorf("verified %v: status = %d, want %d", verified, rec.Code, want)
+		}
+		if want == http.StatusForbidden && !strings.Contains(rec.Body.String(), "email_unverified") {
+			t.Errorf("verified %v: body %s", verified, rec.Body)
+		}
+	}
+}
+
+// stubAccessTokenRepo serves stored opaque access tokens by hash
+type stubAccessTokenRepo struct {
+	domain.AccessTokenRepository
+	tokens map[string]*domain.AccessToken
+}
+
+func (r stubAccessTokenRepo) GetActive(tokenHash string, now time.Time) (*domain.AccessToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok || !token.ExpiresAt.After(now) {
+		return nil, sql.ErrNoRows
+	}
+	return token, nil
+}
+
+func TestAuthMiddlewareAcceptsEveryTokenFormat(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	hmacKeys, rsaKeys := services.NewHMACKeys("test-secret"), services.NewRSAKeys(rsaKey)
+	repo := stubAccessTokenRepo{tokens: make(map[string]*domain.AccessToken)}
+	users := make(map[uuid.UUID]*domain.User)
+	tokens := services.NewAccessTokens(hmacKeys, rsaKeys, repo, services.NewMemoryBlacklist(), 15*time.Minute)
+	router := gin.New()
+	router.GET("/me", AuthMiddleware(tokens, services.NewActiveUsers(stubUserRepo{users: users}, 0)), whoAmI)
+
+	claims := func(userID uuid.UUID) *services.Claims {
+		now := time.Now()
+		return &services.Claims{
+			UserID:    userID.String(),
+			Role:      string(domain.RoleStudent),
+			SessionID: uuid.NewString(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(15 * time.Minute)),
+			},
+		}
+	}
+	signed := func(keys *services.SigningKeys) func(*services.Claims) string {
+		return func(c *services.Claims) string {
+			token, err := keys.Sign(c)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			return token
+		}
+	}
+	opaque := func(c *services.Claims) string {
+		encoded, _ := json.Marshal(c)
+		token := "opaque-" + c.ID
+		sessionID, _ := uuid.Parse(c.SessionID)
+		repo.tokens[domain.HashToken(token)] = domain.NewAccessToken(token, sessionID, encoded, c.ExpiresAt.Time)
+		return token
+	}
+
+	for format, issue := range map[string]func(*services.Claims) string{
+		"jwt_hs256": signed(hmacKeys),
+		"jwt_rs256": signed(rsaKeys),
+		"opaque":    opaque,
+	} {
+		user := &domain.User{ID: uuid.New(), Role: domain.RoleStudent, IsActive: true}
+		users[user.ID] = user
+		rec := serve(router, http.MethodGet, "/me", issue(claims(user.ID)))
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"user_id":"`+user.ID.String()+`"}` {
+			t.Errorf("%s token: %d %s", format, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/me", "opaque-unknown"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown opaque token: status %d, want 401", rec.Code)
+	}
+}
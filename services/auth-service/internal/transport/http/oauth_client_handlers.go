@@ -0,0 +1,125 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// OAuthClientHandlers exposes the admin endpoints managing OAuth clients
+type OAuthClientHandlers struct {
+	clientService *services.OAuthClientService
+}
+
+// NewOAuthClientHandlers creates the OAuth client handlers
+func NewOAuthClientHandlers(clientService *services.OAuthClientService) *OAuthClientHandlers {
+	return &OAuthClientHandlers{clientService: clientService}
+}
+
+// Register registers a new client and returns its secret once
+func (h *OAuthClientHandlers) Register(c *gin.Context) {
+	var req domain.OAuthClientRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	client, err := h.clientService.Register(c.Request.Context(), req)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+// List lists the registered clients
+func (h *OAuthClientHandlers) List(c *gin.Context) {
+	clients, err := h.clientService.List(c.Request.Context())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// Get returns one client
+func (h *OAuthClientHandlers) Get(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+
+	client, err := h.clientService.Get(c.Request.Context(), id)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// Update replaces a client's settings
+func (h *OAuthClientHandlers) Update(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req domain.OAuthClientRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	client, err := h.clientService.Update(c.Request.Context(), id, req)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// RotateSecret issues a new secret for a client and returns it once
+func (h *OAuthClientHandlers) RotateSecret(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+
+	client, err := h.clientService.RotateSecret(c.Request.Context(), id)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// Delete removes a client
+func (h *OAuthClientHandlers) Delete(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.clientService.Delete(c.Request.Context(), id); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// clientIDParam reads the client ID path parameter, writing a 400 when it is invalid
+func clientIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/config"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+const (
+	// oauthStateCookie binds an OAuth login to the browser that started it
+	oauthStateCookie = "oauth_state"
+	// oauthCookieMaxAge matches the lifetime of the state, in seconds
+	oauthCookieMaxAge = 600
+)
+
+// OAuthHandlers exposes logins through external identity providers
+type OAuthHandlers struct {
+	oauthService *services.OAuthService
+	cookies      config.CookieSettings
+}
+
+// NewOAuthHandlers creates the OAuth login handlers, setting the state
+// cookie with the given attributes
+func NewOAuthHandlers(oauthService *services.OAuthService, cookies config.CookieSettings) *OAuthHandlers {
+	return &OAuthHandlers{oauthService: oauthService, cookies: cookies}
+}
+
+// Start redirects the browser to the provider's login page
+func (h *OAuthHandlers) Start(c *gin.Context) {
+	start, err := h.oauthService.Start(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	setCookie(c, h.cookies, oauthStateCookie, start.Nonce, oauthCookieMaxAge)
+	c.Redirect(http.StatusFound, start.AuthURL)
+}
+
+// Callback completes the login the provider redirected back from
+func (h *OAuthHandlers) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login was refused by the identity provider", "code": providerErr})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+	nonce, _ := c.Cookie(oauthStateCookie)
+
+	// the state is single use either way
+	clearCookie(c, h.cookies, oauthStateCookie)
+
+	result, err := h.oauthService.Callback(c.Request.Context(), c.Param("provider"), code, state, nonce, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
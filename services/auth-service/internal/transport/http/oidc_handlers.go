@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// OIDCHandlers exposes the OpenID Connect discovery document
+type OIDCHandlers struct {
+	keys   *services.SigningKeys
+	issuer string
+}
+
+// NewOIDCHandlers creates the OpenID Connect handlers for the given issuer URL
+func NewOIDCHandlers(keys *services.SigningKeys, issuer string) *OIDCHandlers {
+	return &OIDCHandlers{keys: keys, issuer: strings.TrimSuffix(issuer, "/")}
+}
+
+// Discovery returns the OpenID provider metadata
+func (h *OIDCHandlers) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", publicKeyCacheControl)
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                h.issuer,
+		"token_endpoint":                        h.issuer + "/api/v1/auth/login",
+		"introspection_endpoint":                h.issuer + "/api/v1/auth/introspect",
+		"jwks_uri":                              h.issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"token id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{h.keys.Algorithm()},
+		"scopes_supported":                      []string{domain.ScopeOpenID},
+		"claims_supported":                      []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "name"},
+	})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// PasswordResetHandlers exposes the password reset endpoints
+type PasswordResetHandlers struct {
+	resetService *services.PasswordResetService
+}
+
+// NewPasswordResetHandlers creates the password reset handlers
+func NewPasswordResetHandlers(resetService *services.PasswordResetService) *PasswordResetHandlers {
+	return &PasswordResetHandlers{resetService: resetService}
+}
+
+// ForgotPassword emails a reset token to the account with the given email.
+// The response is the same whether or not the account exists.
+func (h *PasswordResetHandlers) ForgotPassword(c *gin.Context) {
+	var req domain.PasswordResetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	h.resetService.RequestReset(c.Request.Context(), req)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account exists for this email, a reset link has been sent"})
+}
+
+// ValidateResetToken reports whether a reset token is usable without consuming it
+func (h *PasswordResetHandlers) ValidateResetToken(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	status, err := h.resetService.ValidateToken(c.Request.Context(), token)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, status)
+}
+
+// ResetPassword consumes a reset token and sets the new password
+func (h *PasswordResetHandlers) ResetPassword(c *gin.Context) {
+	var req domain.PasswordResetConfirm
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.resetService.ResetPassword(c.Request.Context(), req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// PhoneHandlers exposes the phone number endpoints
+type PhoneHandlers struct {
+	phoneService *services.PhoneService
+}
+
+// NewPhoneHandlers creates the phone handlers
+func NewPhoneHandlers(phoneService *services.PhoneService) *PhoneHandlers {
+	return &PhoneHandlers{phoneService: phoneService}
+}
+
+// SetPhone sends a verification code to the requested number
+func (h *PhoneHandlers) SetPhone(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.PhoneRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.phoneService.RequestPhone(c.Request.Context(), userID, req.Phone); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification code sent"})
+}
+
+// VerifyPhone confirms the pending number with the code sent to it
+func (h *PhoneHandlers) VerifyPhone(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.PhoneVerification
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.phoneService.ConfirmPhone(c.Request.Context(), userID, req.Code, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "phone number verified"})
+}
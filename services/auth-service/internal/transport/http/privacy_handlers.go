@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// PrivacyHandlers exposes the consent and data export endpoints
+type PrivacyHandlers struct {
+	privacyService *services.PrivacyService
+}
+
+// NewPrivacyHandlers creates the privacy handlers
+func NewPrivacyHandlers(privacyService *services.PrivacyService) *PrivacyHandlers {
+	return &PrivacyHandlers{privacyService: privacyService}
+}
+
+// GetConsents returns the authenticated user's current consents
+func (h *PrivacyHandlers) GetConsents(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	consents, err := h.privacyService.GetConsents(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// UpdateConsents records new consent decisions
+func (h *PrivacyHandlers) UpdateConsents(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.ConsentUpdate
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	consents, err := h.privacyService.RecordConsents(c.Request.Context(), userID, req, c.ClientIP())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// ExportData returns all personal data held about the authenticated user
+func (h *PrivacyHandlers) ExportData(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	export, err := h.privacyService.Export(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="unibazzar-data-export.json"`)
+	c.JSON(http.StatusOK, export)
+}
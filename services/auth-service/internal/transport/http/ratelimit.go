@@ -0,0 +1,230 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+var (
+	rateLimitDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_rate_limit_requests_total",
+		Help: "Requests checked against a rate limit, by route group and result.",
+	}, []string{"group", "result"})
+	rateLimitRemaining = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "auth_rate_limit_remaining_tokens",
+		Help:    "Tokens left in the bucket a request was checked against, by route group.",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100},
+	}, []string{"group"})
+)
+
+// RateLimitMiddleware throttles requests per identity. Authenticated requests
+// are keyed by user ID so per-user overrides apply; anonymous ones by client IP.
+// Mount it after AuthMiddleware on protected groups, as SignedInGroup does.
+func RateLimitMiddleware(limiter ratelimit.Limiter, policy *ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := rateLimitIdentity(c)
+		result := limiter.Allow(identity, policy.LimitFor(identity))
+		recordRateLimit("default", result)
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			rejectRateLimited(c, result)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// SignedInGroup returns a group under parent for routes of signed-in users.
+// Its requests are authenticated and scope-checked before rateLimit, so they
+// are counted per user, under the user's override if there is one.
+func SignedInGroup(parent *gin.RouterGroup, tokens *services.AccessTokens, activeUsers *services.ActiveUsers, rateLimit gin.HandlerFunc) *gin.RouterGroup {
+	return parent.Group("", AuthMiddleware(tokens, activeUsers), RequireSessionScope(), rateLimit)
+}
+
+// RateLimitKey derives the bucket a request is counted against; an empty key
+// leaves the request out of that bucket
+type RateLimitKey func(c *gin.Context) string
+
+// ClientIPKey counts requests against the client address
+func ClientIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// maxPeekedBody bounds how much of a request body EmailKey reads
+const maxPeekedBody = 64 << 10
+
+// EmailKey counts requests against the email submitted in the JSON body, so
+// attempts on one account are limited however many addresses they come from.
+// The body is left for the handler to read.
+func EmailKey(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	peeked, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekedBody))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return ""
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(peeked, &body) != nil {
+		return ""
+	}
+	return emailIdentity(body.Email)
+}
+
+// AccountEmailKey counts requests against the email of the authenticated
+// caller: the bucket EmailKey fills when they log in
+func AccountEmailKey(c *gin.Context) string {
+	return emailIdentity(c.GetString(ContextEmail))
+}
+
+func emailIdentity(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return "email:" + email
+}
+
+// RouteRateLimitMiddleware throttles a route group on a limit of its own, on
+// top of RateLimitMiddleware: a request needs a token from its bucket under
+// every key. A zero limit disables the throttle.
+func RouteRateLimitMiddleware(limiter ratelimit.Limiter, group string, limit ratelimit.Limit, keys ...RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit.Requests <= 0 {
+			c.Next()
+			return
+		}
+
+		for _, key := range keys {
+			identity := key(c)
+			if identity == "" {
+				continue
+			}
+
+			result := limiter.Allow(group+":"+identity, limit)
+			recordRateLimit(group, result)
+			if !result.Allowed {
+				c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				c.Header("X-RateLimit-Remaining", "0")
+				rejectRateLimited(c, result)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimitGroup describes the buckets of a route group for
+// RateLimitStatusHandler: its limit and the keys its requests are counted
+// against
+type RateLimitGroup struct {
+	Limit ratelimit.Limit
+	Keys  []RateLimitKey
+}
+
+// rateLimitBucket is the allowance left in one bucket, times in seconds
+type rateLimitBucket struct {
+	Group      string `json:"group"`
+	Key        string `json:"key"`
+	Limit      int    `json:"limit"`
+	Remaining  int    `json:"remaining"`
+	RetryAfter int    `json:"retry_after"`
+	ResetAfter int    `json:"reset_after"`
+}
+
+// RateLimitStatusHandler reports what the client has left in every bucket
+// it is counted against, read from the limiter that enforces them without
+// consuming a token: the default tier by IP, which the auth routes check,
+// and by user when the request is authenticated, plus each route group under
+// each of its keys. Mount it outside the rate-limited groups, behind
+// OptionalAuthMiddleware, so checking the status is free.
+func RateLimitStatusHandler(limiter ratelimit.Limiter, policy *ratelimit.Policy, groups map[string]RateLimitGroup) gin.HandlerFunc {
+	names := make([]string, 0, len(groups))
+	for group, g := range groups {
+		if g.Limit.Requests > 0 {
+			names = append(names, group)
+		}
+	}
+	sort.Strings(names)
+
+	return func(c *gin.Context) {
+		identities := []string{ClientIPKey(c)}
+		if userID, ok := currentUserID(c); ok {
+			identities = append(identities, "user:"+userID.String())
+		}
+
+		var buckets []rateLimitBucket
+		for _, identity := range identities {
+			buckets = append(buckets, newRateLimitBucket("default", identity, limiter.Peek(identity, policy.LimitFor(identity))))
+		}
+		for _, group := range names {
+			for _, key := range groups[group].Keys {
+				identity := key(c)
+				if identity == "" {
+					continue
+				}
+				buckets = append(buckets, newRateLimitBucket(group, identity, limiter.Peek(group+":"+identity, groups[group].Limit)))
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"buckets": buckets})
+	}
+}
+
+func newRateLimitBucket(group, identity string, result ratelimit.Result) rateLimitBucket {
+	kind, _, _ := strings.Cut(identity, ":")
+	return rateLimitBucket{
+		Group:      group,
+		Key:        kind,
+		Limit:      result.Limit,
+		Remaining:  result.Remaining,
+		RetryAfter: int(math.Ceil(result.RetryAfter.Seconds())),
+		ResetAfter: int(math.Ceil(result.ResetAfter.Seconds())),
+	}
+}
+
+// rejectRateLimited aborts with 429 and tells the client when to retry
+func rejectRateLimited(c *gin.Context, result ratelimit.Result) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+}
+
+func recordRateLimit(group string, result ratelimit.Result) {
+	outcome := "allowed"
+	if !result.Allowed {
+		outcome = "rejected"
+	}
+	rateLimitDecisions.WithLabelValues(group, outcome).Inc()
+	rateLimitRemaining.WithLabelValues(group).Observe(float64(result.Remaining))
+}
+
+func rateLimitIdentity(c *gin.Context) string {
+	if userID, ok := currentUserID(c); ok {
+		return "user:" + userID.String()
+	}
+	return "ip:" + c.ClientIP()
+}
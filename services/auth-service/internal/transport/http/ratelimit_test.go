@@ -0,0 +1,269 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/ratelimit"
+)
+
+func TestRateLimitMiddlewareKeysByUser(t *testing.T) {
+	auth := newTestAuth()
+	_, token := auth.token(t, "ada@example.edu", domain.RoleStudent, "")
+	limiter := ratelimit.NewMemoryLimiter()
+	policy := &ratelimit.Policy{Default: ratelimit.Limit{Requests: 1, Window: time.Minute}}
+
+	router := gin.New()
+	router.GET("/anon", RateLimitMiddleware(limiter, policy), whoAmI)
+	router.GET("/me", AuthMiddleware(auth.tokens, auth.activeUsers), RateLimitMiddleware(limiter, policy), whoAmI)
+
+	if rec := serve(router, http.MethodGet, "/anon", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first anonymous request: status = %d", rec.Code)
+	}
+	rec := serve(router, http.MethodGet, "/anon", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second anonymous request: status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	// the user has a bucket of their own, apart from the one of their IP
+	if rec := serve(router, http.MethodGet, "/me", token); rec.Code != http.StatusOK {
+		t.Fatalf("authenticated request: status = %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/me", token); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second authenticated request: status = %d, want 429", rec.Code)
+	}
+}
+
+// The signed-in /auth routes count users under their overrides, not their IP
+func TestSignedInGroupAppliesUserOverride(t *testing.T) {
+	auth := newTestAuth()
+	userID, token := auth.token(t, "ada@example.edu", domain.RoleStudent, "")
+	_, otherToken := auth.token(t, "alan@example.edu", domain.RoleStudent, "")
+	limiter := ratelimit.NewMemoryLimiter()
+	policy := &ratelimit.Policy{
+		Default:   ratelimit.Limit{Requests: 1, Window: time.Minute},
+		Overrides: ratelimit.StaticOverrides{"user:" + userID.String(): 3},
+	}
+
+	router := gin.New()
+	SignedInGroup(router.Group("/auth"), auth.tokens, auth.activeUsers, RateLimitMiddleware(limiter, policy)).GET("/session/posture", whoAmI)
+
+	for i := 1; i <= 3; i++ {
+		rec := serve(router, http.MethodGet, "/auth/session/posture", token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d of the overridden user: status = %d", i, rec.Code)
+		}
+		if limit := rec.Header().Get("X-RateLimit-Limit"); limit != "3" {
+			t.Fatalf("X-RateLimit-Limit = %s, want the override", limit)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/auth/session/posture", token); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request past the override: status = %d, want 429", rec.Code)
+	}
+
+	// another user from the same address has the default bucket of their own
+	if rec := serve(router, http.MethodGet, "/auth/session/posture", otherToken); rec.Code != http.StatusOK {
+		t.Errorf("another user's request: status = %d", rec.Code)
+	}
+}
+
+func TestRouteRateLimitMiddlewareCountsEveryKey(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	limit := ratelimit.Limit{Requests: 2, Window: time.Minute}
+
+	router := gin.New()
+	router.POST("/login", RouteRateLimitMiddleware(limiter, "login", limit, ClientIPKey, EmailKey), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	login := func(ip, email string) *httptest.ResponseRecorder {
+		body := `{"email":"` + email + `","password":"secret"}`
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := login("10.0.0.1", "Ada@Example.edu")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first login: status = %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"password":"secret"`) {
+		t.Errorf("handler read body %q", rec.Body)
+	}
+
+	// the email bucket follows the account across addresses and spellings
+	if rec := login("10.0.0.2", "ada@example.edu"); rec.Code != http.StatusOK {
+		t.Fatalf("second login: status = %d", rec.Code)
+	}
+	if rec := login("10.0.0.3", " ADA@example.edu "); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third login on the account: status = %d, want 429", rec.Code)
+	}
+	if rec := login("10.0.0.3", "grace@example.edu"); rec.Code != http.StatusOK {
+		t.Fatalf("login on another account: status = %d", rec.Code)
+	}
+}
+
+func TestRouteRateLimitMiddlewareDisabled(t *testing.T) {
+	router := gin.New()
+	router.GET("/", RouteRateLimitMiddleware(ratelimit.NewMemoryLimiter(), "login", ratelimit.Limit{}, ClientIPKey), whoAmI)
+
+	for i := 0; i < 5; i++ {
+		if rec := serve(router, http.MethodGet, "/", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i+1, rec.Code)
+		}
+	}
+}
+
+type statusBucket struct {
+	Group     string `json:"group"`
+	Key       string `json:"key"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+}
+
+func TestRateLimitStatusHandlerReportsEnforcedBuckets(t *testing.T) {
+	auth := newTestAuth()
+	userID, token := auth.token(t, "Ada@Example.edu", domain.RoleStudent, "")
+	limiter := ratelimit.NewMemoryLimiter()
+	policy := &ratelimit.Policy{
+		Default:   ratelimit.Limit{Requests: 10, Window: time.Minute},
+		Overrides: ratelimit.StaticOverrides{"user:" + userID.String(): 50},
+	}
+	login := ratelimit.Limit{Requests: 5, Window: time.Minute}
+	register := ratelimit.Limit{Requests: 3, Window: time.Hour}
+
+	router := gin.New()
+	router.GET("/status", OptionalAuthMiddleware(auth.tokens, auth.activeUsers), RateLimitStatusHandler(limiter, policy, map[string]RateLimitGroup{
+		"login":    {Limit: login, Keys: []RateLimitKey{ClientIPKey, AccountEmailKey}},
+		"register": {Limit: register, Keys: []RateLimitKey{ClientIPKey}},
+		"disabled": {Keys: []RateLimitKey{ClientIPKey}},
+	}))
+
+	ip := "ip:192.0.2.1"
+	limiter.Allow(ip, policy.Default)
+	limiter.Allow("user:"+userID.String(), ratelimit.Limit{Requests: 50, Window: time.Minute})
+	limiter.Allow("login:"+ip, login)
+	limiter.Allow("login:email:ada@example.edu", login)
+	limiter.Allow("login:email:ada@example.edu", login)
+
+	status := func(token string) []statusBucket {
+		t.Helper()
+		rec := serve(router, http.MethodGet, "/status", token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var body struct {
+			Buckets []statusBucket `json:"buckets"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body.Buckets
+	}
+
+	anonymous := []statusBucket{
+		{Group: "default", Key: "ip", Limit: 10, Remaining: 9},
+		{Group: "login", Key: "ip", Limit: 5, Remaining: 4},
+		{Group: "register", Key: "ip", Limit: 3, Remaining: 3},
+	}
+	assertBuckets(t, "anonymous", status(""), anonymous)
+
+	authenticated := []statusBucket{
+		{Group: "default", Key: "ip", Limit: 10, Remaining: 9},
+		{Group: "default", Key: "user", Limit: 50, Remaining: 49},
+		{Group: "login", Key: "ip", Limit: 5, Remaining: 4},
+		{Group: "login", Key: "email", Limit: 5, Remaining: 3},
+		{Group: "register", Key: "ip", Limit: 3, Remaining: 3},
+	}
+	assertBuckets(t, "authenticated", status(token), authenticated)
+
+	// reading the status consumes nothing
+	assertBuckets(t, "second read", status(token), authenticated)
+}
+
+func assertBuckets(t *testing.T, name string, got, want []statusBucket) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d buckets %+v, want %+v", name, len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: bucket %d = %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
+
+// The status follows the buckets the middlewares fill, one token per
+// request, while reading it takes none
+func TestRateLimitStatusTracksEnforcement(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	policy := &ratelimit.Policy{Default: ratelimit.Limit{Requests: 5, Window: time.Minute}}
+	login := ratelimit.Limit{Requests: 2, Window: time.Minute}
+
+	router := gin.New()
+	router.POST("/login", RateLimitMiddleware(limiter, policy), RouteRateLimitMiddleware(limiter, "login", login, ClientIPKey), whoAmI)
+	router.GET("/status", RateLimitStatusHandler(limiter, policy, map[string]RateLimitGroup{
+		"login": {Limit: login, Keys: []RateLimitKey{ClientIPKey}},
+	}))
+
+	status := func() map[string]rateLimitBucket {
+		t.Helper()
+		rec := serve(router, http.MethodGet, "/status", "")
+		var body struct {
+			Buckets []rateLimitBucket `json:"buckets"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("status = %d %s: %v", rec.Code, rec.Body, err)
+		}
+		buckets := make(map[string]rateLimitBucket)
+		for _, bucket := range body.Buckets {
+			buckets[bucket.Group] = bucket
+		}
+		return buckets
+	}
+	want := func(step string, defaultLeft, loginLeft int) {
+		t.Helper()
+		// several reads in a row see the same allowance
+		for i := 0; i < 3; i++ {
+			buckets := status()
+			if buckets["default"].Remaining != defaultLeft || buckets["login"].Remaining != loginLeft {
+				t.Fatalf("%s, read %d: default %d left, login %d left; want %d and %d",
+					step, i+1, buckets["default"].Remaining, buckets["login"].Remaining, defaultLeft, loginLeft)
+			}
+		}
+	}
+
+	want("before any login", 5, 2)
+	if buckets := status(); buckets["login"].Limit != 2 || buckets["login"].RetryAfter != 0 || buckets["login"].ResetAfter != 0 {
+		t.Errorf("full login bucket = %+v, want no wait", buckets["login"])
+	}
+
+	for i := 1; i <= 2; i++ {
+		if rec := serve(router, http.MethodPost, "/login", ""); rec.Code != http.StatusOK {
+			t.Fatalf("login %d: status = %d", i, rec.Code)
+		}
+		want(fmt.Sprintf("after login %d", i), 5-i, 2-i)
+	}
+	if bucket := status()["login"]; bucket.RetryAfter <= 0 || bucket.RetryAfter > 30 || bucket.ResetAfter <= bucket.RetryAfter {
+		t.Errorf("empty login bucket = %+v, want a retry within half the window and a later reset", bucket)
+	}
+
+	// the status predicted the rejection, which still costs a default token
+	if rec := serve(router, http.MethodPost, "/login", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third login: status = %d, want 429", rec.Code)
+	}
+	want("after the rejected login", 2, 0)
+}
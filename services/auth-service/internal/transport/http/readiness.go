@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessCheckTimeout bounds each dependency check of /readyz
+const readinessCheckTimeout = 2 * time.Second
+
+// ReadinessCheck probes one dependency of the service
+type ReadinessCheck struct {
+	Name string
+	// Critical checks make the service not ready when they fail; other
+	// failures only report it degraded
+	Critical bool
+	Check    func(ctx context.Context) error
+	// Details, when set, adds the dependency's state to its report
+	Details func() interface{}
+}
+
+// dependencyStatus is the report of one ReadinessCheck
+type dependencyStatus struct {
+	Status   string      `json:"status"`
+	Critical bool        `json:"critical"`
+	Error    string      `json:"error,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// ReadinessHandler runs the checks concurrently and reports each
+// dependency's status. It answers 503 "not ready" when a critical check
+// fails, and 200 "degraded" when only non-critical ones do.
+func ReadinessHandler(serviceName string, checks []ReadinessCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		reports := make([]dependencyStatus, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func(i int, check ReadinessCheck) {
+				defer wg.Done()
+				reports[i] = runReadinessCheck(ctx, check)
+			}(i, check)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		dependencies := make(map[string]dependencyStatus, len(checks))
+		for i, check := range checks {
+			report := reports[i]
+			dependencies[check.Name] = report
+			if report.Status == "up" {
+				continue
+			}
+			if check.Critical {
+				status, code = "not ready", http.StatusServiceUnavailable
+			} else if code == http.StatusOK {
+				status = "degraded"
+			}
+		}
+		c.JSON(code, gin.H{"status": status, "service": serviceName, "dependencies": dependencies})
+	}
+}
+
+func runReadinessCheck(ctx context.Context, check ReadinessCheck) dependencyStatus {
+	report := dependencyStatus{Status: "up", Critical: check.Critical}
+	if err := check.Check(ctx); err != nil {
+		report.Status = "down"
+		report.Error = err.Error()
+	}
+	if check.Details != nil {
+		report.Details = check.Details()
+	}
+	return report
+}
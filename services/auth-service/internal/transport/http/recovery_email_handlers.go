@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// RecoveryEmailHandlers exposes the recovery email endpoints
+type RecoveryEmailHandlers struct {
+	recoveryService *services.RecoveryEmailService
+}
+
+// NewRecoveryEmailHandlers creates the recovery email handlers
+func NewRecoveryEmailHandlers(recoveryService *services.RecoveryEmailService) *RecoveryEmailHandlers {
+	return &RecoveryEmailHandlers{recoveryService: recoveryService}
+}
+
+// SetRecoveryEmail sends a confirmation to the requested recovery address
+func (h *RecoveryEmailHandlers) SetRecoveryEmail(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.RecoveryEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.recoveryService.RequestRecoveryEmail(c.Request.Context(), userID, req.RecoveryEmail); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation sent to recovery email"})
+}
+
+// ConfirmRecoveryEmail confirms ownership of a recovery address
+func (h *RecoveryEmailHandlers) ConfirmRecoveryEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	if err := h.recoveryService.ConfirmRecoveryEmail(c.Request.Context(), token, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "recovery email confirmed"})
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextRequestID holds the ID set by RequestIDMiddleware
+const ContextRequestID = "request_id"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware identifies each request by the X-Request-ID it came
+// with, or a new UUID when it had none or an unusable one. The ID is echoed
+// in the response, stored in the gin and request contexts for logs and
+// published events, and set on the request's span.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(events.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(ContextRequestID, requestID)
+		c.Header(events.RequestIDHeader, requestID)
+		ctx := events.ContextWithRequestID(c.Request.Context(), requestID)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts IDs of letters, digits and -_.: only, so a client
+// cannot forge log lines through them
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger logs one structured line per request with its ID, the
+// authenticated user if any, method, path, status and latency. Server
+// errors are logged at error level, client errors at warn.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("request_id", c.GetString(ContextRequestID)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if userID, ok := c.Get(ContextUserID); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
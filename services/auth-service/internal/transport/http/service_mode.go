@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/config"
+)
+
+// ServiceModeMiddleware rejects requests the configured mode does not allow.
+// Read-only mode blocks mutating methods, except on the readRoutes given:
+// routes such as token introspection that are POSTed but change nothing.
+// Maintenance mode blocks everything. Mount it on the API group only so
+// health checks keep answering.
+func ServiceModeMiddleware(mode config.ServiceMode, readRoutes ...string) gin.HandlerFunc {
+	reads := make(map[string]bool, len(readRoutes))
+	for _, route := range readRoutes {
+		reads[route] = true
+	}
+
+	return func(c *gin.Context) {
+		switch {
+		case mode == config.ModeMaintenance:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "service is under maintenance",
+				"code":  "maintenance",
+			})
+			return
+		case mode == config.ModeReadOnly && isMutating(c.Request.Method) && !reads[c.FullPath()]:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "service is in read-only mode; changes are temporarily disabled",
+				"code":  "read_only",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/unibazzar/auth-service/internal/config"
+)
+
+func serviceModeRouter(mode config.ServiceMode) *gin.Engine {
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router := gin.New()
+	router.GET("/healthz", ok)
+	v1 := router.Group("/api/v1")
+	v1.Use(ServiceModeMiddleware(mode, "/api/v1/auth/introspect"))
+	v1.GET("/users/profile", ok)
+	v1.PUT("/users/profile", ok)
+	v1.POST("/auth/login", ok)
+	v1.DELETE("/users/sessions/:id", ok)
+	v1.POST("/auth/introspect", ok)
+	return router
+}
+
+func TestServiceModeMiddleware(t *testing.T) {
+	tests := []struct {
+		mode   config.ServiceMode
+		method string
+		path   string
+		want   int
+		code   string
+	}{
+		{config.ModeNormal, http.MethodGet, "/api/v1/users/profile", http.StatusOK, ""},
+		{config.ModeNormal, http.MethodPost, "/api/v1/auth/login", http.StatusOK, ""},
+
+		{config.ModeReadOnly, http.MethodGet, "/api/v1/users/profile", http.StatusOK, ""},
+		{config.ModeReadOnly, http.MethodPost, "/api/v1/auth/introspect", http.StatusOK, ""},
+		{config.ModeReadOnly, http.MethodGet, "/healthz", http.StatusOK, ""},
+		{config.ModeReadOnly, http.MethodPut, "/api/v1/users/profile", http.StatusServiceUnavailable, "read_only"},
+		{config.ModeReadOnly, http.MethodPost, "/api/v1/auth/login", http.StatusServiceUnavailable, "read_only"},
+		{config.ModeReadOnly, http.MethodDelete, "/api/v1/users/sessions/1", http.StatusServiceUnavailable, "read_only"},
+
+		{config.ModeMaintenance, http.MethodGet, "/api/v1/users/profile", http.StatusServiceUnavailable, "maintenance"},
+		{config.ModeMaintenance, http.MethodPost, "/api/v1/auth/introspect", http.StatusServiceUnavailable, "maintenance"},
+		{config.ModeMaintenance, http.MethodGet, "/healthz", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			rec := serve(serviceModeRouter(tt.mode), tt.method, tt.path, "")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.code == "" {
+				return
+			}
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("code = %q, want %q", body.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutMiddleware puts a deadline on the request context: the timeout
+// configured for the route ("METHOD /path/:param"), or fallback. Work that
+// honours the context stops at the deadline; a request that overran without
+// answering gets a 504. A zero timeout disables the deadline.
+func TimeoutMiddleware(fallback time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			timeout = fallback
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out", "code": "timeout"})
+		}
+	}
+}
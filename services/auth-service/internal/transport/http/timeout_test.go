@@ -0,0 +1,82 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowHandler stands in for a query taking d: it answers once d has passed,
+// or fails as a repository would when the request context ends first
+func slowHandler(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			errorResponse(c, c.Request.Context().Err())
+		case <-time.After(d):
+			c.Status(http.StatusOK)
+		}
+	}
+}
+
+func timeoutRouter(fallback time.Duration, routes map[string]time.Duration, handler gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.Use(TimeoutMiddleware(fallback, routes))
+	router.GET("/api/v1/users/:id", handler)
+	router.POST("/api/v1/auth/login", handler)
+	return router
+}
+
+// timed serves the request and returns its status and how long it took
+func timed(router *gin.Engine, method, path string) (int, time.Duration) {
+	start := time.Now()
+	rec := serve(router, method, path, "")
+	return rec.Code, time.Since(start)
+}
+
+func TestTimeoutCutsOffSlowHandler(t *testing.T) {
+	router := timeoutRouter(50*time.Millisecond, nil, slowHandler(time.Second))
+
+	status, took := timed(router, http.MethodGet, "/api/v1/users/1")
+	if status != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", status)
+	}
+	if took > 500*time.Millisecond {
+		t.Errorf("request took %s, not cut off at its 50ms timeout", took)
+	}
+}
+
+func TestTimeoutPerRoute(t *testing.T) {
+	router := timeoutRouter(20*time.Millisecond, map[string]time.Duration{
+		"POST /api/v1/auth/login": 5 * time.Second,
+		"GET /api/v1/users/:id":   0,
+	}, slowHandler(100*time.Millisecond))
+
+	if status, _ := timed(router, http.MethodPost, "/api/v1/auth/login"); status != http.StatusOK {
+		t.Errorf("route with a longer timeout: status = %d, want 200", status)
+	}
+	if status, _ := timed(router, http.MethodGet, "/api/v1/users/1"); status != http.StatusOK {
+		t.Errorf("route with the timeout disabled: status = %d, want 200", status)
+	}
+}
+
+// A handler that gives up at the deadline without answering still gets a 504
+func TestTimeoutAnswersForSilentHandler(t *testing.T) {
+	router := timeoutRouter(20*time.Millisecond, nil, func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	if status, _ := timed(router, http.MethodGet, "/api/v1/users/1"); status != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", status)
+	}
+}
+
+func TestTimeoutLeavesFastHandlerAlone(t *testing.T) {
+	router := timeoutRouter(time.Second, nil, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	if status, _ := timed(router, http.MethodGet, "/api/v1/users/1"); status != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", status)
+	}
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// TwoFactorHandlers exposes the 2FA and trusted device endpoints
+type TwoFactorHandlers struct {
+	twoFactorService *services.TwoFactorService
+	authService      *services.AuthService
+}
+
+// NewTwoFactorHandlers creates the 2FA handlers
+func NewTwoFactorHandlers(twoFactorService *services.TwoFactorService, authService *services.AuthService) *TwoFactorHandlers {
+	return &TwoFactorHandlers{
+		twoFactorService: twoFactorService,
+		authService:      authService,
+	}
+}
+
+// VerifyLogin completes a challenged login with a TOTP code
+func (h *TwoFactorHandlers) VerifyLogin(c *gin.Context) {
+	var req domain.TwoFactorLogin
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.authService.VerifyTwoFactor(c.Request.Context(), req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Setup starts 2FA enrollment and returns the authenticator secret
+func (h *TwoFactorHandlers) Setup(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	setup, err := h.twoFactorService.Setup(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Enable confirms enrollment with a code from the authenticator app
+func (h *TwoFactorHandlers) Enable(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.TwoFactorCode
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.twoFactorService.Enable(c.Request.Context(), userID, req.Code, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication enabled"})
+}
+
+// Disable turns off 2FA for the authenticated user
+func (h *TwoFactorHandlers) Disable(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	var req domain.TwoFactorCode
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.Request.Context(), userID, req.Code, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// ListTrustedDevices lists the devices that currently skip 2FA
+func (h *TwoFactorHandlers) ListTrustedDevices(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	devices, err := h.twoFactorService.ListTrustedDevices(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": devices})
+}
+
+// RevokeTrustedDevice withdraws trust from one device
+func (h *TwoFactorHandlers) RevokeTrustedDevice(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	deviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device id"})
+		return
+	}
+
+	if err := h.twoFactorService.RevokeTrustedDevice(c.Request.Context(), userID, deviceID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMethods lists the second factors the user has enrolled
+func (h *TwoFactorHandlers) ListMethods(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	methods, err := h.twoFactorService.ListMethods(c.Request.Context(), userID)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"methods": methods})
+}
+
+// SetPreferredMethod makes one second factor the one offered first at login
+func (h *TwoFactorHandlers) SetPreferredMethod(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid method id"})
+		return
+	}
+
+	if err := h.twoFactorService.SetPreferredMethod(c.Request.Context(), userID, methodID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveMethod removes one second factor
+func (h *TwoFactorHandlers) RemoveMethod(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid method id"})
+		return
+	}
+
+	if err := h.twoFactorService.RemoveMethod(c.Request.Context(), userID, methodID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
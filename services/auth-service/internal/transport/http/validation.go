@@ -0,0 +1,106 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one request field that failed validation
+type FieldError struct {
+	// Field is the path of the field in the JSON body, such as
+	// "consents[0].purpose"
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// newValidator creates a validator reporting fields by their JSON names
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// renderValidationError writes a 422 listing every field that failed
+// validation, or a 400 for any other error
+func renderValidationError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fields := make([]FieldError, len(validationErrs))
+	for i, fe := range validationErrs {
+		fields[i] = FieldError{Field: jsonPath(fe), Rule: fe.Tag(), Message: validationMessage(fe)}
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation_failed", "fields": fields})
+}
+
+// jsonPath returns the path of the failed field in the JSON body. Embedded
+// structs have no JSON name and keep their Go name, which is exported, so
+// segments starting with an upper case letter are dropped along with the
+// root type.
+func jsonPath(fe validator.FieldError) string {
+	segments := strings.Split(fe.Namespace(), ".")[1:]
+	path := segments[:0]
+	for _, segment := range segments {
+		if !unicode.IsUpper([]rune(segment)[0]) {
+			path = append(path, segment)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// validationMessage describes a failed validation rule in plain words
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "e164":
+		return "must be a phone number in E.164 format, such as +251911000000"
+	case "numeric":
+		return "must contain only digits"
+	case "hexadecimal":
+		return "must be hexadecimal"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min":
+		return "must be at least " + sizeOf(fe)
+	case "max":
+		return "must be at most " + sizeOf(fe)
+	case "len":
+		return "must be exactly " + sizeOf(fe)
+	default:
+		return fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+}
+
+// sizeOf phrases the parameter of a size rule for the kind of field it checks
+func sizeOf(fe validator.FieldError) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return fe.Param() + " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fe.Param() + " items"
+	default:
+		return fe.Param()
+	}
+}
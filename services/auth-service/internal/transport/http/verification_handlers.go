@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/unibazzar/auth-service/internal/domain"
+	"github.com/unibazzar/auth-service/internal/services"
+)
+
+// VerificationHandlers exposes email verification and the bulk re-verification jobs
+type VerificationHandlers struct {
+	verificationService   *services.EmailVerificationService
+	reverificationService *services.ReverificationService
+}
+
+// NewVerificationHandlers creates the email verification handlers
+func NewVerificationHandlers(verificationService *services.EmailVerificationService, reverificationService *services.ReverificationService) *VerificationHandlers {
+	return &VerificationHandlers{
+		verificationService:   verificationService,
+		reverificationService: reverificationService,
+	}
+}
+
+// VerifyEmail confirms ownership of the login email
+func (h *VerificationHandlers) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	if err := h.verificationService.ConfirmEmail(c.Request.Context(), token); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ResendVerification sends the caller a new verification link
+func (h *VerificationHandlers) ResendVerification(c *gin.Context) {
+	userID, _ := currentUserID(c)
+
+	if err := h.verificationService.ResendVerification(c.Request.Context(), userID); err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+// StartReverification starts a job re-sending verification to unverified users
+func (h *VerificationHandlers) StartReverification(c *gin.Context) {
+	callerID, _ := currentUserID(c)
+
+	var req domain.ReverificationRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	job, err := h.reverificationService.Start(c.Request.Context(), callerID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetReverification reports the progress of a re-verification job
+func (h *VerificationHandlers) GetReverification(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.reverificationService.GetJob(c.Request.Context(), id)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ResumeReverification restarts a failed re-verification job from where it stopped
+func (h *VerificationHandlers) ResumeReverification(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.reverificationService.Resume(c.Request.Context(), id)
+	if err != nil {
+		errorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// jobIDParam reads the job ID path parameter, writing a 400 when it is invalid
+func jobIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,158 @@
+-- Migration: create_users_and_sessions
+-- Created: Sat Oct 17 11:00:00 UTC 2026
+-- Description: Initial schema of the users and sessions tables. Databases
+-- created by setup.sh before migrations were tracked are upgraded in place:
+-- missing columns are added and the nullable legacy columns the service
+-- reads into plain values are backfilled and made NOT NULL.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (
+    id                      UUID PRIMARY KEY,
+    email                   TEXT NOT NULL,
+    password_hash           TEXT NOT NULL DEFAULT '',
+    first_name              TEXT NOT NULL DEFAULT '',
+    last_name               TEXT NOT NULL DEFAULT '',
+    campus_id               TEXT,
+    role                    TEXT NOT NULL DEFAULT 'student',
+    is_active               BOOLEAN NOT NULL DEFAULT TRUE,
+    is_verified             BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at           TIMESTAMPTZ,
+    recovery_email          TEXT,
+    recovery_email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    phone                   TEXT,
+    phone_verified          BOOLEAN NOT NULL DEFAULT FALSE,
+    avatar_url              TEXT,
+    timezone                TEXT,
+    profile_hidden          BOOLEAN NOT NULL DEFAULT FALSE,
+    deactivated_at          TIMESTAMPTZ,
+    suspended_until         TIMESTAMPTZ,
+    banned_at               TIMESTAMPTZ,
+    merged_into             UUID REFERENCES users (id),
+    two_factor_enabled      BOOLEAN NOT NULL DEFAULT FALSE,
+    two_factor_secret       TEXT NOT NULL DEFAULT '',
+    must_change_password    BOOLEAN NOT NULL DEFAULT FALSE,
+    password_length         INTEGER NOT NULL DEFAULT 0,
+    password_classes        INTEGER NOT NULL DEFAULT 0,
+    password_pepper_version INTEGER NOT NULL DEFAULT 0,
+    pin_hash                TEXT NOT NULL DEFAULT '',
+    pin_pepper_version      INTEGER NOT NULL DEFAULT 0,
+    deleted_at              TIMESTAMPTZ
+);
+
+-- emails are stored normalized; lookups match lower(email)
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (lower(email));
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON users (phone) WHERE phone_verified;
+CREATE INDEX IF NOT EXISTS users_campus_id_idx ON users (campus_id);
+
+-- upgrade the users table of setup.sh
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS recovery_email          TEXT,
+    ADD COLUMN IF NOT EXISTS recovery_email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS phone                   TEXT,
+    ADD COLUMN IF NOT EXISTS phone_verified          BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS avatar_url              TEXT,
+    ADD COLUMN IF NOT EXISTS timezone                TEXT,
+    ADD COLUMN IF NOT EXISTS profile_hidden          BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS deactivated_at          TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS suspended_until         TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS banned_at               TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS merged_into             UUID REFERENCES users (id),
+    ADD COLUMN IF NOT EXISTS two_factor_enabled      BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS two_factor_secret       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS must_change_password    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS password_length         INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS password_classes        INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS password_pepper_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS pin_hash                TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS pin_pepper_version      INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS deleted_at              TIMESTAMPTZ;
+
+UPDATE users SET role = 'student' WHERE role IS NULL;
+UPDATE users SET is_active = TRUE WHERE is_active IS NULL;
+UPDATE users SET is_verified = FALSE WHERE is_verified IS NULL;
+UPDATE users SET created_at = NOW() WHERE created_at IS NULL;
+UPDATE users SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN role SET NOT NULL,
+    ALTER COLUMN is_active SET NOT NULL,
+    ALTER COLUMN is_verified SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id                          UUID PRIMARY KEY,
+    user_id                     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    refresh_token_hash          TEXT NOT NULL,
+    previous_refresh_token_hash TEXT NOT NULL DEFAULT '',
+    rotated_at                  TIMESTAMPTZ,
+    expires_at                  TIMESTAMPTZ NOT NULL,
+    created_at                  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at                TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ip_address                  TEXT NOT NULL DEFAULT '',
+    user_agent                  TEXT NOT NULL DEFAULT '',
+    is_revoked                  BOOLEAN NOT NULL DEFAULT FALSE,
+    risk_score                  INTEGER NOT NULL DEFAULT 0,
+    risk_signals                JSONB NOT NULL DEFAULT '{}',
+    auth_method                 TEXT NOT NULL DEFAULT '',
+    mfa_used                    BOOLEAN NOT NULL DEFAULT FALSE,
+    trusted_device              BOOLEAN NOT NULL DEFAULT FALSE,
+    scopes                      TEXT,
+    pin_failures                INTEGER NOT NULL DEFAULT 0,
+    token_format                TEXT NOT NULL DEFAULT '',
+    client_id                   TEXT NOT NULL DEFAULT '',
+    device_id                   TEXT NOT NULL DEFAULT '',
+    rotation_nonce              TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- upgrade the sessions table of setup.sh; its plaintext refresh_token is
+-- replaced by the hash columns, and their indexes built, by hash_refresh_tokens
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS rotated_at     TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS risk_score     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS risk_signals   JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS auth_method    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS mfa_used       BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS trusted_device BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS scopes         TEXT,
+    ADD COLUMN IF NOT EXISTS pin_failures   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS token_format   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS client_id      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS device_id      TEXT NOT NULL DEFAULT '';
+
+-- setup.sh stored ip_address as INET, which rejects the empty address of
+-- sessions opened without one
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'sessions' AND column_name = 'ip_address'
+                 AND data_type = 'inet') THEN
+        ALTER TABLE sessions ALTER COLUMN ip_address TYPE TEXT USING COALESCE(host(ip_address), '');
+    END IF;
+END
+$$;
+
+UPDATE sessions SET ip_address = '' WHERE ip_address IS NULL;
+UPDATE sessions SET user_agent = '' WHERE user_agent IS NULL;
+UPDATE sessions SET is_revoked = FALSE WHERE is_revoked IS NULL;
+UPDATE sessions SET created_at = NOW() WHERE created_at IS NULL;
+UPDATE sessions SET last_used_at = created_at WHERE last_used_at IS NULL;
+DELETE FROM sessions WHERE user_id IS NULL;
+
+ALTER TABLE sessions
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN ip_address SET DEFAULT '',
+    ALTER COLUMN ip_address SET NOT NULL,
+    ALTER COLUMN user_agent SET DEFAULT '',
+    ALTER COLUMN user_agent SET NOT NULL,
+    ALTER COLUMN is_revoked SET NOT NULL,
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN last_used_at SET NOT NULL;
+
+-- +migrate Down
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,55 @@
+-- Migration: hash_refresh_tokens
+-- Created: Sat Oct 17 12:00:00 UTC 2026
+-- Description: Store refresh tokens as their SHA-256 hex digest instead of in
+-- plaintext. Only databases still holding the refresh_token column of
+-- setup.sh are converted; those created by create_users_and_sessions
+-- already store hashes.
+
+-- +migrate Up
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'sessions' AND column_name = 'refresh_token') THEN
+        RETURN;
+    END IF;
+
+    -- setup.sh never had previous_refresh_token
+    ALTER TABLE sessions
+        ADD COLUMN IF NOT EXISTS previous_refresh_token TEXT NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS refresh_token_hash TEXT,
+        ADD COLUMN IF NOT EXISTS previous_refresh_token_hash TEXT NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS rotation_nonce TEXT NOT NULL DEFAULT '';
+
+    UPDATE sessions SET
+        refresh_token_hash = encode(sha256(convert_to(refresh_token, 'UTF8')), 'hex'),
+        previous_refresh_token_hash = CASE WHEN previous_refresh_token = '' THEN ''
+            ELSE encode(sha256(convert_to(previous_refresh_token, 'UTF8')), 'hex') END;
+
+    ALTER TABLE sessions
+        ALTER COLUMN refresh_token_hash SET NOT NULL,
+        DROP COLUMN refresh_token,
+        DROP COLUMN previous_refresh_token;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_refresh_token_hash_key ON sessions (refresh_token_hash);
+CREATE INDEX IF NOT EXISTS sessions_previous_refresh_token_hash_idx ON sessions (previous_refresh_token_hash);
+
+-- +migrate Down
+-- The plaintext tokens cannot be recovered, so every session is revoked and
+-- its users log in again
+DROP INDEX sessions_previous_refresh_token_hash_idx;
+DROP INDEX sessions_refresh_token_hash_key;
+
+ALTER TABLE sessions
+    ADD COLUMN refresh_token TEXT,
+    ADD COLUMN previous_refresh_token TEXT NOT NULL DEFAULT '';
+
+UPDATE sessions SET refresh_token = 'revoked:' || id, is_revoked = TRUE;
+
+ALTER TABLE sessions
+    ALTER COLUMN refresh_token SET NOT NULL,
+    ADD CONSTRAINT sessions_refresh_token_key UNIQUE (refresh_token),
+    DROP COLUMN refresh_token_hash,
+    DROP COLUMN previous_refresh_token_hash,
+    DROP COLUMN rotation_nonce;
@@ -0,0 +1,14 @@
+-- Migration: add_last_login_origin
+-- Created: Sat Oct 17 13:00:00 UTC 2026
+-- Description: Record the IP address and user agent of each user's last
+-- login, the baseline suspicious login detection compares new logins with.
+
+-- +migrate Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS last_login_ip TEXT,
+    ADD COLUMN IF NOT EXISTS last_login_user_agent TEXT;
+
+-- +migrate Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS last_login_ip,
+    DROP COLUMN IF EXISTS last_login_user_agent;
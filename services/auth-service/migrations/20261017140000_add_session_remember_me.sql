@@ -0,0 +1,13 @@
+-- Migration: add_session_remember_me
+-- Created: Sat Oct 17 14:00:00 UTC 2026
+-- Description: Record whether the user asked to be remembered at login,
+-- which picks the lifetime of the session. Existing sessions were opened
+-- with the long lifetime.
+
+-- +migrate Up
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS remember_me BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +migrate Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS remember_me;
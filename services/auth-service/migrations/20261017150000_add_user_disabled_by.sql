@@ -0,0 +1,13 @@
+-- Migration: add_user_disabled_by
+-- Created: Sat Oct 17 15:00:00 UTC 2026
+-- Description: Record the administrator who disabled an account. Disabled
+-- accounts are inactive like self-deactivated ones, but only an
+-- administrator can activate them again.
+
+-- +migrate Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS disabled_by UUID;
+
+-- +migrate Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS disabled_by;
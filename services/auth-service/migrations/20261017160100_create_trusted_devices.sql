@@ -0,0 +1,22 @@
+-- Migration: create_trusted_devices
+-- Created: Sat Oct 17 16:01:00 UTC 2026
+-- Description: Devices a user chose to trust after passing two-factor
+-- authentication, identified by the SHA-256 of their device token.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS trusted_devices (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash   TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS trusted_devices_token_hash_key ON trusted_devices (token_hash);
+CREATE INDEX IF NOT EXISTS trusted_devices_user_id_idx ON trusted_devices (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS trusted_devices;
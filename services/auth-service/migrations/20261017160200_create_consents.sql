@@ -0,0 +1,20 @@
+-- Migration: create_consents
+-- Created: Sat Oct 17 16:02:00 UTC 2026
+-- Description: Consent decisions of users per processing purpose. Records
+-- are only ever appended; the latest one per purpose is in effect.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS consents (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose      TEXT NOT NULL,
+    granted      BOOLEAN NOT NULL,
+    text_version TEXT NOT NULL DEFAULT '',
+    ip_address   TEXT NOT NULL DEFAULT '',
+    recorded_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS consents_user_id_purpose_recorded_at_idx ON consents (user_id, purpose, recorded_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS consents;
@@ -0,0 +1,10 @@
+-- Migration: add_users_created_at_index
+-- Created: Sat Oct 17 16:03:00 UTC 2026
+-- Description: Index registrations by time for the range scan of the signup
+-- funnel metrics.
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS users_created_at_idx;
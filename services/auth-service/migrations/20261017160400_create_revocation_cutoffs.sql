@@ -0,0 +1,18 @@
+-- Migration: create_revocation_cutoffs
+-- Created: Sat Oct 17 16:04:00 UTC 2026
+-- Description: Scheduled revocations. Tokens issued before a cutoff are
+-- rejected once it passes; cutoffs without a user apply to everyone.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS revocation_cutoffs (
+    id         UUID PRIMARY KEY,
+    user_id    UUID REFERENCES users (id) ON DELETE CASCADE,
+    cutoff_at  TIMESTAMPTZ NOT NULL,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS revocation_cutoffs_user_id_idx ON revocation_cutoffs (user_id, cutoff_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS revocation_cutoffs;
@@ -0,0 +1,19 @@
+-- Migration: create_mfa_methods
+-- Created: Sat Oct 17 16:05:00 UTC 2026
+-- Description: Second factors enrolled by users. The record describes the
+-- enrollment only; secrets stay with the factor's own storage.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS mfa_methods (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL,
+    label      TEXT NOT NULL DEFAULT '',
+    preferred  BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS mfa_methods_user_id_idx ON mfa_methods (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS mfa_methods;
@@ -0,0 +1,22 @@
+-- Migration: create_oauth_clients
+-- Created: Sat Oct 17 16:06:00 UTC 2026
+-- Description: Trusted OAuth client applications registered by
+-- administrators. Only the hash of a client secret is stored.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id            UUID PRIMARY KEY,
+    client_id     TEXT NOT NULL,
+    secret_hash   TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    scopes        TEXT[] NOT NULL DEFAULT '{}',
+    grant_types   TEXT[] NOT NULL DEFAULT '{}',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS oauth_clients_client_id_key ON oauth_clients (client_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS oauth_clients;
@@ -0,0 +1,27 @@
+-- Migration: create_reverification_jobs
+-- Created: Sat Oct 17 16:07:00 UTC 2026
+-- Description: Bulk email re-verification jobs. cursor is the last user
+-- processed, so a failed or interrupted job resumes after it.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS reverification_jobs (
+    id              UUID PRIMARY KEY,
+    idempotency_key TEXT NOT NULL DEFAULT '',
+    campus_id       TEXT,
+    status          TEXT NOT NULL,
+    cursor          UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    processed       INTEGER NOT NULL DEFAULT 0,
+    sent            INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    created_by      UUID NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reverification_jobs_idempotency_key_key ON reverification_jobs (idempotency_key)
+    WHERE idempotency_key <> '';
+CREATE INDEX IF NOT EXISTS reverification_jobs_status_idx ON reverification_jobs (status, created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS reverification_jobs;
@@ -0,0 +1,24 @@
+-- Migration: create_device_logins
+-- Created: Sat Oct 17 16:08:00 UTC 2026
+-- Description: Logins on shared kiosks, approved by the user from another
+-- device. Only the hash of the device code is stored.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS device_logins (
+    id               UUID PRIMARY KEY,
+    kiosk_id         TEXT NOT NULL,
+    device_code_hash TEXT NOT NULL,
+    user_code        TEXT NOT NULL,
+    user_id          UUID REFERENCES users (id) ON DELETE CASCADE,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at       TIMESTAMPTZ NOT NULL,
+    approved_at      TIMESTAMPTZ,
+    last_polled_at   TIMESTAMPTZ,
+    consumed_at      TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS device_logins_device_code_hash_key ON device_logins (device_code_hash);
+CREATE INDEX IF NOT EXISTS device_logins_user_code_idx ON device_logins (user_code) WHERE user_id IS NULL;
+
+-- +migrate Down
+DROP TABLE IF EXISTS device_logins;
@@ -0,0 +1,20 @@
+-- Migration: create_signing_keys
+-- Created: Sat Oct 17 16:09:00 UTC 2026
+-- Description: Token signing keys, one per generation. A rotation adds the
+-- next generation and retires the older ones after a grace period.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id           UUID PRIMARY KEY,
+    generation   INTEGER NOT NULL,
+    algorithm    TEXT NOT NULL,
+    material     TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    activates_at TIMESTAMPTZ NOT NULL,
+    retires_at   TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS signing_keys_generation_key ON signing_keys (generation);
+
+-- +migrate Down
+DROP TABLE IF EXISTS signing_keys;
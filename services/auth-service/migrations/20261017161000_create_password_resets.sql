@@ -0,0 +1,20 @@
+-- Migration: create_password_resets
+-- Created: Sat Oct 17 16:10:00 UTC 2026
+-- Description: Time-limited password reset tokens. Only the SHA-256 of a
+-- token is stored, and each can be used once.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS password_resets_token_hash_key ON password_resets (token_hash);
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS password_resets;
@@ -0,0 +1,23 @@
+-- Migration: create_verification_tokens
+-- Created: Sat Oct 17 16:11:00 UTC 2026
+-- Description: Single-use tokens confirming an email address, a recovery
+-- email, a phone number or an email change. target is the address the
+-- token confirms.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS verification_tokens (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    TEXT NOT NULL,
+    target     TEXT NOT NULL DEFAULT '',
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS verification_tokens_token_hash_key ON verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS verification_tokens_user_id_purpose_idx ON verification_tokens (user_id, purpose, created_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS verification_tokens;
@@ -0,0 +1,27 @@
+-- Migration: create_audit_logs
+-- Created: Sat Oct 17 16:12:00 UTC 2026
+-- Description: The hash-chained audit log. Each entry carries its position
+-- in the chain, the hash of the entry before it and its own hash. Entries
+-- written before the chain existed have no seq and are outside it.
+-- user_id has no foreign key so that the log outlives purged accounts.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    action     TEXT NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    metadata   JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE audit_logs
+    ADD COLUMN IF NOT EXISTS seq       BIGINT,
+    ADD COLUMN IF NOT EXISTS prev_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash      TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_logs_seq_key ON audit_logs (seq);
+
+-- +migrate Down
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,10 @@
+-- Migration: add_audit_logs_user_index
+-- Created: Sat Oct 17 16:13:00 UTC 2026
+-- Description: Index audit entries by user, newest first, for the per-user
+-- audit listing and its count.
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS audit_logs_user_id_created_at_idx ON audit_logs (user_id, created_at DESC, seq DESC);
+
+-- +migrate Down
+DROP INDEX IF EXISTS audit_logs_user_id_created_at_idx;
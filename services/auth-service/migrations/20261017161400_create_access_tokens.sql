@@ -0,0 +1,24 @@
+-- Migration: create_access_tokens
+-- Created: Sat Oct 17 16:14:00 UTC 2026
+-- Description: Opaque access tokens and the per-client choice of access
+-- token format. An opaque token is stored as its SHA-256 with the claims it
+-- stands for, and dies with its session.
+
+-- +migrate Up
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS access_token_format TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+    token_hash TEXT PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    -- the JSON encoded claims, written and read as raw bytes
+    claims     BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS access_tokens_session_id_idx ON access_tokens (session_id);
+CREATE INDEX IF NOT EXISTS access_tokens_expires_at_idx ON access_tokens (expires_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS access_tokens;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS access_token_format;
@@ -0,0 +1,17 @@
+-- Migration: create_app_authorizations
+-- Created: Sat Oct 17 16:15:00 UTC 2026
+-- Description: The scopes each user has granted to each OAuth client, one
+-- row per pair; later grants add to the scopes.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS app_authorizations (
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id    TEXT NOT NULL REFERENCES oauth_clients (client_id) ON DELETE CASCADE,
+    scopes       TEXT[] NOT NULL DEFAULT '{}',
+    granted_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS app_authorizations;
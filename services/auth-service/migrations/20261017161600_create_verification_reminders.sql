@@ -0,0 +1,14 @@
+-- Migration: create_verification_reminders
+-- Created: Sat Oct 17 16:16:00 UTC 2026
+-- Description: How many verification reminders each unverified user has
+-- been sent, and when the last one went out.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS verification_reminders (
+    user_id      UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    count        INTEGER NOT NULL DEFAULT 0,
+    last_sent_at TIMESTAMPTZ NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS verification_reminders;
@@ -0,0 +1,21 @@
+-- Migration: create_delegations
+-- Created: Sat Oct 17 16:17:00 UTC 2026
+-- Description: Read access to parts of an account that its owner, the
+-- grantor, delegated to another user such as an advisor or guardian.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS delegations (
+    id          UUID PRIMARY KEY,
+    grantor_id  UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    delegate_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scopes      TEXT[] NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS delegations_grantor_id_idx ON delegations (grantor_id);
+CREATE INDEX IF NOT EXISTS delegations_delegate_id_idx ON delegations (delegate_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS delegations;
@@ -0,0 +1,20 @@
+-- Migration: create_oauth_identities
+-- Created: Sat Oct 17 16:18:00 UTC 2026
+-- Description: Accounts at external login providers, such as Google or a
+-- university SSO, linked to users. A provider account links to one user.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS oauth_identities (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    email      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS oauth_identities_provider_subject_key ON oauth_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS oauth_identities_user_id_idx ON oauth_identities (user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS oauth_identities;
@@ -0,0 +1,26 @@
+-- Migration: create_outbox_events
+-- Created: Sat Oct 17 16:19:00 UTC 2026
+-- Description: The transactional outbox. Events are written in the
+-- transaction of the change they describe and published by the dispatcher;
+-- dispatched events are purged after a retention period.
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id            UUID PRIMARY KEY,
+    dedupe_key    TEXT NOT NULL,
+    event_type    TEXT NOT NULL,
+    -- the JSON encoded event envelope, written and read as raw bytes
+    payload       BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts      INTEGER NOT NULL DEFAULT 0,
+    last_error    TEXT,
+    locked_until  TIMESTAMPTZ,
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS outbox_events_dedupe_key_key ON outbox_events (dedupe_key);
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (created_at) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_events_dispatched_at_idx ON outbox_events (dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+-- +migrate Down
+DROP TABLE IF EXISTS outbox_events;
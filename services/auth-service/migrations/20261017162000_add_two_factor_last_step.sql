@@ -0,0 +1,12 @@
+-- Migration: add_two_factor_last_step
+-- Created: Sat Oct 17 16:20:00 UTC 2026
+-- Description: Remember the TOTP time step of the user's last accepted
+-- code, so a code cannot be replayed within its validity window.
+
+-- +migrate Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS two_factor_last_step BIGINT;
+
+-- +migrate Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS two_factor_last_step;
@@ -0,0 +1,13 @@
+// Package migrations holds the versioned schema changes of the auth service,
+// embedded in the binary and applied in order by repo.Migrate. Files are
+// named YYYYMMDDHHMMSS_description.sql, as "tools/db/migrate.sh create"
+// names them; the statements after "-- +migrate Up" are applied, and those
+// after "-- +migrate Down" are for tools/db/migrate.sh rollbacks only.
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
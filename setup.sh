@@ -119,44 +119,17 @@ cd ../..
 # Create database tables
 echo -e "${BLUE}🗄️ Setting up databases...${NC}"
 
-# PostgreSQL tables
-echo -e "${YELLOW}Creating PostgreSQL tables...${NC}"
-docker-compose -f infra/docker-compose.yml exec -T postgres psql -U unibazzar -d unibazzar << 'EOSQL'
--- Users table
-CREATE TABLE IF NOT EXISTS users (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    email VARCHAR(255) UNIQUE NOT NULL,
-    password_hash VARCHAR(255) NOT NULL,
-    first_name VARCHAR(100) NOT NULL,
-    last_name VARCHAR(100) NOT NULL,
-    campus_id VARCHAR(100),
-    role VARCHAR(50) DEFAULT 'student',
-    is_active BOOLEAN DEFAULT true,
-    is_verified BOOLEAN DEFAULT false,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    last_login_at TIMESTAMP
-);
-
--- Sessions table
-CREATE TABLE IF NOT EXISTS sessions (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-    refresh_token VARCHAR(512) NOT NULL,
-    expires_at TIMESTAMP NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    ip_address INET,
-    user_agent TEXT,
-    is_revoked BOOLEAN DEFAULT false
-);
-
--- Create indexes
-CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
-CREATE INDEX IF NOT EXISTS idx_sessions_refresh_token ON sessions(refresh_token);
-
-EOSQL
+# PostgreSQL tables come from the embedded auth-service migrations, the only
+# definition of the schema. Databases bootstrapped by earlier versions of
+# this script are upgraded in place.
+echo -e "${YELLOW}Applying auth-service migrations...${NC}"
+(
+    cd services/auth-service
+    set -a
+    source configs/.env
+    set +a
+    go run ./cmd/server --migrate-only
+)
 
 # DynamoDB tables
 echo -e "${YELLOW}Creating DynamoDB tables...${NC}"